@@ -123,6 +123,31 @@ func IntersectCompressedWithBin(dec *codec.Decoder, q []uint64, o *[]uint64) {
 	}
 }
 
+// The ways IntersectWith intersects two lists.
+const (
+	IntersectLinear = "linear"
+	IntersectJump   = "jump"
+	IntersectBinary = "binary"
+)
+
+// IntersectStrategy returns how IntersectWith intersects lists of lengths n and m: linearly if
+// they have similar lengths, otherwise by jumping or binary searching through the longer one.
+func IntersectStrategy(n, m int) string {
+	if n > m {
+		n, m = m, n
+	}
+	if n == 0 {
+		n = 1
+	}
+	ratio := float64(m) / float64(n)
+	if ratio < 100 {
+		return IntersectLinear
+	} else if ratio < 500 {
+		return IntersectJump
+	}
+	return IntersectBinary
+}
+
 // IntersectWith intersects u with v. The update is made to o.
 // u, v should be sorted.
 func IntersectWith(u, v, o *pb.List) {
@@ -130,22 +155,19 @@ func IntersectWith(u, v, o *pb.List) {
 	m := len(v.Uids)
 
 	if n > m {
-		n, m = m, n
+		n = m
 	}
 	if o.Uids == nil {
 		o.Uids = make([]uint64, 0, n)
 	}
 	dst := o.Uids[:0]
-	if n == 0 {
-		n = 1
-	}
 	// Select appropriate function based on heuristics.
-	ratio := float64(m) / float64(n)
-	if ratio < 100 {
+	switch IntersectStrategy(len(u.Uids), len(v.Uids)) {
+	case IntersectLinear:
 		IntersectWithLin(u.Uids, v.Uids, &dst)
-	} else if ratio < 500 {
+	case IntersectJump:
 		IntersectWithJump(u.Uids, v.Uids, &dst)
-	} else {
+	default:
 		IntersectWithBin(u.Uids, v.Uids, &dst)
 	}
 	o.Uids = dst
@@ -414,3 +414,10 @@ func skipDuplicate(in []uint64, idx int) int {
 	}
 	return i
 }
+
+func TestIntersectStrategy(t *testing.T) {
+	require.Equal(t, IntersectLinear, IntersectStrategy(10, 500))
+	require.Equal(t, IntersectLinear, IntersectStrategy(0, 50))
+	require.Equal(t, IntersectJump, IntersectStrategy(2000, 10))
+	require.Equal(t, IntersectBinary, IntersectStrategy(10, 5000))
+}
@@ -144,6 +144,11 @@ func (w *RaftServer) IsPeer(ctx context.Context, rc *pb.RaftContext) (*pb.PeerRe
 			return &pb.PeerResponse{Status: true}, nil
 		}
 	}
+	for _, raftIdx := range node._confState.Learners {
+		if rc.Id == raftIdx {
+			return &pb.PeerResponse{Status: true}, nil
+		}
+	}
 	return &pb.PeerResponse{}, nil
 }
 
@@ -181,7 +186,7 @@ func (w *RaftServer) JoinCluster(ctx context.Context,
 	}
 	node.Connect(rc.Id, rc.Addr)
 
-	err := node.AddToCluster(context.Background(), rc.Id)
+	err := node.AddToCluster(context.Background(), rc.Id, rc.IsLearner)
 	glog.Infof("[%d] Done joining cluster with err: %v", rc.Id, err)
 	return &api.Payload{}, err
 }
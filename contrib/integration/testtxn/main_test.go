@@ -30,6 +30,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type state struct {
@@ -39,6 +40,9 @@ type state struct {
 var s state
 var addr string = "localhost:9180"
 
+// otherAddr is another Alpha of the cluster of addr.
+var otherAddr string = "localhost:9182"
+
 func TestMain(m *testing.M) {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
@@ -250,6 +254,43 @@ func TestTxnRead5(t *testing.T) {
 	x.AssertTrue(bytes.Equal(resp.Json, []byte(`{"me":[{"name":"Manish2"}]}`)))
 }
 
+// A read-only txn can't commit through an Alpha other than the one that started it.
+func TestReadOnlyTxnOtherAlpha(t *testing.T) {
+	op := &api.Operation{}
+	op.DropAll = true
+	require.NoError(t, s.dg.Alter(context.Background(), op))
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	dc := api.NewDgraphClient(conn)
+	other, err := grpc.Dial(otherAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer other.Close()
+	odc := api.NewDgraphClient(other)
+
+	q := `{ me(func: has(name)) { name }}`
+	roCtx := context.Background()
+	beCtx := metadata.AppendToOutgoingContext(context.Background(), "best-effort", "true")
+	for _, ctx := range []context.Context{roCtx, beCtx} {
+		resp, err := dc.Query(ctx, &api.Request{Query: q, ReadOnly: true})
+		require.NoError(t, err)
+		require.True(t, resp.Txn.StartTs > 0)
+
+		mu := &api.Mutation{
+			SetJson:   []byte(`{"name": "Manish"}`),
+			StartTs:   resp.Txn.StartTs,
+			CommitNow: true,
+		}
+		_, err = odc.Mutate(context.Background(), mu)
+		require.Error(t, err)
+
+		resp, err = dc.Query(context.Background(), &api.Request{Query: q})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"me":[]}`, string(resp.Json))
+	}
+}
+
 func TestConflict(t *testing.T) {
 	op := &api.Operation{}
 	op.DropAll = true
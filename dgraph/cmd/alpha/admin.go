@@ -19,11 +19,13 @@ package alpha
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/dgraph-io/dgraph/edgraph"
 	"github.com/dgraph-io/dgraph/posting"
@@ -46,6 +48,36 @@ func handlerInit(w http.ResponseWriter, r *http.Request, method string) bool {
 	return true
 }
 
+// auditAdmin records the requests to the admin endpoints in the audit log.
+func auditAdmin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/admin/") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ev := edgraph.NewAuditEvent("admin " + r.Method + " " + r.URL.Path)
+		ev.SetBody(r.URL.RawQuery)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+		var err error
+		if sw.status >= http.StatusBadRequest {
+			err = fmt.Errorf("HTTP status %d", sw.status)
+		}
+		ev.Done(attachCredentials(r.Context(), r), err)
+	})
+}
+
+// statusWriter remembers the status code written to an http.ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
 func shutDownHandler(w http.ResponseWriter, r *http.Request) {
 	if !handlerInit(w, r, http.MethodGet) {
 		return
@@ -61,7 +93,9 @@ func exportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Export logic can be moved to dgraphzero.
-	if err := worker.ExportOverNetwork(context.Background()); err != nil {
+	err := worker.ExportOverNetwork(context.Background(), r.FormValue("format"),
+		r.FormValue("destination"), formList(r, "predicates"), formList(r, "predicate_prefix"))
+	if err != nil {
 		x.SetStatus(w, err.Error(), "Export failed.")
 		return
 	}
@@ -69,6 +103,17 @@ func exportHandler(w http.ResponseWriter, r *http.Request) {
 	x.Check2(w.Write([]byte(`{"code": "Success", "message": "Export completed."}`)))
 }
 
+// formList returns the comma-separated values of the form value key, without the empty ones.
+func formList(r *http.Request, key string) []string {
+	var list []string
+	for _, val := range strings.Split(r.FormValue(key), ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			list = append(list, val)
+		}
+	}
+	return list
+}
+
 func memoryLimitHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -113,6 +158,50 @@ func memoryLimitGetHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// queryPolicyHandler returns the query policy on GET, and replaces it with the one in the body
+// on PUT. An empty body removes it. The policy set this way isn't persisted, --query_policy_file
+// is loaded again on restart.
+func queryPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !handlerInit(w, r, http.MethodGet) {
+			return
+		}
+		policy := edgraph.GetQueryPolicy()
+		if policy == nil {
+			policy = &edgraph.QueryPolicy{}
+		}
+		js, err := json.Marshal(policy)
+		if err != nil {
+			x.SetStatus(w, x.Error, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		x.Check2(w.Write(js))
+	case http.MethodPut:
+		if !handlerInit(w, r, http.MethodPut) {
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+			return
+		}
+		var policy *edgraph.QueryPolicy
+		if len(bytes.TrimSpace(body)) > 0 {
+			if policy, err = edgraph.ParseQueryPolicy(body); err != nil {
+				x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+				return
+			}
+		}
+		edgraph.SetQueryPolicy(policy)
+		w.Header().Set("Content-Type", "application/json")
+		x.Check2(w.Write([]byte(`{"code": "Success", "message": "Query policy updated."}`)))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func ipInIPWhitelistRanges(ipString string) bool {
 	ip := net.ParseIP(ipString)
 
@@ -41,7 +41,13 @@ func backupHandler(w http.ResponseWriter, r *http.Request) {
 		x.SetStatus(w, err.Error(), "Backup failed.")
 		return
 	}
-	if err := worker.BackupOverNetwork(context.Background(), target); err != nil {
+	incremental := r.FormValue("incremental") == "true"
+	compression := r.FormValue("compression")
+	prefixes := formList(r, "predicate_prefix")
+	skipIndexes := r.FormValue("include_indexes") == "false"
+	err := worker.BackupOverNetwork(context.Background(), target, incremental, compression,
+		prefixes, skipIndexes)
+	if err != nil {
 		x.SetStatus(w, err.Error(), "Backup failed.")
 		return
 	}
@@ -0,0 +1,54 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alpha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/dgraph-io/dgraph/worker"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// replicationHandler returns how far this cluster replicated the backups of --replicate_from.
+func replicationHandler(w http.ResponseWriter, r *http.Request) {
+	if !handlerInit(w, r, http.MethodGet) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	status, err := worker.ReplicationStatusOverNetwork(ctx)
+	if err != nil {
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+	js, err := json.Marshal(status)
+	if err != nil {
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	x.Check2(w.Write(js))
+}
+
+func init() {
+	http.HandleFunc("/admin/replication", replicationHandler)
+}
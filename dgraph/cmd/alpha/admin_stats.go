@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alpha
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/worker"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// predicateStats is the JSON of the statistics of a predicate, with the counts that are zero.
+type predicateStats struct {
+	Predicate   string   `json:"predicate"`
+	Group       uint32   `json:"group"`
+	Type        string   `json:"type,omitempty"`
+	Indexes     []string `json:"indexes,omitempty"`
+	Reverse     bool     `json:"reverse,omitempty"`
+	Count       bool     `json:"count,omitempty"`
+	DataKeys    uint64   `json:"data_keys"`
+	IndexKeys   uint64   `json:"index_keys"`
+	ReverseKeys uint64   `json:"reverse_keys"`
+	CountKeys   uint64   `json:"count_keys"`
+	SizeBytes   int64    `json:"size_bytes"`
+	Hits        uint64   `json:"hits"`
+}
+
+// predicateStatsHandler returns the statistics of the predicates of all the groups, or of those
+// given by the predicates and predicate_prefix form values.
+func predicateStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !handlerInit(w, r, http.MethodGet) {
+		return
+	}
+	stats, err := worker.PredicateStatsOverNetwork(context.Background())
+	if err != nil {
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+
+	preds, prefixes := formList(r, "predicates"), formList(r, "predicate_prefix")
+	res := struct {
+		Predicates []*predicateStats `json:"predicates"`
+	}{Predicates: []*predicateStats{}}
+	for _, ps := range stats {
+		if !wantPredicate(ps.Predicate, preds, prefixes) {
+			continue
+		}
+		res.Predicates = append(res.Predicates, newPredicateStats(ps))
+	}
+	js, err := json.Marshal(res)
+	if err != nil {
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	x.Check2(w.Write(js))
+}
+
+func newPredicateStats(ps *pb.PredicateStats) *predicateStats {
+	return &predicateStats{
+		Predicate:   ps.Predicate,
+		Group:       ps.GroupId,
+		Type:        ps.Type,
+		Indexes:     ps.Tokenizers,
+		Reverse:     ps.Reverse,
+		Count:       ps.Count,
+		DataKeys:    ps.DataKeys,
+		IndexKeys:   ps.IndexKeys,
+		ReverseKeys: ps.ReverseKeys,
+		CountKeys:   ps.CountKeys,
+		SizeBytes:   ps.Space,
+		Hits:        ps.Hits,
+	}
+}
+
+// wantPredicate returns whether pred is one of preds or has one of prefixes. All are wanted if
+// neither is given.
+func wantPredicate(pred string, preds, prefixes []string) bool {
+	if len(preds) == 0 && len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range preds {
+		if pred == p {
+			return true
+		}
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(pred, p) {
+			return true
+		}
+	}
+	return false
+}
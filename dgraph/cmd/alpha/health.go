@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alpha
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/dgraph/worker"
+	"github.com/dgraph-io/dgraph/x"
+	"golang.org/x/net/context"
+)
+
+// clusterHealthCheck writes the health of every Alpha of the cluster, for /health?all=true.
+// It answers 503 if this Alpha is unhealthy or, given max_lag, lags further behind the leader
+// of its group, so it can serve as a readiness probe.
+func clusterHealthCheck(w http.ResponseWriter, r *http.Request) {
+	var maxLag uint64
+	checkLag := r.URL.Query().Get("max_lag") != ""
+	if checkLag {
+		var err error
+		if maxLag, err = strconv.ParseUint(r.URL.Query().Get("max_lag"), 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			x.SetStatus(w, x.ErrorInvalidRequest, "max_lag must be a number of Raft entries")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	members := worker.ClusterHealth(ctx)
+
+	status := http.StatusOK
+	if err := x.HealthCheck(); err != nil {
+		status = http.StatusServiceUnavailable
+	}
+	for _, mh := range members {
+		if checkLag && mh.Self && (mh.Lag == nil || *mh.Lag > maxLag) {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	js, err := json.Marshal(members)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
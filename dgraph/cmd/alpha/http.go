@@ -35,7 +35,10 @@ import (
 	"github.com/dgraph-io/dgraph/worker"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/golang/glog"
+	otrace "go.opencensus.io/trace"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 func allowed(method string) bool {
@@ -60,6 +63,84 @@ func extractStartTs(urlPath string) (uint64, error) {
 	}
 }
 
+// isJSONContent returns whether the body of the request is JSON, as given by its Content-Type.
+// attachCredentials passes the credentials of an HTTP request to the server like those of a
+// gRPC request, to check them against the ACLs: the access jwt of the X-Dgraph-AccessToken
+// header as the accessjwt metadata, the auth token of the X-Dgraph-AuthToken header as the
+// auth-token metadata, and the client and its TLS connection as the peer.
+func attachCredentials(ctx context.Context, r *http.Request) context.Context {
+	p := &peer.Peer{Addr: httpAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	ctx = peer.NewContext(ctx, p)
+	accessJwt := r.Header.Get("X-Dgraph-AccessToken")
+	authToken := r.Header.Get("X-Dgraph-AuthToken")
+	if accessJwt == "" && authToken == "" {
+		return ctx
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.New(nil)
+	}
+	if accessJwt != "" {
+		md["accessjwt"] = []string{accessJwt}
+	}
+	if authToken != "" {
+		md["auth-token"] = []string{authToken}
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// attachQueryLimits attaches the limits of the query asked for by the headers of r to ctx,
+// like the metadata of a gRPC request.
+func attachQueryLimits(ctx context.Context, r *http.Request) context.Context {
+	headers := map[string]string{
+		"X-Dgraph-Timeout":   "query-timeout",
+		"X-Dgraph-Max-Depth": "query-max-depth",
+		"X-Dgraph-Max-Edges": "query-max-edges",
+		"X-Dgraph-Partial":   "query-partial",
+	}
+	var md metadata.MD
+	for header, key := range headers {
+		val := r.Header.Get(header)
+		if val == "" {
+			continue
+		}
+		if md == nil {
+			if old, ok := metadata.FromIncomingContext(ctx); ok {
+				md = old.Copy()
+			} else {
+				md = metadata.New(nil)
+			}
+		}
+		md[key] = []string{val}
+	}
+	if md == nil {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// requestContext returns the context to process an HTTP request in, with the span of the
+// request, which continues the trace of the client, but not cancelled with the request.
+func requestContext(r *http.Request) context.Context {
+	return otrace.NewContext(context.Background(), otrace.FromContext(r.Context()))
+}
+
+// httpAddr is the address of the client of an HTTP request.
+type httpAddr string
+
+func (a httpAddr) Network() string { return "tcp" }
+func (a httpAddr) String() string  { return string(a) }
+
+func isJSONContent(r *http.Request) bool {
+	ct := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+	return strings.EqualFold(ct, "application/json")
+}
+
 // This method should just build the request and proxy it to the Query method of dgraph.Server.
 // It can then encode the response as appropriate before sending it back to the user.
 func queryHandler(w http.ResponseWriter, r *http.Request) {
@@ -85,10 +166,9 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 	req.StartTs = ts
 
 	if vars := r.Header.Get("X-Dgraph-Vars"); vars != "" {
-		req.Vars = map[string]string{}
-		if err := json.Unmarshal([]byte(vars), &req.Vars); err != nil {
+		if req.Vars, err = gql.ParseVariables([]byte(vars)); err != nil {
 			x.SetStatus(w, x.ErrorInvalidRequest,
-				"Error while unmarshalling Vars header into map")
+				"Error while unmarshalling Vars header into map: "+err.Error())
 			return
 		}
 	}
@@ -101,8 +181,28 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	req.Query = string(q)
 
+	if isJSONContent(r) {
+		// The query and its variables, with values of their JSON types.
+		var body struct {
+			Query     string          `json:"query"`
+			Variables json.RawMessage `json:"variables"`
+		}
+		if err := json.Unmarshal(q, &body); err != nil {
+			x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+			return
+		}
+		req.Query = body.Query
+		if len(body.Variables) > 0 && string(body.Variables) != "null" {
+			if req.Vars, err = gql.ParseVariables(body.Variables); err != nil {
+				x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+				return
+			}
+		}
+	}
+
 	d := r.URL.Query().Get("debug")
-	ctx := context.WithValue(context.Background(), "debug", d)
+	ctx := context.WithValue(requestContext(r), "debug", d)
+	ctx = context.WithValue(ctx, "explain", r.URL.Query().Get("explain"))
 
 	// If ro is set, run this as a readonly query.
 	if ro := r.URL.Query().Get("ro"); len(ro) > 0 && req.StartTs == 0 {
@@ -110,6 +210,17 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 			req.ReadOnly = true
 		}
 	}
+	// If be is set, run this as a best-effort query, which is also readonly.
+	if be := r.URL.Query().Get("be"); be == "true" || be == "1" {
+		ctx = context.WithValue(ctx, "best-effort", "true")
+	}
+
+	ctx = attachCredentials(ctx, r)
+	ctx = attachQueryLimits(ctx, r)
+	var truncated bool
+	ctx = edgraph.WithTruncatedFlag(ctx, &truncated)
+	var plan []*query.ExplainStep
+	ctx = edgraph.WithExplainPlan(ctx, &plan)
 
 	// Core processing happens here.
 	resp, err := (&edgraph.Server{}).Query(ctx, &req)
@@ -128,8 +239,10 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	e := query.Extensions{
-		Txn:     resp.Txn,
-		Latency: resp.Latency,
+		Txn:       resp.Txn,
+		Latency:   resp.Latency,
+		Truncated: truncated,
+		Explain:   plan,
 	}
 	js, err := json.Marshal(e)
 	if err != nil {
@@ -185,6 +298,8 @@ func mutationHandler(w http.ResponseWriter, r *http.Request) {
 	parseStart := time.Now()
 
 	var mu *api.Mutation
+	// up is set for an upsert: a query, and mutations applied depending on its results.
+	var up *gql.Upsert
 	if mType := r.Header.Get("X-Dgraph-MutationType"); mType == "json" {
 		// Parse JSON.
 		ms := make(map[string]*skipJSONUnmarshal)
@@ -201,6 +316,19 @@ func mutationHandler(w http.ResponseWriter, r *http.Request) {
 		if delJSON, ok := ms["delete"]; ok && delJSON != nil {
 			mu.DeleteJson = delJSON.bs
 		}
+		if queryJSON, ok := ms["query"]; ok && queryJSON != nil {
+			if up, err = parseJSONUpsert(queryJSON.bs, ms["cond"], mu); err != nil {
+				x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+				return
+			}
+			mu = &api.Mutation{}
+		}
+	} else if gql.IsUpsert(string(m)) {
+		if up, err = gql.ParseUpsert(string(m)); err != nil {
+			x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+			return
+		}
+		mu = &api.Mutation{}
 	} else {
 		// Parse NQuads.
 		mu, err = gql.ParseMutation(string(m))
@@ -231,7 +359,13 @@ func mutationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	mu.StartTs = ts
 
-	resp, err := (&edgraph.Server{}).Mutate(context.Background(), mu)
+	ctx := attachCredentials(requestContext(r), r)
+	var resp *api.Assigned
+	if up != nil {
+		resp, err = (&edgraph.Server{}).Upsert(ctx, up, mu)
+	} else {
+		resp, err = (&edgraph.Server{}).Mutate(ctx, mu)
+	}
 	if err != nil {
 		x.SetStatusWithData(w, x.ErrorInvalidRequest, err.Error())
 		return
@@ -264,6 +398,26 @@ func mutationHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(js)
 }
 
+// parseJSONUpsert returns the upsert of a JSON mutation with a query, applying mu if the
+// optional condition holds.
+func parseJSONUpsert(queryJSON []byte, condJSON *skipJSONUnmarshal,
+	mu *api.Mutation) (*gql.Upsert, error) {
+	up := &gql.Upsert{Mutations: []*gql.CondMutation{{Mutation: mu}}}
+	if err := json.Unmarshal(queryJSON, &up.Query); err != nil {
+		return nil, err
+	}
+	if condJSON == nil {
+		return up, nil
+	}
+	var cond string
+	if err := json.Unmarshal(condJSON.bs, &cond); err != nil {
+		return nil, err
+	}
+	var err error
+	up.Mutations[0].Cond, err = gql.ParseCond(cond)
+	return up, err
+}
+
 func commitHandler(w http.ResponseWriter, r *http.Request) {
 	x.AddCorsHeaders(w)
 	w.Header().Set("Content-Type", "application/json")
@@ -312,7 +466,12 @@ func commitHandler(w http.ResponseWriter, r *http.Request) {
 
 	tc.Keys = encodedKeys
 
-	cts, err := worker.CommitOverNetwork(context.Background(), tc)
+	ctx := attachCredentials(requestContext(r), r)
+	ev := edgraph.NewAuditEvent("commit")
+	ev.StartTs = ts
+	cts, err := worker.CommitOverNetwork(ctx, tc)
+	ev.CommitTs = cts
+	ev.Done(ctx, err)
 	if err != nil {
 		x.SetStatus(w, x.Error, err.Error())
 		return
@@ -370,7 +529,11 @@ func abortHandler(w http.ResponseWriter, r *http.Request) {
 	tc.StartTs = ts
 	tc.Aborted = true
 
-	_, aerr := worker.CommitOverNetwork(context.Background(), tc)
+	ctx := attachCredentials(requestContext(r), r)
+	ev := edgraph.NewAuditEvent("abort")
+	ev.StartTs = ts
+	_, aerr := worker.CommitOverNetwork(ctx, tc)
+	ev.Done(ctx, aerr)
 	if aerr != nil {
 		x.SetStatus(w, x.Error, aerr.Error())
 		return
@@ -425,7 +588,7 @@ func alterHandler(w http.ResponseWriter, r *http.Request) {
 	md := metadata.New(nil)
 	// Pass in an auth token, if present.
 	md.Append("auth-token", r.Header.Get("X-Dgraph-AuthToken"))
-	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ctx := attachCredentials(metadata.NewIncomingContext(requestContext(r), md), r)
 	if _, err = (&edgraph.Server{}).Alter(ctx, op); err != nil {
 		x.SetStatus(w, x.Error, err.Error())
 		return
@@ -185,6 +185,69 @@ func TestTransactionBasic(t *testing.T) {
 	require.Equal(t, `{"data":{"balances":[{"name":"Bob","balance":"110"}]}}`, data)
 }
 
+func TestQueryTypedVariables(t *testing.T) {
+	require.NoError(t, dropAll())
+	require.NoError(t, alterSchema(`name: string @index(exact) .`))
+	require.NoError(t, runMutation(`
+	{
+	  set {
+		_:a <name> "Alice" .
+		_:b <name> "Bob" .
+		_:c <name> "Carol" .
+	  }
+	}
+	`))
+
+	body := `{
+	  "query": "query q($names: [string], $n: int) { me(func: eq(name, $names), orderasc: name, first: $n) { name } }",
+	  "variables": {"names": ["Alice", "Bob\" ) { uid } }", "Carol"], "n": 2}
+	}`
+	req, err := http.NewRequest("POST", addr+"/query", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	_, out, err := runRequest(req)
+	require.NoError(t, err)
+
+	var r res
+	require.NoError(t, json.Unmarshal(out, &r))
+	require.JSONEq(t, `{"me":[{"name":"Alice"},{"name":"Carol"}]}`, string(r.Data))
+}
+
+func TestReadOnlyTxn(t *testing.T) {
+	require.NoError(t, dropAll())
+	require.NoError(t, alterSchema(`name: string @index(exact) .`))
+	require.NoError(t, runMutation(`
+	{
+	  set {
+		_:a <name> "Alice" .
+	  }
+	}
+	`))
+
+	q := `{ me(func: eq(name, "Alice")) { name } }`
+	for _, params := range []string{"?ro=true", "?be=true"} {
+		req, err := http.NewRequest("POST", addr+"/query"+params, bytes.NewBufferString(q))
+		require.NoError(t, err)
+		_, out, err := runRequest(req)
+		require.NoError(t, err)
+
+		var r res
+		require.NoError(t, json.Unmarshal(out, &r))
+		require.JSONEq(t, `{"me":[{"name":"Alice"}]}`, string(r.Data))
+
+		m := `
+		{
+		  set {
+			_:b <name> "Bob" .
+		  }
+		}
+		`
+		_, _, err = mutationWithTs(m, false, true, false, r.Extensions.Txn.StartTs)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Mutations aren't allowed in read-only transaction")
+	}
+}
+
 func TestAlterAllFieldsShouldBeSet(t *testing.T) {
 	req, err := http.NewRequest("PUT", "/alter", bytes.NewBufferString(
 		`{"dropall":true}`, // "dropall" is spelt incorrect - should be "drop_all"
@@ -34,8 +34,11 @@ import (
 	"time"
 
 	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/tracing"
 	"github.com/dgraph-io/dgraph/edgraph"
 	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/query"
 	"github.com/dgraph-io/dgraph/schema"
 	"github.com/dgraph-io/dgraph/tok"
 	"github.com/dgraph-io/dgraph/worker"
@@ -43,8 +46,6 @@ import (
 	"github.com/golang/glog"
 	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
-	"go.opencensus.io/exporter/jaeger"
-	"go.opencensus.io/plugin/ocgrpc"
 	otrace "go.opencensus.io/trace"
 	"go.opencensus.io/zpages"
 	"golang.org/x/net/context"
@@ -100,7 +101,7 @@ they form a Raft group and provide synchronous replication.
 
 	// OpenCensus flags.
 	flag.Float64("trace", 1.0, "The ratio of queries to trace.")
-	flag.String("jaeger.collector", "", "Send opencensus traces to Jaeger.")
+	tracing.RegisterFlags(flag)
 
 	flag.StringP("wal", "w", "w", "Directory to store raft write-ahead logs.")
 	flag.Bool("nomutations", false, "Don't allow mutations on this server.")
@@ -108,6 +109,11 @@ they form a Raft group and provide synchronous replication.
 		"A comma separated list of IP ranges you wish to whitelist for performing admin "+
 			"actions (i.e., --whitelist 127.0.0.1:127.0.0.3,0.0.0.7:0.0.0.9)")
 	flag.String("export", "export", "Folder in which to store exports.")
+	flag.Int("export_workers", 4, "Number of files of an export written in parallel.")
+	flag.Int64("export_part_mb", 1024, "Size in MB of the compressed files the data of an "+
+		"export is split into. 0 to not split it.")
+	flag.Bool("cdc", false, "Stream the committed mutations of the group of this Alpha to the"+
+		" subscribers of the Subscribe RPC of its internal gRPC port.")
 	flag.Int("pending_proposals", 256,
 		"Number of pending mutation proposals. Useful for rate limiting.")
 	flag.String("my", "",
@@ -116,6 +122,13 @@ they form a Raft group and provide synchronous replication.
 		"IP_ADDRESS:PORT of a Dgraph Zero.")
 	flag.Uint64("idx", 0,
 		"Optional Raft ID that this Dgraph Alpha will use to join RAFT groups.")
+	flag.Bool("learner", false, "Join a group as a learner, which replicates the group and"+
+		" serves best-effort queries, but never votes or becomes its leader.")
+	flag.Uint32("learner_group", 0, "The group a learner joins. By default, the group with the"+
+		" fewest learners.")
+	flag.Float64("snapshot_rate_limit", 0, "Maximum rate in MB/s of the snapshots sent by the"+
+		" leader of a group to the members joining or falling behind, shared by all of them."+
+		" Zero for no limit.")
 	flag.Bool("expand_edge", true,
 		"Enables the expand() feature. This is very expensive for large data loads because it"+
 			" doubles the number of mutations going on in the system.")
@@ -128,10 +141,56 @@ they form a Raft group and provide synchronous replication.
 			" For Grpc, in auth-token key in the context.")
 	flag.String("hmac_secret_file", "", "The file storing the HMAC secret"+
 		" that is used for signing the JWT. Enterprise feature.")
+	flag.String("encryption_key_file", "", "The file storing the AES key (16, 24 or 32 bytes)"+
+		" used to encrypt backups. Enterprise feature.")
+	flag.Float64("backup_rate_limit", 0, "Maximum rate of backup uploads and restore"+
+		" downloads in MB/s, zero for no limit. Enterprise feature.")
+	flag.String("backup_schedule", "", "Schedule of the backups taken automatically to"+
+		" --backup_destination, in cron format or like \"@daily\" or \"@every 6h\", in UTC."+
+		" Enterprise feature.")
+	flag.String("backup_destination", "", "Location of the scheduled backups, the same as"+
+		" the destination of /admin/backup. Enterprise feature.")
+	flag.Int("backup_full_every", 1, "Number of scheduled backups in a chain: a full backup"+
+		" followed by incremental ones. Zero takes only incremental backups after the first"+
+		" full one. Enterprise feature.")
+	flag.String("backup_compression", "", "Compression of the scheduled backups: gzip or"+
+		" none. Enterprise feature.")
+	flag.Bool("backup_include_indexes", true, "Include the index, reverse and count keys in"+
+		" the scheduled backups. Restore rebuilds them if they aren't. Enterprise feature.")
+	flag.String("replicate_from", "", "Location of the backups of another cluster, like the"+
+		" --backup_destination of its scheduled backups, to keep replicating into this cluster."+
+		" This cluster then only serves queries. Enterprise feature.")
+	flag.Duration("replicate_interval", time.Minute, "How often to look for new backups to"+
+		" replicate at --replicate_from. Enterprise feature.")
 	flag.Duration("access_jwt_ttl", 6*time.Hour, "The TTL for the access jwt. "+
 		"Enterprise feature.")
 	flag.Duration("refresh_jwt_ttl", 30*24*time.Hour, "The TTL for the refresh jwt. "+
 		"Enterprise feature.")
+	flag.String("query_policy_file", "", "JSON file with the query policy, which restricts the"+
+		" functions, predicates and shapes of the queries of clients that are not admins."+
+		" It can be changed at /admin/query_policy.")
+	flag.Duration("query_timeout", 0, "Time a query can run for before it's stopped with an"+
+		" error, zero for no limit. Requests can ask for a lower one.")
+	flag.Int("query_max_depth", 0, "Number of nested levels a query block can have, zero for no"+
+		" limit. Requests can ask for a lower one.")
+	flag.Uint64("query_max_edges", 0, "Number of edges, uids and values, a query can return,"+
+		" zero for no limit. Requests can ask for a lower one.")
+	flag.Bool("query_partial", false, "Return the results found within --query_max_depth and"+
+		" --query_max_edges, marked as truncated, instead of an error when a query goes"+
+		" beyond them.")
+	flag.String("audit_output", "", "File to write the audit log of queries, mutations and"+
+		" admin operations to, or \"syslog\". Auditing is off if empty.")
+	flag.Int("audit_max_size_mb", 100, "Size in MB of the audit log file before it's rotated,"+
+		" zero to never rotate it.")
+	flag.Int("audit_max_backups", 10, "Number of rotated audit log files to keep.")
+	flag.Bool("audit_bodies", false, "Include the text of the queries, mutations and schema"+
+		" updates in the audit log.")
+	flag.Duration("slow_query_threshold", 0, "Latency above which a query is written to the"+
+		" slow query log, like 500ms. The log is off if zero.")
+	flag.String("slow_query_log", "slow_query.log", "File to write the slow queries to.")
+	flag.Int("slow_query_max_size_mb", 100, "Size in MB of the slow query log file before it's"+
+		" rotated, zero to never rotate it.")
+	flag.Int("slow_query_max_backups", 10, "Number of rotated slow query log files to keep.")
 	flag.Float64P("lru_mb", "l", -1,
 		"Estimated memory the LRU cache can take. "+
 			"Actual usage by the process would be more than specified here.")
@@ -149,6 +208,10 @@ they form a Raft group and provide synchronous replication.
 	// TLS configurations
 	x.RegisterTLSFlags(flag)
 	flag.String("tls_client_auth", "VERIFYIFGIVEN", "Enable TLS client authentication")
+	flag.String("tls_cert_acl", "", "Comma separated list of name=user:<userid> or"+
+		" name=group:<groupid>, to authenticate the clients whose verified TLS certificate has"+
+		" the name as common name or subject alternative name as the ACL user or group."+
+		" Requires --hmac_secret_file. Enterprise feature.")
 	tlsConf.ConfigType = x.TLSServerConfig
 
 	//Custom plugins.
@@ -210,6 +273,38 @@ func parseIPsFromString(str string) ([]worker.IPRange, error) {
 	return ipRanges, nil
 }
 
+// parseCertAcl parses the --tls_cert_acl flag, which maps the names of client certificates to
+// ACL users or groups.
+func parseCertAcl(str string) (map[string]edgraph.CertIdentity, error) {
+	certAcl := make(map[string]edgraph.CertIdentity)
+	if str == "" {
+		return certAcl, nil
+	}
+	for _, entry := range strings.Split(str, ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.New("Invalid entry " + entry + ", must be name=user:<userid>" +
+				" or name=group:<groupid>")
+		}
+		if _, ok := certAcl[kv[0]]; ok {
+			return nil, errors.New("Certificate name " + kv[0] + " is mapped more than once")
+		}
+		var id edgraph.CertIdentity
+		switch {
+		case strings.HasPrefix(kv[1], "user:"):
+			id.User = strings.TrimPrefix(kv[1], "user:")
+		case strings.HasPrefix(kv[1], "group:"):
+			id.Group = strings.TrimPrefix(kv[1], "group:")
+		}
+		if id.User == "" && id.Group == "" {
+			return nil, errors.New("Invalid entry " + entry + ", must be name=user:<userid>" +
+				" or name=group:<groupid>")
+		}
+		certAcl[kv[0]] = id
+	}
+	return certAcl, nil
+}
+
 func httpPort() int {
 	return x.Config.PortOffset + x.PortHTTP
 }
@@ -220,6 +315,10 @@ func grpcPort() int {
 
 func healthCheck(w http.ResponseWriter, r *http.Request) {
 	x.AddCorsHeaders(w)
+	if r.URL.Query().Get("all") == "true" {
+		clusterHealthCheck(w, r)
+		return
+	}
 	if err := x.HealthCheck(); err == nil {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -255,19 +354,6 @@ func setupListener(addr string, port int, reload func()) (net.Listener, error) {
 func serveGRPC(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	if collector := Alpha.Conf.GetString("jaeger.collector"); len(collector) > 0 {
-		// Port details: https://www.jaegertracing.io/docs/getting-started/
-		// Default collectorEndpointURI := "http://localhost:14268"
-		je, err := jaeger.NewExporter(jaeger.Options{
-			Endpoint:    collector,
-			ServiceName: "dgraph.alpha",
-		})
-		if err != nil {
-			log.Fatalf("Failed to create the Jaeger exporter: %v", err)
-		}
-		// And now finally register it as a Trace Exporter
-		otrace.RegisterExporter(je)
-	}
 	// Exclusively for stats, metrics, etc. Not for tracing.
 	// var views = append(ocgrpc.DefaultServerViews, ocgrpc.DefaultClientViews...)
 	// if err := view.Register(views...); err != nil {
@@ -278,7 +364,7 @@ func serveGRPC(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
 		grpc.MaxRecvMsgSize(x.GrpcMaxSize),
 		grpc.MaxSendMsgSize(x.GrpcMaxSize),
 		grpc.MaxConcurrentStreams(1000),
-		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
+		grpc.StatsHandler(tracing.ServerHandler()),
 	}
 	if tlsCfg != nil {
 		opt = append(opt, grpc.Creds(credentials.NewTLS(tlsCfg)))
@@ -286,6 +372,7 @@ func serveGRPC(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
 
 	s := grpc.NewServer(opt...)
 	api.RegisterDgraphServer(s, &edgraph.Server{})
+	pb.RegisterAlphaServer(s, &edgraph.Server{})
 	hapi.RegisterHealthServer(s, health.NewServer())
 	err := s.Serve(l)
 	glog.Errorf("GRPC listener canceled: %v\n", err)
@@ -295,6 +382,7 @@ func serveGRPC(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
 func serveHTTP(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
 	defer wg.Done()
 	srv := &http.Server{
+		Handler:      tracing.HTTP(auditAdmin(http.DefaultServeMux)),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 600 * time.Second,
 		IdleTimeout:  2 * time.Minute,
@@ -361,6 +449,8 @@ func setupServer() {
 	http.HandleFunc("/admin/shutdown", shutDownHandler)
 	http.HandleFunc("/admin/export", exportHandler)
 	http.HandleFunc("/admin/config/lru_mb", memoryLimitHandler)
+	http.HandleFunc("/admin/query_policy", queryPolicyHandler)
+	http.HandleFunc("/admin/stats/predicates", predicateStatsHandler)
 
 	// Add OpenCensus z-pages.
 	zpages.Handle(http.DefaultServeMux, "/z")
@@ -402,6 +492,13 @@ func run() {
 		Nomutations:    Alpha.Conf.GetBool("nomutations"),
 		AuthToken:      Alpha.Conf.GetString("auth_token"),
 		AllottedMemory: Alpha.Conf.GetFloat64("lru_mb"),
+
+		QueryLimits: query.Limits{
+			Timeout:  Alpha.Conf.GetDuration("query_timeout"),
+			MaxDepth: Alpha.Conf.GetInt("query_max_depth"),
+			MaxEdges: cast.ToUint64(Alpha.Conf.GetString("query_max_edges")),
+			Partial:  Alpha.Conf.GetBool("query_partial"),
+		},
 	}
 
 	secretFile := Alpha.Conf.GetString("hmac_secret_file")
@@ -417,12 +514,36 @@ func run() {
 
 		glog.Info("HMAC secret loaded successfully.")
 	}
+	certAcl, err := parseCertAcl(Alpha.Conf.GetString("tls_cert_acl"))
+	if err != nil {
+		glog.Fatalf("Invalid --tls_cert_acl: %v", err)
+	}
+	if len(certAcl) > 0 {
+		if opts.HmacSecret == nil {
+			glog.Fatalf("--tls_cert_acl requires --hmac_secret_file.")
+		}
+		// The names of certificates that are not verified can't be trusted.
+		if !x.VerifiesClientCerts(Alpha.Conf.GetString("tls_client_auth")) {
+			glog.Fatalf("--tls_cert_acl requires --tls_client_auth to be VERIFYIFGIVEN" +
+				" or REQUIREANDVERIFY.")
+		}
+		opts.CertAcl = certAcl
+	}
 	edgraph.SetConfiguration(opts)
+	if file := Alpha.Conf.GetString("query_policy_file"); file != "" {
+		policy, err := edgraph.LoadQueryPolicy(file)
+		if err != nil {
+			glog.Fatalf("%v", err)
+		}
+		edgraph.SetQueryPolicy(policy)
+	}
 
 	ips, err := parseIPsFromString(Alpha.Conf.GetString("whitelist"))
 	x.Check(err)
 	worker.Config = worker.Options{
 		ExportPath:          Alpha.Conf.GetString("export"),
+		ExportWorkers:       Alpha.Conf.GetInt("export_workers"),
+		ExportPartSize:      Alpha.Conf.GetInt64("export_part_mb") << 20,
 		NumPendingProposals: Alpha.Conf.GetInt("pending_proposals"),
 		Tracing:             Alpha.Conf.GetFloat64("trace"),
 		MyAddr:              Alpha.Conf.GetString("my"),
@@ -431,6 +552,51 @@ func run() {
 		ExpandEdge:          Alpha.Conf.GetBool("expand_edge"),
 		WhiteListedIPRanges: ips,
 		MaxRetries:          Alpha.Conf.GetInt("max_retries"),
+		BackupRateLimit:     Alpha.Conf.GetFloat64("backup_rate_limit"),
+		BackupSchedule:      Alpha.Conf.GetString("backup_schedule"),
+		BackupDestination:   Alpha.Conf.GetString("backup_destination"),
+		BackupFullEvery:     Alpha.Conf.GetInt("backup_full_every"),
+		BackupCompression:   Alpha.Conf.GetString("backup_compression"),
+		BackupSkipIndexes:   !Alpha.Conf.GetBool("backup_include_indexes"),
+		ChangeDataCapture:   Alpha.Conf.GetBool("cdc"),
+		Learner:             Alpha.Conf.GetBool("learner"),
+		LearnerGroup:        cast.ToUint32(Alpha.Conf.GetString("learner_group")),
+		SnapshotRateLimit:   Alpha.Conf.GetFloat64("snapshot_rate_limit"),
+		ReplicateFrom:       Alpha.Conf.GetString("replicate_from"),
+		ReplicateInterval:   Alpha.Conf.GetDuration("replicate_interval"),
+	}
+	worker.AuthorizeSubscription = edgraph.AuthorizeSubscription
+	if worker.Config.LearnerGroup > 0 && !worker.Config.Learner {
+		glog.Fatalf("--learner_group requires --learner.")
+	}
+	if worker.Config.ReplicateFrom != "" {
+		if !Alpha.Conf.GetBool("enterprise_features") {
+			glog.Fatalf("--replicate_from requires --enterprise_features.")
+		}
+		if worker.Config.ReplicateInterval <= 0 {
+			glog.Fatalf("--replicate_interval must be positive.")
+		}
+	}
+	if worker.Config.BackupSchedule != "" {
+		if !Alpha.Conf.GetBool("enterprise_features") {
+			glog.Fatalf("--backup_schedule requires --enterprise_features.")
+		}
+		if worker.Config.BackupDestination == "" {
+			glog.Fatalf("--backup_schedule requires --backup_destination.")
+		}
+	}
+
+	if keyFile := Alpha.Conf.GetString("encryption_key_file"); keyFile != "" {
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			glog.Fatalf("Unable to read encryption key from file: %v", keyFile)
+		}
+		if n := len(key); n != 16 && n != 24 && n != 32 {
+			glog.Fatalf("Encryption key in %v must be 16, 24 or 32 bytes long, got %d",
+				keyFile, n)
+		}
+		worker.Config.BackupKey = key
+		glog.Info("Backup encryption key loaded successfully.")
 	}
 
 	x.LoadTLSConfig(&tlsConf, Alpha.Conf, tlsNodeCert, tlsNodeKey)
@@ -443,6 +609,30 @@ func run() {
 	x.Config.QueryEdgeLimit = cast.ToUint64(Alpha.Conf.GetString("query_edge_limit"))
 
 	x.PrintVersion()
+	if output := Alpha.Conf.GetString("audit_output"); output != "" {
+		err := edgraph.StartAudit(edgraph.AuditOptions{
+			Output:     output,
+			MaxSizeMB:  Alpha.Conf.GetInt("audit_max_size_mb"),
+			MaxBackups: Alpha.Conf.GetInt("audit_max_backups"),
+			Bodies:     Alpha.Conf.GetBool("audit_bodies"),
+		})
+		if err != nil {
+			glog.Fatalf("Unable to start the audit log: %v", err)
+		}
+		defer edgraph.StopAudit()
+	}
+	if threshold := Alpha.Conf.GetDuration("slow_query_threshold"); threshold > 0 {
+		err := edgraph.StartSlowLog(edgraph.SlowLogOptions{
+			Threshold:  threshold,
+			Output:     Alpha.Conf.GetString("slow_query_log"),
+			MaxSizeMB:  Alpha.Conf.GetInt("slow_query_max_size_mb"),
+			MaxBackups: Alpha.Conf.GetInt("slow_query_max_backups"),
+		})
+		if err != nil {
+			glog.Fatalf("Unable to start the slow query log: %v", err)
+		}
+		defer edgraph.StopSlowLog()
+	}
 	edgraph.InitServerState()
 	defer func() {
 		edgraph.State.Dispose()
@@ -457,6 +647,7 @@ func run() {
 	}
 	otrace.ApplyConfig(otrace.Config{
 		DefaultSampler: otrace.ProbabilitySampler(worker.Config.Tracing)})
+	defer tracing.Start(Alpha.Conf, "dgraph.alpha")()
 
 	// Posting will initialize index which requires schema. Hence, initialize
 	// schema before calling posting.Init().
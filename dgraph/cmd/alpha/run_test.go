@@ -34,6 +34,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/edgraph"
 	"github.com/dgraph-io/dgraph/gql"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/query"
@@ -1461,6 +1462,25 @@ func TestJsonUnicode(t *testing.T) {
 		`{"data":{"node":[{"log.message":"\u001b[32mHello World 1!\u001b[39m\n"}]}}`, output)
 }
 
+func TestParseCertAcl(t *testing.T) {
+	certAcl, err := parseCertAcl("billing.svc=user:billing, ops@example.com=group:ops")
+	require.NoError(t, err)
+	require.Equal(t, map[string]edgraph.CertIdentity{
+		"billing.svc":     {User: "billing"},
+		"ops@example.com": {Group: "ops"},
+	}, certAcl)
+
+	certAcl, err = parseCertAcl("")
+	require.NoError(t, err)
+	require.Empty(t, certAcl)
+
+	for _, str := range []string{"billing.svc", "billing.svc=billing", "=user:billing",
+		"billing.svc=group:", "a=user:a,a=user:b"} {
+		_, err := parseCertAcl(str)
+		require.Error(t, err, str)
+	}
+}
+
 func TestMain(m *testing.M) {
 	// Increment lease, so that mutations work.
 	conn, err := grpc.Dial("localhost:5080", grpc.WithInsecure())
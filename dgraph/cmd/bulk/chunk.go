@@ -39,11 +39,16 @@ type chunker interface {
 }
 
 type rdfChunker struct{}
-type jsonChunker struct{}
+
+// jsonChunker reads the maps of a JSON array, or of a JSON Lines file if lines is set.
+type jsonChunker struct {
+	lines bool
+}
 
 const (
 	rdfInput int = iota
 	jsonInput
+	jsonLinesInput
 )
 
 func newChunker(inputFormat int) chunker {
@@ -52,6 +57,8 @@ func newChunker(inputFormat int) chunker {
 		return &rdfChunker{}
 	case jsonInput:
 		return &jsonChunker{}
+	case jsonLinesInput:
+		return &jsonChunker{lines: true}
 	default:
 		panic("unknown loader type")
 	}
@@ -149,11 +156,15 @@ func slurpQuoted(r *bufio.Reader, out *bytes.Buffer) error {
 	}
 }
 
-func (_ jsonChunker) begin(r *bufio.Reader) error {
+func (jc jsonChunker) begin(r *bufio.Reader) error {
 	// The JSON file to load must be an array of maps (that is, '[ { ... }, { ... }, ... ]').
 	// This function must be called before calling readJSONChunk for the first time to advance
 	// the Reader past the array start token ('[') so that calls to readJSONChunk can read
 	// one array element at a time instead of having to read the entire array into memory.
+	// A JSON Lines file has one map per line instead, with nothing to skip.
+	if jc.lines {
+		return nil
+	}
 	if err := slurpSpace(r); err != nil {
 		return err
 	}
@@ -167,7 +178,7 @@ func (_ jsonChunker) begin(r *bufio.Reader) error {
 	return nil
 }
 
-func (_ jsonChunker) chunk(r *bufio.Reader) (*bytes.Buffer, error) {
+func (jc jsonChunker) chunk(r *bufio.Reader) (*bytes.Buffer, error) {
 	out := new(bytes.Buffer)
 	out.Grow(1 << 20)
 
@@ -210,8 +221,11 @@ func (_ jsonChunker) chunk(r *bufio.Reader) (*bytes.Buffer, error) {
 		}
 	}
 
-	// The map should be followed by either the ',' between array elements, or the ']'
-	// at the end of the array.
+	// In a JSON Lines file, the next map starts on the next line. Otherwise, the map should be
+	// followed by either the ',' between array elements, or the ']' at the end of the array.
+	if jc.lines {
+		return out, nil
+	}
 	if err := slurpSpace(r); err != nil {
 		return nil, err
 	}
@@ -195,3 +195,39 @@ func TestJSONLoadSuccessAll(t *testing.T) {
 	err = chunker.end(reader)
 	require.NoError(t, err, "end reading JSON document")
 }
+
+// Test that all the maps of a JSON Lines document are read and parsed.
+func TestJSONLinesLoad(t *testing.T) {
+	var testDoc = `{"name": "Alice", "friend": {"name": "Bob"}}
+
+{"name": "Carol {}", "age": 3}
+`
+	chunker := newChunker(jsonLinesInput)
+	reader := bufioReader(testDoc)
+	require.NoError(t, chunker.begin(reader))
+
+	var names []string
+	for {
+		json, err := chunker.chunk(reader)
+		if err == io.EOF {
+			require.Equal(t, 0, json.Len())
+			break
+		}
+		require.NoError(t, err)
+		nqs, err := chunker.parse(json)
+		require.NoError(t, err)
+		for _, nq := range nqs {
+			if nq.Predicate == "name" {
+				names = append(names, nq.ObjectValue.GetStrVal())
+			}
+		}
+	}
+	require.Equal(t, []string{"Alice", "Bob", "Carol {}"}, names)
+	require.NoError(t, chunker.end(reader))
+
+	// A JSON Lines document isn't an array.
+	reader = bufioReader("[{}]")
+	require.NoError(t, chunker.begin(reader))
+	_, err := chunker.chunk(reader)
+	require.Error(t, err)
+}
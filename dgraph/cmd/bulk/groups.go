@@ -0,0 +1,78 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bulk
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/gogo/protobuf/jsonpb"
+	"google.golang.org/grpc"
+)
+
+// readZeroState reads the state of a cluster, from the file at path in the JSON format served
+// by the /state endpoint of Zero, or from the Zero at addr if path is empty.
+func readZeroState(path, addr string) (*pb.MembershipState, error) {
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		var state pb.MembershipState
+		if err := jsonpb.Unmarshal(f, &state); err != nil {
+			return nil, x.Wrapf(err, "while reading the Zero state in %q", path)
+		}
+		return &state, nil
+	}
+
+	zero, err := grpc.Dial(addr, grpc.WithBlock(), grpc.WithInsecure(),
+		grpc.WithTimeout(time.Minute))
+	if err != nil {
+		return nil, x.Wrapf(err, "unable to connect to zero, is it running at %s?", addr)
+	}
+	defer zero.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cs, err := pb.NewZeroClient(zero).Connect(ctx, &pb.Member{ClusterInfoOnly: true})
+	if err != nil {
+		return nil, x.Wrapf(err, "while reading the state of the Zero at %s", addr)
+	}
+	return cs.GetState(), nil
+}
+
+// clusterGroups returns the ids of the groups in state, in order, and the index in them of
+// the group serving each predicate.
+func clusterGroups(state *pb.MembershipState) ([]uint32, map[string]int) {
+	var gids []uint32
+	for gid := range state.GetGroups() {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+
+	tablets := make(map[string]int)
+	for i, gid := range gids {
+		for pred := range state.Groups[gid].GetTablets() {
+			tablets[pred] = i
+		}
+	}
+	return gids, tablets
+}
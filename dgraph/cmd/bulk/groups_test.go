@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bulk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+		"groups": {
+			"3": {"tablets": {"age": {"groupId": 3, "predicate": "age"}}},
+			"1": {"tablets": {
+				"name": {"groupId": 1, "predicate": "name"},
+				"friend": {"groupId": 1, "predicate": "friend"}
+			}}
+		},
+		"maxLeaseId": "10000"
+	}`), 0600))
+	state, err := readZeroState(path, "")
+	require.NoError(t, err)
+	gids, tablets := clusterGroups(state)
+	require.Equal(t, []uint32{1, 3}, gids)
+	require.Equal(t, map[string]int{"name": 0, "friend": 0, "age": 1}, tablets)
+
+	// The predicates of the cluster stay in their group, the new ones are spread.
+	m := newShardMap(len(gids), tablets)
+	require.Equal(t, 1, m.shardFor("age"))
+	require.Equal(t, 0, m.shardFor("name"))
+	require.Equal(t, 0, m.shardFor("email"))
+	require.Equal(t, 1, m.shardFor("address"))
+
+	_, err = readZeroState(filepath.Join(dir, "none.json"), "")
+	require.Error(t, err)
+}
@@ -62,6 +62,13 @@ type options struct {
 	MapShards    int
 	ReduceShards int
 
+	// With --zero_state or --match_zero, the groups of the cluster and the shard of their
+	// predicates. Each group has a map shard and a reduce shard, in order.
+	ZeroState string
+	MatchZero bool
+	groups    []uint32
+	tablets   map[string]int
+
 	shardOutputDirs []string
 }
 
@@ -94,7 +101,7 @@ func newLoader(opt options) *loader {
 	st := &state{
 		opt:    opt,
 		prog:   newProgress(),
-		shards: newShardMap(opt.MapShards),
+		shards: newShardMap(opt.MapShards, opt.tablets),
 		// Lots of gz readers, so not much channel buffer needed.
 		readerChunkCh: make(chan *bytes.Buffer, opt.NumGoroutines),
 		writeTs:       getWriteTimestamp(zero),
@@ -192,6 +199,16 @@ func (ld *loader) mapStage() {
 		loaderType = jsonInput
 		ext = ".json"
 		files = findDataFiles(ld.opt.JSONDir, ext)
+		files = append(files, findDataFiles(ld.opt.JSONDir, ".jsonl")...)
+	}
+
+	// JSON Lines files are chunked by line, and parsed as any other JSON.
+	formats := make(map[string]int)
+	for _, file := range files {
+		formats[file] = loaderType
+		if strings.HasSuffix(strings.TrimSuffix(file, ".gz"), ".jsonl") {
+			formats[file] = jsonLinesInput
+		}
 	}
 
 	readers := make(map[string]*bufio.Reader)
@@ -211,6 +228,9 @@ func (ld *loader) mapStage() {
 	}
 
 	if len(readers) == 0 {
+		if loaderType == jsonInput {
+			ext = ".json or *.jsonl"
+		}
 		fmt.Printf("No *%s files found.\n", ext)
 		os.Exit(1)
 	}
@@ -231,7 +251,7 @@ func (ld *loader) mapStage() {
 		thr.Start()
 		fileCount++
 		fmt.Printf("Processing file (%d out of %d): %s\n", fileCount, len(readers), file)
-		chunker := newChunker(loaderType)
+		chunker := newChunker(formats[file])
 		go func(r *bufio.Reader) {
 			defer thr.Done()
 			x.Check(chunker.begin(r))
@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dgraph-io/dgraph/x"
@@ -31,11 +32,23 @@ func mergeMapShardsIntoReduceShards(opt options) {
 
 	var reduceShards []string
 	for i := 0; i < opt.ReduceShards; i++ {
-		shardDir := filepath.Join(opt.TmpDir, "shards", fmt.Sprintf("shard_%d", i))
+		shardDir := reduceShardDir(opt.TmpDir, i)
 		x.Check(os.MkdirAll(shardDir, 0755))
 		reduceShards = append(reduceShards, shardDir)
 	}
 
+	if len(opt.groups) > 0 {
+		// The map shards hold the predicates of the groups, in order. They aren't balanced.
+		for _, shard := range mapShards {
+			i, err := strconv.Atoi(filepath.Base(shard))
+			x.Check(err)
+			reduceShard := filepath.Join(reduceShards[i], filepath.Base(shard))
+			fmt.Printf("Shard %s -> Reduce %s\n", shard, reduceShard)
+			x.Check(os.Rename(shard, reduceShard))
+		}
+		return
+	}
+
 	// Heuristic: put the largest map shard into the smallest reduce shard
 	// until there are no more map shards left. Should be a good approximation.
 	for _, shard := range mapShards {
@@ -47,6 +60,11 @@ func mergeMapShardsIntoReduceShards(opt options) {
 	}
 }
 
+// reduceShardDir returns the directory the map shards of reduce shard i are moved to.
+func reduceShardDir(tmpDir string, i int) string {
+	return filepath.Join(tmpDir, "shards", fmt.Sprintf("shard_%d", i))
+}
+
 func shardDirs(tmpDir string) []string {
 	dir, err := os.Open(filepath.Join(tmpDir, "shards"))
 	x.Check(err)
@@ -51,7 +51,8 @@ func init() {
 		"Directory containing *.rdf or *.rdf.gz files to load.")
 	// would be nice to use -j to match -r, but already used by --num_go_routines
 	flag.String("jsons", "",
-		"Directory containing *.json or *.json.gz files to load.")
+		"Directory containing *.json or *.json.gz files to load, each one with an array of "+
+			"JSON maps, or *.jsonl or *.jsonl.gz files with a JSON map per line.")
 	flag.StringP("schema_file", "s", "",
 		"Location of schema file to load.")
 	flag.String("out", "out",
@@ -91,6 +92,12 @@ func init() {
 			"more parallelism, but increases memory usage.")
 	flag.String("custom_tokenizers", "",
 		"Comma separated list of tokenizer plugins")
+	flag.String("zero_state", "",
+		"File with the state of an existing cluster, as served by the /state endpoint of Zero. "+
+			"The output is written to a directory per group, out/<group id>/p, with the "+
+			"predicates the group serves. Sets the number of map and reduce shards.")
+	flag.Bool("match_zero", false,
+		"Like --zero_state, with the state read from the Zero at --zero.")
 }
 
 func run() {
@@ -114,6 +121,8 @@ func run() {
 		MapShards:        Bulk.Conf.GetInt("map_shards"),
 		ReduceShards:     Bulk.Conf.GetInt("reduce_shards"),
 		CustomTokenizers: Bulk.Conf.GetString("custom_tokenizers"),
+		ZeroState:        Bulk.Conf.GetString("zero_state"),
+		MatchZero:        Bulk.Conf.GetBool("match_zero"),
 	}
 
 	x.PrintVersion()
@@ -133,6 +142,30 @@ func run() {
 			opt.RDFDir, opt.JSONDir)
 		os.Exit(1)
 	}
+	if opt.ZeroState != "" && opt.MatchZero {
+		fmt.Fprint(os.Stderr, "Invalid flags: only one of zero_state or match_zero may be "+
+			"specified.\n")
+		os.Exit(1)
+	}
+	if opt.ZeroState != "" || opt.MatchZero {
+		state, err := readZeroState(opt.ZeroState, opt.ZeroAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read the state of the cluster: %v\n", err)
+			os.Exit(1)
+		}
+		opt.groups, opt.tablets = clusterGroups(state)
+		if len(opt.groups) == 0 {
+			fmt.Fprint(os.Stderr, "The cluster doesn't have any group.\n")
+			os.Exit(1)
+		}
+		fmt.Printf("Matching the %d groups of the cluster, with %d predicates.\n",
+			len(opt.groups), len(opt.tablets))
+		opt.MapShards = len(opt.groups)
+		opt.ReduceShards = len(opt.groups)
+		if opt.NumShufflers > opt.ReduceShards {
+			opt.NumShufflers = opt.ReduceShards
+		}
+	}
 	if opt.ReduceShards > opt.MapShards {
 		fmt.Fprintf(os.Stderr, "Invalid flags: reduce_shards(%d) should be <= map_shards(%d)\n",
 			opt.ReduceShards, opt.MapShards)
@@ -165,6 +198,9 @@ func run() {
 	x.Check(os.RemoveAll(opt.DgraphsDir))
 	for i := 0; i < opt.ReduceShards; i++ {
 		dir := filepath.Join(opt.DgraphsDir, strconv.Itoa(i), "p")
+		if len(opt.groups) > 0 {
+			dir = filepath.Join(opt.DgraphsDir, fmt.Sprint(opt.groups[i]), "p")
+		}
 		x.Check(os.MkdirAll(dir, 0700))
 		opt.shardOutputDirs = append(opt.shardOutputDirs, dir)
 	}
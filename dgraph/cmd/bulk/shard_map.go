@@ -25,11 +25,17 @@ type shardMap struct {
 	nextShard   int
 }
 
-func newShardMap(numShards int) *shardMap {
-	return &shardMap{
+// newShardMap returns a map of the predicates to numShards shards. The predicates in tablets
+// keep the shard given there, the others are assigned to the shards in turn.
+func newShardMap(numShards int, tablets map[string]int) *shardMap {
+	m := &shardMap{
 		numShards:   numShards,
 		predToShard: make(map[string]int),
 	}
+	for pred, shard := range tablets {
+		m.predToShard[pred] = shard
+	}
+	return m
 }
 
 func (m *shardMap) shardFor(pred string) int {
@@ -40,6 +40,12 @@ type shuffler struct {
 func (s *shuffler) run() {
 	shardDirs := shardDirs(s.opt.TmpDir)
 	x.AssertTrue(len(shardDirs) == s.opt.ReduceShards)
+	if len(s.opt.groups) > 0 {
+		// Each reduce shard is written to the directory of its group.
+		for i := range shardDirs {
+			shardDirs[i] = reduceShardDir(s.opt.TmpDir, i)
+		}
+	}
 	x.AssertTrue(len(s.opt.shardOutputDirs) == s.opt.ReduceShards)
 
 	thr := x.NewThrottle(s.opt.NumShufflers)
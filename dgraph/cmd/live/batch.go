@@ -33,7 +33,6 @@ import (
 
 	"github.com/dgraph-io/badger"
 	"github.com/dgraph-io/dgo"
-	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/dgraph-io/dgo/y"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/x"
@@ -91,8 +90,12 @@ type loader struct {
 	// To get time elapsel.
 	start time.Time
 
-	reqs     chan api.Mutation
+	reqs     chan request
 	zeroconn *grpc.ClientConn
+
+	// The checkpoints of the files being loaded, see saveCheckpoints.
+	ckMu        sync.Mutex
+	checkpoints []*checkpoint
 }
 
 func (p *uidProvider) ReserveUidRange() (start, end uint64, err error) {
@@ -149,15 +152,16 @@ func handleError(err error) {
 	}
 }
 
-func (l *loader) infinitelyRetry(req api.Mutation) {
+func (l *loader) infinitelyRetry(req request) {
 	defer l.retryRequestsWg.Done()
 	for i := time.Millisecond; ; i *= 2 {
 		txn := l.dc.NewTxn()
 		req.CommitNow = true
-		_, err := txn.Mutate(l.opts.Ctx, &req)
+		_, err := txn.Mutate(l.opts.Ctx, &req.Mutation)
 		if err == nil {
 			atomic.AddUint64(&l.rdfs, uint64(len(req.Set)))
 			atomic.AddUint64(&l.txns, 1)
+			req.ck.commit(req.line)
 			return
 		}
 		handleError(err)
@@ -169,14 +173,15 @@ func (l *loader) infinitelyRetry(req api.Mutation) {
 	}
 }
 
-func (l *loader) request(req api.Mutation) {
+func (l *loader) request(req request) {
 	txn := l.dc.NewTxn()
 	req.CommitNow = true
-	_, err := txn.Mutate(l.opts.Ctx, &req)
+	_, err := txn.Mutate(l.opts.Ctx, &req.Mutation)
 
 	if err == nil {
 		atomic.AddUint64(&l.rdfs, uint64(len(req.Set)))
 		atomic.AddUint64(&l.txns, 1)
+		req.ck.commit(req.line)
 		return
 	}
 	handleError(err)
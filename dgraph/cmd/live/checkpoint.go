@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package live
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// The checkpoints are stored in the --xidmap directory along with the xid to uid mapping,
+// under this prefix, which xids don't start with.
+const checkpointPrefix = "\x00checkpoint:"
+
+// How often the checkpoints are saved while loading.
+const checkpointInterval = 10 * time.Second
+
+// request is a batch of N-Quads read from a file, and the line of the last one. In a JSON file,
+// the lines are the JSON maps.
+type request struct {
+	api.Mutation
+	ck   *checkpoint
+	line uint64
+}
+
+// checkpoint tracks the batches of a file sent to Dgraph, to know the line up to which all of
+// them are committed. The batches are committed out of order by the concurrent requests.
+type checkpoint struct {
+	sync.Mutex
+	file      string
+	line      uint64          // all the lines up to this one are committed
+	sent      []uint64        // the last lines of the batches sent and not yet in line
+	committed map[uint64]bool // the last lines of the batches committed and not yet in line
+}
+
+func newCheckpoint(file string, line uint64) *checkpoint {
+	return &checkpoint{file: file, line: line, committed: make(map[uint64]bool)}
+}
+
+// send records a batch ending at line before it's sent.
+func (ck *checkpoint) send(line uint64) {
+	ck.Lock()
+	defer ck.Unlock()
+	ck.sent = append(ck.sent, line)
+}
+
+// commit records the batch ending at line as committed, and moves the checkpoint past it if
+// all the batches sent before it are committed too.
+func (ck *checkpoint) commit(line uint64) {
+	ck.Lock()
+	defer ck.Unlock()
+	ck.committed[line] = true
+	for len(ck.sent) > 0 && ck.committed[ck.sent[0]] {
+		ck.line = ck.sent[0]
+		delete(ck.committed, ck.line)
+		ck.sent = ck.sent[1:]
+	}
+}
+
+func (ck *checkpoint) committedLine() uint64 {
+	ck.Lock()
+	defer ck.Unlock()
+	return ck.line
+}
+
+func checkpointKey(file string) []byte {
+	return []byte(checkpointPrefix + file)
+}
+
+// readCheckpoint returns the line up to which file was loaded by a previous run, or 0.
+func (l *loader) readCheckpoint(file string) (uint64, error) {
+	var line uint64
+	err := l.kv.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(checkpointKey(file))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			var n int
+			line, n = binary.Uvarint(val)
+			if n <= 0 {
+				return x.Errorf("Invalid checkpoint of file %q", file)
+			}
+			return nil
+		})
+	})
+	return line, err
+}
+
+// addCheckpoint starts tracking the batches of file, loaded from line on.
+func (l *loader) addCheckpoint(file string, line uint64) *checkpoint {
+	ck := newCheckpoint(file, line)
+	l.ckMu.Lock()
+	defer l.ckMu.Unlock()
+	l.checkpoints = append(l.checkpoints, ck)
+	return ck
+}
+
+// saveCheckpoints persists the lines up to which the files are committed. The xid to uid
+// mapping is flushed first, so that a resumed load assigns the same uids to the xids of the
+// lines it skips.
+func (l *loader) saveCheckpoints() error {
+	l.ckMu.Lock()
+	lines := make(map[string]uint64, len(l.checkpoints))
+	for _, ck := range l.checkpoints {
+		lines[ck.file] = ck.committedLine()
+	}
+	l.ckMu.Unlock()
+
+	l.alloc.Flush()
+	return l.kv.Update(func(txn *badger.Txn) error {
+		for file, line := range lines {
+			var buf [binary.MaxVarintLen64]byte
+			n := binary.PutUvarint(buf[:], line)
+			if err := txn.Set(checkpointKey(file), buf[:n]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// clearCheckpoints deletes the checkpoints of a previous run, for a load that isn't resumed.
+func (l *loader) clearCheckpoints() error {
+	return l.kv.Update(func(txn *badger.Txn) error {
+		itr := txn.NewIterator(badger.IteratorOptions{PrefetchValues: false})
+		var keys [][]byte
+		prefix := []byte(checkpointPrefix)
+		for itr.Seek(prefix); itr.ValidForPrefix(prefix); itr.Next() {
+			keys = append(keys, itr.Item().KeyCopy(nil))
+		}
+		itr.Close()
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkpointEvery saves the checkpoints at each interval, and a last time once done is
+// closed.
+func (l *loader) checkpointEvery(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			ticker.Stop()
+		}
+		if err := l.saveCheckpoints(); err != nil {
+			fmt.Printf("Error while saving the checkpoints: %v\n", err)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
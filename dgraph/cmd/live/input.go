@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package live
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/x"
+	minio "github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
+)
+
+const s3DefaultEndpoint = "s3.amazonaws.com"
+
+// gzipReadCloser decompresses an input, and closes it along with the gzip reader.
+type gzipReadCloser struct {
+	*gzip.Reader
+	in io.Closer
+}
+
+func (r *gzipReadCloser) Close() error {
+	x.Ignore(r.Reader.Close())
+	return r.in.Close()
+}
+
+// inputPath returns the path of file, without the scheme and host of a URL.
+func inputPath(file string) string {
+	if u, err := url.Parse(file); err == nil && u.Scheme != "" {
+		return u.Path
+	}
+	return file
+}
+
+// isJSON returns true if file holds JSON maps rather than N-Quads.
+func isJSON(file string) bool {
+	p := strings.TrimSuffix(strings.ToLower(inputPath(file)), ".gz")
+	return strings.HasSuffix(p, ".json") || strings.HasSuffix(p, ".jsonl")
+}
+
+// openInput opens a file to load, which is either a local path or an http://, https:// or
+// s3:// URL. Files ending in .gz are decompressed as they're read.
+func openInput(file string) (io.ReadCloser, error) {
+	var in io.ReadCloser
+	u, err := url.Parse(file)
+	switch {
+	case err != nil || u.Scheme == "":
+		in, err = os.Open(file)
+	case u.Scheme == "http" || u.Scheme == "https":
+		in, err = openHTTP(file)
+	case u.Scheme == "s3":
+		in, err = openS3(u)
+	default:
+		err = x.Errorf("Unsupported scheme %q of %q", u.Scheme, file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(strings.ToLower(inputPath(file)), ".gz") {
+		return in, nil
+	}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		in.Close()
+		return nil, x.Wrapf(err, "while decompressing %q", file)
+	}
+	return &gzipReadCloser{Reader: gz, in: in}, nil
+}
+
+func openHTTP(file string) (io.ReadCloser, error) {
+	resp, err := http.Get(file)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, x.Errorf("Unable to read %q: %s", file, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// openS3 reads an object at s3://<endpoint>/bucket/key, or at s3:///bucket/key from the default
+// S3 endpoint, with the keys in the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env vars. The
+// object is read anonymously if they're not set. With ?secure=false, the endpoint is reached
+// over http.
+func openS3(u *url.URL) (io.ReadCloser, error) {
+	host := u.Host
+	if host == "" {
+		host = s3DefaultEndpoint
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, x.Errorf("The S3 URL %q must have a bucket and an object", u.String())
+	}
+	creds := credentials.NewStaticV4(os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"), "")
+	mc, err := minio.NewWithOptions(host, &minio.Options{
+		Creds:  creds,
+		Secure: u.Query().Get("secure") != "false",
+	})
+	if err != nil {
+		return nil, err
+	}
+	obj, err := mc.GetObject(parts[0], parts[1], minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject doesn't send any request, check the object can be read before loading it.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, x.Wrapf(err, "while reading %q", u.String())
+	}
+	return obj, nil
+}
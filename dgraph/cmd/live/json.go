@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package live
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/edgraph"
+)
+
+// processJSON sends mutations for the JSON maps read from r, in the format of JSON mutations.
+// r holds either an array of maps or a stream of them, e.g. one per line. The maps are read
+// one at a time, and the ones up to skip are skipped.
+func (l *loader) processJSON(ctx context.Context, r io.Reader, ck *checkpoint,
+	skip uint64) error {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(br)
+	inArray := first == '['
+	if inArray {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	var n uint64
+	mu := api.Mutation{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if inArray && !dec.More() {
+			break
+		}
+		var m json.RawMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("Error while parsing JSON: %v, in map %d", err, n+1)
+		}
+		n++
+		if n <= skip {
+			continue
+		}
+
+		nqs, err := edgraph.NquadsFromJson(m)
+		if err != nil {
+			return fmt.Errorf("Error while parsing JSON: %v, in map %d", err, n)
+		}
+		blanks := make(map[string]string)
+		for _, nq := range nqs {
+			nq.Subject = l.jsonUid(nq.Subject, blanks)
+			if len(nq.ObjectId) > 0 {
+				nq.ObjectId = l.jsonUid(nq.ObjectId, blanks)
+			}
+		}
+		mu.Set = append(mu.Set, nqs...)
+
+		if len(mu.Set) >= opt.numRdf {
+			ck.send(n)
+			l.reqs <- request{Mutation: mu, ck: ck, line: n}
+			mu = api.Mutation{}
+		}
+	}
+	if len(mu.Set) > 0 {
+		ck.send(n)
+		l.reqs <- request{Mutation: mu, ck: ck, line: n}
+	}
+	return nil
+}
+
+// peekNonSpace skips the white space at the start of br, and returns the byte after it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return b[0], nil
+		}
+		if _, err := br.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// jsonUid returns the uid of a node of a JSON map, as named by the JSON parser. Uids are kept,
+// blank nodes get the uid of their xid, as in N-Quads, and the nodes without a uid, which the
+// parser names after their position in the map, e.g. _:blank-0, get a new uid for each map.
+func (l *loader) jsonUid(val string, blanks map[string]string) string {
+	if strings.HasPrefix(val, "_:blank-") {
+		uid, ok := blanks[val]
+		if !ok {
+			uid = fmt.Sprintf("%#x", l.alloc.AllocateUid())
+			blanks[val] = uid
+		}
+		return uid
+	}
+	// The parser writes the uids in decimal.
+	if uid, err := strconv.ParseUint(val, 10, 64); err == nil {
+		return fmt.Sprintf("%#x", uid)
+	}
+	return l.uid(val)
+}
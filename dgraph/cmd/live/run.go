@@ -19,7 +19,6 @@ package live
 import (
 	"bufio"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -29,7 +28,6 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -56,6 +54,7 @@ type options struct {
 	clientDir           string
 	ignoreIndexConflict bool
 	authToken           string
+	resume              bool
 }
 
 var opt options
@@ -77,8 +76,10 @@ func init() {
 	Live.EnvPrefix = "DGRAPH_LIVE"
 
 	flag := Live.Cmd.Flags()
-	flag.StringP("rdfs", "r", "", "Location of rdf files to load")
-	flag.StringP("schema", "s", "", "Location of schema file")
+	flag.StringP("rdfs", "r", "", "Location of the rdf or JSON files to load, comma separated. "+
+		"Local paths or http(s):// and s3:// URLs. Files ending in .json or .jsonl hold JSON "+
+		"maps, and files ending in .gz are decompressed.")
+	flag.StringP("schema", "s", "", "Location of schema file, a local path or URL")
 	flag.StringP("dgraph", "d", "127.0.0.1:9080", "Dgraph gRPC server address")
 	flag.StringP("zero", "z", "127.0.0.1:5080", "Dgraphzero gRPC server address")
 	flag.IntP("conc", "c", 10,
@@ -90,6 +91,9 @@ func init() {
 		"Ignores conflicts on index keys during transaction")
 	flag.StringP("auth_token", "a", "",
 		"The auth token passed to the server for Alter operation of the schema file")
+	flag.Bool("resume", false, "Resume an interrupted load, skipping the lines of the rdf "+
+		"files, or the maps of the JSON files, committed by the previous run. Requires the "+
+		"--xidmap directory of that run.")
 
 	// TLS configuration
 	x.RegisterTLSFlags(flag)
@@ -125,17 +129,9 @@ func processSchemaFile(ctx context.Context, file string, dgraphClient *dgo.Dgrap
 		ctx = metadata.NewOutgoingContext(ctx, md)
 	}
 
-	f, err := os.Open(file)
+	reader, err := openInput(file)
 	x.Check(err)
-	defer f.Close()
-
-	var reader io.Reader
-	if strings.HasSuffix(strings.ToLower(file), ".gz") {
-		reader, err = gzip.NewReader(f)
-		x.Check(err)
-	} else {
-		reader = f
-	}
+	defer reader.Close()
 
 	b, err := ioutil.ReadAll(reader)
 	if err != nil {
@@ -163,27 +159,40 @@ func (l *loader) uid(val string) string {
 	return fmt.Sprintf("%#x", uint64(uid))
 }
 
-func fileReader(file string) (io.Reader, *os.File) {
-	f, err := os.Open(file)
-	x.Check(err)
-
-	var r io.Reader
-	if filepath.Ext(file) == ".gz" {
-		r, err = gzip.NewReader(f)
-		x.Check(err)
-	} else {
-		r = bufio.NewReader(f)
+// processFile sends mutations for a given file, of N-Quads or JSON maps. With --resume, the
+// lines or maps up to the checkpoint of the file are skipped.
+func (l *loader) processFile(ctx context.Context, file string) error {
+	fmt.Printf("\nProcessing %s\n", file)
+	var skip uint64
+	if opt.resume {
+		var err error
+		if skip, err = l.readCheckpoint(file); err != nil {
+			return err
+		}
+		if skip > 0 && isJSON(file) {
+			fmt.Printf("Resuming %s after map %d\n", file, skip)
+		} else if skip > 0 {
+			fmt.Printf("Resuming %s after line %d\n", file, skip)
+		}
 	}
-	return r, f
+	ck := l.addCheckpoint(file, skip)
+	r, err := openInput(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if isJSON(file) {
+		return l.processJSON(ctx, r, ck, skip)
+	}
+	return l.processRDF(ctx, r, ck, skip)
 }
 
-// processFile sends mutations for a given gz file.
-func (l *loader) processFile(ctx context.Context, file string) error {
-	fmt.Printf("\nProcessing %s\n", file)
-	gr, f := fileReader(file)
+// processRDF sends mutations for the N-Quads read from r, one per line. The lines up to skip
+// are skipped.
+func (l *loader) processRDF(ctx context.Context, r io.Reader, ck *checkpoint,
+	skip uint64) error {
 	var buf bytes.Buffer
-	bufReader := bufio.NewReader(gr)
-	defer f.Close()
+	bufReader := bufio.NewReader(r)
 
 	var line uint64
 	mu := api.Mutation{}
@@ -202,6 +211,10 @@ func (l *loader) processFile(ctx context.Context, file string) error {
 			break
 		}
 		line++
+		if line <= skip {
+			buf.Reset()
+			continue
+		}
 
 		nq, err := rdf.Parse(buf.String())
 		if err == rdf.ErrEmpty { // special case: comment/empty line
@@ -220,13 +233,15 @@ func (l *loader) processFile(ctx context.Context, file string) error {
 		mu.Set = append(mu.Set, &nq)
 
 		if batchSize >= opt.numRdf {
-			l.reqs <- mu
+			ck.send(line)
+			l.reqs <- request{Mutation: mu, ck: ck, line: line}
 			batchSize = 0
 			mu = api.Mutation{}
 		}
 	}
 	if batchSize > 0 {
-		l.reqs <- mu
+		ck.send(line)
+		l.reqs <- request{Mutation: mu, ck: ck, line: line}
 		mu = api.Mutation{}
 	}
 	return nil
@@ -266,7 +281,7 @@ func setup(opts batchMutationOptions, dc *dgo.Dgraph) *loader {
 		opts:     opts,
 		dc:       dc,
 		start:    time.Now(),
-		reqs:     make(chan api.Mutation, opts.Pending*2),
+		reqs:     make(chan request, opts.Pending*2),
 		alloc:    alloc,
 		kv:       kv,
 		zeroconn: connzero,
@@ -293,6 +308,12 @@ func run() error {
 		clientDir:           Live.Conf.GetString("xidmap"),
 		ignoreIndexConflict: Live.Conf.GetBool("ignore_index_conflict"),
 		authToken:           Live.Conf.GetString("auth_token"),
+		resume:              Live.Conf.GetBool("resume"),
+	}
+	if opt.resume && opt.clientDir == "" {
+		err := x.Errorf("--resume requires the --xidmap directory of the interrupted load")
+		fmt.Println(err)
+		return err
 	}
 	x.LoadTLSConfig(&tlsConf, Live.Conf, x.TlsClientCert, x.TlsClientKey)
 	tlsConf.ServerName = Live.Conf.GetString("tls_server_name")
@@ -330,6 +351,9 @@ func run() error {
 	defer l.zeroconn.Close()
 	defer l.kv.Close()
 	defer l.alloc.EvictAll()
+	if !opt.resume {
+		x.Checkf(l.clearCheckpoints(), "While clearing the checkpoints of a previous load")
+	}
 
 	if len(opt.schemaFile) > 0 {
 		if err := processSchemaFile(ctx, opt.schemaFile, dgraphClient); err != nil {
@@ -363,6 +387,17 @@ func run() error {
 		go l.printCounters()
 	}
 
+	// The checkpoints are saved before the xidmap is closed, even if a file fails to load.
+	stopCheckpoints, checkpointsStopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		l.checkpointEvery(checkpointInterval, stopCheckpoints)
+		close(checkpointsStopped)
+	}()
+	defer func() {
+		close(stopCheckpoints)
+		<-checkpointsStopped
+	}()
+
 	for i := 0; i < totalFiles; i++ {
 		if err := <-errCh; err != nil {
 			fmt.Printf("Error while processing file %q: %s\n", filesList[i], err)
@@ -30,6 +30,7 @@ import (
 	"github.com/dgraph-io/dgraph/dgraph/cmd/version"
 	"github.com/dgraph-io/dgraph/dgraph/cmd/zero"
 	"github.com/dgraph-io/dgraph/ee/acl"
+	"github.com/dgraph-io/dgraph/ee/backup"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -87,7 +88,7 @@ func init() {
 
 	var subcommands = []*x.SubCommand{
 		&bulk.Bulk, &cert.Cert, &conv.Conv, &live.Live, &alpha.Alpha, &zero.Zero,
-		&version.Version, &debug.Debug, &acl.CmdAcl,
+		&version.Version, &debug.Debug, &acl.CmdAcl, &backup.Restore, &backup.Backup,
 	}
 	for _, sc := range subcommands {
 		RootCmd.AddCommand(sc.Cmd)
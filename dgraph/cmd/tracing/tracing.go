@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tracing sets up the exporters of the opencensus traces of the Dgraph servers, and
+// continues the traces of their clients.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"contrib.go.opencensus.io/exporter/ocagent"
+	"github.com/golang/glog"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opencensus.io/exporter/jaeger"
+	"go.opencensus.io/plugin/ocgrpc"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+// RegisterFlags registers the flags of the exporters of the traces.
+func RegisterFlags(flag *pflag.FlagSet) {
+	flag.String("jaeger.collector", "", "Send opencensus traces to the Jaeger collector at"+
+		" this URL, like http://localhost:14268.")
+	flag.String("jaeger.agent", "", "Send opencensus traces to the Jaeger agent at this"+
+		" host:port, like localhost:6831.")
+	flag.String("ocagent", "", "Send opencensus traces to the OpenCensus agent, or the"+
+		" OpenTelemetry Collector with an opencensus receiver, at this host:port.")
+	flag.Bool("ocagent.insecure", false, "Connect to --ocagent without TLS.")
+}
+
+// Start registers the exporters of the traces of service set by the flags. It returns
+// the function that flushes and stops them.
+func Start(v *viper.Viper, service string) func() {
+	var stops []func()
+	collector, agent := v.GetString("jaeger.collector"), v.GetString("jaeger.agent")
+	if collector != "" || agent != "" {
+		// Port details: https://www.jaegertracing.io/docs/getting-started/
+		je, err := jaeger.NewExporter(jaeger.Options{
+			Endpoint:      collector,
+			AgentEndpoint: agent,
+			ServiceName:   service,
+		})
+		if err != nil {
+			glog.Fatalf("Failed to create the Jaeger exporter: %v", err)
+		}
+		trace.RegisterExporter(je)
+		stops = append(stops, je.Flush)
+	}
+	if addr := v.GetString("ocagent"); addr != "" {
+		opts := []ocagent.ExporterOption{
+			ocagent.WithAddress(addr),
+			ocagent.WithServiceName(service),
+		}
+		if v.GetBool("ocagent.insecure") {
+			opts = append(opts, ocagent.WithInsecure())
+		}
+		oe, err := ocagent.NewExporter(opts...)
+		if err != nil {
+			glog.Fatalf("Failed to create the OpenCensus agent exporter: %v", err)
+		}
+		trace.RegisterExporter(oe)
+		stops = append(stops, func() {
+			if err := oe.Stop(); err != nil {
+				glog.Errorf("Error while stopping the OpenCensus agent exporter: %v", err)
+			}
+		})
+	}
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
+// Format reads the trace of an HTTP request from the W3C traceparent header, or else from
+// the B3 headers of Zipkin and Jaeger, and writes it in both.
+var Format propagation.HTTPFormat = formats{&tracecontext.HTTPFormat{}, &b3.HTTPFormat{}}
+
+type formats []propagation.HTTPFormat
+
+func (f formats) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	for _, format := range f {
+		if sc, ok := format.SpanContextFromRequest(r); ok {
+			return sc, true
+		}
+	}
+	return trace.SpanContext{}, false
+}
+
+func (f formats) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	for _, format := range f {
+		format.SpanContextToRequest(sc, r)
+	}
+}
+
+// HTTP returns h with a span for each request, continuing the trace of the client.
+func HTTP(h http.Handler) http.Handler {
+	return &ochttp.Handler{Handler: h, Propagation: Format}
+}
+
+// ServerHandler returns the stats handler of a gRPC server that continues the traces of
+// the clients. They are read from the grpc-trace-bin metadata of OpenCensus clients, or else
+// from the traceparent metadata of W3C trace context, like OpenTelemetry clients send.
+func ServerHandler() stats.Handler {
+	return &serverHandler{}
+}
+
+type serverHandler struct {
+	ocgrpc.ServerHandler
+}
+
+func (h *serverHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok && len(md["grpc-trace-bin"]) == 0 && len(md["traceparent"]) > 0 {
+		r := &http.Request{Header: http.Header{
+			"Traceparent": md["traceparent"],
+			"Tracestate":  md["tracestate"],
+		}}
+		if sc, ok := (&tracecontext.HTTPFormat{}).SpanContextFromRequest(r); ok {
+			md = md.Copy()
+			md["grpc-trace-bin"] = []string{string(propagation.Binary(sc))}
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+	}
+	return h.ServerHandler.TagRPC(ctx, info)
+}
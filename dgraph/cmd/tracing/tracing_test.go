@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanId := "00f067aa0ba902b7"
+
+	// W3C trace context.
+	r, err := http.NewRequest("POST", "/query", nil)
+	require.NoError(t, err)
+	r.Header.Set("traceparent", "00-"+traceId+"-"+spanId+"-01")
+	sc, ok := Format.SpanContextFromRequest(r)
+	require.True(t, ok)
+	require.Equal(t, traceId, sc.TraceID.String())
+	require.Equal(t, spanId, sc.SpanID.String())
+	require.True(t, sc.IsSampled())
+
+	// B3 of Zipkin and Jaeger.
+	r, err = http.NewRequest("POST", "/query", nil)
+	require.NoError(t, err)
+	r.Header.Set("X-B3-TraceId", traceId)
+	r.Header.Set("X-B3-SpanId", spanId)
+	sc, ok = Format.SpanContextFromRequest(r)
+	require.True(t, ok)
+	require.Equal(t, traceId, sc.TraceID.String())
+
+	// Both are written.
+	r, err = http.NewRequest("POST", "/query", nil)
+	require.NoError(t, err)
+	Format.SpanContextToRequest(sc, r)
+	require.NotEmpty(t, r.Header.Get("traceparent"))
+	require.Equal(t, traceId, r.Header.Get("X-B3-TraceId"))
+
+	r, err = http.NewRequest("POST", "/query", nil)
+	require.NoError(t, err)
+	_, ok = Format.SpanContextFromRequest(r)
+	require.False(t, ok)
+}
@@ -146,6 +146,7 @@ func (s *Server) lease(ctx context.Context, num *pb.Num, txn bool) (*pb.Assigned
 			s.readOnlyTs = s.nextTxnTs
 			s.nextTxnTs++
 			out.ReadOnly = s.readOnlyTs
+			s.orc.markReadOnly(s.readOnlyTs)
 		}
 		s.orc.doneUntil.Begin(x.Max(out.EndId, out.ReadOnly))
 	} else {
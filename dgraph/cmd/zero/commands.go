@@ -0,0 +1,292 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zero
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+// adminOptions are the flags of the subcommands of dgraph zero, which talk to a running Zero.
+type adminOptions struct {
+	zero     string
+	tablet   string
+	group    uint32
+	dryRun   bool
+	undo     bool
+	maxMoves int
+	interval time.Duration
+}
+
+var adminOpt adminOptions
+
+// initAdminCommands adds the subcommands of dgraph zero.
+func initAdminCommands() {
+	move := &cobra.Command{
+		Use:   "move",
+		Short: "Move a tablet to another group",
+		Long: `
+Move moves the tablet of --tablet to the group of --group, and waits for it to be done. The group
+must be known, have a leader, and not be drained, and the tablet must not be pinned to another
+group by the rebalance policy.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAdmin(func(w io.Writer) error {
+				return startAndFollow(w, "/moveTablet", url.Values{
+					"tablet": {adminOpt.tablet},
+					"group":  {strconv.Itoa(int(adminOpt.group))},
+					"async":  {"true"},
+				})
+			})
+		},
+	}
+	flag := move.Flags()
+	flag.StringVar(&adminOpt.tablet, "tablet", "", "The predicate of the tablet to move.")
+	x.Check(move.MarkFlagRequired("tablet"))
+	addGroupFlag(move, "The group to move the tablet to.")
+
+	drain := &cobra.Command{
+		Use:   "drain",
+		Short: "Move all the tablets of a group to the other groups",
+		Long: `
+Drain marks the group of --group as drained, so that Zero stops assigning new tablets and new
+Alphas to it, and moves its tablets to the other groups, the biggest first, each one to the
+group it's pinned to or else to the smallest group. It waits for the moves to be done. A drained
+group keeps being drained by the rebalancing if some moves fail. With --dry_run, the moves are
+only listed. With --undo, the group is not drained anymore, and can take tablets again.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAdmin(func(w io.Writer) error {
+				params := url.Values{"group": {strconv.Itoa(int(adminOpt.group))}}
+				if adminOpt.undo {
+					params.Set("undo", "true")
+					return zeroRequest(w, "/drainGroup", params, nil)
+				}
+				params.Set("dry_run", strconv.FormatBool(adminOpt.dryRun))
+				return startAndFollow(w, "/drainGroup", params)
+			})
+		},
+	}
+	flag = drain.Flags()
+	flag.BoolVar(&adminOpt.dryRun, "dry_run", false, "Only list the moves.")
+	flag.BoolVar(&adminOpt.undo, "undo", false, "Stop draining the group.")
+	addGroupFlag(drain, "The group to drain.")
+
+	decommission := &cobra.Command{
+		Use:   "decommission",
+		Short: "Remove the Alphas of a drained group",
+		Long: `
+Decommission removes all the Alphas of the group of --group from the cluster, so that the group
+is gone. The group must have been drained, and serve no tablet anymore. The Alphas can be shut
+down afterwards, and their Raft ids can't be used again.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAdmin(func(w io.Writer) error {
+				return zeroRequest(w, "/decommissionGroup",
+					url.Values{"group": {strconv.Itoa(int(adminOpt.group))}}, nil)
+			})
+		},
+	}
+	addGroupFlag(decommission, "The group to decommission.")
+
+	rebalance := &cobra.Command{
+		Use:   "rebalance",
+		Short: "Move tablets as the rebalance policy says, right away",
+		Long: `
+Rebalance makes up to --max_moves moves of the rebalance policy right away, outside of its
+maintenance window too, and waits for them to be done: the tablets of drained groups and the
+pinned tablets are moved first, then the groups are balanced. With --dry_run, the moves are only
+listed.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAdmin(func(w io.Writer) error {
+				return startAndFollow(w, "/rebalance/now", url.Values{
+					"max_moves": {strconv.Itoa(adminOpt.maxMoves)},
+					"dry_run":   {strconv.FormatBool(adminOpt.dryRun)},
+				})
+			})
+		},
+	}
+	flag = rebalance.Flags()
+	flag.IntVar(&adminOpt.maxMoves, "max_moves", 10, "The most tablets to move.")
+	flag.BoolVar(&adminOpt.dryRun, "dry_run", false, "Only list the moves.")
+
+	progress := &cobra.Command{
+		Use:   "progress",
+		Short: "Follow the move, drain or rebalance running",
+		Long: `
+Progress reports the progress of the move, drain or rebalance running on the Zero, or the result
+of the last one, until it's done.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAdmin(func(w io.Writer) error {
+				var op operation
+				if err := zeroRequest(nil, "/operation", nil, &op); err != nil {
+					return err
+				}
+				return follow(w, &op)
+			})
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{move, drain, decommission, rebalance, progress} {
+		flag := cmd.Flags()
+		flag.StringVar(&adminOpt.zero, "zero", "localhost:6080", "HTTP address of a Zero.")
+		flag.DurationVar(&adminOpt.interval, "interval", 2*time.Second,
+			"How often to check the progress.")
+		Zero.Cmd.AddCommand(cmd)
+	}
+}
+
+func addGroupFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().Uint32Var(&adminOpt.group, "group", 0, usage)
+	x.Check(cmd.MarkFlagRequired("group"))
+}
+
+func runAdmin(f func(w io.Writer) error) {
+	if err := f(os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// zeroRequest sends a request to the HTTP endpoint path of the Zero. It decodes the JSON response
+// into out if set, or else copies the response to w.
+func zeroRequest(w io.Writer, path string, params url.Values, out interface{}) error {
+	u := url.URL{Scheme: "http", Host: adminOpt.zero, Path: path, RawQuery: params.Encode()}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return x.Wrapf(err, "while talking to Zero")
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return x.Wrapf(err, "while reading the response of Zero")
+	}
+
+	if bytes.HasPrefix(body, []byte("{")) {
+		var res struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &res); err == nil && len(res.Errors) > 0 {
+			return x.Errorf("%s", res.Errors[0].Message)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return x.Errorf("Zero responded with %s: %s", resp.Status, body)
+	}
+	if out != nil {
+		return json.Unmarshal(body, out)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", body)
+	return err
+}
+
+// startAndFollow starts an operation, and reports its progress until it's done.
+func startAndFollow(w io.Writer, path string, params url.Values) error {
+	var op operation
+	if err := zeroRequest(nil, path, params, &op); err != nil {
+		return err
+	}
+	return follow(w, &op)
+}
+
+// follow writes a line for every tablet move of op that starts or ends, until all are done. It
+// fails if any move failed.
+func follow(w io.Writer, op *operation) error {
+	what := op.Kind
+	if op.Group > 0 {
+		what = fmt.Sprintf("%s to group %d", op.Kind, op.Group)
+		if op.Kind == opDrain {
+			what = fmt.Sprintf("drain of group %d", op.Group)
+		}
+	}
+	if op.DryRun {
+		fmt.Fprintf(w, "The %s would move %d tablets:\n", what, len(op.Moves))
+		for i, m := range op.Moves {
+			fmt.Fprintf(w, "[%d/%d] %s\n", i+1, len(op.Moves), describeMove(m))
+		}
+		return nil
+	}
+	fmt.Fprintf(w, "Operation %d: %s, moving %d tablets.\n", op.Id, what, len(op.Moves))
+
+	reported := make([]string, len(op.Moves))
+	for {
+		for i, m := range op.Moves {
+			if m.Status == reported[i] || m.Status == movePending {
+				continue
+			}
+			reported[i] = m.Status
+			line := fmt.Sprintf("[%d/%d] %s: %s", i+1, len(op.Moves), describeMove(m), m.Status)
+			if m.Took != "" && m.Status != moveRunning {
+				line += " in " + m.Took
+			}
+			if m.Error != "" {
+				line += ": " + m.Error
+			}
+			fmt.Fprintln(w, line)
+		}
+		if op.Finished != nil {
+			break
+		}
+		time.Sleep(adminOpt.interval)
+
+		var next operation
+		if err := zeroRequest(nil, "/operation", nil, &next); err != nil {
+			return err
+		}
+		if next.Id != op.Id {
+			return x.Errorf("Operation %d isn't known by Zero anymore", op.Id)
+		}
+		op = &next
+	}
+
+	counts := make(map[string]int)
+	for _, m := range op.Moves {
+		counts[m.Status]++
+	}
+	fmt.Fprintf(w, "Done: %d moved, %d failed, %d skipped.\n",
+		counts[moveDone], counts[moveFailed], counts[moveSkipped])
+	if counts[moveFailed] > 0 {
+		return x.Errorf("%d tablet moves failed", counts[moveFailed])
+	}
+	return nil
+}
+
+func describeMove(m *tabletMove) string {
+	return fmt.Sprintf("%s (%s) from group %d to %d", m.Predicate,
+		humanize.Bytes(uint64(m.Space)), m.From, m.To)
+}
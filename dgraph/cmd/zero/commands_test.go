@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zero
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollow(t *testing.T) {
+	finished := time.Now()
+	// The states of the operation returned by /operation, one after the other.
+	states := []*operation{
+		{Id: 7, Kind: opDrain, Group: 2, Moves: []*tabletMove{
+			{Predicate: "name", From: 2, To: 1, Space: 2000, Status: moveDone, Took: "3s"},
+			{Predicate: "age", From: 2, To: 3, Space: 1000, Status: moveRunning},
+		}},
+		{Id: 7, Kind: opDrain, Group: 2, Finished: &finished, Moves: []*tabletMove{
+			{Predicate: "name", From: 2, To: 1, Space: 2000, Status: moveDone, Took: "3s"},
+			{Predicate: "age", From: 2, To: 3, Space: 1000, Status: moveFailed, Took: "1s",
+				Error: "no leader"},
+		}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/operation", r.URL.Path)
+		require.NoError(t, json.NewEncoder(w).Encode(states[0]))
+		states = states[1:]
+	}))
+	defer srv.Close()
+	adminOpt.zero = strings.TrimPrefix(srv.URL, "http://")
+	adminOpt.interval = time.Millisecond
+
+	var buf bytes.Buffer
+	err := follow(&buf, &operation{Id: 7, Kind: opDrain, Group: 2, Moves: []*tabletMove{
+		{Predicate: "name", From: 2, To: 1, Space: 2000, Status: movePending},
+		{Predicate: "age", From: 2, To: 3, Space: 1000, Status: movePending},
+	}})
+	require.Error(t, err)
+	require.Equal(t, `Operation 7: drain of group 2, moving 2 tablets.
+[1/2] name (2.0 kB) from group 2 to 1: done in 3s
+[2/2] age (1.0 kB) from group 2 to 3: moving
+[2/2] age (1.0 kB) from group 2 to 3: failed in 1s: no leader
+Done: 1 moved, 1 failed, 0 skipped.
+`, buf.String())
+}
+
+func TestZeroRequestError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"code":"ErrorInvalidRequest",` +
+			`"message":"Group: [2] must be drained first."}]}`))
+	}))
+	defer srv.Close()
+	adminOpt.zero = strings.TrimPrefix(srv.URL, "http://")
+
+	err := zeroRequest(nil, "/decommissionGroup", nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Group: [2] must be drained first.")
+}
@@ -18,7 +18,9 @@ package zero
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
@@ -167,6 +169,19 @@ func (st *state) moveTablet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("async") == "true" {
+		// Move the tablet in the background, like dgraph zero move does. Its progress is
+		// reported by /operation.
+		op, err := st.zero.moveOperation(tablet, dstGroup)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+			return
+		}
+		writeJSON(w, op)
+		return
+	}
+
 	tab := st.zero.ServingTablet(tablet)
 	if tab == nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -192,6 +207,256 @@ func (st *state) moveTablet(w http.ResponseWriter, r *http.Request) {
 		tablet, srcGroup, dstGroup)))
 }
 
+// rebalancePolicy returns the rebalance policy on a GET, and replaces it with the one in the body
+// on a PUT.
+func (st *state) rebalancePolicy(w http.ResponseWriter, r *http.Request) {
+	x.AddCorsHeaders(w)
+	if r.Method == "OPTIONS" {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+			return
+		}
+		policy, err := parseRebalancePolicy(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+			return
+		}
+		if !st.updateRebalancePolicy(w, func(p *RebalancePolicy) error {
+			*p = *policy
+			return nil
+		}) {
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidMethod, "Invalid method")
+		return
+	}
+	writeJSON(w, st.zero.rebalancePolicy())
+}
+
+// pinPredicate pins a predicate to a group: it's moved there, and never moved away
+// automatically. It takes in predicate and group as argument.
+func (st *state) pinPredicate(w http.ResponseWriter, r *http.Request) {
+	predicate, ok := predicateFromQueryParam(w, r)
+	if !ok {
+		return
+	}
+	groupId, ok := intFromQueryParam(w, r, "group")
+	if !ok {
+		return
+	}
+	gid := uint32(groupId)
+	if _, has := st.zero.membershipState().Groups[gid]; !has {
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidRequest, fmt.Sprintf("Group: [%d] is not a known group.",
+			gid))
+		return
+	}
+	if !st.updateRebalancePolicy(w, func(p *RebalancePolicy) error {
+		if p.Pins == nil {
+			p.Pins = make(map[string]uint32)
+		}
+		p.Pins[predicate] = gid
+		return nil
+	}) {
+		return
+	}
+	writeJSON(w, st.zero.rebalancePolicy())
+}
+
+// excludePredicate excludes a predicate from the automatic moves. It takes in predicate as
+// argument.
+func (st *state) excludePredicate(w http.ResponseWriter, r *http.Request) {
+	predicate, ok := predicateFromQueryParam(w, r)
+	if !ok {
+		return
+	}
+	if !st.updateRebalancePolicy(w, func(p *RebalancePolicy) error {
+		for _, ex := range p.Exclude {
+			if ex == predicate {
+				return nil
+			}
+		}
+		p.Exclude = append(p.Exclude, predicate)
+		return nil
+	}) {
+		return
+	}
+	writeJSON(w, st.zero.rebalancePolicy())
+}
+
+// releasePredicate removes the pin and the exclusion of a predicate, so that it's moved like any
+// other. It takes in predicate as argument.
+func (st *state) releasePredicate(w http.ResponseWriter, r *http.Request) {
+	predicate, ok := predicateFromQueryParam(w, r)
+	if !ok {
+		return
+	}
+	if !st.updateRebalancePolicy(w, func(p *RebalancePolicy) error {
+		delete(p.Pins, predicate)
+		exclude := p.Exclude[:0]
+		for _, ex := range p.Exclude {
+			if ex != predicate {
+				exclude = append(exclude, ex)
+			}
+		}
+		p.Exclude = exclude
+		return nil
+	}) {
+		return
+	}
+	writeJSON(w, st.zero.rebalancePolicy())
+}
+
+// predicateFromQueryParam checks the method of a request to change the rebalance policy, and
+// returns its predicate query param. It also writes any errors to w.
+func predicateFromQueryParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !checkAdminMethod(w, r) {
+		return "", false
+	}
+	predicate := r.URL.Query().Get("predicate")
+	if len(predicate) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidRequest, "predicate is a mandatory query parameter")
+		return "", false
+	}
+	return predicate, true
+}
+
+// updateRebalancePolicy proposes the rebalance policy changed by update. It also writes any
+// errors to w.
+func (st *state) updateRebalancePolicy(w http.ResponseWriter,
+	update func(p *RebalancePolicy) error) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := st.zero.updateRebalancePolicy(ctx, update); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		x.SetStatus(w, x.Error, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		x.SetStatus(w, x.Error, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	x.Check2(w.Write(js))
+}
+
+// drainGroup moves all the tablets of a group to the other groups, in the background, and stops
+// assigning tablets and new Alphas to it. It takes in group as argument, and dry_run to only
+// return the moves, or undo to let the group take tablets again.
+func (st *state) drainGroup(w http.ResponseWriter, r *http.Request) {
+	if !checkAdminMethod(w, r) {
+		return
+	}
+	groupId, ok := intFromQueryParam(w, r, "group")
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if r.URL.Query().Get("undo") == "true" {
+		if err := st.zero.undrainGroup(ctx, uint32(groupId)); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			x.SetStatus(w, x.Error, err.Error())
+			return
+		}
+		w.Write([]byte(fmt.Sprintf("Group: [%d] is not drained anymore", groupId)))
+		return
+	}
+	op, err := st.zero.drainOperation(ctx, uint32(groupId), r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+		return
+	}
+	writeJSON(w, op)
+}
+
+// decommissionGroup removes all the Alphas of a drained group that serves no tablets anymore. It
+// takes in group as argument.
+func (st *state) decommissionGroup(w http.ResponseWriter, r *http.Request) {
+	if !checkAdminMethod(w, r) {
+		return
+	}
+	groupId, ok := intFromQueryParam(w, r, "group")
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := st.zero.decommissionGroup(ctx, uint32(groupId)); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("Group: [%d] decommissioned. Its Alphas can be shut down.",
+		groupId)))
+}
+
+// rebalanceNow makes the moves of the rebalance policy right away, in the background. It takes in
+// max_moves, 10 by default, and dry_run to only return the moves.
+func (st *state) rebalanceNow(w http.ResponseWriter, r *http.Request) {
+	if !checkAdminMethod(w, r) {
+		return
+	}
+	maxMoves := uint64(10)
+	if r.URL.Query().Get("max_moves") != "" {
+		var ok bool
+		if maxMoves, ok = intFromQueryParam(w, r, "max_moves"); !ok {
+			return
+		}
+	}
+	op, err := st.zero.rebalanceOperation(int(maxMoves), r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidRequest, err.Error())
+		return
+	}
+	writeJSON(w, op)
+}
+
+// getOperation returns the progress of the move, drain or rebalance running, or of the last one.
+func (st *state) getOperation(w http.ResponseWriter, r *http.Request) {
+	if !checkAdminMethod(w, r) {
+		return
+	}
+	op := lastOperation()
+	if op == nil {
+		x.SetStatus(w, x.ErrorNoData, "No operation has run.")
+		return
+	}
+	writeJSON(w, op)
+}
+
+func checkAdminMethod(w http.ResponseWriter, r *http.Request) bool {
+	x.AddCorsHeaders(w)
+	if r.Method == "OPTIONS" {
+		return false
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusBadRequest)
+		x.SetStatus(w, x.ErrorInvalidMethod, "Invalid method")
+		return false
+	}
+	return true
+}
+
 func (st *state) getState(w http.ResponseWriter, r *http.Request) {
 	x.AddCorsHeaders(w)
 	w.Header().Set("Content-Type", "application/json")
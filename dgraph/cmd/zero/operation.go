@@ -0,0 +1,321 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zero
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	opMove      = "move"
+	opDrain     = "drain"
+	opRebalance = "rebalance"
+
+	movePending = "pending"
+	moveRunning = "moving"
+	moveDone    = "done"
+	moveFailed  = "failed"
+	moveSkipped = "skipped"
+)
+
+// operation is a move, drain or rebalance started by hand, which moves tablets one after the
+// other in the background.
+type operation struct {
+	Id       uint64        `json:"id"`
+	Kind     string        `json:"kind"`
+	Group    uint32        `json:"group,omitempty"`
+	DryRun   bool          `json:"dry_run,omitempty"`
+	Started  time.Time     `json:"started"`
+	Finished *time.Time    `json:"finished,omitempty"`
+	Moves    []*tabletMove `json:"moves"`
+}
+
+// operations keeps the operation running, or the last one. Only one runs at a time, and the
+// tablets aren't rebalanced meanwhile.
+var operations struct {
+	sync.Mutex
+	last   *operation
+	nextId uint64
+}
+
+func operationRunning() bool {
+	operations.Lock()
+	defer operations.Unlock()
+	return operations.last != nil && operations.last.Finished == nil
+}
+
+// lastOperation returns a copy of the operation running, or of the last one, or nil.
+func lastOperation() *operation {
+	operations.Lock()
+	defer operations.Unlock()
+	if operations.last == nil {
+		return nil
+	}
+	return copyOperation(operations.last)
+}
+
+func copyOperation(op *operation) *operation {
+	cp := *op
+	cp.Moves = make([]*tabletMove, len(op.Moves))
+	for i, m := range op.Moves {
+		mv := *m
+		cp.Moves[i] = &mv
+	}
+	return &cp
+}
+
+// startOperation starts moving the tablets of moves one after the other, unless another
+// operation is running. A dry run only reports the moves.
+func (s *Server) startOperation(kind string, gid uint32, moves []*tabletMove,
+	dryRun bool) (*operation, error) {
+	operations.Lock()
+	defer operations.Unlock()
+	if op := operations.last; op != nil && op.Finished == nil {
+		return nil, x.Errorf("The %s operation %d is still running", op.Kind, op.Id)
+	}
+	operations.nextId++
+	op := &operation{
+		Id:      operations.nextId,
+		Kind:    kind,
+		Group:   gid,
+		DryRun:  dryRun,
+		Started: time.Now(),
+		Moves:   moves,
+	}
+	for _, m := range moves {
+		m.Status = movePending
+	}
+	if dryRun || len(moves) == 0 {
+		now := time.Now()
+		op.Finished = &now
+		return op, nil
+	}
+	operations.last = op
+	glog.Infof("Starting the %s operation %d, with %d tablet moves", kind, op.Id, len(moves))
+	go s.runOperation(op)
+	return copyOperation(op), nil
+}
+
+func (s *Server) runOperation(op *operation) {
+	setStatus := func(m *tabletMove, status string, err error) {
+		operations.Lock()
+		defer operations.Unlock()
+		m.Status = status
+		if err != nil {
+			m.Error = err.Error()
+		}
+	}
+
+	for _, m := range op.Moves {
+		// Check again, the tablet might have been moved since the operation was planned.
+		if tab := s.ServingTablet(m.Predicate); tab == nil || tab.GroupId != m.From {
+			setStatus(m, moveSkipped, x.Errorf("Tablet isn't served by group %d anymore", m.From))
+			continue
+		}
+		setStatus(m, moveRunning, nil)
+		start := time.Now()
+		err := s.movePredicate(m.Predicate, m.From, m.To)
+		operations.Lock()
+		m.Took = time.Since(start).Round(time.Second).String()
+		operations.Unlock()
+		if err != nil {
+			glog.Errorln(err)
+			setStatus(m, moveFailed, err)
+			continue
+		}
+		setStatus(m, moveDone, nil)
+	}
+
+	operations.Lock()
+	defer operations.Unlock()
+	now := time.Now()
+	op.Finished = &now
+	glog.Infof("The %s operation %d is done", op.Kind, op.Id)
+}
+
+// moveOperation starts moving tablet predicate to group gid.
+func (s *Server) moveOperation(predicate string, gid uint32) (*operation, error) {
+	if err := s.checkDestination(gid); err != nil {
+		return nil, err
+	}
+	tab := s.ServingTablet(predicate)
+	if tab == nil {
+		return nil, x.Errorf("No tablet found for: %s", predicate)
+	}
+	if tab.GroupId == gid {
+		return nil, x.Errorf("Tablet: [%s] is already being served by group: [%d]",
+			predicate, gid)
+	}
+	policy := s.rebalancePolicy()
+	if pin, ok := policy.Pins[predicate]; ok && pin != gid {
+		return nil, x.Errorf("Tablet: [%s] is pinned to group: [%d]. Release it first.",
+			predicate, pin)
+	}
+	return s.startOperation(opMove, gid, []*tabletMove{{
+		Predicate: predicate,
+		From:      tab.GroupId,
+		To:        gid,
+		Space:     tab.Space,
+	}}, false)
+}
+
+// checkDestination checks that tablets can be moved to group gid.
+func (s *Server) checkDestination(gid uint32) error {
+	s.RLock()
+	defer s.RUnlock()
+	if _, ok := s.state.Groups[gid]; !ok {
+		return x.Errorf("Group: [%d] is not a known group.", gid)
+	}
+	if s.readRebalancePolicy().drained(gid) {
+		return x.Errorf("Group: [%d] is being drained.", gid)
+	}
+	if !s.hasLeader(gid) {
+		return x.Errorf("Group: [%d] has no leader.", gid)
+	}
+	return nil
+}
+
+// drainOperation marks group gid as drained, and starts moving all its tablets to the other
+// groups. A dry run only reports the moves, without marking the group.
+func (s *Server) drainOperation(ctx context.Context, gid uint32,
+	dryRun bool) (*operation, error) {
+	s.RLock()
+	_, known := s.state.Groups[gid]
+	var others int
+	drained := s.readRebalancePolicy().drained
+	for g := range s.state.Groups {
+		if g != gid && !drained(g) && s.hasLeader(g) {
+			others++
+		}
+	}
+	s.RUnlock()
+	if !known {
+		return nil, x.Errorf("Group: [%d] is not a known group.", gid)
+	}
+	if others == 0 {
+		return nil, x.Errorf("No other group with a leader can take the tablets of group: [%d]",
+			gid)
+	}
+
+	policy := s.rebalancePolicy()
+	for pred, pin := range policy.Pins {
+		if pin == gid {
+			return nil, x.Errorf("Tablet: [%s] is pinned to group: [%d]. Release it first.",
+				pred, gid)
+		}
+	}
+	if !dryRun && !policy.drained(gid) {
+		var err error
+		policy, err = s.updateRebalancePolicy(ctx, func(p *RebalancePolicy) error {
+			if !p.drained(gid) {
+				p.Drain = append(p.Drain, gid)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if !policy.drained(gid) {
+		policy.Drain = append(policy.Drain, gid)
+	}
+
+	// Only plan the moves out of the drained group.
+	plan := &RebalancePolicy{By: rebalanceNone, Pins: policy.Pins, Drain: policy.Drain}
+	var moves []*tabletMove
+	for _, m := range s.planMoves(plan, nil, math.MaxInt32) {
+		if m.From == gid {
+			moves = append(moves, m)
+		}
+	}
+	return s.startOperation(opDrain, gid, moves, dryRun)
+}
+
+// undrainGroup lets tablets and new Alphas be assigned to group gid again.
+func (s *Server) undrainGroup(ctx context.Context, gid uint32) error {
+	_, err := s.updateRebalancePolicy(ctx, func(p *RebalancePolicy) error {
+		drain := p.Drain[:0]
+		for _, g := range p.Drain {
+			if g != gid {
+				drain = append(drain, g)
+			}
+		}
+		p.Drain = drain
+		return nil
+	})
+	return err
+}
+
+// rebalanceOperation starts up to max moves of the rebalance policy right away, ignoring its
+// maintenance window.
+func (s *Server) rebalanceOperation(max int, dryRun bool) (*operation, error) {
+	policy := s.rebalancePolicy()
+	loads := lastLoads()
+	if policy.By == rebalanceByQps && loads == nil {
+		return nil, x.Errorf("The queries per second of the tablets aren't known yet." +
+			" Try again after the next rebalance interval.")
+	}
+	return s.startOperation(opRebalance, 0, s.planMoves(policy, loads, max), dryRun)
+}
+
+// decommissionGroup removes all the Alphas of group gid, which must have been drained, so that
+// the group is gone.
+func (s *Server) decommissionGroup(ctx context.Context, gid uint32) error {
+	if operationRunning() {
+		return x.Errorf("Wait for the running operation to finish")
+	}
+	state := s.membershipState()
+	group, ok := state.Groups[gid]
+	if !ok {
+		return x.Errorf("Group: [%d] is not a known group.", gid)
+	}
+	if !s.rebalancePolicy().drained(gid) {
+		return x.Errorf("Group: [%d] must be drained first.", gid)
+	}
+	if n := len(group.Tablets); n > 0 {
+		return x.Errorf("Group: [%d] still serves %d tablets. Drain it first.", gid, n)
+	}
+	for id := range group.Members {
+		if err := s.removeNode(ctx, id, gid); err != nil {
+			return x.Wrapf(err, "while removing node %d of group %d", id, gid)
+		}
+	}
+	return s.undrainGroup(ctx, gid)
+}
+
+// qpsLoads are the queries per second of the tablets measured at the last rebalance interval.
+var qpsLoads struct {
+	sync.Mutex
+	m map[string]float64
+}
+
+func setLoads(m map[string]float64) {
+	qpsLoads.Lock()
+	defer qpsLoads.Unlock()
+	qpsLoads.m = m
+}
+
+func lastLoads() map[string]float64 {
+	qpsLoads.Lock()
+	defer qpsLoads.Unlock()
+	return qpsLoads.m
+}
@@ -41,6 +41,10 @@ type Oracle struct {
 	// TODO: Check if we need LRU.
 	keyCommit   map[string]uint64 // fp(key) -> commitTs. Used to detect conflict.
 	maxAssigned uint64            // max transaction assigned by us.
+	// Read-only timestamps handed out by us. Transactions started at them can't commit. The set
+	// only lives in the memory of the leader, and isn't proposed. It doesn't need to be: a new
+	// leader aborts every transaction started before it, at a timestamp below startTxnTs.
+	readOnly map[uint64]struct{}
 
 	// timestamp at the time of start of server or when it became leader. Used to detect conflicts.
 	tmax uint64
@@ -55,6 +59,7 @@ type Oracle struct {
 func (o *Oracle) Init() {
 	o.commits = make(map[uint64]uint64)
 	o.keyCommit = make(map[string]uint64)
+	o.readOnly = make(map[uint64]struct{})
 	o.subscribers = make(map[int]chan *pb.OracleDelta)
 	o.updates = make(chan *pb.OracleDelta, 100000) // Keeping 1 second worth of updates.
 	o.doneUntil.Init()
@@ -74,6 +79,10 @@ func (o *Oracle) hasConflict(src *api.TxnContext) bool {
 	if src.StartTs < o.startTxnTs {
 		return true
 	}
+	// A read-only transaction can't write, whichever Alpha its mutations went through.
+	if _, ok := o.readOnly[src.StartTs]; ok {
+		return true
+	}
 	for _, k := range src.Keys {
 		if last := o.keyCommit[k]; last > src.StartTs {
 			return true
@@ -99,12 +108,24 @@ func (o *Oracle) purgeBelow(minTs uint64) {
 			delete(o.keyCommit, key)
 		}
 	}
+	for ts := range o.readOnly {
+		if ts < minTs {
+			delete(o.readOnly, ts)
+		}
+	}
 	o.tmax = minTs
 	glog.Infof("Purged below ts:%d, len(o.commits):%d"+
 		", len(o.rowCommit):%d\n",
 		minTs, len(o.commits), len(o.keyCommit))
 }
 
+// markReadOnly records ts as the start timestamp of read-only transactions.
+func (o *Oracle) markReadOnly(ts uint64) {
+	o.Lock()
+	defer o.Unlock()
+	o.readOnly[ts] = struct{}{}
+}
+
 func (o *Oracle) commit(src *api.TxnContext) error {
 	o.Lock()
 	defer o.Unlock()
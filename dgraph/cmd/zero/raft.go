@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -32,6 +34,7 @@ import (
 	"github.com/dgraph-io/dgraph/conn"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/x"
+	"github.com/gogo/protobuf/jsonpb"
 	"github.com/golang/glog"
 	"github.com/google/uuid"
 	"golang.org/x/net/context"
@@ -189,10 +192,19 @@ func (n *node) handleMemberProposal(member *pb.Member) error {
 			state.Removed = append(state.Removed, m)
 		}
 		// else already removed.
+
+		// A group left without members or tablets, like a decommissioned one, is gone.
+		if len(group.Members) == 0 && len(group.Tablets) == 0 {
+			delete(state.Groups, member.GroupId)
+		}
 		return nil
 	}
-	if !has && len(group.Members) >= n.server.NumReplicas {
-		// We shouldn't allow more members than the number of replicas.
+	if has {
+		// Whether a member is a learner is decided when it joins, whatever the updates it sends.
+		member.Learner = m.Learner
+	}
+	if !has && !member.Learner && numVoters(group) >= n.server.NumReplicas {
+		// We shouldn't allow more members than the number of replicas. Learners don't count.
 		return x.Errorf("Group reached replication level. Can't add another member: %+v", member)
 	}
 
@@ -202,7 +214,7 @@ func (n *node) handleMemberProposal(member *pb.Member) error {
 	group.Members[member.Id] = member
 	// Increment nextGroup when we have enough replicas
 	if member.GroupId == n.server.nextGroup &&
-		len(group.Members) >= n.server.NumReplicas {
+		numVoters(group) >= n.server.NumReplicas {
 		n.server.nextGroup++
 	}
 	if member.Leader {
@@ -323,6 +335,9 @@ func (n *node) applyProposal(e raftpb.Entry) (string, error) {
 			return p.Key, err
 		}
 	}
+	if len(p.RebalancePolicy) > 0 {
+		state.RebalancePolicy = p.RebalancePolicy
+	}
 
 	if p.MaxLeaseId > state.MaxLeaseId {
 		state.MaxLeaseId = p.MaxLeaseId
@@ -444,6 +459,13 @@ func (n *node) initAndStartNode() error {
 		n.SetRaft(raft.StartNode(n.Cfg, nil))
 
 	} else {
+		var restored *pb.MembershipState
+		if len(opts.restoreState) > 0 {
+			if restored, err = readRestoredState(opts.restoreState); err != nil {
+				return err
+			}
+		}
+
 		data, err := n.RaftContext.Marshal()
 		x.Check(err)
 		peers := []raft.Peer{{ID: n.Id, Context: data}}
@@ -456,6 +478,9 @@ func (n *node) initAndStartNode() error {
 				err := n.proposeAndWait(context.Background(), &pb.ZeroProposal{Cid: id})
 				if err == nil {
 					glog.Infof("CID set for cluster: %v", id)
+					if restored != nil {
+						n.proposeRestoredState(restored)
+					}
 					return
 				}
 				if err == errInvalidProposal {
@@ -472,6 +497,71 @@ func (n *node) initAndStartNode() error {
 	return nil
 }
 
+// readRestoredState reads the state file written by dgraph restore.
+func readRestoredState(file string) (*pb.MembershipState, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var state pb.MembershipState
+	if err := jsonpb.Unmarshal(f, &state); err != nil {
+		return nil, x.Wrapf(err, "while reading restored state from %q", file)
+	}
+	return &state, nil
+}
+
+// proposeRestoredState proposes the tablets and leases of a restored cluster, so each
+// predicate is served by the group it was restored into, and the UIDs and timestamps used by
+// the restored data aren't leased again.
+func (n *node) proposeRestoredState(state *pb.MembershipState) {
+	propose := func(p *pb.ZeroProposal) {
+		for {
+			err := n.proposeAndWait(context.Background(), p)
+			if err == nil {
+				return
+			}
+			glog.Errorf("While proposing restored state: %v. Retrying...", err)
+			time.Sleep(3 * time.Second)
+		}
+	}
+
+	var gids []uint32
+	for gid := range state.Groups {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	for _, gid := range gids {
+		var attrs []string
+		for attr := range state.Groups[gid].Tablets {
+			attrs = append(attrs, attr)
+		}
+		sort.Strings(attrs)
+		for _, attr := range attrs {
+			// Force it, an Alpha could have asked to serve the predicate already.
+			propose(&pb.ZeroProposal{Tablet: &pb.Tablet{
+				GroupId:   gid,
+				Predicate: attr,
+				Force:     true,
+			}})
+		}
+		glog.Infof("Restored %d tablets of group %d", len(attrs), gid)
+	}
+
+	// The leases in memory must be updated too, block any lease until then.
+	s := n.server
+	s.leaseLock.Lock()
+	defer s.leaseLock.Unlock()
+	// A proposal can only set one of them.
+	if state.MaxLeaseId > 0 {
+		propose(&pb.ZeroProposal{MaxLeaseId: state.MaxLeaseId})
+	}
+	if state.MaxTxnTs > 0 {
+		propose(&pb.ZeroProposal{MaxTxnTs: state.MaxTxnTs})
+	}
+	s.updateLeases()
+}
+
 func (n *node) updateZeroMembershipPeriodically(closer *y.Closer) {
 	defer closer.Done()
 	ticker := time.NewTicker(10 * time.Second)
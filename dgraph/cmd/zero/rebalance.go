@@ -0,0 +1,487 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zero
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/conn"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	rebalanceBySize = "size"
+	rebalanceByQps  = "qps"
+	rebalanceNone   = "none"
+)
+
+// RebalancePolicy decides how Zero moves tablets between groups. It's kept in the membership
+// state, so that every Zero applies the same one. The zero policy balances the groups by size,
+// at any time.
+type RebalancePolicy struct {
+	// By is what the groups are balanced by: the "size" of their tablets, the "qps" of the
+	// queries they serve, or "none" to not balance them.
+	By string `json:"by,omitempty"`
+	// Window is the maintenance window, like "01:00-05:00" in UTC, outside of which no tablet
+	// is moved automatically. Empty for any time.
+	Window string `json:"window,omitempty"`
+	// Pins are the groups some predicates must be served by. They are moved there, even if By
+	// is "none", and never away.
+	Pins map[string]uint32 `json:"pins,omitempty"`
+	// Exclude are the predicates that are never moved automatically.
+	Exclude []string `json:"exclude,omitempty"`
+	// Drain are the groups being drained: their tablets are moved to the other groups, and no
+	// tablet or new Alpha is assigned to them.
+	Drain []uint32 `json:"drain,omitempty"`
+}
+
+// parseRebalancePolicy parses a rebalance policy in JSON.
+func parseRebalancePolicy(data []byte) (*RebalancePolicy, error) {
+	p := &RebalancePolicy{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, x.Errorf("Invalid rebalance policy: %v", err)
+	}
+	switch p.By {
+	case "", rebalanceBySize, rebalanceByQps, rebalanceNone:
+	default:
+		return nil, x.Errorf("Invalid rebalance policy: by must be one of size, qps or none."+
+			" Got: %q", p.By)
+	}
+	if _, _, err := parseWindow(p.Window); err != nil {
+		return nil, err
+	}
+	for pred, gid := range p.Pins {
+		if gid == 0 {
+			return nil, x.Errorf("Invalid rebalance policy: %q is pinned to group 0", pred)
+		}
+		if p.drained(gid) {
+			return nil, x.Errorf("Invalid rebalance policy: %q is pinned to group %d,"+
+				" which is drained", pred, gid)
+		}
+	}
+	return p, nil
+}
+
+// parseWindow parses a window like "22:00-02:30", returning its start and end as offsets from
+// midnight.
+func parseWindow(w string) (start, end time.Duration, err error) {
+	if w == "" {
+		return 0, 0, nil
+	}
+	var sh, sm, eh, em int
+	if n, _ := fmt.Sscanf(w, "%d:%d-%d:%d", &sh, &sm, &eh, &em); n != 4 ||
+		sh > 23 || eh > 23 || sm > 59 || em > 59 || sh < 0 || eh < 0 || sm < 0 || em < 0 {
+		return 0, 0, x.Errorf("Invalid rebalance policy: window must be like 01:00-05:00."+
+			" Got: %q", w)
+	}
+	start = time.Duration(sh)*time.Hour + time.Duration(sm)*time.Minute
+	end = time.Duration(eh)*time.Hour + time.Duration(em)*time.Minute
+	return start, end, nil
+}
+
+// inWindow returns whether tablets can be moved automatically at t.
+func (p *RebalancePolicy) inWindow(t time.Time) bool {
+	start, end, err := parseWindow(p.Window)
+	if err != nil || p.Window == "" {
+		return err == nil
+	}
+	t = t.UTC()
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if start <= end {
+		return start <= now && now < end
+	}
+	// The window spans midnight.
+	return now >= start || now < end
+}
+
+// movable returns whether pred can be moved to balance the groups.
+func (p *RebalancePolicy) movable(pred string) bool {
+	if _, ok := p.Pins[pred]; ok {
+		return false
+	}
+	for _, ex := range p.Exclude {
+		if ex == pred {
+			return false
+		}
+	}
+	return true
+}
+
+// drained returns whether group gid is being drained.
+func (p *RebalancePolicy) drained(gid uint32) bool {
+	for _, g := range p.Drain {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// rebalancePolicy returns the rebalance policy in the membership state.
+func (s *Server) rebalancePolicy() *RebalancePolicy {
+	s.RLock()
+	defer s.RUnlock()
+	return s.readRebalancePolicy()
+}
+
+func (s *Server) readRebalancePolicy() *RebalancePolicy {
+	s.AssertRLock()
+	if s.state == nil || s.state.RebalancePolicy == "" {
+		return &RebalancePolicy{}
+	}
+	p, err := parseRebalancePolicy([]byte(s.state.RebalancePolicy))
+	if err != nil {
+		// Only valid policies are proposed.
+		glog.Errorf("While reading the rebalance policy: %v", err)
+		return &RebalancePolicy{By: rebalanceNone}
+	}
+	return p
+}
+
+// assignedGroup returns the group a new tablet asked for by group gid is assigned to: the group
+// it's pinned to, or the smallest group if gid is being drained, or else gid.
+func (s *Server) assignedGroup(predicate string, gid uint32) uint32 {
+	s.RLock()
+	defer s.RUnlock()
+	p := s.readRebalancePolicy()
+	if pin, ok := p.Pins[predicate]; ok {
+		if _, has := s.state.Groups[pin]; has {
+			return pin
+		}
+	}
+	if !p.drained(gid) {
+		return gid
+	}
+	dst, dstSpace := gid, int64(0)
+	for g, group := range s.state.Groups {
+		if p.drained(g) || len(group.Members) == 0 {
+			continue
+		}
+		var space int64
+		for _, tab := range group.Tablets {
+			space += tab.Space
+		}
+		if dst == gid || space < dstSpace {
+			dst, dstSpace = g, space
+		}
+	}
+	return dst
+}
+
+// policyLock serializes the updates of the rebalance policy.
+var policyLock sync.Mutex
+
+// updateRebalancePolicy applies update to the rebalance policy, and proposes the result.
+func (s *Server) updateRebalancePolicy(ctx context.Context,
+	update func(p *RebalancePolicy) error) (*RebalancePolicy, error) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	p := s.rebalancePolicy()
+	if err := update(p); err != nil {
+		return nil, err
+	}
+	js, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	// Check the policy the same way it will be read.
+	if _, err := parseRebalancePolicy(js); err != nil {
+		return nil, err
+	}
+	if err := s.Node.proposeAndWait(ctx, &pb.ZeroProposal{RebalancePolicy: string(js)}); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// tabletMove is a move of a tablet between groups, and how it went.
+type tabletMove struct {
+	Predicate string `json:"predicate"`
+	From      uint32 `json:"from"`
+	To        uint32 `json:"to"`
+	Space     int64  `json:"size_bytes"`
+	Status    string `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Took      string `json:"took,omitempty"`
+}
+
+// planMoves returns up to max moves that Zero would make one after the other under policy p.
+// loads are the queries per second of the tablets, for the qps policy.
+func (s *Server) planMoves(p *RebalancePolicy, loads map[string]float64,
+	max int) []*tabletMove {
+	s.RLock()
+	defer s.RUnlock()
+	if s.state == nil {
+		return nil
+	}
+
+	var weight func(tab *pb.Tablet) float64
+	switch p.By {
+	case "", rebalanceBySize:
+		weight = func(tab *pb.Tablet) float64 { return float64(tab.Space) }
+	case rebalanceByQps:
+		if loads != nil {
+			weight = func(tab *pb.Tablet) float64 { return loads[tab.Predicate] }
+		}
+	}
+	return planMoves(s.state.Groups, p, weight, s.hasLeader, max)
+}
+
+// planMoves returns up to max moves of tablets between groups, each one planned as if the previous
+// ones were done. The tablets of drained groups are moved first, to the group they are pinned to
+// or else to the smallest one. Then, the pinned tablets are moved to their group. Last, the loads
+// of the groups are balanced if weight is set.
+func planMoves(groups map[uint32]*pb.Group, p *RebalancePolicy,
+	weight func(tab *pb.Tablet) float64, hasLeader func(gid uint32) bool,
+	max int) []*tabletMove {
+	// Plan on a copy of the tablets of the groups.
+	plan := make(map[uint32]*pb.Group, len(groups))
+	for gid, group := range groups {
+		tablets := make(map[string]*pb.Tablet, len(group.Tablets))
+		for pred, tab := range group.Tablets {
+			tablets[pred] = tab
+		}
+		plan[gid] = &pb.Group{Tablets: tablets}
+	}
+	// The groups tablets can be moved to.
+	canReceive := func(gid uint32) bool {
+		_, ok := plan[gid]
+		return ok && !p.drained(gid) && hasLeader(gid)
+	}
+
+	var moves []*tabletMove
+	for len(moves) < max {
+		m := nextMove(plan, p, weight, canReceive, hasLeader)
+		if m == nil {
+			break
+		}
+		tab := plan[m.From].Tablets[m.Predicate]
+		delete(plan[m.From].Tablets, m.Predicate)
+		plan[m.To].Tablets[m.Predicate] = tab
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+func nextMove(groups map[uint32]*pb.Group, p *RebalancePolicy,
+	weight func(tab *pb.Tablet) float64, canReceive func(gid uint32) bool,
+	hasLeader func(gid uint32) bool) *tabletMove {
+	gids := make([]uint32, 0, len(groups))
+	for gid := range groups {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+
+	// Move the biggest tablet of a drained group out first.
+	for _, gid := range gids {
+		if !p.drained(gid) {
+			continue
+		}
+		var tab *pb.Tablet
+		for _, t := range groups[gid].Tablets {
+			if tab == nil || t.Space > tab.Space ||
+				(t.Space == tab.Space && t.Predicate < tab.Predicate) {
+				tab = t
+			}
+		}
+		if tab == nil {
+			continue
+		}
+		dst, ok := p.Pins[tab.Predicate]
+		if !ok || !canReceive(dst) {
+			dst = 0
+			var dstSpace int64
+			for _, g := range gids {
+				if !canReceive(g) {
+					continue
+				}
+				var space int64
+				for _, t := range groups[g].Tablets {
+					space += t.Space
+				}
+				if dst == 0 || space < dstSpace {
+					dst, dstSpace = g, space
+				}
+			}
+		}
+		if dst == 0 {
+			return nil
+		}
+		return &tabletMove{Predicate: tab.Predicate, From: gid, To: dst, Space: tab.Space}
+	}
+
+	pinned := make([]string, 0, len(p.Pins))
+	for pred := range p.Pins {
+		pinned = append(pinned, pred)
+	}
+	sort.Strings(pinned)
+	for _, pred := range pinned {
+		dst := p.Pins[pred]
+		if !canReceive(dst) {
+			continue
+		}
+		for _, gid := range gids {
+			if tab, ok := groups[gid].Tablets[pred]; ok && gid != dst {
+				return &tabletMove{Predicate: pred, From: gid, To: dst, Space: tab.Space}
+			}
+		}
+	}
+
+	if weight == nil {
+		return nil
+	}
+	balanced := make(map[uint32]*pb.Group)
+	for gid, group := range groups {
+		if !p.drained(gid) {
+			balanced[gid] = group
+		}
+	}
+	if len(balanced) <= 1 {
+		return nil
+	}
+	pred, src, dst := pickTablet(balanced, weight, p.movable, hasLeader)
+	if len(pred) == 0 {
+		return nil
+	}
+	return &tabletMove{Predicate: pred, From: src, To: dst,
+		Space: balanced[src].Tablets[pred].Space}
+}
+
+// pickTablet returns a tablet of the most loaded group that can be moved to the least loaded one
+// to balance them, and those groups. The load of a group is the sum of the weights of its
+// tablets.
+func pickTablet(groups map[uint32]*pb.Group, weight func(tab *pb.Tablet) float64,
+	movable func(pred string) bool, hasLeader func(gid uint32) bool) (
+	predicate string, srcGroup, dstGroup uint32) {
+	// Sort all groups by their loads.
+	type kv struct {
+		gid  uint32
+		load float64
+	}
+	var sorted []kv
+	for k, v := range groups {
+		load := 0.0
+		for _, tab := range v.Tablets {
+			load += weight(tab)
+		}
+		sorted = append(sorted, kv{k, load})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].load < sorted[j].load
+	})
+
+	glog.Infof("\n\nGroups sorted by load: %+v\n\n", sorted)
+	for lastGroup := len(sorted) - 1; lastGroup > 0; lastGroup-- {
+		srcGroup = sorted[lastGroup].gid
+		dstGroup = sorted[0].gid
+		diff := sorted[lastGroup].load - sorted[0].load
+		glog.Infof("load diff %v\n", diff)
+		// Don't move a node unless you receive atleast one update regarding tablet size.
+		// Tablet size would have come up with leader update.
+		if !hasLeader(dstGroup) {
+			return "", 0, 0
+		}
+		// We move the predicate only if the difference between the loads of both groups is
+		// atleast 10% of the least loaded group.
+		if diff < 0.1*sorted[0].load {
+			continue
+		}
+
+		// Try to find a predicate which we can move.
+		var load float64
+		for _, tab := range groups[srcGroup].Tablets {
+			if !movable(tab.Predicate) {
+				continue
+			}
+			// Finds a tablet as big a possible such that on moving it dstGroup's load is
+			// less than or equal to srcGroup.
+			if w := weight(tab); w <= diff/2 && w > load {
+				predicate = tab.Predicate
+				load = w
+			}
+		}
+		if len(predicate) > 0 {
+			return
+		}
+	}
+	return "", 0, 0
+}
+
+// qpsMeter turns the hits of the predicates, which only grow while the Alphas are up, into
+// queries per second between two samples.
+type qpsMeter struct {
+	last     map[string]uint64
+	lastTime time.Time
+}
+
+// rates returns the queries per second of each predicate since the last sample, nil for the
+// first one.
+func (m *qpsMeter) rates(hits map[string]uint64, now time.Time) map[string]float64 {
+	defer func() {
+		m.last, m.lastTime = hits, now
+	}()
+	if m.last == nil {
+		return nil
+	}
+	elapsed := now.Sub(m.lastTime).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+	rates := make(map[string]float64, len(hits))
+	for pred, n := range hits {
+		// The hits restart from zero when an Alpha restarts.
+		if prev := m.last[pred]; n >= prev {
+			n -= prev
+		}
+		rates[pred] = float64(n) / elapsed
+	}
+	return rates
+}
+
+// predicateHits returns the hits of the predicates, added up over every Alpha of the cluster.
+func (s *Server) predicateHits(ctx context.Context) (map[string]uint64, error) {
+	state := s.membershipState()
+	hits := make(map[string]uint64)
+	for _, group := range state.Groups {
+		for _, m := range group.Members {
+			pl, err := conn.Get().Get(m.Addr)
+			if err != nil {
+				return nil, x.Wrapf(err, "while getting the hits of %s", m.Addr)
+			}
+			res, err := pb.NewWorkerClient(pl.Get()).PredicateStats(ctx,
+				&pb.PredicateStatsRequest{HitsOnly: true})
+			if err != nil {
+				return nil, x.Wrapf(err, "while getting the hits of %s", m.Addr)
+			}
+			for _, ps := range res.Stats {
+				hits[ps.Predicate] += ps.Hits
+			}
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zero
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRebalancePolicy(t *testing.T) {
+	p, err := parseRebalancePolicy([]byte(`{"by": "qps", "window": "22:00-02:30",
+		"pins": {"name": 2}, "exclude": ["age"]}`))
+	require.NoError(t, err)
+	require.Equal(t, &RebalancePolicy{By: "qps", Window: "22:00-02:30",
+		Pins: map[string]uint32{"name": 2}, Exclude: []string{"age"}}, p)
+
+	for _, js := range []string{
+		`{"by": "count"}`,
+		`{"window": "22:00"}`,
+		`{"window": "24:00-02:00"}`,
+		`{"pins": {"name": 0}}`,
+		`{"pin": {"name": 1}}`,
+	} {
+		_, err := parseRebalancePolicy([]byte(js))
+		require.Error(t, err, js)
+	}
+}
+
+func TestRebalanceWindow(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2018, 10, 1, hour, min, 0, 0, time.UTC)
+	}
+	require.True(t, (&RebalancePolicy{}).inWindow(at(12, 0)))
+
+	p := &RebalancePolicy{Window: "01:00-05:00"}
+	require.True(t, p.inWindow(at(1, 0)))
+	require.True(t, p.inWindow(at(4, 59)))
+	require.False(t, p.inWindow(at(5, 0)))
+	require.False(t, p.inWindow(at(23, 0)))
+
+	p = &RebalancePolicy{Window: "22:00-02:30"}
+	require.True(t, p.inWindow(at(23, 0)))
+	require.True(t, p.inWindow(at(2, 0)))
+	require.False(t, p.inWindow(at(2, 30)))
+	require.False(t, p.inWindow(at(12, 0)))
+}
+
+func TestPickTablet(t *testing.T) {
+	groups := map[uint32]*pb.Group{
+		1: {Tablets: map[string]*pb.Tablet{
+			"a": {Predicate: "a", Space: 100},
+			"b": {Predicate: "b", Space: 40},
+			"c": {Predicate: "c", Space: 30},
+		}},
+		2: {Tablets: map[string]*pb.Tablet{
+			"d": {Predicate: "d", Space: 10},
+		}},
+	}
+	bySize := func(tab *pb.Tablet) float64 { return float64(tab.Space) }
+	hasLeader := func(gid uint32) bool { return true }
+
+	// The biggest tablet at most half of the difference is moved.
+	pred, src, dst := pickTablet(groups, bySize, (&RebalancePolicy{}).movable, hasLeader)
+	require.Equal(t, "b", pred)
+	require.Equal(t, uint32(1), src)
+	require.Equal(t, uint32(2), dst)
+
+	p := &RebalancePolicy{Pins: map[string]uint32{"b": 1}}
+	pred, _, _ = pickTablet(groups, bySize, p.movable, hasLeader)
+	require.Equal(t, "c", pred)
+
+	p.Exclude = []string{"c"}
+	pred, _, _ = pickTablet(groups, bySize, p.movable, hasLeader)
+	require.Equal(t, "", pred)
+
+	// By qps, the tablets of group 2 are the busiest.
+	loads := map[string]float64{"a": 1, "d": 50}
+	byQps := func(tab *pb.Tablet) float64 { return loads[tab.Predicate] }
+	pred, _, _ = pickTablet(groups, byQps, (&RebalancePolicy{}).movable, hasLeader)
+	require.Equal(t, "", pred)
+	loads["c"] = 5
+	loads["e"] = 20
+	groups[2].Tablets["e"] = &pb.Tablet{Predicate: "e"}
+	pred, src, dst = pickTablet(groups, byQps, (&RebalancePolicy{}).movable, hasLeader)
+	require.Equal(t, "e", pred)
+	require.Equal(t, uint32(2), src)
+	require.Equal(t, uint32(1), dst)
+}
+
+func TestQpsMeter(t *testing.T) {
+	var m qpsMeter
+	now := time.Now()
+	require.Nil(t, m.rates(map[string]uint64{"a": 10, "b": 100}, now))
+
+	rates := m.rates(map[string]uint64{"a": 30, "b": 5, "c": 10}, now.Add(10*time.Second))
+	// The hits of b went down after a restart.
+	require.Equal(t, map[string]float64{"a": 2, "b": 0.5, "c": 1}, rates)
+}
+
+func TestPlanMoves(t *testing.T) {
+	groups := map[uint32]*pb.Group{
+		1: {Tablets: map[string]*pb.Tablet{
+			"a": {Predicate: "a", Space: 10},
+		}},
+		2: {Tablets: map[string]*pb.Tablet{
+			"b": {Predicate: "b", Space: 50},
+			"c": {Predicate: "c", Space: 30},
+			"d": {Predicate: "d", Space: 20},
+		}},
+		3: {Tablets: map[string]*pb.Tablet{
+			"e": {Predicate: "e", Space: 40},
+		}},
+	}
+	bySize := func(tab *pb.Tablet) float64 { return float64(tab.Space) }
+	hasLeader := func(gid uint32) bool { return true }
+
+	// The tablets of group 2 go the biggest first, to the smallest group or to their pin.
+	p := &RebalancePolicy{By: rebalanceNone, Pins: map[string]uint32{"c": 3}, Drain: []uint32{2}}
+	moves := planMoves(groups, p, nil, hasLeader, 10)
+	require.Equal(t, []*tabletMove{
+		{Predicate: "b", From: 2, To: 1, Space: 50},
+		{Predicate: "c", From: 2, To: 3, Space: 30},
+		{Predicate: "d", From: 2, To: 1, Space: 20},
+	}, moves)
+	// The groups themselves aren't changed.
+	require.Len(t, groups[2].Tablets, 3)
+
+	// Then the pinned tablets, then the balance.
+	p = &RebalancePolicy{Pins: map[string]uint32{"a": 3}}
+	moves = planMoves(groups, p, bySize, hasLeader, 10)
+	require.Equal(t, []*tabletMove{
+		{Predicate: "a", From: 1, To: 3, Space: 10},
+		{Predicate: "b", From: 2, To: 1, Space: 50},
+	}, moves)
+	require.Len(t, planMoves(groups, p, bySize, hasLeader, 1), 1)
+}
@@ -27,8 +27,6 @@ import (
 	"syscall"
 	"time"
 
-	"go.opencensus.io/exporter/jaeger"
-	"go.opencensus.io/plugin/ocgrpc"
 	otrace "go.opencensus.io/trace"
 	"go.opencensus.io/zpages"
 	"golang.org/x/net/context"
@@ -37,6 +35,7 @@ import (
 
 	"github.com/dgraph-io/badger"
 	"github.com/dgraph-io/dgraph/conn"
+	"github.com/dgraph-io/dgraph/dgraph/cmd/tracing"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/raftwal"
 	"github.com/dgraph-io/dgraph/x"
@@ -53,6 +52,7 @@ type options struct {
 	peer              string
 	w                 string
 	rebalanceInterval time.Duration
+	restoreState      string
 }
 
 var opts options
@@ -87,10 +87,14 @@ instances to achieve high-availability.
 	flag.StringP("wal", "w", "zw", "Directory storing WAL.")
 	flag.Duration("rebalance_interval", 8*time.Minute, "Interval for trying a predicate move.")
 	flag.Bool("telemetry", true, "Send anonymous telemetry data to Dgraph devs.")
+	flag.String("restore_state", "",
+		"State file written by dgraph restore, applied when this Zero starts a new cluster.")
 
 	// OpenCensus flags.
 	flag.Float64("trace", 1.0, "The ratio of queries to trace.")
-	flag.String("jaeger.collector", "", "Send opencensus traces to Jaeger.")
+	tracing.RegisterFlags(flag)
+
+	initAdminCommands()
 }
 
 func setupListener(addr string, port int, kind string) (listener net.Listener, err error) {
@@ -106,19 +110,6 @@ type state struct {
 }
 
 func (st *state) serveGRPC(l net.Listener, wg *sync.WaitGroup, store *raftwal.DiskStorage) {
-	if collector := Zero.Conf.GetString("jaeger.collector"); len(collector) > 0 {
-		// Port details: https://www.jaegertracing.io/docs/getting-started/
-		// Default collectorEndpointURI := "http://localhost:14268"
-		je, err := jaeger.NewExporter(jaeger.Options{
-			Endpoint:    collector,
-			ServiceName: "dgraph.zero",
-		})
-		if err != nil {
-			log.Fatalf("Failed to create the Jaeger exporter: %v", err)
-		}
-		// And now finally register it as a Trace Exporter
-		otrace.RegisterExporter(je)
-	}
 	// Exclusively for stats, metrics, etc. Not for tracing.
 	// var views = append(ocgrpc.DefaultServerViews, ocgrpc.DefaultClientViews...)
 	// if err := view.Register(views...); err != nil {
@@ -129,7 +120,7 @@ func (st *state) serveGRPC(l net.Listener, wg *sync.WaitGroup, store *raftwal.Di
 		grpc.MaxRecvMsgSize(x.GrpcMaxSize),
 		grpc.MaxSendMsgSize(x.GrpcMaxSize),
 		grpc.MaxConcurrentStreams(1000),
-		grpc.StatsHandler(&ocgrpc.ServerHandler{}))
+		grpc.StatsHandler(tracing.ServerHandler()))
 
 	rc := pb.RaftContext{Id: opts.nodeId, Addr: opts.myAddr, Group: 0}
 	m := conn.NewNode(&rc, store)
@@ -182,6 +173,7 @@ func run() {
 		peer:              Zero.Conf.GetString("peer"),
 		w:                 Zero.Conf.GetString("wal"),
 		rebalanceInterval: Zero.Conf.GetDuration("rebalance_interval"),
+		restoreState:      Zero.Conf.GetString("restore_state"),
 	}
 
 	if opts.numReplicas < 0 || opts.numReplicas%2 == 0 {
@@ -198,6 +190,7 @@ func run() {
 	grpc.EnableTracing = false
 	otrace.ApplyConfig(otrace.Config{
 		DefaultSampler: otrace.ProbabilitySampler(Zero.Conf.GetFloat64("trace"))})
+	defer tracing.Start(Zero.Conf, "dgraph.zero")()
 
 	addr := "localhost"
 	if opts.bindall {
@@ -238,6 +231,14 @@ func run() {
 	http.HandleFunc("/state", st.getState)
 	http.HandleFunc("/removeNode", st.removeNode)
 	http.HandleFunc("/moveTablet", st.moveTablet)
+	http.HandleFunc("/drainGroup", st.drainGroup)
+	http.HandleFunc("/decommissionGroup", st.decommissionGroup)
+	http.HandleFunc("/operation", st.getOperation)
+	http.HandleFunc("/rebalance/policy", st.rebalancePolicy)
+	http.HandleFunc("/rebalance/pin", st.pinPredicate)
+	http.HandleFunc("/rebalance/exclude", st.excludePredicate)
+	http.HandleFunc("/rebalance/release", st.releasePredicate)
+	http.HandleFunc("/rebalance/now", st.rebalanceNow)
 	http.HandleFunc("/assign", st.assign)
 	zpages.Handle(http.DefaultServeMux, "/z")
 
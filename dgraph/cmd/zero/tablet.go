@@ -18,7 +18,6 @@ package zero
 
 import (
 	"fmt"
-	"sort"
 	"time"
 
 	"github.com/dgraph-io/dgraph/protos/pb"
@@ -59,14 +58,35 @@ This would trigger G1 to get latest state. Wait for it.
 //  TODO: Have a event log for everything.
 func (s *Server) rebalanceTablets() {
 	ticker := time.NewTicker(opts.rebalanceInterval)
+	var meter qpsMeter
 	for {
 		select {
 		case <-ticker.C:
-			predicate, srcGroup, dstGroup := s.chooseTablet()
-			if len(predicate) == 0 {
+			policy := s.rebalancePolicy()
+			var loads map[string]float64
+			if policy.By == rebalanceByQps && s.Node.AmLeader() {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				hits, err := s.predicateHits(ctx)
+				cancel()
+				if err != nil {
+					glog.Warningf("Not rebalancing by qps: %v", err)
+					// Don't compute the next rates over hits missing from some Alphas.
+					meter = qpsMeter{}
+					break
+				}
+				loads = meter.rates(hits, time.Now())
+			}
+			setLoads(loads)
+			// Leave the tablets alone during a move, drain or rebalance started by hand.
+			if !policy.inWindow(time.Now()) || !s.Node.AmLeader() || operationRunning() {
 				break
 			}
-			if err := s.movePredicate(predicate, srcGroup, dstGroup); err != nil {
+			moves := s.planMoves(policy, loads, 1)
+			if len(moves) == 0 {
+				break
+			}
+			m := moves[0]
+			if err := s.movePredicate(m.Predicate, m.From, m.To); err != nil {
 				glog.Errorln(err)
 			}
 		}
@@ -158,69 +178,6 @@ func (s *Server) runRecovery() {
 	}
 }
 
-func (s *Server) chooseTablet() (predicate string, srcGroup uint32, dstGroup uint32) {
-	s.RLock()
-	defer s.RUnlock()
-	if s.state == nil {
-		return
-	}
-	numGroups := len(s.state.Groups)
-	if !s.Node.AmLeader() || numGroups <= 1 {
-		return
-	}
-
-	// Sort all groups by their sizes.
-	type kv struct {
-		gid  uint32
-		size int64 // in bytes
-	}
-	var groups []kv
-	for k, v := range s.state.Groups {
-		space := int64(0)
-		for _, tab := range v.Tablets {
-			space += tab.Space
-		}
-		groups = append(groups, kv{k, space})
-	}
-	sort.Slice(groups, func(i, j int) bool {
-		return groups[i].size < groups[j].size
-	})
-
-	glog.Infof("\n\nGroups sorted by size: %+v\n\n", groups)
-	for lastGroup := numGroups - 1; lastGroup > 0; lastGroup-- {
-		srcGroup = groups[lastGroup].gid
-		dstGroup = groups[0].gid
-		size_diff := groups[lastGroup].size - groups[0].size
-		glog.Infof("size_diff %v\n", size_diff)
-		// Don't move a node unless you receive atleast one update regarding tablet size.
-		// Tablet size would have come up with leader update.
-		if !s.hasLeader(dstGroup) {
-			return
-		}
-		// We move the predicate only if the difference between size of both machines is
-		// atleast 10% of src group.
-		if float64(size_diff) < 0.1*float64(groups[0].size) {
-			continue
-		}
-
-		// Try to find a predicate which we can move.
-		size := int64(0)
-		group := s.state.Groups[srcGroup]
-		for _, tab := range group.Tablets {
-			// Finds a tablet as big a possible such that on moving it dstGroup's size is
-			// less than or equal to srcGroup.
-			if tab.Space <= size_diff/2 && tab.Space > size {
-				predicate = tab.Predicate
-				size = tab.Space
-			}
-		}
-		if len(predicate) > 0 {
-			return
-		}
-	}
-	return
-}
-
 func (s *Server) moveTablet(ctx context.Context, predicate string, srcGroup uint32,
 	dstGroup uint32) error {
 	err := s.movePredicateHelper(ctx, predicate, srcGroup, dstGroup)
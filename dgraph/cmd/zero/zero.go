@@ -194,6 +194,17 @@ func (s *Server) hasLeader(gid uint32) bool {
 	return false
 }
 
+// numVoters returns the number of members of group that aren't learners.
+func numVoters(group *pb.Group) int {
+	var n int
+	for _, m := range group.Members {
+		if !m.Learner {
+			n++
+		}
+	}
+	return n
+}
+
 func (s *Server) SetMembershipState(state *pb.MembershipState) {
 	s.Lock()
 	defer s.Unlock()
@@ -205,15 +216,19 @@ func (s *Server) SetMembershipState(state *pb.MembershipState) {
 		state.Groups = make(map[uint32]*pb.Group)
 	}
 	// Create connections to all members.
-	for _, g := range state.Groups {
+	s.nextGroup = 1
+	for gid, g := range state.Groups {
 		for _, m := range g.Members {
 			conn.Get().Connect(m.Addr)
 		}
 		if g.Tablets == nil {
 			g.Tablets = make(map[string]*pb.Tablet)
 		}
+		// The group ids have gaps once a group is decommissioned.
+		if gid >= s.nextGroup {
+			s.nextGroup = gid + 1
+		}
 	}
-	s.nextGroup = uint32(len(state.Groups) + 1)
 }
 
 func (s *Server) MarshalMembershipState() ([]byte, error) {
@@ -425,6 +440,14 @@ func (s *Server) Connect(ctx context.Context,
 		}
 	}
 
+	if m.Learner {
+		gid, err := s.learnerGroup(m.Id, m.GroupId)
+		if err != nil {
+			return &emptyConnectionState, err
+		}
+		m.GroupId = gid
+	}
+
 	// Create a connection and check validity of the address by doing an Echo.
 	conn.Get().Connect(m.Addr)
 
@@ -444,6 +467,12 @@ func (s *Server) Connect(ctx context.Context,
 			proposal.MaxRaftId = m.Id
 		}
 
+		if m.Learner {
+			// Learners don't count towards the replicas of their group.
+			proposal.Member = m
+			return proposal
+		}
+
 		// We don't have this member. So, let's see if it has preference for a group.
 		if m.GroupId > 0 {
 			group, has := s.state.Groups[m.GroupId]
@@ -459,21 +488,30 @@ func (s *Server) Connect(ctx context.Context,
 			}
 
 			// We don't have this server in the list.
-			if len(group.Members) < s.NumReplicas {
+			if numVoters(group) < s.NumReplicas {
 				// We need more servers here, so let's add it.
 				proposal.Member = m
 				return proposal
 			}
 			// Already have plenty of servers serving this group.
 		}
-		// Let's assign this server to a new group.
+		// Let's assign this server to a new group. Pick the lowest one, so the servers of
+		// restored groups can be started in order.
+		var next uint32
+		policy := s.readRebalancePolicy()
 		for gid, group := range s.state.Groups {
-			if len(group.Members) < s.NumReplicas {
-				m.GroupId = gid
-				proposal.Member = m
-				return proposal
+			if policy.drained(gid) {
+				continue
+			}
+			if numVoters(group) < s.NumReplicas && (next == 0 || gid < next) {
+				next = gid
 			}
 		}
+		if next > 0 {
+			m.GroupId = next
+			proposal.Member = m
+			return proposal
+		}
 		// We either don't have any groups, or don't have any groups which need another member.
 		m.GroupId = s.nextGroup
 		// We shouldn't increase nextGroup here as we don't know whether we have enough
@@ -495,6 +533,40 @@ func (s *Server) Connect(ctx context.Context,
 	return resp, nil
 }
 
+// learnerGroup returns the group the learner id joins: the one it's a member of already, or want
+// if set, or else the group with the fewest learners. It must have voters to learn from.
+func (s *Server) learnerGroup(id uint64, want uint32) (uint32, error) {
+	s.RLock()
+	defer s.RUnlock()
+	for gid, group := range s.state.Groups {
+		if _, has := group.Members[id]; has {
+			return gid, nil
+		}
+	}
+	if want > 0 {
+		if group, has := s.state.Groups[want]; !has || numVoters(group) == 0 {
+			return 0, x.Errorf("Group %d has no voters for a learner to join", want)
+		}
+		return want, nil
+	}
+	var gid uint32
+	var learners int
+	for g, group := range s.state.Groups {
+		voters := numVoters(group)
+		if voters == 0 {
+			continue
+		}
+		n := len(group.Members) - voters
+		if gid == 0 || n < learners || (n == learners && g < gid) {
+			gid, learners = g, n
+		}
+	}
+	if gid == 0 {
+		return 0, x.Errorf("No group has voters for a learner to join")
+	}
+	return gid, nil
+}
+
 func (s *Server) ShouldServe(
 	ctx context.Context, tablet *pb.Tablet) (resp *pb.Tablet, err error) {
 	ctx, span := otrace.StartSpan(ctx, "Zero.ShouldServe")
@@ -517,7 +589,12 @@ func (s *Server) ShouldServe(
 		return tab, nil
 	}
 
-	// Set the tablet to be served by this server's group.
+	// Set the tablet to be served by this server's group, unless it's pinned to another one or
+	// this group is being drained.
+	if gid := s.assignedGroup(tablet.Predicate, tablet.GroupId); gid != tablet.GroupId {
+		span.Annotatef(nil, "Tablet for %s is assigned to group %d", tablet.Predicate, gid)
+		tablet.GroupId = gid
+	}
 	var proposal pb.ZeroProposal
 	// Multiple Groups might be assigned to same tablet, so during proposal we will check again.
 	tablet.Force = false
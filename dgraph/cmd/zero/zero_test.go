@@ -20,6 +20,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/stretchr/testify/require"
 )
@@ -37,3 +38,60 @@ func TestRemoveNode(t *testing.T) {
 	err = server.removeNode(nil, 1, 2)
 	require.Error(t, err)
 }
+
+func TestLearnerGroup(t *testing.T) {
+	server := &Server{
+		state: &pb.MembershipState{
+			Groups: map[uint32]*pb.Group{
+				1: {Members: map[uint64]*pb.Member{
+					1: {Id: 1},
+					4: {Id: 4, Learner: true},
+				}},
+				2: {Members: map[uint64]*pb.Member{
+					2: {Id: 2},
+				}},
+				3: {Members: map[uint64]*pb.Member{
+					5: {Id: 5, Learner: true},
+				}},
+			},
+		},
+	}
+	// A known member stays in its group.
+	gid, err := server.learnerGroup(4, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), gid)
+
+	// A new learner goes to the group with voters and the fewest learners.
+	gid, err = server.learnerGroup(6, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), gid)
+	gid, err = server.learnerGroup(6, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), gid)
+
+	// Group 3 has nobody to learn from.
+	_, err = server.learnerGroup(6, 3)
+	require.Error(t, err)
+	_, err = server.learnerGroup(6, 7)
+	require.Error(t, err)
+	require.Equal(t, 1, numVoters(server.state.Groups[1]))
+	require.Equal(t, 0, numVoters(server.state.Groups[3]))
+}
+
+func TestReadOnlyTxnConflict(t *testing.T) {
+	var o Oracle
+	o.Init()
+	o.markReadOnly(10)
+	require.True(t, o.hasConflict(&api.TxnContext{StartTs: 10}))
+	require.False(t, o.hasConflict(&api.TxnContext{StartTs: 11}))
+
+	o.purgeBelow(11)
+	require.Empty(t, o.readOnly)
+
+	// A new leader doesn't know the timestamps marked by the previous one, but aborts all the
+	// transactions started before it.
+	var leader Oracle
+	leader.Init()
+	leader.updateStartTxnTs(12)
+	require.True(t, leader.hasConflict(&api.TxnContext{StartTs: 10}))
+}
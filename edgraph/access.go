@@ -22,6 +22,7 @@ import (
 	"context"
 
 	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/golang/glog"
 )
@@ -32,3 +33,27 @@ func (s *Server) Login(ctx context.Context,
 	glog.Warningf("Login failed: %s", x.ErrNotSupported)
 	return &api.Response{}, x.ErrNotSupported
 }
+
+func (s *Server) authorizeQuery(ctx context.Context, preds predicates) error {
+	return nil
+}
+
+func (s *Server) authorizeMutation(ctx context.Context, preds predicates) error {
+	return nil
+}
+
+func (s *Server) authorizeAlter(ctx context.Context, preds predicates) error {
+	return nil
+}
+
+func AuthorizeSubscription(ctx context.Context, req *pb.SubscribeRequest) error {
+	return nil
+}
+
+func auditUser(ctx context.Context) string {
+	return ""
+}
+
+func (s *Server) isGuardian(ctx context.Context) bool {
+	return false
+}
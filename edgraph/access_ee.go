@@ -17,22 +17,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/dgraph-io/dgraph/ee/acl"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/golang/glog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 
 	otrace "go.opencensus.io/trace"
 )
 
 func (s *Server) Login(ctx context.Context,
-	request *api.LoginRequest) (*api.Response, error) {
+	request *api.LoginRequest) (resp *api.Response, err error) {
 	ctx, span := otrace.StartSpan(ctx, "server.Login")
 	defer span.End()
 
+	ev := NewAuditEvent("login")
+	ev.User = request.GetUserid()
+	defer func() { ev.Done(ctx, err) }()
+
 	// record the client ip for this login request
 	var addr string
 	if ip, ok := peer.FromContext(ctx); ok {
@@ -50,7 +61,7 @@ func (s *Server) Login(ctx context.Context,
 		return nil, fmt.Errorf(errMsg)
 	}
 
-	resp := &api.Response{}
+	resp = &api.Response{}
 	accessJwt, err := getAccessJwt(request.Userid, user.Groups)
 	if err != nil {
 		errMsg := fmt.Sprintf("unable to get access jwt (userid=%s,addr=%s):%v",
@@ -124,7 +135,22 @@ func (s *Server) authenticate(ctx context.Context, request *api.LoginRequest) (*
 }
 
 func authenticateRefreshToken(refreshToken string) (string, error) {
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
+	claims, err := parseJwt(refreshToken, "refresh")
+	if err != nil {
+		return "", err
+	}
+
+	userId, ok := claims["userid"].(string)
+	if !ok {
+		return "", fmt.Errorf("userid in claims is not a string:%v", userId)
+	}
+	return userId, nil
+}
+
+// parseJwt returns the claims of a jwt signed by this cluster, kind being the kind of jwt for
+// errors.
+func parseJwt(jwtStr string, kind string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(jwtStr, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
@@ -132,26 +158,21 @@ func authenticateRefreshToken(refreshToken string) (string, error) {
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("unable to parse refresh token:%v", err)
+		return nil, fmt.Errorf("unable to parse %s token:%v", kind, err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return "", fmt.Errorf("claims in refresh token is not map claims:%v", refreshToken)
+		return nil, fmt.Errorf("claims in %s token is not map claims:%v", kind, jwtStr)
 	}
 
 	// by default, the MapClaims.Valid will return true if the exp field is not set
-	// here we enforce the checking to make sure that the refresh token has not expired
+	// here we enforce the checking to make sure that the token has not expired
 	now := time.Now().Unix()
 	if !claims.VerifyExpiresAt(now, true) {
-		return "", fmt.Errorf("refresh token has expired: %v", refreshToken)
-	}
-
-	userId, ok := claims["userid"].(string)
-	if !ok {
-		return "", fmt.Errorf("userid in claims is not a string:%v", userId)
+		return nil, fmt.Errorf("%s token has expired: %v", kind, jwtStr)
 	}
-	return userId, nil
+	return claims, nil
 }
 
 func validateLoginRequest(request *api.LoginRequest) error {
@@ -227,7 +248,7 @@ func (s *Server) queryUser(ctx context.Context, userid string, password string)
 		Vars:  queryVars,
 	}
 
-	queryResp, err := s.Query(ctx, &queryRequest)
+	queryResp, err := s.Query(withoutAcl(ctx), &queryRequest)
 	if err != nil {
 		glog.Errorf("Error while query user with id %s: %v", userid, err)
 		return nil, err
@@ -238,3 +259,317 @@ func (s *Server) queryUser(ctx context.Context, userid string, password string)
 	}
 	return user, nil
 }
+
+// aclRefreshInterval is how often the ACLs of the groups are reloaded.
+const aclRefreshInterval = 30 * time.Second
+
+// internalKey marks the contexts of the queries run by the server itself, like those that
+// authenticate users or load the ACLs, which are not subject to the ACLs.
+type internalKey struct{}
+
+func withoutAcl(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalKey{}, true)
+}
+
+func isInternal(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalKey{}).(bool)
+	return internal
+}
+
+const queryAcls = `
+    {
+      groups(func: has(dgraph.group.acl)) {
+        dgraph.xid
+        dgraph.group.acl
+      }
+      guardians(func: eq(dgraph.xid, "` + acl.GuardiansId + `")) {
+        count(~dgraph.user.group)
+      }
+    }`
+
+// aclCache holds the permissions of each group on the predicates, as last loaded.
+type aclCache struct {
+	sync.RWMutex
+	perms     map[string]map[string]int32 // group -> predicate -> perm
+	guarded   bool                        // Whether the guardians group has a member.
+	loaded    time.Time
+	loadMutex sync.Mutex // Only one load at a time.
+	// userGroups has the groups of the users that client certificates authenticate as, as
+	// looked up since the last load.
+	userGroups map[string][]string
+}
+
+var acls aclCache
+
+// load reloads the ACLs if they are older than aclRefreshInterval.
+func (c *aclCache) load(ctx context.Context, s *Server) error {
+	c.loadMutex.Lock()
+	defer c.loadMutex.Unlock()
+	c.RLock()
+	fresh := time.Since(c.loaded) < aclRefreshInterval
+	c.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	resp, err := s.Query(withoutAcl(ctx), &api.Request{Query: queryAcls})
+	if err != nil {
+		return x.Wrapf(err, "while loading the ACLs")
+	}
+	var result struct {
+		Groups    []acl.Group `json:"groups"`
+		Guardians []struct {
+			Count int `json:"count(~dgraph.user.group)"`
+		} `json:"guardians"`
+	}
+	if err := json.Unmarshal(resp.GetJson(), &result); err != nil {
+		return x.Wrapf(err, "while loading the ACLs")
+	}
+
+	perms := make(map[string]map[string]int32, len(result.Groups))
+	for _, g := range result.Groups {
+		var groupAcls []acl.Acl
+		if err := json.Unmarshal([]byte(g.Acls), &groupAcls); err != nil {
+			glog.Errorf("Unable to unmarshal the ACLs of group %s: %v", g.GroupID, err)
+			continue
+		}
+		predPerms := make(map[string]int32, len(groupAcls))
+		for _, a := range groupAcls {
+			predPerms[a.Predicate] = a.Perm
+		}
+		perms[g.GroupID] = predPerms
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.perms = perms
+	c.guarded = len(result.Guardians) > 0 && result.Guardians[0].Count > 0
+	c.loaded = time.Now()
+	c.userGroups = make(map[string][]string)
+	return nil
+}
+
+// groupsOf returns the groups of the user userId.
+func (c *aclCache) groupsOf(ctx context.Context, s *Server, userId string) ([]string, error) {
+	c.RLock()
+	groups, ok := c.userGroups[userId]
+	c.RUnlock()
+	if ok {
+		return groups, nil
+	}
+
+	user, err := s.queryUser(ctx, userId, "")
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found for id %v", userId)
+	}
+	groups = acl.GetGroupIDs(user.Groups)
+	c.Lock()
+	// The ACLs may not have been loaded yet, like when a query policy checks for guardians.
+	if c.userGroups == nil {
+		c.userGroups = make(map[string][]string)
+	}
+	c.userGroups[userId] = groups
+	c.Unlock()
+	return groups, nil
+}
+
+// denied returns the predicates that none of the groups has the permission perm on.
+func (c *aclCache) denied(groups []string, preds predicates, perm int32) []string {
+	c.RLock()
+	defer c.RUnlock()
+	var denied []string
+	for _, pred := range preds.sorted() {
+		if acl.IsAclPredicate(pred) {
+			denied = append(denied, pred)
+			continue
+		}
+		ok := false
+		for _, g := range groups {
+			if c.perms[g][pred]&perm != 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			denied = append(denied, pred)
+		}
+	}
+	return denied
+}
+
+// identity returns the user and groups that the request authenticates as, with the access jwt
+// sent in the accessjwt metadata or else with the client certificate of its TLS connection.
+func (s *Server) identity(ctx context.Context) (string, []string, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md["accessjwt"]) > 0 {
+		return accessJwtClaims(md["accessjwt"][0])
+	}
+	name, id, ok := certIdentity(ctx)
+	if !ok {
+		return "", nil, fmt.Errorf("no access jwt or client certificate in the request")
+	}
+	if id.Group != "" {
+		return "certificate " + name, []string{id.Group}, nil
+	}
+	groups, err := acls.groupsOf(ctx, s, id.User)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to authenticate certificate %s as user %s: %v",
+			name, id.User, err)
+	}
+	return id.User, groups, nil
+}
+
+// certIdentity returns the first name of the client certificate of the request that is mapped
+// by Config.CertAcl, and who it authenticates as. The certificate has been verified during the
+// handshake, as required by --tls_cert_acl.
+func certIdentity(ctx context.Context) (string, CertIdentity, bool) {
+	if len(Config.CertAcl) == 0 {
+		return "", CertIdentity{}, false
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", CertIdentity{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return "", CertIdentity{}, false
+	}
+	for _, name := range x.CertNames(info.State.PeerCertificates[0]) {
+		if id, ok := Config.CertAcl[name]; ok {
+			return name, id, true
+		}
+	}
+	return "", CertIdentity{}, false
+}
+
+// accessJwtClaims returns the user and groups of an access jwt.
+func accessJwtClaims(accessJwt string) (string, []string, error) {
+	claims, err := parseJwt(accessJwt, "access")
+	if err != nil {
+		return "", nil, err
+	}
+	userId, ok := claims["userid"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("userid in claims is not a string:%v", claims["userid"])
+	}
+	var groups []string
+	if list, ok := claims["groups"].([]interface{}); ok {
+		for _, g := range list {
+			if group, ok := g.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return userId, groups, nil
+}
+
+// authorize checks that the user of the request is in a group with the permission perm on
+// all of the predicates. The ACLs are only enforced once the guardians group has a member, so
+// that a cluster can be set up before. The guardians have all the permissions, and are the
+// only ones that can access the predicates of the ACLs themselves, or all the predicates.
+func (s *Server) authorize(ctx context.Context, preds predicates, perm int32) error {
+	if len(Config.HmacSecret) == 0 || isInternal(ctx) {
+		return nil
+	}
+	if len(preds.names) == 0 && !preds.all {
+		return nil
+	}
+	if err := acls.load(ctx, s); err != nil {
+		return err
+	}
+	acls.RLock()
+	guarded := acls.guarded
+	acls.RUnlock()
+	if !guarded {
+		return nil
+	}
+
+	userId, groups, err := s.identity(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "%v", err)
+	}
+	for _, g := range groups {
+		if g == acl.GuardiansId {
+			return nil
+		}
+	}
+	if preds.all {
+		return status.Errorf(codes.PermissionDenied,
+			"Only the guardians can %s all the predicates", acl.PermName(perm))
+	}
+	if denied := acls.denied(groups, preds, perm); len(denied) > 0 {
+		return status.Errorf(codes.PermissionDenied,
+			"User %s doesn't have the %s permission on the predicates %v",
+			userId, acl.PermName(perm), denied)
+	}
+	return nil
+}
+
+func (s *Server) authorizeQuery(ctx context.Context, preds predicates) error {
+	return s.authorize(ctx, preds, acl.Read)
+}
+
+func (s *Server) authorizeMutation(ctx context.Context, preds predicates) error {
+	return s.authorize(ctx, preds, acl.Write)
+}
+
+func (s *Server) authorizeAlter(ctx context.Context, preds predicates) error {
+	return s.authorize(ctx, preds, acl.Modify)
+}
+
+// AuthorizeSubscription checks that the user of a subscription to the changes has the read
+// permission on its predicates. Subscribing by prefix, or to all the predicates, is only
+// allowed to the guardians.
+func AuthorizeSubscription(ctx context.Context, req *pb.SubscribeRequest) error {
+	var preds predicates
+	for _, pred := range req.Predicates {
+		preds.add(pred)
+	}
+	preds.all = len(req.Predicates) == 0 || len(req.PredicatePrefixes) > 0
+	return (&Server{}).authorize(ctx, preds, acl.Read)
+}
+
+// auditUser returns who made a request for the audit log: the user of its access jwt, or who
+// its client certificate authenticates as. It is empty if neither is valid.
+func auditUser(ctx context.Context) string {
+	if len(Config.HmacSecret) == 0 {
+		return ""
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md["accessjwt"]) > 0 {
+		userId, _, err := accessJwtClaims(md["accessjwt"][0])
+		if err != nil {
+			return ""
+		}
+		return userId
+	}
+	if name, id, ok := certIdentity(ctx); ok {
+		if id.User != "" {
+			return id.User
+		}
+		return "certificate " + name
+	}
+	return ""
+}
+
+// isGuardian returns whether the request is made by a member of the guardians group, or by the
+// server itself.
+func (s *Server) isGuardian(ctx context.Context) bool {
+	if len(Config.HmacSecret) == 0 {
+		return false
+	}
+	if isInternal(ctx) {
+		return true
+	}
+	_, groups, err := s.identity(ctx)
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		if g == acl.GuardiansId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+	"google.golang.org/grpc/peer"
+)
+
+// AuditOptions configure the audit log.
+type AuditOptions struct {
+	// Output is the file the audit events are written to, or "syslog".
+	Output string
+	// MaxSizeMB is the size a file grows to before it is rotated, zero to never rotate it.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+	// Bodies includes the text of the queries, mutations and schema updates in the events.
+	Bodies bool
+}
+
+// audit is where the audit events are written, with a nil writer if auditing is off. The
+// requests check on and bodies atomically, so they only take the lock to write an event.
+var audit struct {
+	sync.Mutex
+	w      io.WriteCloser
+	on     int32 // 1 if w is set
+	bodies int32 // 1 if the events include the text of the requests
+}
+
+// StartAudit starts writing the audit events of the requests to the output of opts.
+func StartAudit(opts AuditOptions) error {
+	var w io.WriteCloser
+	var err error
+	if opts.Output == "syslog" {
+		w, err = newSyslogWriter()
+	} else {
+		w, err = newRotatingFile(opts.Output, int64(opts.MaxSizeMB)<<20, opts.MaxBackups)
+	}
+	if err != nil {
+		return x.Wrapf(err, "while opening the audit log %q", opts.Output)
+	}
+	audit.Lock()
+	defer audit.Unlock()
+	audit.w = w
+	var bodies int32
+	if opts.Bodies {
+		bodies = 1
+	}
+	atomic.StoreInt32(&audit.bodies, bodies)
+	atomic.StoreInt32(&audit.on, 1)
+	glog.Infof("Writing the audit log to %s", opts.Output)
+	return nil
+}
+
+// StopAudit stops writing audit events and closes the audit log.
+func StopAudit() {
+	audit.Lock()
+	defer audit.Unlock()
+	if audit.w == nil {
+		return
+	}
+	atomic.StoreInt32(&audit.on, 0)
+	if err := audit.w.Close(); err != nil {
+		glog.Errorf("Error while closing the audit log: %v", err)
+	}
+	audit.w = nil
+}
+
+// AuditEvent is the record of a request in the audit log, written as a line of JSON.
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	Operation string    `json:"operation"`
+	// Predicates are the predicates read, written or altered by the request, and
+	// AllPredicates is set if it can touch any of them, like expand(_all_).
+	Predicates    []string `json:"predicates,omitempty"`
+	AllPredicates bool     `json:"all_predicates,omitempty"`
+	StartTs       uint64   `json:"start_ts,omitempty"`
+	CommitTs      uint64   `json:"commit_ts,omitempty"`
+	LatencyMs     float64  `json:"latency_ms"`
+	Status        string   `json:"status"`
+	Error         string   `json:"error,omitempty"`
+	Body          string   `json:"body,omitempty"`
+}
+
+// NewAuditEvent returns the event of an operation starting now.
+func NewAuditEvent(op string) *AuditEvent {
+	return &AuditEvent{Time: time.Now(), Operation: op}
+}
+
+func (ev *AuditEvent) setPredicates(preds predicates) {
+	ev.Predicates = append(ev.Predicates, preds.sorted()...)
+	ev.AllPredicates = ev.AllPredicates || preds.all
+}
+
+// SetBody sets the text of the request, if the audit log includes them.
+func (ev *AuditEvent) SetBody(body string) {
+	if atomic.LoadInt32(&audit.bodies) == 1 {
+		ev.Body = body
+	}
+}
+
+// Done writes the event of an operation that ended with err, if auditing is on. Who made the
+// operation is found in ctx, unless already set.
+func (ev *AuditEvent) Done(ctx context.Context, err error) {
+	if atomic.LoadInt32(&audit.on) == 0 {
+		return
+	}
+	ev.LatencyMs = float64(time.Since(ev.Time)) / float64(time.Millisecond)
+	if ev.User == "" {
+		ev.User = auditUser(ctx)
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ev.Client = p.Addr.String()
+	}
+	ev.Status = "ok"
+	if err != nil {
+		ev.Status = "error"
+		ev.Error = err.Error()
+	}
+	js, err := json.Marshal(ev)
+	if err != nil {
+		glog.Errorf("Unable to marshal audit event: %v", err)
+		return
+	}
+
+	audit.Lock()
+	defer audit.Unlock()
+	if audit.w == nil {
+		return
+	}
+	if _, err := audit.w.Write(append(js, '\n')); err != nil {
+		glog.Errorf("Unable to write audit event: %v", err)
+	}
+}
+
+// mutationBody returns the text of a mutation for the audit log.
+func mutationBody(mu *api.Mutation) string {
+	var parts []string
+	for _, part := range []struct {
+		name string
+		val  []byte
+	}{
+		{"set", mu.SetNquads},
+		{"delete", mu.DelNquads},
+		{"set_json", mu.SetJson},
+		{"delete_json", mu.DeleteJson},
+	} {
+		if len(part.val) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", part.name, part.val))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// rotatingFile is a file that is renamed to path.1, shifting the older ones, once it grows
+// past maxSize. Only maxBackups of the rotated files are kept.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", r.path, i) }
+	if err := os.Remove(backup(r.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if r.maxBackups > 0 {
+		if err := os.Rename(r.path, backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
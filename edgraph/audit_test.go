@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAuditEvents(t *testing.T, path string) []AuditEvent {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var events []AuditEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var ev AuditEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &ev))
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestAuditEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// Nothing is written while auditing is off.
+	NewAuditEvent("query").Done(context.Background(), nil)
+
+	require.NoError(t, StartAudit(AuditOptions{Output: path}))
+	ev := NewAuditEvent("mutate")
+	ev.StartTs = 10
+	ev.setPredicates(predicates{names: map[string]struct{}{"name": {}, "age": {}}})
+	ev.SetBody("set: _:a <name> \"Alice\" .")
+	ev.Done(context.Background(), errors.New("Transaction has been aborted"))
+	StopAudit()
+
+	events := readAuditEvents(t, path)
+	require.Len(t, events, 1)
+	ev = &events[0]
+	require.Equal(t, "mutate", ev.Operation)
+	require.Equal(t, []string{"age", "name"}, ev.Predicates)
+	require.Equal(t, uint64(10), ev.StartTs)
+	require.Equal(t, "error", ev.Status)
+	require.Equal(t, "Transaction has been aborted", ev.Error)
+	// Bodies are only included if asked for.
+	require.Empty(t, ev.Body)
+
+	require.NoError(t, StartAudit(AuditOptions{Output: path, Bodies: true}))
+	ev = NewAuditEvent("query")
+	ev.SetBody("{ q(func: has(name)) { name } }")
+	ev.Done(context.Background(), nil)
+	StopAudit()
+
+	events = readAuditEvents(t, path)
+	require.Len(t, events, 2)
+	require.Equal(t, "ok", events[1].Status)
+	require.Equal(t, "{ q(func: has(name)) { name } }", events[1].Body)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	r, err := newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	read := func(path string) string {
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+	require.Equal(t, "dddddddd\n", read(path))
+	require.Equal(t, "cccccccc\n", read(path+".1"))
+	require.Equal(t, "bbbbbbbb\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/query"
 	"github.com/dgraph-io/dgraph/worker"
 	"github.com/dgraph-io/dgraph/x"
 )
@@ -39,6 +40,16 @@ type Options struct {
 	HmacSecret    []byte
 	AccessJwtTtl  time.Duration
 	RefreshJwtTtl time.Duration
+	// CertAcl maps the names of verified client certificates to who they authenticate as.
+	CertAcl map[string]CertIdentity
+	// QueryLimits are the limits of every query, which requests can only lower.
+	QueryLimits query.Limits
+}
+
+// CertIdentity is the ACL user, or the group, that a client certificate authenticates as.
+type CertIdentity struct {
+	User  string
+	Group string
 }
 
 var Config Options
@@ -111,4 +122,6 @@ func (o *Options) validate() {
 	x.AssertTruefNoTrace(o.AllottedMemory >= MinAllottedMemory,
 		"LRU memory (--lru_mb) must be at least %.0f MB. Currently set to: %f",
 		MinAllottedMemory, o.AllottedMemory)
+	x.AssertTruefNoTrace(o.QueryLimits.Timeout >= 0 && o.QueryLimits.MaxDepth >= 0,
+		"Query limits (--query_timeout, --query_max_depth) can't be negative.")
 }
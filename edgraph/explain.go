@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dgraph-io/dgraph/query"
+	"github.com/golang/glog"
+	"google.golang.org/grpc/metadata"
+)
+
+type explainKey struct{}
+
+// WithExplainPlan returns a context in which Query sets *plan to the plan of the query, if it
+// was asked for.
+func WithExplainPlan(ctx context.Context, plan *[]*query.ExplainStep) context.Context {
+	return context.WithValue(ctx, explainKey{}, plan)
+}
+
+// reportExplain gives the plan of a query to the client: by the pointer of WithExplainPlan over
+// HTTP, or as JSON in the "dgraph-explain" trailer over gRPC.
+func reportExplain(ctx context.Context, plan []*query.ExplainStep, setTrailer func(metadata.MD)) {
+	if plan == nil {
+		return
+	}
+	if p, ok := ctx.Value(explainKey{}).(*[]*query.ExplainStep); ok {
+		*p = plan
+		return
+	}
+	js, err := json.Marshal(plan)
+	if err != nil {
+		glog.Errorf("Unable to marshal the plan of a query: %v", err)
+		return
+	}
+	setTrailer(metadata.Pairs("dgraph-explain", string(js)))
+}
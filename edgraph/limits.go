@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/dgraph/query"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The metadata of a request that lowers the limits of its query.
+const (
+	limitTimeoutKey  = "query-timeout"
+	limitMaxDepthKey = "query-max-depth"
+	limitMaxEdgesKey = "query-max-edges"
+	limitPartialKey  = "query-partial"
+)
+
+// queryLimits returns the limits of the query of a request: those of the server, lowered by
+// the ones asked for in the metadata of the request.
+func queryLimits(ctx context.Context) (query.Limits, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Config.QueryLimits, nil
+	}
+	get := func(key string) string {
+		if vals := md.Get(key); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+	invalid := func(key, val string) error {
+		return status.Errorf(codes.InvalidArgument, "Invalid %s: %q", key, val)
+	}
+
+	var asked query.Limits
+	if val := get(limitTimeoutKey); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			return asked, invalid(limitTimeoutKey, val)
+		}
+		asked.Timeout = d
+	}
+	if val := get(limitMaxDepthKey); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			return asked, invalid(limitMaxDepthKey, val)
+		}
+		asked.MaxDepth = n
+	}
+	if val := get(limitMaxEdgesKey); val != "" {
+		n, err := strconv.ParseUint(val, 10, 64)
+		if err != nil || n == 0 {
+			return asked, invalid(limitMaxEdgesKey, val)
+		}
+		asked.MaxEdges = n
+	}
+	if val := get(limitPartialKey); val != "" {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return asked, invalid(limitPartialKey, val)
+		}
+		asked.Partial = b
+	}
+	return Config.QueryLimits.Tighten(asked), nil
+}
+
+// withQueryLimits returns a context in which a query is bound by limits, and the function
+// that releases its resources once the query is done.
+func withQueryLimits(ctx context.Context,
+	limits query.Limits) (context.Context, context.CancelFunc) {
+	ctx = query.WithLimits(ctx, limits)
+	if limits.Timeout > 0 {
+		return context.WithTimeout(ctx, limits.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// limitsError returns err, or a clean error if the query was stopped by its timeout.
+func limitsError(ctx context.Context, limits query.Limits, err error) error {
+	if limits.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		return status.Errorf(codes.DeadlineExceeded, "Query exceeded the limit of %v",
+			limits.Timeout)
+	}
+	return err
+}
+
+type truncatedKey struct{}
+
+// WithTruncatedFlag returns a context in which Query sets *truncated if the limits of the query
+// cut its results short.
+func WithTruncatedFlag(ctx context.Context, truncated *bool) context.Context {
+	return context.WithValue(ctx, truncatedKey{}, truncated)
+}
+
+// reportTruncated tells the client if the limits of its query cut the results short: by the
+// flag of WithTruncatedFlag over HTTP, or by the "dgraph-truncated" trailer over gRPC.
+func reportTruncated(ctx context.Context, setTrailer func(metadata.MD)) {
+	if !query.Truncated(ctx) {
+		return
+	}
+	if truncated, ok := ctx.Value(truncatedKey{}).(*bool); ok {
+		*truncated = true
+	}
+	setTrailer(metadata.Pairs("dgraph-truncated", "true"))
+}
+
+// setUnaryTrailer sets the trailer of the response to a unary gRPC call. It's a no-op for
+// requests that didn't come over gRPC.
+func setUnaryTrailer(ctx context.Context) func(metadata.MD) {
+	return func(md metadata.MD) {
+		_ = grpc.SetTrailer(ctx, md)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
 	"github.com/dgraph-io/dgraph/query"
 	"github.com/dgraph-io/dgraph/types"
 	"github.com/dgraph-io/dgraph/types/facets"
@@ -235,6 +236,9 @@ func mapToNquads(m map[string]interface{}, idx *int, op int, parentPred string)
 				uid = 0
 			} else if ok := strings.HasPrefix(uidVal, "_:"); ok {
 				mr.uid = uidVal
+			} else if _, ok := gql.UidVar(uidVal); ok {
+				// The uids of a query variable, substituted in an upsert.
+				mr.uid = uidVal
 			} else if u, err := strconv.ParseUint(uidVal, 0, 64); err != nil {
 				return mr, err
 			} else {
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/x"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// QueryPolicy restricts the queries of the clients that are not admins, to protect a cluster
+// from expensive ad-hoc queries. The zero policy allows everything.
+type QueryPolicy struct {
+	// DenyFunctions are the functions that can't be used, at the root or in filters.
+	DenyFunctions []string `json:"deny_functions,omitempty"`
+	// DenyRootFunctions are the functions that can't be used at the root of a block.
+	DenyRootFunctions []string `json:"deny_root_functions,omitempty"`
+	// AllowRootFunctions, if not empty, are the only functions allowed at the root of a block.
+	AllowRootFunctions []string `json:"allow_root_functions,omitempty"`
+	// DenyPredicates are the predicates that can't be queried.
+	DenyPredicates []string `json:"deny_predicates,omitempty"`
+	// AllowPredicates, if not empty, are the only predicates that can be queried.
+	AllowPredicates []string `json:"allow_predicates,omitempty"`
+	// DenyExpand denies expand() and _predicate_, which can query any predicate.
+	DenyExpand bool `json:"deny_expand,omitempty"`
+	// DenyRecurse denies @recurse blocks.
+	DenyRecurse bool `json:"deny_recurse,omitempty"`
+	// DenyShortest denies shortest path blocks.
+	DenyShortest bool `json:"deny_shortest,omitempty"`
+	// MaxDepth is the maximum number of nested levels of a block, zero for no limit.
+	MaxDepth int `json:"max_depth,omitempty"`
+}
+
+var queryPolicy struct {
+	sync.RWMutex
+	p *QueryPolicy
+}
+
+// LoadQueryPolicy reads a query policy from a JSON file.
+func LoadQueryPolicy(file string) (*QueryPolicy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, x.Wrapf(err, "while reading the query policy %q", file)
+	}
+	p, err := ParseQueryPolicy(data)
+	if err != nil {
+		return nil, x.Wrapf(err, "while reading the query policy %q", file)
+	}
+	return p, nil
+}
+
+// ParseQueryPolicy parses a query policy in JSON.
+func ParseQueryPolicy(data []byte) (*QueryPolicy, error) {
+	p := &QueryPolicy{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, x.Errorf("Invalid query policy: %v", err)
+	}
+	if p.MaxDepth < 0 {
+		return nil, x.Errorf("Invalid query policy: max_depth can't be negative")
+	}
+	// Functions are case insensitive in queries.
+	for _, fns := range [][]string{p.DenyFunctions, p.DenyRootFunctions, p.AllowRootFunctions} {
+		for i := range fns {
+			fns[i] = strings.ToLower(fns[i])
+		}
+	}
+	return p, nil
+}
+
+// SetQueryPolicy replaces the query policy, nil to allow every query.
+func SetQueryPolicy(p *QueryPolicy) {
+	queryPolicy.Lock()
+	defer queryPolicy.Unlock()
+	queryPolicy.p = p
+}
+
+// GetQueryPolicy returns the query policy, nil if there is none.
+func GetQueryPolicy() *QueryPolicy {
+	queryPolicy.RLock()
+	defer queryPolicy.RUnlock()
+	return queryPolicy.p
+}
+
+// checkQueryPolicy checks a query against the query policy, unless it's made by an admin: a
+// guardian, or a client with the auth token of alter operations.
+func (s *Server) checkQueryPolicy(ctx context.Context, res *gql.Result) error {
+	p := GetQueryPolicy()
+	if p == nil {
+		return nil
+	}
+	if Config.AuthToken != "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if tokens := md.Get("auth-token"); len(tokens) > 0 && tokens[0] == Config.AuthToken {
+				return nil
+			}
+		}
+	}
+	if s.isGuardian(ctx) {
+		return nil
+	}
+	if err := p.check(res); err != nil {
+		return status.Errorf(codes.PermissionDenied, "Query denied by the query policy: %v", err)
+	}
+	return nil
+}
+
+// check returns why the query isn't allowed by p, if it isn't.
+func (p *QueryPolicy) check(res *gql.Result) error {
+	// Reading the whole schema isn't an expansion, so only the blocks are checked for those.
+	if p.DenyExpand && queryPredicates(&gql.Result{Query: res.Query}).all {
+		return x.Errorf("expand() and _predicate_ are not allowed")
+	}
+	preds := queryPredicates(res)
+	for _, pred := range preds.sorted() {
+		if contains(p.DenyPredicates, pred) ||
+			(len(p.AllowPredicates) > 0 && !contains(p.AllowPredicates, pred)) {
+			return x.Errorf("predicate %s is not allowed", pred)
+		}
+	}
+
+	for _, gq := range res.Query {
+		switch {
+		case p.DenyRecurse && gq.Recurse:
+			return x.Errorf("@recurse is not allowed")
+		case p.DenyShortest && gq.Alias == "shortest":
+			return x.Errorf("shortest path queries are not allowed")
+		case p.MaxDepth > 0 && gq.Depth() > p.MaxDepth:
+			return x.Errorf("blocks deeper than %d levels are not allowed", p.MaxDepth)
+		}
+		if gq.Func != nil {
+			fn := strings.ToLower(gq.Func.Name)
+			if contains(p.DenyRootFunctions, fn) ||
+				(len(p.AllowRootFunctions) > 0 && !contains(p.AllowRootFunctions, fn)) {
+				return x.Errorf("function %s is not allowed at the root", fn)
+			}
+		}
+		if err := p.checkFuncs(gq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *QueryPolicy) checkFuncs(gq *gql.GraphQuery) error {
+	if len(p.DenyFunctions) == 0 {
+		return nil
+	}
+	var walkFilter func(ft *gql.FilterTree) error
+	checkFunc := func(f *gql.Function) error {
+		if f != nil && contains(p.DenyFunctions, strings.ToLower(f.Name)) {
+			return x.Errorf("function %s is not allowed", strings.ToLower(f.Name))
+		}
+		return nil
+	}
+	walkFilter = func(ft *gql.FilterTree) error {
+		if ft == nil {
+			return nil
+		}
+		if err := checkFunc(ft.Func); err != nil {
+			return err
+		}
+		for _, child := range ft.Child {
+			if err := walkFilter(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := checkFunc(gq.Func); err != nil {
+		return err
+	}
+	if err := walkFilter(gq.Filter); err != nil {
+		return err
+	}
+	for _, child := range gq.Children {
+		if err := p.checkFuncs(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryPolicy(t *testing.T) {
+	p, err := ParseQueryPolicy([]byte(`{"deny_functions": ["RegExp"], "max_depth": 3}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"regexp"}, p.DenyFunctions)
+	require.Equal(t, 3, p.MaxDepth)
+
+	_, err = ParseQueryPolicy([]byte(`{"deny_function": ["regexp"]}`))
+	require.Error(t, err)
+	_, err = ParseQueryPolicy([]byte(`{"max_depth": -1}`))
+	require.Error(t, err)
+}
+
+func TestQueryPolicyCheck(t *testing.T) {
+	p, err := ParseQueryPolicy([]byte(`{
+		"deny_functions": ["regexp"],
+		"deny_root_functions": ["has"],
+		"deny_predicates": ["password"],
+		"deny_expand": true,
+		"deny_recurse": true,
+		"max_depth": 3
+	}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{query: `{ me(func: eq(name, "Alice")) { name friend { name } } }`},
+		{query: `{ me(func: eq(name, "Alice")) { friend { friend { name } } } }`,
+			err: "blocks deeper than 3 levels are not allowed"},
+		{query: `{ me(func: eq(name, "Alice")) @filter(regexp(name, /^Al/)) { name } }`,
+			err: "function regexp is not allowed"},
+		{query: `{ me(func: eq(name, "Alice")) { friend @filter(regexp(name, /^Al/)) { name } } }`,
+			err: "function regexp is not allowed"},
+		{query: `{ me(func: has(name)) { name } }`,
+			err: "function has is not allowed at the root"},
+		{query: `{ me(func: eq(name, "Alice")) { friend @filter(has(name)) { name } } }`},
+		{query: `{ me(func: eq(name, "Alice")) { password } }`,
+			err: "predicate password is not allowed"},
+		{query: `{ me(func: eq(name, "Alice")) { expand(_all_) } }`,
+			err: "expand() and _predicate_ are not allowed"},
+		{query: `{ me(func: eq(name, "Alice")) @recurse { friend } }`,
+			err: "@recurse is not allowed"},
+	}
+	for _, tc := range tests {
+		res, err := gql.Parse(gql.Request{Str: tc.query})
+		require.NoError(t, err, tc.query)
+		err = p.check(&res)
+		if tc.err == "" {
+			require.NoError(t, err, tc.query)
+			continue
+		}
+		require.Error(t, err, tc.query)
+		require.Contains(t, err.Error(), tc.err, tc.query)
+	}
+}
+
+func TestQueryPolicyAllowLists(t *testing.T) {
+	p, err := ParseQueryPolicy([]byte(`{
+		"allow_root_functions": ["eq", "uid"],
+		"allow_predicates": ["name", "friend"]
+	}`))
+	require.NoError(t, err)
+
+	check := func(query string) error {
+		res, err := gql.Parse(gql.Request{Str: query})
+		require.NoError(t, err, query)
+		return p.check(&res)
+	}
+	require.NoError(t, check(`{ me(func: uid(0x1)) { name friend { name } } }`))
+	require.Error(t, check(`{ me(func: anyofterms(name, "Alice")) { name } }`))
+	require.Error(t, check(`{ me(func: uid(0x1)) { name age } }`))
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// predicates are the predicates that a request reads, writes or modifies, to check them against
+// the ACLs.
+type predicates struct {
+	names map[string]struct{}
+	// all is set if the request can touch any predicate, like expand(_all_) or S * * deletions.
+	all bool
+}
+
+func (p *predicates) add(attr string) {
+	attr = strings.TrimPrefix(attr, "~")
+	if attr == "" {
+		return
+	}
+	if p.names == nil {
+		p.names = make(map[string]struct{})
+	}
+	p.names[attr] = struct{}{}
+}
+
+// sorted returns the names of the predicates in order.
+func (p *predicates) sorted() []string {
+	names := make([]string, 0, len(p.names))
+	for name := range p.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queryPredicates returns the predicates read by the blocks of a query, and those whose schema
+// it asks for. A schema query without predicates asks for the schema of all of them.
+func queryPredicates(res *gql.Result) predicates {
+	var p predicates
+	for _, gq := range res.Query {
+		p.addQuery(gq)
+	}
+	if res.Schema != nil {
+		if len(res.Schema.Predicates) == 0 {
+			p.all = true
+		}
+		for _, pred := range res.Schema.Predicates {
+			p.add(pred)
+		}
+	}
+	return p
+}
+
+func (p *predicates) addQuery(gq *gql.GraphQuery) {
+	if gq == nil {
+		return
+	}
+	if gq.Expand != "" || gq.Attr == "_predicate_" {
+		p.all = true
+	}
+	switch {
+	case gq.IsInternal, gq.Expand != "":
+	case gq.Attr == "uid", gq.Attr == "_predicate_", gq.Attr == "_cursor_":
+	default:
+		p.add(gq.Attr)
+	}
+	p.addFunc(gq.Func)
+	p.addFilter(gq.Filter)
+	p.addFilter(gq.Having)
+
+	vars := make(map[string]bool, len(gq.NeedsVar))
+	for _, v := range gq.NeedsVar {
+		vars[v.Name] = true
+	}
+	for _, o := range gq.Order {
+		// Orders by a value variable have its name as attribute.
+		if !vars[o.Attr] {
+			p.add(o.Attr)
+		}
+	}
+	for _, attr := range gq.GroupbyAttrs {
+		p.add(attr.Attr)
+	}
+	for _, level := range gq.RecurseArgs.Levels {
+		for _, attr := range level {
+			p.add(attr)
+		}
+	}
+	for _, child := range gq.Children {
+		p.addQuery(child)
+	}
+}
+
+func (p *predicates) addFilter(ft *gql.FilterTree) {
+	if ft == nil {
+		return
+	}
+	p.addFunc(ft.Func)
+	for _, child := range ft.Child {
+		p.addFilter(child)
+	}
+}
+
+func (p *predicates) addFunc(f *gql.Function) {
+	if f == nil || f.IsValueVar || f.Name == "uid" {
+		return
+	}
+	p.add(f.Attr)
+}
+
+// mutationPredicates returns the predicates written by a mutation.
+func mutationPredicates(gmu *gql.Mutation) predicates {
+	var p predicates
+	for _, nq := range gmu.Set {
+		p.add(nq.Predicate)
+	}
+	for _, nq := range gmu.Del {
+		if nq.Predicate == x.Star {
+			p.all = true
+			continue
+		}
+		p.add(nq.Predicate)
+	}
+	return p
+}
+
+// schemaPredicates returns the predicates modified by the updates of a schema.
+func schemaPredicates(updates []*pb.SchemaUpdate) predicates {
+	var p predicates
+	for _, su := range updates {
+		p.add(su.Predicate)
+	}
+	return p
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func parsedQueryPredicates(t *testing.T, q string) predicates {
+	res, err := gql.Parse(gql.Request{Str: q})
+	require.NoError(t, err)
+	return queryPredicates(&res)
+}
+
+func TestQueryPredicates(t *testing.T) {
+	preds := parsedQueryPredicates(t, `
+	{
+		me(func: anyofterms(name, "Alice"), orderasc: age) @filter(has(alias) or uid(0x1)) {
+			uid
+			name
+			~friend {
+				count(uid)
+			}
+			school @filter(eq(city, "SF")) {
+				name@en
+			}
+		}
+	}`)
+	require.False(t, preds.all)
+	require.Equal(t, []string{"age", "alias", "city", "friend", "name", "school"},
+		preds.sorted())
+}
+
+func TestQueryPredicatesValueVar(t *testing.T) {
+	preds := parsedQueryPredicates(t, `
+	{
+		var(func: has(age)) {
+			a as age
+		}
+		me(func: uid(a), orderdesc: val(a)) {
+			name
+			val(a)
+		}
+	}`)
+	require.False(t, preds.all)
+	require.Equal(t, []string{"age", "name"}, preds.sorted())
+}
+
+func TestQueryPredicatesExpand(t *testing.T) {
+	preds := parsedQueryPredicates(t, `
+	{
+		me(func: has(name)) {
+			expand(_all_)
+		}
+	}`)
+	require.True(t, preds.all)
+	require.Equal(t, []string{"name"}, preds.sorted())
+}
+
+func TestQueryPredicatesSchema(t *testing.T) {
+	preds := parsedQueryPredicates(t, `
+	schema(pred: [name, age]) {
+		type
+	}`)
+	require.False(t, preds.all)
+	require.Equal(t, []string{"age", "name"}, preds.sorted())
+
+	// The whole schema can only be read by the guardians.
+	preds = parsedQueryPredicates(t, `
+	schema {
+		type
+	}`)
+	require.True(t, preds.all)
+	require.Empty(t, preds.sorted())
+}
+
+func TestMutationPredicates(t *testing.T) {
+	gmu, err := parseMutationObject(&api.Mutation{
+		SetNquads: []byte(`_:a <name> "Alice" .
+			_:a <friend> <0x1> .`),
+		DelNquads: []byte(`<0x2> <age> * .`),
+	})
+	require.NoError(t, err)
+	preds := mutationPredicates(gmu)
+	require.False(t, preds.all)
+	require.Equal(t, []string{"age", "friend", "name"}, preds.sorted())
+
+	gmu, err = parseMutationObject(&api.Mutation{DelNquads: []byte(`<0x2> * * .`)})
+	require.NoError(t, err)
+	require.True(t, mutationPredicates(gmu).all)
+}
+
+func TestSchemaPredicates(t *testing.T) {
+	updates, err := schema.Parse(`
+		name: string @index(term) .
+		friend: uid @reverse .`)
+	require.NoError(t, err)
+	preds := schemaPredicates(updates)
+	require.Equal(t, []string{"friend", "name"}, preds.sorted())
+}
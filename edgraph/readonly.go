@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/posting"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// readOnlyTxnTTL is how long the start timestamp of a read-only transaction is remembered, to
+// reject the mutations made in it.
+const readOnlyTxnTTL = time.Hour
+
+// readOnlyTxns has the start timestamps of the read-only transactions handed out by this
+// server, with the time they were last handed out at. Zero marks them too, so that the
+// transactions can't commit through another server either.
+type readOnlyTxns struct {
+	sync.Mutex
+	ts         map[uint64]time.Time
+	lastPrune  time.Time
+	latest     uint64 // latest read-only timestamp handed out, used by best-effort queries
+	refreshing bool   // a newer latest is being asked to Zero
+}
+
+func (r *readOnlyTxns) add(ts uint64) {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	if r.ts == nil {
+		r.ts = make(map[uint64]time.Time)
+	}
+	r.ts[ts] = now
+	if ts > r.latest {
+		r.latest = ts
+	}
+	if now.Sub(r.lastPrune) < time.Minute {
+		return
+	}
+	// The builtin delete is shadowed in this package, so the live entries are copied over.
+	live := make(map[uint64]time.Time, len(r.ts))
+	for t, at := range r.ts {
+		if now.Sub(at) <= readOnlyTxnTTL {
+			live[t] = at
+		}
+	}
+	r.ts, r.lastPrune = live, now
+}
+
+func (r *readOnlyTxns) has(ts uint64) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.ts[ts]
+	return ok
+}
+
+// latestTs returns the latest read-only timestamp, and whether a newer one must be asked to
+// Zero because max, the latest timestamp this server has seen, is past it. Only one caller is
+// told to ask at a time.
+func (r *readOnlyTxns) latestTs(max uint64) (uint64, bool) {
+	r.Lock()
+	defer r.Unlock()
+	if r.latest > 0 {
+		r.ts[r.latest] = time.Now()
+	}
+	if r.latest == 0 || r.latest >= max || r.refreshing {
+		return r.latest, false
+	}
+	r.refreshing = true
+	return r.latest, true
+}
+
+func (r *readOnlyTxns) refreshed(ts uint64) {
+	r.add(ts)
+	r.Lock()
+	defer r.Unlock()
+	r.refreshing = false
+}
+
+// isBestEffort returns whether the query of ctx can be run at the latest read-only timestamp
+// this server has, instead of asking Zero for one. gRPC clients ask for it with the best-effort metadata,
+// and HTTP clients with the be query parameter.
+func isBestEffort(ctx context.Context) bool {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if be := md["best-effort"]; len(be) > 0 && be[0] == "true" {
+			return true
+		}
+	}
+	return ctx.Value("best-effort") == "true"
+}
+
+// queryTs returns the start timestamp of a query without one. A best-effort query is read-only
+// and runs at the latest read-only timestamp this server got from Zero, which saves a round trip
+// to Zero but may miss the latest commits. Once commits are seen past it, a newer one is asked
+// for in the background. The read-only timestamps are remembered, so that mutations are not
+// allowed in their transactions, and Zero doesn't let them commit through other servers.
+func (s *ServerState) queryTs(ctx context.Context, req *api.Request) uint64 {
+	if isBestEffort(ctx) {
+		req.ReadOnly = true
+		if ts, refresh := s.readOnly.latestTs(posting.Oracle().MaxAssigned()); ts > 0 {
+			if refresh {
+				go func() {
+					s.readOnly.refreshed(s.getTimestamp(true))
+				}()
+			}
+			return ts
+		}
+	}
+	ts := s.getTimestamp(req.ReadOnly)
+	if req.ReadOnly {
+		s.readOnly.add(ts)
+	}
+	return ts
+}
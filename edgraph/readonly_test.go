@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnlyLatestTs(t *testing.T) {
+	var r readOnlyTxns
+	ts, refresh := r.latestTs(10)
+	require.Equal(t, uint64(0), ts)
+	require.False(t, refresh)
+
+	r.add(10)
+	r.add(5)
+	ts, refresh = r.latestTs(10)
+	require.Equal(t, uint64(10), ts)
+	require.False(t, refresh)
+
+	// Commits past the latest one are seen, a single caller asks for a newer one.
+	ts, refresh = r.latestTs(12)
+	require.Equal(t, uint64(10), ts)
+	require.True(t, refresh)
+	_, refresh = r.latestTs(12)
+	require.False(t, refresh)
+
+	r.refreshed(13)
+	ts, refresh = r.latestTs(13)
+	require.Equal(t, uint64(13), ts)
+	require.False(t, refresh)
+	require.True(t, r.has(10))
+	require.True(t, r.has(13))
+}
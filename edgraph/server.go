@@ -31,6 +31,7 @@ import (
 	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/dgraph-io/dgo/y"
 	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/posting"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/query"
 	"github.com/dgraph-io/dgraph/rdf"
@@ -60,6 +61,8 @@ type ServerState struct {
 
 	mu     sync.Mutex
 	needTs chan tsReq
+
+	readOnly readOnlyTxns
 }
 
 var State ServerState
@@ -262,11 +265,22 @@ func (s *ServerState) getTimestamp(readOnly bool) uint64 {
 	return <-tr.ch
 }
 
-func (s *Server) Alter(ctx context.Context, op *api.Operation) (*api.Payload, error) {
+func (s *Server) Alter(ctx context.Context, op *api.Operation) (payload *api.Payload, err error) {
 	ctx, span := otrace.StartSpan(ctx, "Server.Alter")
 	defer span.End()
 	span.Annotatef(nil, "Alter operation: %+v", op)
 
+	ev := NewAuditEvent("alter")
+	defer func() { ev.Done(ctx, err) }()
+	switch {
+	case op.DropAll:
+		ev.SetBody("drop_all")
+	case op.DropAttr != "":
+		ev.SetBody("drop_attr: " + op.DropAttr)
+	default:
+		ev.SetBody(op.Schema)
+	}
+
 	// Always print out Alter operations because they are important and rare.
 	glog.Infof("Received ALTER op: %+v", op)
 
@@ -283,6 +297,9 @@ func (s *Server) Alter(ctx context.Context, op *api.Operation) (*api.Payload, er
 	if !isMutationAllowed(ctx) {
 		return nil, x.Errorf("No mutations allowed by server.")
 	}
+	if err := checkNotReplica(); err != nil {
+		return nil, err
+	}
 	if err := isAlterAllowed(ctx); err != nil {
 		glog.Warningf("Alter denied with error: %v\n", err)
 		return nil, err
@@ -294,11 +311,21 @@ func (s *Server) Alter(ctx context.Context, op *api.Operation) (*api.Payload, er
 	// if it lies on some other machine. Let's get it for safety.
 	m := &pb.Mutations{StartTs: State.getTimestamp(false)}
 	if op.DropAll {
+		ev.AllPredicates = true
+		if err := s.authorizeAlter(ctx, predicates{all: true}); err != nil {
+			return empty, err
+		}
 		m.DropAll = true
 		_, err := query.ApplyMutations(ctx, m)
 		return empty, err
 	}
 	if len(op.DropAttr) > 0 {
+		var preds predicates
+		preds.add(op.DropAttr)
+		ev.setPredicates(preds)
+		if err := s.authorizeAlter(ctx, preds); err != nil {
+			return empty, err
+		}
 		nq := &api.NQuad{
 			Subject:     x.Star,
 			Predicate:   op.DropAttr,
@@ -318,6 +345,10 @@ func (s *Server) Alter(ctx context.Context, op *api.Operation) (*api.Payload, er
 	if err != nil {
 		return empty, err
 	}
+	ev.setPredicates(schemaPredicates(updates))
+	if err := s.authorizeAlter(ctx, schemaPredicates(updates)); err != nil {
+		return empty, err
+	}
 	glog.Infof("Got schema: %+v\n", updates)
 	// TODO: Maybe add some checks about the schema.
 	m.Schema = updates
@@ -333,6 +364,19 @@ func (s *Server) Mutate(ctx context.Context, mu *api.Mutation) (resp *api.Assign
 	ctx, span := otrace.StartSpan(ctx, "Server.Mutate")
 	defer span.End()
 
+	ev := NewAuditEvent("mutate")
+	ev.SetBody(mutationBody(mu))
+	defer func() {
+		if ev == nil {
+			return
+		}
+		ev.StartTs = mu.StartTs
+		if resp != nil && resp.Context != nil {
+			ev.CommitTs = resp.Context.CommitTs
+		}
+		ev.Done(ctx, err)
+	}()
+
 	resp = &api.Assigned{}
 	if err := x.HealthCheck(); err != nil {
 		return resp, err
@@ -347,6 +391,19 @@ func (s *Server) Mutate(ctx context.Context, mu *api.Mutation) (resp *api.Assign
 	if !isMutationAllowed(ctx) {
 		return nil, x.Errorf("No mutations allowed.")
 	}
+	if err := checkNotReplica(); err != nil {
+		return nil, err
+	}
+	if mu.StartTs != 0 && State.readOnly.has(mu.StartTs) {
+		return resp, x.Errorf("Mutations aren't allowed in read-only transaction %d", mu.StartTs)
+	}
+	if up, err := upsertFromContext(ctx, mu); err != nil {
+		return resp, err
+	} else if up != nil {
+		// The upsert is audited on its own.
+		ev = nil
+		return s.Upsert(ctx, up, &api.Mutation{StartTs: mu.StartTs, CommitNow: mu.CommitNow})
+	}
 	if mu.StartTs == 0 {
 		mu.StartTs = State.getTimestamp(false)
 	}
@@ -365,6 +422,13 @@ func (s *Server) Mutate(ctx context.Context, mu *api.Mutation) (resp *api.Assign
 	if err != nil {
 		return resp, err
 	}
+	if vars := uidVars(gmu); len(vars) > 0 {
+		return resp, x.Errorf("Variables %v can only be used in the mutations of an upsert", vars)
+	}
+	ev.setPredicates(mutationPredicates(gmu))
+	if err := s.authorizeMutation(ctx, mutationPredicates(gmu)); err != nil {
+		return resp, err
+	}
 	parseEnd := time.Now()
 	l.Parsing = parseEnd.Sub(l.Start)
 	defer func() {
@@ -375,24 +439,47 @@ func (s *Server) Mutate(ctx context.Context, mu *api.Mutation) (resp *api.Assign
 		}
 	}()
 
-	newUids, err := query.AssignUids(ctx, gmu.Set)
-	if err != nil {
-		return resp, err
-	}
-	resp.Uids = query.ConvertUidsToHex(query.StripBlankNode(newUids))
-	edges, err := query.ToInternal(gmu, newUids)
-	if err != nil {
-		return resp, err
-	}
+	resp, err = applyMutation(ctx, gmu, mu.StartTs, mu.CommitNow, nil)
+	return resp, err
+}
+
+// applyMutation applies gmu in the transaction started at startTs, and commits it right away
+// if commitNow is set.
+func applyMutation(ctx context.Context, gmu *gql.Mutation, startTs uint64,
+	commitNow bool, readKeys []string) (*api.Assigned, error) {
+	span := otrace.FromContext(ctx)
+	resp := &api.Assigned{Context: &api.TxnContext{StartTs: startTs}}
+
+	var err error
+	if gmu.HasOps() {
+		var newUids map[string]uint64
+		if newUids, err = query.AssignUids(ctx, gmu.Set); err != nil {
+			return resp, err
+		}
+		resp.Uids = query.ConvertUidsToHex(query.StripBlankNode(newUids))
+		var edges []*pb.DirectedEdge
+		if edges, err = query.ToInternal(gmu, newUids); err != nil {
+			return resp, err
+		}
 
-	m := &pb.Mutations{
-		Edges:   edges,
-		StartTs: mu.StartTs,
+		m := &pb.Mutations{
+			Edges:   edges,
+			StartTs: startTs,
+		}
+		span.Annotatef(nil, "Applying mutations: %+v", m)
+		resp.Context, err = query.ApplyMutations(ctx, m)
+		span.Annotatef(nil, "Txn Context: %+v. Err=%v", resp.Context, err)
 	}
-	span.Annotatef(nil, "Applying mutations: %+v", m)
-	resp.Context, err = query.ApplyMutations(ctx, m)
-	span.Annotatef(nil, "Txn Context: %+v. Err=%v", resp.Context, err)
-	if !mu.CommitNow {
+	if resp.Context != nil {
+		// The keys read by the query of an upsert are conflict keys too, so that the commit
+		// aborts if another transaction changed them after the query ran.
+		for _, key := range readKeys {
+			if !x.HasString(resp.Context.Keys, key) {
+				resp.Context.Keys = append(resp.Context.Keys, key)
+			}
+		}
+	}
+	if !commitNow {
 		if err == y.ErrConflict {
 			err = status.Error(codes.FailedPrecondition, err.Error())
 		}
@@ -405,7 +492,7 @@ func (s *Server) Mutate(ctx context.Context, mu *api.Mutation) (resp *api.Assign
 		// ignoring any error that might occur during the abort (the user would
 		// care more about the previous error).
 		if resp.Context == nil {
-			resp.Context = &api.TxnContext{StartTs: mu.StartTs}
+			resp.Context = &api.TxnContext{StartTs: startTs}
 		}
 		resp.Context.Aborted = true
 		_, _ = worker.CommitOverNetwork(ctx, resp.Context)
@@ -434,6 +521,107 @@ func (s *Server) Mutate(ctx context.Context, mu *api.Mutation) (resp *api.Assign
 	return resp, nil
 }
 
+// Upsert runs the query of up and applies the mutations whose condition holds for its
+// results in the transaction of mu, with the uid(v) subjects and objects of their N-Quads
+// replaced by the uids of the query variable v. The query reads at the start ts of the
+// transaction, and the keys it read are conflict keys of the transaction even if nothing is
+// applied, so that a concurrent upsert reading them, or a write of them that is a conflict,
+// aborts the commit.
+func (s *Server) Upsert(ctx context.Context, up *gql.Upsert,
+	mu *api.Mutation) (resp *api.Assigned, err error) {
+	ctx, span := otrace.StartSpan(ctx, "Server.Upsert")
+	defer span.End()
+
+	ev := NewAuditEvent("upsert")
+	bodies := []string{"query: " + up.Query}
+	for _, cm := range up.Mutations {
+		bodies = append(bodies, mutationBody(cm.Mutation))
+	}
+	ev.SetBody(strings.Join(bodies, "\n"))
+	defer func() {
+		ev.StartTs = mu.StartTs
+		if resp != nil && resp.Context != nil {
+			ev.CommitTs = resp.Context.CommitTs
+		}
+		ev.Done(ctx, err)
+	}()
+
+	if err := x.HealthCheck(); err != nil {
+		return &api.Assigned{}, err
+	}
+	if !isMutationAllowed(ctx) {
+		return nil, x.Errorf("No mutations allowed.")
+	}
+	if err := checkNotReplica(); err != nil {
+		return nil, err
+	}
+	if mu.StartTs != 0 && State.readOnly.has(mu.StartTs) {
+		return &api.Assigned{}, x.Errorf("Mutations aren't allowed in read-only transaction %d",
+			mu.StartTs)
+	}
+
+	var l query.Latency
+	l.Start = time.Now()
+	gmus := make([]*gql.Mutation, len(up.Mutations))
+	var needs []string
+	for i, cm := range up.Mutations {
+		if gmus[i], err = parseMutationObject(cm.Mutation); err != nil {
+			return &api.Assigned{}, err
+		}
+		ev.setPredicates(mutationPredicates(gmus[i]))
+		if err := s.authorizeMutation(ctx, mutationPredicates(gmus[i])); err != nil {
+			return &api.Assigned{}, err
+		}
+		needs = append(needs, uidVars(gmus[i])...)
+	}
+	parsedReq, err := up.ParseQuery(needs...)
+	if err != nil {
+		return &api.Assigned{}, err
+	}
+	ev.setPredicates(queryPredicates(&parsedReq))
+	if err := s.authorizeQuery(ctx, queryPredicates(&parsedReq)); err != nil {
+		return &api.Assigned{}, err
+	}
+	if err := s.checkQueryPolicy(ctx, &parsedReq); err != nil {
+		return &api.Assigned{}, err
+	}
+	if mu.StartTs == 0 {
+		mu.StartTs = State.getTimestamp(false)
+	}
+	annotateStartTs(span, mu.StartTs)
+
+	queryRequest := query.QueryRequest{
+		Latency:  &l,
+		GqlQuery: &parsedReq,
+		ReadTs:   mu.StartTs,
+	}
+	if err := queryRequest.ProcessQuery(ctx); err != nil {
+		return &api.Assigned{}, x.Wrap(err)
+	}
+
+	readKeys := posting.ReadConflictKeys(queryRequest.ReadKeys())
+	gmu := &gql.Mutation{}
+	for i, cm := range up.Mutations {
+		if cm.Cond != nil && !cm.Cond.Eval(queryRequest.VarLen) {
+			span.Annotatef(nil, "Condition of mutation %d doesn't hold", i)
+			continue
+		}
+		gmu.Set = append(gmu.Set, substituteUidVars(gmus[i].Set, queryRequest.VarUids)...)
+		gmu.Del = append(gmu.Del, substituteUidVars(gmus[i].Del, queryRequest.VarUids)...)
+	}
+
+	// If none of the conditions hold, or the variables have no uids, only the read keys are
+	// committed.
+	if resp, err = applyMutation(ctx, gmu, mu.StartTs, mu.CommitNow, readKeys); err != nil {
+		return resp, err
+	}
+	resp.Latency = &api.Latency{
+		ParsingNs:    uint64(l.Parsing.Nanoseconds()),
+		ProcessingNs: uint64((time.Since(l.Start) - l.Parsing).Nanoseconds()),
+	}
+	return resp, nil
+}
+
 // This method is used to execute the query and return the response to the
 // client as a protocol buffer message.
 func (s *Server) Query(ctx context.Context, req *api.Request) (resp *api.Response, err error) {
@@ -443,6 +631,13 @@ func (s *Server) Query(ctx context.Context, req *api.Request) (resp *api.Respons
 	ctx, span := otrace.StartSpan(ctx, "Server.Query")
 	defer span.End()
 
+	ev := NewAuditEvent("query")
+	ev.SetBody(req.Query)
+	defer func() {
+		ev.StartTs = req.StartTs
+		ev.Done(ctx, err)
+	}()
+
 	if err := x.HealthCheck(); err != nil {
 		return resp, err
 	}
@@ -463,6 +658,10 @@ func (s *Server) Query(ctx context.Context, req *api.Request) (resp *api.Respons
 	var l query.Latency
 	l.Start = time.Now()
 	span.Annotatef(nil, "Query received: %v", req)
+	slow := newSlowQuery("query", req.Query)
+	defer func() {
+		slow.done(ctx, req.StartTs, &l, err)
+	}()
 
 	parsedReq, err := gql.Parse(gql.Request{
 		Str:       req.Query,
@@ -471,9 +670,23 @@ func (s *Server) Query(ctx context.Context, req *api.Request) (resp *api.Respons
 	if err != nil {
 		return resp, err
 	}
+	ev.setPredicates(queryPredicates(&parsedReq))
+	slow.setPredicates(queryPredicates(&parsedReq))
+	if err := s.authorizeQuery(ctx, queryPredicates(&parsedReq)); err != nil {
+		return resp, err
+	}
+	if err := s.checkQueryPolicy(ctx, &parsedReq); err != nil {
+		return resp, err
+	}
+	limits, err := queryLimits(ctx)
+	if err != nil {
+		return resp, err
+	}
+	ctx, cancel := withQueryLimits(ctx, limits)
+	defer cancel()
 
 	if req.StartTs == 0 {
-		req.StartTs = State.getTimestamp(req.ReadOnly)
+		req.StartTs = State.queryTs(ctx, req)
 	}
 	resp.Txn = &api.TxnContext{
 		StartTs: req.StartTs,
@@ -489,7 +702,7 @@ func (s *Server) Query(ctx context.Context, req *api.Request) (resp *api.Respons
 	// Core processing happens here.
 	var er query.ExecuteResult
 	if er, err = queryRequest.Process(ctx); err != nil {
-		return resp, x.Wrap(err)
+		return resp, limitsError(ctx, limits, x.Wrap(err))
 	}
 	resp.Schema = er.SchemaNode
 
@@ -507,18 +720,138 @@ func (s *Server) Query(ctx context.Context, req *api.Request) (resp *api.Respons
 	}
 
 	resp.Latency = gl
+	reportTruncated(ctx, setUnaryTrailer(ctx))
+	reportExplain(ctx, er.Explain, setUnaryTrailer(ctx))
 	return resp, err
 }
 
-func (s *Server) CommitOrAbort(ctx context.Context, tc *api.TxnContext) (*api.TxnContext, error) {
+// StreamQuery executes a query like Query, but sends the results in several responses, one for
+// each batch of uids at the root of the query, so that large results are never held in memory
+// as a whole. The latency of the whole query is sent with the last response.
+func (s *Server) StreamQuery(req *api.Request, stream pb.Alpha_StreamQueryServer) (err error) {
+	if glog.V(3) {
+		glog.Infof("Got a streaming query: %+v", req)
+	}
+	ctx, span := otrace.StartSpan(stream.Context(), "Server.StreamQuery")
+	defer span.End()
+
+	ev := NewAuditEvent("stream_query")
+	ev.SetBody(req.Query)
+	defer func() {
+		ev.StartTs = req.StartTs
+		ev.Done(ctx, err)
+	}()
+
+	if err := x.HealthCheck(); err != nil {
+		return err
+	}
+
+	x.PendingQueries.Add(1)
+	x.NumQueries.Add(1)
+	defer x.PendingQueries.Add(-1)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if len(req.Query) == 0 {
+		span.Annotate(nil, "Empty query")
+		return fmt.Errorf("empty query")
+	}
+
+	var l query.Latency
+	l.Start = time.Now()
+	span.Annotatef(nil, "Query received: %v", req)
+	slow := newSlowQuery("stream_query", req.Query)
+	defer func() {
+		slow.done(ctx, req.StartTs, &l, err)
+	}()
+
+	parsedReq, err := gql.Parse(gql.Request{
+		Str:       req.Query,
+		Variables: req.Vars,
+	})
+	if err != nil {
+		return err
+	}
+	ev.setPredicates(queryPredicates(&parsedReq))
+	slow.setPredicates(queryPredicates(&parsedReq))
+	if err := s.authorizeQuery(ctx, queryPredicates(&parsedReq)); err != nil {
+		return err
+	}
+	if err := s.checkQueryPolicy(ctx, &parsedReq); err != nil {
+		return err
+	}
+	limits, err := queryLimits(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withQueryLimits(ctx, limits)
+	defer cancel()
+
+	if req.StartTs == 0 {
+		req.StartTs = State.queryTs(ctx, req)
+	}
+	annotateStartTs(span, req.StartTs)
+
+	queryRequest := query.QueryRequest{
+		Latency:  &l,
+		GqlQuery: &parsedReq,
+		ReadTs:   req.StartTs,
+	}
+
+	// Each batch is sent once the next one is encoded, so that the last one carries the
+	// latency.
+	var pending []byte
+	send := func(json []byte) error {
+		if pending != nil {
+			err := stream.Send(&api.Response{
+				Json: pending,
+				Txn:  &api.TxnContext{StartTs: req.StartTs},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		pending = json
+		return nil
+	}
+	if err := queryRequest.ProcessStream(ctx, query.StreamBatchSize, send); err != nil {
+		return limitsError(ctx, limits, x.Wrap(err))
+	}
+	reportTruncated(ctx, stream.SetTrailer)
+	return stream.Send(&api.Response{
+		Json: pending,
+		Txn:  &api.TxnContext{StartTs: req.StartTs},
+		Latency: &api.Latency{
+			ParsingNs:    uint64(l.Parsing.Nanoseconds()),
+			ProcessingNs: uint64(l.Processing.Nanoseconds()),
+			EncodingNs:   uint64(l.Json.Nanoseconds()),
+		},
+	})
+}
+
+func (s *Server) CommitOrAbort(ctx context.Context,
+	tc *api.TxnContext) (tctx *api.TxnContext, err error) {
 	ctx, span := otrace.StartSpan(ctx, "Server.CommitOrAbort")
 	defer span.End()
 
+	ev := NewAuditEvent("commit")
+	if tc.Aborted {
+		ev.Operation = "abort"
+	}
+	ev.StartTs = tc.StartTs
+	defer func() {
+		if tctx != nil {
+			ev.CommitTs = tctx.CommitTs
+		}
+		ev.Done(ctx, err)
+	}()
+
 	if err := x.HealthCheck(); err != nil {
 		return &api.TxnContext{}, err
 	}
 
-	tctx := &api.TxnContext{}
+	tctx = &api.TxnContext{}
 	if tc.StartTs == 0 {
 		return &api.TxnContext{}, fmt.Errorf("StartTs cannot be zero while committing a transaction.")
 	}
@@ -558,6 +891,16 @@ func isMutationAllowed(ctx context.Context) bool {
 	return true
 }
 
+// checkNotReplica fails if this cluster replicates the backups of another one, which would
+// overwrite the changes.
+func checkNotReplica() error {
+	if worker.Config.ReplicateFrom != "" {
+		return x.Errorf("This cluster replicates the backups at %q, it only serves queries.",
+			worker.Config.ReplicateFrom)
+	}
+	return nil
+}
+
 var errNoAuth = x.Errorf("No Auth Token found. Token needed for Alter operations.")
 
 func isAlterAllowed(ctx context.Context) error {
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgraph/query"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+)
+
+// SlowLogOptions configure the log of slow queries.
+type SlowLogOptions struct {
+	// Threshold is the latency above which a query is logged.
+	Threshold time.Duration
+	// Output is the file the slow queries are written to.
+	Output string
+	// MaxSizeMB is the size a file grows to before it is rotated, zero to never rotate it.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+}
+
+// slowLog is where the slow queries are written, with a nil writer if the log is off.
+var slowLog struct {
+	sync.Mutex
+	w         io.WriteCloser
+	threshold time.Duration
+}
+
+// StartSlowLog starts writing the queries slower than the threshold of opts to its output.
+func StartSlowLog(opts SlowLogOptions) error {
+	w, err := newRotatingFile(opts.Output, int64(opts.MaxSizeMB)<<20, opts.MaxBackups)
+	if err != nil {
+		return x.Wrapf(err, "while opening the slow query log %q", opts.Output)
+	}
+	slowLog.Lock()
+	defer slowLog.Unlock()
+	slowLog.w = w
+	slowLog.threshold = opts.Threshold
+	glog.Infof("Writing the queries slower than %v to %s", opts.Threshold, opts.Output)
+	return nil
+}
+
+// StopSlowLog stops writing slow queries and closes their log.
+func StopSlowLog() {
+	slowLog.Lock()
+	defer slowLog.Unlock()
+	if slowLog.w == nil {
+		return
+	}
+	if err := slowLog.w.Close(); err != nil {
+		glog.Errorf("Error while closing the slow query log: %v", err)
+	}
+	slowLog.w = nil
+}
+
+// SlowQuery is the record of a slow query in the slow query log, written as a line of JSON.
+type SlowQuery struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	Operation string    `json:"operation"`
+	StartTs   uint64    `json:"start_ts,omitempty"`
+	// LatencyMs is the whole latency of the query, and the others its parts: parsing,
+	// processing and encoding the results in JSON.
+	LatencyMs    float64 `json:"latency_ms"`
+	ParsingMs    float64 `json:"parsing_ms"`
+	ProcessingMs float64 `json:"processing_ms"`
+	EncodingMs   float64 `json:"encoding_ms"`
+	// Predicates are the predicates read by the query, and AllPredicates is set if it can
+	// read any of them, like expand(_all_).
+	Predicates    []string `json:"predicates,omitempty"`
+	AllPredicates bool     `json:"all_predicates,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	Query         string   `json:"query"`
+}
+
+// newSlowQuery returns the record of a query starting now, logged if it turns out slow.
+func newSlowQuery(op, text string) *SlowQuery {
+	return &SlowQuery{Time: time.Now(), Operation: op, Query: text}
+}
+
+func (sq *SlowQuery) setPredicates(preds predicates) {
+	sq.Predicates = preds.sorted()
+	sq.AllPredicates = preds.all
+}
+
+// done writes the record of a query that ended with err, with the latency in l, if the log is
+// on and the query took longer than its threshold.
+func (sq *SlowQuery) done(ctx context.Context, startTs uint64, l *query.Latency, err error) {
+	latency := time.Since(sq.Time)
+	slowLog.Lock()
+	on := slowLog.w != nil && latency >= slowLog.threshold
+	slowLog.Unlock()
+	if !on {
+		return
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	sq.StartTs = startTs
+	sq.LatencyMs = ms(latency)
+	sq.ParsingMs = ms(l.Parsing)
+	sq.ProcessingMs = ms(l.Processing)
+	sq.EncodingMs = ms(l.Json)
+	sq.User = auditUser(ctx)
+	if err != nil {
+		sq.Error = err.Error()
+	}
+	js, err := json.Marshal(sq)
+	if err != nil {
+		glog.Errorf("Unable to marshal slow query: %v", err)
+		return
+	}
+
+	slowLog.Lock()
+	defer slowLog.Unlock()
+	if slowLog.w == nil {
+		return
+	}
+	if _, err := slowLog.w.Write(append(js, '\n')); err != nil {
+		glog.Errorf("Unable to write slow query: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slowlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow_query.log")
+
+	require.NoError(t, StartSlowLog(SlowLogOptions{Threshold: 50 * time.Millisecond,
+		Output: path}))
+	defer StopSlowLog()
+
+	// A query faster than the threshold isn't logged.
+	sq := newSlowQuery("query", "{ q(func: has(name)) { name } }")
+	sq.done(context.Background(), 10, &query.Latency{}, nil)
+
+	sq = newSlowQuery("query", "{ q(func: has(name)) { name friend { name } } }")
+	sq.setPredicates(predicates{names: map[string]struct{}{"name": {}, "friend": {}}})
+	sq.Time = sq.Time.Add(-time.Second)
+	sq.done(context.Background(), 11, &query.Latency{
+		Parsing:    time.Millisecond,
+		Processing: 900 * time.Millisecond,
+		Json:       99 * time.Millisecond,
+	}, nil)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var logged SlowQuery
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &logged))
+	require.Equal(t, uint64(11), logged.StartTs)
+	require.Equal(t, []string{"friend", "name"}, logged.Predicates)
+	require.True(t, logged.LatencyMs >= 1000)
+	require.Equal(t, float64(900), logged.ProcessingMs)
+	require.Equal(t, "{ q(func: has(name)) { name friend { name } } }", logged.Query)
+}
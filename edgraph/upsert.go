@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The metadata of a mutation that makes it the mutation of an upsert. The query has newlines,
+// so it is sent as binary metadata.
+const (
+	upsertQueryKey = "upsert-query-bin"
+	upsertCondKey  = "upsert-cond"
+)
+
+// upsertFromContext returns the upsert applying mu if the metadata of the request has an upsert
+// query, with the optional condition of mu, or nil for a plain mutation.
+func upsertFromContext(ctx context.Context, mu *api.Mutation) (*gql.Upsert, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	queries := md.Get(upsertQueryKey)
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	up := &gql.Upsert{Query: queries[0], Mutations: []*gql.CondMutation{{Mutation: mu}}}
+	if conds := md.Get(upsertCondKey); len(conds) > 0 {
+		cond, err := gql.ParseCond(conds[0])
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid %s: %v", upsertCondKey, err)
+		}
+		up.Mutations[0].Cond = cond
+	}
+	return up, nil
+}
+
+// uidVars returns the query variables used as uid(v) in the subjects and objects of gmu.
+func uidVars(gmu *gql.Mutation) []string {
+	var vars []string
+	for _, nqs := range [][]*api.NQuad{gmu.Set, gmu.Del} {
+		for _, nq := range nqs {
+			if v, ok := gql.UidVar(nq.Subject); ok {
+				vars = append(vars, v)
+			}
+			if v, ok := gql.UidVar(nq.ObjectId); ok {
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars
+}
+
+// substituteUidVars returns nqs with every uid(v) subject or object replaced by each uid of
+// the variable v, as given by uids. An N-Quad using a variable without uids is dropped.
+func substituteUidVars(nqs []*api.NQuad, uids func(v string) []uint64) []*api.NQuad {
+	var out []*api.NQuad
+	for _, nq := range nqs {
+		subjects := expandUidVar(nq.Subject, uids)
+		objects := expandUidVar(nq.ObjectId, uids)
+		for _, sub := range subjects {
+			for _, obj := range objects {
+				n := *nq
+				n.Subject, n.ObjectId = sub, obj
+				out = append(out, &n)
+			}
+		}
+	}
+	return out
+}
+
+func expandUidVar(id string, uids func(v string) []uint64) []string {
+	v, ok := gql.UidVar(id)
+	if !ok {
+		return []string{id}
+	}
+	var ids []string
+	for _, uid := range uids(v) {
+		ids = append(ids, fmt.Sprintf("%#x", uid))
+	}
+	return ids
+}
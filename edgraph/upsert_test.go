@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package edgraph
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUpsertFromContext(t *testing.T) {
+	mu := &api.Mutation{SetNquads: []byte(`uid(v) <name> "Alice" .`)}
+	up, err := upsertFromContext(context.Background(), mu)
+	require.NoError(t, err)
+	require.Nil(t, up)
+
+	// A mutation isn't an upsert because of its N-Quads.
+	plain := &api.Mutation{SetNquads: []byte(`upsert { query { } }`)}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("foo", "bar"))
+	up, err = upsertFromContext(ctx, plain)
+	require.NoError(t, err)
+	require.Nil(t, up)
+
+	query := "{\n  v as var(func: eq(email, \"alice@example.com\"))\n}"
+	ctx = metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(upsertQueryKey, query, upsertCondKey, "@if(eq(len(v), 0))"))
+	up, err = upsertFromContext(ctx, mu)
+	require.NoError(t, err)
+	require.Equal(t, query, up.Query)
+	require.Len(t, up.Mutations, 1)
+	require.Equal(t, mu, up.Mutations[0].Mutation)
+	require.False(t, up.Mutations[0].Cond.Eval(func(string) int { return 1 }))
+	require.True(t, up.Mutations[0].Cond.Eval(func(string) int { return 0 }))
+
+	ctx = metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(upsertQueryKey, query, upsertCondKey, "eq(len(v), 0)"))
+	_, err = upsertFromContext(ctx, mu)
+	require.Error(t, err)
+}
+
+func TestSubstituteUidVars(t *testing.T) {
+	vars := map[string][]uint64{
+		"v": {0x1, 0x2},
+		"w": {0xa},
+	}
+	uids := func(v string) []uint64 { return vars[v] }
+
+	nqs := []*api.NQuad{
+		makeNquadEdge("uid(v)", "friend", "uid(w)"),
+		makeNquad("uid(v)", "name", &api.Value{Val: &api.Value_StrVal{StrVal: "Alice"}}),
+		makeNquadEdge("uid(empty)", "friend", "0x3"),
+	}
+	gmu := &gql.Mutation{Set: nqs}
+	require.Equal(t, []string{"v", "w", "v", "empty"}, uidVars(gmu))
+
+	out := substituteUidVars(nqs, uids)
+	require.Len(t, out, 4)
+	require.Equal(t, "0x1", out[0].Subject)
+	require.Equal(t, "0xa", out[0].ObjectId)
+	require.Equal(t, "0x2", out[1].Subject)
+	require.Equal(t, "0xa", out[1].ObjectId)
+	require.Equal(t, "0x1", out[2].Subject)
+	require.Equal(t, "0x2", out[3].Subject)
+	require.Equal(t, "name", out[3].Predicate)
+	// The N-Quads of the mutation are left as they are.
+	require.Equal(t, "uid(v)", nqs[0].Subject)
+}
+
+func TestNquadsFromJsonUidVar(t *testing.T) {
+	json := `{"uid": "uid(v)", "name": "Alice", "friend": {"uid": "uid(w)"}}`
+	nq, err := nquadsFromJson([]byte(json), set)
+	require.NoError(t, err)
+	require.Len(t, nq, 2)
+	for _, n := range nq {
+		require.Equal(t, "uid(v)", n.Subject)
+		if n.Predicate == "friend" {
+			require.Equal(t, "uid(w)", n.ObjectId)
+		}
+	}
+}
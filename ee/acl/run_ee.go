@@ -45,6 +45,9 @@ func init() {
 
 	flag := CmdAcl.Cmd.PersistentFlags()
 	flag.StringP("dgraph", "d", "127.0.0.1:9080", "Dgraph gRPC server address")
+	flag.String("admin_user", "", "The user to log in as, which must be in the guardians group "+
+		"once it has a member")
+	flag.String("admin_password", "", "The password of the admin_user")
 
 	// TLS configuration
 	x.RegisterTLSFlags(flag)
@@ -216,8 +219,16 @@ func getDgraphClient(conf *viper.Viper) (*dgo.Dgraph, CloseFunc) {
 	conn, err := x.SetupConnection(opt.dgraph, &tlsConf)
 	x.Checkf(err, "While trying to setup connection to Dgraph alpha.")
 
-	dc := api.NewDgraphClient(conn)
-	return dgo.NewDgraphClient(dc), func() {
+	dc := dgo.NewDgraphClient(api.NewDgraphClient(conn))
+	if adminUser := conf.GetString("admin_user"); len(adminUser) > 0 {
+		// Once the guardians group has a member, the ACLs are enforced and only its members
+		// can manage the users and groups.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := dc.Login(ctx, adminUser, conf.GetString("admin_password"))
+		cancel()
+		x.Checkf(err, "While trying to login as %s", adminUser)
+	}
+	return dc, func() {
 		if err := conn.Close(); err != nil {
 			glog.Errorf("Error while closing connection:%v", err)
 		}
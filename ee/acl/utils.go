@@ -21,6 +21,40 @@ import (
 	"github.com/golang/glog"
 )
 
+// The permissions of a group on a predicate, combined as the bits of Acl.Perm.
+const (
+	Read   = 4 // Query the predicate.
+	Write  = 2 // Mutate the predicate.
+	Modify = 1 // Alter the schema of the predicate, or drop it.
+)
+
+// GuardiansId is the group whose members have all the permissions on all the predicates. The
+// ACLs are only enforced once it has a member.
+const GuardiansId = "guardians"
+
+// IsAclPredicate returns whether the predicate stores users, groups or their ACLs. Only the
+// guardians can access them.
+func IsAclPredicate(pred string) bool {
+	switch pred {
+	case "dgraph.xid", "dgraph.password", "dgraph.user.group", "dgraph.group.acl":
+		return true
+	}
+	return false
+}
+
+// PermName returns the name of the permission perm, for errors.
+func PermName(perm int32) string {
+	switch perm {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Modify:
+		return "modify"
+	}
+	return fmt.Sprintf("perm %d", perm)
+}
+
 func GetGroupIDs(groups []Group) []string {
 	if len(groups) == 0 {
 		// the user does not have any groups
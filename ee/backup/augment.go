@@ -0,0 +1,183 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// augmenter computes the checksums of the backup files whose manifest has none, written by
+// older Alphas, as they're restored, and saves them to augmented manifests in dir. The next
+// restores and verifications with the same dir read the checksums from there, see
+// readAugmented, so they can check the files against them.
+type augmenter struct {
+	sync.Mutex
+	dir string
+	// Checksums by backup directory and group: the ones of the augmented manifests read, and
+	// the ones computed. computed is set if any of them is new.
+	sums     map[string]map[uint32]string
+	computed bool
+}
+
+// augmentedPath returns the path of the augmented manifest of the backup of m in dir.
+func augmentedPath(dir string, m *Manifest) string {
+	return filepath.Join(dir, filepath.FromSlash(path.Dir(m.path)), manifestName)
+}
+
+// readAugmented sets the checksums missing from the manifests to the ones of their augmented
+// manifests in dir, if they have any.
+func readAugmented(dir string, manifests []*Manifest) error {
+	for _, m := range manifests {
+		b, err := ioutil.ReadFile(augmentedPath(dir, m))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var am Manifest
+		if err := json.Unmarshal(b, &am); err != nil {
+			return x.Wrapf(err, "while reading the augmented manifest of %q", m.path)
+		}
+		if am.ReadTs != m.ReadTs {
+			return x.Errorf("The augmented manifest of %q in %q is of another backup, taken "+
+				"at ts %d instead of %d.", m.path, dir, am.ReadTs, m.ReadTs)
+		}
+		for gid, sum := range am.Checksums {
+			if m.Checksums[gid] != "" {
+				continue
+			}
+			if m.Checksums == nil {
+				m.Checksums = make(map[uint32]string)
+			}
+			m.Checksums[gid] = sum
+		}
+	}
+	return nil
+}
+
+// newAugmenter returns an augmenter of the backups of chain into dir, with the checksums of
+// their augmented manifests already there.
+func newAugmenter(dir string, chain []*Manifest) (*augmenter, error) {
+	a := &augmenter{dir: dir, sums: make(map[string]map[uint32]string)}
+	for _, m := range chain {
+		am := *m
+		am.Checksums = nil
+		if err := readAugmented(dir, []*Manifest{&am}); err != nil {
+			return nil, err
+		}
+		a.sums[path.Dir(m.path)] = am.Checksums
+	}
+	return a, nil
+}
+
+// filter returns filter, setting the checksum of the files without one to the one of their
+// augmented manifest first, so it's verified before they're loaded.
+func (a *augmenter) filter(filter loadFilter) loadFilter {
+	return func(f *loadFile) bool {
+		if f.checksum == "" {
+			a.Lock()
+			f.checksum = a.sums[path.Dir(f.name)][f.group]
+			a.Unlock()
+		}
+		return filter == nil || filter(f)
+	}
+}
+
+// wrap returns fn, computing the checksum of the files without one as fn reads them. The
+// part of a file fn doesn't read is read afterwards.
+func (a *augmenter) wrap(fn loadFn) loadFn {
+	return func(r io.Reader, f *loadFile) error {
+		if f.checksum != "" {
+			return fn(r, f)
+		}
+		sum := sha256.New()
+		tr := io.TeeReader(r, sum)
+		if err := fn(tr, f); err != nil {
+			return err
+		}
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			return err
+		}
+		dir := path.Dir(f.name)
+		a.Lock()
+		defer a.Unlock()
+		if a.sums[dir] == nil {
+			a.sums[dir] = make(map[uint32]string)
+		}
+		a.sums[dir][f.group] = hex.EncodeToString(sum.Sum(nil))
+		a.computed = true
+		return nil
+	}
+}
+
+// save writes the augmented manifests of the backups of chain with the checksums computed,
+// and returns their paths. Nothing is written if no checksum was computed.
+func (a *augmenter) save(chain []*Manifest) ([]string, error) {
+	a.Lock()
+	defer a.Unlock()
+	if !a.computed {
+		return nil, nil
+	}
+	var paths []string
+	for _, m := range chain {
+		sums := a.sums[path.Dir(m.path)]
+		var missing bool
+		for _, gid := range m.Groups {
+			if m.Checksums[gid] == "" && sums[gid] != "" {
+				missing = true
+			}
+		}
+		if !missing {
+			continue
+		}
+		am := *m
+		am.Checksums = make(map[uint32]string)
+		for gid, sum := range m.Checksums {
+			am.Checksums[gid] = sum
+		}
+		for gid, sum := range sums {
+			if am.Checksums[gid] == "" {
+				am.Checksums[gid] = sum
+			}
+		}
+		b, err := json.Marshal(&am)
+		if err != nil {
+			return nil, err
+		}
+		p := augmentedPath(a.dir, m)
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return nil, err
+		}
+		// The manifest is replaced at once, a restore that dies while writing it keeps the
+		// old one.
+		if err := ioutil.WriteFile(p+".tmp", b, 0600); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(p+".tmp", p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
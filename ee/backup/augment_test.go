@@ -0,0 +1,101 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreAugmentManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The manifests of older Alphas have no checksums.
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+	writeBackup(t, bdir, "20181106.021302", 10, 20, testKVs("name", 2))
+	checksums := make(map[string]map[uint32]string)
+	for _, ts := range []string{"20181106.011302", "20181106.021302"} {
+		mfile := filepath.Join(bdir, backupDir(ts), manifestName)
+		b, err := ioutil.ReadFile(mfile)
+		require.NoError(t, err)
+		var m Manifest
+		require.NoError(t, json.Unmarshal(b, &m))
+		require.NotEmpty(t, m.Checksums)
+		checksums[backupDir(ts)] = m.Checksums
+		m.Checksums = nil
+		require.NoError(t, WriteManifest(bdir, ts, &m))
+	}
+
+	adir := filepath.Join(dir, "augmented")
+	restore := func(pdir string, dryRun bool) (string, error) {
+		p, err := newProgress("text", ioutil.Discard)
+		require.NoError(t, err)
+		var msgs bytes.Buffer
+		p.msgs = &msgs
+		o := &restoreOptions{location: bdir, pdir: filepath.Join(dir, pdir), dryRun: dryRun,
+			augmentDir: adir}
+		err = runRestore(o, p)
+		return msgs.String(), err
+	}
+
+	// The first run computes the checksums of the files, and saves them.
+	msgs, err := restore("p1", false)
+	require.NoError(t, err)
+	for backup, expected := range checksums {
+		path := filepath.Join(adir, backup, manifestName)
+		require.Contains(t, msgs, "Wrote the checksums of the backup files to \""+path+"\"\n")
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		var m Manifest
+		require.NoError(t, json.Unmarshal(b, &m))
+		require.Equal(t, expected, m.Checksums)
+	}
+	require.Len(t, readKVs(t, filepath.Join(dir, "p1", "p1")), 5)
+
+	// The next runs check the files against them, and have nothing to save.
+	msgs, err = restore("p2", true)
+	require.NoError(t, err)
+	require.NotContains(t, msgs, "Wrote the checksums")
+
+	// A file that doesn't match its saved checksum fails, unlike without them.
+	path := filepath.Join(adir, backupDir("20181106.011302"), manifestName)
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(b, &m))
+	m.Checksums[2] = checksums[backupDir("20181106.011302")][1]
+	b, err = json.Marshal(&m)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+	_, err = restore("p3", true)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Checksum mismatch")
+
+	var out bytes.Buffer
+	o := &verifyOptions{location: bdir, augmentDir: adir}
+	err = runVerify(o, &out)
+	require.Error(t, err)
+	require.Contains(t, out.String(), "Checksum mismatch")
+	o.augmentDir = ""
+	require.NoError(t, runVerify(o, &out))
+}
@@ -0,0 +1,373 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+const (
+	azDefaultHost = "blob.core.windows.net"
+	azVersion     = "2017-11-09"
+	azResource    = "https://storage.azure.com/"
+	// azMaxRetries is the number of times a failed block upload is retried.
+	azMaxRetries = 5
+)
+
+var (
+	// azIdentityEndpoint is the Azure instance metadata endpoint that hands out managed
+	// identity tokens. It can be changed for testing.
+	azIdentityEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	// azBlockSize is the size of each block of a block blob upload.
+	azBlockSize = 16 << 20
+)
+
+// azHandler is used for 'azblob:' URI scheme.
+type azHandler struct {
+	client *http.Client
+	creds  *Credentials
+	sas    url.Values
+	token  string
+	expiry time.Time
+
+	container string // container URL
+	prefix    string
+	blob      string
+	buf       bytes.Buffer
+	blocks    []string
+}
+
+// setup prepares the container URL and the credentials to access it, once per handler.
+// URI formats:
+//   azblob://<account>/container/folder1.../folderN
+//   azblob://<account>.blob.core.windows.net/container/folder1.../folderN?secure=true|false
+// If env var AZURE_STORAGE_SAS_TOKEN is set, requests are authorized with that SAS token.
+// Otherwise, a token is requested for the VM's managed identity (use AZURE_CLIENT_ID to
+// pick a user-assigned identity).
+func (h *azHandler) setup(uri *url.URL) error {
+	if h.client != nil {
+		return nil
+	}
+	if uri.Host == "" {
+		return x.Errorf("The Azure storage account in %q is invalid", uri.String())
+	}
+	host := uri.Host
+	if !strings.Contains(host, ".") {
+		host = host + "." + azDefaultHost
+	}
+	parts := strings.SplitN(strings.Trim(uri.Path, "/"), "/", 2)
+	if parts[0] == "" {
+		return x.Errorf("The Azure container in %q is invalid", uri.String())
+	}
+	if len(parts) > 1 {
+		h.prefix = parts[1]
+	}
+	scheme := "https"
+	if uri.Query().Get("secure") == "false" {
+		scheme = "http"
+	}
+	h.container = fmt.Sprintf("%s://%s/%s", scheme, host, parts[0])
+	glog.V(2).Infof("Azure handler using container: %s, path: %s", h.container, h.prefix)
+
+	client, err := newHTTPClient(h.creds)
+	if err != nil {
+		return err
+	}
+	h.client = client
+	if sas := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); sas != "" {
+		if h.sas, err = url.ParseQuery(strings.TrimPrefix(sas, "?")); err != nil {
+			return x.Wrapf(err, "while parsing AZURE_STORAGE_SAS_TOKEN")
+		}
+		return nil
+	}
+	return h.refreshToken()
+}
+
+// refreshToken gets an access token for the managed identity, if the current one is
+// missing or about to expire.
+func (h *azHandler) refreshToken() error {
+	if h.sas != nil || (h.token != "" && time.Now().Add(time.Minute).Before(h.expiry)) {
+		return nil
+	}
+
+	q := url.Values{"api-version": {"2018-02-01"}, "resource": {azResource}}
+	if id := os.Getenv("AZURE_CLIENT_ID"); id != "" {
+		q.Set("client_id", id)
+	}
+	req, err := http.NewRequest(http.MethodGet, azIdentityEndpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return x.Wrapf(err, "while getting Azure managed identity token "+
+			"(set AZURE_STORAGE_SAS_TOKEN to use a SAS token instead)")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return azError(resp, "while getting Azure managed identity token")
+	}
+
+	// expires_in is sent as a string by the metadata service.
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	secs, err := strconv.ParseInt(token.ExpiresIn, 10, 64)
+	if err != nil {
+		return x.Errorf("Invalid Azure token expiry %q", token.ExpiresIn)
+	}
+	h.token = token.AccessToken
+	h.expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	return nil
+}
+
+// do sends an authorized request for the blob (or the container, if blob is empty).
+func (h *azHandler) do(method, blob string, q url.Values, body []byte) (*http.Response, error) {
+	if err := h.refreshToken(); err != nil {
+		return nil, err
+	}
+	u := h.container
+	if blob != "" {
+		u += "/" + (&url.URL{Path: blob}).EscapedPath()
+	}
+	if q == nil {
+		q = url.Values{}
+	}
+	for k, v := range h.sas {
+		q[k] = v
+	}
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	if h.sas == nil {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	return h.client.Do(req)
+}
+
+// Create prepares a block blob upload for the blob at p, relative to the location. Data sent
+// via Write is uploaded in blocks of azBlockSize, each one retried on failure, and the blob is
+// committed on Close.
+func (h *azHandler) Create(uri *url.URL, p string) error {
+	if err := h.setup(uri); err != nil {
+		return err
+	}
+
+	h.blob = path.Join(h.prefix, p)
+	h.blocks = h.blocks[:0]
+	h.buf.Reset()
+	glog.V(2).Infof("Sending data to Azure blob %q ...", h.blob)
+	return nil
+}
+
+func (h *azHandler) Write(b []byte) (int, error) {
+	n, _ := h.buf.Write(b)
+	for h.buf.Len() >= azBlockSize {
+		if err := h.putBlock(h.buf.Next(azBlockSize)); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close uploads the remaining data and commits the block list.
+func (h *azHandler) Close() error {
+	glog.V(2).Infof("Backup waiting for upload to complete.")
+	if h.buf.Len() > 0 {
+		if err := h.putBlock(h.buf.Next(h.buf.Len())); err != nil {
+			return err
+		}
+	}
+
+	var list bytes.Buffer
+	list.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+	for _, id := range h.blocks {
+		fmt.Fprintf(&list, "<Latest>%s</Latest>", id)
+	}
+	list.WriteString("</BlockList>")
+	return h.retry(func() error {
+		resp, err := h.do(http.MethodPut, h.blob, url.Values{"comp": {"blocklist"}},
+			list.Bytes())
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			return azError(resp, "while committing Azure block list")
+		}
+		return nil
+	})
+}
+
+// putBlock uploads one block of the blob. Blocks are uncommitted until Close, so a block
+// that fails mid-way can simply be sent again.
+func (h *azHandler) putBlock(block []byte) error {
+	// Block IDs must all have the same length within a blob.
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%010d", len(h.blocks))))
+	err := h.retry(func() error {
+		resp, err := h.do(http.MethodPut, h.blob,
+			url.Values{"comp": {"block"}, "blockid": {id}}, block)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			return azError(resp, "while uploading Azure block")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.blocks = append(h.blocks, id)
+	return nil
+}
+
+func (h *azHandler) retry(f func() error) error {
+	var err error
+	for i := 0; i < azMaxRetries; i++ {
+		if i > 0 {
+			glog.Warningf("Retrying Azure request for blob %q: %v", h.blob, err)
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		if err = f(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// List returns the relative paths of the blobs under the location ending in suffix.
+func (h *azHandler) List(uri *url.URL, suffix string) ([]string, error) {
+	if err := h.setup(uri); err != nil {
+		return nil, err
+	}
+
+	var prefix string
+	if h.prefix != "" {
+		prefix = h.prefix + "/"
+	}
+	var blobs []string
+	var marker string
+	for {
+		q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		resp, err := h.do(http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Blobs []struct {
+				Name string `xml:"Name"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = azError(resp, "while listing Azure blobs")
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&res)
+		}
+		x.Ignore(resp.Body.Close())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blob := range res.Blobs {
+			if strings.HasSuffix(blob.Name, suffix) {
+				blobs = append(blobs, strings.TrimPrefix(blob.Name, prefix))
+			}
+		}
+		if res.NextMarker == "" {
+			break
+		}
+		marker = res.NextMarker
+	}
+	sort.Strings(blobs)
+	return blobs, nil
+}
+
+// Read returns a reader for the blob at p, relative to the location.
+func (h *azHandler) Read(uri *url.URL, p string) (io.ReadCloser, int64, error) {
+	if err := h.setup(uri); err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := h.do(http.MethodGet, path.Join(h.prefix, p), nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, azError(resp, "while downloading Azure blob")
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Delete deletes the blob at p, relative to the location.
+func (h *azHandler) Delete(uri *url.URL, p string) error {
+	if err := h.setup(uri); err != nil {
+		return err
+	}
+
+	resp, err := h.do(http.MethodDelete, path.Join(h.prefix, p), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return azError(resp, "while deleting Azure blob")
+	}
+	return nil
+}
+
+// azError builds an error from a failed Azure response, including its body.
+func azError(resp *http.Response, msg string) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return x.Errorf("Error %s: %s %s", msg, resp.Status, bytes.TrimSpace(body))
+}
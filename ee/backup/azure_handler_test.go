@@ -0,0 +1,195 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAzure implements the parts of the Blob service REST API used by azHandler.
+type fakeAzure struct {
+	sync.Mutex
+	sas    string
+	blobs  map[string][]byte
+	blocks map[string][]byte
+	// failPut makes the PUT request with this sequence number fail.
+	failPut int
+	puts    int
+}
+
+func (s *fakeAzure) authorized(r *http.Request) bool {
+	if s.sas != "" {
+		return r.URL.Query().Get("sig") == s.sas
+	}
+	return r.Header.Get("Authorization") == "Bearer token"
+}
+
+func (s *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.URL.Path == "/identity" {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token": "token", "expires_in": "3600"}`))
+		return
+	}
+	if !s.authorized(r) || r.Header.Get("x-ms-version") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// Paths look like /container/blob.
+	blob := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPut && q.Get("comp") == "block":
+		s.puts++
+		if s.puts == s.failPut {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.blocks[blob[1]+q.Get("blockid")], _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data bytes.Buffer
+		for _, id := range list.Latest {
+			data.Write(s.blocks[blob[1]+id])
+		}
+		s.blobs[blob[1]] = data.Bytes()
+		w.WriteHeader(http.StatusCreated)
+
+	case r.Method == http.MethodGet && q.Get("comp") == "list":
+		// Return one blob per page to exercise pagination.
+		var names []string
+		for name := range s.blobs {
+			if strings.HasPrefix(name, q.Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var i int
+		if marker := q.Get("marker"); marker != "" {
+			fmt.Sscanf(marker, "%d", &i)
+		}
+		w.Write([]byte("<EnumerationResults><Blobs>"))
+		if i < len(names) {
+			fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", names[i])
+		}
+		w.Write([]byte("</Blobs>"))
+		if i+1 < len(names) {
+			fmt.Fprintf(w, "<NextMarker>%d</NextMarker>", i+1)
+		}
+		w.Write([]byte("</EnumerationResults>"))
+
+	case r.Method == http.MethodDelete:
+		if _, ok := s.blobs[blob[1]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.blobs, blob[1])
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodGet:
+		data, ok := s.blobs[blob[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func testAzureBackupRestore(t *testing.T, az *fakeAzure) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	srv := httptest.NewServer(az)
+	defer srv.Close()
+	defer func(s string) { azIdentityEndpoint = s }(azIdentityEndpoint)
+	azIdentityEndpoint = srv.URL + "/identity"
+	// Use tiny blocks so the upload spans many requests.
+	defer func(n int) { azBlockSize = n }(azBlockSize)
+	azBlockSize = 64
+
+	location := fmt.Sprintf("azblob://%s/container/dgraph?secure=false",
+		strings.TrimPrefix(srv.URL, "http://"))
+	writeBackup(t, location, "20181106.011302", 0, 10,
+		testKVs("name", 5), testKVs("age", 3))
+	require.Len(t, az.blobs, 3)
+	require.Contains(t, az.blobs, "dgraph/dgraph.20181106.011302/r10-g1.backup")
+	require.Contains(t, az.blobs, "dgraph/dgraph.20181106.011302/manifest.json")
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, location, 0))
+	for i, expected := range []*pb.KVS{testKVs("name", 5), testKVs("age", 3)} {
+		got := readKVs(t, filepath.Join(pdir, fmt.Sprintf("p%d", i+1)))
+		require.Len(t, got, len(expected.Kv))
+		for _, kv := range expected.Kv {
+			require.Equal(t, kv, got[string(kv.Key)])
+		}
+	}
+
+	// Pruning deletes the blobs of the older backup of the day.
+	writeBackup(t, location, "20181106.021302", 0, 20, testKVs("name", 5))
+	require.NoError(t, runPrune(&pruneOptions{location: location, keepDaily: 1}, ioutil.Discard))
+	require.Len(t, az.blobs, 2)
+	require.Contains(t, az.blobs, "dgraph/dgraph.20181106.021302/manifest.json")
+}
+
+func TestAzureManagedIdentity(t *testing.T) {
+	testAzureBackupRestore(t, &fakeAzure{
+		blobs:   make(map[string][]byte),
+		blocks:  make(map[string][]byte),
+		failPut: 2,
+	})
+}
+
+func TestAzureSASToken(t *testing.T) {
+	require.NoError(t, os.Setenv("AZURE_STORAGE_SAS_TOKEN", "?sv=2017-11-09&sig=secret"))
+	defer os.Unsetenv("AZURE_STORAGE_SAS_TOKEN")
+	testAzureBackupRestore(t, &fakeAzure{
+		sas:    "secret",
+		blobs:  make(map[string][]byte),
+		blocks: make(map[string][]byte),
+	})
+}
@@ -13,6 +13,7 @@
 package backup
 
 import (
+	"bytes"
 	"context"
 
 	"github.com/dgraph-io/badger"
@@ -26,23 +27,54 @@ import (
 
 // Request has all the information needed to perform a backup.
 type Request struct {
-	DB     *badger.DB // Badger pstore managed by this node.
-	Sizex  uint64     // approximate upload size
+	DB     *badger.DB   // Badger pstore managed by this node.
+	Sizex  uint64       // approximate upload size
+	Key    []byte       // AES key to encrypt the backup with, nil to not encrypt it.
+	Limit  *RateLimiter // limits the upload rate of the backup, nil to not limit it.
 	Backup *pb.BackupRequest
 }
 
 // Process uses the request values to create a stream writer then hand off the data
 // retrieval to stream.Orchestrate. The writer will create all the fd's needed to
 // collect the data and later move to the target.
-// Returns errors on failure, nil on success.
-func (r *Request) Process(ctx context.Context) error {
+// Returns the checksum of the backup file, the predicates in it and its encryption on success.
+func (r *Request) Process(ctx context.Context) (*pb.Status, error) {
 	w, err := r.newWriter()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	sl := stream.Lists{Stream: w, DB: r.DB}
-	sl.ChooseKeyFunc = nil
+	// A full backup is sent in key order, see Manifest.Ordered.
+	sl := stream.Lists{Stream: w, DB: r.DB, Ordered: r.Backup.SinceTs == 0}
+	since := r.Backup.SinceTs
+	preds := newPredicateSet(nil, r.Backup.PredicatePrefixes)
+	skipIndexes := r.Backup.SkipIndexes
+	// Incremental backup: only the schema and the keys changed after the previous backup are sent.
+	// With predicate prefixes, only the keys of the predicates starting with them.
+	// Without indexes, only the data and schema keys, restore rebuilds the rest.
+	sl.ChooseKeyFunc = func(item *badger.Item) bool {
+		// The schema keys are always written at version 1, so an incremental backup sends all
+		// of them, or it would miss the schema changes made after the previous backup.
+		isSchema := bytes.HasPrefix(item.Key(), x.SchemaPrefix())
+		if item.Version() <= since && !isSchema {
+			return false
+		}
+		// A full backup skips the deleted keys. An incremental one sends the deleted posting
+		// lists as empty ones, see ItemToKVFunc, but there's no empty schema to send.
+		if item.IsDeletedOrExpired() && (since == 0 || isSchema) {
+			return false
+		}
+		// The replicated ts, the progress of a snapshot and the reindex markers are about this
+		// cluster, not about its data. The drops are written before the data, see writeDrops.
+		pk := x.Parse(item.Key())
+		if pk == nil || !pk.IsPredicate() {
+			return false
+		}
+		if skipIndexes && (pk.IsIndex() || pk.IsReverse() || pk.IsCount()) {
+			return false
+		}
+		return preds.has(pk.Attr)
+	}
 	sl.ItemToKVFunc = func(key []byte, itr *badger.Iterator) (*pb.KV, error) {
 		item := itr.Item()
 		pk := x.Parse(key)
@@ -59,21 +91,74 @@ func (r *Request) Process(ctx context.Context) error {
 			}
 			return kv, nil
 		}
+		version := item.Version()
 		l, err := posting.ReadPostingList(key, itr)
 		if err != nil {
 			return nil, err
 		}
-		return l.MarshalToKv()
+		kv, err := l.MarshalToKv()
+		if err != nil {
+			return nil, err
+		}
+		// The rolled up list has the version of its latest posting, which is older than the
+		// latest write if that one deleted postings, and zero if the key was deleted. The
+		// restore would keep the data of the previous backups then, written at later versions.
+		kv.Version = version
+		return kv, nil
 	}
 
 	glog.V(2).Infof("Backup started ...")
+	if err = r.writeDrops(w, preds); err != nil {
+		return nil, err
+	}
 	if err = sl.Orchestrate(ctx, "Backup:", r.Backup.ReadTs); err != nil {
-		return err
+		return nil, err
 	}
 	if err = w.flush(); err != nil {
-		return err
+		return nil, err
 	}
 	glog.Infof("Backup complete: group %d at %d", r.Backup.GroupId, r.Backup.ReadTs)
 
-	return nil
+	resp := &pb.Status{Checksum: w.checksum(), Predicates: w.predicates()}
+	if w.enc != nil {
+		resp.Encryption = encryptionAESGCM
+	}
+	return resp, nil
+}
+
+// writeDrops writes the drops recorded after the previous backup, see x.DropKey, for the
+// restore to delete the data dropped before it loads the data of this backup. A full backup has
+// nothing to delete. The drops of all the data are always written, the drops of predicates only
+// if they're in preds.
+func (r *Request) writeDrops(w *writer, preds *predicateSet) error {
+	if r.Backup.SinceTs == 0 {
+		return nil
+	}
+	txn := r.DB.NewTransactionAt(r.Backup.ReadTs, false)
+	defer txn.Discard()
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = x.DropPrefix()
+	opt.PrefetchValues = false
+	itr := txn.NewIterator(opt)
+	defer itr.Close()
+
+	kvs := &pb.KVS{}
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		item := itr.Item()
+		if item.Version() <= r.Backup.SinceTs {
+			continue
+		}
+		if pk := x.Parse(item.Key()); pk.Attr != "" && !preds.has(pk.Attr) {
+			continue
+		}
+		kvs.Kv = append(kvs.Kv, &pb.KV{
+			Key:      item.KeyCopy(nil),
+			UserMeta: []byte{item.UserMeta()},
+			Version:  item.Version(),
+		})
+	}
+	if len(kvs.Kv) == 0 {
+		return nil
+	}
+	return w.Send(kvs)
 }
@@ -0,0 +1,334 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupPredicatePrefixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bo := badger.DefaultOptions
+	bo.Dir = filepath.Join(dir, "p")
+	bo.ValueDir = bo.Dir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	defer db.Close()
+
+	pl := &pb.PostingList{Postings: []*pb.Posting{{Uid: 1}}}
+	val, err := pl.Marshal()
+	require.NoError(t, err)
+	txn := db.NewTransactionAt(5, true)
+	for _, attr := range []string{"acme.name", "acme.age", "globex.name"} {
+		require.NoError(t, txn.SetWithMeta(x.DataKey(attr, 1), val, posting.BitCompletePosting))
+	}
+	require.NoError(t, txn.CommitAt(5, nil))
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	req := &Request{DB: db, Backup: &pb.BackupRequest{
+		ReadTs:            10,
+		GroupId:           1,
+		UnixTs:            "20181106.011302",
+		Target:            bdir,
+		PredicatePrefixes: []string{"acme."},
+	}}
+	resp, err := req.Process(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme.age", "acme.name"}, resp.Predicates)
+
+	// Without prefixes, all the predicates are backed up.
+	req.Backup.UnixTs = "20181106.021302"
+	req.Backup.PredicatePrefixes = nil
+	resp, err = req.Process(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"acme.age", "acme.name", "globex.name"}, resp.Predicates)
+}
+
+func TestBackupSkipIndexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bo := badger.DefaultOptions
+	bo.Dir = filepath.Join(dir, "p")
+	bo.ValueDir = bo.Dir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	defer db.Close()
+
+	pl := &pb.PostingList{Postings: []*pb.Posting{{Uid: 1}}}
+	val, err := pl.Marshal()
+	require.NoError(t, err)
+	keys := [][]byte{
+		x.DataKey("friend", 1),
+		x.IndexKey("friend", "\x02alice"),
+		x.ReverseKey("friend", 1),
+		x.CountKey("friend", 1, false),
+	}
+	txn := db.NewTransactionAt(5, true)
+	for _, key := range keys {
+		require.NoError(t, txn.SetWithMeta(key, val, posting.BitCompletePosting))
+	}
+	require.NoError(t, txn.CommitAt(5, nil))
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	req := &Request{DB: db, Backup: &pb.BackupRequest{
+		ReadTs:  10,
+		GroupId: 1,
+		Target:  bdir,
+	}}
+	for i, skip := range []bool{false, true} {
+		req.Backup.UnixTs = fmt.Sprintf("20181106.0%d1302", i+1)
+		req.Backup.SkipIndexes = skip
+		_, err := req.Process(context.Background())
+		require.NoError(t, err)
+		b, err := ioutil.ReadFile(filepath.Join(bdir, backupDir(req.Backup.UnixTs),
+			backupName(10, 1)))
+		require.NoError(t, err)
+		kvs, err := readAll(b)
+		require.NoError(t, err)
+		if !skip {
+			require.Len(t, kvs, len(keys))
+			continue
+		}
+		// Only the data key is left.
+		require.Len(t, kvs, 1)
+		require.Equal(t, keys[0], kvs[0].Key)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := Create("file://"+dir, "dgraph.r10.u1106.0113/g01.manifest.json")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`{"group": 1}`))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	b, err := ioutil.ReadFile(filepath.Join(dir, "dgraph.r10.u1106.0113", "g01.manifest.json"))
+	require.NoError(t, err)
+	require.Equal(t, `{"group": 1}`, string(b))
+
+	_, err = Create("ftp://host/exports", "g01.manifest.json")
+	require.Error(t, err)
+}
+
+// backupDB backs up db as group 1 with Process, followed by its manifest.
+func backupDB(t *testing.T, db *badger.DB, target, unixTs string, since, readTs uint64) {
+	req := &Request{DB: db, Backup: &pb.BackupRequest{
+		ReadTs:  readTs,
+		SinceTs: since,
+		GroupId: 1,
+		UnixTs:  unixTs,
+		Target:  target,
+	}}
+	resp, err := req.Process(context.Background())
+	require.NoError(t, err)
+	m := &Manifest{
+		Version:    x.Version(),
+		Since:      since,
+		ReadTs:     readTs,
+		Groups:     []uint32{1},
+		Checksums:  map[uint32]string{1: resp.Checksum},
+		Predicates: map[uint32][]string{1: resp.Predicates},
+		Ordered:    since == 0,
+	}
+	require.NoError(t, WriteManifest(target, unixTs, m))
+}
+
+func TestBackupIncrementalDeletes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bo := badger.DefaultOptions
+	bo.Dir = filepath.Join(dir, "p")
+	bo.ValueDir = bo.Dir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	defer db.Close()
+
+	set := func(key []byte, pl *pb.PostingList, meta byte, ts uint64) {
+		val, err := pl.Marshal()
+		require.NoError(t, err)
+		txn := db.NewTransactionAt(ts, true)
+		require.NoError(t, txn.SetWithMeta(key, val, meta))
+		require.NoError(t, txn.CommitAt(ts, nil))
+	}
+	edge := func(uid uint64, op uint32) *pb.PostingList {
+		return &pb.PostingList{Postings: []*pb.Posting{{Uid: uid, Op: op}}}
+	}
+	friend, name := x.DataKey("friend", 1), x.DataKey("name", 1)
+	full := &pb.PostingList{Pack: codec.Encode([]uint64{1}, 256)}
+	set(friend, full, posting.BitCompletePosting, 5)
+	set(name, full, posting.BitCompletePosting, 5)
+	setSchema := func(su *pb.SchemaUpdate) []byte {
+		val, err := su.Marshal()
+		require.NoError(t, err)
+		txn := db.NewTransactionAt(1, true)
+		require.NoError(t, txn.SetWithMeta(x.SchemaKey(su.Predicate), val,
+			posting.BitSchemaPosting))
+		require.NoError(t, txn.CommitAt(1, nil))
+		return val
+	}
+	setSchema(&pb.SchemaUpdate{Predicate: "friend", ValueType: pb.Posting_DEFAULT})
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	backupDB(t, db, bdir, "20181106.011302", 0, 10)
+
+	// The second backup has the new edge, the third one its deletion. The rolled up list of
+	// the third backup only has the edge of the first one, committed at 5.
+	set(friend, edge(2, posting.Set), posting.BitDeltaPosting, 12)
+	backupDB(t, db, bdir, "20181106.021302", 10, 20)
+	set(friend, edge(2, posting.Del), posting.BitDeltaPosting, 25)
+	txn := db.NewTransactionAt(25, true)
+	require.NoError(t, txn.Delete(name))
+	require.NoError(t, txn.CommitAt(25, nil))
+	// The schema changed after the full backup, its key keeps version 1.
+	su := setSchema(&pb.SchemaUpdate{Predicate: "friend", ValueType: pb.Posting_UID})
+	backupDB(t, db, bdir, "20181106.031302", 20, 30)
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+
+	var pl pb.PostingList
+	require.NoError(t, pl.Unmarshal(got[string(friend)].Val))
+	require.Equal(t, []uint64{1}, codec.Decode(pl.Pack, 0))
+	require.Equal(t, uint64(25), got[string(friend)].Version)
+	require.Equal(t, []byte{posting.BitEmptyPosting}, got[string(name)].UserMeta)
+	require.Equal(t, su, got[string(x.SchemaKey("friend"))].Val)
+}
+
+func TestBackupIncrementalDrops(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bo := badger.DefaultOptions
+	bo.Dir = filepath.Join(dir, "p")
+	bo.ValueDir = bo.Dir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	defer db.Close()
+
+	val, err := (&pb.PostingList{Pack: codec.Encode([]uint64{1}, 256)}).Marshal()
+	require.NoError(t, err)
+	set := func(key []byte, ts uint64) {
+		txn := db.NewTransactionAt(ts, true)
+		require.NoError(t, txn.SetWithMeta(key, val, posting.BitCompletePosting))
+		require.NoError(t, txn.CommitAt(ts, nil))
+	}
+	// drop deletes the keys at their versions, like posting.DeletePredicate, and records the
+	// drop at ts.
+	drop := func(attr string, ts uint64, keys ...[]byte) {
+		for _, key := range keys {
+			txn := db.NewTransactionAt(math.MaxUint64, false)
+			item, err := txn.Get(key)
+			require.NoError(t, err)
+			version := item.Version()
+			txn.Discard()
+			txn = db.NewTransactionAt(version, true)
+			require.NoError(t, txn.Delete(key))
+			require.NoError(t, txn.CommitAt(version, nil))
+		}
+		txn := db.NewTransactionAt(ts, true)
+		require.NoError(t, txn.Set(x.DropKey(attr), nil))
+		require.NoError(t, txn.CommitAt(ts, nil))
+	}
+	for uid := uint64(1); uid <= 3; uid++ {
+		set(x.DataKey("name", uid), 5)
+		set(x.DataKey("age", uid), 5)
+	}
+	set(x.IndexKey("name", "\x02alice"), 5)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	backupDB(t, db, bdir, "20181106.011302", 0, 10)
+
+	// The full backup is in key order.
+	full, err := ioutil.ReadFile(filepath.Join(bdir, backupDir("20181106.011302"),
+		backupName(10, 1)))
+	require.NoError(t, err)
+	fullKVs, err := readAll(full)
+	require.NoError(t, err)
+	require.Len(t, fullKVs, 7)
+	require.True(t, sort.SliceIsSorted(fullKVs, func(i, j int) bool {
+		return bytes.Compare(fullKVs[i].Key, fullKVs[j].Key) < 0
+	}))
+
+	// The name predicate is dropped, then set again for one of the UIDs.
+	drop("name", 15, x.DataKey("name", 1), x.DataKey("name", 2), x.DataKey("name", 3),
+		x.IndexKey("name", "\x02alice"))
+	set(x.DataKey("name", 2), 17)
+	backupDB(t, db, bdir, "20181106.021302", 10, 20)
+
+	// The drop is written before the data.
+	b, err := ioutil.ReadFile(filepath.Join(bdir, backupDir("20181106.021302"),
+		backupName(20, 1)))
+	require.NoError(t, err)
+	kvs, err := readAll(b)
+	require.NoError(t, err)
+	require.Equal(t, x.DropKey("name"), kvs[0].Key)
+	require.Equal(t, uint64(15), kvs[0].Version)
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 4)
+	require.Contains(t, got, string(x.DataKey("name", 2)))
+	for uid := uint64(1); uid <= 3; uid++ {
+		require.Contains(t, got, string(x.DataKey("age", uid)))
+	}
+
+	// A drop of all the data.
+	drop("", 25, x.DataKey("name", 2), x.DataKey("age", 1), x.DataKey("age", 2),
+		x.DataKey("age", 3))
+	set(x.DataKey("age", 4), 27)
+	backupDB(t, db, bdir, "20181106.031302", 20, 30)
+
+	pdir = filepath.Join(dir, "postings-all")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+	got = readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 1)
+	require.Contains(t, got, string(x.DataKey("age", 4)))
+
+	// Restored up to the second backup, the drop of all the data is skipped.
+	pdir = filepath.Join(dir, "postings-20")
+	require.NoError(t, restore(t, pdir, bdir, 20))
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p1")), 4)
+}
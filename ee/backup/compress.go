@@ -0,0 +1,80 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// compressionGzip is the name of the gzip codec, as given in backup requests and recorded
+// in the manifest. An empty name means the backup isn't compressed.
+const compressionGzip = "gzip"
+
+// compressionDetect is the codec of a file without a manifest to record it, like the one read
+// from stdin without --compression. It's told from the first bytes of the file.
+const compressionDetect = "detect"
+
+// gzipMagic are the first bytes of a gzip stream: its ID and the deflate method.
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// CheckCompression returns an error if name isn't a supported compression codec.
+func CheckCompression(name string) error {
+	switch name {
+	case "", "none", compressionGzip:
+		return nil
+	}
+	return x.Errorf("Unsupported compression %q. Valid values are gzip and none.", name)
+}
+
+// newCompressor returns a writer that compresses the data written to it with codec name
+// before writing it to w. It returns nil if name doesn't need any compression.
+func newCompressor(name string, w io.Writer) (io.WriteCloser, error) {
+	if err := CheckCompression(name); err != nil {
+		return nil, err
+	}
+	if name == compressionGzip {
+		return gzip.NewWriterLevel(w, gzip.BestSpeed)
+	}
+	return nil, nil
+}
+
+// newDecompressor returns a reader that decompresses the data read from r with codec name.
+// With compressionDetect, the codec is told from the first bytes read.
+func newDecompressor(name string, r io.Reader) (io.Reader, error) {
+	switch name {
+	case compressionDetect:
+		// Only gzip can be told apart, the files that aren't are read as they are.
+		br := bufio.NewReader(r)
+		if b, _ := br.Peek(len(gzipMagic)); !bytes.Equal(b, gzipMagic) {
+			return br, nil
+		}
+		return gzip.NewReader(br)
+	case compressionGzip:
+		return gzip.NewReader(r)
+	}
+	if err := CheckCompression(name); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// plainFile returns whether the bytes of the file f are its KVs, so that they can be mapped or
+// seeked to, neither encrypted nor compressed.
+func plainFile(f *loadFile) bool {
+	return f.encryption == "" && (f.compression == "" || f.compression == "none")
+}
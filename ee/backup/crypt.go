@@ -0,0 +1,216 @@
+//go:build !oss
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// encryptionAESGCM is the name of the cipher of encrypted backups, as recorded in the manifest.
+const encryptionAESGCM = "aes-gcm"
+
+// chunkSize is the size of the plaintext chunks that are sealed one at a time, so that
+// restore can decrypt the files as they are read.
+const chunkSize = 64 << 10
+
+// An encrypted backup file is a sequence of chunks, each one written as:
+//   uint32 little-endian length of the sealed chunk
+//   nonce (12 bytes)
+//   sealed chunk
+// The additional data of each chunk is its index and whether it's the last one, so chunks
+// can't be reordered, dropped or truncated without failing the authentication. The last
+// chunk is always written, even if it's empty.
+
+// ReadKeyFile reads an AES key from file. The key must be 16, 24 or 32 bytes long, to use
+// AES-128, AES-192 or AES-256.
+func ReadKeyFile(file string) ([]byte, error) {
+	key, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, x.Wrapf(err, "while reading encryption key from %q", file)
+	}
+	if _, err := newAEAD(key); err != nil {
+		return nil, x.Wrapf(err, "invalid encryption key in %q", file)
+	}
+	return key, nil
+}
+
+// checkEncryption returns an error if the backups of chain don't match the key given to read
+// them: some are encrypted but key isn't set, or key is set but none of them is encrypted. A
+// chain can mix encrypted and plain backups, if encryption was turned on or off along it.
+func checkEncryption(chain []*Manifest, key []byte) error {
+	var encrypted bool
+	for _, m := range chain {
+		switch {
+		case m.Encryption == "":
+			continue
+		case m.Encryption != encryptionAESGCM:
+			return x.Errorf("Backup %q is encrypted with %q, which is not supported.",
+				path.Dir(m.path), m.Encryption)
+		case key == nil:
+			return x.Errorf("Backup %q is encrypted, its key must be given with "+
+				"--encryption_key_file.", path.Dir(m.path))
+		}
+		encrypted = true
+	}
+	if key != nil && len(chain) > 0 && !encrypted {
+		return x.Errorf("The backups aren't encrypted, but a key was given with " +
+			"--encryption_key_file. Read them without it.")
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkData returns the additional data authenticated with chunk n.
+func chunkData(n uint64, last bool) []byte {
+	var ad [9]byte
+	binary.BigEndian.PutUint64(ad[:8], n)
+	if last {
+		ad[8] = 1
+	}
+	return ad[:]
+}
+
+// encryptWriter seals the data written to it in chunks and writes them to w.
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	buf  []byte
+	n    uint64 // index of the next chunk
+}
+
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (ew *encryptWriter) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], b)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		b = b[n:]
+		written += n
+		// The last chunk is only sealed by Close, so don't seal a full chunk until more
+		// data comes in.
+		if len(ew.buf) == cap(ew.buf) && len(b) > 0 {
+			if err := ew.seal(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close seals the last chunk. It doesn't close the underlying writer.
+func (ew *encryptWriter) Close() error {
+	return ew.seal(true)
+}
+
+func (ew *encryptWriter) seal(last bool) error {
+	nonce := make([]byte, ew.aead.NonceSize(), ew.aead.NonceSize()+len(ew.buf)+ew.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := ew.aead.Seal(nonce, nonce, ew.buf, chunkData(ew.n, last))
+	if err := binary.Write(ew.w, binary.LittleEndian, uint32(len(sealed))); err != nil {
+		return err
+	}
+	if _, err := ew.w.Write(sealed); err != nil {
+		return err
+	}
+	ew.buf = ew.buf[:0]
+	ew.n++
+	return nil
+}
+
+// decryptReader reads the chunks written by an encryptWriter from r and opens them.
+type decryptReader struct {
+	r    io.Reader
+	aead cipher.AEAD
+	buf  []byte // plaintext not read yet
+	n    uint64 // index of the next chunk
+	done bool   // the last chunk was read
+}
+
+func newDecryptReader(r io.Reader, key []byte) (*decryptReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: r, aead: aead}, nil
+}
+
+func (dr *decryptReader) Read(b []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
+
+func (dr *decryptReader) open() error {
+	var sz uint32
+	if err := binary.Read(dr.r, binary.LittleEndian, &sz); err != nil {
+		if err == io.EOF {
+			// The last chunk is always written, so the file was truncated.
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	ns := dr.aead.NonceSize()
+	if int(sz) < ns+dr.aead.Overhead() || sz > chunkSize+uint32(ns+dr.aead.Overhead()) {
+		return x.Errorf("Invalid encrypted chunk size %d", sz)
+	}
+	sealed := make([]byte, sz)
+	if _, err := io.ReadFull(dr.r, sealed); err != nil {
+		return err
+	}
+	// Try the chunk as a middle one first, it's the most common case.
+	nonce, sealed := sealed[:ns], sealed[ns:]
+	buf, err := dr.aead.Open(nil, nonce, sealed, chunkData(dr.n, false))
+	if err != nil {
+		buf, err = dr.aead.Open(nil, nonce, sealed, chunkData(dr.n, true))
+		if err != nil {
+			return x.Errorf("Unable to decrypt chunk %d: wrong key or corrupted backup", dr.n)
+		}
+		dr.done = true
+	}
+	dr.buf = buf
+	dr.n++
+	return nil
+}
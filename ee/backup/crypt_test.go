@@ -0,0 +1,84 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func encrypt(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	ew, err := newEncryptWriter(&buf, testKey)
+	require.NoError(t, err)
+	// Write in odd-sized pieces to cross the chunk boundaries.
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err := ew.Write(data[:n])
+		require.NoError(t, err)
+		data = data[n:]
+	}
+	require.NoError(t, ew.Close())
+	return buf.Bytes()
+}
+
+func decrypt(data []byte) ([]byte, error) {
+	dr, err := newDecryptReader(bytes.NewReader(data), testKey)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 7} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+
+		got, err := decrypt(encrypt(t, data))
+		require.NoError(t, err, "size %d", size)
+		require.Equal(t, data, got, "size %d", size)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	data := make([]byte, 2*chunkSize+100)
+	enc := encrypt(t, data)
+
+	// Dropping the last chunk is detected.
+	full := chunkSize + 4 + 12 + 16
+	_, err := decrypt(enc[:2*full])
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// So is swapping chunks.
+	swapped := append(append(append([]byte{}, enc[full:2*full]...), enc[:full]...), enc[2*full:]...)
+	_, err = decrypt(swapped)
+	require.Error(t, err)
+
+	// And flipping a bit.
+	enc[100] ^= 1
+	_, err = decrypt(enc)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wrong key or corrupted")
+}
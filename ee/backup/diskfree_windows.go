@@ -0,0 +1,19 @@
+// +build !oss,windows
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import "github.com/pkg/errors"
+
+func diskFree(dir string) (uint64, error) {
+	return 0, errors.New("cannot detect free disk space on this platform")
+}
@@ -0,0 +1,549 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/dgraph-io/dgraph/types/facets"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+// rdfTypes are the RDF types of the values written by an RDF export, by their Dgraph type.
+var rdfTypes = map[types.TypeID]string{
+	types.StringID:   "xs:string",
+	types.DateTimeID: "xs:dateTime",
+	types.IntID:      "xs:int",
+	types.FloatID:    "xs:float",
+	types.BoolID:     "xs:boolean",
+	types.GeoID:      "geo:geojson",
+	types.BinaryID:   "xs:base64Binary",
+	types.PasswordID: "xs:password",
+}
+
+// runExport restores the backups as runRestore does, then exports the restored data of each
+// group to o.out in the format of o.exportTo, "rdf" or "json", in the format of the exports
+// taken by the Alphas: g01.rdf.gz or g01.json.gz with the data of group 1, and g01.schema.gz
+// with its schema. The UIDs are written as blank nodes, _:uid1 for UID 0x1, so the files can
+// be loaded into any cluster. The data is restored into the exportRestoreDir directory under
+// o.out, which is removed once the export is done.
+// The files are written under a temporary name and renamed once complete, and the progress of
+// the export is saved as it goes, see exportCheckpoints. With o.resume, an export that died
+// continues from there: the restore is skipped if it was done, or resumed, and the files of
+// the groups continue from their last checkpoint.
+func runExport(o *restoreOptions, p *progress) error {
+	if o.exportTo != "rdf" && o.exportTo != "json" {
+		return x.Errorf("Invalid export format %q, it must be rdf or json.", o.exportTo)
+	}
+	if err := os.MkdirAll(o.out, 0700); err != nil {
+		return err
+	}
+	ecp, err := openExportCheckpoints(o)
+	if err != nil {
+		return err
+	}
+	ro := *o
+	ro.pdir = filepath.Join(o.out, exportRestoreDir)
+	ro.roots = nil
+	_, err = os.Stat(ro.pdir)
+	switch {
+	case err == nil && !o.resume:
+		return x.Errorf("The export to %q didn't complete, use --resume to continue it, or "+
+			"remove %q to start over.", o.out, ro.pdir)
+	case os.IsNotExist(err) && ecp.Restored:
+		return x.Errorf("The data restored for the export to %q is missing from %q, remove "+
+			"%q to start over.", o.out, ro.pdir, ecp.path)
+	case err != nil && !os.IsNotExist(err):
+		return err
+	}
+
+	if ecp.Restored {
+		p.printf("Skipping the restore, it was done already\n")
+	} else {
+		if err := runRestore(&ro, p); err != nil {
+			return err
+		}
+		ecp.Restored = true
+		if err := ecp.save(); err != nil {
+			return err
+		}
+	}
+	dirs, err := filepath.Glob(filepath.Join(ro.pdir, "p*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		gid, err := strconv.ParseUint(strings.TrimPrefix(filepath.Base(dir), "p"), 10, 32)
+		if err != nil {
+			continue
+		}
+		gc := ecp.group(uint32(gid))
+		if gc.Done {
+			p.printf("Skipping group %d, it was exported already\n", gid)
+			continue
+		}
+		if gc.Records > 0 {
+			p.printf("Resuming the export of group %d after %d records\n", gid, gc.Records)
+		}
+		if err := exportGroup(dir, uint32(gid), o.exportTo, o.out, gc, ecp.save); err != nil {
+			return x.Wrapf(err, "while exporting group %d", gid)
+		}
+		p.printf("Exported group %d to: %s\n", gid, o.out)
+	}
+	if err := os.RemoveAll(ro.pdir); err != nil {
+		return err
+	}
+	return os.Remove(ecp.path)
+}
+
+// exportRestoreDir is the directory under the --out directory of an export the data is
+// restored into.
+const exportRestoreDir = "restore"
+
+// exportCheckpointName is the name of the file the checkpoints of an export are saved to, in
+// its --out directory.
+const exportCheckpointName = "export_progress.json"
+
+// exportTmpSuffix is the suffix of the files of an export until they're complete.
+const exportTmpSuffix = ".tmp"
+
+// groupCheckpoint is the progress of the export of a group.
+type groupCheckpoint struct {
+	Records      int64 `json:"records"`       // postings and schemas read from the group
+	Written      int64 `json:"written"`       // postings of them written to the data file
+	DataOffset   int64 `json:"data_offset"`   // where their data ends in the data file
+	SchemaOffset int64 `json:"schema_offset"` // and where their schemas end in its file
+	Done         bool  `json:"done"`          // whether the files of the group are complete
+}
+
+// exportCheckpoints is the progress of an export. The files of a group are saved every
+// checkpointKeys records, the postings and schemas read from its restored DB, which are
+// always read in the same order. An export that dies can continue from the last checkpoint
+// of each group with --resume: the files are cut back to the size they had then, the records
+// read already are skipped, and the rest are appended. Only the same export can be resumed,
+// the settings that change what's restored and the format are saved too.
+type exportCheckpoints struct {
+	path string
+
+	Settings string                      `json:"settings"`
+	Restored bool                        `json:"restored"` // the data is in exportRestoreDir
+	Groups   map[uint32]*groupCheckpoint `json:"groups"`
+}
+
+// openExportCheckpoints returns the checkpoints of the export of o into o.out. With o.resume,
+// the checkpoints saved by a previous export are read, if there are any.
+func openExportCheckpoints(o *restoreOptions) (*exportCheckpoints, error) {
+	c := &exportCheckpoints{
+		path:     filepath.Join(o.out, exportCheckpointName),
+		Settings: o.settings() + " export_to=" + o.exportTo,
+		Groups:   make(map[uint32]*groupCheckpoint),
+	}
+	if !o.resume {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved exportCheckpoints
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, x.Wrapf(err, "while reading the checkpoints in %q", c.path)
+	}
+	if saved.Settings != c.Settings {
+		return nil, x.Errorf("The export to %q was started with %s, it can't be resumed "+
+			"with %s", o.out, saved.Settings, c.Settings)
+	}
+	c.Restored = saved.Restored
+	if saved.Groups != nil {
+		c.Groups = saved.Groups
+	}
+	return c, nil
+}
+
+// group returns the checkpoint of group gid, which save saves as it's updated.
+func (c *exportCheckpoints) group(gid uint32) *groupCheckpoint {
+	gc, ok := c.Groups[gid]
+	if !ok {
+		gc = &groupCheckpoint{}
+		c.Groups[gid] = gc
+	}
+	return gc
+}
+
+// save saves the checkpoints.
+func (c *exportCheckpoints) save() error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	// The file is replaced at once, an export that dies while saving it keeps the old one.
+	tmp := c.path + exportTmpSuffix
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// exportWriter writes a gzip'd export file. The file is written under a temporary name,
+// and renamed once it's complete, so a file with the final name is never partial.
+type exportWriter struct {
+	path string
+	fd   *os.File
+	bw   *bufio.Writer
+	gw   *gzip.Writer
+}
+
+// newExportWriter returns a writer of the file path, which continues the temporary file
+// written before from offset, a checkpoint of it, if offset is set.
+func newExportWriter(path string, offset int64) (*exportWriter, error) {
+	flags := os.O_CREATE | os.O_WRONLY
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	fd, err := os.OpenFile(path+exportTmpSuffix, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if err := fd.Truncate(offset); err != nil {
+			fd.Close()
+			return nil, err
+		}
+		if _, err := fd.Seek(offset, io.SeekStart); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+	w := &exportWriter{path: path, fd: fd, bw: bufio.NewWriterSize(fd, 1e6)}
+	w.gw = gzip.NewWriter(w.bw)
+	return w, nil
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	return w.gw.Write(p)
+}
+
+// checkpoint writes the data written so far to disk, and returns the size of the file. The
+// gzip stream is completed, and the data written afterwards goes to another one appended to
+// it. A gzip file can be made of many streams, gzip readers read them as one.
+func (w *exportWriter) checkpoint() (int64, error) {
+	if err := w.gw.Close(); err != nil {
+		return 0, err
+	}
+	if err := w.bw.Flush(); err != nil {
+		return 0, err
+	}
+	if err := w.fd.Sync(); err != nil {
+		return 0, err
+	}
+	w.gw.Reset(w.bw)
+	return w.fd.Seek(0, io.SeekCurrent)
+}
+
+// Close completes the file, but keeps its temporary name, see finish. Once the file is
+// closed, it can be closed again to no effect.
+func (w *exportWriter) Close() error {
+	if w.fd == nil {
+		return nil
+	}
+	defer func() { w.fd = nil }()
+	if err := w.gw.Close(); err != nil {
+		w.fd.Close()
+		return err
+	}
+	if err := w.bw.Flush(); err != nil {
+		w.fd.Close()
+		return err
+	}
+	if err := w.fd.Sync(); err != nil {
+		w.fd.Close()
+		return err
+	}
+	return w.fd.Close()
+}
+
+// finish completes the file, and renames it to its final name.
+func (w *exportWriter) finish() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(w.path+exportTmpSuffix, w.path)
+}
+
+// exportGroup writes the data and schema in the posting directory dir of group gid to outDir,
+// in format, see runExport. The files continue from the checkpoint gc, which is updated and
+// saved with save every checkpointKeys records, and once the files are complete.
+func exportGroup(dir string, gid uint32, format, outDir string, gc *groupCheckpoint,
+	save func() error) error {
+	bo := badger.DefaultOptions
+	bo.Dir = dir
+	bo.ValueDir = dir
+	db, err := badger.OpenManaged(bo)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dataPath := filepath.Join(outDir, fmt.Sprintf("g%02d.%s.gz", gid, format))
+	schemaPath := filepath.Join(outDir, fmt.Sprintf("g%02d.schema.gz", gid))
+	// The files of a group that was renamed, but not saved as done, are written again.
+	for _, f := range []struct {
+		path   string
+		offset int64
+	}{{dataPath, gc.DataOffset}, {schemaPath, gc.SchemaOffset}} {
+		if fi, err := os.Stat(f.path + exportTmpSuffix); err != nil || fi.Size() < f.offset {
+			*gc = groupCheckpoint{}
+		}
+	}
+	data, err := newExportWriter(dataPath, gc.DataOffset)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	sch, err := newExportWriter(schemaPath, gc.SchemaOffset)
+	if err != nil {
+		return err
+	}
+	defer sch.Close()
+
+	// The records read before the checkpoint were written already.
+	var records int64
+	skip := func() bool {
+		records++
+		return records <= gc.Records
+	}
+	checkpoint := func() error {
+		if records%checkpointKeys != 0 {
+			return nil
+		}
+		dataOffset, err := data.checkpoint()
+		if err != nil {
+			return err
+		}
+		schemaOffset, err := sch.checkpoint()
+		if err != nil {
+			return err
+		}
+		gc.Records, gc.DataOffset, gc.SchemaOffset = records, dataOffset, schemaOffset
+		return save()
+	}
+
+	if format == "json" && gc.DataOffset == 0 {
+		data.Write([]byte("[\n"))
+	}
+	txn := db.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	iopt := badger.DefaultIteratorOptions
+	iopt.AllVersions = true
+	itr := txn.NewIterator(iopt)
+	defer itr.Close()
+	for itr.Rewind(); itr.Valid(); {
+		key := itr.Item().KeyCopy(nil)
+		pk := x.Parse(key)
+		if pk == nil {
+			return x.Errorf("Invalid key %q in restored data", key)
+		}
+		switch {
+		case pk.Attr == "_predicate_":
+		case pk.IsSchema():
+			// Schema keys are data keys too, they're handled first.
+			if skip() {
+				break
+			}
+			var su pb.SchemaUpdate
+			err := itr.Item().Value(func(val []byte) error {
+				return su.Unmarshal(val)
+			})
+			if err != nil {
+				return x.Wrapf(err, "while decoding schema of %q", pk.Attr)
+			}
+			if _, err := sch.Write([]byte(schema.Format(pk.Attr, su) + " .\n")); err != nil {
+				return err
+			}
+			if err := checkpoint(); err != nil {
+				return err
+			}
+		case pk.IsData():
+			pl, err := posting.ReadPostingList(key, itr)
+			if err != nil {
+				return err
+			}
+			err = pl.Iterate(math.MaxUint64, 0, func(p *pb.Posting) error {
+				if skip() {
+					return nil
+				}
+				var buf []byte
+				var err error
+				if format == "rdf" {
+					buf, err = toExportRDF(pk.Uid, pk.Attr, p)
+				} else {
+					buf, err = toExportJSON(pk.Uid, pk.Attr, p)
+					if gc.Written > 0 {
+						buf = append([]byte(",\n"), buf...)
+					}
+				}
+				if err != nil {
+					glog.Errorf("Export: skipping a posting of key %q: %v", key, err)
+					return checkpoint()
+				}
+				gc.Written++
+				if _, err := data.Write(buf); err != nil {
+					return err
+				}
+				return checkpoint()
+			})
+			if err != nil {
+				return err
+			}
+		}
+		// Skip the rest of the versions of the key.
+		for itr.Valid() && bytes.Equal(itr.Item().Key(), key) {
+			itr.Next()
+		}
+	}
+	if format == "json" {
+		data.Write([]byte("\n]\n"))
+	}
+	if err := data.finish(); err != nil {
+		return err
+	}
+	if err := sch.finish(); err != nil {
+		return err
+	}
+	gc.Done = true
+	return save()
+}
+
+// exportValue returns the value of posting p converted to its type, and to a string.
+func exportValue(p *pb.Posting) (types.Val, string, error) {
+	vID := types.TypeID(p.ValType)
+	src := types.ValueForType(vID)
+	src.Value = p.Value
+	str, err := types.Convert(src, types.StringID)
+	if err != nil {
+		return types.Val{}, "", err
+	}
+	val, err := types.Convert(types.Val{Tid: types.BinaryID, Value: p.Value}, vID)
+	if err != nil {
+		return types.Val{}, "", err
+	}
+	// Strings can be stored with a trailing null character.
+	return val, strings.TrimRight(str.Value.(string), "\x00"), nil
+}
+
+// toExportRDF returns the N-Quad of posting p of the posting list of uid and attr.
+func toExportRDF(uid uint64, attr string, p *pb.Posting) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<_:uid%x> <%s> ", uid, attr)
+	if p.PostingType == pb.Posting_REF {
+		fmt.Fprintf(&buf, "<_:uid%x>", p.Uid)
+	} else {
+		val, str, err := exportValue(p)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(str))
+		if p.PostingType == pb.Posting_VALUE_LANG {
+			buf.WriteByte('@')
+			buf.Write(p.LangTag)
+		} else if val.Tid != types.DefaultID {
+			rdfType, ok := rdfTypes[val.Tid]
+			if !ok {
+				return nil, x.Errorf("No RDF type for type %s", val.Tid.Name())
+			}
+			fmt.Fprintf(&buf, "^^<%s>", rdfType)
+		}
+	}
+	if len(p.Facets) > 0 {
+		buf.WriteString(" (")
+		for i, f := range p.Facets {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fVal, err := facets.ValFor(f)
+			if err != nil {
+				return nil, err
+			}
+			str := types.Val{Tid: types.StringID}
+			if err := types.Marshal(fVal, &str); err != nil {
+				return nil, err
+			}
+			if fVal.Tid == types.StringID {
+				fmt.Fprintf(&buf, "%s=%s", f.Key, strconv.Quote(str.Value.(string)))
+			} else {
+				fmt.Fprintf(&buf, "%s=%s", f.Key, str.Value.(string))
+			}
+		}
+		buf.WriteByte(')')
+	}
+	buf.WriteString(" .\n")
+	return buf.Bytes(), nil
+}
+
+// toExportJSON returns the JSON object of posting p of the posting list of uid and attr, as
+// it would be given in a JSON mutation. The facets of a UID posting are set in the object
+// of the UID it points to.
+func toExportJSON(uid uint64, attr string, p *pb.Posting) ([]byte, error) {
+	obj := map[string]interface{}{"uid": fmt.Sprintf("_:uid%x", uid)}
+	fobj := obj
+	if p.PostingType == pb.Posting_REF {
+		fobj = map[string]interface{}{"uid": fmt.Sprintf("_:uid%x", p.Uid)}
+		obj[attr] = fobj
+	} else {
+		val, str, err := exportValue(p)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{} = str
+		switch val.Tid {
+		case types.IntID, types.FloatID, types.BoolID:
+			v = val.Value
+		case types.GeoID:
+			v = json.RawMessage(str)
+		}
+		name := attr
+		if p.PostingType == pb.Posting_VALUE_LANG {
+			name += "@" + string(p.LangTag)
+		}
+		obj[name] = v
+	}
+	for _, f := range p.Facets {
+		fVal, err := facets.ValFor(f)
+		if err != nil {
+			return nil, err
+		}
+		fobj[attr+"|"+f.Key] = fVal.Value
+	}
+	return json.Marshal(obj)
+}
@@ -0,0 +1,219 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+// postingKV returns the KV of the complete posting list of attr and uid with postings,
+// committed at version.
+func postingKV(t *testing.T, attr string, uid, version uint64, postings ...*pb.Posting) *pb.KV {
+	enc := codec.Encoder{BlockSize: 10}
+	for _, p := range postings {
+		enc.Add(p.Uid)
+	}
+	pl := &pb.PostingList{Pack: enc.Done(), Postings: postings}
+	val, err := pl.Marshal()
+	require.NoError(t, err)
+	return &pb.KV{Key: x.DataKey(attr, uid), Val: val,
+		UserMeta: []byte{posting.BitCompletePosting}, Version: version}
+}
+
+func readGzip(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	age := func(v int64) []byte {
+		out := types.ValueForType(types.BinaryID)
+		require.NoError(t, types.Marshal(types.Val{Tid: types.IntID, Value: v}, &out))
+		return out.Value.([]byte)
+	}
+	su, err := (&pb.SchemaUpdate{Predicate: "age", ValueType: pb.Posting_INT}).Marshal()
+	require.NoError(t, err)
+	full := &pb.KVS{Kv: []*pb.KV{
+		postingKV(t, "age", 1, 2, &pb.Posting{Uid: math.MaxUint64, ValType: pb.Posting_INT,
+			Value: age(21), PostingType: pb.Posting_VALUE}),
+		postingKV(t, "age", 2, 3, &pb.Posting{Uid: math.MaxUint64, ValType: pb.Posting_INT,
+			Value: age(30), PostingType: pb.Posting_VALUE}),
+		postingKV(t, "friend", 1, 4, &pb.Posting{Uid: 2, PostingType: pb.Posting_REF,
+			Facets: []*api.Facet{{Key: "close", Value: []byte{1}, ValType: api.Facet_BOOL}}}),
+		{Key: x.SchemaKey("age"), Val: su, UserMeta: []byte{posting.BitSchemaPosting},
+			Version: 1},
+	}}
+	writeBackup(t, dir, "20181106.011302", 0, 10, full)
+	// The incremental backup changes the age of 0x1.
+	writeBackup(t, dir, "20181106.021302", 10, 20, &pb.KVS{Kv: []*pb.KV{
+		postingKV(t, "age", 1, 15, &pb.Posting{Uid: math.MaxUint64, ValType: pb.Posting_INT,
+			Value: age(22), PostingType: pb.Posting_VALUE}),
+	}})
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	out := filepath.Join(dir, "rdf")
+	o := &restoreOptions{location: dir, exportTo: "rdf", out: out}
+	require.NoError(t, runExport(o, p))
+	require.Equal(t, strings.Join([]string{
+		`<_:uid1> <age> "22"^^<xs:int> .`,
+		`<_:uid2> <age> "30"^^<xs:int> .`,
+		`<_:uid1> <friend> <_:uid2> (close=true) .`,
+	}, "\n")+"\n", readGzip(t, filepath.Join(out, "g01.rdf.gz")))
+	require.Equal(t, "age:int .\n", readGzip(t, filepath.Join(out, "g01.schema.gz")))
+	files, err := ioutil.ReadDir(out)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	p, err = newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	out = filepath.Join(dir, "json")
+	o = &restoreOptions{location: dir, exportTo: "json", out: out}
+	require.NoError(t, runExport(o, p))
+	var objs []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(readGzip(t, filepath.Join(out, "g01.json.gz"))),
+		&objs))
+	require.Equal(t, []map[string]interface{}{
+		{"uid": "_:uid1", "age": float64(22)},
+		{"uid": "_:uid2", "age": float64(30)},
+		{"uid": "_:uid1", "friend": map[string]interface{}{"uid": "_:uid2", "friend|close": true}},
+	}, objs)
+
+	o.exportTo = "xml"
+	require.Error(t, runExport(o, p))
+}
+
+func TestExportResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(n int64) { checkpointKeys = n }(checkpointKeys)
+	checkpointKeys = 3
+
+	su, err := (&pb.SchemaUpdate{Predicate: "name", ValueType: pb.Posting_STRING}).Marshal()
+	require.NoError(t, err)
+	kvs := &pb.KVS{Kv: []*pb.KV{{Key: x.SchemaKey("name"), Val: su,
+		UserMeta: []byte{posting.BitSchemaPosting}, Version: 1}}}
+	for uid := uint64(1); uid <= 10; uid++ {
+		kvs.Kv = append(kvs.Kv, postingKV(t, "name", uid, 2, &pb.Posting{Uid: math.MaxUint64,
+			ValType: pb.Posting_STRING, Value: []byte(fmt.Sprintf("name-%d", uid)),
+			PostingType: pb.Posting_VALUE}))
+	}
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	expected := filepath.Join(dir, "expected")
+	require.NoError(t, runExport(&restoreOptions{location: bdir, exportTo: "rdf",
+		out: expected}, p))
+
+	// The export died after its second checkpoint of group 1, once the data was restored.
+	out := filepath.Join(dir, "rdf")
+	o := &restoreOptions{location: bdir, exportTo: "rdf", out: out, resume: true}
+	require.NoError(t, runRestore(&restoreOptions{location: bdir,
+		pdir: filepath.Join(out, exportRestoreDir)}, p))
+	ecp, err := openExportCheckpoints(o)
+	require.NoError(t, err)
+	ecp.Restored = true
+	saves := 0
+	err = exportGroup(filepath.Join(out, exportRestoreDir, "p1"), 1, "rdf", out, ecp.group(1),
+		func() error {
+			if err := ecp.save(); err != nil {
+				return err
+			}
+			if saves++; saves == 2 {
+				return errors.New("killed")
+			}
+			return nil
+		})
+	require.Error(t, err)
+	// Only the temporary files were written.
+	_, err = os.Stat(filepath.Join(out, "g01.rdf.gz"))
+	require.True(t, os.IsNotExist(err))
+	data := filepath.Join(out, "g01.rdf.gz"+exportTmpSuffix)
+	fi, err := os.Stat(data)
+	require.NoError(t, err)
+	require.True(t, fi.Size() >= ecp.Groups[1].DataOffset)
+	require.Equal(t, int64(6), ecp.Groups[1].Records)
+	written, err := ioutil.ReadFile(data)
+	require.NoError(t, err)
+	written = written[:ecp.Groups[1].DataOffset]
+	// Some of the data written after the checkpoint made it to the file.
+	f, err := os.OpenFile(data, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("partial"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// It can't be restarted without --resume, nor resumed with other settings.
+	o.resume = false
+	err = runExport(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "use --resume")
+	o.resume, o.exportTo = true, "json"
+	_, err = openExportCheckpoints(o)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can't be resumed")
+	o.exportTo = "rdf"
+
+	var msgs bytes.Buffer
+	p, err = newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = &msgs
+	require.NoError(t, runExport(o, p))
+	require.Contains(t, msgs.String(), "Skipping the restore, it was done already\n")
+	require.Contains(t, msgs.String(), "Resuming the export of group 1 after 6 records\n")
+	// The data written before the checkpoint was kept, and the rest appended.
+	b, err := ioutil.ReadFile(filepath.Join(out, "g01.rdf.gz"))
+	require.NoError(t, err)
+	require.Equal(t, written, b[:len(written)])
+	for _, name := range []string{"g01.rdf.gz", "g01.schema.gz"} {
+		require.Equal(t, readGzip(t, filepath.Join(expected, name)),
+			readGzip(t, filepath.Join(out, name)))
+	}
+	files, err := ioutil.ReadDir(out)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
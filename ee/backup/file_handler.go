@@ -13,10 +13,11 @@
 package backup
 
 import (
-	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/dgraph-io/dgraph/x"
 
@@ -28,21 +29,18 @@ type fileHandler struct {
 	fp *os.File
 }
 
-// Open authenticates or prepares a handler session.
+// Create prepares the file at path, relative to the location, for writing.
 // Returns error on failure, nil on success.
-func (h *fileHandler) Open(uri *url.URL, req *Request) error {
+func (h *fileHandler) Create(uri *url.URL, path string) error {
 	// check that this path exists and we can access it.
 	if !h.exists(uri.Path) {
 		return x.Errorf("The path %q does not exist or it is inaccessible.", uri.Path)
 	}
 
-	dir := filepath.Join(uri.Path, fmt.Sprintf("dgraph.%s", req.Backup.UnixTs))
-	if err := os.Mkdir(dir, 0700); err != nil {
+	path = filepath.Join(uri.Path, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return err
 	}
-
-	path := filepath.Join(dir,
-		fmt.Sprintf("r%d-g%d.backup", req.Backup.ReadTs, req.Backup.GroupId))
 	fp, err := os.Create(path)
 	if err != nil {
 		return err
@@ -65,6 +63,58 @@ func (h *fileHandler) Write(b []byte) (int, error) {
 	return h.fp.Write(b)
 }
 
+// List walks the location path and returns the relative paths of the files ending in suffix.
+func (h *fileHandler) List(uri *url.URL, suffix string) ([]string, error) {
+	if !h.exists(uri.Path) {
+		return nil, x.Errorf("The path %q does not exist or it is inaccessible.", uri.Path)
+	}
+
+	var paths []string
+	err := filepath.Walk(uri.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, suffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(uri.Path, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+// Read opens the file at path, relative to the location.
+func (h *fileHandler) Read(uri *url.URL, path string) (io.ReadCloser, int64, error) {
+	fp, err := os.Open(filepath.Join(uri.Path, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := fp.Stat()
+	if err != nil {
+		x.Ignore(fp.Close())
+		return nil, 0, err
+	}
+	return fp, fi.Size(), nil
+}
+
+// Delete deletes the file at path, relative to the location. The directory of the file is
+// deleted too once it's empty.
+func (h *fileHandler) Delete(uri *url.URL, path string) error {
+	path = filepath.Join(uri.Path, filepath.FromSlash(path))
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != filepath.Clean(uri.Path) {
+		// Fails while the directory has other files.
+		x.Ignore(os.Remove(dir))
+	}
+	return nil
+}
+
 // Exists checks if a path (file or dir) is found at target.
 // Returns true if found, false otherwise.
 func (h *fileHandler) exists(path string) bool {
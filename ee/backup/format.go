@@ -0,0 +1,211 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// The KVs of a backup file are written inside its compression and encryption, in one of two
+// formats. Version 1 files are just the marshaled KVs, each one preceded by its length as a
+// little-endian uint64. Version 2 files start with a header of 8 bytes: the magic bytes
+// "DGBK", then the format version and flags as little-endian uint16. Each KV follows in its
+// own frame: the length of the marshaled KV as a little-endian uint32, the KV, and its
+// CRC-32C as a little-endian uint32. Read as a version 1 length, the header would be a KV of
+// over 9GB, which can't be, so readBackup tells the formats apart by the first bytes.
+// Files without any KVs are empty in both formats.
+const (
+	formatMagic   = "DGBK"
+	formatVersion = 2
+	headerSize    = 8
+)
+
+// crcTable is the table of the CRC-32C of the frames.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// writeHeader writes the header of a version 2 file to w. No flags are defined yet.
+func writeHeader(w io.Writer) error {
+	var hdr [headerSize]byte
+	copy(hdr[:4], formatMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], formatVersion)
+	_, err := w.Write(hdr[:])
+	return err
+}
+
+// writeFrame writes the frame of the marshaled KV b to w.
+func writeFrame(w io.Writer, b []byte) error {
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(b)))
+	if _, err := w.Write(n[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(n[:], crc32.Checksum(b, crcTable))
+	_, err := w.Write(n[:])
+	return err
+}
+
+// frameReader returns the bytes of a backup file in slices, for readBackup to decode the KVs
+// from them without copying them first when it can, see mmapReader.
+type frameReader interface {
+	// peek returns the next n bytes without consuming them, or fewer at the end of the file.
+	peek(n int) ([]byte, error)
+	// next consumes the next n bytes and returns them, or fewer at the end of the file. They're
+	// only valid until the next call.
+	next(n int64) ([]byte, error)
+}
+
+// bufReader is the frameReader of any reader, which copies each slice into its buffer.
+type bufReader struct {
+	br *bufio.Reader
+	bb bytes.Buffer
+}
+
+func (r *bufReader) peek(n int) ([]byte, error) {
+	b, err := r.br.Peek(n)
+	if err == io.EOF {
+		err = nil
+	}
+	return b, err
+}
+
+func (r *bufReader) next(n int64) ([]byte, error) {
+	r.bb.Reset()
+	_, err := r.bb.ReadFrom(io.LimitReader(r.br, n))
+	return r.bb.Bytes(), err
+}
+
+// readBackup reads the KVs written by writer.Send, in either format, and calls fn for each
+// one. The KV given to fn and its buffers are reused for the next one, fn must copy what it
+// keeps.
+func readBackup(r io.Reader, fn func(kv *pb.KV) error) error {
+	return readBackupAt(r, func(kv *pb.KV, _ int64) error { return fn(kv) })
+}
+
+// readBackupAt is like readBackup, but also gives fn the offset of the end of the frame of
+// each KV, counted from the end of the header. A restore can continue from that offset once
+// the KV is committed, see seekFrames.
+func readBackupAt(r io.Reader, fn func(kv *pb.KV, end int64) error) error {
+	fr, ok := r.(frameReader)
+	if !ok {
+		br, ok := r.(*bufio.Reader)
+		if !ok {
+			br = bufio.NewReader(r)
+		}
+		fr = &bufReader{br: br}
+	}
+	var kv pb.KV
+	decode := func(b []byte, end int64) error {
+		// Unmarshal appends to the buffers of kv, and leaves the fields missing untouched.
+		kv.Key, kv.Val, kv.UserMeta, kv.Version = kv.Key[:0], kv.Val[:0], kv.UserMeta[:0], 0
+		if err := kv.Unmarshal(b); err != nil {
+			return err
+		}
+		return fn(&kv, end)
+	}
+
+	hdr, err := fr.peek(headerSize)
+	if err != nil {
+		return err
+	}
+	if len(hdr) < 4 || string(hdr[:4]) != formatMagic {
+		return readV1(fr, decode)
+	}
+	if len(hdr) < headerSize {
+		return x.Errorf("Invalid backup file: truncated header")
+	}
+	if v := binary.LittleEndian.Uint16(hdr[4:6]); v != formatVersion {
+		return x.Errorf("Unsupported backup format version %d", v)
+	}
+	if flags := binary.LittleEndian.Uint16(hdr[6:8]); flags != 0 {
+		return x.Errorf("Unsupported backup format flags %#x", flags)
+	}
+	if _, err := fr.next(headerSize); err != nil {
+		return err
+	}
+	return readV2(fr, decode)
+}
+
+// readV1 reads the KVs of a version 1 file and calls decode for each one, with the offset of
+// its end.
+func readV1(r frameReader, decode func(b []byte, end int64) error) error {
+	for off := int64(0); ; {
+		b, err := r.next(8)
+		switch {
+		case err != nil:
+			return err
+		case len(b) == 0:
+			return nil
+		case len(b) < 8:
+			return io.ErrUnexpectedEOF
+		}
+		sz := binary.LittleEndian.Uint64(b)
+		if b, err = r.next(int64(sz)); err != nil {
+			return err
+		}
+		if uint64(len(b)) != sz {
+			return x.Errorf("Restore failed read. Expected %d bytes but got %d instead.", sz,
+				len(b))
+		}
+		off += 8 + int64(sz)
+		if err := decode(b, off); err != nil {
+			return err
+		}
+	}
+}
+
+// readV2 reads the frames of a version 2 file, after its header, and calls decode for the KV
+// of each one, with the offset of the end of its frame.
+func readV2(r frameReader, decode func(b []byte, end int64) error) error {
+	var off int64
+	for frame := 0; ; frame++ {
+		b, err := r.next(4)
+		switch {
+		case err != nil:
+			return x.Wrapf(err, "while reading frame %d", frame)
+		case len(b) == 0:
+			return nil
+		case len(b) < 4:
+			return x.Wrapf(io.ErrUnexpectedEOF, "while reading frame %d", frame)
+		}
+		sz := binary.LittleEndian.Uint32(b)
+		data, err := r.next(int64(sz) + 4)
+		if err != nil {
+			return x.Wrapf(err, "while reading frame %d", frame)
+		}
+		if len(data) < int(sz) {
+			return x.Errorf("Frame %d is truncated: expected %d bytes but got %d", frame, sz,
+				len(data))
+		}
+		if len(data) < int(sz)+4 {
+			return x.Errorf("Frame %d is truncated: missing its checksum", frame)
+		}
+		b, crc := data[:sz], binary.LittleEndian.Uint32(data[sz:])
+		if crc32.Checksum(b, crcTable) != crc {
+			return x.Errorf("Frame %d is corrupted: checksum mismatch", frame)
+		}
+		off += 4 + int64(sz) + 4
+		if err := decode(b, off); err != nil {
+			return x.Wrapf(err, "while decoding frame %d", frame)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readAll returns copies of the KVs read by readBackup from b.
+func readAll(b []byte) ([]*pb.KV, error) {
+	var kvs []*pb.KV
+	err := readBackup(bytes.NewReader(b), func(kv *pb.KV) error {
+		c := *kv
+		c.Key = append([]byte{}, kv.Key...)
+		c.Val = append([]byte{}, kv.Val...)
+		c.UserMeta = append([]byte{}, kv.UserMeta...)
+		kvs = append(kvs, &c)
+		return nil
+	})
+	return kvs, err
+}
+
+func TestReadBackupFormats(t *testing.T) {
+	expected := testKVs("name", 3)
+	// A KV without a version must not get the version of the KV read before it.
+	expected.Kv[1].Version = 0
+
+	var v1 bytes.Buffer
+	for _, kv := range expected.Kv {
+		require.NoError(t, binary.Write(&v1, binary.LittleEndian, uint64(kv.Size())))
+		b, err := kv.Marshal()
+		require.NoError(t, err)
+		v1.Write(b)
+	}
+	var v2 bytes.Buffer
+	w := &writer{w: &v2, preds: make(map[string]struct{})}
+	require.NoError(t, w.Send(expected))
+	require.Equal(t, formatMagic, v2.String()[:4])
+
+	for _, b := range [][]byte{v1.Bytes(), v2.Bytes()} {
+		kvs, err := readAll(b)
+		require.NoError(t, err)
+		require.Equal(t, expected.Kv, kvs)
+	}
+
+	kvs, err := readAll(nil)
+	require.NoError(t, err)
+	require.Empty(t, kvs)
+}
+
+func TestReadBackupInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	w := &writer{w: &buf, preds: make(map[string]struct{})}
+	require.NoError(t, w.Send(testKVs("name", 3)))
+	b := buf.Bytes()
+
+	_, err := readAll(b[:6])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "truncated header")
+
+	_, err = readAll(b[:len(b)-2])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Frame 2 is truncated")
+
+	_, err = readAll(b[:len(b)-10])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Frame 2 is truncated")
+
+	c := append([]byte{}, b...)
+	c[4] = 3
+	_, err = readAll(c)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Unsupported backup format version 3")
+
+	c = append([]byte{}, b...)
+	c[6] = 1
+	_, err = readAll(c)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Unsupported backup format flags")
+}
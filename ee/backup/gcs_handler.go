@@ -0,0 +1,426 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/golang/glog"
+)
+
+const (
+	gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+	// gcsMaxRetries is the number of times a failed chunk upload is retried.
+	gcsMaxRetries = 5
+)
+
+var (
+	// gcsEndpoint is the GCS JSON API endpoint. It can be changed for testing.
+	gcsEndpoint = "https://storage.googleapis.com"
+	// gcsChunkSize is the size of each resumable upload request. It must be a multiple
+	// of 256 KiB, as required by the GCS API.
+	gcsChunkSize = 16 << 20
+)
+
+// gcsServiceAccount holds the fields we need from a service-account JSON key file.
+type gcsServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// gcsHandler is used for 'gs:' URI scheme.
+type gcsHandler struct {
+	client  *http.Client
+	creds   *Credentials
+	account gcsServiceAccount
+	token   string
+	expiry  time.Time
+
+	bucket  string
+	prefix  string
+	object  string
+	session string // resumable upload session URI
+	buf     bytes.Buffer
+	offset  int64
+}
+
+// setup reads the service-account key file and gets an access token for it. Later calls
+// reuse the token until it expires.
+// URI formats:
+//   gs://bucket/folder1.../folderN
+// The service-account JSON key file is read from env var GOOGLE_APPLICATION_CREDENTIALS.
+func (h *gcsHandler) setup(uri *url.URL) error {
+	if h.client != nil {
+		return nil
+	}
+
+	file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if file == "" {
+		return x.Errorf("Env var GOOGLE_APPLICATION_CREDENTIALS not set.")
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return x.Wrapf(err, "while reading GCS credentials file")
+	}
+	if err := json.Unmarshal(b, &h.account); err != nil {
+		return x.Wrapf(err, "while parsing GCS credentials file")
+	}
+
+	if uri.Host == "" {
+		return x.Errorf("The GCS bucket in %q is invalid", uri.String())
+	}
+	h.bucket = uri.Host
+	h.prefix = strings.Trim(uri.Path, "/")
+	glog.V(2).Infof("GCS handler using bucket: %s, path: %s", h.bucket, h.prefix)
+
+	client, err := newHTTPClient(h.creds)
+	if err != nil {
+		return err
+	}
+	h.client = client
+	return h.refreshToken()
+}
+
+// refreshToken exchanges a signed JWT for an OAuth2 access token, if the current one is
+// missing or about to expire.
+func (h *gcsHandler) refreshToken() error {
+	if h.token != "" && time.Now().Add(time.Minute).Before(h.expiry) {
+		return nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(h.account.PrivateKey))
+	if err != nil {
+		return x.Wrapf(err, "while parsing GCS private key")
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   h.account.ClientEmail,
+		"scope": gcsScope,
+		"aud":   h.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.PostForm(h.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return gcsError(resp, "while getting GCS access token")
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	h.token = token.AccessToken
+	h.expiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return nil
+}
+
+// do sends an authorized request to GCS.
+func (h *gcsHandler) do(method, u string, body io.Reader, hdr http.Header) (*http.Response, error) {
+	if err := h.refreshToken(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", "Bearer "+h.token)
+	return h.client.Do(req)
+}
+
+// Create starts a resumable upload session for the object at p, relative to the location.
+// Data sent via Write is uploaded in chunks of gcsChunkSize, so large backups don't need to
+// fit in memory and a failed chunk can be retried without starting over.
+func (h *gcsHandler) Create(uri *url.URL, p string) error {
+	if err := h.setup(uri); err != nil {
+		return err
+	}
+
+	h.object = path.Join(h.prefix, p)
+	h.offset = 0
+	h.buf.Reset()
+	glog.V(2).Infof("Sending data to GCS object %q ...", h.object)
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s",
+		gcsEndpoint, url.PathEscape(h.bucket), url.QueryEscape(h.object))
+	resp, err := h.do(http.MethodPost, u, nil, http.Header{
+		"Content-Type": {"application/json; charset=UTF-8"},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return gcsError(resp, "while starting GCS upload")
+	}
+	h.session = resp.Header.Get("Location")
+	if h.session == "" {
+		return x.Errorf("GCS did not return a resumable upload session")
+	}
+	return nil
+}
+
+func (h *gcsHandler) Write(b []byte) (int, error) {
+	n, _ := h.buf.Write(b)
+	for h.buf.Len() >= gcsChunkSize {
+		if err := h.upload(h.buf.Next(gcsChunkSize), false); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close uploads the remaining data and finalizes the object.
+func (h *gcsHandler) Close() error {
+	glog.V(2).Infof("Backup waiting for upload to complete.")
+	return h.upload(h.buf.Next(h.buf.Len()), true)
+}
+
+// upload sends a chunk of data to the resumable session. On failure, it asks GCS how much
+// data it has persisted and resends only the missing part.
+func (h *gcsHandler) upload(chunk []byte, final bool) error {
+	var err error
+	for i := 0; i < gcsMaxRetries; i++ {
+		if i > 0 {
+			glog.Warningf("Retrying GCS chunk upload at offset %d: %v", h.offset, err)
+			time.Sleep(time.Duration(i) * time.Second)
+			var persisted int64
+			var done bool
+			if persisted, done, err = h.persisted(); err != nil {
+				continue
+			}
+			if done {
+				// The final chunk made it, only its response was lost.
+				h.offset += int64(len(chunk))
+				return nil
+			}
+			if persisted > h.offset {
+				chunk = chunk[persisted-h.offset:]
+				h.offset = persisted
+			}
+		}
+		if err = h.put(chunk, final); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (h *gcsHandler) contentRange(n int64, final bool) string {
+	total := "*"
+	if final {
+		total = strconv.FormatInt(h.offset+n, 10)
+	}
+	if n == 0 {
+		return "bytes */" + total
+	}
+	return fmt.Sprintf("bytes %d-%d/%s", h.offset, h.offset+n-1, total)
+}
+
+func (h *gcsHandler) put(chunk []byte, final bool) error {
+	n := int64(len(chunk))
+	resp, err := h.do(http.MethodPut, h.session, bytes.NewReader(chunk), http.Header{
+		"Content-Range": {h.contentRange(n, final)},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case final && (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated):
+	case !final && resp.StatusCode == http.StatusPermanentRedirect:
+	default:
+		return gcsError(resp, "while uploading to GCS")
+	}
+	h.offset += n
+	return nil
+}
+
+// persisted returns the number of bytes GCS has stored for the current session, and whether
+// the upload has already been completed.
+func (h *gcsHandler) persisted() (int64, bool, error) {
+	resp, err := h.do(http.MethodPut, h.session, nil, http.Header{
+		"Content-Range": {"bytes */*"},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case http.StatusPermanentRedirect:
+	default:
+		return 0, false, gcsError(resp, "while checking GCS upload status")
+	}
+	// Range header looks like "bytes=0-1234". No header means nothing was persisted.
+	rng := resp.Header.Get("Range")
+	if rng == "" {
+		return 0, false, nil
+	}
+	end, err := strconv.ParseInt(rng[strings.LastIndex(rng, "-")+1:], 10, 64)
+	if err != nil {
+		return 0, false, x.Errorf("Invalid GCS upload range %q", rng)
+	}
+	return end + 1, false, nil
+}
+
+// List returns the relative paths of the objects under the location ending in suffix.
+func (h *gcsHandler) List(uri *url.URL, suffix string) ([]string, error) {
+	objects, err := h.ListAfter(uri, suffix, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(objects)
+	return objects, nil
+}
+
+// ListAfter returns up to limit relative paths of the objects under the location ending in
+// suffix that sort after startAfter, or all of them if limit is zero. GCS lists the objects
+// in lexical order, from startOffset on, so the objects before startAfter aren't listed.
+func (h *gcsHandler) ListAfter(uri *url.URL, suffix, startAfter string, limit int) (
+	[]string, error) {
+	if err := h.setup(uri); err != nil {
+		return nil, err
+	}
+
+	var prefix string
+	if h.prefix != "" {
+		prefix = h.prefix + "/"
+	}
+	var objects []string
+	var page string
+	for {
+		q := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if startAfter != "" {
+			q.Set("startOffset", prefix+startAfter)
+		}
+		if page != "" {
+			q.Set("pageToken", page)
+		}
+		u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s",
+			gcsEndpoint, url.PathEscape(h.bucket), q.Encode())
+		resp, err := h.do(http.MethodGet, u, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = gcsError(resp, "while listing GCS objects")
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&res)
+		}
+		x.Ignore(resp.Body.Close())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range res.Items {
+			// startOffset is inclusive.
+			name := strings.TrimPrefix(item.Name, prefix)
+			if strings.HasSuffix(name, suffix) && (startAfter == "" || name > startAfter) {
+				objects = append(objects, name)
+				if len(objects) == limit {
+					return objects, nil
+				}
+			}
+		}
+		if res.NextPageToken == "" {
+			break
+		}
+		page = res.NextPageToken
+	}
+	return objects, nil
+}
+
+// Read returns a reader for the object at p, relative to the location.
+func (h *gcsHandler) Read(uri *url.URL, p string) (io.ReadCloser, int64, error) {
+	if err := h.setup(uri); err != nil {
+		return nil, 0, err
+	}
+
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		gcsEndpoint, url.PathEscape(h.bucket), url.PathEscape(path.Join(h.prefix, p)))
+	resp, err := h.do(http.MethodGet, u, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, gcsError(resp, "while downloading from GCS")
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Delete deletes the object at p, relative to the location.
+func (h *gcsHandler) Delete(uri *url.URL, p string) error {
+	if err := h.setup(uri); err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s",
+		gcsEndpoint, url.PathEscape(h.bucket), url.PathEscape(path.Join(h.prefix, p)))
+	resp, err := h.do(http.MethodDelete, u, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return gcsError(resp, "while deleting from GCS")
+	}
+	return nil
+}
+
+// gcsError builds an error from a failed GCS response, including its body.
+func gcsError(resp *http.Response, msg string) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return x.Errorf("Error %s: %s %s", msg, resp.Status, bytes.TrimSpace(body))
+}
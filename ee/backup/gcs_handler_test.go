@@ -0,0 +1,212 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGCS implements the parts of the GCS JSON API used by gcsHandler.
+type fakeGCS struct {
+	sync.Mutex
+	url      string
+	objects  map[string][]byte
+	sessions map[string][]byte
+	// failPut makes the PUT request with this sequence number fail.
+	failPut int
+	puts    int
+	// listed counts the objects listed.
+	listed int
+}
+
+func (s *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if r.URL.Path == "/token" {
+		w.Write([]byte(`{"access_token": "token", "expires_in": 3600}`))
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/"):
+		name := r.URL.Query().Get("name")
+		s.sessions[name] = nil
+		w.Header().Set("Location", s.url+"/session/"+name)
+
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/session/"):
+		s.puts++
+		name := strings.TrimPrefix(r.URL.Path, "/session/")
+		data := s.sessions[name]
+		body, _ := ioutil.ReadAll(r.Body)
+		if s.puts == s.failPut {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var start, end int
+		var total string
+		rng := r.Header.Get("Content-Range")
+		if strings.HasPrefix(rng, "bytes */") {
+			total = strings.TrimPrefix(rng, "bytes */")
+		} else {
+			fmt.Sscanf(rng, "bytes %d-%d/%s", &start, &end, &total)
+			if start != len(data) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data = append(data, body...)
+			s.sessions[name] = data
+		}
+		if total == "*" {
+			if len(data) > 0 {
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(data)-1))
+			}
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		s.objects[name] = data
+		delete(s.sessions, name)
+
+	case r.Method == http.MethodGet && r.URL.Query().Get("alt") == "media":
+		name := r.URL.Path[strings.Index(r.URL.Path, "/o/")+3:]
+		data, ok := s.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+
+	case r.Method == http.MethodDelete:
+		name := r.URL.Path[strings.Index(r.URL.Path, "/o/")+3:]
+		if _, ok := s.objects[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/o"):
+		// Return one object per page to exercise pagination.
+		var names []string
+		for name := range s.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) &&
+				name >= r.URL.Query().Get("startOffset") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		var i int
+		if tok := r.URL.Query().Get("pageToken"); tok != "" {
+			fmt.Sscanf(tok, "%d", &i)
+		}
+		res := map[string]interface{}{}
+		if i < len(names) {
+			res["items"] = []map[string]string{{"name": names[i]}}
+			s.listed++
+		}
+		if i+1 < len(names) {
+			res["nextPageToken"] = fmt.Sprintf("%d", i+1)
+		}
+		json.NewEncoder(w).Encode(res)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func setupFakeGCS(t *testing.T, dir string) *fakeGCS {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	gcs := &fakeGCS{
+		objects:  make(map[string][]byte),
+		sessions: make(map[string][]byte),
+	}
+	srv := httptest.NewServer(gcs)
+	gcs.url = srv.URL
+
+	creds, err := json.Marshal(gcsServiceAccount{
+		ClientEmail: "backup@dgraph.iam.gserviceaccount.com",
+		PrivateKey:  string(pemKey),
+		TokenURI:    srv.URL + "/token",
+	})
+	require.NoError(t, err)
+	path := filepath.Join(dir, "creds.json")
+	require.NoError(t, ioutil.WriteFile(path, creds, 0600))
+	require.NoError(t, os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path))
+	gcsEndpoint = srv.URL
+	return gcs
+}
+
+func TestGCSBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gcs := setupFakeGCS(t, dir)
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+	// Use tiny chunks so the upload spans many requests, and fail one of them.
+	defer func(n int) { gcsChunkSize = n }(gcsChunkSize)
+	gcsChunkSize = 64
+	gcs.failPut = 3
+
+	writeBackup(t, "gs://bucket/dgraph", "20181106.011302", 0, 10,
+		testKVs("name", 5), testKVs("age", 3))
+	require.Len(t, gcs.objects, 3)
+	require.Contains(t, gcs.objects, "dgraph/dgraph.20181106.011302/r10-g1.backup")
+	require.Contains(t, gcs.objects, "dgraph/dgraph.20181106.011302/manifest.json")
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, "gs://bucket/dgraph", 0))
+	for i, expected := range []*pb.KVS{testKVs("name", 5), testKVs("age", 3)} {
+		got := readKVs(t, filepath.Join(pdir, fmt.Sprintf("p%d", i+1)))
+		require.Len(t, got, len(expected.Kv))
+		for _, kv := range expected.Kv {
+			require.Equal(t, kv, got[string(kv.Key)])
+		}
+	}
+
+	// Pruning deletes the objects of the older backup of the day.
+	writeBackup(t, "gs://bucket/dgraph", "20181106.021302", 0, 20, testKVs("name", 5))
+	o := &pruneOptions{location: "gs://bucket/dgraph", keepDaily: 1}
+	require.NoError(t, runPrune(o, ioutil.Discard))
+	require.Len(t, gcs.objects, 2)
+	require.Contains(t, gcs.objects, "dgraph/dgraph.20181106.021302/manifest.json")
+}
@@ -0,0 +1,317 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+// backupExt is the file extension of the backup files written by the handlers.
+const backupExt = ".backup"
+
+// handler interface is implemented by URI scheme handlers.
+// All paths given to and returned by a handler are relative to the location in the URI,
+// and use '/' as separator.
+type handler interface {
+	// Handlers know how to Write and Close to their target.
+	io.WriteCloser
+	// Create prepares the object at path so the data sent via Write is stored in it.
+	// Close completes the object. It should get all its configuration from the environment.
+	Create(uri *url.URL, path string) error
+	// List returns the paths of all the objects under the location whose name ends with
+	// suffix, in lexical order.
+	List(uri *url.URL, suffix string) ([]string, error)
+	// Read returns a reader for the object at path, and its size in bytes or -1 if unknown.
+	Read(uri *url.URL, path string) (io.ReadCloser, int64, error)
+	// Delete deletes the object at path.
+	Delete(uri *url.URL, path string) error
+}
+
+// pageLister is implemented by the handlers that can list the objects after a given one
+// without listing the ones before it, see listPage.
+type pageLister interface {
+	// ListAfter returns up to limit paths of the objects under the location whose name ends
+	// with suffix and whose path sorts after startAfter, in lexical order.
+	ListAfter(uri *url.URL, suffix, startAfter string, limit int) ([]string, error)
+}
+
+// loadFile describes a backup file passed to a loadFn.
+type loadFile struct {
+	name        string   // path of the file, relative to the location
+	group       uint32   // ID of the group the file belongs to
+	size        int64    // size of the file in bytes, -1 if unknown
+	checksum    string   // expected hex SHA-256 of the file, empty if unknown
+	preds       []string // predicates in the file, nil if unknown
+	encryption  string   // cipher the file is encrypted with, empty if it isn't
+	compression string   // codec the file is compressed with, empty if it isn't
+	since       uint64   // ts the backup is incremental from, zero for full backups
+	readTs      uint64   // ts the backup was taken at
+	skipIndexes bool     // the index, reverse and count keys weren't backed up
+	ordered     bool     // the keys of the file are in key order
+}
+
+// loadFn is a function that will receive the current file being read.
+type loadFn func(io.Reader, *loadFile) error
+
+// loadFilter is a function that decides if a backup file must be loaded.
+type loadFilter func(*loadFile) bool
+
+// Credentials holds the keys used by remote handlers to authenticate with their service.
+// Empty values are read from the environment instead.
+// The S3 settings override the ones of the location URI, for S3-compatible stores like MinIO.
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+
+	S3Endpoint   string // host[:port] of the S3 service, instead of the URI host
+	S3PathStyle  bool   // address buckets in the path instead of the host name
+	S3SkipVerify bool   // don't verify the TLS certificate of the S3 service
+
+	Proxy         string // URL of the HTTP proxy, instead of the one of the env vars
+	ProxyInsecure bool   // don't verify the TLS certificates of the remote services
+}
+
+// getHandler returns a handler for the URI scheme, or nil if the scheme is not supported.
+func getHandler(uri *url.URL, creds *Credentials) handler {
+	switch uri.Scheme {
+	case "file", "":
+		return &fileHandler{}
+	case "s3":
+		return &s3Handler{creds: creds}
+	case "gs", "gcs":
+		return &gcsHandler{creds: creds}
+	case "azblob":
+		return &azHandler{creds: creds}
+	case "hdfs":
+		return &hdfsHandler{creds: creds}
+	case "http", "https":
+		if strings.HasPrefix(uri.Host, "s3") &&
+			strings.HasSuffix(uri.Host, ".amazonaws.com") {
+			return &s3Handler{creds: creds}
+		}
+	}
+	return nil
+}
+
+// newHandler parses the location URI and returns a handler for it.
+func newHandler(l string, creds *Credentials) (handler, *url.URL, error) {
+	uri, err := url.Parse(l)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := getHandler(uri, creds)
+	if h == nil {
+		return nil, nil, x.Errorf("Unable to handle url: %v", uri)
+	}
+	return h, uri, nil
+}
+
+// Create returns a writer of the object at path under the location l, which is complete once
+// the writer is closed. The location URI formats are the same as the backup target (see
+// newWriter). It's used to write exports to remote destinations.
+func Create(l, path string) (io.WriteCloser, error) {
+	h, uri, err := newHandler(l, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Create(uri, path); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// backupDir returns the directory, relative to the location, of the backup started at unixTs.
+func backupDir(unixTs string) string {
+	return fmt.Sprintf("dgraph.%s", unixTs)
+}
+
+// backupName returns the name of a group's backup file, i.e. r110001-g1.backup.
+func backupName(readTs uint64, gid uint32) string {
+	return fmt.Sprintf("r%d-g%d%s", readTs, gid, backupExt)
+}
+
+// Load finds the backups at location l and calls fn for each of their group files.
+// Backups are applied in the order of their manifest chain: a full backup first, followed by
+// the incremental backups taken after it. If restoreTs is set, the chain ends at the latest
+// backup taken at or before it. The location URI formats are the same as
+// the backup target (see newWriter).
+// Groups are independent, so up to workers groups are loaded concurrently. The files of each
+// group are always loaded in order. If filter is set, only the files it accepts are loaded.
+// If pf is set, the files of remote locations are downloaded ahead of their load, see
+// prefetcher.
+// Returns errors on failure, nil on success.
+func Load(l string, restoreTs uint64, workers int, creds *Credentials, filter loadFilter,
+	pf *prefetcher, fn loadFn) error {
+	chain, err := Chain(l, restoreTs, creds)
+	if err != nil {
+		return err
+	}
+
+	// Find the files of each group, in chain order.
+	files := make(map[uint32][]*loadFile)
+	var gids []uint32
+	for _, m := range chain {
+		if m.Encryption != "" && m.Encryption != encryptionAESGCM {
+			return x.Errorf("Backup %q is encrypted with %q, which is not supported",
+				m.path, m.Encryption)
+		}
+		glog.Infof("Restore: using backup %q taken at ts %d by Dgraph %s",
+			m.path, m.ReadTs, m.Version)
+		for _, gid := range m.Groups {
+			f := &loadFile{
+				name:        path.Join(path.Dir(m.path), backupName(m.ReadTs, gid)),
+				group:       gid,
+				checksum:    m.Checksums[gid],
+				preds:       m.Predicates[gid],
+				encryption:  m.Encryption,
+				compression: m.Compression,
+				since:       m.Since,
+				readTs:      m.ReadTs,
+				skipIndexes: m.SkipIndexes,
+				ordered:     m.Ordered,
+			}
+			if filter != nil && !filter(f) {
+				glog.V(2).Infof("Restore: skipping backup file %q", f.name)
+				continue
+			}
+			if _, ok := files[gid]; !ok {
+				gids = append(gids, gid)
+			}
+			files[gid] = append(files[gid], f)
+		}
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		once    sync.Once
+		loadErr error
+		failed  int32
+	)
+	thr := x.NewThrottle(workers)
+	for _, gid := range gids {
+		thr.Start()
+		go func(gid uint32) {
+			defer thr.Done()
+			// Each group gets its own handler, they are not safe for concurrent use.
+			if err := loadGroup(l, creds, files[gid], &failed, pf, fn); err != nil {
+				atomic.StoreInt32(&failed, 1)
+				once.Do(func() { loadErr = err })
+			}
+		}(gid)
+	}
+	thr.Wait()
+	return loadErr
+}
+
+// Chain returns the manifests of the backups at location l that Load applies for restoreTs,
+// in chain order. See restoreChain.
+func Chain(l string, restoreTs uint64, creds *Credentials) ([]*Manifest, error) {
+	h, uri, err := newHandler(l, creds)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := readManifests(h, uri)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, x.Errorf("No backups found in %q", uri.String())
+	}
+	chain, err := restoreChain(manifests, restoreTs)
+	if err != nil {
+		return nil, err
+	}
+	if last := chain[len(chain)-1]; restoreTs > 0 && last.ReadTs != restoreTs {
+		glog.Warningf("Restore: no backup was taken at ts %d, restoring the data as of "+
+			"ts %d, the read ts of %q", restoreTs, last.ReadTs, last.path)
+	}
+	return chain, nil
+}
+
+// loadGroup calls fn for each one of the files of a group, in order, once its checksum is
+// verified. It stops early if failed is set by another group. If pf is set, the files of
+// a remote location are downloaded ahead by pf.
+func loadGroup(l string, creds *Credentials, files []*loadFile, failed *int32,
+	pf *prefetcher, fn loadFn) error {
+	h, uri, err := newHandler(l, creds)
+	if err != nil {
+		return err
+	}
+	if _, local := h.(*fileHandler); pf != nil && !local {
+		return pf.load(h, uri, files, failed, fn)
+	}
+	for _, f := range files {
+		if atomic.LoadInt32(failed) != 0 {
+			return nil
+		}
+		if f.checksum != "" {
+			if err := verifyChecksum(h, uri, f); err != nil {
+				return err
+			}
+		}
+		glog.V(2).Infof("Restore: loading backup file %q", f.name)
+		r, size, err := readFile(h, uri, f.name)
+		if err != nil {
+			return x.Wrapf(err, "while reading %q", f.name)
+		}
+		f.size = size
+		err = fn(r, f)
+		x.Ignore(r.Close())
+		if err != nil {
+			return x.Wrapf(err, "while loading %q", f.name)
+		}
+	}
+	return nil
+}
+
+// verifyChecksum reads the backup file f to check that its SHA-256 is the one in its manifest.
+// It's read before it's loaded, so nothing of a corrupted or truncated file is ever loaded,
+// at the cost of reading each file twice.
+func verifyChecksum(h handler, uri *url.URL, f *loadFile) error {
+	glog.V(2).Infof("Restore: verifying the checksum of backup file %q", f.name)
+	r, _, err := readFile(h, uri, f.name)
+	if err != nil {
+		return x.Wrapf(err, "while reading %q", f.name)
+	}
+	defer r.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, r); err != nil {
+		return x.Wrapf(err, "while reading %q", f.name)
+	}
+	return checkChecksum(f, hex.EncodeToString(sum.Sum(nil)))
+}
+
+// checkChecksum returns an error if got isn't the checksum of the backup file f.
+func checkChecksum(f *loadFile, got string) error {
+	if got != f.checksum {
+		return x.Errorf("Checksum mismatch for %q: expected %s, got %s. "+
+			"The backup file is corrupted or truncated.", f.name, f.checksum, got)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// hdfsDefaultPort is the default port of the WebHDFS REST API of the NameNode.
+	hdfsDefaultPort = "9870"
+	hdfsAPIPath     = "/webhdfs/v1"
+)
+
+// hdfsBlockSize is the size of the data sent with each CREATE or APPEND request.
+var hdfsBlockSize = 16 << 20
+
+// hdfsHandler is used for 'hdfs:' URI scheme. It talks to the WebHDFS REST API, so no
+// Hadoop client libraries are needed.
+type hdfsHandler struct {
+	client *http.Client
+	creds  *Credentials
+	base   string // URL of the WebHDFS API
+	user   string
+	prefix string
+
+	file    string
+	buf     bytes.Buffer
+	created bool
+}
+
+// setup prepares the WebHDFS URL of the location, once per handler.
+// URI formats:
+//   hdfs://<namenode>[:port]/folder1.../folderN?secure=true|false&user=<name>
+// The host is the WebHDFS (HTTP) address of the NameNode, port 9870 by default. Requests are
+// made as the user given in the URI, or in the HADOOP_USER_NAME env var, with simple auth.
+func (h *hdfsHandler) setup(uri *url.URL) error {
+	if h.client != nil {
+		return nil
+	}
+	if uri.Host == "" {
+		return x.Errorf("The HDFS NameNode in %q is invalid", uri.String())
+	}
+	host := uri.Host
+	if uri.Port() == "" {
+		host = net.JoinHostPort(host, hdfsDefaultPort)
+	}
+	scheme := "http"
+	if uri.Query().Get("secure") == "true" {
+		scheme = "https"
+	}
+	h.base = fmt.Sprintf("%s://%s%s", scheme, host, hdfsAPIPath)
+	h.prefix = "/" + strings.Trim(uri.Path, "/")
+	h.user = uri.Query().Get("user")
+	if h.user == "" {
+		h.user = os.Getenv("HADOOP_USER_NAME")
+	}
+	glog.V(2).Infof("HDFS handler using: %s, path: %s", h.base, h.prefix)
+
+	// The NameNode redirects the reads and writes to a DataNode, the client follows them.
+	client, err := newHTTPClient(h.creds)
+	if err != nil {
+		return err
+	}
+	h.client = client
+	return nil
+}
+
+// do sends a request for the operation op on the file or directory p, an absolute path.
+func (h *hdfsHandler) do(method, p, op string, q url.Values, body []byte) (
+	*http.Response, error) {
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("op", op)
+	if h.user != "" {
+		q.Set("user.name", h.user)
+	}
+	u := h.base + (&url.URL{Path: p}).EscapedPath() + "?" + q.Encode()
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return h.client.Do(req)
+}
+
+// Create prepares the upload of the file at p, relative to the location. Data sent via Write
+// is uploaded in blocks of hdfsBlockSize, the first one creates the file and the rest are
+// appended to it.
+func (h *hdfsHandler) Create(uri *url.URL, p string) error {
+	if err := h.setup(uri); err != nil {
+		return err
+	}
+
+	h.file = path.Join(h.prefix, p)
+	h.created = false
+	h.buf.Reset()
+	glog.V(2).Infof("Sending data to HDFS file %q ...", h.file)
+	return nil
+}
+
+func (h *hdfsHandler) Write(b []byte) (int, error) {
+	n, _ := h.buf.Write(b)
+	for h.buf.Len() >= hdfsBlockSize {
+		if err := h.putBlock(h.buf.Next(hdfsBlockSize)); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close uploads the remaining data, creating the file if nothing was sent yet.
+func (h *hdfsHandler) Close() error {
+	glog.V(2).Infof("Backup waiting for upload to complete.")
+	if h.buf.Len() > 0 || !h.created {
+		return h.putBlock(h.buf.Next(h.buf.Len()))
+	}
+	return nil
+}
+
+// putBlock creates the file with the block, or appends the block to it.
+func (h *hdfsHandler) putBlock(block []byte) error {
+	method, op, status := http.MethodPost, "APPEND", http.StatusOK
+	q := url.Values{}
+	if !h.created {
+		method, op, status = http.MethodPut, "CREATE", http.StatusCreated
+		q.Set("overwrite", "true")
+	}
+	resp, err := h.do(method, h.file, op, q, block)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		return hdfsError(resp, "while writing HDFS file")
+	}
+	h.created = true
+	return nil
+}
+
+// List returns the relative paths of the files under the location ending in suffix.
+func (h *hdfsHandler) List(uri *url.URL, suffix string) ([]string, error) {
+	if err := h.setup(uri); err != nil {
+		return nil, err
+	}
+
+	root := h.prefix
+	if root != "/" {
+		root += "/"
+	}
+	var files []string
+	dirs := []string{h.prefix}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		resp, err := h.do(http.MethodGet, dir, "LISTSTATUS", nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			FileStatuses struct {
+				FileStatus []struct {
+					PathSuffix string `json:"pathSuffix"`
+					Type       string `json:"type"`
+				}
+			}
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(&res)
+		case http.StatusNotFound:
+			// Like the object stores, a location without files is empty.
+		default:
+			err = hdfsError(resp, "while listing HDFS directory")
+		}
+		x.Ignore(resp.Body.Close())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, st := range res.FileStatuses.FileStatus {
+			p := path.Join(dir, st.PathSuffix)
+			switch {
+			case st.Type == "DIRECTORY":
+				dirs = append(dirs, p)
+			case strings.HasSuffix(p, suffix):
+				files = append(files, strings.TrimPrefix(p, root))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Read returns a reader for the file at p, relative to the location.
+func (h *hdfsHandler) Read(uri *url.URL, p string) (io.ReadCloser, int64, error) {
+	if err := h.setup(uri); err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := h.do(http.MethodGet, path.Join(h.prefix, p), "OPEN", nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, hdfsError(resp, "while reading HDFS file")
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// Delete deletes the file at p, relative to the location.
+func (h *hdfsHandler) Delete(uri *url.URL, p string) error {
+	if err := h.setup(uri); err != nil {
+		return err
+	}
+
+	resp, err := h.do(http.MethodDelete, path.Join(h.prefix, p), "DELETE", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Boolean bool `json:"boolean"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return hdfsError(resp, "while deleting HDFS file")
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if !res.Boolean {
+		return x.Errorf("HDFS file %q not deleted", path.Join(h.prefix, p))
+	}
+	return nil
+}
+
+// hdfsError builds an error from a failed WebHDFS response, including its body. The body
+// usually holds the RemoteException thrown by the NameNode.
+func hdfsError(resp *http.Response, msg string) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return x.Errorf("Error %s: %s %s", msg, resp.Status, bytes.TrimSpace(body))
+}
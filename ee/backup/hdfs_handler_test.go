@@ -0,0 +1,169 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHDFS implements the parts of the WebHDFS REST API used by hdfsHandler. The NameNode
+// redirects the data operations to the /datanode path, like a real one does to a DataNode.
+type fakeHDFS struct {
+	sync.Mutex
+	user  string
+	files map[string][]byte
+}
+
+func (s *fakeHDFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	q := r.URL.Query()
+	if q.Get("user.name") != s.user {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	op := q.Get("op")
+	p := strings.TrimPrefix(r.URL.Path, hdfsAPIPath)
+	if strings.HasPrefix(p, "/datanode") {
+		p = strings.TrimPrefix(p, "/datanode")
+	} else if op == "CREATE" || op == "APPEND" || op == "OPEN" {
+		r.URL.Path = hdfsAPIPath + "/datanode" + p
+		http.Redirect(w, r, r.URL.String(), http.StatusTemporaryRedirect)
+		return
+	}
+
+	switch op {
+	case "CREATE":
+		s.files[p], _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+
+	case "APPEND":
+		if _, ok := s.files[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		s.files[p] = append(s.files[p], data...)
+
+	case "OPEN":
+		data, ok := s.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+
+	case "LISTSTATUS":
+		type status struct {
+			PathSuffix string `json:"pathSuffix"`
+			Type       string `json:"type"`
+		}
+		var statuses []status
+		seen := make(map[string]bool)
+		for name := range s.files {
+			if !strings.HasPrefix(name, p+"/") {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimPrefix(name, p+"/"), "/", 2)
+			st := status{PathSuffix: parts[0], Type: "FILE"}
+			if len(parts) > 1 {
+				st.Type = "DIRECTORY"
+			}
+			if !seen[st.PathSuffix] {
+				seen[st.PathSuffix] = true
+				statuses = append(statuses, st)
+			}
+		}
+		if len(statuses) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var res struct {
+			FileStatuses struct {
+				FileStatus []status
+			}
+		}
+		res.FileStatuses.FileStatus = statuses
+		json.NewEncoder(w).Encode(res)
+
+	case "DELETE":
+		_, ok := s.files[p]
+		delete(s.files, p)
+		fmt.Fprintf(w, `{"boolean": %t}`, ok)
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestHDFSBackupRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	hdfs := &fakeHDFS{user: "dgraph", files: make(map[string][]byte)}
+	srv := httptest.NewServer(hdfs)
+	defer srv.Close()
+	// Use tiny blocks so the upload spans many requests.
+	defer func(n int) { hdfsBlockSize = n }(hdfsBlockSize)
+	hdfsBlockSize = 64
+
+	require.NoError(t, os.Setenv("HADOOP_USER_NAME", "dgraph"))
+	defer os.Unsetenv("HADOOP_USER_NAME")
+	location := fmt.Sprintf("hdfs://%s/data/dgraph", strings.TrimPrefix(srv.URL, "http://"))
+	writeBackup(t, location, "20181106.011302", 0, 10,
+		testKVs("name", 5), testKVs("age", 3))
+	require.Len(t, hdfs.files, 3)
+	require.Contains(t, hdfs.files, "/data/dgraph/dgraph.20181106.011302/r10-g1.backup")
+	require.Contains(t, hdfs.files, "/data/dgraph/dgraph.20181106.011302/manifest.json")
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, location, 0))
+	for i, expected := range []*pb.KVS{testKVs("name", 5), testKVs("age", 3)} {
+		got := readKVs(t, filepath.Join(pdir, fmt.Sprintf("p%d", i+1)))
+		require.Len(t, got, len(expected.Kv))
+		for _, kv := range expected.Kv {
+			require.Equal(t, kv, got[string(kv.Key)])
+		}
+	}
+
+	// Pruning deletes the files of the older backup of the day.
+	writeBackup(t, location, "20181106.021302", 0, 20, testKVs("name", 5))
+	require.NoError(t, runPrune(&pruneOptions{location: location, keepDaily: 1}, ioutil.Discard))
+	require.Len(t, hdfs.files, 2)
+	require.Contains(t, hdfs.files, "/data/dgraph/dgraph.20181106.021302/manifest.json")
+
+	// The user given in the location is used instead of the env var.
+	hdfs.user = "spark"
+	h := &hdfsHandler{}
+	uri, err := url.Parse(location + "?user=spark")
+	require.NoError(t, err)
+	files, err := h.List(uri, ".json")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dgraph.20181106.021302/manifest.json"}, files)
+}
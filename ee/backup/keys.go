@@ -0,0 +1,182 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// keysFormat is the --out_format that lists the keys of the backups, see runKeys.
+const keysFormat = "keys"
+
+// runKeys writes the keys of the backups to o.out, or to stdout if it's not set, one per line,
+// without their values. Each line has the group of the backup file, the kind of the key, its
+// predicate, its UID, index term or count, and the whole key in hex, separated by tabs:
+//   1	data	name	0x1	000004...
+// The files are selected as runRestore does, and only the keys of o.predicates are written.
+// The keys are written as they're read, so a key is listed once for each backup of the chain
+// that has it, and the keys of concurrent groups are interleaved. Sorted, with sort -u, the
+// lists of two backups can be diffed.
+func runKeys(o *restoreOptions, p *progress) error {
+	go p.report()
+	defer p.stop()
+	sum := newSummary()
+	defer sum.close()
+
+	if o.limit == nil {
+		o.limit = NewRateLimiter(o.rateLimit)
+	}
+	if o.location != stdinLocation {
+		chain, err := Chain(o.location, o.restoreTs, &o.creds)
+		if err != nil {
+			return err
+		}
+		if err := checkEncryption(chain, o.key); err != nil {
+			return err
+		}
+	}
+	var out io.Writer = os.Stdout
+	if o.out != "" {
+		f, err := os.Create(o.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriterSize(out, 1<<20)
+	var mu sync.Mutex
+
+	preds := newPredicateSet(o.predicates, o.prefixes)
+	var gids map[uint32]bool
+	if len(o.groups) > 0 {
+		gids = make(map[uint32]bool)
+		for _, gid := range o.groups {
+			gids[uint32(gid)] = true
+		}
+	}
+	filter := func(f *loadFile) bool {
+		if gids != nil && !gids[f.group] {
+			return false
+		}
+		return preds == nil || f.preds == nil || f.since > 0 || preds.hasAny(f.preds)
+	}
+	err := o.load(filter, func(r io.Reader, f *loadFile) error {
+		if f.encryption != "" && o.key == nil {
+			return x.Errorf("Backup %q is encrypted, its key must be given with "+
+				"--encryption_key_file", f.name)
+		}
+		p.printf("Listing the keys of backup %q\n", f.name)
+		fp := p.add(f)
+		r, err := o.newReader(r, f, fp)
+		if err != nil {
+			return err
+		}
+		// The keys of a file are written in blocks, so the writes of the groups don't wait
+		// for each other at every key.
+		var buf []byte
+		flush := func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			_, err := w.Write(buf)
+			buf = buf[:0]
+			return err
+		}
+		err = readBackup(r, func(kv *pb.KV) error {
+			if len(kv.Key) < 3 {
+				return x.Errorf("Backup %q has an invalid key %x.", f.name, kv.Key)
+			}
+			if pk := x.Parse(kv.Key); pk != nil && pk.Attr != "" && !preds.has(pk.Attr) {
+				return nil
+			}
+			buf = appendKey(buf, f.group, kv.Key)
+			atomic.AddInt64(&fp.keys, 1)
+			if len(buf) < 1<<20 {
+				return nil
+			}
+			return flush()
+		})
+		if err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		p.done(fp)
+		sum.add(fp)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	sum.write(p)
+	return nil
+}
+
+// appendKey appends the line of the key of group gid to b, see runKeys.
+func appendKey(b []byte, gid uint32, key []byte) []byte {
+	b = strconv.AppendUint(b, uint64(gid), 10)
+	pk := x.Parse(key)
+	var kind, id string
+	switch {
+	case pk == nil:
+		kind = "unknown"
+	case pk.IsSchema():
+		kind = "schema"
+	case pk.IsDrop():
+		kind = "drop"
+	case pk.IsReindex():
+		kind = "reindex"
+	case pk.IsReplicated():
+		kind = "replicated"
+	case pk.IsSnapshot():
+		kind = "snapshot"
+	case pk.IsBackups():
+		kind = "backups"
+	case pk.IsData():
+		kind, id = "data", "0x"+strconv.FormatUint(pk.Uid, 16)
+	case pk.IsReverse():
+		kind, id = "reverse", "0x"+strconv.FormatUint(pk.Uid, 16)
+	case pk.IsIndex():
+		// The first byte of the term is the identifier of its tokenizer.
+		kind = "index"
+		if len(pk.Term) > 0 {
+			id = strconv.Itoa(int(pk.Term[0])) + ":" + strconv.Quote(pk.Term[1:])
+		}
+	case pk.IsCount():
+		kind, id = "count", strconv.FormatUint(uint64(pk.Count), 10)
+	}
+	var attr string
+	if pk != nil {
+		attr = pk.Attr
+	}
+	for _, s := range []string{kind, attr, id} {
+		b = append(b, '\t')
+		b = append(b, s...)
+	}
+	b = append(b, '\t')
+	b = append(b, hex.EncodeToString(key)...)
+	return append(b, '\n')
+}
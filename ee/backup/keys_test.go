@@ -0,0 +1,78 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	names := testKVs("name", 2)
+	for _, key := range [][]byte{x.IndexKey("name", "\x02alice"), x.SchemaKey("name")} {
+		names.Kv = append(names.Kv, &pb.KV{Key: key, Val: []byte("value"), Version: 1})
+	}
+	friends := &pb.KVS{}
+	for _, key := range [][]byte{x.ReverseKey("friend", 0x1a), x.CountKey("friend", 3, false)} {
+		friends.Kv = append(friends.Kv, &pb.KV{Key: key, Val: []byte("value"), Version: 1})
+	}
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, names, friends)
+
+	line := func(gid int, kind, attr, id string, key []byte) string {
+		return fmt.Sprintf("%d\t%s\t%s\t%s\t%s", gid, kind, attr, id, hex.EncodeToString(key))
+	}
+	keys := func(o *restoreOptions) []string {
+		p, err := newProgress("text", ioutil.Discard)
+		require.NoError(t, err)
+		o.location, o.outFormat, o.out = bdir, keysFormat, filepath.Join(dir, "keys")
+		require.NoError(t, runKeys(o, p))
+		b, err := ioutil.ReadFile(o.out)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+
+	require.Equal(t, []string{
+		line(1, "data", "name", "0x1", x.DataKey("name", 1)),
+		line(1, "data", "name", "0x2", x.DataKey("name", 2)),
+		line(1, "index", "name", `2:"alice"`, x.IndexKey("name", "\x02alice")),
+		line(1, "schema", "name", "", x.SchemaKey("name")),
+		line(2, "count", "friend", "3", x.CountKey("friend", 3, false)),
+		line(2, "reverse", "friend", "0x1a", x.ReverseKey("friend", 0x1a)),
+	}, keys(&restoreOptions{}))
+
+	// Only the keys of the predicates and groups asked for are listed.
+	require.Equal(t, []string{
+		line(2, "count", "friend", "3", x.CountKey("friend", 3, false)),
+		line(2, "reverse", "friend", "0x1a", x.ReverseKey("friend", 0x1a)),
+	}, keys(&restoreOptions{predicates: []string{"friend"}}))
+	require.Len(t, keys(&restoreOptions{groups: []uint{1}}), 4)
+}
@@ -0,0 +1,184 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// listOptions are the settings of a listing of the backups.
+type listOptions struct {
+	location string
+	creds    Credentials
+	// List the backups whose directory sorts after startAfter, up to limit of them, zero for
+	// all of them. See readManifestsPage.
+	startAfter string
+	limit      int
+	json       bool // write the list as a JSON object instead of a table, see listJSON
+}
+
+// listEntry is a backup in the JSON list written by runList.
+type listEntry struct {
+	Backup      string `json:"backup"`
+	Type        string `json:"type"`
+	Since       uint64 `json:"since"`
+	ReadTs      uint64 `json:"read_ts"`
+	Groups      int    `json:"groups"`
+	Size        int64  `json:"size"` // -1 if unknown
+	Compression string `json:"compression"`
+	Encryption  string `json:"encryption"`
+}
+
+// listJSON is the JSON list written by runList. NextCursor is the --start_after of the next
+// page, empty if it's the last one.
+type listJSON struct {
+	Backups    []listEntry `json:"backups"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+// listPage returns up to limit paths of the objects under the location whose name ends with
+// suffix and whose path sorts after startAfter, in lexical order, or all of them if limit is
+// zero. more is set if there are objects after the last one returned. The handlers that are
+// pageListers start listing after startAfter, the others list every object and skip them.
+func listPage(h handler, uri *url.URL, suffix, startAfter string, limit int) (
+	paths []string, more bool, err error) {
+	// One more object is listed to tell if there are more.
+	n := limit
+	if n > 0 {
+		n++
+	}
+	if pl, ok := h.(pageLister); ok {
+		paths, err = pl.ListAfter(uri, suffix, startAfter, n)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		var all []string
+		if all, err = h.List(uri, suffix); err != nil {
+			return nil, false, err
+		}
+		i := sort.SearchStrings(all, startAfter)
+		for i < len(all) && all[i] <= startAfter {
+			i++
+		}
+		paths = all[i:]
+		if n > 0 && len(paths) > n {
+			paths = paths[:n]
+		}
+	}
+	if limit > 0 && len(paths) > limit {
+		return paths[:limit], true, nil
+	}
+	return paths, false, nil
+}
+
+// backupSize returns the total size in bytes of the group files of the backup of m, or -1 if
+// the size of any one of them is unknown.
+func backupSize(h handler, uri *url.URL, m *Manifest) (int64, error) {
+	var total int64
+	dir := path.Dir(m.path)
+	for _, gid := range m.Groups {
+		name := path.Join(dir, backupName(m.ReadTs, gid))
+		r, size, err := readFile(h, uri, name)
+		if err != nil {
+			return 0, x.Wrapf(err, "while reading %q", name)
+		}
+		r.Close()
+		if size < 0 {
+			return -1, nil
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// runList writes a table of the backups at o.location to out, oldest first, with the details
+// recorded in their manifests and the size of their files. With o.startAfter and o.limit,
+// only a page of the backups is listed, followed by the cursor of the next one if there are
+// more. With o.json, the list is written as a listJSON instead.
+func runList(o *listOptions, out io.Writer) error {
+	h, uri, err := newHandler(o.location, &o.creds)
+	if err != nil {
+		return err
+	}
+	manifests, next, err := readManifestsPage(h, uri, o.startAfter, o.limit)
+	if err != nil {
+		return err
+	}
+	list := listJSON{Backups: []listEntry{}, NextCursor: next}
+	for _, m := range manifests {
+		typ := "full"
+		if m.Since > 0 {
+			typ = "incremental"
+		}
+		size, err := backupSize(h, uri, m)
+		if err != nil {
+			return err
+		}
+		list.Backups = append(list.Backups, listEntry{
+			Backup:      path.Dir(m.path),
+			Type:        typ,
+			Since:       m.Since,
+			ReadTs:      m.ReadTs,
+			Groups:      len(m.Groups),
+			Size:        size,
+			Compression: orNone(m.Compression),
+			Encryption:  orNone(m.Encryption),
+		})
+	}
+	if o.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	if len(manifests) == 0 {
+		fmt.Fprintf(out, "No backups found in %q\n", uri.String())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKUP\tTYPE\tSINCE\tREAD TS\tGROUPS\tSIZE\tCOMPRESSION\tENCRYPTION")
+	for _, e := range list.Backups {
+		sz := "-"
+		if e.Size >= 0 {
+			sz = humanize.Bytes(uint64(e.Size))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", e.Backup, e.Type, e.Since,
+			e.ReadTs, e.Groups, sz, e.Compression, e.Encryption)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if next != "" {
+		fmt.Fprintf(out, "More backups follow, list them with --start_after=%s\n", next)
+	}
+	return nil
+}
+
+// orNone returns s, or "none" if it's empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
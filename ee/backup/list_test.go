@@ -0,0 +1,122 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var out bytes.Buffer
+	require.NoError(t, runList(&listOptions{location: dir}, &out))
+	require.Contains(t, out.String(), "No backups found")
+
+	writeBackup(t, dir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+	writeBackupOpts(t, dir, "20181106.021302", 10, 20,
+		backupOpts{key: testKey, compression: "gzip"}, testKVs("name", 5))
+	// Incomplete backups aren't listed.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dgraph.20181106.031302"), 0755))
+
+	out.Reset()
+	require.NoError(t, runList(&listOptions{location: dir}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"BACKUP", "TYPE", "SINCE", "READ", "TS", "GROUPS", "SIZE",
+		"COMPRESSION", "ENCRYPTION"}, strings.Fields(lines[0]))
+	first := strings.Fields(lines[1])
+	require.Equal(t, []string{"dgraph.20181106.011302", "full", "0", "10", "2"}, first[:5])
+	require.Equal(t, []string{"none", "none"}, first[len(first)-2:])
+	second := strings.Fields(lines[2])
+	require.Equal(t, []string{"dgraph.20181106.021302", "incremental", "10", "20", "1"},
+		second[:5])
+	require.Equal(t, []string{"gzip", "aes-gcm"}, second[len(second)-2:])
+}
+
+func TestListPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	gcs := setupFakeGCS(t, dir)
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+	local := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(local, 0700))
+	var expected []string
+	for i := 0; i < 25; i++ {
+		ts := fmt.Sprintf("20181106.%06d", i)
+		writeBackup(t, "gs://bucket/dgraph", ts, 0, uint64(i+1), testKVs("name", 1))
+		writeBackup(t, local, ts, 0, uint64(i+1), testKVs("name", 1))
+		expected = append(expected, "dgraph."+ts)
+	}
+
+	// Page through the backups with the cursors of the JSON lists.
+	list := func(location, startAfter string, limit int) listJSON {
+		var out bytes.Buffer
+		o := &listOptions{location: location, startAfter: startAfter, limit: limit, json: true}
+		require.NoError(t, runList(o, &out))
+		var res listJSON
+		require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+		return res
+	}
+	for _, location := range []string{"gs://bucket/dgraph", local} {
+		var got []string
+		var pages int
+		for cursor := ""; ; pages++ {
+			res := list(location, cursor, 10)
+			require.True(t, len(res.Backups) <= 10)
+			for _, b := range res.Backups {
+				got = append(got, b.Backup)
+			}
+			if cursor = res.NextCursor; cursor == "" {
+				break
+			}
+			require.Equal(t, got[len(got)-1], cursor)
+		}
+		require.Equal(t, expected, got, location)
+		require.Equal(t, 2, pages)
+	}
+
+	// The last page only lists the objects from the manifest of the cursor on, instead of the
+	// 50 objects: the 2 files of the backup of the cursor, and the 10 of the backups after it.
+	gcs.listed = 0
+	res := list("gs://bucket/dgraph", expected[19], 10)
+	require.Len(t, res.Backups, 5)
+	require.Empty(t, res.NextCursor)
+	require.Equal(t, 12, gcs.listed)
+
+	// The table ends with the command to list the next page.
+	var out bytes.Buffer
+	require.NoError(t, runList(&listOptions{location: local, limit: 3}, &out))
+	require.Contains(t, out.String(), "--start_after="+expected[2]+"\n")
+	require.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 5)
+
+	// Verify pages through them too.
+	out.Reset()
+	o := &verifyOptions{location: local, startAfter: expected[22]}
+	require.NoError(t, runVerify(o, &out))
+	require.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 3)
+	require.NotContains(t, out.String(), "--start_after")
+}
@@ -0,0 +1,345 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgo/y"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/dgraph-io/dgraph/xidmap"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+)
+
+// liveLoader sends the data of backup files to a running cluster as mutations, instead of
+// writing it into posting directories. The UIDs of the backup are replaced by new UIDs
+// leased from the Zero of the cluster, so the cluster can have any number of groups.
+// The index, reverse and count keys are not sent, the cluster rebuilds them.
+type liveLoader struct {
+	dc    *dgo.Dgraph
+	conns []*grpc.ClientConn
+	alloc *xidmap.XidMap
+	kv    *badger.DB // store of alloc
+	xdir  string     // directory of kv
+	batch int        // N-Quads per mutation
+
+	sync.Mutex
+	schema map[string]pb.SchemaUpdate // schema of the backups, read by readSchema
+
+	skipped int64 // password values skipped, updated atomically
+}
+
+func newLiveLoader(o *restoreOptions) (*liveLoader, error) {
+	ll := &liveLoader{batch: o.batch, schema: make(map[string]pb.SchemaUpdate)}
+	if ll.batch < 1 {
+		ll.batch = 1
+	}
+	tlsConf := &x.TLSHelperConfig{}
+	dconn, err := x.SetupConnection(o.alpha, tlsConf)
+	if err != nil {
+		return nil, x.Wrapf(err, "while connecting to Dgraph alpha at %s", o.alpha)
+	}
+	ll.conns = append(ll.conns, dconn)
+	zconn, err := x.SetupConnection(o.zero, tlsConf)
+	if err != nil {
+		ll.close()
+		return nil, x.Wrapf(err, "while connecting to Dgraph zero at %s", o.zero)
+	}
+	ll.conns = append(ll.conns, zconn)
+	ll.dc = dgo.NewDgraphClient(api.NewDgraphClient(dconn))
+
+	if ll.xdir, err = ioutil.TempDir("", "restore"); err != nil {
+		ll.close()
+		return nil, err
+	}
+	bo := badger.DefaultOptions
+	bo.Dir = ll.xdir
+	bo.ValueDir = ll.xdir
+	if ll.kv, err = badger.Open(bo); err != nil {
+		ll.close()
+		return nil, err
+	}
+	ll.alloc = xidmap.New(ll.kv, zconn, xidmap.Options{NumShards: 100, LRUSize: 1e5})
+	return ll, nil
+}
+
+func (ll *liveLoader) close() {
+	if ll.kv != nil {
+		ll.kv.Close()
+	}
+	if ll.xdir != "" {
+		os.RemoveAll(ll.xdir)
+	}
+	for _, c := range ll.conns {
+		c.Close()
+	}
+}
+
+// readSchema reads the schema KVs of a backup file. The schema of the later files in the
+// chain replaces the one of the earlier files. If preds is set, the schema of the other
+// predicates is skipped.
+func (ll *liveLoader) readSchema(r io.Reader, preds *predicateSet) error {
+	errDone := x.Errorf("done")
+	err := readBackup(r, func(kv *pb.KV) error {
+		// The schema keys are sorted after the data keys and before the rest, but the drops
+		// are written before all of them.
+		if !bytes.HasPrefix(kv.Key, x.SchemaPrefix()) {
+			if kv.Key[0] > x.SchemaPrefix()[0] && !bytes.HasPrefix(kv.Key, x.DropPrefix()) {
+				return errDone
+			}
+			return nil
+		}
+		pk := x.Parse(kv.Key)
+		if pk == nil {
+			return x.Errorf("Invalid key %q in backup", kv.Key)
+		}
+		if !preds.has(pk.Attr) {
+			return nil
+		}
+		var su pb.SchemaUpdate
+		if err := su.Unmarshal(kv.Val); err != nil {
+			return x.Wrapf(err, "while decoding schema of %q", pk.Attr)
+		}
+		ll.Lock()
+		ll.schema[pk.Attr] = su
+		ll.Unlock()
+		return nil
+	})
+	if err == errDone {
+		return nil
+	}
+	return err
+}
+
+// alterSchema sets the schema read by readSchema of attrs in the cluster, or of all the
+// predicates if attrs is empty.
+func (ll *liveLoader) alterSchema(ctx context.Context, attrs ...string) error {
+	var lines []string
+	for attr, su := range ll.schema {
+		if attr == "_predicate_" || (len(attrs) > 0 && !hasAttr(attrs, attr)) {
+			continue
+		}
+		lines = append(lines, schema.Format(attr, su)+" .")
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Strings(lines)
+	return ll.dc.Alter(ctx, &api.Operation{Schema: strings.Join(lines, "\n")})
+}
+
+// hasAttr returns whether attr is one of attrs.
+func hasAttr(attrs []string, attr string) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// drop drops attr in the cluster, or all the predicates of the backups if attr is empty, as
+// the drop recorded in an incremental backup did, see x.DropKey. The other predicates of the
+// cluster are kept. The schema of the dropped predicates is set again for the data sent after
+// the drop. If preds is set, only those predicates are dropped.
+func (ll *liveLoader) drop(ctx context.Context, attr string, preds *predicateSet) error {
+	var attrs []string
+	if attr != "" {
+		if !preds.has(attr) {
+			return nil
+		}
+		attrs = append(attrs, attr)
+	} else {
+		for a := range ll.schema {
+			if a != "_predicate_" {
+				attrs = append(attrs, a)
+			}
+		}
+		sort.Strings(attrs)
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	for _, a := range attrs {
+		if err := ll.dc.Alter(ctx, &api.Operation{DropAttr: a}); err != nil {
+			return x.Wrapf(err, "while dropping predicate %q", a)
+		}
+	}
+	return ll.alterSchema(ctx, attrs...)
+}
+
+// alterSchemaFile sets the schema in file in the cluster. Set after the data is sent, the
+// cluster rebuilds the indexes of the data to match it.
+func (ll *liveLoader) alterSchemaFile(ctx context.Context, file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return ll.dc.Alter(ctx, &api.Operation{Schema: string(b)})
+}
+
+// send reads the data KVs of backup file f from r and sends them to the cluster as
+// mutations. The data of a key in an incremental backup replaces the data sent before for
+// it. If preds is set, the data of the other predicates is skipped.
+// The sent keys are counted in fp.
+func (ll *liveLoader) send(ctx context.Context, r io.Reader, f *loadFile,
+	preds *predicateSet, fp *fileProgress) error {
+	var set, del []*api.NQuad
+	flush := func() error {
+		// The old data has to be deleted before the new data is set.
+		if len(del) > 0 {
+			if err := ll.mutate(ctx, &api.Mutation{Del: del}); err != nil {
+				return err
+			}
+		}
+		if len(set) > 0 {
+			if err := ll.mutate(ctx, &api.Mutation{Set: set}); err != nil {
+				return err
+			}
+		}
+		set, del = set[:0], del[:0]
+		return nil
+	}
+
+	err := readBackup(r, func(kv *pb.KV) error {
+		pk := x.Parse(kv.Key)
+		if pk == nil {
+			return x.Errorf("Invalid key %q in backup", kv.Key)
+		}
+		if pk.IsDrop() {
+			// The data sent before the drop has to be dropped too.
+			if err := flush(); err != nil {
+				return err
+			}
+			return ll.drop(ctx, pk.Attr, preds)
+		}
+		// Only the data keys are sent. Schema keys are data keys too.
+		if pk.IsSchema() || !pk.IsData() || pk.Attr == "_predicate_" {
+			return nil
+		}
+		if !preds.has(pk.Attr) {
+			return nil
+		}
+		subject := ll.uid(pk.Uid)
+		if f.since > 0 {
+			del = append(del, &api.NQuad{
+				Subject:     subject,
+				Predicate:   pk.Attr,
+				ObjectValue: &api.Value{Val: &api.Value_DefaultVal{DefaultVal: x.Star}},
+			})
+		}
+		nqs, err := ll.toNQuads(subject, pk.Attr, kv)
+		if err != nil {
+			return err
+		}
+		set = append(set, nqs...)
+		atomic.AddInt64(&fp.keys, 1)
+		x.RestoreKeys.Add(1)
+		if len(set)+len(del) >= ll.batch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// toNQuads converts the posting list in kv into the N-Quads of subject and attr.
+func (ll *liveLoader) toNQuads(subject, attr string, kv *pb.KV) ([]*api.NQuad, error) {
+	var meta byte
+	if len(kv.UserMeta) > 0 {
+		meta = kv.UserMeta[0]
+	}
+	if meta&posting.BitCompletePosting == 0 {
+		return nil, nil
+	}
+	var pl pb.PostingList
+	if err := pl.Unmarshal(kv.Val); err != nil {
+		return nil, x.Wrapf(err, "while decoding posting list of key %q", kv.Key)
+	}
+
+	var nqs []*api.NQuad
+	var pitr posting.PIterator
+	for pitr.Init(&pl, 0); pitr.Valid(); pitr.Next() {
+		p := pitr.Posting()
+		nq := &api.NQuad{Subject: subject, Predicate: attr, Facets: p.Facets}
+		if p.PostingType == pb.Posting_REF {
+			nq.ObjectId = ll.uid(p.Uid)
+			nqs = append(nqs, nq)
+			continue
+		}
+		vID := types.TypeID(p.ValType)
+		if vID == types.PasswordID {
+			// Passwords are stored hashed, setting them again would hash them twice.
+			atomic.AddInt64(&ll.skipped, 1)
+			continue
+		}
+		val, err := types.Convert(types.Val{Tid: types.BinaryID, Value: p.Value}, vID)
+		if err != nil {
+			return nil, x.Wrapf(err, "while decoding value of key %q", kv.Key)
+		}
+		if nq.ObjectValue, err = types.ObjectValue(vID, val.Value); err != nil {
+			return nil, x.Wrapf(err, "while decoding value of key %q", kv.Key)
+		}
+		nq.Lang = string(p.LangTag)
+		nqs = append(nqs, nq)
+	}
+	return nqs, nil
+}
+
+// uid returns the UID in the cluster of a UID of the backup.
+func (ll *liveLoader) uid(uid uint64) string {
+	newUid, _ := ll.alloc.AssignUid(fmt.Sprintf("%#x", uid))
+	return fmt.Sprintf("%#x", newUid)
+}
+
+// mutate commits mu, retrying it while it's aborted by conflicting transactions.
+func (ll *liveLoader) mutate(ctx context.Context, mu *api.Mutation) error {
+	mu.CommitNow = true
+	for delay := 10 * time.Millisecond; ; delay *= 2 {
+		_, err := ll.dc.NewTxn().Mutate(ctx, mu)
+		if err != y.ErrAborted && err != y.ErrConflict {
+			return err
+		}
+		glog.V(2).Infof("Restore: mutation aborted, retrying: %v", err)
+		if delay > 10*time.Second {
+			delay = 10 * time.Second
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveReadSchema(t *testing.T) {
+	schemaKV := func(attr string, su *pb.SchemaUpdate) *pb.KV {
+		b, err := su.Marshal()
+		require.NoError(t, err)
+		return &pb.KV{Key: x.SchemaKey(attr), Val: b, Version: 1}
+	}
+	var buf bytes.Buffer
+	w := &writer{w: &buf}
+	for _, kv := range []*pb.KV{
+		{Key: x.DataKey("name", 1), Val: []byte("val"), Version: 2},
+		schemaKV("age", &pb.SchemaUpdate{Predicate: "age", ValueType: pb.Posting_INT}),
+		schemaKV("name", &pb.SchemaUpdate{Predicate: "name", ValueType: pb.Posting_STRING,
+			Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"exact"}}),
+		{Key: x.IndexKey("name", "val"), Val: []byte("garbage"), Version: 2},
+	} {
+		require.NoError(t, w.write(kv))
+	}
+
+	ll := &liveLoader{schema: make(map[string]pb.SchemaUpdate)}
+	require.NoError(t, ll.readSchema(bytes.NewReader(buf.Bytes()),
+		newPredicateSet([]string{"name"}, nil)))
+	require.Len(t, ll.schema, 1)
+	require.Equal(t, "name:string @index(exact)", schema.Format("name", ll.schema["name"]))
+}
+
+func TestLiveToNQuads(t *testing.T) {
+	value := func(tid types.TypeID, v interface{}) []byte {
+		out := types.ValueForType(types.BinaryID)
+		require.NoError(t, types.Marshal(types.Val{Tid: tid, Value: v}, &out))
+		return out.Value.([]byte)
+	}
+	postings := []*pb.Posting{
+		{Uid: 10, ValType: pb.Posting_INT, Value: value(types.IntID, int64(21)),
+			PostingType: pb.Posting_VALUE},
+		{Uid: 20, ValType: pb.Posting_STRING, Value: []byte("hallo"), LangTag: []byte("de"),
+			PostingType: pb.Posting_VALUE_LANG,
+			Facets:      []*api.Facet{{Key: "since", Value: []byte("2018")}}},
+		{Uid: 30, ValType: pb.Posting_PASSWORD, Value: []byte("$2a$10$hash"),
+			PostingType: pb.Posting_VALUE},
+	}
+	enc := codec.Encoder{BlockSize: 10}
+	for _, p := range postings {
+		enc.Add(p.Uid)
+	}
+	pl := &pb.PostingList{Pack: enc.Done(), Postings: postings}
+	val, err := pl.Marshal()
+	require.NoError(t, err)
+
+	ll := &liveLoader{}
+	kv := &pb.KV{Key: x.DataKey("age", 1), Val: val,
+		UserMeta: []byte{posting.BitCompletePosting}}
+	nqs, err := ll.toNQuads("0x1", "age", kv)
+	require.NoError(t, err)
+	require.Len(t, nqs, 2)
+	require.Equal(t, &api.Value{Val: &api.Value_IntVal{IntVal: 21}}, nqs[0].ObjectValue)
+	require.Equal(t, "", nqs[0].Lang)
+	require.Equal(t, &api.Value{Val: &api.Value_StrVal{StrVal: "hallo"}}, nqs[1].ObjectValue)
+	require.Equal(t, "de", nqs[1].Lang)
+	require.Equal(t, postings[1].Facets, nqs[1].Facets)
+	require.Equal(t, int64(1), ll.skipped)
+
+	// Only complete posting lists are sent.
+	kv.UserMeta = nil
+	nqs, err = ll.toNQuads("0x1", "age", kv)
+	require.NoError(t, err)
+	require.Empty(t, nqs)
+}
@@ -0,0 +1,102 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+	bpb "github.com/dgraph-io/badger/protos"
+)
+
+// loadWriter writes KVs into a badger DB with DB.Load, the bulk write path of the vendored
+// badger, which has no StreamWriter. Load writes the KVs in batches at their versions, without
+// the transaction of each KV committed by x.TxnWriter. But the KVs are only known to be written
+// once the writer is closed, and nothing else can write into the DB meanwhile, so it's only
+// used for the full backups, with --load_writer.
+type loadWriter struct {
+	pw   *io.PipeWriter
+	bw   *bufio.Writer
+	buf  []byte
+	done chan error // the result of Load
+
+	once sync.Once
+	err  error
+}
+
+// newLoadWriter starts loading the KVs written to the writer into db. The writer must be
+// closed, which waits for them to be written.
+func newLoadWriter(db *badger.DB) *loadWriter {
+	pr, pw := io.Pipe()
+	lw := &loadWriter{pw: pw, bw: bufio.NewWriterSize(pw, 1<<20), done: make(chan error, 1)}
+	go func() {
+		err := db.Load(pr)
+		// A Load that fails stops reading, so the writes must fail too.
+		if err != nil {
+			pr.CloseWithError(err)
+		}
+		lw.done <- err
+	}()
+	return lw
+}
+
+// SetAt writes the KV at version ts, in the format of the badger backups that Load reads.
+// Like x.TxnWriter, it skips the KVs without a version. The key and value are copied, they
+// can be reused once it returns.
+func (lw *loadWriter) SetAt(key, val []byte, meta byte, ts uint64) error {
+	if ts == 0 {
+		return nil
+	}
+	kv := bpb.KVPair{Key: key, Value: val, UserMeta: []byte{meta}, Version: ts,
+		Meta: []byte{0}}
+	sz := kv.Size()
+	if cap(lw.buf) < 8+sz {
+		lw.buf = make([]byte, 8+sz)
+	}
+	b := lw.buf[:8+sz]
+	binary.LittleEndian.PutUint64(b, uint64(sz))
+	if _, err := kv.MarshalTo(b[8:]); err != nil {
+		return err
+	}
+	_, err := lw.bw.Write(b)
+	return err
+}
+
+// check returns the error of Load if it failed already. The KVs written so far may not be
+// written into the DB yet.
+func (lw *loadWriter) check() error {
+	select {
+	case err := <-lw.done:
+		lw.once.Do(func() { lw.err = err })
+		return lw.err
+	default:
+		return nil
+	}
+}
+
+// close waits for the KVs written to be written into the DB, and returns the error of Load.
+// No KV can be written afterwards. It can be called more than once.
+func (lw *loadWriter) close() error {
+	lw.once.Do(func() {
+		if err := lw.bw.Flush(); err != nil {
+			lw.pw.CloseWithError(err)
+		} else {
+			lw.pw.Close()
+		}
+		lw.err = <-lw.done
+	})
+	return lw.err
+}
@@ -0,0 +1,144 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreLoadWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The incremental backup updates some keys of the full one, at higher versions.
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackupOpts(t, bdir, "20181106.011302", 0, 1000, backupOpts{ordered: true},
+		testKVs("name", 500), testKVs("age", 30))
+	updates := testKVs("name", 10)
+	for _, kv := range updates.Kv {
+		kv.Val = []byte(fmt.Sprintf("new-%s", kv.Val))
+		kv.Version += 1000
+	}
+	writeBackup(t, bdir, "20181106.021302", 1000, 2000, updates, &pb.KVS{})
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	for _, load := range []bool{false, true} {
+		o := &restoreOptions{location: bdir, pdir: filepath.Join(dir, fmt.Sprint(load)),
+			loadWriter: load}
+		require.NoError(t, runRestore(o, p))
+	}
+
+	// The DBs written with the load writer have the same keys at the same versions.
+	for _, group := range []string{"p1", "p2"} {
+		expected := readKVs(t, filepath.Join(dir, "false", group))
+		got := readKVs(t, filepath.Join(dir, "true", group))
+		require.NotEmpty(t, expected)
+		require.Equal(t, expected, got)
+	}
+	got := readKVs(t, filepath.Join(dir, "true", "p1"))
+	require.Len(t, got, 500)
+	for _, kv := range updates.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func TestRestoreLoadWriterResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(n int64) { checkpointKeys = n }(checkpointKeys)
+	checkpointKeys = 10
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	kvs := testKVs("name", 100)
+	writeBackupOpts(t, bdir, "20181106.011302", 0, 10, backupOpts{ordered: true}, kvs)
+	name := filepath.Join(bdir, "dgraph.20181106.011302", "r10-g1.backup")
+	orig, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	mb, err := ioutil.ReadFile(filepath.Join(bdir, "dgraph.20181106.011302", manifestName))
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(mb, &m))
+	m.Checksums = nil
+	require.NoError(t, WriteManifest(bdir, "20181106.011302", &m))
+
+	// The restore dies at the truncated frame of the 56th KV, after its checkpoint at 49. The
+	// KVs before the checkpoint are written.
+	require.NoError(t, ioutil.WriteFile(name, orig[:len(orig)*55/100], 0600))
+	pdir := filepath.Join(dir, "postings")
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, resume: true,
+		loadWriter: true}
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	require.Error(t, runRestore(o, p))
+	cps, err := openCheckpoints(o)
+	require.NoError(t, err)
+	require.Equal(t, int64(49), cps.get("dgraph.20181106.011302/r10-g1.backup").Keys)
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	for _, kv := range kvs.Kv[:49] {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+
+	// The restore resumed from the checkpoint writes the rest.
+	require.NoError(t, ioutil.WriteFile(name, orig, 0600))
+	var msgs bytes.Buffer
+	p.msgs = &msgs
+	require.NoError(t, runRestore(o, p))
+	require.Contains(t, msgs.String(), "after 49 keys")
+	got = readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 100)
+	for _, kv := range kvs.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func BenchmarkRestoreLoadWriter(b *testing.B) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(b, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(b, os.Mkdir(bdir, 0700))
+	writeBackupOpts(b, bdir, "20181106.011302", 0, 10, backupOpts{ordered: true},
+		testKVs("name", 1000000))
+
+	for _, load := range []bool{false, true} {
+		b.Run(fmt.Sprintf("load_writer=%v", load), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p, err := newProgress("text", ioutil.Discard)
+				require.NoError(b, err)
+				p.msgs = ioutil.Discard
+				pdir := filepath.Join(dir, "postings")
+				o := &restoreOptions{location: bdir, pdir: pdir, loadWriter: load}
+				require.NoError(b, runRestore(o, p))
+				require.NoError(b, os.RemoveAll(pdir))
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// lockName is the file Badger writes the ID of the process that has a DB open for writes to.
+// The lock itself is released when the process dies, but a process that was killed leaves
+// the file behind.
+const lockName = "LOCK"
+
+// clearStaleLocks removes the lock files left under pdir by the processes that aren't running
+// any more, like a restore that was killed, and reports each one with p. It fails if the
+// process of a lock file is still running, whose lock is never removed, or if the process
+// can't be told from the file.
+func clearStaleLocks(pdir string, p *progress) error {
+	dirs, err := filepath.Glob(filepath.Join(pdir, "p*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range append([]string{pdir}, dirs...) {
+		path := filepath.Join(dir, lockName)
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || pid <= 0 {
+			return x.Errorf("The lock file %q doesn't have the ID of its process, remove it "+
+				"once no process uses %q.", path, dir)
+		}
+		if pid == os.Getpid() || processRunning(pid) {
+			return x.Errorf("The Badger DB in %q is in use by process %d, stop it before "+
+				"restoring into %q.", dir, pid, pdir)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		p.printf("Warning: removed the stale lock %q of process %d, which isn't running\n",
+			path, pid)
+	}
+	return nil
+}
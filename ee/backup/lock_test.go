@@ -0,0 +1,73 @@
+// +build !oss,!windows
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreClearStaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+
+	// A restore was killed, and left the lock of its process, which has exited since.
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+	dead := exec.Command("true")
+	require.NoError(t, dead.Run())
+	lock := filepath.Join(pdir, "p1", lockName)
+	writeLock := func(pid int) {
+		require.NoError(t, ioutil.WriteFile(lock, []byte(fmt.Sprintf("%d\n", pid)), 0666))
+	}
+
+	// The lock of a running process is kept.
+	live := exec.Command("sleep", "60")
+	require.NoError(t, live.Start())
+	defer func() {
+		x.Ignore(live.Process.Kill())
+		x.Ignore(live.Wait())
+	}()
+	writeLock(live.Process.Pid)
+	var buf bytes.Buffer
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = &buf
+	o := &restoreOptions{location: bdir, pdir: pdir, force: true, clearStaleLock: true}
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), fmt.Sprintf("is in use by process %d", live.Process.Pid))
+	_, err = os.Stat(lock)
+	require.NoError(t, err)
+
+	// The stale one is removed, and the restore goes ahead.
+	writeLock(dead.Process.Pid)
+	require.NoError(t, runRestore(o, p))
+	require.Contains(t, buf.String(), fmt.Sprintf("removed the stale lock %q of process %d",
+		lock, dead.Process.Pid))
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p1")), 5)
+}
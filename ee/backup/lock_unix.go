@@ -0,0 +1,24 @@
+// +build !oss,!windows
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// processRunning returns whether the process pid is running. A process that exists but can't
+// be signaled by this one is running too.
+func processRunning(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
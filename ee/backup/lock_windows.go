@@ -0,0 +1,30 @@
+// +build !oss,windows
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"os"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// processRunning returns whether the process pid is running. On Windows, finding a process
+// opens it, which fails if it isn't running.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	x.Ignore(p.Release())
+	return true
+}
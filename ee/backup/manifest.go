@@ -0,0 +1,199 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// manifestName is the name of the manifest file written in each backup directory.
+const manifestName = "manifest.json"
+
+// Manifest records the details of a backup. It's written next to the group files once all
+// the groups have completed their part, so a backup directory without one is incomplete.
+type Manifest struct {
+	// Version is the version of the Dgraph Alpha that took the backup.
+	Version string `json:"version"`
+	// Since is the read timestamp of the previous backup in the chain. Only the data committed
+	// after it is included in this backup. It's zero for a full backup.
+	Since uint64 `json:"since"`
+	// ReadTs is the timestamp at which the backup was taken.
+	ReadTs uint64 `json:"read_ts"`
+	// Groups are the IDs of the groups included in the backup.
+	Groups []uint32 `json:"groups"`
+	// Checksums are the hex SHA-256 of each group's backup file, by group ID.
+	Checksums map[uint32]string `json:"checksums,omitempty"`
+	// Predicates are the predicates included in each group's backup file, by group ID.
+	// For incremental backups, only the predicates changed since the previous backup.
+	Predicates map[uint32][]string `json:"predicates,omitempty"`
+	// Compression is the codec the backup files are compressed with, "gzip", or empty if
+	// they aren't.
+	Compression string `json:"compression,omitempty"`
+	// Encryption is the cipher the backup files are encrypted with, "aes-gcm", or empty if
+	// they aren't.
+	Encryption string `json:"encryption,omitempty"`
+	// PredicatePrefixes are the prefixes of the predicates included in the backup, if only
+	// the predicates starting with them were backed up.
+	PredicatePrefixes []string `json:"predicate_prefixes,omitempty"`
+	// SkipIndexes is set if the index, reverse and count keys weren't backed up. Restore
+	// rebuilds them from the data.
+	SkipIndexes bool `json:"skip_indexes,omitempty"`
+	// Ordered is set if the keys of each backup file are in key order, as in the full backups.
+	// Restore can then write them with --load_writer.
+	Ordered bool `json:"ordered,omitempty"`
+
+	// path is the location of the manifest, relative to the backup location.
+	path string
+}
+
+// Time returns the time the backup was started at, from the name of its directory.
+func (m *Manifest) Time() (time.Time, error) {
+	dir := path.Base(path.Dir(m.path))
+	t, err := time.Parse("20060102.150405", strings.TrimPrefix(dir, "dgraph."))
+	if err != nil {
+		return time.Time{}, x.Wrapf(err, "while parsing the time of backup %q", m.path)
+	}
+	return t, nil
+}
+
+// WriteManifest writes the manifest of the backup started at unixTs to target.
+func WriteManifest(target, unixTs string, m *Manifest) error {
+	h, uri, err := newHandler(target, nil)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := h.Create(uri, path.Join(backupDir(unixTs), manifestName)); err != nil {
+		return err
+	}
+	if _, err := h.Write(b); err != nil {
+		x.Ignore(h.Close())
+		return err
+	}
+	return h.Close()
+}
+
+// LatestManifest returns the manifest of the most recent backup at location l,
+// or nil if there are no backups there yet.
+func LatestManifest(l string) (*Manifest, error) {
+	h, uri, err := newHandler(l, nil)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := readManifests(h, uri)
+	if err != nil || len(manifests) == 0 {
+		return nil, err
+	}
+	return manifests[len(manifests)-1], nil
+}
+
+// readManifests reads all the manifests at the location, sorted by their read timestamp.
+func readManifests(h handler, uri *url.URL) ([]*Manifest, error) {
+	paths, err := h.List(uri, manifestName)
+	if err != nil {
+		return nil, err
+	}
+	return readManifestFiles(h, uri, paths)
+}
+
+// readManifestsPage reads up to limit manifests of the backups at the location whose
+// directory sorts after the directory startAfter, or all of them if limit is zero, sorted
+// by their read timestamp. It also returns the cursor of the next page, the directory of the
+// last backup read, or an empty one if there are no more backups. See listPage.
+func readManifestsPage(h handler, uri *url.URL, startAfter string, limit int) (
+	[]*Manifest, string, error) {
+	if startAfter != "" {
+		// The manifest of the directory startAfter sorts before the other files in it.
+		startAfter = path.Join(startAfter, manifestName)
+	}
+	paths, more, err := listPage(h, uri, manifestName, startAfter, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	manifests, err := readManifestFiles(h, uri, paths)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if more {
+		next = path.Dir(paths[len(paths)-1])
+	}
+	return manifests, next, nil
+}
+
+// readManifestFiles reads the manifests at paths, sorted by their read timestamp.
+func readManifestFiles(h handler, uri *url.URL, paths []string) ([]*Manifest, error) {
+	var manifests []*Manifest
+	for _, p := range paths {
+		r, _, err := h.Read(uri, p)
+		if err != nil {
+			return nil, err
+		}
+		var m Manifest
+		err = json.NewDecoder(r).Decode(&m)
+		x.Ignore(r.Close())
+		if err != nil {
+			return nil, x.Wrapf(err, "while reading manifest %q", p)
+		}
+		m.path = p
+		manifests = append(manifests, &m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].ReadTs < manifests[j].ReadTs
+	})
+	return manifests, nil
+}
+
+// restoreChain returns the manifests needed to restore the latest backed up state: the most
+// recent full backup, followed by the incremental backups taken after it, in order.
+// If restoreTs is set, the chain ends at the latest backup taken at or before restoreTs. The
+// backups are applied whole, so the data is restored as of the read ts of that backup.
+func restoreChain(manifests []*Manifest, restoreTs uint64) ([]*Manifest, error) {
+	start := -1
+	for i, m := range manifests {
+		if m.Since == 0 && (restoreTs == 0 || m.ReadTs <= restoreTs) {
+			start = i
+		}
+	}
+	if start < 0 {
+		if restoreTs > 0 {
+			return nil, x.Errorf("No full backup found at or before ts %d", restoreTs)
+		}
+		return nil, x.Errorf("No full backup found to start the restore from")
+	}
+
+	chain := manifests[start : start+1]
+	for _, m := range manifests[start+1:] {
+		// Backups taken after restoreTs have changes committed after it.
+		if m.Since == 0 || (restoreTs > 0 && m.ReadTs > restoreTs) {
+			break
+		}
+		if prev := chain[len(chain)-1]; m.Since != prev.ReadTs {
+			return nil, x.Errorf("Broken backup chain: %q was taken since ts %d, "+
+				"but the previous backup %q was taken at ts %d",
+				m.path, m.Since, prev.path, prev.ReadTs)
+		}
+		chain = append(chain, m)
+	}
+	return chain, nil
+}
@@ -0,0 +1,125 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/y"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// How the local backup files are read, see --local_read.
+const (
+	localReadBuffered = "buffered"
+	localReadMmap     = "mmap"
+)
+
+// mmapReader reads a local backup file mapped into memory. Plain files are decoded by
+// readBackup straight from the mapping, see newReader, which saves the read calls and the copy
+// of the bytes of every KV into a buffer. Encrypted or compressed files are read from it like
+// from any reader.
+type mmapReader struct {
+	data []byte
+	off  int
+	// Where the frames continue once the header is read, see seekFrames. Zero if they follow.
+	skipTo int
+
+	// Set by newReader when the KVs are read from the mapping.
+	fp    *fileProgress // counts the bytes read
+	limit *RateLimiter  // limits the rate they're read at
+}
+
+// mmapFile maps the backup file r into memory, if it's a regular file that isn't empty.
+// Otherwise, it returns nil and r must be read as it is. The reader must be closed once read.
+func mmapFile(r io.Reader) (*mmapReader, error) {
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil, nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return nil, nil
+	}
+	data, err := y.Mmap(f, false, fi.Size())
+	if err != nil {
+		return nil, x.Wrapf(err, "while mapping %q into memory", f.Name())
+	}
+	return &mmapReader{data: data}, nil
+}
+
+// seekFrames moves the reader to offset bytes after the header of the file, but only once the
+// header is read again, see the function seekFrames.
+func (mr *mmapReader) seekFrames(offset int64) {
+	hl := headerLen(mr.data)
+	mr.off, mr.skipTo = 0, hl+int(offset)
+	if mr.skipTo > len(mr.data) {
+		mr.skipTo = len(mr.data)
+	}
+	mr.skip()
+}
+
+// skip moves the reader to skipTo once it's past the header.
+func (mr *mmapReader) skip() {
+	if mr.skipTo > 0 && mr.off == headerLen(mr.data) {
+		mr.off, mr.skipTo = mr.skipTo, 0
+	}
+}
+
+func (mr *mmapReader) Read(b []byte) (int, error) {
+	mr.skip()
+	if mr.off == len(mr.data) {
+		return 0, io.EOF
+	}
+	n := copy(b, mr.data[mr.off:])
+	mr.off += n
+	return n, nil
+}
+
+func (mr *mmapReader) peek(n int) ([]byte, error) {
+	mr.skip()
+	if rest := len(mr.data) - mr.off; n > rest {
+		n = rest
+	}
+	return mr.data[mr.off : mr.off+n], nil
+}
+
+func (mr *mmapReader) next(n int64) ([]byte, error) {
+	mr.skip()
+	if rest := int64(len(mr.data) - mr.off); n > rest {
+		n = rest
+	}
+	b := mr.data[mr.off : mr.off+int(n)]
+	mr.off += int(n)
+	mr.limit.wait(len(b))
+	if mr.fp != nil {
+		atomic.AddInt64(&mr.fp.bytes, int64(len(b)))
+	}
+	x.RestoreBytes.Add(int64(len(b)))
+	return b, nil
+}
+
+// Close unmaps the file. The bytes returned by next can't be used afterwards.
+func (mr *mmapReader) Close() error {
+	if mr.data == nil {
+		return nil
+	}
+	err := y.Munmap(mr.data)
+	mr.data = nil
+	return err
+}
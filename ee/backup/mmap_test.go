@@ -0,0 +1,69 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreMmap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The plain file is decoded from the mapping, the compressed one is read from it.
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 50))
+	writeBackupOpts(t, bdir, "20181106.021302", 10, 20, backupOpts{compression: "gzip"},
+		testKVs("age", 20))
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	pdir := filepath.Join(dir, "postings")
+	o := &restoreOptions{location: bdir, pdir: pdir, localRead: localReadMmap}
+	require.NoError(t, runRestore(o, p))
+
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 70)
+	for _, kv := range append(testKVs("name", 50).Kv, testKVs("age", 20).Kv...) {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func BenchmarkRestoreLocalRead(b *testing.B) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(b, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(b, os.Mkdir(bdir, 0700))
+	writeBackup(b, bdir, "20181106.011302", 0, 10, testKVs("name", 1000000))
+
+	for _, mode := range []string{localReadBuffered, localReadMmap} {
+		b.Run(mode, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p, err := newProgress("text", ioutil.Discard)
+				require.NoError(b, err)
+				p.msgs = ioutil.Discard
+				o := &restoreOptions{location: bdir, dryRun: true, localRead: mode}
+				require.NoError(b, runRestore(o, p))
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// partSuffix separates the name of a backup file from the number of its part, when the file
+// was split into parts to transfer it: r10-g1.backup.part0, r10-g1.backup.part1 and so on.
+const partSuffix = ".part"
+
+// readFile returns a reader of the backup file name at the location, and its size in bytes or
+// -1 if unknown. A file that isn't found is read from its parts instead, if it has any, as if
+// they were concatenated in the order of their numbers.
+func readFile(h handler, uri *url.URL, name string) (io.ReadCloser, int64, error) {
+	r, size, err := h.Read(uri, name)
+	if err == nil {
+		return r, size, nil
+	}
+	parts, perr := findParts(h, uri, name)
+	if perr != nil {
+		return nil, 0, perr
+	}
+	if len(parts) == 0 {
+		return nil, 0, err
+	}
+
+	// Each part is opened to get its size, then again once the previous part is read.
+	var total int64
+	for _, part := range parts {
+		r, size, err := h.Read(uri, part)
+		if err != nil {
+			return nil, 0, x.Wrapf(err, "while reading %q", part)
+		}
+		x.Ignore(r.Close())
+		if size < 0 || total < 0 {
+			total = -1
+		} else {
+			total += size
+		}
+	}
+	return &partsReader{h: h, uri: uri, parts: parts}, total, nil
+}
+
+// deleteFile deletes the backup file name at the location, or its parts if it was split.
+func deleteFile(h handler, uri *url.URL, name string) error {
+	err := h.Delete(uri, name)
+	if err == nil {
+		return nil
+	}
+	parts, perr := findParts(h, uri, name)
+	if perr != nil {
+		return perr
+	}
+	if len(parts) == 0 {
+		return err
+	}
+	for _, part := range parts {
+		if err := h.Delete(uri, part); err != nil {
+			return x.Wrapf(err, "while deleting %q", part)
+		}
+	}
+	return nil
+}
+
+// findParts returns the paths of the parts of the backup file name, in order, or nil if it has
+// none. It fails if any part is missing.
+func findParts(h handler, uri *url.URL, name string) ([]string, error) {
+	paths, err := h.List(uri, "")
+	if err != nil {
+		return nil, err
+	}
+	prefix := name + partSuffix
+	found := make(map[int]string)
+	for _, p := range paths {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if n, err := strconv.Atoi(p[len(prefix):]); err == nil && n >= 0 {
+			found[n] = p
+		}
+	}
+	parts := make([]string, len(found))
+	for i := range parts {
+		p, ok := found[i]
+		if !ok {
+			return nil, x.Errorf("Part %d of backup file %q is missing", i, name)
+		}
+		parts[i] = p
+	}
+	return parts, nil
+}
+
+// partsReader reads the parts of a backup file one after the other. Each part is opened once
+// the previous one is read, so only one of them is open at a time.
+type partsReader struct {
+	h     handler
+	uri   *url.URL
+	parts []string      // parts left to open
+	cur   io.ReadCloser // part being read, nil if none
+}
+
+func (pr *partsReader) Read(b []byte) (int, error) {
+	for {
+		if pr.cur == nil {
+			if len(pr.parts) == 0 {
+				return 0, io.EOF
+			}
+			r, _, err := pr.h.Read(pr.uri, pr.parts[0])
+			if err != nil {
+				return 0, x.Wrapf(err, "while reading %q", pr.parts[0])
+			}
+			pr.cur, pr.parts = r, pr.parts[1:]
+		}
+		n, err := pr.cur.Read(b)
+		if err != io.EOF {
+			return n, err
+		}
+		x.Ignore(pr.cur.Close())
+		pr.cur = nil
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (pr *partsReader) Close() error {
+	if pr.cur == nil {
+		return nil
+	}
+	err := pr.cur.Close()
+	pr.cur = nil
+	return err
+}
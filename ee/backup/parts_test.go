@@ -0,0 +1,65 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreParts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 50))
+
+	// Split the file into parts of 100 bytes, out of the order of their names.
+	name := filepath.Join(bdir, "dgraph.20181106.011302", "r10-g1.backup")
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(name))
+	var parts int
+	for ; len(b) > 0; parts++ {
+		n := 100
+		if n > len(b) {
+			n = len(b)
+		}
+		part := fmt.Sprintf("%s.part%d", name, parts)
+		require.NoError(t, ioutil.WriteFile(part, b[:n], 0600))
+		b = b[n:]
+	}
+	require.True(t, parts > 10)
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+	expected := testKVs("name", 50)
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, len(expected.Kv))
+	for _, kv := range expected.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+
+	require.NoError(t, os.Remove(name+".part3"))
+	err = restore(t, filepath.Join(dir, "missing"), bdir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Part 3 of backup file")
+	require.Contains(t, err.Error(), "is missing")
+}
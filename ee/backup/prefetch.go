@@ -0,0 +1,161 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync/atomic"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+// prefetcher downloads the backup files of a remote location into a staging directory ahead
+// of their load, so the downloads overlap the loads. Up to max files are staged at once, for
+// all the groups together: the downloads wait for the files loaded to be removed, so a slow
+// load doesn't fill the disk. The checksum of a file is verified as it's downloaded, instead
+// of reading it twice.
+type prefetcher struct {
+	dir   string
+	slots chan struct{} // one for each file staged, or being downloaded
+}
+
+// newPrefetcher returns a prefetcher that stages up to max files in a new directory under
+// tmpDir, or under the default temporary directory if it's empty.
+func newPrefetcher(tmpDir string, max int) (*prefetcher, error) {
+	dir, err := ioutil.TempDir(tmpDir, "restore-prefetch")
+	if err != nil {
+		return nil, err
+	}
+	return &prefetcher{dir: dir, slots: make(chan struct{}, max)}, nil
+}
+
+// close removes the staging directory, and the files left in it.
+func (pf *prefetcher) close() error {
+	return os.RemoveAll(pf.dir)
+}
+
+// stagedFile is a backup file downloaded by a prefetcher, or the error downloading it.
+type stagedFile struct {
+	path string
+	size int64
+	err  error
+}
+
+// release removes the staged file sf, and frees its slot.
+func (pf *prefetcher) release(sf stagedFile) {
+	if sf.path != "" {
+		x.Ignore(os.Remove(sf.path))
+	}
+	<-pf.slots
+}
+
+// load calls fn for each one of the files of a group, in order, as loadGroup does, once it's
+// downloaded. It stops early if failed is set by another group.
+func (pf *prefetcher) load(h handler, uri *url.URL, files []*loadFile, failed *int32,
+	fn loadFn) error {
+	done := make(chan struct{})
+	staged := pf.fetch(h, uri, files, done)
+	defer func() {
+		close(done)
+		for sf := range staged {
+			pf.release(sf)
+		}
+	}()
+	for _, f := range files {
+		if atomic.LoadInt32(failed) != 0 {
+			return nil
+		}
+		sf := <-staged
+		err := loadStaged(sf, f, fn)
+		pf.release(sf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadStaged calls fn for the file f, staged in sf.
+func loadStaged(sf stagedFile, f *loadFile, fn loadFn) error {
+	if sf.err != nil {
+		return sf.err
+	}
+	fd, err := os.Open(sf.path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	glog.V(2).Infof("Restore: loading backup file %q from %q", f.name, sf.path)
+	f.size = sf.size
+	if err := fn(fd, f); err != nil {
+		return x.Wrapf(err, "while loading %q", f.name)
+	}
+	return nil
+}
+
+// fetch downloads the files in order, each one once a slot is free, and sends them on the
+// returned channel. A file that can't be downloaded is sent with its error, and the rest
+// aren't downloaded. It stops once done is closed, and then the channel must be drained.
+func (pf *prefetcher) fetch(h handler, uri *url.URL, files []*loadFile,
+	done <-chan struct{}) <-chan stagedFile {
+	staged := make(chan stagedFile, len(files))
+	go func() {
+		defer close(staged)
+		for _, f := range files {
+			select {
+			case pf.slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			sf := pf.download(h, uri, f)
+			staged <- sf
+			if sf.err != nil {
+				return
+			}
+		}
+	}()
+	return staged
+}
+
+// download downloads the file f into the staging directory, and verifies its checksum.
+func (pf *prefetcher) download(h handler, uri *url.URL, f *loadFile) stagedFile {
+	glog.V(2).Infof("Restore: downloading backup file %q", f.name)
+	r, _, err := readFile(h, uri, f.name)
+	if err != nil {
+		return stagedFile{err: x.Wrapf(err, "while reading %q", f.name)}
+	}
+	defer r.Close()
+	fd, err := ioutil.TempFile(pf.dir, "backup")
+	if err != nil {
+		return stagedFile{err: err}
+	}
+	sf := stagedFile{path: fd.Name()}
+	sum := sha256.New()
+	sf.size, sf.err = io.Copy(io.MultiWriter(fd, sum), r)
+	if err := fd.Close(); sf.err == nil {
+		sf.err = err
+	}
+	if sf.err != nil {
+		sf.err = x.Wrapf(sf.err, "while downloading %q", f.name)
+	} else if f.checksum != "" {
+		sf.err = checkChecksum(f, hex.EncodeToString(sum.Sum(nil)))
+	}
+	return sf
+}
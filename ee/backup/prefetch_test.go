@@ -0,0 +1,116 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	hdfs := &fakeHDFS{user: "dgraph", files: make(map[string][]byte)}
+	srv := httptest.NewServer(hdfs)
+	defer srv.Close()
+	require.NoError(t, os.Setenv("HADOOP_USER_NAME", "dgraph"))
+	defer os.Unsetenv("HADOOP_USER_NAME")
+	location := fmt.Sprintf("hdfs://%s/dgraph", strings.TrimPrefix(srv.URL, "http://"))
+	// A chain of 6 backups of 2 groups.
+	for i := 0; i < 6; i++ {
+		writeBackup(t, location, fmt.Sprintf("20181106.0%d1302", i), uint64(i*10),
+			uint64(i*10+10), testKVs(fmt.Sprintf("name%d", i), 5), testKVs("age", 3))
+	}
+
+	tmp := filepath.Join(dir, "tmp")
+	require.NoError(t, os.Mkdir(tmp, 0700))
+	staged := func() int {
+		matches, err := filepath.Glob(filepath.Join(tmp, "*", "*"))
+		require.NoError(t, err)
+		return len(matches)
+	}
+
+	// The loads are slow, the downloads go ahead of them, but no more than 2 files at once.
+	var mu sync.Mutex
+	var max int
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			n := staged()
+			mu.Lock()
+			if n > max {
+				max = n
+			}
+			mu.Unlock()
+		}
+	}()
+	var loaded []string
+	o := &restoreOptions{location: location, workers: 2, maxInflightFiles: 2, tmpDir: tmp}
+	err = o.load(nil, func(r io.Reader, f *loadFile) error {
+		time.Sleep(20 * time.Millisecond)
+		b, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(b)), f.size)
+		mu.Lock()
+		loaded = append(loaded, f.name)
+		mu.Unlock()
+		return nil
+	})
+	close(done)
+	require.NoError(t, err)
+	require.Len(t, loaded, 12)
+	require.Equal(t, 2, max)
+	// The staging directory is removed.
+	dirs, err := ioutil.ReadDir(tmp)
+	require.NoError(t, err)
+	require.Empty(t, dirs)
+
+	// The data restored from the staged files is the same.
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	pdir := filepath.Join(dir, "postings")
+	o = &restoreOptions{location: location, pdir: pdir, maxInflightFiles: 1, tmpDir: tmp}
+	require.NoError(t, runRestore(o, p))
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p1")), 30)
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p2")), 3)
+
+	// A corrupted file fails its checksum as it's downloaded.
+	name := "/dgraph/dgraph.20181106.021302/r30-g2.backup"
+	require.Contains(t, hdfs.files, name)
+	hdfs.files[name] = hdfs.files[name][:len(hdfs.files[name])-1]
+	o = &restoreOptions{location: location, maxInflightFiles: 2, tmpDir: tmp}
+	err = o.load(nil, func(r io.Reader, f *loadFile) error { return nil })
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Checksum mismatch")
+	dirs, err = ioutil.ReadDir(tmp)
+	require.NoError(t, err)
+	require.Empty(t, dirs)
+}
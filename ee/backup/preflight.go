@@ -0,0 +1,142 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/golang/glog"
+)
+
+// The data of a backup takes more space once restored, as posting lists in the LSM tree and
+// the value log of Badger. Compressed backups expand more. These factors are applied to the
+// size of the backup files to estimate the space a restore needs.
+const (
+	restoreExpansion     = 2
+	gzipRestoreExpansion = 6
+)
+
+// preflight checks that the restore into o.pdir can complete before anything is written to
+// it: the directory must be empty unless the restore is resumed or forced, it must be
+// writable, and its disk must have room for the estimated size of the restored data.
+// With o.roots, each postings root is checked, and must have room for its share of the groups.
+// With --force, a restore that doesn't seem to fit is attempted anyway.
+func (o *restoreOptions) preflight(p *progress) error {
+	roots := o.postingsRoots()
+	for _, root := range roots {
+		entries, err := ioutil.ReadDir(root)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if len(entries) > 0 && !o.resume && !o.force {
+			return x.Errorf("The --postings directory %q is not empty. Use --resume to "+
+				"continue an interrupted restore, or --force to restore into it anyway.", root)
+		}
+
+		if err := os.MkdirAll(root, 0700); err != nil {
+			return x.Wrapf(err, "while creating the --postings directory")
+		}
+		f, err := ioutil.TempFile(root, ".preflight")
+		if err != nil {
+			return x.Wrapf(err, "the --postings directory %q is not writable", root)
+		}
+		x.Ignore(f.Close())
+		if err := os.Remove(f.Name()); err != nil {
+			return err
+		}
+	}
+
+	need, err := o.estimateSize()
+	if err != nil {
+		return err
+	}
+	if need < 0 {
+		glog.Warningf("Restore: the size of the backups is unknown, not checking disk space")
+		return nil
+	}
+	// The groups are spread evenly over the roots, see groupDir.
+	need = (need + int64(len(roots)) - 1) / int64(len(roots))
+	for _, root := range roots {
+		free, err := diskFree(root)
+		if err != nil {
+			glog.Warningf("Restore: unable to check the free space in %q: %v", root, err)
+			continue
+		}
+		if len(roots) > 1 {
+			p.printf("Estimated space needed in %s: %s, free: %s\n", root,
+				humanize.IBytes(uint64(need)), humanize.IBytes(free))
+		} else {
+			p.printf("Estimated space needed: %s, free: %s\n", humanize.IBytes(uint64(need)),
+				humanize.IBytes(free))
+		}
+		if uint64(need) > free && !o.force {
+			return x.Errorf("The restore needs about %s in %q, but only %s are free. "+
+				"Use --force to restore anyway.", humanize.IBytes(uint64(need)), root,
+				humanize.IBytes(free))
+		}
+	}
+	return nil
+}
+
+// estimateSize returns the disk space the restore is estimated to need, from the size of
+// the backup files restored and the expansion factor of their compression, or -1 if unknown.
+func (o *restoreOptions) estimateSize() (int64, error) {
+	if o.location == stdinLocation {
+		return -1, nil
+	}
+	chain, err := Chain(o.location, o.restoreTs, &o.creds)
+	if err != nil {
+		return 0, err
+	}
+	h, uri, err := newHandler(o.location, &o.creds)
+	if err != nil {
+		return 0, err
+	}
+	gids := make(map[uint32]bool)
+	for _, gid := range o.groups {
+		gids[uint32(gid)] = true
+	}
+	preds := newPredicateSet(o.predicates, o.prefixes)
+
+	var total int64
+	for _, m := range chain {
+		factor := int64(restoreExpansion)
+		if m.Compression != "" {
+			factor = gzipRestoreExpansion
+		}
+		for _, gid := range m.Groups {
+			if len(gids) > 0 && !gids[gid] {
+				continue
+			}
+			if preds != nil && m.Predicates[gid] != nil && !preds.hasAny(m.Predicates[gid]) {
+				continue
+			}
+			name := path.Join(path.Dir(m.path), backupName(m.ReadTs, gid))
+			r, size, err := readFile(h, uri, name)
+			if err != nil {
+				return 0, x.Wrapf(err, "while reading %q", name)
+			}
+			r.Close()
+			if size < 0 {
+				return -1, nil
+			}
+			total += size * factor
+		}
+	}
+	return total, nil
+}
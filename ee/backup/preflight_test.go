@@ -0,0 +1,70 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreflight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+	writeBackupOpts(t, bdir, "20181106.021302", 10, 20, backupOpts{compression: "gzip"},
+		testKVs("name", 2), testKVs("age", 1))
+	size := func(name string) int64 {
+		fi, err := os.Stat(filepath.Join(bdir, name))
+		require.NoError(t, err)
+		return fi.Size()
+	}
+
+	// The size of the files is expanded by the factor of their compression.
+	o := &restoreOptions{location: bdir, pdir: filepath.Join(dir, "postings")}
+	need, err := o.estimateSize()
+	require.NoError(t, err)
+	require.Equal(t, restoreExpansion*(size("dgraph.20181106.011302/r10-g1.backup")+
+		size("dgraph.20181106.011302/r10-g2.backup"))+
+		gzipRestoreExpansion*(size("dgraph.20181106.021302/r20-g1.backup")+
+			size("dgraph.20181106.021302/r20-g2.backup")), need)
+	o.groups = []uint{2}
+	need, err = o.estimateSize()
+	require.NoError(t, err)
+	require.Equal(t, restoreExpansion*size("dgraph.20181106.011302/r10-g2.backup")+
+		gzipRestoreExpansion*size("dgraph.20181106.021302/r20-g2.backup"), need)
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	require.NoError(t, o.preflight(p))
+	fi, err := os.Stat(o.pdir)
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+
+	// Directories with data are only restored into when resuming or forced.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(o.pdir, "MANIFEST"), nil, 0600))
+	err = o.preflight(p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not empty")
+	o.resume = true
+	require.NoError(t, o.preflight(p))
+	o.resume, o.force = false, true
+	require.NoError(t, o.preflight(p))
+}
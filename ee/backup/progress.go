@@ -0,0 +1,292 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// progressInterval is how often the progress of the files being restored is reported.
+var progressInterval = 5 * time.Second
+
+// fileProgress tracks the restore of a single backup file.
+type fileProgress struct {
+	*loadFile
+	start time.Time
+	keys  int64 // updated atomically
+	bytes int64 // updated atomically
+}
+
+// progressReader counts the bytes read from a backup file into its progress.
+type progressReader struct {
+	r  io.Reader
+	fp *fileProgress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	atomic.AddInt64(&pr.fp.bytes, int64(n))
+	x.RestoreBytes.Add(int64(n))
+	return n, err
+}
+
+// progressRecord is a progress report in JSON format. One is written per file being
+// restored at each interval, plus a final one when the file is done.
+type progressRecord struct {
+	Time        time.Time `json:"time"`
+	File        string    `json:"file"`
+	Group       uint32    `json:"group"`
+	Keys        int64     `json:"keys"`
+	Bytes       int64     `json:"bytes"`
+	TotalBytes  int64     `json:"total_bytes,omitempty"`
+	BytesPerSec float64   `json:"bytes_per_sec"`
+	EtaSecs     float64   `json:"eta_secs,omitempty"`
+	Done        bool      `json:"done"`
+}
+
+// progress reports the restore progress periodically, either as text or as a stream of
+// JSON records, one per line.
+type progress struct {
+	sync.Mutex
+	json  bool
+	out   io.Writer // progress reports
+	tick  bool      // report the files being restored every progressInterval
+	msgs  io.Writer // human-readable messages
+	files []*fileProgress
+	start time.Time
+
+	// shutdown is used to stop the report goroutine and to wait until it has stopped.
+	// It must be unbuffered.
+	shutdown chan struct{}
+}
+
+// newProgress returns a progress that writes its reports in format ("text" or "json") to out.
+// When JSON is written to stdout, the human-readable messages are sent to stderr instead.
+// The text reports of the files being restored are only written every progressInterval if out
+// is a terminal, so they don't fill up logs. The report of each file once done always is.
+func newProgress(format string, out io.Writer) (*progress, error) {
+	p := &progress{
+		out:      out,
+		tick:     isTerminal(out),
+		msgs:     os.Stdout,
+		start:    time.Now(),
+		shutdown: make(chan struct{}),
+	}
+	switch format {
+	case "text":
+	case "json":
+		p.json = true
+		p.tick = true
+		if out == os.Stdout {
+			p.msgs = os.Stderr
+		}
+	default:
+		return nil, x.Errorf("Invalid progress format %q. Valid values are text and json.",
+			format)
+	}
+	return p, nil
+}
+
+// isTerminal returns whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// printf writes a human-readable message. It's called by the workers of all the groups.
+func (p *progress) printf(format string, args ...interface{}) {
+	p.Lock()
+	defer p.Unlock()
+	fmt.Fprintf(p.msgs, format, args...)
+}
+
+// add starts tracking f and returns its progress.
+func (p *progress) add(f *loadFile) *fileProgress {
+	fp := &fileProgress{loadFile: f, start: time.Now()}
+	p.Lock()
+	p.files = append(p.files, fp)
+	p.Unlock()
+	return fp
+}
+
+// done stops tracking fp and reports its final state.
+func (p *progress) done(fp *fileProgress) {
+	p.Lock()
+	defer p.Unlock()
+	for i, f := range p.files {
+		if f == fp {
+			p.files = append(p.files[:i], p.files[i+1:]...)
+			break
+		}
+	}
+	p.reportFile(fp, true)
+}
+
+func (p *progress) report() {
+	for {
+		select {
+		case <-time.After(progressInterval):
+			if p.tick {
+				p.reportOnce()
+			}
+		case <-p.shutdown:
+			p.shutdown <- struct{}{}
+			return
+		}
+	}
+}
+
+// stop ends the report goroutine.
+func (p *progress) stop() {
+	p.shutdown <- struct{}{}
+	<-p.shutdown
+}
+
+func (p *progress) reportOnce() {
+	p.Lock()
+	defer p.Unlock()
+	for _, fp := range p.files {
+		p.reportFile(fp, false)
+	}
+}
+
+// reportFile writes the progress of fp. Must be called with the lock held.
+func (p *progress) reportFile(fp *fileProgress, done bool) {
+	rec := progressRecord{
+		Time:  time.Now().UTC(),
+		File:  fp.name,
+		Group: fp.group,
+		Keys:  atomic.LoadInt64(&fp.keys),
+		Bytes: atomic.LoadInt64(&fp.bytes),
+		Done:  done,
+	}
+	elapsed := time.Since(fp.start)
+	if secs := elapsed.Seconds(); secs > 0 {
+		rec.BytesPerSec = float64(rec.Bytes) / secs
+	}
+	if fp.size > 0 {
+		rec.TotalBytes = fp.size
+		if !done && rec.BytesPerSec > 0 && fp.size > rec.Bytes {
+			rec.EtaSecs = float64(fp.size-rec.Bytes) / rec.BytesPerSec
+		}
+	}
+
+	if p.json {
+		b, err := json.Marshal(rec)
+		x.Check(err)
+		fmt.Fprintf(p.out, "%s\n", b)
+		return
+	}
+	if done {
+		fmt.Fprintf(p.out, "Loaded %s keys from %q in %s\n", humanize.Comma(rec.Keys),
+			rec.File, elapsed.Round(time.Second))
+		return
+	}
+	var pct, eta string
+	if rec.TotalBytes > 0 {
+		pct = fmt.Sprintf("[%.2f%%] ", 100*float64(rec.Bytes)/float64(rec.TotalBytes))
+		eta = fmt.Sprintf(" eta:%s", time.Duration(rec.EtaSecs)*time.Second)
+	}
+	fmt.Fprintf(p.out, "RESTORE %s %sfile:%s keys:%s bytes:%s speed:%s/sec%s\n",
+		x.FixedDuration(time.Since(p.start)), pct, rec.File, humanize.Comma(rec.Keys),
+		humanize.Bytes(uint64(rec.Bytes)), humanize.Bytes(uint64(rec.BytesPerSec)), eta)
+}
+
+// groupSummary is the total of the files of a group restored, see summary.
+type groupSummary struct {
+	group uint32
+	files int
+	keys  int64
+	bytes int64
+}
+
+// summary totals the files restored by group. The files are sent to it on a channel as they
+// are done by the workers of each group, so the workers share no counters, and its goroutine
+// is the only one updating the totals. The totals are written in the order of the group IDs,
+// whatever the order the groups complete in.
+type summary struct {
+	files  chan *fileProgress
+	once   sync.Once
+	done   chan struct{}
+	groups map[uint32]*groupSummary
+}
+
+// newSummary returns a summary with its goroutine running, until write is called.
+func newSummary() *summary {
+	s := &summary{
+		files:  make(chan *fileProgress, 16),
+		done:   make(chan struct{}),
+		groups: make(map[uint32]*groupSummary),
+	}
+	go func() {
+		defer close(s.done)
+		for fp := range s.files {
+			g, ok := s.groups[fp.group]
+			if !ok {
+				g = &groupSummary{group: fp.group}
+				s.groups[fp.group] = g
+			}
+			g.files++
+			g.keys += atomic.LoadInt64(&fp.keys)
+			g.bytes += atomic.LoadInt64(&fp.bytes)
+		}
+	}()
+	return s
+}
+
+// add adds the file fp, once it's done, to the total of its group.
+func (s *summary) add(fp *fileProgress) {
+	s.files <- fp
+}
+
+// close waits for the files added to be totaled, and returns the totals sorted by group ID.
+// No files can be added after it's called, but it can be called again.
+func (s *summary) close() []groupSummary {
+	s.once.Do(func() { close(s.files) })
+	<-s.done
+	totals := make([]groupSummary, 0, len(s.groups))
+	for _, g := range s.groups {
+		totals = append(totals, *g)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].group < totals[j].group })
+	return totals
+}
+
+// write writes the totals of s with p, one line per group, and the total of all the groups.
+func (s *summary) write(p *progress) {
+	var all groupSummary
+	for _, g := range s.close() {
+		p.printf("Group %d: %d files, %s keys, %s\n", g.group, g.files,
+			humanize.Comma(g.keys), humanize.Bytes(uint64(g.bytes)))
+		all.files += g.files
+		all.keys += g.keys
+		all.bytes += g.bytes
+	}
+	p.printf("Total: %d files, %s keys, %s\n", all.files, humanize.Comma(all.keys),
+		humanize.Bytes(uint64(all.bytes)))
+}
@@ -0,0 +1,65 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// newTransport returns a copy of tr for the requests of the remote handlers. Like tr, it goes
+// through the proxy given by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY env vars, unless the
+// proxy is set with --proxy, which is used for all the requests. The link-local addresses,
+// where the clouds serve their instance metadata and identity tokens, are always reached
+// directly. The TLS certificates are verified, through the proxy too, unless skipVerify or
+// --proxy_insecure is set, e.g. for a proxy that intercepts TLS with its own certificates.
+func newTransport(tr *http.Transport, creds *Credentials, skipVerify bool) (
+	*http.Transport, error) {
+
+	proxy := tr.Proxy
+	if creds != nil && creds.Proxy != "" {
+		u, err := url.Parse(creds.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, x.Errorf("The proxy %q is invalid.", creds.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	tr = tr.Clone()
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		if ip := net.ParseIP(req.URL.Hostname()); ip != nil && ip.IsLinkLocalUnicast() {
+			return nil, nil
+		}
+		if proxy == nil {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+	if skipVerify || (creds != nil && creds.ProxyInsecure) {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return tr, nil
+}
+
+// newHTTPClient returns the client of the remote handlers that use net/http directly, see
+// newTransport.
+func newHTTPClient(creds *Credentials) (*http.Client, error) {
+	tr, err := newTransport(http.DefaultTransport.(*http.Transport), creds, false)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: tr}, nil
+}
@@ -0,0 +1,166 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProxy is an HTTP proxy that records the hosts of the requests it forwards. HTTPS
+// requests are tunneled with CONNECT, so the TLS connection is still with the target.
+type fakeProxy struct {
+	sync.Mutex
+	hosts []string
+}
+
+func (s *fakeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	s.hosts = append(s.hosts, r.Method+" "+r.Host)
+	s.Unlock()
+
+	if r.Method == http.MethodConnect {
+		conn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		client, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		go func() {
+			io.Copy(conn, rw)
+			conn.Close()
+		}()
+		io.Copy(client, conn)
+		client.Close()
+		return
+	}
+
+	r.RequestURI = ""
+	resp, err := (&http.Transport{}).RoundTrip(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func TestRestoreProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	hdfs := &fakeHDFS{user: "dgraph", files: make(map[string][]byte)}
+	srv := httptest.NewServer(hdfs)
+	defer srv.Close()
+	proxy := &fakeProxy{}
+	psrv := httptest.NewServer(proxy)
+	defer psrv.Close()
+
+	require.NoError(t, os.Setenv("HADOOP_USER_NAME", "dgraph"))
+	defer os.Unsetenv("HADOOP_USER_NAME")
+	host := strings.TrimPrefix(srv.URL, "http://")
+	location := fmt.Sprintf("hdfs://%s/data/dgraph", host)
+	writeBackup(t, location, "20181106.011302", 0, 10, testKVs("name", 5))
+	require.Empty(t, proxy.hosts)
+
+	// All the requests of the restore, the redirected reads too, go through the proxy.
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	pdir := filepath.Join(dir, "postings")
+	o := &restoreOptions{location: location, pdir: pdir, creds: Credentials{Proxy: psrv.URL}}
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 5)
+	for _, kv := range testKVs("name", 5).Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+	require.NotEmpty(t, proxy.hosts)
+	for _, h := range proxy.hosts {
+		require.Equal(t, "GET "+host, h)
+	}
+
+	o.pdir, o.creds.Proxy = filepath.Join(dir, "invalid"), "::"
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "The proxy")
+}
+
+func TestProxyTLS(t *testing.T) {
+	fs := &fakeS3{}
+	srv := httptest.NewTLSServer(fs)
+	defer srv.Close()
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	proxy := &fakeProxy{}
+	psrv := httptest.NewServer(proxy)
+	defer psrv.Close()
+
+	setup := func(creds Credentials) error {
+		uri, err := url.Parse("s3://" + endpoint.Host + "/bucket/dgraph?path_style=true")
+		require.NoError(t, err)
+		creds.AccessKey, creds.SecretKey = "access", "secret"
+		h := &s3Handler{creds: &creds}
+		_, err = h.setup(uri)
+		return err
+	}
+
+	// The self-signed certificate of the endpoint is still verified through the proxy.
+	err = setup(Credentials{Proxy: psrv.URL})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "certificate")
+	require.Empty(t, fs.checks)
+
+	require.NoError(t, setup(Credentials{Proxy: psrv.URL, ProxyInsecure: true}))
+	require.Equal(t, []string{endpoint.Host + "/bucket/"}, fs.checks)
+	require.NotEmpty(t, proxy.hosts)
+	for _, h := range proxy.hosts {
+		require.Equal(t, "CONNECT "+endpoint.Host, h)
+	}
+
+	// The instance metadata endpoints are reached directly.
+	tr, err := newTransport(http.DefaultTransport.(*http.Transport),
+		&Credentials{Proxy: psrv.URL}, false)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, azIdentityEndpoint, nil)
+	require.NoError(t, err)
+	u, err := tr.Proxy(req)
+	require.NoError(t, err)
+	require.Nil(t, u)
+	req, err = http.NewRequest(http.MethodGet, "https://storage.googleapis.com/", nil)
+	require.NoError(t, err)
+	u, err = tr.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, psrv.URL, u.String())
+}
@@ -0,0 +1,128 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+// pruneOptions are the settings of a prune.
+type pruneOptions struct {
+	location   string
+	keepDaily  int // days to keep the latest backup of
+	keepWeekly int // weeks to keep the latest backup of
+	dryRun     bool
+	creds      Credentials
+}
+
+// retain returns which of the manifests, sorted by their read timestamp, must be kept. For
+// each one of the last keepDaily days with backups, the latest backup of the day is kept, and
+// the same for the last keepWeekly weeks. The backups the kept ones are incremental from are
+// kept too, back to their full backup, so the kept backups can still be restored.
+func retain(manifests []*Manifest, keepDaily, keepWeekly int) ([]bool, error) {
+	keep := make([]bool, len(manifests))
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i := len(manifests) - 1; i >= 0; i-- {
+		t, err := manifests[i].Time()
+		if err != nil {
+			return nil, err
+		}
+		if day := t.Format("2006-01-02"); !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep[i] = true
+		}
+		year, week := t.ISOWeek()
+		if w := fmt.Sprintf("%d-%d", year, week); !weeks[w] && len(weeks) < keepWeekly {
+			weeks[w] = true
+			keep[i] = true
+		}
+	}
+
+	byReadTs := make(map[uint64]int)
+	for i, m := range manifests {
+		byReadTs[m.ReadTs] = i
+	}
+	for i := len(manifests) - 1; i >= 0; i-- {
+		if !keep[i] || manifests[i].Since == 0 {
+			continue
+		}
+		// The previous backup comes earlier in the list, its own previous one is kept when
+		// the loop gets there.
+		prev, ok := byReadTs[manifests[i].Since]
+		if !ok {
+			glog.Warningf("Prune: backup %q was taken since ts %d, but there's no backup "+
+				"taken then", manifests[i].path, manifests[i].Since)
+			continue
+		}
+		keep[prev] = true
+	}
+	return keep, nil
+}
+
+// runPrune deletes the backups at o.location that aren't retained, see retain. The manifest of
+// a backup is deleted before its files, so a backup deleted partially is never restored.
+// With o.dryRun, the backups that would be deleted are only listed.
+func runPrune(o *pruneOptions, out io.Writer) error {
+	if o.keepDaily <= 0 && o.keepWeekly <= 0 {
+		return x.Errorf("At least one of --keep_daily and --keep_weekly must be set.")
+	}
+	h, uri, err := newHandler(o.location, &o.creds)
+	if err != nil {
+		return err
+	}
+	manifests, err := readManifests(h, uri)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		fmt.Fprintf(out, "No backups found in %q\n", uri.String())
+		return nil
+	}
+	keep, err := retain(manifests, o.keepDaily, o.keepWeekly)
+	if err != nil {
+		return err
+	}
+
+	var deleted int
+	for i, m := range manifests {
+		dir := path.Dir(m.path)
+		if keep[i] {
+			fmt.Fprintf(out, "Keeping backup %q\n", dir)
+			continue
+		}
+		deleted++
+		if o.dryRun {
+			fmt.Fprintf(out, "Would delete backup %q\n", dir)
+			continue
+		}
+		fmt.Fprintf(out, "Deleting backup %q\n", dir)
+		if err := h.Delete(uri, m.path); err != nil {
+			return x.Wrapf(err, "while deleting %q", m.path)
+		}
+		for _, gid := range m.Groups {
+			f := path.Join(dir, backupName(m.ReadTs, gid))
+			if err := deleteFile(h, uri, f); err != nil {
+				return x.Wrapf(err, "while deleting %q", f)
+			}
+		}
+	}
+	fmt.Fprintf(out, "Pruned %d of %d backups\n", deleted, len(manifests))
+	return nil
+}
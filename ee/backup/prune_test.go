@@ -0,0 +1,87 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetain(t *testing.T) {
+	m := func(dir string, since, readTs uint64) *Manifest {
+		return &Manifest{Since: since, ReadTs: readTs, path: dir + "/manifest.json"}
+	}
+	manifests := []*Manifest{
+		m("dgraph.20181029.010000", 0, 10), // week 44
+		m("dgraph.20181030.010000", 10, 20),
+		m("dgraph.20181104.010000", 0, 30), // week 44, its last day
+		m("dgraph.20181105.010000", 0, 40), // week 45
+		m("dgraph.20181105.020000", 40, 50),
+		m("dgraph.20181106.010000", 50, 60),
+		m("dgraph.20181106.020000", 60, 70),
+	}
+	tests := []struct {
+		daily, weekly int
+		keep          []bool
+	}{
+		// The latest backup of the last day needs all the ones back to its full backup.
+		{1, 0, []bool{false, false, false, true, true, true, true}},
+		{2, 0, []bool{false, false, false, true, true, true, true}},
+		{3, 0, []bool{false, false, true, true, true, true, true}},
+		{0, 2, []bool{false, false, true, true, true, true, true}},
+		{0, 3, []bool{false, false, true, true, true, true, true}},
+	}
+	for _, tc := range tests {
+		keep, err := retain(manifests, tc.daily, tc.weekly)
+		require.NoError(t, err)
+		require.Equal(t, tc.keep, keep, "daily=%d weekly=%d", tc.daily, tc.weekly)
+	}
+
+	// An incremental backup kept keeps its chain, even if it's the only one kept.
+	keep, err := retain(manifests[:2], 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true}, keep)
+
+	_, err = retain([]*Manifest{m("backup", 0, 10)}, 1, 0)
+	require.Error(t, err)
+}
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeBackup(t, dir, "20181105.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+	writeBackup(t, dir, "20181106.011302", 0, 20, testKVs("name", 5), testKVs("age", 3))
+	writeBackup(t, dir, "20181106.021302", 20, 30, testKVs("name", 5))
+
+	o := &pruneOptions{location: dir, keepDaily: 1, dryRun: true}
+	require.NoError(t, runPrune(o, ioutil.Discard))
+	_, err = os.Stat(filepath.Join(dir, "dgraph.20181105.011302"))
+	require.NoError(t, err)
+
+	o.dryRun = false
+	require.NoError(t, runPrune(o, ioutil.Discard))
+	_, err = os.Stat(filepath.Join(dir, "dgraph.20181105.011302"))
+	require.True(t, os.IsNotExist(err))
+	chain, err := Chain(dir, 0, &Credentials{})
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+
+	o.keepDaily = 0
+	require.Error(t, runPrune(o, ioutil.Discard))
+}
@@ -0,0 +1,155 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// rebalancer restores the predicates of a backup into a different number of groups. The
+// files of any group of the backup can have data of any of the target groups, so the pN
+// directories of all of them stay open for the whole restore.
+type rebalancer struct {
+	groups map[string]uint32 // target group of each predicate
+	dbs    []*badger.DB      // pN directory of group N, at index N-1
+}
+
+// assignPredicates assigns the predicates of the backups in chain to n groups. A predicate
+// stays in its group in the backup if that group is one of the n groups and has less than
+// its share of the predicates. The rest go to the group with the fewest predicates.
+// If preds is set, only those predicates are assigned.
+func assignPredicates(chain []*Manifest, n uint32, preds *predicateSet) (
+	map[string]uint32, error) {
+	// A predicate can move between groups, its latest group is the one that counts.
+	src := make(map[string]uint32)
+	for _, m := range chain {
+		if m.Predicates == nil {
+			return nil, x.Errorf("The manifest of backup %q doesn't list its predicates, "+
+				"it can't be rebalanced", m.path)
+		}
+		for gid, attrs := range m.Predicates {
+			for _, attr := range attrs {
+				if !preds.has(attr) {
+					continue
+				}
+				src[attr] = gid
+			}
+		}
+	}
+	attrs := make([]string, 0, len(src))
+	for attr := range src {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	share := (len(attrs) + int(n) - 1) / int(n)
+	counts := make([]int, n+1)
+	groups := make(map[string]uint32)
+	var moved []string
+	for _, attr := range attrs {
+		if gid := src[attr]; gid >= 1 && gid <= n && counts[gid] < share {
+			groups[attr] = gid
+			counts[gid]++
+			continue
+		}
+		moved = append(moved, attr)
+	}
+	for _, attr := range moved {
+		gid := uint32(1)
+		for i := uint32(2); i <= n; i++ {
+			if counts[i] < counts[gid] {
+				gid = i
+			}
+		}
+		groups[attr] = gid
+		counts[gid]++
+	}
+	return groups, nil
+}
+
+// groupPredicates returns the predicates of each one of the n groups in groups, sorted.
+// The predicates of group N are at index N-1.
+func groupPredicates(groups map[string]uint32, n uint32) [][]string {
+	attrs := make([][]string, n)
+	for attr, gid := range groups {
+		attrs[gid-1] = append(attrs[gid-1], attr)
+	}
+	for _, a := range attrs {
+		sort.Strings(a)
+	}
+	return attrs
+}
+
+// newRebalancer opens the pN directories of the o.rebalance groups, see groupDir.
+func newRebalancer(o *restoreOptions, groups map[string]uint32) (*rebalancer, error) {
+	rb := &rebalancer{groups: groups}
+	for gid := uint32(1); gid <= o.rebalance; gid++ {
+		db, err := o.openPostings(o.groupDir(gid))
+		if err != nil {
+			rb.close()
+			return nil, err
+		}
+		rb.dbs = append(rb.dbs, db)
+	}
+	return rb, nil
+}
+
+// writers returns a route for loadKVs that commits each KV into the pN directory of the group
+// of its predicate and records it in fs, and the function that flushes the KVs routed.
+func (rb *rebalancer) writers(fs *fileState) (routeFn, func() error) {
+	writers := make([]*x.TxnWriter, len(rb.dbs))
+	route := func(pk *x.ParsedKey, kv *pb.KV) (kvWriter, error) {
+		gid, ok := rb.groups[pk.Attr]
+		if !ok {
+			return nil, x.Errorf("Predicate %q isn't listed in the backup manifests", pk.Attr)
+		}
+		if err := fs.add(pk, kv, gid); err != nil {
+			return nil, err
+		}
+		w := writers[gid-1]
+		if w == nil {
+			w = x.NewTxnWriter(rb.dbs[gid-1])
+			w.BlindWrite = true
+			writers[gid-1] = w
+		}
+		return w, nil
+	}
+	flush := func() error {
+		for _, w := range writers {
+			if w == nil {
+				continue
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return route, flush
+}
+
+// close closes the pN directories. It can be called more than once.
+func (rb *rebalancer) close() error {
+	var err error
+	for _, db := range rb.dbs {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}
+	rb.dbs = nil
+	return err
+}
@@ -0,0 +1,149 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"context"
+	"io/ioutil"
+	"sort"
+
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// readSchemaFile parses the schema in o.schemaFile, or returns nil if it isn't set.
+func (o *restoreOptions) readSchemaFile() ([]*pb.SchemaUpdate, error) {
+	return parseSchemaFile(o.schemaFile, "--schema_file")
+}
+
+// parseSchemaFile parses the schema in the file given with flag, or returns nil if it isn't
+// set.
+func parseSchemaFile(path, flag string) ([]*pb.SchemaUpdate, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, x.Wrapf(err, "while reading the %s", flag)
+	}
+	updates, err := schema.Parse(string(b))
+	if err != nil {
+		return nil, x.Wrapf(err, "while parsing the %s", flag)
+	}
+	return updates, nil
+}
+
+// rebuildIndexes applies the schema to the groups restored into o.pdir, as recorded in zs, and
+// rebuilds the indexes, reverse edges and count indexes of their predicates to match it.
+// The schema is the one in updates for the predicates listed there, and the restored one for
+// the rest. The rebuilt keys are written one ts above the restored data, and zs is updated
+// so Zero doesn't lease that ts again. With o.reindexOnStart, the Alphas rebuild them when
+// they start instead, see rebuildGroupIndexes.
+// It uses the global state of the posting and schema packages, so the groups are rebuilt
+// one at a time.
+func (o *restoreOptions) rebuildIndexes(p *progress, zs *zeroState,
+	updates []*pb.SchemaUpdate) error {
+	byAttr := make(map[string]*pb.SchemaUpdate)
+	for _, su := range updates {
+		byAttr[su.Predicate] = su
+	}
+	groups := make(map[uint32][]string)
+	for attr, tablet := range zs.tablets {
+		groups[tablet.GroupId] = append(groups[tablet.GroupId], attr)
+		delete(byAttr, attr)
+	}
+	for attr := range byAttr {
+		p.printf("No data restored for predicate %q, its schema isn't applied\n", attr)
+	}
+	gids := make([]uint32, 0, len(groups))
+	for gid := range groups {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+
+	zs.maxTxnTs++
+	for _, gid := range gids {
+		attrs := groups[gid]
+		sort.Strings(attrs)
+		dir := o.groupDir(gid)
+		p.printf("Rebuilding the indexes of %d predicates in %q\n", len(attrs), dir)
+		err := o.rebuildGroupIndexes(dir, attrs, updates, zs.maxTxnTs)
+		if err != nil {
+			return x.Wrapf(err, "while rebuilding the indexes of group %d", gid)
+		}
+	}
+	return nil
+}
+
+// rebuildGroupIndexes applies the schema to the predicates attrs restored into dir, and rebuilds
+// their indexes at ts. With o.reindexOnStart, the predicates are marked with x.ReindexKey at
+// ts instead, for the Alpha to rebuild them.
+func (o *restoreOptions) rebuildGroupIndexes(dir string, attrs []string,
+	updates []*pb.SchemaUpdate, ts uint64) error {
+	db, err := o.openPostings(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	posting.Init(db)
+	defer posting.Cleanup()
+	schema.Init(db)
+	if err := schema.LoadFromDb(); err != nil {
+		return err
+	}
+
+	inGroup := make(map[string]bool)
+	for _, attr := range attrs {
+		inGroup[attr] = true
+	}
+	// The schema keys are always read at version 1, see loadKVs. They replace the restored ones.
+	w := x.NewTxnWriter(db)
+	w.BlindWrite = true
+	for _, su := range updates {
+		if !inGroup[su.Predicate] {
+			continue
+		}
+		val, err := su.Marshal()
+		if err != nil {
+			return err
+		}
+		key := x.SchemaKey(su.Predicate)
+		if err := w.SetAt(key, val, posting.BitSchemaPosting, 1); err != nil {
+			return err
+		}
+		schema.State().Set(su.Predicate, *su)
+	}
+	if o.reindexOnStart {
+		// The Alpha rebuilds the indexes of the marked predicates at ts when it starts.
+		for _, attr := range attrs {
+			if err := w.SetAt(x.ReindexKey(attr), nil, 0, ts); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil || o.reindexOnStart {
+		return err
+	}
+
+	for _, attr := range attrs {
+		if attr == "_predicate_" {
+			continue
+		}
+		if err := posting.RebuildAllIndexes(context.Background(), attr, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
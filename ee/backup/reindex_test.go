@@ -0,0 +1,233 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/stretchr/testify/require"
+)
+
+// countKeys returns the number of keys in kvs of attr for which is returns true, and checks
+// they're all committed at version.
+func countKeys(t *testing.T, kvs map[string]*pb.KV, attr string, version uint64,
+	is func(pk *x.ParsedKey) bool) int {
+	var n int
+	for key, kv := range kvs {
+		pk := x.Parse([]byte(key))
+		if pk.Attr != attr || !is(pk) {
+			continue
+		}
+		require.Equal(t, version, kv.Version, "key %q", key)
+		n++
+	}
+	return n
+}
+
+func TestRestoreSchemaFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	schemaKV := func(su *pb.SchemaUpdate) *pb.KV {
+		val, err := su.Marshal()
+		require.NoError(t, err)
+		return &pb.KV{Key: x.SchemaKey(su.Predicate), Val: val,
+			UserMeta: []byte{posting.BitSchemaPosting}, Version: 1}
+	}
+	value := func(uid uint64, name string, version uint64) *pb.KV {
+		return postingKV(t, "name", uid, version, &pb.Posting{Uid: math.MaxUint64,
+			ValType: pb.Posting_STRING, Value: []byte(name), PostingType: pb.Posting_VALUE})
+	}
+	kvs := &pb.KVS{Kv: []*pb.KV{
+		postingKV(t, "friend", 1, 4, &pb.Posting{Uid: 2, PostingType: pb.Posting_REF}),
+		value(1, "alice", 2),
+		value(2, "bob", 3),
+		schemaKV(&pb.SchemaUpdate{Predicate: "friend", ValueType: pb.Posting_UID}),
+		schemaKV(&pb.SchemaUpdate{Predicate: "name", ValueType: pb.Posting_STRING,
+			Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"term"}}),
+	}}
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	// The schema restored indexes name, but the backup doesn't have its index keys.
+	pdir := filepath.Join(dir, "reindex")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, reindex: true}
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Equal(t, 2, countKeys(t, got, "name", 5, (*x.ParsedKey).IsIndex))
+
+	schemaFile := filepath.Join(dir, "schema.txt")
+	require.NoError(t, ioutil.WriteFile(schemaFile, []byte(`
+		name: string @index(exact) .
+		friend: uid @reverse @count .
+		age: int .
+	`), 0600))
+	pdir = filepath.Join(dir, "postings")
+	o = &restoreOptions{location: bdir, pdir: pdir, workers: 1, schemaFile: schemaFile,
+		zeroState: true}
+	require.NoError(t, runRestore(o, p))
+	got = readKVs(t, filepath.Join(pdir, "p1"))
+	require.Equal(t, 2, countKeys(t, got, "name", 5, (*x.ParsedKey).IsIndex))
+	require.Equal(t, 1, countKeys(t, got, "friend", 5, (*x.ParsedKey).IsReverse))
+	require.Equal(t, 2, countKeys(t, got, "friend", 5, (*x.ParsedKey).IsCount))
+	require.Contains(t, got, string(x.ReverseKey("friend", 2)))
+	require.Contains(t, got, string(x.IndexKey("name", "\x02alice")))
+
+	var su pb.SchemaUpdate
+	require.NoError(t, su.Unmarshal(got[string(x.SchemaKey("name"))].Val))
+	require.Equal(t, []string{"exact"}, su.Tokenizer)
+	require.NotContains(t, got, string(x.SchemaKey("age")))
+
+	// Zero must not lease the ts the indexes were rebuilt at.
+	f, err := os.Open(filepath.Join(pdir, zeroStateName))
+	require.NoError(t, err)
+	defer f.Close()
+	var state pb.MembershipState
+	require.NoError(t, jsonpb.Unmarshal(f, &state))
+	require.Equal(t, uint64(5), state.MaxTxnTs)
+
+	o.dryRun = true
+	require.NoError(t, runRestore(o, p))
+	o = &restoreOptions{location: bdir, workers: 1, schemaFile: filepath.Join(dir, "none")}
+	require.Error(t, runRestore(o, p))
+}
+
+func TestRestoreReindexOnStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kvs := testKVs("name", 3)
+	kvs.Kv = append(kvs.Kv,
+		&pb.KV{Key: x.IndexKey("name", "\x02alice"), Val: []byte{}, Version: 3},
+		&pb.KV{Key: x.ReverseKey("friend", 2), Val: []byte{}, Version: 3},
+		&pb.KV{Key: x.CountKey("friend", 1, false), Val: []byte{}, Version: 3})
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, reindexOnStart: true}
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 4)
+	for _, kv := range testKVs("name", 3).Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+	// The Alpha rebuilds the indexes above the restored data.
+	require.Equal(t, uint64(4), got[string(x.ReindexKey("name"))].Version)
+}
+
+func TestRestoreSkippedIndexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	su := &pb.SchemaUpdate{Predicate: "name", ValueType: pb.Posting_STRING,
+		Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"exact"}}
+	val, err := su.Marshal()
+	require.NoError(t, err)
+	kvs := &pb.KVS{Kv: []*pb.KV{
+		postingKV(t, "name", 1, 2, &pb.Posting{Uid: math.MaxUint64,
+			ValType: pb.Posting_STRING, Value: []byte("alice"), PostingType: pb.Posting_VALUE}),
+		{Key: x.SchemaKey("name"), Val: val, UserMeta: []byte{posting.BitSchemaPosting},
+			Version: 1},
+	}}
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackupOpts(t, bdir, "20181106.011302", 0, 10, backupOpts{skipIndexes: true}, kvs)
+
+	// The indexes left out of the backup are rebuilt without asking.
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1}
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Equal(t, 1, countKeys(t, got, "name", 3, (*x.ParsedKey).IsIndex))
+	require.Contains(t, got, string(x.IndexKey("name", "\x02alice")))
+
+	// An online restore marks the predicates for the Alpha to rebuild.
+	bo := badger.DefaultOptions
+	bo.Dir = filepath.Join(dir, "p")
+	bo.ValueDir = bo.Dir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	req := &pb.RestoreRequest{GroupId: 1, Location: bdir, CommitTs: 100}
+	require.NoError(t, RestoreGroup(db, req, nil, nil))
+	require.NoError(t, db.Close())
+	got = readKVs(t, bo.Dir)
+	require.Len(t, got, 3)
+	require.Equal(t, uint64(100), got[string(x.ReindexKey("name"))].Version)
+}
+
+func TestRestoreDropIndexData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	su := &pb.SchemaUpdate{Predicate: "name", ValueType: pb.Posting_STRING,
+		Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"term"}}
+	val, err := su.Marshal()
+	require.NoError(t, err)
+	index := postingKV(t, "name", 0, 2, &pb.Posting{Uid: 1, PostingType: pb.Posting_REF})
+	index.Key = x.IndexKey("name", "\x01alice")
+	reverse := postingKV(t, "friend", 0, 4, &pb.Posting{Uid: 1, PostingType: pb.Posting_REF})
+	reverse.Key = x.ReverseKey("friend", 2)
+	kvs := &pb.KVS{Kv: []*pb.KV{
+		postingKV(t, "friend", 1, 4, &pb.Posting{Uid: 2, PostingType: pb.Posting_REF}),
+		postingKV(t, "name", 1, 2, &pb.Posting{Uid: math.MaxUint64,
+			ValType: pb.Posting_STRING, Value: []byte("alice"), PostingType: pb.Posting_VALUE}),
+		index,
+		reverse,
+		{Key: x.SchemaKey("name"), Val: val, UserMeta: []byte{posting.BitSchemaPosting},
+			Version: 1},
+	}}
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, dropIndexData: true}
+	require.NoError(t, runRestore(o, p))
+
+	// The index key is dropped, and nothing is marked to rebuild it, but the schema keeps the
+	// index, and the reverse key is kept.
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 4)
+	require.NotContains(t, got, string(index.Key))
+	require.NotContains(t, got, string(x.ReindexKey("name")))
+	require.Equal(t, reverse, got[string(reverse.Key)])
+	var restored pb.SchemaUpdate
+	require.NoError(t, restored.Unmarshal(got[string(x.SchemaKey("name"))].Val))
+	require.Equal(t, []string{"term"}, restored.Tokenizer)
+}
@@ -0,0 +1,81 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// repairDirs returns the Badger directories to repair under the postings roots: each root
+// that is a Badger directory itself, and the pN directories restored under the others. The
+// groups of a restore into several roots are spread over them, see groupDir.
+func repairDirs(roots []string) ([]string, error) {
+	var dirs []string
+	for _, root := range roots {
+		if _, err := os.Stat(filepath.Join(root, badger.ManifestFilename)); err == nil {
+			dirs = append(dirs, root)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(root, "p*", badger.ManifestFilename))
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, m := range matches {
+			found = append(found, filepath.Dir(m))
+		}
+		sort.Strings(found)
+		dirs = append(dirs, found...)
+	}
+	if len(dirs) == 0 {
+		return nil, x.Errorf("No restored postings found in %q", strings.Join(roots, ","))
+	}
+	return dirs, nil
+}
+
+// runRepair salvages the Badger DBs restored under the postings roots, whose value log ends
+// in a partially written entry, e.g. after the machine crashed during a restore. Each DB is
+// opened with Badger's Truncate option, which drops the corrupt end of the value log, and
+// closed again. The writes lost that way were never committed, so the DB is left as of its
+// last commit.
+func runRepair(roots []string, out io.Writer) error {
+	dirs, err := repairDirs(roots)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		bo := badger.DefaultOptions
+		bo.Dir = dir
+		bo.ValueDir = dir
+		bo.Truncate = true
+		bo.NumVersionsToKeep = math.MaxInt32
+		db, err := badger.OpenManaged(bo)
+		if err != nil {
+			return x.Wrapf(err, "while repairing %q", dir)
+		}
+		if err := db.Close(); err != nil {
+			return x.Wrapf(err, "while repairing %q", dir)
+		}
+		fmt.Fprintf(out, "Repaired %q\n", dir)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+
+	err = runRepair([]string{dir}, ioutil.Discard)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No restored postings found")
+
+	// Leave a partially written entry at the end of the value log, like a crash would.
+	p1 := filepath.Join(pdir, "p1")
+	vlogs, err := filepath.Glob(filepath.Join(p1, "*.vlog"))
+	require.NoError(t, err)
+	require.NotEmpty(t, vlogs)
+	f, err := os.OpenFile(vlogs[len(vlogs)-1], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	bo := badger.DefaultOptions
+	bo.Dir = p1
+	bo.ValueDir = p1
+	_, err = badger.OpenManaged(bo)
+	require.Equal(t, badger.ErrTruncateNeeded, err)
+
+	require.NoError(t, runRepair([]string{pdir}, ioutil.Discard))
+	expected := testKVs("name", 5)
+	got := readKVs(t, p1)
+	require.Len(t, got, len(expected.Kv))
+	for _, kv := range expected.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func TestRepairRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3),
+		testKVs("email", 4))
+	roots := []string{filepath.Join(dir, "disk1"), filepath.Join(dir, "disk2")}
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	o := &restoreOptions{location: bdir, pdir: roots[0], roots: roots}
+	require.NoError(t, runRestore(o, p))
+
+	// The groups of every root are repaired.
+	var out bytes.Buffer
+	require.NoError(t, runRepair(roots, &out))
+	require.Equal(t, fmt.Sprintf("Repaired %q\nRepaired %q\nRepaired %q\n",
+		filepath.Join(roots[0], "p1"), filepath.Join(roots[0], "p3"),
+		filepath.Join(roots[1], "p2")), out.String())
+}
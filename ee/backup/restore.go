@@ -0,0 +1,1155 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/badger/options"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/golang/glog"
+)
+
+// restoreOptions are the settings of a restore.
+type restoreOptions struct {
+	location, pdir string
+	roots          []string // postings roots to spread the groups over, see groupDir
+	restoreTs      uint64
+	predicates     []string
+	prefixes       []string // restore the predicates starting with these too
+	groups         []uint
+	workers        int
+	dryRun         bool
+	resume         bool   // continue a restore that died, see checkpoints
+	force          bool   // restore even if the preflight checks fail, see preflight
+	alpha, zero    string // cluster to send the data to as mutations, instead of writing pdir
+	batch          int    // N-Quads per mutation sent to alpha
+	rebalance      uint32 // number of groups to spread the predicates over, zero to keep them
+	zeroState      bool   // write the Zero state of the restored data, always set by rebalance
+	creds          Credentials
+	keyFile        string
+	key            []byte // AES key to decrypt encrypted backups, read from keyFile
+	progressFormat string
+	progressFile   string
+	progress       bool // report the progress periodically even if stdout isn't a terminal
+
+	rateLimit float64      // read rate of the backup files in MB/s, zero for no limit
+	limit     *RateLimiter // limiter of rateLimit, shared by all the files
+
+	// Number of remote backup files downloaded into tmpDir ahead of their load, zero to read
+	// them as they're loaded, see prefetcher.
+	maxInflightFiles int
+	tmpDir           string
+
+	exportTo  string // format to export the restored data to, see runExport
+	outFormat string // format to list the data of the backups in, see runKeys
+	out       string // directory to export the restored data to, or file of outFormat
+
+	compression string // codec of the backup file read from stdin, see load
+	localRead   string // how the local backup files are read: buffered, the default, or mmap
+
+	// Settings of the badger DBs restored into, see openPostings. The zero values keep the
+	// defaults.
+	badgerTables     string // how the LSM tree is loaded: ram, mmap or disk
+	badgerVlogSize   int64  // size of the value log files in MB
+	badgerCompactors int    // number of compaction workers
+	badgerSyncWrites bool   // sync the writes to disk before returning them
+
+	limits batchLimits // bound the KVs pending to be written into pdir
+
+	httpAddr string // address to serve the metrics on, see recordRestore
+
+	schemaFile string // schema to apply once the data is loaded, see rebuildIndexes
+	reindex    bool   // rebuild the indexes of the restored schema, see rebuildIndexes
+
+	// Skip the index, reverse and count keys, and have the Alphas rebuild them as they start.
+	reindexOnStart bool
+
+	// Skip the index keys, but not the reverse and count keys, and leave the indexes to be
+	// rebuilt in the cluster. The schema keeps them. preserveIndexState is its inverse, the
+	// default, set to false to drop them too.
+	dropIndexData      bool
+	preserveIndexState bool
+
+	// Fail if the backup files have data of predicates without a schema, see schemaCheck.
+	abortOnSchemaMissing bool
+
+	// Write the full backups with badger's Load, see loadWriter.
+	loadWriter bool
+
+	// File to write the statistics of the restored predicates to, see restoreStats.
+	statsCSV string
+
+	// Remove the lock files left in pdir by processes that aren't running, see
+	// clearStaleLocks.
+	clearStaleLock bool
+
+	// Schema to compare the schema of the backup with, see backupSchema. The differences
+	// fail the restore with strict, and are only reported otherwise.
+	validateSchemaFile string
+	strict             bool
+
+	// Warn if the highest version of a restored group is more than this below the one of the
+	// others, see checkVersions.
+	versionSkew uint64
+
+	// Directory to save the checksums of the files whose manifest has none to, and to read
+	// them from, see augmenter.
+	augmentDir string
+	augment    *augmenter
+}
+
+// stdinLocation is the location of a restore that reads a single backup file from stdin.
+const stdinLocation = "-"
+
+// stdin is the reader of stdinLocation.
+var stdin io.Reader = os.Stdin
+
+// load calls fn for each one of the backup files at the location, see Load. With
+// o.maxInflightFiles, the files of a remote location are downloaded into o.tmpDir ahead of
+// their load, see prefetcher. With the location stdinLocation, the only file loaded is the
+// one read from stdin. It has no manifest, so it belongs to the group given in o.groups,
+// group 1 by default. It's encrypted if o.key is set and compressed with o.compression, or with
+// the codec told from its first bytes.
+func (o *restoreOptions) load(filter loadFilter, fn loadFn) error {
+	if o.location != stdinLocation {
+		var pf *prefetcher
+		if o.maxInflightFiles > 0 {
+			var err error
+			if pf, err = newPrefetcher(o.tmpDir, o.maxInflightFiles); err != nil {
+				return err
+			}
+			defer pf.close()
+		}
+		if o.augment != nil {
+			filter, fn = o.augment.filter(filter), o.augment.wrap(fn)
+		}
+		return Load(o.location, o.restoreTs, o.workers, &o.creds, filter, pf, fn)
+	}
+	f := &loadFile{name: "stdin", group: 1, size: -1, compression: o.compression}
+	if f.compression == "" {
+		f.compression = compressionDetect
+	}
+	if len(o.groups) > 1 {
+		return x.Errorf("The backup read from stdin belongs to a single group, got %d groups.",
+			len(o.groups))
+	}
+	if len(o.groups) == 1 {
+		f.group = uint32(o.groups[0])
+	}
+	if o.key != nil {
+		f.encryption = encryptionAESGCM
+	}
+	if filter != nil && !filter(f) {
+		return nil
+	}
+	return fn(stdin, f)
+}
+
+// runRestore restores the backups at o.location into pdir, or sends them to o.alpha, in the
+// phases of a restorer: it plans the restore, checks the schema, loads the files, verifies
+// what was loaded, rebuilds the indexes and writes the state of the restored groups.
+func runRestore(o *restoreOptions, p *progress) error {
+	go p.report()
+	defer p.stop()
+	re, err := openRestorer(o, p)
+	if err != nil {
+		return err
+	}
+	defer re.close()
+
+	if err := re.checkSchema(); err != nil {
+		return err
+	}
+	if err := re.load(); err != nil {
+		return err
+	}
+	if err := re.verify(); err != nil {
+		return err
+	}
+	if err := re.reindex(); err != nil {
+		return err
+	}
+	return re.finalize()
+}
+
+// restorer holds the state of a restore across its phases, see runRestore.
+type restorer struct {
+	o   *restoreOptions
+	p   *progress
+	sum *summary
+
+	chain    []*Manifest
+	updates  []*pb.SchemaUpdate // schema of o.schemaFile, see rebuildIndexes
+	expected []*pb.SchemaUpdate // schema of o.validateSchemaFile, see backupSchema
+	preds    *predicateSet
+	gids     map[uint32]bool // groups of o.groups, set to true once a file of theirs is found
+	zs       *zeroState
+	stats    *restoreStats
+	cps      *checkpoints // progress saved in pdir, nil if nothing is written there
+
+	ll        *liveLoader // sends the data to o.alpha
+	rb        *rebalancer // spreads the predicates over o.rebalance groups
+	noIndexes bool        // some of the backups have no index keys
+}
+
+// openRestorer plans the restore: it finds the chain of backups to restore, checks they're
+// encrypted if and only if o.key is set, see checkEncryption, and reads the schema files.
+// Unless the restore only reads the backups, pdir is checked and its checkpoints opened.
+func openRestorer(o *restoreOptions, p *progress) (*restorer, error) {
+	if o.limit == nil {
+		o.limit = NewRateLimiter(o.rateLimit)
+	}
+	re := &restorer{o: o, p: p, preds: newPredicateSet(o.predicates, o.prefixes),
+		zs: newZeroState()}
+	if o.location != stdinLocation {
+		var err error
+		if re.chain, err = Chain(o.location, o.restoreTs, &o.creds); err != nil {
+			return nil, err
+		}
+		if err := checkEncryption(re.chain, o.key); err != nil {
+			return nil, err
+		}
+		if o.augmentDir != "" {
+			if o.augment, err = newAugmenter(o.augmentDir, re.chain); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var err error
+	if re.updates, err = o.readSchemaFile(); err != nil {
+		return nil, err
+	}
+	if re.expected, err = parseSchemaFile(o.validateSchemaFile, "--validate_schema_file"); err != nil {
+		return nil, err
+	}
+
+	if len(o.groups) > 0 {
+		re.gids = make(map[uint32]bool)
+		for _, gid := range o.groups {
+			re.gids[uint32(gid)] = false
+		}
+	}
+	if o.statsCSV != "" {
+		re.stats = newRestoreStats()
+	}
+	if o.alpha == "" && !o.dryRun {
+		if o.clearStaleLock {
+			for _, root := range o.postingsRoots() {
+				if err := clearStaleLocks(root, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := o.preflight(p); err != nil {
+			return nil, err
+		}
+		if re.cps, err = openCheckpoints(o); err != nil {
+			return nil, err
+		}
+	}
+	re.sum = newSummary()
+	return re, nil
+}
+
+// close releases what the phases of the restore opened.
+func (re *restorer) close() {
+	if re.rb != nil {
+		re.rb.close()
+	}
+	if re.ll != nil {
+		re.ll.close()
+	}
+	re.sum.close()
+}
+
+// selected returns whether the file f is restored. The files of other groups, and the ones
+// known not to have any of the predicates, are skipped. An incremental backup can drop all the
+// data, so it's loaded even without the predicates.
+func (re *restorer) selected(f *loadFile) bool {
+	re.noIndexes = re.noIndexes || f.skipIndexes
+	if re.gids != nil {
+		if _, ok := re.gids[f.group]; !ok {
+			return false
+		}
+		re.gids[f.group] = true
+	}
+	return re.preds == nil || f.preds == nil || f.since > 0 || re.preds.hasAny(f.preds)
+}
+
+// pending returns whether the file f is selected and wasn't restored already by a restore that
+// died. The state of the ones restored already is merged as if they were restored again.
+func (re *restorer) pending(f *loadFile) bool {
+	if !re.selected(f) {
+		return false
+	}
+	if re.cps != nil {
+		if cp := re.cps.get(f.name); cp.Done {
+			re.p.printf("Skipping backup %q, it was restored already\n", f.name)
+			re.zs.merge(&cp.State, f.readTs)
+			re.stats.merge(cp.State.Stats)
+			return false
+		}
+	}
+	return true
+}
+
+// checkKey fails if the file f is encrypted and no key was given.
+func (re *restorer) checkKey(f *loadFile) error {
+	if f.encryption != "" && re.o.key == nil {
+		return x.Errorf("Backup %q is encrypted, its key must be given with "+
+			"--encryption_key_file", f.name)
+	}
+	return nil
+}
+
+// checkSchema reads the files once before anything is written, with o.abortOnSchemaMissing to
+// fail if they have data of predicates without a schema, see schemaCheck, and with
+// o.validateSchemaFile to compare their schema with the one of that file, see backupSchema.
+func (re *restorer) checkSchema() error {
+	o, p := re.o, re.p
+	var bs *backupSchema
+	if o.validateSchemaFile != "" {
+		bs = newBackupSchema()
+	}
+	if o.abortOnSchemaMissing || bs != nil {
+		// The files are read once first, so nothing of a partial backup is restored. The ones
+		// restored already are read too, for the schema of the whole backup.
+		err := o.load(re.selected, func(r io.Reader, f *loadFile) error {
+			if err := re.checkKey(f); err != nil {
+				return err
+			}
+			p.printf("Checking the schema of backup %q\n", f.name)
+			fp := &fileProgress{loadFile: f, start: time.Now()}
+			r, err := o.newReader(r, f, fp)
+			if err != nil {
+				return err
+			}
+			attrs, err := checkSchema(r, f.group, re.preds, bs)
+			if err != nil {
+				return err
+			}
+			if len(attrs) > 0 && o.abortOnSchemaMissing {
+				return x.Errorf("Backup %q has data of predicates without a schema: %s. "+
+					"It may be partial or corrupted.", f.name, strings.Join(attrs, ", "))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if bs == nil {
+		return nil
+	}
+	diffs := bs.diff(re.expected, re.preds)
+	switch {
+	case len(diffs) == 0:
+		p.printf("The schema of the backup matches %q\n", o.validateSchemaFile)
+	case o.strict:
+		return x.Errorf("The schema of the backup differs from %q:\n  %s",
+			o.validateSchemaFile, strings.Join(diffs, "\n  "))
+	default:
+		p.printf("Warning: the schema of the backup differs from %q:\n  %s\n",
+			o.validateSchemaFile, strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
+
+// load restores each pending file: with o.alpha it's sent as mutations, see liveLoader, with
+// o.dryRun it's only verified, and otherwise it's written into pdir, see writeFile.
+func (re *restorer) load() error {
+	o, p := re.o, re.p
+	if o.alpha != "" && !o.dryRun {
+		var err error
+		if re.ll, err = newLiveLoader(o); err != nil {
+			return err
+		}
+		// The schema must be set before the data is sent, but the schema keys are stored
+		// after the data keys. So the files are read once to get the schema first.
+		err = o.load(re.pending, func(r io.Reader, f *loadFile) error {
+			if err := re.checkKey(f); err != nil {
+				return err
+			}
+			p.printf("Reading schema of backup %q\n", f.name)
+			fp := &fileProgress{loadFile: f, start: time.Now()}
+			r, err := o.newReader(r, f, fp)
+			if err != nil {
+				return err
+			}
+			return re.ll.readSchema(r, re.preds)
+		})
+		if err != nil {
+			return err
+		}
+		if err := re.ll.alterSchema(context.Background()); err != nil {
+			return x.Wrapf(err, "while setting the schema")
+		}
+	}
+
+	if o.rebalance > 0 {
+		groups, err := assignPredicates(re.chain, o.rebalance, re.preds)
+		if err != nil {
+			return err
+		}
+		for i, attrs := range groupPredicates(groups, o.rebalance) {
+			p.printf("Predicates of group %d: %s\n", i+1, strings.Join(attrs, ", "))
+		}
+		if !o.dryRun {
+			if re.rb, err = newRebalancer(o, groups); err != nil {
+				return err
+			}
+		}
+	}
+
+	return o.load(re.pending, func(r io.Reader, f *loadFile) error {
+		if err := re.checkKey(f); err != nil {
+			return err
+		}
+		if o.localRead == localReadMmap {
+			mr, err := mmapFile(r)
+			if err != nil {
+				return err
+			}
+			if mr != nil {
+				defer mr.Close()
+				r = mr
+			}
+		}
+		switch {
+		case re.ll != nil:
+			return re.sendFile(r, f)
+		case o.dryRun:
+			return re.verifyFile(r, f)
+		default:
+			return re.writeFile(r, f)
+		}
+	})
+}
+
+// sendFile sends the KVs of the file f to o.alpha as mutations.
+func (re *restorer) sendFile(r io.Reader, f *loadFile) error {
+	re.p.printf("Sending backup %q to %s\n", f.name, re.o.alpha)
+	fp := re.p.add(f)
+	r, err := re.o.newReader(r, f, fp)
+	if err != nil {
+		return err
+	}
+	if err := re.ll.send(context.Background(), r, f, re.preds, fp); err != nil {
+		return err
+	}
+	re.p.done(fp)
+	re.sum.add(fp)
+	return nil
+}
+
+// verifyFile reads the file f without writing it, see verifyBackup.
+func (re *restorer) verifyFile(r io.Reader, f *loadFile) error {
+	re.p.printf("Verifying backup %q\n", f.name)
+	fp := re.p.add(f)
+	r, err := re.o.newReader(r, f, fp)
+	if err != nil {
+		return err
+	}
+	if err := verifyBackup(r, fp); err != nil {
+		return err
+	}
+	re.p.done(fp)
+	re.sum.add(fp)
+	return nil
+}
+
+// fileWriter writes the KVs of a backup file into pdir.
+type fileWriter struct {
+	dir   string
+	route routeFn
+	flush func() error
+	drop  dropFn
+	db    *badger.DB  // DB of the group of the file, nil if the KVs are rebalanced
+	lw    *loadWriter // writer of an ordered full backup with o.loadWriter
+}
+
+// openWriter returns the writer of the file f, whose KVs add to the state fs.
+func (re *restorer) openWriter(f *loadFile, fs *fileState) (*fileWriter, error) {
+	fw := &fileWriter{dir: re.o.pdir}
+	if re.rb != nil {
+		fw.route, fw.flush = re.rb.writers(fs)
+		fw.drop = func(attr string, ts uint64) error {
+			for _, db := range re.rb.dbs {
+				if err := dropKeys(db, attr, ts, re.preds); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return fw, nil
+	}
+
+	fw.dir = re.o.groupDir(f.group)
+	var err error
+	if fw.db, err = re.o.openPostings(fw.dir); err != nil {
+		return nil, err
+	}
+	if re.o.loadWriter && f.since == 0 && f.ordered {
+		// Only the full backups known to be in key order are loaded, the others are written
+		// in transactions. Load can't wait for batches, so flush only checks it didn't fail.
+		// The writer is replaced at each checkpoint, and gets the keys after it, still in
+		// order. A full backup has no drops.
+		fw.lw = newLoadWriter(fw.db)
+		fw.route = func(pk *x.ParsedKey, kv *pb.KV) (kvWriter, error) {
+			return fw.lw, fs.add(pk, kv, f.group)
+		}
+		fw.flush = func() error { return fw.lw.check() }
+		fw.drop = func(string, uint64) error {
+			return x.Errorf("Full backup %q has drops, it can't be restored with "+
+				"--load_writer", f.name)
+		}
+		return fw, nil
+	}
+	w := x.NewTxnWriter(fw.db)
+	w.BlindWrite = true
+	fw.route = func(pk *x.ParsedKey, kv *pb.KV) (kvWriter, error) {
+		return w, fs.add(pk, kv, f.group)
+	}
+	fw.flush = w.Flush
+	fw.drop = func(attr string, ts uint64) error {
+		return dropKeys(fw.db, attr, ts, re.preds)
+	}
+	return fw, nil
+}
+
+// sync waits for the KVs routed so far to be written. With a load writer, they're only known
+// to be written once it's closed, so the next KVs go to a new one.
+func (fw *fileWriter) sync() error {
+	if fw.lw == nil {
+		return nil
+	}
+	if err := fw.lw.close(); err != nil {
+		return err
+	}
+	fw.lw = newLoadWriter(fw.db)
+	return nil
+}
+
+func (fw *fileWriter) close() {
+	if fw.lw != nil {
+		fw.lw.close()
+	}
+	if fw.db != nil {
+		fw.db.Close()
+	}
+}
+
+// writeFile writes the KVs of the file f into pdir, saving a checkpoint as it goes, and
+// resuming from the one saved by a restore that died.
+func (re *restorer) writeFile(r io.Reader, f *loadFile) error {
+	o, p := re.o, re.p
+	cp := re.cps.get(f.name)
+	fs := cp.State
+	if re.stats != nil && fs.Stats == nil {
+		fs.Stats = make(statsSet)
+	}
+	fw, err := re.openWriter(f, &fs)
+	if err != nil {
+		return err
+	}
+	defer fw.close()
+
+	route := fw.route
+	if o.reindexOnStart {
+		route = skipIndexes(route)
+	} else if o.dropIndexData {
+		route = skipIndexKeys(route)
+	}
+	check := newSchemaCheck()
+	route = check.route(route)
+	if cp.Keys > 0 {
+		p.printf("Resuming backup %q into %q after %d keys\n", f.name, fw.dir, cp.Keys)
+	} else {
+		p.printf("Restoring backup %q into %q\n", f.name, fw.dir)
+	}
+	fp := p.add(f)
+	// Only the offsets of plain files are offsets in the file itself.
+	from := resumePoint{keys: cp.Keys, offset: cp.Offset}
+	if cp.Offset > 0 && plainFile(f) {
+		sr, ok, err := seekFrames(r, cp.Offset)
+		if err != nil {
+			return x.Wrapf(err, "while resuming %q", f.name)
+		}
+		if ok {
+			r, from.seeked = sr, true
+			atomic.AddInt64(&fp.bytes, cp.Offset)
+		}
+	}
+	r, err = o.newReader(r, f, fp)
+	if err != nil {
+		return err
+	}
+	checkpoint := func(keys, offset int64) error {
+		if err := fw.sync(); err != nil {
+			return x.Wrapf(err, "while writing %q", f.name)
+		}
+		return re.cps.save(f.name, keys, offset, false, &fs)
+	}
+	err = loadKVs(r, 0, re.preds, fp, from, route, fw.flush, fw.drop, o.limits, checkpoint)
+	if err != nil {
+		return err
+	}
+	if fw.lw != nil {
+		if err := fw.lw.close(); err != nil {
+			return x.Wrapf(err, "while writing %q", f.name)
+		}
+	}
+	// The keys skipped when resuming aren't checked, they may have the schema.
+	if attrs := check.missing(); len(attrs) > 0 && cp.Keys == 0 {
+		p.printf("Warning: backup %q has data of predicates without a schema: %s\n",
+			f.name, strings.Join(attrs, ", "))
+	}
+	if err := re.cps.save(f.name, 0, 0, true, &fs); err != nil {
+		return err
+	}
+	re.zs.merge(&fs, f.readTs)
+	re.stats.merge(fs.Stats)
+	p.done(fp)
+	re.sum.add(fp)
+	return nil
+}
+
+// verify checks what was loaded: it saves the checksums computed by o.augment, checks a file
+// of each group of o.groups was found, and warns if the versions of the restored groups
+// diverge, see checkVersions.
+func (re *restorer) verify() error {
+	o, p := re.o, re.p
+	if o.augment != nil {
+		paths, err := o.augment.save(re.chain)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			p.printf("Wrote the checksums of the backup files to %q\n", path)
+		}
+	}
+	for _, gid := range o.groups {
+		if !re.gids[uint32(gid)] {
+			return x.Errorf("No backups of group %d found in %q", gid, o.location)
+		}
+	}
+	if re.rb != nil {
+		if err := re.rb.close(); err != nil {
+			return err
+		}
+	}
+	if re.ll != nil || o.dryRun {
+		return nil
+	}
+	// The versions are checked before the indexes are rebuilt at a new version.
+	versions, err := o.groupVersions(re.zs)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		p.printf("Max version of group %d: %d\n", v.group, v.max)
+	}
+	if diverged := checkVersions(versions, re.zs.maxReadTs(), o.versionSkew); len(diverged) > 0 {
+		p.printf("Warning: the versions of the restored groups diverge, the backups may be "+
+			"mixed up or corrupted:\n  %s\n", strings.Join(diverged, "\n  "))
+	}
+	return nil
+}
+
+// reindex rebuilds the indexes of the restored data with o.schemaFile or o.reindex, or if the
+// backups have no index keys, see rebuildIndexes. With o.alpha, the schema file is set in the
+// cluster instead, and the cluster rebuilds them.
+func (re *restorer) reindex() error {
+	o, p := re.o, re.p
+	if re.ll != nil {
+		if o.schemaFile != "" {
+			p.printf("Setting the schema in %q\n", o.schemaFile)
+			if err := re.ll.alterSchemaFile(context.Background(), o.schemaFile); err != nil {
+				return x.Wrapf(err, "while setting the schema")
+			}
+		}
+		return nil
+	}
+	if o.dryRun {
+		return nil
+	}
+	reindex := o.schemaFile != "" || o.reindex || o.reindexOnStart
+	if o.dropIndexData && !reindex {
+		p.printf("The index keys weren't restored, the indexes must be rebuilt in the " +
+			"cluster\n")
+	}
+	if re.noIndexes && !reindex && !o.dropIndexData {
+		p.printf("The backups don't have the index keys, rebuilding them\n")
+		reindex = true
+	}
+	if !reindex {
+		return nil
+	}
+	return o.rebuildIndexes(p, re.zs, re.updates)
+}
+
+// finalize writes the statistics of o.statsCSV and the Zero state of the restored groups,
+// removes the checkpoints, and reports the totals of the files restored by group.
+func (re *restorer) finalize() error {
+	o, p := re.o, re.p
+	if re.stats != nil {
+		if err := re.stats.writeCSV(o.statsCSV); err != nil {
+			return err
+		}
+		p.printf("Wrote the statistics of the restored predicates to %q\n", o.statsCSV)
+	}
+	if re.cps != nil {
+		if err := re.cps.remove(); err != nil {
+			return err
+		}
+	}
+	if (o.zeroState || o.rebalance > 0) && re.ll == nil && !o.dryRun {
+		if err := re.zs.write(o.pdir); err != nil {
+			return err
+		}
+		p.printf("Wrote the Zero state of the restored groups to %q\n",
+			filepath.Join(o.pdir, zeroStateName))
+	}
+	if re.ll != nil && re.ll.skipped > 0 {
+		p.printf("Skipped %d password values, they can't be sent as mutations\n",
+			re.ll.skipped)
+	}
+	re.sum.write(p)
+	if len(o.roots) > 1 && o.alpha == "" && !o.dryRun {
+		for _, gid := range re.zs.groups() {
+			if _, err := os.Stat(o.groupDir(gid)); err == nil {
+				p.printf("Restored group %d into %q\n", gid, o.groupDir(gid))
+			}
+		}
+	}
+	return nil
+}
+
+// predicateSet selects predicates by name or by prefix. A nil set selects all of them.
+type predicateSet struct {
+	names    map[string]struct{}
+	prefixes []string
+}
+
+// newPredicateSet returns the set of the predicates in names and the ones starting with any of
+// prefixes, or nil if both are empty.
+func newPredicateSet(names, prefixes []string) *predicateSet {
+	if len(names) == 0 && len(prefixes) == 0 {
+		return nil
+	}
+	ps := &predicateSet{names: make(map[string]struct{}), prefixes: prefixes}
+	for _, attr := range names {
+		ps.names[attr] = struct{}{}
+	}
+	return ps
+}
+
+// has returns whether attr is in the set.
+func (ps *predicateSet) has(attr string) bool {
+	if ps == nil {
+		return true
+	}
+	if _, ok := ps.names[attr]; ok {
+		return true
+	}
+	for _, prefix := range ps.prefixes {
+		if strings.HasPrefix(attr, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAny returns whether any of attrs is in the set.
+func (ps *predicateSet) hasAny(attrs []string) bool {
+	for _, attr := range attrs {
+		if ps.has(attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreGroup loads the backups of group req.GroupId at req.Location into db, the pstore of
+// a running Alpha. The chain of backups is chosen as in the offline restore, using
+// req.RestoreTs. Every data KV is written at version req.CommitTs instead of its original
+// one, so the restored data sits on top of the data deleted before the restore. A key is
+// written once per backup in the chain, the later backups overwrite the earlier ones.
+// Encrypted backups are decrypted with key. The files are read at the rate allowed by limit.
+// If the backups don't have the index keys, the restored predicates are marked with
+// x.ReindexKey at req.CommitTs, for the Alpha to rebuild their indexes.
+// The drops in the incremental backups delete the data loaded before them at req.CommitTs.
+// If req.SinceTs is set, only the backups of the chain taken after it are loaded, on top of
+// the data already there. If req.Replicate is set, req.RestoreTs is written at x.ReplicatedKey.
+func RestoreGroup(db *badger.DB, req *pb.RestoreRequest, key []byte,
+	limit *RateLimiter) (err error) {
+	defer func(start time.Time) { recordRestore(start, err) }(time.Now())
+	if req.CommitTs == 0 {
+		return x.Errorf("Restore of group %d has no commit ts", req.GroupId)
+	}
+	var found, noIndexes bool
+	attrs := make(map[string]bool)
+	filter := func(f *loadFile) bool {
+		if f.group != req.GroupId || f.readTs <= req.SinceTs {
+			return false
+		}
+		found = true
+		noIndexes = noIndexes || f.skipIndexes
+		for _, attr := range f.preds {
+			attrs[attr] = true
+		}
+		return true
+	}
+	o := &restoreOptions{key: key, limit: limit}
+	err = Load(req.Location, req.RestoreTs, 1, &Credentials{}, filter, nil,
+		func(r io.Reader, f *loadFile) error {
+			if f.encryption != "" && key == nil {
+				return x.Errorf("Backup %q is encrypted, its key must be given with "+
+					"--encryption_key_file", f.name)
+			}
+			glog.Infof("Restore: loading backup %q into group %d", f.name, f.group)
+			fp := &fileProgress{loadFile: f, start: time.Now()}
+			r, err := o.newReader(r, f, fp)
+			if err != nil {
+				return err
+			}
+			if err := loadFromBackup(db, r, req.CommitTs, nil, fp); err != nil {
+				return err
+			}
+			glog.Infof("Restore: loaded %s keys from %q in %s", humanize.Comma(fp.keys),
+				f.name, time.Since(fp.start).Round(time.Second))
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	if !found && req.SinceTs == 0 {
+		return x.Errorf("No backups of group %d found in %q", req.GroupId, req.Location)
+	}
+	if !noIndexes && !req.Replicate {
+		return nil
+	}
+	w := x.NewTxnWriter(db)
+	w.BlindWrite = true
+	if noIndexes {
+		for attr := range attrs {
+			if err := w.SetAt(x.ReindexKey(attr), nil, 0, req.CommitTs); err != nil {
+				return err
+			}
+		}
+	}
+	if req.Replicate {
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], req.RestoreTs)
+		if err := w.SetAt(x.ReplicatedKey(), ts[:], 0, req.CommitTs); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// recordRestore updates the restore metrics with the outcome err of a restore started at
+// start. The bytes and keys read are counted as they're loaded.
+func recordRestore(start time.Time, err error) {
+	if err != nil {
+		x.RestoreFailures.Add(1)
+		return
+	}
+	x.RestoreDuration.Set(int64(time.Since(start).Seconds()))
+	x.RestoreLastSuccess.Set(time.Now().Unix())
+}
+
+// newReader returns a buffered reader of the KVs in backup file f, read from r. The bytes
+// read from r are counted in fp, and limited by o.limit. Encrypted files are decrypted with
+// o.key, then decompressed. The KVs of a plain file mapped into memory are read from the
+// mapping instead, see mmapReader.
+func (o *restoreOptions) newReader(r io.Reader, f *loadFile, fp *fileProgress) (
+	io.Reader, error) {
+	if mr, ok := r.(*mmapReader); ok && plainFile(f) {
+		mr.fp, mr.limit = fp, o.limit
+		return mr, nil
+	}
+	r = &progressReader{r: o.limit.Reader(r), fp: fp}
+	if f.encryption != "" {
+		dr, err := newDecryptReader(r, o.key)
+		if err != nil {
+			return nil, err
+		}
+		r = dr
+	}
+	r, err := newDecompressor(f.compression, r)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReaderSize(r, 1<<20), nil
+}
+
+// loadFromBackup reads the KVs written by writer.Send and commits each one into db at its
+// original version. If version is set, the data KVs are committed at version instead.
+// If preds is set, the KVs of other predicates are skipped.
+// The loaded keys are counted in fp.
+func loadFromBackup(db *badger.DB, r io.Reader, version uint64,
+	preds *predicateSet, fp *fileProgress) error {
+	w := x.NewTxnWriter(db)
+	w.BlindWrite = true
+	route := func(*x.ParsedKey, *pb.KV) (kvWriter, error) { return w, nil }
+	drop := func(attr string, ts uint64) error { return dropKeys(db, attr, ts, preds) }
+	return loadKVs(r, version, preds, fp, resumePoint{}, route, w.Flush, drop, batchLimits{},
+		nil)
+}
+
+// kvWriter commits KVs at their versions, like x.TxnWriter.
+type kvWriter interface {
+	SetAt(key, val []byte, meta byte, ts uint64) error
+}
+
+// routeFn returns the writer to commit a KV with, or nil to skip it.
+type routeFn func(*x.ParsedKey, *pb.KV) (kvWriter, error)
+
+// dropFn deletes at ts the data of attr, or all the data if attr is empty, see dropKeys.
+type dropFn func(attr string, ts uint64) error
+
+// dropKeys deletes at ts the keys of attr in db, or the keys of all the predicates if attr is
+// empty, as the drop recorded at ts in an incremental backup did, see x.DropKey. The keys
+// written after ts are kept. The schema keys are always at version 1, so they're deleted at
+// version 1, the ones of the predicates set again after the drop are loaded after it.
+// If preds is set, only the keys of those predicates are deleted. A drop of all the data keeps
+// the schema of the initial predicates, like posting.DeleteAll.
+func dropKeys(db *badger.DB, attr string, ts uint64, preds *predicateSet) error {
+	prefixes := [][]byte{nil}
+	if attr != "" {
+		prefixes = [][]byte{x.PredicatePrefix(attr), x.SchemaKey(attr)}
+	}
+	txn := db.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	w := x.NewTxnWriter(db)
+	w.BlindWrite = true
+	for _, prefix := range prefixes {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = prefix
+		opt.PrefetchValues = false
+		itr := txn.NewIterator(opt)
+		for itr.Rewind(); itr.Valid(); itr.Next() {
+			item := itr.Item()
+			pk := x.Parse(item.Key())
+			if pk == nil || !preds.has(pk.Attr) {
+				continue
+			}
+			version := ts
+			switch {
+			case !pk.IsPredicate():
+				continue
+			case pk.IsSchema():
+				if _, ok := x.InitialPreds[pk.Attr]; ok && attr == "" {
+					continue
+				}
+				version = 1
+			case item.Version() > ts:
+				continue
+			}
+			if err := w.Delete(item.KeyCopy(nil), version); err != nil {
+				itr.Close()
+				return err
+			}
+		}
+		itr.Close()
+	}
+	return w.Flush()
+}
+
+// skipIndexKeys returns a routeFn that skips the index keys, and routes the rest with route.
+func skipIndexKeys(route routeFn) routeFn {
+	return func(pk *x.ParsedKey, kv *pb.KV) (kvWriter, error) {
+		if pk.IsIndex() {
+			return nil, nil
+		}
+		return route(pk, kv)
+	}
+}
+
+// skipIndexes returns a routeFn that skips the index, reverse and count keys, and routes the
+// rest with route.
+func skipIndexes(route routeFn) routeFn {
+	return func(pk *x.ParsedKey, kv *pb.KV) (kvWriter, error) {
+		if pk.IsIndex() || pk.IsReverse() || pk.IsCount() {
+			return nil, nil
+		}
+		return route(pk, kv)
+	}
+}
+
+// batchLimits bound the KVs loadKVs writes before it waits for them to be committed, so the
+// memory used by a restore doesn't grow with the size of the backup files. The zero values
+// use the defaults.
+type batchLimits struct {
+	keys  int   // KVs in a batch
+	bytes int64 // size of the keys and values of a batch
+}
+
+// Default batchLimits.
+const (
+	defaultBatchKeys  = 10000
+	defaultBatchBytes = 64 << 20
+)
+
+// loadKVs is like loadFromBackup, but commits each KV with the writer returned by route for
+// it. The KVs are written in batches bounded by limits, flush must wait for the KVs routed so
+// far to be committed. The drops in the backup are applied with drop once the KVs before them
+// are committed, the incremental backups write them before their data. The KVs before from
+// are skipped, they were committed before.
+// If checkpoint is set, it's called every checkpointKeys KVs with the number of KVs read and
+// committed so far, and the offset their frames end at.
+func loadKVs(r io.Reader, version uint64, preds *predicateSet,
+	fp *fileProgress, from resumePoint, route routeFn, flush func() error, drop dropFn,
+	limits batchLimits, checkpoint func(keys, offset int64) error) error {
+	if limits.keys <= 0 {
+		limits.keys = defaultBatchKeys
+	}
+	if limits.bytes <= 0 {
+		limits.bytes = defaultBatchBytes
+	}
+	// The writers keep the keys and values until they're committed, but readBackup reuses
+	// them. So they're copied to buf, which is reused once the batch is committed.
+	var buf []byte
+	var batch int
+	commit := func() error {
+		if err := flush(); err != nil {
+			return err
+		}
+		buf, batch = buf[:0], 0
+		return nil
+	}
+
+	// The KVs read, and the offset their frames end at. The offsets of a reader that was
+	// seeked are counted from where it starts.
+	var read, offset, base int64
+	skip := from.keys
+	if from.seeked {
+		read, base, skip = from.keys, from.offset, 0
+	}
+	err := readBackupAt(r, func(kv *pb.KV, end int64) error {
+		read++
+		prev := offset
+		offset = base + end
+		if read <= skip {
+			return nil
+		}
+		if checkpoint != nil && read%checkpointKeys == 0 {
+			// The KVs before this one are committed, this one isn't yet.
+			if err := commit(); err != nil {
+				return err
+			}
+			if err := checkpoint(read-1, prev); err != nil {
+				return err
+			}
+		}
+		pk := x.Parse(kv.Key)
+		if pk == nil {
+			return x.Errorf("Invalid key %q in backup", kv.Key)
+		}
+		if pk.IsDrop() {
+			// A drop of all the data has no predicate, dropKeys checks preds.
+			if pk.Attr != "" && !preds.has(pk.Attr) {
+				return nil
+			}
+			ts := kv.Version
+			if version > 0 {
+				ts = version
+			}
+			if err := commit(); err != nil {
+				return err
+			}
+			return drop(pk.Attr, ts)
+		}
+		if !preds.has(pk.Attr) {
+			return nil
+		}
+		w, err := route(pk, kv)
+		if err != nil || w == nil {
+			return err
+		}
+		var meta byte
+		if len(kv.UserMeta) > 0 {
+			meta = kv.UserMeta[0]
+		}
+		// The schema is always read at version 1, so it keeps its version.
+		ts := kv.Version
+		if version > 0 && !bytes.HasPrefix(kv.Key, x.SchemaPrefix()) {
+			ts = version
+		}
+		start := len(buf)
+		buf = append(buf, kv.Key...)
+		buf = append(buf, kv.Val...)
+		key, val := buf[start:start+len(kv.Key)], buf[start+len(kv.Key):]
+		if err := w.SetAt(key, val, meta, ts); err != nil {
+			return err
+		}
+		atomic.AddInt64(&fp.keys, 1)
+		x.RestoreKeys.Add(1)
+		if batch++; batch >= limits.keys || int64(len(buf)) >= limits.bytes {
+			return commit()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return commit()
+}
+
+// openPostings opens the posting directory dir to restore into, creating it if needed.
+// The badger settings of o are applied.
+func (o *restoreOptions) openPostings(dir string) (*badger.DB, error) {
+	bo := badger.DefaultOptions
+	bo.SyncWrites = o.badgerSyncWrites
+	switch o.badgerTables {
+	case "", "mmap":
+		bo.TableLoadingMode = options.MemoryMap
+	case "ram":
+		bo.TableLoadingMode = options.LoadToRAM
+	case "disk":
+		bo.TableLoadingMode = options.FileIO
+	default:
+		return nil, x.Errorf("Invalid badger tables mode %q, it must be ram, mmap or disk.",
+			o.badgerTables)
+	}
+	if o.badgerVlogSize > 0 {
+		bo.ValueLogFileSize = o.badgerVlogSize << 20
+	}
+	if o.badgerCompactors > 0 {
+		bo.NumCompactors = o.badgerCompactors
+	}
+	bo.ValueThreshold = 1 << 10
+	bo.NumVersionsToKeep = math.MaxInt32
+	bo.Dir = dir
+	bo.ValueDir = dir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return badger.OpenManaged(bo)
+}
@@ -0,0 +1,1197 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBackup writes a backup of the given groups, one per KVS starting at group 1,
+// followed by its manifest.
+func writeBackup(t testing.TB, target, unixTs string, since, readTs uint64, groups ...*pb.KVS) {
+	writeBackupOpts(t, target, unixTs, since, readTs, backupOpts{}, groups...)
+}
+
+// backupOpts are the optional settings of a test backup.
+type backupOpts struct {
+	key         []byte // encryption key
+	compression string
+	skipIndexes bool
+	ordered     bool // the keys of the files are in key order
+}
+
+// writeBackupOpts is like writeBackup, but encrypts and compresses the backup files as
+// set in o, and records in the manifest if they skip the index keys or are in key order.
+func writeBackupOpts(t testing.TB, target, unixTs string, since, readTs uint64, o backupOpts,
+	groups ...*pb.KVS) {
+	var gids []uint32
+	checksums := make(map[uint32]string)
+	preds := make(map[uint32][]string)
+	for i, kvs := range groups {
+		req := &Request{Key: o.key, Backup: &pb.BackupRequest{
+			ReadTs:      readTs,
+			SinceTs:     since,
+			GroupId:     uint32(i + 1),
+			UnixTs:      unixTs,
+			Target:      target,
+			Compression: o.compression,
+		}}
+		w, err := req.newWriter()
+		require.NoError(t, err)
+		require.NoError(t, w.Send(kvs))
+		require.NoError(t, w.flush())
+		gids = append(gids, req.Backup.GroupId)
+		checksums[req.Backup.GroupId] = w.checksum()
+		preds[req.Backup.GroupId] = w.predicates()
+	}
+	m := &Manifest{
+		Version:     x.Version(),
+		Since:       since,
+		ReadTs:      readTs,
+		Groups:      gids,
+		Checksums:   checksums,
+		Predicates:  preds,
+		Compression: o.compression,
+		SkipIndexes: o.skipIndexes,
+		Ordered:     o.ordered,
+	}
+	if o.key != nil {
+		m.Encryption = encryptionAESGCM
+	}
+	require.NoError(t, WriteManifest(target, unixTs, m))
+}
+
+// restore runs a restore of the backups at location into pdir, discarding the progress.
+func restore(t *testing.T, pdir, location string, restoreTs uint64) error {
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: location, pdir: pdir, restoreTs: restoreTs}
+	return runRestore(o, p)
+}
+
+func testKVs(attr string, n int) *pb.KVS {
+	kvs := &pb.KVS{}
+	for i := 1; i <= n; i++ {
+		kvs.Kv = append(kvs.Kv, &pb.KV{
+			Key:      x.DataKey(attr, uint64(i)),
+			Val:      []byte(fmt.Sprintf("val-%d", i)),
+			UserMeta: []byte{1},
+			Version:  uint64(i),
+		})
+	}
+	return kvs
+}
+
+func readKVs(t *testing.T, dir string) map[string]*pb.KV {
+	bo := badger.DefaultOptions
+	bo.Dir = dir
+	bo.ValueDir = dir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	defer db.Close()
+
+	kvs := make(map[string]*pb.KV)
+	txn := db.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	itr := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer itr.Close()
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		item := itr.Item()
+		val, err := item.ValueCopy(nil)
+		require.NoError(t, err)
+		kvs[string(item.Key())] = &pb.KV{
+			Key:      item.KeyCopy(nil),
+			Val:      val,
+			UserMeta: []byte{item.UserMeta()},
+			Version:  item.Version(),
+		}
+	}
+	return kvs
+}
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+
+	for i, expected := range []*pb.KVS{testKVs("name", 5), testKVs("age", 3)} {
+		got := readKVs(t, filepath.Join(pdir, fmt.Sprintf("p%d", i+1)))
+		require.Len(t, got, len(expected.Kv))
+		for _, kv := range expected.Kv {
+			require.Equal(t, kv, got[string(kv.Key)])
+		}
+	}
+}
+
+func TestRestoreBadgerOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, badgerTables: "disk", badgerVlogSize: 1,
+		badgerCompactors: 1, badgerSyncWrites: true}
+	require.NoError(t, runRestore(o, p))
+	expected := testKVs("name", 5)
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, len(expected.Kv))
+	for _, kv := range expected.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+
+	o.badgerTables = "tape"
+	o.force = true
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Invalid badger tables mode")
+
+	o.badgerTables = "mmap"
+	o.badgerVlogSize = 4096
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ValueLogFileSize")
+}
+
+func TestLoadKVsBatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	w := &writer{w: &buf, preds: make(map[string]struct{})}
+	expected := testKVs("name", 10)
+	require.NoError(t, w.Send(expected))
+	b := buf.Bytes()
+
+	for _, limits := range []batchLimits{{keys: 3}, {bytes: 20}, {}} {
+		o := &restoreOptions{}
+		db, err := o.openPostings(filepath.Join(dir, fmt.Sprintf("p%d-%d", limits.keys,
+			limits.bytes)))
+		require.NoError(t, err)
+		tw := x.NewTxnWriter(db)
+		tw.BlindWrite = true
+		route := func(*x.ParsedKey, *pb.KV) (kvWriter, error) { return tw, nil }
+		var flushes int
+		flush := func() error {
+			flushes++
+			return tw.Flush()
+		}
+		err = loadKVs(bytes.NewReader(b), 0, nil, &fileProgress{}, resumePoint{}, route, flush, nil,
+			limits, nil)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+		switch {
+		case limits.keys > 0:
+			require.Equal(t, 4, flushes) // 3 full batches and the last one
+		case limits.bytes > 0:
+			require.Equal(t, 11, flushes) // each KV fills a batch
+		default:
+			require.Equal(t, 1, flushes)
+		}
+
+		got := readKVs(t, filepath.Join(dir, fmt.Sprintf("p%d-%d", limits.keys, limits.bytes)))
+		require.Len(t, got, len(expected.Kv))
+		for _, kv := range expected.Kv {
+			require.Equal(t, kv, got[string(kv.Key)])
+		}
+	}
+}
+
+func TestRestoreNoBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = restore(t, filepath.Join(dir, "postings"), dir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No backups found")
+}
+
+func TestRestoreIncremental(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	// An older full backup that must be skipped, then the chain to restore.
+	writeBackup(t, bdir, "20181105.011302", 0, 5, testKVs("old", 2))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+	update := &pb.KV{
+		Key:      x.DataKey("name", 1),
+		Val:      []byte("updated"),
+		UserMeta: []byte{1},
+		Version:  15,
+	}
+	writeBackup(t, bdir, "20181106.021302", 10, 20, &pb.KVS{Kv: []*pb.KV{update}})
+
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 5)
+	require.Equal(t, update, got[string(update.Key)])
+	for _, kv := range testKVs("name", 5).Kv[1:] {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func TestRestoreBrokenChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeBackup(t, dir, "20181106.011302", 0, 10, testKVs("name", 5))
+	writeBackup(t, dir, "20181106.021302", 15, 20, testKVs("name", 2))
+
+	err = restore(t, filepath.Join(dir, "postings"), dir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Broken backup chain")
+}
+
+func TestRestoreTs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	kv := func(uid uint64, val string, version uint64) *pb.KV {
+		return &pb.KV{
+			Key:      x.DataKey("name", uid),
+			Val:      []byte(val),
+			UserMeta: []byte{1},
+			Version:  version,
+		}
+	}
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+	writeBackup(t, bdir, "20181106.021302", 10, 20,
+		&pb.KVS{Kv: []*pb.KV{kv(1, "after", 15), kv(6, "before", 12)}})
+	writeBackup(t, bdir, "20181106.031302", 20, 30, &pb.KVS{Kv: []*pb.KV{kv(2, "later", 25)}})
+
+	// No backup was taken at 14, the data is restored as of the full backup at 10.
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, restore(t, pdir, bdir, 14))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 5)
+	for _, expected := range testKVs("name", 5).Kv {
+		require.Equal(t, expected, got[string(expected.Key)])
+	}
+
+	// The incremental backup at 20 is restored whole, the one at 30 isn't.
+	pdir = filepath.Join(dir, "postings20")
+	require.NoError(t, restore(t, pdir, bdir, 20))
+	got = readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 6)
+	expected := append(testKVs("name", 5).Kv[1:], kv(1, "after", 15), kv(6, "before", 12))
+	for _, kv := range expected {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+
+	err = restore(t, filepath.Join(dir, "postings2"), bdir, 5)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No full backup found at or before ts 5")
+}
+
+func TestRestoreProgressJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+
+	var buf bytes.Buffer
+	p, err := newProgress("json", &buf)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: filepath.Join(dir, "postings")}
+	require.NoError(t, runRestore(o, p))
+
+	var recs []progressRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec progressRecord
+		require.NoError(t, dec.Decode(&rec))
+		recs = append(recs, rec)
+	}
+	require.Len(t, recs, 2)
+	for i, keys := range []int64{5, 3} {
+		require.True(t, recs[i].Done)
+		require.Equal(t, uint32(i+1), recs[i].Group)
+		require.Equal(t, keys, recs[i].Keys)
+		require.Equal(t, recs[i].TotalBytes, recs[i].Bytes)
+	}
+
+	_, err = newProgress("xml", &buf)
+	require.Error(t, err)
+}
+
+func TestProgressTicker(t *testing.T) {
+	defer func(d time.Duration) { progressInterval = d }(progressInterval)
+	progressInterval = time.Millisecond
+
+	f := &loadFile{name: "r10-g1.backup", group: 1, size: 100}
+	tick := func(format string, force bool) string {
+		var buf bytes.Buffer
+		p, err := newProgress(format, &buf)
+		require.NoError(t, err)
+		p.tick = p.tick || force
+		fp := p.add(f)
+		go p.report()
+		time.Sleep(50 * time.Millisecond)
+		p.stop()
+		p.done(fp)
+		return buf.String()
+	}
+
+	// A buffer isn't a terminal, so only the final report is written.
+	out := tick("text", false)
+	require.NotContains(t, out, "RESTORE")
+	require.Equal(t, 1, strings.Count(out, "\n"))
+	require.Contains(t, out, `Loaded 0 keys from "r10-g1.backup"`)
+
+	require.Contains(t, tick("text", true), "RESTORE")
+	require.True(t, strings.Count(tick("json", false), "\n") > 1)
+}
+
+func TestRestoreWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	attrs := []string{"name", "age", "friend", "city"}
+	var full, incr []*pb.KVS
+	for _, attr := range attrs {
+		full = append(full, testKVs(attr, 5))
+		incr = append(incr, &pb.KVS{Kv: []*pb.KV{{
+			Key:      x.DataKey(attr, 1),
+			Val:      []byte("updated"),
+			UserMeta: []byte{1},
+			Version:  15,
+		}}})
+	}
+	writeBackup(t, bdir, "20181106.011302", 0, 10, full...)
+	writeBackup(t, bdir, "20181106.021302", 10, 20, incr...)
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, runRestore(&restoreOptions{location: bdir, pdir: pdir, workers: 3}, p))
+
+	for i := range attrs {
+		got := readKVs(t, filepath.Join(pdir, fmt.Sprintf("p%d", i+1)))
+		require.Len(t, got, 5)
+		update := incr[i].Kv[0]
+		require.Equal(t, update, got[string(update.Key)])
+	}
+}
+
+func TestRestoreSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	var full, incr []*pb.KVS
+	for i := 1; i <= 16; i++ {
+		full = append(full, testKVs(fmt.Sprintf("attr%d", i), i))
+		incr = append(incr, testKVs(fmt.Sprintf("attr%d", i), 1))
+	}
+	writeBackup(t, bdir, "20181106.011302", 0, 10, full...)
+	writeBackup(t, bdir, "20181106.021302", 10, 20, incr...)
+
+	var expected []string
+	for i := 1; i <= 16; i++ {
+		expected = append(expected, fmt.Sprintf("Group %d: 2 files, %d keys, ", i, i+1))
+	}
+	for run := 0; run < 3; run++ {
+		var buf bytes.Buffer
+		p, err := newProgress("text", ioutil.Discard)
+		require.NoError(t, err)
+		p.msgs = &buf
+		o := &restoreOptions{location: bdir, pdir: filepath.Join(dir, fmt.Sprintf("p%d", run)),
+			workers: 16}
+		require.NoError(t, runRestore(o, p))
+
+		var got []string
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasPrefix(line, "Group ") {
+				got = append(got, line)
+			}
+		}
+		require.Len(t, got, len(expected))
+		for i, line := range got {
+			require.True(t, strings.HasPrefix(line, expected[i]), line)
+		}
+		require.Contains(t, buf.String(), "Total: 32 files, 152 keys, ")
+	}
+}
+
+func TestRestoreChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+
+	// Corrupt the value of the last KV. The file doesn't match the checksum in the manifest,
+	// so none of its KVs are written.
+	file := filepath.Join(bdir, "dgraph.20181106.011302", "r10-g1.backup")
+	orig, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	b := append([]byte{}, orig...)
+	idx := bytes.LastIndex(b, []byte("val-5"))
+	require.True(t, idx > 0)
+	b[idx] = 'X'
+	require.NoError(t, ioutil.WriteFile(file, b, 0600))
+
+	pdir := filepath.Join(dir, "postings")
+	err = restore(t, pdir, bdir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Checksum mismatch")
+	_, err = os.Stat(filepath.Join(pdir, "p1"))
+	require.True(t, os.IsNotExist(err))
+
+	// Without the checksum in the manifest, the checksum of the frame doesn't match.
+	mfile := filepath.Join(bdir, "dgraph.20181106.011302", manifestName)
+	mb, err := ioutil.ReadFile(mfile)
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(mb, &m))
+	sum := m.Checksums[1]
+	m.Checksums[1] = ""
+	require.NoError(t, WriteManifest(bdir, "20181106.011302", &m))
+
+	err = restore(t, filepath.Join(dir, "postings2"), bdir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Frame 4 is corrupted")
+
+	// The file is intact, but doesn't match the checksum in the manifest.
+	require.NoError(t, ioutil.WriteFile(file, orig, 0600))
+	m.Checksums[1] = strings.Repeat("0", 64)
+	require.NoError(t, WriteManifest(bdir, "20181106.011302", &m))
+
+	err = restore(t, filepath.Join(dir, "postings3"), bdir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Checksum mismatch")
+
+	// The intact file is restored.
+	m.Checksums[1] = sum
+	require.NoError(t, WriteManifest(bdir, "20181106.011302", &m))
+	pdir = filepath.Join(dir, "postings4")
+	require.NoError(t, restore(t, pdir, bdir, 0))
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p1")), 5)
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+
+	var out bytes.Buffer
+	p, err := newProgress("json", &out)
+	require.NoError(t, err)
+	pdir := filepath.Join(dir, "postings")
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, dryRun: true}
+	require.NoError(t, runRestore(o, p))
+
+	_, err = os.Stat(pdir)
+	require.True(t, os.IsNotExist(err))
+	var keys int64
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var rec progressRecord
+		require.NoError(t, dec.Decode(&rec))
+		if rec.Done {
+			keys += rec.Keys
+		}
+	}
+	require.Equal(t, int64(8), keys)
+}
+
+func TestRestoreDryRunInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kvs := testKVs("name", 2)
+	kvs.Kv = append(kvs.Kv, &pb.KV{
+		Key:     x.SchemaKey("name"),
+		Val:     []byte("not a schema"),
+		Version: 3,
+	})
+	writeBackup(t, dir, "20181106.011302", 0, 10, kvs)
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: dir, workers: 1, dryRun: true}
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `schema of "name"`)
+}
+
+func TestManifestTopology(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	g1 := testKVs("name", 2)
+	g1.Kv = append(g1.Kv, testKVs("friend", 1).Kv...)
+	writeBackup(t, dir, "20181106.011302", 0, 10, g1, testKVs("age", 3))
+
+	m, err := LatestManifest(dir)
+	require.NoError(t, err)
+	require.Equal(t, x.Version(), m.Version)
+	require.Equal(t, []uint32{1, 2}, m.Groups)
+	require.Equal(t, map[uint32][]string{1: {"friend", "name"}, 2: {"age"}}, m.Predicates)
+	require.Empty(t, m.Encryption)
+
+	// Backups encrypted with an unknown cipher are refused.
+	m.Encryption = "rot13"
+	require.NoError(t, WriteManifest(dir, "20181106.011302", m))
+	err = restore(t, filepath.Join(dir, "postings"), dir, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is encrypted")
+}
+
+func TestRestorePredicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	g1 := testKVs("name", 5)
+	g1.Kv = append(g1.Kv, testKVs("friend", 2).Kv...)
+	writeBackup(t, bdir, "20181106.011302", 0, 10, g1, testKVs("age", 3))
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, predicates: []string{"name"}}
+	require.NoError(t, runRestore(o, p))
+
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	expected := testKVs("name", 5)
+	require.Len(t, got, len(expected.Kv))
+	for _, kv := range expected.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+	// The file of group 2 doesn't have the predicate, so it isn't restored at all.
+	_, err = os.Stat(filepath.Join(pdir, "p2"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestRestoreGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10,
+		testKVs("name", 5), testKVs("age", 3), testKVs("friend", 2))
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, groups: []uint{2}}
+	require.NoError(t, runRestore(o, p))
+
+	dirs, err := ioutil.ReadDir(pdir)
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	require.Equal(t, "p2", dirs[0].Name())
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p2")), 3)
+
+	o.groups = []uint{4}
+	o.force = true
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No backups of group 4")
+}
+
+func TestRestorePredicatePrefixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	g1 := testKVs("acme.name", 5)
+	g1.Kv = append(g1.Kv, testKVs("globex.name", 2).Kv...)
+	writeBackup(t, bdir, "20181106.011302", 0, 10, g1, testKVs("globex.age", 3))
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, prefixes: []string{"acme."}}
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 5)
+	for _, kv := range testKVs("acme.name", 5).Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+	_, err = os.Stat(filepath.Join(pdir, "p2"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestPredicateSet(t *testing.T) {
+	require.Nil(t, newPredicateSet(nil, nil))
+	var all *predicateSet
+	require.True(t, all.has("name"))
+
+	preds := newPredicateSet([]string{"name"}, []string{"acme.", "globex."})
+	require.True(t, preds.has("name"))
+	require.True(t, preds.has("acme.age"))
+	require.True(t, preds.has("globex.name"))
+	require.False(t, preds.has("age"))
+	require.True(t, preds.hasAny([]string{"age", "acme.age"}))
+	require.False(t, preds.hasAny([]string{"age", "friend"}))
+}
+
+func TestRestoreRebalance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	g1 := testKVs("name", 5)
+	g1.Kv = append(g1.Kv, testKVs("friend", 2).Kv...)
+	writeBackup(t, bdir, "20181106.011302", 0, 10, g1, testKVs("age", 3), testKVs("email", 4))
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 3, rebalance: 2}
+	require.NoError(t, runRestore(o, p))
+
+	// Group 1 keeps its predicates, the ones of group 3 move to group 2.
+	p1 := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, p1, 7)
+	p2 := readKVs(t, filepath.Join(pdir, "p2"))
+	require.Len(t, p2, 7)
+	for _, kv := range testKVs("email", 4).Kv {
+		require.Equal(t, kv, p2[string(kv.Key)])
+	}
+	_, err = os.Stat(filepath.Join(pdir, "p3"))
+	require.True(t, os.IsNotExist(err))
+
+	f, err := os.Open(filepath.Join(pdir, zeroStateName))
+	require.NoError(t, err)
+	defer f.Close()
+	var state pb.MembershipState
+	require.NoError(t, jsonpb.Unmarshal(f, &state))
+	require.Len(t, state.Groups, 2)
+	require.Len(t, state.Groups[1].Tablets, 2)
+	require.Equal(t, uint32(1), state.Groups[1].Tablets["friend"].GroupId)
+	require.Len(t, state.Groups[2].Tablets, 2)
+	require.Equal(t, uint32(2), state.Groups[2].Tablets["email"].GroupId)
+	require.Equal(t, uint64(5), state.MaxLeaseId)
+	require.Equal(t, uint64(5), state.MaxTxnTs)
+}
+
+func TestRestoreResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(n int64) { checkpointKeys = n }(checkpointKeys)
+	checkpointKeys = 2
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+
+	// A restore died after restoring group 2, and the first 3 keys of group 1.
+	pdir := filepath.Join(dir, "postings")
+	require.NoError(t, os.Mkdir(pdir, 0700))
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, zeroState: true, resume: true}
+	cps, err := openCheckpoints(o)
+	require.NoError(t, err)
+	require.NoError(t, cps.save("dgraph.20181106.011302/r10-g2.backup", 0, 0, true,
+		&fileState{Groups: map[string]uint32{"age": 2}, MaxUid: 3, MaxTxnTs: 3}))
+	require.NoError(t, cps.save("dgraph.20181106.011302/r10-g1.backup", 3, 0, false,
+		&fileState{Groups: map[string]uint32{"name": 1}, MaxUid: 3, MaxTxnTs: 3}))
+
+	// It can't be resumed with other settings.
+	o.restoreTs = 5
+	_, err = openCheckpoints(o)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can't be resumed")
+	o.restoreTs = 0
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 2)
+	for _, kv := range testKVs("name", 5).Kv[3:] {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+	_, err = os.Stat(filepath.Join(pdir, "p2"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(pdir, checkpointName))
+	require.True(t, os.IsNotExist(err))
+
+	// The Zero state includes the keys restored before.
+	f, err := os.Open(filepath.Join(pdir, zeroStateName))
+	require.NoError(t, err)
+	defer f.Close()
+	var state pb.MembershipState
+	require.NoError(t, jsonpb.Unmarshal(f, &state))
+	require.Equal(t, uint32(2), state.Groups[2].Tablets["age"].GroupId)
+	require.Equal(t, uint64(5), state.MaxLeaseId)
+}
+
+func TestRestoreResumeSeek(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(n int64) { checkpointKeys = n }(checkpointKeys)
+	checkpointKeys = 10
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	kvs := testKVs("name", 100)
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+	name := filepath.Join(bdir, "dgraph.20181106.011302", "r10-g1.backup")
+	orig, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+
+	// The file is changed below, so its checksum can't be checked.
+	mb, err := ioutil.ReadFile(filepath.Join(bdir, "dgraph.20181106.011302", manifestName))
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(mb, &m))
+	m.Checksums = nil
+	require.NoError(t, WriteManifest(bdir, "20181106.011302", &m))
+
+	for _, mode := range []string{localReadBuffered, localReadMmap} {
+		// The restore dies at the truncated frame of the 56th KV, after its checkpoint at 49.
+		require.NoError(t, ioutil.WriteFile(name, orig[:len(orig)*55/100], 0600))
+		pdir := filepath.Join(dir, mode)
+		o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, resume: true,
+			localRead: mode}
+		p, err := newProgress("text", ioutil.Discard)
+		require.NoError(t, err)
+		p.msgs = ioutil.Discard
+		require.Error(t, runRestore(o, p))
+		cps, err := openCheckpoints(o)
+		require.NoError(t, err)
+		cp := cps.get("dgraph.20181106.011302/r10-g1.backup")
+		require.Equal(t, int64(49), cp.Keys)
+		require.True(t, cp.Offset > 0)
+
+		// The frames committed are corrupted, so they fail the restore if they're read again.
+		b := append([]byte{}, orig...)
+		for i := headerSize; i < headerSize+int(cp.Offset); i++ {
+			b[i] ^= 0xff
+		}
+		require.NoError(t, ioutil.WriteFile(name, b, 0600))
+		var buf bytes.Buffer
+		p, err = newProgress("json", &buf)
+		require.NoError(t, err)
+		p.msgs = ioutil.Discard
+		require.NoError(t, runRestore(o, p))
+
+		// The keys after the checkpoint are loaded once, and none is missing.
+		var last progressRecord
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			require.NoError(t, json.Unmarshal([]byte(line), &last))
+		}
+		require.True(t, last.Done)
+		require.Equal(t, int64(51), last.Keys)
+		got := readKVs(t, filepath.Join(pdir, "p1"))
+		require.Len(t, got, len(kvs.Kv))
+		for _, kv := range kvs.Kv {
+			require.Equal(t, kv, got[string(kv.Key)])
+		}
+	}
+}
+
+func TestAssignPredicates(t *testing.T) {
+	chain := []*Manifest{
+		{Predicates: map[uint32][]string{1: {"a", "b", "c"}, 2: {"d"}}},
+		// An incremental backup after d moved to group 1.
+		{Predicates: map[uint32][]string{1: {"d"}}},
+	}
+	groups, err := assignPredicates(chain, 4, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{"a": 1, "b": 2, "c": 3, "d": 4}, groups)
+
+	groups, err = assignPredicates(chain, 1, newPredicateSet([]string{"a", "d"}, nil))
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{"a": 1, "d": 1}, groups)
+
+	chain[1].Predicates = nil
+	_, err = assignPredicates(chain, 2, nil)
+	require.Error(t, err)
+}
+
+func TestRestoreEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackupOpts(t, bdir, "20181106.011302", 0, 10, backupOpts{key: key}, testKVs("name", 5))
+
+	// The data isn't readable in the backup file.
+	b, err := ioutil.ReadFile(filepath.Join(bdir, "dgraph.20181106.011302", "r10-g1.backup"))
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(b, []byte("val-1")))
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1}
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--encryption_key_file")
+
+	o.key = []byte("fedcba9876543210fedcba9876543210")
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wrong key")
+
+	// The failed restore created the directory of the group.
+	o.key = key
+	o.force = true
+	require.NoError(t, runRestore(o, p))
+	expected := testKVs("name", 5)
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, len(expected.Kv))
+	for _, kv := range expected.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func TestRestoreEncryptionMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "plain")
+	require.NoError(t, os.Mkdir(plain, 0700))
+	writeBackup(t, plain, "20181106.011302", 0, 10, testKVs("name", 5))
+	encrypted := filepath.Join(dir, "encrypted")
+	require.NoError(t, os.Mkdir(encrypted, 0700))
+	writeBackupOpts(t, encrypted, "20181106.011302", 0, 10, backupOpts{key: testKey},
+		testKVs("name", 5))
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	pdir := filepath.Join(dir, "postings")
+	for _, dryRun := range []bool{false, true} {
+		o := &restoreOptions{location: encrypted, pdir: pdir, dryRun: dryRun}
+		err = runRestore(o, p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `Backup "dgraph.20181106.011302" is encrypted, its key `+
+			"must be given with --encryption_key_file.")
+
+		o = &restoreOptions{location: plain, pdir: pdir, dryRun: dryRun, key: testKey}
+		err = runRestore(o, p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "The backups aren't encrypted, but a key was given "+
+			"with --encryption_key_file.")
+	}
+	// Nothing was written.
+	_, err = os.Stat(pdir)
+	require.True(t, os.IsNotExist(err))
+	err = runVerify(&verifyOptions{location: plain, key: testKey}, ioutil.Discard)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "aren't encrypted")
+
+	// The backups of a chain can be encrypted from some point on.
+	writeBackupOpts(t, plain, "20181106.021302", 10, 20, backupOpts{key: testKey},
+		testKVs("age", 3))
+	require.NoError(t, runRestore(&restoreOptions{location: plain, pdir: pdir, key: testKey}, p))
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p1")), 8)
+}
+
+func TestRestoreCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	// Values that compress well, so the size of the files shows if they were compressed.
+	kvs := &pb.KVS{}
+	for i := 1; i <= 100; i++ {
+		kvs.Kv = append(kvs.Kv, &pb.KV{
+			Key:      x.DataKey("name", uint64(i)),
+			Val:      bytes.Repeat([]byte("a"), 1000),
+			UserMeta: []byte{1},
+			Version:  uint64(i),
+		})
+	}
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+	writeBackupOpts(t, bdir, "20181106.011303", 10, 20, backupOpts{compression: "gzip"},
+		testKVs("age", 3))
+	key := []byte("0123456789abcdef")
+	writeBackupOpts(t, bdir, "20181106.011304", 20, 30,
+		backupOpts{key: key, compression: "gzip"}, kvs)
+
+	plain, err := os.Stat(filepath.Join(bdir, "dgraph.20181106.011302", "r10-g1.backup"))
+	require.NoError(t, err)
+	packed, err := os.Stat(filepath.Join(bdir, "dgraph.20181106.011304", "r30-g1.backup"))
+	require.NoError(t, err)
+	require.True(t, packed.Size()*10 < plain.Size(), "%d vs %d", packed.Size(), plain.Size())
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	o := &restoreOptions{location: bdir, pdir: pdir, workers: 1, key: key}
+	require.NoError(t, runRestore(o, p))
+	got := readKVs(t, filepath.Join(pdir, "p1"))
+	require.Len(t, got, 103)
+	for _, kv := range append(kvs.Kv, testKVs("age", 3).Kv...) {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+
+	req := &Request{Backup: &pb.BackupRequest{
+		ReadTs: 40, GroupId: 1, UnixTs: "20181106.011305", Target: bdir, Compression: "lz4"}}
+	_, err = req.newWriter()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Unsupported compression")
+}
+
+func TestRestoreStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackupOpts(t, bdir, "20181106.011302", 0, 10,
+		backupOpts{key: testKey, compression: "gzip"}, testKVs("name", 5), testKVs("age", 3))
+	b, err := ioutil.ReadFile(filepath.Join(bdir, "dgraph.20181106.011302", "r10-g2.backup"))
+	require.NoError(t, err)
+	defer func() { stdin = os.Stdin }()
+
+	pdir := filepath.Join(dir, "postings")
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	// The compression is told from the first bytes of the file.
+	o := &restoreOptions{location: stdinLocation, pdir: pdir, groups: []uint{2}, key: testKey}
+	stdin = bytes.NewReader(b)
+	require.NoError(t, runRestore(o, p))
+	expected := testKVs("age", 3)
+	got := readKVs(t, filepath.Join(pdir, "p2"))
+	require.Len(t, got, len(expected.Kv))
+	for _, kv := range expected.Kv {
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+	_, err = os.Stat(filepath.Join(pdir, "p1"))
+	require.True(t, os.IsNotExist(err))
+
+	// The stream belongs to a single group.
+	o.groups = []uint{1, 2}
+	stdin = bytes.NewReader(b)
+	require.Error(t, runRestore(o, p))
+
+	// A file that isn't compressed is read as it is.
+	writeBackupOpts(t, bdir, "20181106.011303", 10, 20, backupOpts{}, testKVs("name", 2))
+	b, err = ioutil.ReadFile(filepath.Join(bdir, "dgraph.20181106.011303", "r20-g1.backup"))
+	require.NoError(t, err)
+	o = &restoreOptions{location: stdinLocation, pdir: filepath.Join(dir, "plain")}
+	stdin = bytes.NewReader(b)
+	require.NoError(t, runRestore(o, p))
+	require.Len(t, readKVs(t, filepath.Join(dir, "plain", "p1")), 2)
+}
+
+func TestRestoreGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	su := pb.SchemaUpdate{Predicate: "age", ValueType: pb.Posting_INT}
+	val, err := su.Marshal()
+	require.NoError(t, err)
+	schemaKV := &pb.KV{
+		Key:      x.SchemaKey("age"),
+		Val:      val,
+		UserMeta: []byte{posting.BitSchemaPosting},
+		Version:  1,
+	}
+	ages := testKVs("age", 3)
+	ages.Kv = append(ages.Kv, schemaKV)
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), ages)
+	update := &pb.KV{
+		Key:      x.DataKey("age", 1),
+		Val:      []byte("updated"),
+		UserMeta: []byte{1},
+		Version:  15,
+	}
+	writeBackup(t, bdir, "20181106.021302", 10, 20, &pb.KVS{}, &pb.KVS{Kv: []*pb.KV{update}})
+
+	pdir := filepath.Join(dir, "p")
+	bo := badger.DefaultOptions
+	bo.Dir = pdir
+	bo.ValueDir = pdir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	keys, bytesRead := x.RestoreKeys.Value(), x.RestoreBytes.Value()
+	failures := x.RestoreFailures.Value()
+	req := &pb.RestoreRequest{GroupId: 2, Location: bdir, CommitTs: 100}
+	require.NoError(t, RestoreGroup(db, req, nil, nil))
+	require.Equal(t, keys+5, x.RestoreKeys.Value())
+	require.True(t, x.RestoreBytes.Value() > bytesRead)
+	require.True(t, x.RestoreLastSuccess.Value() > 0)
+
+	req.GroupId = 3
+	err = RestoreGroup(db, req, nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "No backups of group 3 found")
+	require.Equal(t, failures+1, x.RestoreFailures.Value())
+	require.NoError(t, db.Close())
+
+	// Only the data of group 2 is loaded, all of it at the commit ts except for the schema.
+	got := readKVs(t, pdir)
+	require.Len(t, got, 4)
+	require.Equal(t, schemaKV, got[string(schemaKV.Key)])
+	for _, kv := range testKVs("age", 3).Kv {
+		if bytes.Equal(kv.Key, update.Key) {
+			kv.Val = update.Val
+		}
+		kv.Version = 100
+		require.Equal(t, kv, got[string(kv.Key)])
+	}
+}
+
+func TestRestoreGroupSince(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5))
+	update := &pb.KV{
+		Key:      x.DataKey("name", 2),
+		Val:      []byte("updated"),
+		UserMeta: []byte{1},
+		Version:  15,
+	}
+	writeBackup(t, bdir, "20181106.021302", 10, 20, &pb.KVS{Kv: []*pb.KV{update}})
+
+	pdir := filepath.Join(dir, "p")
+	bo := badger.DefaultOptions
+	bo.Dir = pdir
+	bo.ValueDir = pdir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	// Only the backup taken after the ts the group was replicated at is loaded.
+	req := &pb.RestoreRequest{GroupId: 1, Location: bdir, RestoreTs: 20, CommitTs: 100,
+		SinceTs: 10, Replicate: true}
+	require.NoError(t, RestoreGroup(db, req, nil, nil))
+	// There's nothing to load after the last backup.
+	req.SinceTs, req.CommitTs = 20, 110
+	require.NoError(t, RestoreGroup(db, req, nil, nil))
+	require.NoError(t, db.Close())
+
+	got := readKVs(t, pdir)
+	require.Len(t, got, 2)
+	require.Equal(t, update.Val, got[string(update.Key)].Val)
+	require.Equal(t, uint64(100), got[string(update.Key)].Version)
+	replicated := got[string(x.ReplicatedKey())]
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 20}, replicated.Val)
+	require.Equal(t, uint64(110), replicated.Version)
+}
+
+func TestRestoreGroupDrop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	names := testKVs("name", 3)
+	writeBackup(t, bdir, "20181106.011302", 0, 10, &pb.KVS{Kv: append(names.Kv,
+		testKVs("age", 2).Kv...)})
+	// The name predicate is dropped, then set again for one of the UIDs.
+	update := &pb.KV{
+		Key:      x.DataKey("name", 2),
+		Val:      []byte("updated"),
+		UserMeta: []byte{1},
+		Version:  17,
+	}
+	writeBackup(t, bdir, "20181106.021302", 10, 20, &pb.KVS{Kv: []*pb.KV{
+		{Key: x.DropKey("name"), Version: 15}, update}})
+
+	pdir := filepath.Join(dir, "p")
+	bo := badger.DefaultOptions
+	bo.Dir = pdir
+	bo.ValueDir = pdir
+	db, err := badger.OpenManaged(bo)
+	require.NoError(t, err)
+	// Both backups are loaded at the commit ts, the drop deletes the data of the full backup
+	// at the same version.
+	req := &pb.RestoreRequest{GroupId: 1, Location: bdir, CommitTs: 100}
+	require.NoError(t, RestoreGroup(db, req, nil, nil))
+	require.NoError(t, db.Close())
+
+	got := readKVs(t, pdir)
+	require.Len(t, got, 3)
+	require.Equal(t, update.Val, got[string(update.Key)].Val)
+	for _, kv := range testKVs("age", 2).Kv {
+		require.Contains(t, got, string(kv.Key))
+	}
+}
@@ -0,0 +1,191 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// checkpointName is the name of the file the checkpoints of a restore are saved to, in pdir.
+const checkpointName = "restore_progress.json"
+
+// checkpointKeys is the number of KVs read from a backup file between its checkpoints.
+var checkpointKeys int64 = 1 << 18
+
+// fileCheckpoint is the progress of the restore of a backup file.
+type fileCheckpoint struct {
+	Keys   int64     `json:"keys"`             // KVs read from the file and committed
+	Offset int64     `json:"offset,omitempty"` // where their frames end, see resumePoint
+	Done   bool      `json:"done"`             // whether all the KVs of the file were committed
+	State  fileState `json:"state"`            // Zero state of the KVs committed
+}
+
+// checkpoints is the progress of a restore into a postings directory. It's saved as the
+// restore goes, so a restore that dies can be resumed where it stopped with --resume. The
+// settings that change what's restored are saved too, a restore can only be resumed with the
+// same ones. It's safe for concurrent use.
+type checkpoints struct {
+	sync.Mutex
+	path string
+
+	Settings string                     `json:"settings"`
+	Files    map[string]*fileCheckpoint `json:"files"`
+}
+
+// settings returns the settings of o that change what's restored into pdir.
+func (o *restoreOptions) settings() string {
+	s := fmt.Sprintf("location=%s restore_ts=%d predicates=%v groups=%v rebalance=%d",
+		o.location, o.restoreTs, o.predicates, o.groups, o.rebalance)
+	if len(o.prefixes) > 0 {
+		s += fmt.Sprintf(" predicate_prefixes=%v", o.prefixes)
+	}
+	if o.reindexOnStart {
+		s += " reindex_on_start=true"
+	}
+	if o.dropIndexData {
+		s += " drop_index_data=true"
+	}
+	if o.statsCSV != "" {
+		s += " stats_csv=true"
+	}
+	if len(o.roots) > 1 {
+		s += fmt.Sprintf(" postings=%v", o.roots)
+	}
+	return s
+}
+
+// openCheckpoints returns the checkpoints of the restore of o into o.pdir. With o.resume, the
+// checkpoints saved by a previous restore are read, if there are any.
+func openCheckpoints(o *restoreOptions) (*checkpoints, error) {
+	c := &checkpoints{
+		path:     filepath.Join(o.pdir, checkpointName),
+		Settings: o.settings(),
+		Files:    make(map[string]*fileCheckpoint),
+	}
+	if !o.resume {
+		return c, nil
+	}
+	b, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved checkpoints
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, x.Wrapf(err, "while reading the checkpoints in %q", c.path)
+	}
+	if saved.Settings != c.Settings {
+		return nil, x.Errorf("The restore into %q was started with %s, it can't be resumed "+
+			"with %s", o.pdir, saved.Settings, c.Settings)
+	}
+	if saved.Files != nil {
+		c.Files = saved.Files
+	}
+	return c, nil
+}
+
+// get returns a copy of the checkpoint of the backup file name, empty if it has none.
+func (c *checkpoints) get(name string) fileCheckpoint {
+	c.Lock()
+	defer c.Unlock()
+	fc, ok := c.Files[name]
+	if !ok {
+		return fileCheckpoint{}
+	}
+	return fileCheckpoint{Keys: fc.Keys, Offset: fc.Offset, Done: fc.Done,
+		State: fc.State.copy()}
+}
+
+// save saves the checkpoint of the backup file name: keys KVs of it were committed, up to
+// offset, or all of them if done is set, with the Zero state fs.
+func (c *checkpoints) save(name string, keys, offset int64, done bool, fs *fileState) error {
+	c.Lock()
+	defer c.Unlock()
+	c.Files[name] = &fileCheckpoint{Keys: keys, Offset: offset, Done: done, State: fs.copy()}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	// The file is replaced at once, a restore that dies while saving it keeps the old one.
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// remove removes the saved checkpoints, once the restore is complete.
+func (c *checkpoints) remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// resumePoint is where the restore of a backup file continues from: after its first keys KVs,
+// whose frames end offset bytes after its header. If seeked is set, the reader starts there
+// already, see seekFrames. Otherwise, the KVs are read again and skipped.
+type resumePoint struct {
+	keys   int64
+	offset int64
+	seeked bool
+}
+
+// seekFrames moves the reader of the plain backup file r to offset bytes after its header, if
+// it can seek. The header is read again first for readBackup, so the reader returned gives
+// the header and then continues at offset. It returns false if r can't seek, and must be read
+// from the start.
+func seekFrames(r io.Reader, offset int64) (io.Reader, bool, error) {
+	if mr, ok := r.(*mmapReader); ok {
+		mr.seekFrames(offset)
+		return mr, true, nil
+	}
+	s, ok := r.(io.ReadSeeker)
+	if !ok {
+		return r, false, nil
+	}
+	// The file may not be seekable after all, like a pipe.
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return r, false, nil
+	}
+	var hdr [headerSize]byte
+	n, err := io.ReadFull(s, hdr[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, false, err
+	}
+	h := hdr[:headerLen(hdr[:n])]
+	if _, err := s.Seek(int64(len(h))+offset, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	return io.MultiReader(bytes.NewReader(h), s), true, nil
+}
+
+// headerLen returns the length of the header at the start of the backup file b, zero for a
+// version 1 file.
+func headerLen(b []byte) int {
+	if len(b) >= headerSize && string(b[:4]) == formatMagic {
+		return headerSize
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// parseRoots returns the postings roots of the comma-separated list s, given with --postings.
+// The roots can't be empty or repeated.
+func parseRoots(s string) ([]string, error) {
+	var roots []string
+	seen := make(map[string]bool)
+	for _, root := range strings.Split(s, ",") {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			return nil, x.Errorf("Invalid --postings %q, a directory is empty.", s)
+		}
+		clean := filepath.Clean(root)
+		if seen[clean] {
+			return nil, x.Errorf("Invalid --postings %q, %q is given twice.", s, root)
+		}
+		seen[clean] = true
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// postingsRoots returns the directories the groups are restored into: o.roots, or o.pdir if
+// there's a single one. The first one is always o.pdir, which also has the checkpoints and the
+// Zero state of the restore.
+func (o *restoreOptions) postingsRoots() []string {
+	if len(o.roots) == 0 {
+		return []string{o.pdir}
+	}
+	return o.roots
+}
+
+// groupDir returns the pN directory of the group gid. The groups are assigned to the postings
+// roots round-robin by ID, so a group is restored into the same root when the restore is
+// resumed.
+func (o *restoreOptions) groupDir(gid uint32) string {
+	roots := o.postingsRoots()
+	var i int
+	if gid > 0 {
+		i = int(gid-1) % len(roots)
+	}
+	return filepath.Join(roots[i], fmt.Sprintf("p%d", gid))
+}
@@ -0,0 +1,87 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoots(t *testing.T) {
+	roots, err := parseRoots("/disk1/p, /disk2/p")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/disk1/p", "/disk2/p"}, roots)
+	_, err = parseRoots("/disk1/p,,/disk2/p")
+	require.Error(t, err)
+	_, err = parseRoots("/disk1/p,/disk1/p/")
+	require.Error(t, err)
+}
+
+func TestRestoreRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3),
+		testKVs("email", 4))
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	var msgs bytes.Buffer
+	p.msgs = &msgs
+	roots := []string{filepath.Join(dir, "disk1"), filepath.Join(dir, "disk2")}
+	o := &restoreOptions{location: bdir, pdir: roots[0], roots: roots, zeroState: true}
+	require.NoError(t, runRestore(o, p))
+
+	// The groups are assigned to the roots round-robin, the other root has no pN of them.
+	placed := map[uint32]string{1: roots[0], 2: roots[1], 3: roots[0]}
+	counts := map[uint32]int{1: 5, 2: 3, 3: 4}
+	for gid, root := range placed {
+		group := fmt.Sprintf("p%d", gid)
+		require.Len(t, readKVs(t, filepath.Join(root, group)), counts[gid])
+		other := roots[0]
+		if root == roots[0] {
+			other = roots[1]
+		}
+		_, err := os.Stat(filepath.Join(other, group))
+		require.True(t, os.IsNotExist(err))
+		require.Contains(t, msgs.String(), fmt.Sprintf("Restored group %d into %q\n", gid,
+			filepath.Join(root, group)))
+	}
+	_, err = os.Stat(filepath.Join(roots[0], zeroStateName))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(roots[1], zeroStateName))
+	require.True(t, os.IsNotExist(err))
+
+	// Every root must be empty, unless the restore is resumed.
+	o = &restoreOptions{location: bdir, pdir: filepath.Join(dir, "disk3"),
+		roots: []string{filepath.Join(dir, "disk3"), roots[1]}}
+	require.Error(t, runRestore(o, p))
+
+	// A restore can't be resumed with other roots.
+	o = &restoreOptions{location: bdir, pdir: roots[0], roots: roots, resume: true}
+	cps, err := openCheckpoints(o)
+	require.NoError(t, err)
+	require.NoError(t, cps.save("r1-g1.backup", 1, 0, false, &fileState{}))
+	o.roots = []string{roots[0], filepath.Join(dir, "disk3")}
+	_, err = openCheckpoints(o)
+	require.Error(t, err)
+}
@@ -0,0 +1,555 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+)
+
+var Restore x.SubCommand
+
+var opt restoreOptions
+
+func init() {
+	Restore.Cmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Run Dgraph (EE) Restore backup",
+		Long: `
+Dgraph Restore loads the backups at --location offline: the latest full backup and the
+incremental ones taken after it, into a posting directory under --postings for each group.
+The locations, credentials and the other ways to restore a backup are described in the
+deploy docs, under Restore Backups.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer x.StartProfile(Restore.Conf).Stop()
+			if err := run(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	flag := Restore.Cmd.Flags()
+	flag.StringVarP(&opt.location, "location", "l", "",
+		"Sets the source location URI (required).")
+	flag.StringVarP(&opt.pdir, "postings", "p", "",
+		"Directory where posting lists are stored (required unless --alpha, --dry_run, "+
+			"--export_to or --out_format). A comma-separated list spreads the groups over "+
+			"the directories.")
+	flag.Uint64Var(&opt.restoreTs, "restore_ts", 0,
+		"Restore the data as of the latest backup taken at or before this timestamp. "+
+			"Defaults to the latest backup.")
+	flag.StringSliceVar(&opt.predicates, "predicates", nil,
+		"Comma-separated list of predicates to restore. Defaults to all of them.")
+	flag.StringSliceVar(&opt.prefixes, "predicate_prefix", nil,
+		"Comma-separated list of prefixes of the predicates to restore, e.g. a tenant's.")
+	flag.UintSliceVar(&opt.groups, "groups", nil,
+		"Comma-separated list of group IDs to restore. Defaults to all of them.")
+	flag.StringVar(&opt.keyFile, "encryption_key_file", "",
+		"The file storing the AES key of encrypted backups.")
+	flag.StringVar(&opt.creds.AccessKey, "access_key", "",
+		"Access key for remote locations. Defaults to env var AWS_ACCESS_KEY_ID.")
+	flag.StringVar(&opt.creds.SecretKey, "secret_key", "",
+		"Secret key for remote locations. Defaults to env var AWS_SECRET_ACCESS_KEY.")
+	flag.StringVar(&opt.creds.S3Endpoint, "s3_endpoint", "",
+		"host[:port] of the S3-compatible service to use instead of the host of the location.")
+	flag.BoolVar(&opt.creds.S3PathStyle, "s3_path_style", false,
+		"Address the S3 bucket in the URL path instead of the host name, e.g. for MinIO.")
+	flag.BoolVar(&opt.creds.S3SkipVerify, "s3_insecure_skip_verify", false,
+		"Don't verify the TLS certificate of the S3 service.")
+	flag.StringVar(&opt.creds.Proxy, "proxy", "",
+		"URL of the HTTP proxy of the remote locations. Defaults to env var HTTPS_PROXY "+
+			"or HTTP_PROXY.")
+	flag.BoolVar(&opt.creds.ProxyInsecure, "proxy_insecure", false,
+		"Don't verify the TLS certificates of the remote locations, e.g. behind a proxy "+
+			"that intercepts TLS.")
+	flag.IntVar(&opt.workers, "workers", runtime.NumCPU(),
+		"Number of groups to restore concurrently.")
+	flag.StringVar(&opt.progressFormat, "progress_format", "text",
+		"Format of the progress reports: text or json (one record per line).")
+	flag.StringVar(&opt.progressFile, "progress_file", "",
+		"File the progress reports are appended to. Defaults to stdout.")
+	flag.BoolVar(&opt.progress, "progress", false,
+		"Report the progress every few seconds even if stdout isn't a terminal.")
+	flag.BoolVar(&opt.dryRun, "dry_run", false,
+		"Only read and verify the backups, without writing any postings.")
+	flag.StringVar(&opt.alpha, "alpha", "",
+		"Dgraph alpha gRPC address to send the data to as mutations, instead of --postings.")
+	flag.StringVar(&opt.zero, "zero", "127.0.0.1:5080",
+		"Dgraph zero gRPC address to lease the UIDs from, used with --alpha.")
+	flag.IntVar(&opt.batch, "batch", 1000,
+		"Number of N-Quads to send as part of a mutation, used with --alpha.")
+	flag.Uint32Var(&opt.rebalance, "rebalance", 0,
+		"Number of groups to spread the predicates over. Defaults to the groups of the backup.")
+	flag.BoolVar(&opt.resume, "resume", false,
+		"Resume the interrupted restore into --postings, or export to --out, skipping the "+
+			"data it restored or exported.")
+	flag.BoolVar(&opt.force, "force", false,
+		"Restore into --postings even if it isn't empty or seems short of disk space.")
+	flag.Float64Var(&opt.rateLimit, "rate_limit", 0,
+		"Maximum rate to read the backup files at, in MB/s. Defaults to no limit.")
+	flag.IntVar(&opt.maxInflightFiles, "max_inflight_files", 0,
+		"Number of remote backup files downloaded into --tmp_dir ahead of their load. "+
+			"Defaults to reading them as they're loaded.")
+	flag.StringVar(&opt.tmpDir, "tmp_dir", os.TempDir(),
+		"Directory the backup files of --max_inflight_files are downloaded into.")
+	flag.BoolVar(&opt.zeroState, "zero_state", false,
+		"Write the state of the restored groups for Zero to zero_state.json under --postings.")
+	flag.StringVar(&opt.exportTo, "export_to", "",
+		"Export the data to --out in this format, rdf or json, instead of writing postings.")
+	flag.StringVar(&opt.outFormat, "out_format", "",
+		"List the backups in this format, keys, instead of writing postings.")
+	flag.StringVar(&opt.out, "out", "",
+		"Directory to write the files of --export_to to, or file to write the list of "+
+			"--out_format to. The list defaults to stdout.")
+	flag.StringVar(&opt.compression, "compression", "",
+		"Compression of the backup file read from stdin with --location=-: gzip or none. "+
+			"By default, gzip is told from the first bytes of the file.")
+	flag.StringVar(&opt.localRead, "local_read", localReadBuffered,
+		"[buffered, mmap] How the backup files of a local location are read.")
+	flag.BoolVar(&opt.loadWriter, "load_writer", false,
+		"Write the full backups with Badger's Load instead of transactions.")
+	flag.BoolVar(&opt.clearStaleLock, "clear_stale_lock", false,
+		"Remove the LOCK files left in --postings by restores that aren't running.")
+	flag.StringVar(&opt.statsCSV, "stats_csv", "",
+		"CSV file to write the triples, bytes and subjects of each predicate restored to.")
+	flag.StringVar(&opt.augmentDir, "augment_manifest", "",
+		"Directory to save the checksums of the backup files whose manifest has none to, "+
+			"and to check them against in the next restores.")
+	flag.Uint64Var(&opt.versionSkew, "version_skew", 0,
+		"Warn if the highest version of a restored group is more than this below the one of "+
+			"the others. Defaults to no check.")
+	flag.StringVar(&opt.schemaFile, "schema_file", "",
+		"Schema to apply to the restored predicates, rebuilding their indexes to match it.")
+	flag.BoolVar(&opt.reindex, "reindex", false,
+		"Rebuild the indexes, reverse edges and count indexes of the restored schema.")
+	flag.BoolVar(&opt.reindexOnStart, "reindex_on_start", false,
+		"Skip the index keys, and have the Alphas rebuild them when they first start.")
+	flag.BoolVar(&opt.dropIndexData, "drop_index_data", false,
+		"Skip the index keys, keeping the indexes in the schema, to rebuild them later.")
+	flag.BoolVar(&opt.preserveIndexState, "preserve_index_state", true,
+		"Restore the index keys. The inverse of --drop_index_data.")
+	flag.BoolVar(&opt.abortOnSchemaMissing, "abort_on_schema_missing", false,
+		"Check the backups for data of predicates without a schema before restoring them.")
+	flag.StringVar(&opt.validateSchemaFile, "validate_schema_file", "",
+		"Schema file to compare the schema of the backup with before restoring it.")
+	flag.BoolVar(&opt.strict, "strict", false,
+		"Fail if the schema of the backup differs from the --validate_schema_file.")
+
+	// Options around how to set up the Badger DBs restored into.
+	flag.StringVar(&opt.badgerTables, "badger.tables", "mmap",
+		"[ram, mmap, disk] Specifies how the Badger LSM tree is loaded while restoring. "+
+			"Option sequence consume most to least RAM.")
+	flag.Int64Var(&opt.badgerVlogSize, "badger.vlog_file_size", 1024,
+		"Size of the Badger value log files in MB, from 1 to 2048.")
+	flag.IntVar(&opt.badgerCompactors, "badger.compactors", 3,
+		"Number of Badger compaction workers.")
+	flag.BoolVar(&opt.badgerSyncWrites, "badger.sync_writes", false,
+		"Sync each Badger write to disk. Slower, but a machine crash loses no restored data.")
+	flag.IntVar(&opt.limits.keys, "batch_size", defaultBatchKeys,
+		"Number of keys written to --postings before waiting for them to be committed.")
+	flag.Int64Var(&opt.limits.bytes, "max_pending_bytes", defaultBatchBytes,
+		"Size in bytes of the keys and values written to --postings before waiting for them "+
+			"to be committed.")
+	flag.StringVar(&opt.httpAddr, "http", "",
+		"Address to serve pprof and the Prometheus metrics of the restore on. Defaults to none.")
+	Restore.Cmd.MarkFlagRequired("location")
+
+	var repairDir string
+	repair := &cobra.Command{
+		Use:   "repair",
+		Short: "Salvage the postings of a restore that was cut short by a crash",
+		Long: `
+Repair opens the Badger DBs restored under --postings, the pN directories or a single p
+directory, truncating the partially written entries at the end of their value logs, which a
+crash of the machine during a restore can leave behind. Badger refuses to open them
+otherwise. The data committed before the crash is kept, so a long restore doesn't have to be
+redone: run dgraph restore again with --resume to load the rest.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			roots, err := parseRoots(repairDir)
+			if err == nil {
+				err = runRepair(roots, os.Stdout)
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	repair.Flags().StringVarP(&repairDir, "postings", "p", "",
+		"Directory of the restored posting lists to repair (required). A comma-separated "+
+			"list repairs the groups spread over the directories.")
+	repair.MarkFlagRequired("postings")
+	Restore.Cmd.AddCommand(repair)
+
+	initBackup()
+}
+
+var Backup x.SubCommand
+
+// backupOpt are the settings shared by the backup subcommands.
+var backupOpt struct {
+	location string
+	creds    Credentials
+}
+
+var pruneOpt pruneOptions
+
+var listOpt listOptions
+
+var verifyOpt verifyOptions
+
+func initBackup() {
+	Backup.Cmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Run Dgraph (EE) Backup tools",
+		Long: `
+Dgraph Backup tools manage the backups at a location. The backups are taken by the Alphas,
+through their /admin/backup endpoint. The location is the same target given to the backup
+requests, see Restore Backups in the deploy docs for its formats and credentials.
+`,
+	}
+	flag := Backup.Cmd.PersistentFlags()
+	flag.StringVarP(&backupOpt.location, "location", "l", "",
+		"Sets the location URI of the backups (required).")
+	flag.StringVar(&backupOpt.creds.AccessKey, "access_key", "",
+		"Access key for remote locations. Defaults to env var AWS_ACCESS_KEY_ID.")
+	flag.StringVar(&backupOpt.creds.SecretKey, "secret_key", "",
+		"Secret key for remote locations. Defaults to env var AWS_SECRET_ACCESS_KEY.")
+	flag.StringVar(&backupOpt.creds.S3Endpoint, "s3_endpoint", "",
+		"host[:port] of the S3-compatible service to use instead of the host of the location.")
+	flag.BoolVar(&backupOpt.creds.S3PathStyle, "s3_path_style", false,
+		"Address the S3 bucket in the URL path instead of the host name, e.g. for MinIO.")
+	flag.BoolVar(&backupOpt.creds.S3SkipVerify, "s3_insecure_skip_verify", false,
+		"Don't verify the TLS certificate of the S3 service.")
+	flag.StringVar(&backupOpt.creds.Proxy, "proxy", "",
+		"URL of the HTTP proxy of the remote locations. Defaults to env var HTTPS_PROXY "+
+			"or HTTP_PROXY.")
+	flag.BoolVar(&backupOpt.creds.ProxyInsecure, "proxy_insecure", false,
+		"Don't verify the TLS certificates of the remote locations, e.g. behind a proxy "+
+			"that intercepts TLS.")
+	Backup.Cmd.MarkPersistentFlagRequired("location")
+
+	prune := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete the backups not needed by the retention policy",
+		Long: `
+Prune deletes the backups at the location that the retention policy doesn't keep.
+For each one of the last --keep_daily days with backups, the latest backup of the day is
+kept. The same goes for the last --keep_weekly weeks. The incremental backups kept need the
+backups they were taken since, back to their full backup, so those are always kept too.
+The chain of a kept backup is never broken, it can still be restored.
+
+The days and weeks are the UTC ones of the time each backup was started at. Backups without
+a manifest, incomplete or still running, are never deleted. With --dry_run, the backups that
+would be deleted are only listed.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			pruneOpt.location = backupOpt.location
+			pruneOpt.creds = backupOpt.creds
+			if err := runPrune(&pruneOpt, os.Stdout); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	flag = prune.Flags()
+	flag.IntVar(&pruneOpt.keepDaily, "keep_daily", 0,
+		"Number of days to keep the latest backup of.")
+	flag.IntVar(&pruneOpt.keepWeekly, "keep_weekly", 0,
+		"Number of weeks to keep the latest backup of.")
+	flag.BoolVar(&pruneOpt.dryRun, "dry_run", false,
+		"Only list the backups that would be deleted.")
+	Backup.Cmd.AddCommand(prune)
+
+	ls := &cobra.Command{
+		Use:   "ls",
+		Short: "List the backups at a location",
+		Long: `
+Ls lists the backups at the location, oldest first, with the details recorded in their
+manifests: the directory of the backup, which is named after the time it was started at,
+whether it's a full or an incremental backup, the timestamp it was taken since and at, the
+number of groups, the total size of its files, and how its files are compressed and
+encrypted. Use the READ TS of a backup as the --restore_ts of dgraph restore to restore the
+data as it was then. Backups without a manifest, incomplete or still running, aren't listed.
+
+Locations with many backups can be listed a page at a time: --limit lists that many backups,
+and --start_after lists the backups whose directory sorts after the one given. When there are
+more backups, the command to list the next page is given. Backups are listed from that point
+on without listing the objects before it on S3 and GCS.
+With --json, the list is written as a JSON object, with the backups in "backups" and the
+--start_after of the next page in "next_cursor", empty if it's the last one.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listOpt.location = backupOpt.location
+			listOpt.creds = backupOpt.creds
+			if err := runList(&listOpt, os.Stdout); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	flag = ls.Flags()
+	flag.StringVar(&listOpt.startAfter, "start_after", "",
+		"List the backups whose directory sorts after this one.")
+	flag.IntVar(&listOpt.limit, "limit", 0,
+		"Maximum number of backups to list, 0 to list all of them.")
+	flag.BoolVar(&listOpt.json, "json", false, "Write the list as JSON.")
+	Backup.Cmd.AddCommand(ls)
+
+	verify := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify a sample of each backup at a location",
+		Long: `
+Verify reads the first --frames frames of each backup file at the location, decodes them,
+and checks their checksums, their keys, posting lists and schema, and that their predicates
+are the ones listed in the manifest for the file. Only that part of each file is downloaded,
+so it gives quick confidence in the backups without a full dgraph restore --dry_run.
+With --frames=0, the files are read in full, and are also checked against the checksum and
+the predicates recorded in their manifests.
+
+A table of the files is written with the number of frames verified, whether the file
+checksum was verified or the file only sampled, and the result. The command fails if any
+file fails verification. Encrypted backups need their key, given with --encryption_key_file.
+
+With --verify_parallel, that many files are verified concurrently, to use more cores and
+connections on large backups. The table and the result are the same, in the same order.
+Like with ls, --limit and --start_after verify a page of the backups at a time.
+The backups taken by older Alphas have no checksums in their manifests. With
+--augment_manifest, the checksums saved in that directory by dgraph restore are used instead.
+`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			verifyOpt.location = backupOpt.location
+			verifyOpt.creds = backupOpt.creds
+			if err := runVerify(&verifyOpt, os.Stdout); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		},
+	}
+	flag = verify.Flags()
+	flag.Int64Var(&verifyOpt.frames, "frames", 1000,
+		"Number of frames to verify from each backup file, 0 to verify the whole files.")
+	flag.StringVar(&verifyOpt.keyFile, "encryption_key_file", "",
+		"The file storing the AES key of encrypted backups.")
+	flag.IntVar(&verifyOpt.parallel, "verify_parallel", 1,
+		"Number of backup files verified concurrently.")
+	flag.StringVar(&verifyOpt.startAfter, "start_after", "",
+		"Verify the backups whose directory sorts after this one.")
+	flag.IntVar(&verifyOpt.limit, "limit", 0,
+		"Maximum number of backups to verify, 0 to verify all of them.")
+	flag.StringVar(&verifyOpt.augmentDir, "augment_manifest", "",
+		"Directory with the checksums saved by dgraph restore --augment_manifest, to check "+
+			"the backup files whose manifest has none.")
+	Backup.Cmd.AddCommand(verify)
+}
+
+func run() error {
+	if opt.pdir == "" && opt.alpha == "" && !opt.dryRun && opt.exportTo == "" &&
+		opt.outFormat == "" {
+		return x.Errorf("The --postings directory is required unless --alpha, --dry_run, " +
+			"--export_to or --out_format is set.")
+	}
+	if opt.pdir != "" {
+		roots, err := parseRoots(opt.pdir)
+		if err != nil {
+			return err
+		}
+		opt.pdir = roots[0]
+		if len(roots) > 1 {
+			opt.roots = roots
+		}
+	}
+	if opt.outFormat != "" {
+		if opt.outFormat != keysFormat {
+			return x.Errorf("Invalid --out_format %q. The valid value is keys.", opt.outFormat)
+		}
+		if opt.alpha != "" || opt.dryRun || opt.exportTo != "" || opt.resume ||
+			opt.rebalance > 0 {
+			return x.Errorf("--out_format can't be used with --alpha, --dry_run, " +
+				"--export_to, --resume or --rebalance.")
+		}
+	}
+	if opt.augmentDir != "" && (opt.location == stdinLocation || opt.outFormat != "") {
+		return x.Errorf("--augment_manifest can't be used with --out_format or a backup read " +
+			"from stdin.")
+	}
+	if opt.exportTo != "" {
+		if opt.out == "" {
+			return x.Errorf("The --out directory is required with --export_to.")
+		}
+		if opt.alpha != "" || opt.dryRun || opt.rebalance > 0 || opt.zeroState {
+			return x.Errorf("--export_to can't be used with --alpha, --dry_run, " +
+				"--rebalance or --zero_state.")
+		}
+	}
+	if opt.resume && (opt.alpha != "" || opt.dryRun) {
+		return x.Errorf("--resume can't be used with --alpha or --dry_run.")
+	}
+	if opt.rebalance > 0 && (opt.alpha != "" || len(opt.groups) > 0) {
+		return x.Errorf("--rebalance can't be used with --alpha or --groups.")
+	}
+	if opt.location == stdinLocation && (opt.alpha != "" || opt.rebalance > 0 ||
+		opt.restoreTs > 0) {
+		return x.Errorf("--alpha, --rebalance and --restore_ts can't be used with " +
+			"--location=-.")
+	}
+	if (opt.schemaFile != "" || opt.reindex) && (opt.dryRun || opt.exportTo != "") {
+		return x.Errorf("--schema_file and --reindex can't be used with --dry_run or " +
+			"--export_to.")
+	}
+	if opt.location == stdinLocation && (opt.abortOnSchemaMissing ||
+		opt.validateSchemaFile != "") {
+		return x.Errorf("--abort_on_schema_missing and --validate_schema_file can't be used " +
+			"with --location=-, the backup read from stdin can't be read twice.")
+	}
+	if opt.strict && opt.validateSchemaFile == "" {
+		return x.Errorf("--strict needs the --validate_schema_file to compare with.")
+	}
+	if opt.reindexOnStart && (opt.alpha != "" || opt.dryRun || opt.exportTo != "") {
+		return x.Errorf("--reindex_on_start can't be used with --alpha, --dry_run or " +
+			"--export_to.")
+	}
+	if opt.dropIndexData && opt.preserveIndexState &&
+		Restore.Cmd.Flags().Changed("preserve_index_state") {
+		return x.Errorf("--drop_index_data and --preserve_index_state can't both be set.")
+	}
+	opt.dropIndexData = opt.dropIndexData || !opt.preserveIndexState
+	if opt.dropIndexData && (opt.alpha != "" || opt.dryRun) {
+		return x.Errorf("--drop_index_data can't be used with --alpha or --dry_run.")
+	}
+	if opt.statsCSV != "" && (opt.alpha != "" || opt.dryRun) {
+		return x.Errorf("--stats_csv can't be used with --alpha or --dry_run.")
+	}
+	if opt.loadWriter && (opt.alpha != "" || opt.dryRun || opt.rebalance > 0) {
+		return x.Errorf("--load_writer can't be used with --alpha, --dry_run or --rebalance.")
+	}
+
+	switch opt.localRead {
+	case localReadBuffered, localReadMmap:
+	default:
+		return x.Errorf("Invalid --local_read %q. Valid values are buffered and mmap.",
+			opt.localRead)
+	}
+
+	if opt.keyFile != "" {
+		key, err := ReadKeyFile(opt.keyFile)
+		if err != nil {
+			return err
+		}
+		opt.key = key
+	}
+
+	if opt.httpAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(opt.httpAddr, nil); err != nil {
+				glog.Errorf("Restore: unable to serve the metrics on %q: %s", opt.httpAddr, err)
+			}
+		}()
+	}
+
+	out := os.Stdout
+	if opt.outFormat != "" && opt.out == "" {
+		// The list is written to stdout.
+		out = os.Stderr
+	}
+	if opt.progressFile != "" {
+		f, err := os.OpenFile(opt.progressFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	p, err := newProgress(opt.progressFormat, out)
+	if err != nil {
+		return err
+	}
+	// The reports are written to the file asked for even if nobody watches it.
+	p.tick = p.tick || opt.progress || opt.progressFile != ""
+
+	p.printf("Restoring backups from: %s\n", opt.location)
+	if opt.dryRun {
+		p.printf("Dry run: verifying backups only\n")
+	} else if opt.alpha != "" {
+		p.printf("Sending data to: %s\n", opt.alpha)
+	} else if opt.exportTo != "" {
+		p.printf("Exporting %s to: %s\n", opt.exportTo, opt.out)
+	} else if opt.outFormat != "" {
+		dest := opt.out
+		if dest == "" {
+			dest = "stdout"
+		}
+		p.printf("Listing the %s to: %s\n", opt.outFormat, dest)
+	} else {
+		p.printf("Writing postings to: %s\n", strings.Join(opt.postingsRoots(), ", "))
+	}
+	if opt.restoreTs > 0 {
+		p.printf("Restoring up to ts: %d\n", opt.restoreTs)
+	}
+	if len(opt.predicates) > 0 {
+		p.printf("Restoring predicates: %s\n", strings.Join(opt.predicates, ", "))
+	}
+	if len(opt.prefixes) > 0 {
+		p.printf("Restoring predicates with prefixes: %s\n", strings.Join(opt.prefixes, ", "))
+	}
+	if len(opt.groups) > 0 {
+		p.printf("Restoring groups: %v\n", opt.groups)
+	}
+	if opt.rebalance > 0 {
+		p.printf("Rebalancing predicates into groups: %d\n", opt.rebalance)
+	}
+	if opt.rateLimit > 0 {
+		p.printf("Limiting the read rate to: %g MB/s\n", opt.rateLimit)
+	}
+	if opt.schemaFile != "" {
+		p.printf("Applying the schema in: %s\n", opt.schemaFile)
+	}
+
+	start := time.Now()
+	restore := runRestore
+	switch {
+	case opt.exportTo != "":
+		restore = runExport
+	case opt.outFormat != "":
+		restore = runKeys
+	}
+	err = restore(&opt, p)
+	if !opt.dryRun && opt.exportTo == "" && opt.outFormat == "" {
+		recordRestore(start, err)
+	}
+	if err != nil {
+		return err
+	}
+	if opt.dryRun {
+		p.printf("Dry run: backups at %q can be restored\n", opt.location)
+	}
+	p.printf("Restore: Time elapsed: %s\n", time.Since(start).Round(time.Second))
+	return nil
+}
@@ -13,20 +13,20 @@
 package backup
 
 import (
-	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/dgraph-io/dgraph/x"
 
-	humanize "github.com/dustin/go-humanize"
-
 	"github.com/golang/glog"
 	minio "github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
 )
 
 const (
@@ -36,51 +36,79 @@ const (
 
 // s3Handler is used for 's3:' URI scheme.
 type s3Handler struct {
+	mc      *minio.Client
 	bucket  string
+	prefix  string
 	object  string
+	creds   *Credentials
 	pwriter *io.PipeWriter
 	preader *io.PipeReader
 	cerr    chan error
 }
 
-// Open creates an AWS session and sends our data stream to an S3 blob.
+// setup creates an AWS session, checks the bucket exists and returns a client to it.
+// The client is reused by later calls.
 // URI formats:
 //   s3://<s3 region endpoint>/bucket/folder1.../folderN?secure=true|false
 //   s3:///bucket/folder1.../folderN?secure=true|false (use default S3 endpoint)
-func (h *s3Handler) Open(uri *url.URL, req *Request) error {
-	accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
-	secretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+// S3-compatible stores are reached through their own endpoint, e.g. s3://minio:9000/bucket.
+// With path_style=true, the bucket is addressed in the path instead of the host name, and
+// with insecure_skip_verify=true, the TLS certificate of the endpoint isn't verified.
+func (h *s3Handler) setup(uri *url.URL) (*minio.Client, error) {
+	if h.mc != nil {
+		return h.mc, nil
+	}
+
+	accessKeyID, secretAccessKey := h.credentials()
 	if accessKeyID == "" || secretAccessKey == "" {
-		return x.Errorf("Env vars AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY not set.")
+		return nil, x.Errorf("Env vars AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY not set.")
 	}
 
 	glog.V(2).Infof("S3Handler got uri: %+v. Host: %s. Path: %s\n", uri, uri.Host, uri.Path)
+	query := uri.Query()
+	pathStyle := query.Get("path_style") == "true"
+	skipVerify := query.Get("insecure_skip_verify") == "true"
+	if h.creds != nil {
+		if h.creds.S3Endpoint != "" {
+			uri.Host = h.creds.S3Endpoint
+		}
+		pathStyle = pathStyle || h.creds.S3PathStyle
+		skipVerify = skipVerify || h.creds.S3SkipVerify
+	}
 	// s3:///bucket/folder
-	if !strings.Contains(uri.Host, ".") {
+	if uri.Host == "" {
 		uri.Host = s3DefaultEndpoint
 	}
 	glog.V(2).Infof("Backup using S3 host: %s, path: %s", uri.Host, uri.Path)
 
 	if len(uri.Path) < 1 {
-		return x.Errorf("The S3 bucket %q is invalid", uri.Path)
+		return nil, x.Errorf("The S3 bucket %q is invalid", uri.Path)
 	}
 
 	// split path into bucket and blob
 	parts := strings.Split(uri.Path[1:], "/")
 	h.bucket = parts[0] // bucket
-	// The location is: /bucket/folder1...folderN/dgraph.20181106.0113/r110001-g1.backup
-	parts = append(parts, fmt.Sprintf("dgraph.%s", req.Backup.UnixTs))
-	parts = append(parts, fmt.Sprintf("r%d.g%d.backup", req.Backup.ReadTs, req.Backup.GroupId))
-	h.object = filepath.Join(parts[1:]...)
-	glog.V(2).Infof("Sending data to S3 blob %q ...", h.object)
+	h.prefix = path.Join(parts[1:]...)
 
 	// secure by default
-	secure := uri.Query().Get("secure") != "false"
+	secure := query.Get("secure") != "false"
 
-	mc, err := minio.New(uri.Host, accessKeyID, secretAccessKey, secure)
+	opts := &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure: secure,
+	}
+	if pathStyle {
+		opts.BucketLookup = minio.BucketLookupPath
+	}
+	mc, err := minio.NewWithOptions(uri.Host, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	tr, err := newTransport(minio.DefaultTransport.(*http.Transport), h.creds, skipVerify)
+	if err != nil {
+		return nil, err
+	}
+	mc.SetCustomTransport(tr)
 	// S3 transfer acceleration support.
 	if strings.Contains(uri.Host, s3AccelerateHost) {
 		mc.SetS3TransferAccelerate(uri.Host)
@@ -89,26 +117,145 @@ func (h *s3Handler) Open(uri *url.URL, req *Request) error {
 
 	found, err := mc.BucketExists(h.bucket)
 	if err != nil {
-		return x.Errorf("Error while looking for bucket: %s at host: %s. Error: %v",
+		return nil, x.Errorf("Error while looking for bucket: %s at host: %s. Error: %v",
 			h.bucket, uri.Host, err)
 	}
 	if !found {
-		return x.Errorf("S3 bucket %s not found.", h.bucket)
+		return nil, x.Errorf("S3 bucket %s not found.", h.bucket)
+	}
+
+	h.mc = mc
+	return mc, nil
+}
+
+// credentials returns the access keys given to the handler, falling back to the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env vars for any unset key.
+func (h *s3Handler) credentials() (string, string) {
+	accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretAccessKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if h.creds != nil {
+		if h.creds.AccessKey != "" {
+			accessKeyID = h.creds.AccessKey
+		}
+		if h.creds.SecretKey != "" {
+			secretAccessKey = h.creds.SecretKey
+		}
+	}
+	return accessKeyID, secretAccessKey
+}
+
+// Create sends our data stream to the S3 blob at path, relative to the location.
+func (h *s3Handler) Create(uri *url.URL, p string) error {
+	mc, err := h.setup(uri)
+	if err != nil {
+		return err
 	}
 
+	h.object = path.Join(h.prefix, p)
+	glog.V(2).Infof("Sending data to S3 blob %q ...", h.object)
+
+	h.preader, h.pwriter = io.Pipe()
 	h.cerr = make(chan error, 1)
 	go func() {
 		h.cerr <- h.upload(mc)
 	}()
-
-	glog.Infof("Uploading data, estimated size %s", humanize.Bytes(req.Sizex))
 	return nil
 }
 
+// List returns the relative paths of the objects under the location ending in suffix.
+func (h *s3Handler) List(uri *url.URL, suffix string) ([]string, error) {
+	mc, err := h.setup(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix string
+	if h.prefix != "" {
+		prefix = h.prefix + "/"
+	}
+	var objects []string
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	for object := range mc.ListObjectsV2(h.bucket, prefix, true, doneCh) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		if strings.HasSuffix(object.Key, suffix) {
+			objects = append(objects, strings.TrimPrefix(object.Key, prefix))
+		}
+	}
+	sort.Strings(objects)
+	return objects, nil
+}
+
+// ListAfter returns up to limit relative paths of the objects under the location ending in
+// suffix that sort after startAfter. The listing starts after it, so the objects before it
+// aren't listed at all.
+func (h *s3Handler) ListAfter(uri *url.URL, suffix, startAfter string, limit int) (
+	[]string, error) {
+	mc, err := h.setup(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix string
+	if h.prefix != "" {
+		prefix = h.prefix + "/"
+	}
+	core := minio.Core{Client: mc}
+	var objects []string
+	var token string
+	for {
+		res, err := core.ListObjectsV2(h.bucket, prefix, token, false, "", 1000,
+			prefix+startAfter)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range res.Contents {
+			if strings.HasSuffix(object.Key, suffix) {
+				objects = append(objects, strings.TrimPrefix(object.Key, prefix))
+				if len(objects) == limit {
+					return objects, nil
+				}
+			}
+		}
+		if !res.IsTruncated {
+			return objects, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// Read returns a reader for the S3 blob at path, relative to the location.
+func (h *s3Handler) Read(uri *url.URL, p string) (io.ReadCloser, int64, error) {
+	mc, err := h.setup(uri)
+	if err != nil {
+		return nil, 0, err
+	}
+	obj, err := mc.GetObject(h.bucket, path.Join(h.prefix, p), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		x.Ignore(obj.Close())
+		return nil, 0, err
+	}
+	return obj, info.Size, nil
+}
+
+// Delete deletes the object at p, relative to the location.
+func (h *s3Handler) Delete(uri *url.URL, p string) error {
+	mc, err := h.setup(uri)
+	if err != nil {
+		return err
+	}
+	return mc.RemoveObject(h.bucket, path.Join(h.prefix, p))
+}
+
 // upload will block until it's done or an error occurs.
 func (h *s3Handler) upload(mc *minio.Client) error {
 	start := time.Now()
-	h.preader, h.pwriter = io.Pipe()
 
 	// We don't need to have a progress object, because we're using a Pipe. A write to Pipe would
 	// block until it can be fully read. So, the rate of the writes here would be equal to the rate
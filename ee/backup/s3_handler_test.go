@@ -0,0 +1,142 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 answers the bucket lookups of s3Handler.setup, and records the bucket checks. It
+// lists the keys of objects, one per page, from the start-after key on.
+type fakeS3 struct {
+	sync.Mutex
+	hosts   []string
+	checks  []string
+	objects []string
+	listed  int
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.hosts = append(s.hosts, r.Host)
+	switch {
+	case r.URL.Path != "/bucket/":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodHead:
+		s.checks = append(s.checks, r.Host+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Query().Get("list-type") == "2":
+		q := r.URL.Query()
+		var keys []string
+		for _, key := range s.objects {
+			if strings.HasPrefix(key, q.Get("prefix")) && key > q.Get("start-after") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var i int
+		fmt.Sscanf(q.Get("continuation-token"), "%d", &i)
+		fmt.Fprint(w, `<ListBucketResult>`)
+		if i < len(keys) {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, keys[i])
+			s.listed++
+		}
+		if i+1 < len(keys) {
+			fmt.Fprintf(w, `<IsTruncated>true</IsTruncated>`+
+				`<NextContinuationToken>%d</NextContinuationToken>`, i+1)
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	default:
+		// The location of the bucket.
+		fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+	}
+}
+
+func TestS3Compatible(t *testing.T) {
+	fs := &fakeS3{}
+	srv := httptest.NewTLSServer(fs)
+	defer srv.Close()
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	setup := func(location string, creds Credentials) error {
+		uri, err := url.Parse(location)
+		require.NoError(t, err)
+		creds.AccessKey, creds.SecretKey = "access", "secret"
+		h := &s3Handler{creds: &creds}
+		_, err = h.setup(uri)
+		return err
+	}
+
+	// The certificate of the endpoint is self-signed.
+	location := "s3://" + endpoint.Host + "/bucket/dgraph?path_style=true"
+	err = setup(location, Credentials{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "certificate")
+
+	require.NoError(t, setup(location+"&insecure_skip_verify=true", Credentials{}))
+	require.Equal(t, []string{endpoint.Host + "/bucket/"}, fs.checks)
+
+	// The flags override the location.
+	require.NoError(t, setup("s3:///bucket/dgraph", Credentials{
+		S3Endpoint:   endpoint.Host,
+		S3PathStyle:  true,
+		S3SkipVerify: true,
+	}))
+	require.Len(t, fs.checks, 2)
+	for _, host := range fs.hosts {
+		require.Equal(t, endpoint.Host, host)
+	}
+}
+
+func TestS3ListAfter(t *testing.T) {
+	fs := &fakeS3{}
+	srv := httptest.NewTLSServer(fs)
+	defer srv.Close()
+	endpoint, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	var manifests []string
+	for i := 0; i < 10; i++ {
+		dir := fmt.Sprintf("dgraph.20181106.%06d", i)
+		fs.objects = append(fs.objects, "dgraph/"+dir+"/"+manifestName,
+			"dgraph/"+dir+"/r1-g1.backup")
+		manifests = append(manifests, dir+"/"+manifestName)
+	}
+	uri, err := url.Parse("s3://" + endpoint.Host + "/bucket/dgraph?path_style=true&" +
+		"insecure_skip_verify=true")
+	require.NoError(t, err)
+	h := &s3Handler{creds: &Credentials{AccessKey: "access", SecretKey: "secret"}}
+
+	// The objects before the cursor aren't listed, nor the ones after the limit.
+	paths, more, err := listPage(h, uri, manifestName, manifests[3], 4)
+	require.NoError(t, err)
+	require.True(t, more)
+	require.Equal(t, manifests[4:8], paths)
+	require.Equal(t, 10, fs.listed)
+
+	paths, more, err = listPage(h, uri, manifestName, manifests[7], 4)
+	require.NoError(t, err)
+	require.False(t, more)
+	require.Equal(t, manifests[8:], paths)
+}
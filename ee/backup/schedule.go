@@ -0,0 +1,169 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// Schedule is a schedule of backups, parsed by ParseSchedule.
+type Schedule struct {
+	every time.Duration // interval of an @every schedule, zero for a cron schedule
+
+	// The values allowed in each field of a cron schedule, one bit per value.
+	minute, hour, dom, month, dow uint64
+	// The day fields are restricted, not "*".
+	domSet, dowSet bool
+}
+
+// scheduleAliases are the cron schedules of the @ names.
+var scheduleAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseSchedule parses spec, either "@every" and a duration like "@every 6h", one of the names
+// @hourly, @daily, @weekly and @monthly, or a cron schedule: minute, hour, day of month, month
+// and day of week, separated by spaces. Each cron field is "*" or a comma-separated list of
+// values and ranges like "1-5", optionally followed by a step like "*/15". Days of week go
+// from 0 (Sunday) to 6, 7 is Sunday too. If both day fields are restricted, a day matching
+// either of them matches, as in cron. Schedules are in UTC.
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d := strings.TrimPrefix(spec, "@every "); d != spec {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, x.Wrapf(err, "while parsing schedule %q", spec)
+		}
+		if every < time.Minute {
+			return nil, x.Errorf("Invalid schedule %q: the interval must be at least 1m", spec)
+		}
+		return &Schedule{every: every}, nil
+	}
+	if alias, ok := scheduleAliases[spec]; ok {
+		spec = alias
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, x.Errorf("Invalid schedule %q: expected 5 fields, got %d", spec,
+			len(fields))
+	}
+	s := &Schedule{}
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{{&s.minute, 0, 59}, {&s.hour, 0, 23}, {&s.dom, 1, 31}, {&s.month, 1, 12}, {&s.dow, 0, 7}} {
+		if *f.bits, err = parseField(fields[i], f.min, f.max); err != nil {
+			return nil, x.Wrapf(err, "while parsing schedule %q", spec)
+		}
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domSet, s.dowSet = fields[2] != "*", fields[4] != "*"
+	if s.Next(time.Now()).IsZero() {
+		return nil, x.Errorf("Invalid schedule %q: it never runs", spec)
+	}
+	return s, nil
+}
+
+// parseField returns the values of the cron field f, which go from min to max, one bit per
+// value.
+func parseField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, x.Errorf("Invalid step in %q", part)
+			}
+			rng = part[:i]
+		}
+		lo, hi := min, max
+		switch i := strings.Index(rng, "-"); {
+		case rng == "*":
+		case i >= 0:
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(rng[:i])
+			hi, err2 = strconv.Atoi(rng[i+1:])
+			if err1 != nil || err2 != nil {
+				return 0, x.Errorf("Invalid range in %q", part)
+			}
+		default:
+			var err error
+			if lo, err = strconv.Atoi(rng); err != nil {
+				return 0, x.Errorf("Invalid value in %q", part)
+			}
+			// A value with a step starts a range, "5/10" is "5-max/10".
+			if step == 1 {
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, x.Errorf("Values of %q must be between %d and %d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time of the schedule after t, in UTC. For an @every schedule, the
+// times are the multiples of its interval since the zero time. It returns the zero time if
+// the schedule doesn't run in the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC()
+	if s.every > 0 {
+		return t.Truncate(s.every).Add(s.every)
+	}
+	has := func(bits uint64, v int) bool { return bits&(1<<uint(v)) != 0 }
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+		day := dom && dow
+		if s.domSet && s.dowSet {
+			day = dom || dow
+		}
+		if !day {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
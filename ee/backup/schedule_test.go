@@ -0,0 +1,72 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	// A Tuesday.
+	now := time.Date(2018, 11, 6, 1, 13, 2, 0, time.UTC)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2018, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", at(11, 6, 1, 14)},
+		{"*/15 * * * *", at(11, 6, 1, 15)},
+		{"30 2 * * *", at(11, 6, 2, 30)},
+		{"0 1 * * *", at(11, 7, 1, 0)},
+		{"0 0,12 * * *", at(11, 6, 12, 0)},
+		{"0 3-5/2 * * *", at(11, 6, 3, 0)},
+		{"10/20 1 * * *", at(11, 6, 1, 30)},
+		{"0 0 * * 0", at(11, 11, 0, 0)},
+		{"0 0 * * 7", at(11, 11, 0, 0)},
+		{"0 0 * * 1-5", at(11, 7, 0, 0)},
+		{"0 0 1 * *", at(12, 1, 0, 0)},
+		{"0 0 15 * 5", at(11, 9, 0, 0)}, // Friday the 9th comes before the 15th
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", at(11, 6, 2, 0)},
+		{"@daily", at(11, 7, 0, 0)},
+		{"@weekly", at(11, 11, 0, 0)},
+		{"@monthly", at(12, 1, 0, 0)},
+		{"@every 6h", at(11, 6, 6, 0)},
+		{"@every 30m", at(11, 6, 1, 30)},
+	}
+	for _, tc := range tests {
+		s, err := ParseSchedule(tc.spec)
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.next, s.Next(now), tc.spec)
+	}
+
+	// The next time is always after the given one.
+	s, err := ParseSchedule("14 1 * * *")
+	require.NoError(t, err)
+	require.Equal(t, at(11, 6, 1, 14), s.Next(now))
+	require.Equal(t, at(11, 7, 1, 14), s.Next(at(11, 6, 1, 14)))
+
+	for _, spec := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "0 0 30 2 *", "@every 10s", "@every x",
+		"@yearly",
+	} {
+		_, err := ParseSchedule(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,186 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// schemaCheck finds the predicates that have data in a backup file but no schema in it. Every
+// backup file has the schema of all the predicates of its group, and Dgraph sets the schema of
+// a predicate before it writes any data for it, so data without a schema is a sign of a
+// partial or corrupted backup. The schema keys come after the data keys in a backup file, so
+// the predicates are only known to miss their schema once the whole file is read.
+type schemaCheck struct {
+	data   map[string]struct{}
+	schema map[string]struct{}
+}
+
+func newSchemaCheck() *schemaCheck {
+	return &schemaCheck{data: make(map[string]struct{}), schema: make(map[string]struct{})}
+}
+
+// add records the predicate of the key pk.
+func (c *schemaCheck) add(pk *x.ParsedKey) {
+	switch {
+	case pk.IsSchema():
+		c.schema[pk.Attr] = struct{}{}
+	case pk.IsData() || pk.IsIndex() || pk.IsReverse() || pk.IsCount():
+		c.data[pk.Attr] = struct{}{}
+	}
+}
+
+// route returns a routeFn that records the predicate of each key routed with route.
+func (c *schemaCheck) route(route routeFn) routeFn {
+	return func(pk *x.ParsedKey, kv *pb.KV) (kvWriter, error) {
+		c.add(pk)
+		return route(pk, kv)
+	}
+}
+
+// missing returns the predicates with data but no schema, sorted.
+func (c *schemaCheck) missing() []string {
+	var attrs []string
+	for attr := range c.data {
+		if _, ok := c.schema[attr]; !ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	sort.Strings(attrs)
+	return attrs
+}
+
+// checkSchema reads the backup file r of group gid and returns the predicates in preds with
+// data but no schema in it, see schemaCheck. If bs is set, the schema of the predicates is
+// recorded in it.
+func checkSchema(r io.Reader, gid uint32, preds *predicateSet, bs *backupSchema) (
+	[]string, error) {
+	c := newSchemaCheck()
+	err := readBackup(r, func(kv *pb.KV) error {
+		pk := x.Parse(kv.Key)
+		if pk == nil {
+			return x.Errorf("Invalid key %q in backup", kv.Key)
+		}
+		// A drop of all the data has no predicate, and drops the schema of the preds too.
+		if pk.Attr != "" && !preds.has(pk.Attr) {
+			return nil
+		}
+		c.add(pk)
+		if bs == nil {
+			return nil
+		}
+		return bs.add(gid, pk, kv)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.missing(), nil
+}
+
+// backupSchema is the schema of the predicates in the backup files read into it, by group.
+// The files of a group must be read in order, so the schema set or dropped by an incremental
+// backup replaces the one before it. It's safe for concurrent use by the groups.
+type backupSchema struct {
+	sync.Mutex
+	groups map[uint32]map[string]*pb.SchemaUpdate
+}
+
+func newBackupSchema() *backupSchema {
+	return &backupSchema{groups: make(map[uint32]map[string]*pb.SchemaUpdate)}
+}
+
+// add records the schema, or the drop, in the KV kv of group gid with the key pk.
+func (bs *backupSchema) add(gid uint32, pk *x.ParsedKey, kv *pb.KV) error {
+	bs.Lock()
+	defer bs.Unlock()
+	group, ok := bs.groups[gid]
+	if !ok {
+		group = make(map[string]*pb.SchemaUpdate)
+		bs.groups[gid] = group
+	}
+	switch {
+	case pk.IsSchema():
+		su := &pb.SchemaUpdate{}
+		if err := su.Unmarshal(kv.Val); err != nil {
+			return x.Wrapf(err, "while decoding the schema of %q", pk.Attr)
+		}
+		su.Predicate = pk.Attr
+		group[pk.Attr] = su
+	case pk.IsDrop() && pk.Attr == "":
+		bs.groups[gid] = make(map[string]*pb.SchemaUpdate)
+	case pk.IsDrop():
+		delete(group, pk.Attr)
+	}
+	return nil
+}
+
+// diff compares the schema of the predicates in preds with the expected one, and returns the
+// differences, one per predicate, sorted by predicate: the predicates added in the backup, the
+// ones removed from it, and the ones whose type, index or other directives changed.
+func (bs *backupSchema) diff(expected []*pb.SchemaUpdate, preds *predicateSet) []string {
+	bs.Lock()
+	defer bs.Unlock()
+	got := make(map[string]*pb.SchemaUpdate)
+	for _, group := range bs.groups {
+		for attr, su := range group {
+			got[attr] = su
+		}
+	}
+	want := make(map[string]*pb.SchemaUpdate)
+	for _, su := range expected {
+		if preds.has(su.Predicate) {
+			want[su.Predicate] = su
+		}
+	}
+
+	var attrs []string
+	for attr := range got {
+		attrs = append(attrs, attr)
+	}
+	for attr := range want {
+		if _, ok := got[attr]; !ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	sort.Strings(attrs)
+	var diffs []string
+	for _, attr := range attrs {
+		g, w := got[attr], want[attr]
+		switch {
+		case w == nil:
+			diffs = append(diffs, "added: "+formatSchema(g))
+		case g == nil:
+			diffs = append(diffs, "removed: "+formatSchema(w))
+		case formatSchema(g) != formatSchema(w):
+			diffs = append(diffs, fmt.Sprintf("changed: %s, expected %s", formatSchema(g),
+				formatSchema(w)))
+		}
+	}
+	return diffs
+}
+
+// formatSchema formats su like in a schema file, with its tokenizers sorted so the order they
+// were given in doesn't matter.
+func formatSchema(su *pb.SchemaUpdate) string {
+	s := *su
+	s.Tokenizer = append([]string{}, su.Tokenizer...)
+	sort.Strings(s.Tokenizer)
+	return schema.Format(su.Predicate, s)
+}
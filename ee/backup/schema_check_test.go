@@ -0,0 +1,121 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreSchemaMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	su, err := (&pb.SchemaUpdate{Predicate: "name", ValueType: pb.Posting_STRING}).Marshal()
+	require.NoError(t, err)
+	kvs := testKVs("name", 3)
+	kvs.Kv = append(kvs.Kv, testKVs("age", 2).Kv...)
+	kvs.Kv = append(kvs.Kv, &pb.KV{Key: x.SchemaKey("name"), Val: su,
+		UserMeta: []byte{posting.BitSchemaPosting}, Version: 1})
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	var buf bytes.Buffer
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = &buf
+
+	// The orphan data of age fails the restore before anything is written.
+	pdir := filepath.Join(dir, "abort")
+	o := &restoreOptions{location: bdir, pdir: pdir, abortOnSchemaMissing: true}
+	err = runRestore(o, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "has data of predicates without a schema: age.")
+	_, err = os.Stat(filepath.Join(pdir, "p1"))
+	require.True(t, os.IsNotExist(err))
+
+	// Restoring only name finds no orphans.
+	o.predicates = []string{"name"}
+	require.NoError(t, runRestore(o, p))
+	require.Len(t, readKVs(t, filepath.Join(pdir, "p1")), 4)
+
+	// Without the flag, the orphans are restored and reported.
+	buf.Reset()
+	o = &restoreOptions{location: bdir, pdir: filepath.Join(dir, "warn")}
+	require.NoError(t, runRestore(o, p))
+	require.Len(t, readKVs(t, filepath.Join(dir, "warn", "p1")), 6)
+	require.Contains(t, buf.String(), "has data of predicates without a schema: age\n")
+}
+
+func TestRestoreValidateSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kvs := testKVs("name", 3)
+	for _, su := range []*pb.SchemaUpdate{
+		{Predicate: "name", ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX,
+			Tokenizer: []string{"exact"}},
+		{Predicate: "age", ValueType: pb.Posting_INT},
+	} {
+		b, err := su.Marshal()
+		require.NoError(t, err)
+		kvs.Kv = append(kvs.Kv, &pb.KV{Key: x.SchemaKey(su.Predicate), Val: b,
+			UserMeta: []byte{posting.BitSchemaPosting}, Version: 1})
+	}
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	var buf bytes.Buffer
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = &buf
+	validate := func(schema string, strict bool) error {
+		file := filepath.Join(dir, "schema.txt")
+		require.NoError(t, ioutil.WriteFile(file, []byte(schema), 0600))
+		buf.Reset()
+		o := &restoreOptions{location: bdir, dryRun: true, validateSchemaFile: file,
+			strict: strict}
+		return runRestore(o, p)
+	}
+
+	require.NoError(t, validate("age: int .\nname: string @index(exact) .", true))
+	require.Contains(t, buf.String(), "The schema of the backup matches")
+
+	// The drift fails the restore with --strict, before anything is read.
+	drifted := "name: string @index(term) .\nemail: string ."
+	err = validate(drifted, true)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "added: age:int\n")
+	require.Contains(t, err.Error(), "removed: email:string\n")
+	require.Contains(t, err.Error(),
+		"changed: name:string @index(exact), expected name:string @index(term)")
+	require.NotContains(t, buf.String(), "Verifying backup")
+
+	// Otherwise, it's reported and the backup is restored.
+	require.NoError(t, validate(drifted, false))
+	require.Contains(t, buf.String(), "Warning: the schema of the backup differs")
+	require.Contains(t, buf.String(), "Verifying backup")
+}
@@ -0,0 +1,118 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// predicateStats are the statistics of the data of a predicate restored, see --stats_csv.
+// They're counted from the KVs of the backup files as they're restored, so a subject changed
+// in an incremental backup is counted again for it.
+type predicateStats struct {
+	Triples  int64 `json:"triples"`  // postings in the posting lists of its subjects
+	Bytes    int64 `json:"bytes"`    // size of its keys and values, the indexes included
+	Subjects int64 `json:"subjects"` // subjects with data of it
+}
+
+// statsSet is the statistics of each predicate, by predicate.
+type statsSet map[string]*predicateStats
+
+// get returns the statistics of attr, nil if s is nil.
+func (s statsSet) get(attr string) *predicateStats {
+	if s == nil {
+		return nil
+	}
+	st, ok := s[attr]
+	if !ok {
+		st = &predicateStats{}
+		s[attr] = st
+	}
+	return st
+}
+
+// copy returns a copy of s that doesn't share its statistics, or nil if s is nil.
+func (s statsSet) copy() statsSet {
+	if s == nil {
+		return nil
+	}
+	c := make(statsSet, len(s))
+	for attr, st := range s {
+		cst := *st
+		c[attr] = &cst
+	}
+	return c
+}
+
+// restoreStats totals the statistics of the backup files restored. It's safe for concurrent
+// use.
+type restoreStats struct {
+	sync.Mutex
+	preds statsSet
+}
+
+func newRestoreStats() *restoreStats {
+	return &restoreStats{preds: make(statsSet)}
+}
+
+// merge adds the statistics s of a file. It does nothing if rs is nil.
+func (rs *restoreStats) merge(s statsSet) {
+	if rs == nil {
+		return
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	for attr, st := range s {
+		t := rs.preds.get(attr)
+		t.Triples += st.Triples
+		t.Bytes += st.Bytes
+		t.Subjects += st.Subjects
+	}
+}
+
+// writeCSV writes the statistics to the CSV file at path, with a header and then a row per
+// predicate, sorted by predicate.
+func (rs *restoreStats) writeCSV(path string) error {
+	rs.Lock()
+	defer rs.Unlock()
+	attrs := make([]string, 0, len(rs.preds))
+	for attr := range rs.preds {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return x.Wrapf(err, "while creating the --stats_csv")
+	}
+	// The errors of the writes are kept by the writer, and returned by Error once flushed.
+	w := csv.NewWriter(f)
+	x.Ignore(w.Write([]string{"predicate", "triples", "bytes", "subjects"}))
+	for _, attr := range attrs {
+		st := rs.preds[attr]
+		x.Ignore(w.Write([]string{attr, strconv.FormatInt(st.Triples, 10),
+			strconv.FormatInt(st.Bytes, 10), strconv.FormatInt(st.Subjects, 10)}))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		x.Ignore(f.Close())
+		return x.Wrapf(err, "while writing the --stats_csv")
+	}
+	return f.Close()
+}
@@ -0,0 +1,78 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreStatsCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kvs := &pb.KVS{}
+	var bytes int64
+	add := func(key []byte, uids ...uint64) {
+		pl := &pb.PostingList{Pack: codec.Encode(uids, 256)}
+		val, err := pl.Marshal()
+		require.NoError(t, err)
+		kvs.Kv = append(kvs.Kv, &pb.KV{Key: key, Val: val,
+			UserMeta: []byte{posting.BitCompletePosting}, Version: 1})
+		if x.Parse(key).Attr == "friend" {
+			bytes += int64(len(key) + len(val))
+		}
+	}
+	add(x.DataKey("friend", 1), 2, 3, 4)
+	add(x.DataKey("friend", 2), 5)
+	add(x.ReverseKey("friend", 5), 2)
+	add(x.DataKey("name", 1), math.MaxUint64)
+	kvs.Kv = append(kvs.Kv, &pb.KV{Key: x.DataKey("name", 2), UserMeta: []byte{
+		posting.BitEmptyPosting}, Version: 1})
+	bdir := filepath.Join(dir, "backups")
+	require.NoError(t, os.Mkdir(bdir, 0700))
+	writeBackup(t, bdir, "20181106.011302", 0, 10, kvs)
+
+	p, err := newProgress("text", ioutil.Discard)
+	require.NoError(t, err)
+	p.msgs = ioutil.Discard
+	file := filepath.Join(dir, "stats.csv")
+	o := &restoreOptions{location: bdir, pdir: filepath.Join(dir, "postings"), statsCSV: file}
+	require.NoError(t, runRestore(o, p))
+
+	// The reverse key adds to the bytes only, the empty posting list of name to nothing.
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"predicate", "triples", "bytes", "subjects"},
+		{"friend", "4", fmt.Sprint(bytes), "2"},
+		{"name", "1", fmt.Sprint(len(kvs.Kv[3].Key) + len(kvs.Kv[3].Val) +
+			len(kvs.Kv[4].Key)), "1"},
+	}, rows)
+}
@@ -0,0 +1,90 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of the bytes read or written through it. It's safe for
+// concurrent use, so the transfers sharing a network link can share a single limiter.
+// A nil RateLimiter doesn't limit anything.
+type RateLimiter struct {
+	sync.Mutex
+	rate float64   // bytes per second
+	next time.Time // when the bytes let through so far are within the rate
+}
+
+// NewRateLimiter returns a limiter of mbps MB per second, or nil if mbps isn't positive.
+func NewRateLimiter(mbps float64) *RateLimiter {
+	if mbps <= 0 {
+		return nil
+	}
+	return &RateLimiter{rate: mbps * 1e6}
+}
+
+// wait blocks until n more bytes can go through. The time spent idle isn't saved up for
+// later, so there are no bursts above the rate.
+func (rl *RateLimiter) wait(n int) {
+	if rl == nil || n <= 0 {
+		return
+	}
+	rl.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	rl.next = rl.next.Add(time.Duration(float64(n) / rl.rate * float64(time.Second)))
+	d := rl.next.Sub(now)
+	rl.Unlock()
+	time.Sleep(d)
+}
+
+// Reader returns a reader of r limited by rl.
+func (rl *RateLimiter) Reader(r io.Reader) io.Reader {
+	if rl == nil {
+		return r
+	}
+	return &limitedReader{r: r, rl: rl}
+}
+
+// Writer returns a writer to w limited by rl.
+func (rl *RateLimiter) Writer(w io.Writer) io.Writer {
+	if rl == nil {
+		return w
+	}
+	return &limitedWriter{w: w, rl: rl}
+}
+
+type limitedReader struct {
+	r  io.Reader
+	rl *RateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.rl.wait(n)
+	return n, err
+}
+
+type limitedWriter struct {
+	w  io.Writer
+	rl *RateLimiter
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	lw.rl.wait(len(p))
+	return lw.w.Write(p)
+}
@@ -0,0 +1,52 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	require.Nil(t, NewRateLimiter(0))
+	r := bytes.NewReader(nil)
+	require.Equal(t, r, NewRateLimiter(0).Reader(r))
+
+	// 100 KB at 0.5 MB/s, split over two concurrent transfers, take 0.2s.
+	rl := NewRateLimiter(0.5)
+	data := make([]byte, 50000)
+	start := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		n, err := io.Copy(ioutil.Discard, rl.Reader(bytes.NewReader(data)))
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), n)
+	}()
+	go func() {
+		defer wg.Done()
+		var buf bytes.Buffer
+		_, err := rl.Writer(&buf).Write(data)
+		require.NoError(t, err)
+		require.Equal(t, data, buf.Bytes())
+	}()
+	wg.Wait()
+	require.True(t, time.Since(start) >= 190*time.Millisecond, "took %s", time.Since(start))
+}
@@ -0,0 +1,322 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/tok"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// verifyBackup reads and decodes every KV in a backup file without writing it anywhere.
+// The file checksum is verified by Load, so this only needs to check the KVs themselves.
+// The verified keys are counted in fp.
+func verifyBackup(r io.Reader, fp *fileProgress) error {
+	return readBackup(r, func(kv *pb.KV) error {
+		if err := verifyKV(kv); err != nil {
+			return err
+		}
+		atomic.AddInt64(&fp.keys, 1)
+		return nil
+	})
+}
+
+// verifyKV checks that the key of kv can be parsed and that its value can be decoded.
+// Schema values must be valid schema updates, posting lists must unmarshal.
+func verifyKV(kv *pb.KV) error {
+	// x.Parse doesn't check the key length, so make sure it won't panic first. Only schema
+	// and drop keys end right after the attribute, the others are followed by the key type.
+	key := kv.Key
+	if len(key) < 3 {
+		return x.Errorf("Invalid key %q: too short", key)
+	}
+	end := 3 + int(binary.BigEndian.Uint16(key[1:3]))
+	switch {
+	case len(key) < end:
+		return x.Errorf("Invalid key %q: too short", key)
+	case len(key) == end && !bytes.Equal(key, x.SchemaKey(string(key[3:]))) &&
+		!bytes.Equal(key, x.DropKey(string(key[3:]))):
+		return x.Errorf("Invalid key %q: missing key type", key)
+	}
+	pk := x.Parse(key)
+	if pk == nil {
+		return x.Errorf("Invalid key %q: unknown key type", key)
+	}
+
+	if pk.IsSchema() {
+		var su pb.SchemaUpdate
+		if err := su.Unmarshal(kv.Val); err != nil {
+			return x.Wrapf(err, "while decoding schema of %q", pk.Attr)
+		}
+		if _, ok := pb.Posting_ValType_name[int32(su.ValueType)]; !ok {
+			return x.Errorf("Invalid schema of %q: unknown value type %d", pk.Attr, su.ValueType)
+		}
+		for _, name := range su.Tokenizer {
+			if _, ok := tok.GetTokenizer(name); !ok {
+				return x.Errorf("Invalid schema of %q: unknown tokenizer %q", pk.Attr, name)
+			}
+		}
+		return nil
+	}
+	if pk.IsDrop() {
+		return nil
+	}
+
+	var meta byte
+	if len(kv.UserMeta) > 0 {
+		meta = kv.UserMeta[0]
+	}
+	if meta&posting.BitEmptyPosting == posting.BitEmptyPosting ||
+		meta&posting.BitCompletePosting == 0 {
+		return nil
+	}
+	var pl pb.PostingList
+	if err := pl.Unmarshal(kv.Val); err != nil {
+		return x.Wrapf(err, "while decoding posting list of key %q", key)
+	}
+	return nil
+}
+
+// verifyOptions are the settings of a verification of the backups at a location.
+type verifyOptions struct {
+	location string
+	creds    Credentials
+	keyFile  string
+	key      []byte
+	// frames is the number of frames read from each backup file, zero to read them all.
+	frames int64
+	// parallel is the number of files verified concurrently, see verifyFiles.
+	parallel int
+	// Verify the backups whose directory sorts after startAfter, up to limit of them, zero
+	// for all of them. See readManifestsPage.
+	startAfter string
+	limit      int
+	// augmentDir has the checksums saved by the restores of the backups whose manifests have
+	// none, see augmenter.
+	augmentDir string
+}
+
+// errSampled stops reading a backup file once the sample of frames was read.
+var errSampled = errors.New("sample read")
+
+// fileResult is the outcome of the verification of a backup file.
+type fileResult struct {
+	frames   int64 // frames read and verified
+	complete bool  // the whole file was read, and its checksum verified
+}
+
+// verifyJob is a backup file to verify: the file of group gid of the backup of m. err is the
+// reason it failed verification, if it did.
+type verifyJob struct {
+	m   *Manifest
+	gid uint32
+	res fileResult
+	err error
+}
+
+// runVerify reads a sample of the frames of each backup file at o.location, decodes them and
+// checks their checksums and keys, then writes a table of the results to out. The keys must
+// belong to the predicates the manifest lists for the file. Files read in full are also
+// checked against the checksum and the predicates recorded in the manifest.
+// It returns an error if any file fails verification. With o.parallel, the files are verified
+// concurrently, see verifyFiles. With o.startAfter and o.limit, only a page of the backups is
+// verified, followed by the cursor of the next one if there are more. With o.augmentDir, the
+// files whose manifest has no checksum are checked against the ones saved there.
+func runVerify(o *verifyOptions, out io.Writer) error {
+	if o.keyFile != "" {
+		key, err := ReadKeyFile(o.keyFile)
+		if err != nil {
+			return err
+		}
+		o.key = key
+	}
+	h, uri, err := newHandler(o.location, &o.creds)
+	if err != nil {
+		return err
+	}
+	manifests, next, err := readManifestsPage(h, uri, o.startAfter, o.limit)
+	if err != nil {
+		return err
+	}
+	if len(manifests) == 0 {
+		fmt.Fprintf(out, "No backups found in %q\n", uri.String())
+		return nil
+	}
+	if o.augmentDir != "" {
+		if err := readAugmented(o.augmentDir, manifests); err != nil {
+			return err
+		}
+	}
+	// The files encrypted without a key fail on their own, the others can still be verified.
+	if o.key != nil {
+		if err := checkEncryption(manifests, o.key); err != nil {
+			return err
+		}
+	}
+
+	var jobs []*verifyJob
+	for _, m := range manifests {
+		for _, gid := range m.Groups {
+			jobs = append(jobs, &verifyJob{m: m, gid: gid})
+		}
+	}
+	o.verifyFiles(h, uri, jobs)
+
+	var failed int
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKUP\tGROUP\tFRAMES\tCHECKSUM\tRESULT")
+	for _, job := range jobs {
+		result, checksum := "OK", "sampled"
+		if job.err != nil {
+			failed++
+			result = job.err.Error()
+		}
+		if job.res.complete {
+			checksum = "verified"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", path.Dir(job.m.path), job.gid, job.res.frames,
+			checksum, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if next != "" {
+		fmt.Fprintf(out, "More backups follow, verify them with --start_after=%s\n", next)
+	}
+	if failed > 0 {
+		return x.Errorf("%d of %d backup files failed verification", failed, len(jobs))
+	}
+	return nil
+}
+
+// verifyFiles verifies the files of jobs, and records the result of each one in its job.
+// Up to o.parallel files are verified concurrently, each with its own handler of o.location,
+// since the handlers are not safe for concurrent use. The results don't depend on the order
+// the files complete in, so they're the same as the ones of a serial verification.
+func (o *verifyOptions) verifyFiles(h handler, uri *url.URL, jobs []*verifyJob) {
+	if o.parallel <= 1 {
+		for _, job := range jobs {
+			job.res, job.err = o.verifyFile(h, uri, job.m, job.gid)
+		}
+		return
+	}
+	thr := x.NewThrottle(o.parallel)
+	for _, job := range jobs {
+		thr.Start()
+		go func(job *verifyJob) {
+			defer thr.Done()
+			h, uri, err := newHandler(o.location, &o.creds)
+			if err != nil {
+				job.err = err
+				return
+			}
+			job.res, job.err = o.verifyFile(h, uri, job.m, job.gid)
+		}(job)
+	}
+	thr.Wait()
+}
+
+// verifyFile verifies the file of group gid of the backup of m, see runVerify.
+func (o *verifyOptions) verifyFile(h handler, uri *url.URL, m *Manifest, gid uint32) (
+	fileResult, error) {
+	var res fileResult
+	f := &loadFile{
+		name:        path.Join(path.Dir(m.path), backupName(m.ReadTs, gid)),
+		group:       gid,
+		checksum:    m.Checksums[gid],
+		preds:       m.Predicates[gid],
+		encryption:  m.Encryption,
+		compression: m.Compression,
+	}
+	if f.encryption != "" && o.key == nil {
+		return res, x.Errorf("Backup is encrypted, its key must be given with " +
+			"--encryption_key_file")
+	}
+	rc, size, err := readFile(h, uri, f.name)
+	if err != nil {
+		return res, err
+	}
+	defer rc.Close()
+	f.size = size
+
+	sum := sha256.New()
+	ro := &restoreOptions{key: o.key}
+	r, err := ro.newReader(io.TeeReader(rc, sum), f, &fileProgress{loadFile: f})
+	if err != nil {
+		return res, err
+	}
+	listed := make(map[string]struct{})
+	for _, attr := range f.preds {
+		listed[attr] = struct{}{}
+	}
+	seen := make(map[string]struct{})
+	var sampled bool
+	err = readBackup(r, func(kv *pb.KV) error {
+		if err := verifyKV(kv); err != nil {
+			return err
+		}
+		// A drop of all the data has no predicate.
+		if attr := x.Parse(kv.Key).Attr; attr != "" {
+			if _, ok := listed[attr]; f.preds != nil && !ok {
+				return x.Errorf("Predicate %q is not listed in the manifest", attr)
+			}
+			seen[attr] = struct{}{}
+		}
+		if res.frames++; o.frames > 0 && res.frames >= o.frames {
+			sampled = true
+			return errSampled
+		}
+		return nil
+	})
+	// readBackup wraps the errors of fn.
+	switch {
+	case sampled:
+		return res, nil
+	case err != nil:
+		return res, err
+	}
+
+	// The whole file was read.
+	res.complete = true
+	if _, err := io.Copy(sum, rc); err != nil {
+		return res, err
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); f.checksum != "" && got != f.checksum {
+		return res, x.Errorf("Checksum mismatch: expected %s, got %s", f.checksum, got)
+	}
+	if f.preds != nil && len(seen) != len(listed) {
+		var missing []string
+		for attr := range listed {
+			if _, ok := seen[attr]; !ok {
+				missing = append(missing, attr)
+			}
+		}
+		sort.Strings(missing)
+		return res, x.Errorf("Predicates listed in the manifest not found: %v", missing)
+	}
+	return res, nil
+}
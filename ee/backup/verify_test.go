@@ -0,0 +1,203 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyKV(t *testing.T) {
+	marshal := func(m interface{ Marshal() ([]byte, error) }) []byte {
+		b, err := m.Marshal()
+		require.NoError(t, err)
+		return b
+	}
+	pl := &pb.PostingList{Postings: []*pb.Posting{{Uid: 1}}}
+	complete := []byte{posting.BitCompletePosting}
+
+	tests := []struct {
+		name string
+		kv   *pb.KV
+		err  string
+	}{
+		{"data", &pb.KV{Key: x.DataKey("name", 1), Val: marshal(pl), UserMeta: complete}, ""},
+		{"index", &pb.KV{Key: x.IndexKey("name", "a"), Val: marshal(pl), UserMeta: complete}, ""},
+		{"empty", &pb.KV{Key: x.DataKey("name", 1),
+			UserMeta: []byte{posting.BitEmptyPosting}}, ""},
+		{"schema", &pb.KV{Key: x.SchemaKey("name"), Val: marshal(&pb.SchemaUpdate{
+			Predicate: "name", ValueType: pb.Posting_STRING, Tokenizer: []string{"exact"},
+		})}, ""},
+		{"short key", &pb.KV{Key: []byte{0, 0}}, "too short"},
+		{"short attr", &pb.KV{Key: x.DataKey("name", 1)[:5]}, "too short"},
+		{"no key type", &pb.KV{Key: x.DataKey("name", 1)[:7]}, "missing key type"},
+		{"short uid", &pb.KV{Key: x.DataKey("name", 1)[:10]}, "unknown key type"},
+		{"bad posting list", &pb.KV{Key: x.DataKey("name", 1), Val: []byte{0xff},
+			UserMeta: complete}, "posting list"},
+		{"bad value type", &pb.KV{Key: x.SchemaKey("name"), Val: marshal(&pb.SchemaUpdate{
+			Predicate: "name", ValueType: 100,
+		})}, "unknown value type"},
+		{"bad tokenizer", &pb.KV{Key: x.SchemaKey("name"), Val: marshal(&pb.SchemaUpdate{
+			Predicate: "name", ValueType: pb.Posting_STRING, Tokenizer: []string{"nope"},
+		})}, "unknown tokenizer"},
+	}
+	for _, tc := range tests {
+		err := verifyKV(tc.kv)
+		if tc.err == "" {
+			require.NoError(t, err, tc.name)
+			continue
+		}
+		require.Error(t, err, tc.name)
+		require.Contains(t, err.Error(), tc.err, tc.name)
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeBackup(t, dir, "20181106.011302", 0, 10, testKVs("name", 5), testKVs("age", 3))
+	writeBackupOpts(t, dir, "20181106.021302", 10, 20,
+		backupOpts{key: testKey, compression: "gzip"}, testKVs("name", 2))
+
+	var out bytes.Buffer
+	verify := func(o *verifyOptions) ([][]string, error) {
+		out.Reset()
+		err := runVerify(o, &out)
+		var rows [][]string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n")[1:] {
+			// The result of failed files has spaces, keep it in one field.
+			rows = append(rows, strings.SplitN(strings.Join(strings.Fields(line), " "), " ", 5))
+		}
+		return rows, err
+	}
+
+	o := &verifyOptions{location: dir, frames: 2}
+	rows, err := verify(o)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "1 of 3 backup files failed verification")
+	require.Contains(t, rows[2][4], "--encryption_key_file")
+
+	o.key = testKey
+	rows, err = verify(o)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"dgraph.20181106.011302", "1", "2", "sampled", "OK"},
+		{"dgraph.20181106.011302", "2", "2", "sampled", "OK"},
+		{"dgraph.20181106.021302", "1", "2", "sampled", "OK"},
+	}, rows)
+
+	o.frames = 0
+	rows, err = verify(o)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"dgraph.20181106.011302", "1", "5", "verified", "OK"},
+		{"dgraph.20181106.011302", "2", "3", "verified", "OK"},
+		{"dgraph.20181106.021302", "1", "2", "verified", "OK"},
+	}, rows)
+
+	// Corrupt the value of the last KV of group 1. The sample doesn't get to it.
+	file := filepath.Join(dir, "dgraph.20181106.011302", "r10-g1.backup")
+	orig, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	b := append([]byte{}, orig...)
+	b[bytes.LastIndex(b, []byte("val-5"))] = 'X'
+	require.NoError(t, ioutil.WriteFile(file, b, 0600))
+	o.frames = 2
+	_, err = verify(o)
+	require.NoError(t, err)
+	o.frames = 0
+	rows, err = verify(o)
+	require.Error(t, err)
+	require.Contains(t, rows[0][4], "Frame 4 is corrupted")
+	require.NoError(t, ioutil.WriteFile(file, orig, 0600))
+
+	// The manifest doesn't match the predicates of the files.
+	mfile := filepath.Join(dir, "dgraph.20181106.011302", manifestName)
+	mb, err := ioutil.ReadFile(mfile)
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(mb, &m))
+	m.Predicates[1] = []string{"name", "email"}
+	m.Predicates[2] = []string{"email"}
+	require.NoError(t, WriteManifest(dir, "20181106.011302", &m))
+	rows, err = verify(o)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "2 of 3 backup files failed verification")
+	require.Contains(t, rows[0][4], "Predicates listed in the manifest not found: [email]")
+	require.Contains(t, rows[1][4], `Predicate "age" is not listed in the manifest`)
+}
+
+func TestVerifyParallel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeBackup(t, dir, "20181106.011302", 0, 10, testKVs("name", 50), testKVs("age", 30),
+		testKVs("email", 40))
+	writeBackup(t, dir, "20181106.021302", 10, 20, testKVs("name", 20), testKVs("age", 10))
+	file := filepath.Join(dir, "dgraph.20181106.011302", "r10-g2.backup")
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	b[bytes.LastIndex(b, []byte("val-5"))] = 'X'
+	require.NoError(t, ioutil.WriteFile(file, b, 0600))
+
+	// The parallel verifications report the same files, in the same order, as the serial one.
+	verify := func(parallel int) (string, error) {
+		var out bytes.Buffer
+		err := runVerify(&verifyOptions{location: dir, parallel: parallel}, &out)
+		return out.String(), err
+	}
+	expected, expectedErr := verify(1)
+	require.Error(t, expectedErr)
+	require.Contains(t, expected, "Frame")
+	for _, parallel := range []int{2, 8} {
+		got, err := verify(parallel)
+		require.Equal(t, expected, got)
+		require.Equal(t, expectedErr, err)
+	}
+}
+
+func BenchmarkVerifyParallel(b *testing.B) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(b, err)
+	defer os.RemoveAll(dir)
+
+	var groups []*pb.KVS
+	for i := 0; i < 8; i++ {
+		groups = append(groups, testKVs("name", 100000))
+	}
+	writeBackup(b, dir, "20181106.011302", 0, 10, groups...)
+
+	for _, parallel := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("verify_parallel=%d", parallel), func(b *testing.B) {
+			o := &verifyOptions{location: dir, parallel: parallel}
+			for i := 0; i < b.N; i++ {
+				require.NoError(b, runVerify(o, ioutil.Discard))
+			}
+		})
+	}
+}
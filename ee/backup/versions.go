@@ -0,0 +1,92 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// groupVersion is the highest version of the keys restored into the pN directory of a group.
+type groupVersion struct {
+	group uint32
+	max   uint64
+}
+
+// groupVersions reopens the pN directory of each group in zs and returns the highest version
+// of its keys, sorted by group. The groups without a directory are skipped.
+func (o *restoreOptions) groupVersions(zs *zeroState) ([]groupVersion, error) {
+	var versions []groupVersion
+	for _, gid := range zs.groups() {
+		dir := o.groupDir(gid)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		db, err := o.openPostings(dir)
+		if err != nil {
+			return nil, err
+		}
+		max := maxVersion(db)
+		if err := db.Close(); err != nil {
+			return nil, err
+		}
+		versions = append(versions, groupVersion{group: gid, max: max})
+	}
+	return versions, nil
+}
+
+// maxVersion returns the highest version of the keys in db, the deleted ones aside.
+func maxVersion(db *badger.DB) uint64 {
+	txn := db.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	itr := txn.NewIterator(opt)
+	defer itr.Close()
+	var max uint64
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		max = x.Max(max, itr.Item().Version())
+	}
+	return max
+}
+
+// checkVersions returns the groups of versions that diverge: the ones with keys above readTs,
+// the read ts of the latest backup restored, which the backups can't have, and with skew set,
+// the ones whose highest version is more than skew below the highest of all the groups. The
+// groups of a backup share its read ts, but a group with no recent writes lags behind, so the
+// second check is only made if asked for. Both hint at the data of different backups mixed
+// together or at corrupted versions.
+func checkVersions(versions []groupVersion, readTs, skew uint64) []string {
+	var top groupVersion
+	for _, v := range versions {
+		if v.max > top.max {
+			top = v
+		}
+	}
+	var diverged []string
+	for _, v := range versions {
+		switch {
+		case readTs > 0 && v.max > readTs:
+			diverged = append(diverged, fmt.Sprintf("group %d has keys at version %d, above "+
+				"the read ts %d of the backup", v.group, v.max, readTs))
+		case skew > 0 && top.max-v.max > skew:
+			diverged = append(diverged, fmt.Sprintf("group %d has keys up to version %d, %d "+
+				"below group %d", v.group, v.max, top.max-v.max, top.group))
+		}
+	}
+	return diverged
+}
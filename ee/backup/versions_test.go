@@ -0,0 +1,61 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	restore := func(readTs, skew uint64) string {
+		bdir := filepath.Join(dir, fmt.Sprintf("backups-%d-%d", readTs, skew))
+		require.NoError(t, os.Mkdir(bdir, 0700))
+		writeBackup(t, bdir, "20181106.011302", 0, readTs, testKVs("name", 5), testKVs("age", 3))
+		p, err := newProgress("text", ioutil.Discard)
+		require.NoError(t, err)
+		var msgs bytes.Buffer
+		p.msgs = &msgs
+		o := &restoreOptions{location: bdir, pdir: filepath.Join(bdir, "postings"),
+			versionSkew: skew}
+		require.NoError(t, runRestore(o, p))
+		return msgs.String()
+	}
+
+	// The groups lag behind each other, which is only flagged with a skew.
+	msgs := restore(10, 0)
+	require.Contains(t, msgs, "Max version of group 1: 5\n")
+	require.Contains(t, msgs, "Max version of group 2: 3\n")
+	require.NotContains(t, msgs, "diverge")
+	require.NotContains(t, restore(10, 2), "diverge")
+	msgs = restore(10, 1)
+	require.Contains(t, msgs, "Warning: the versions of the restored groups diverge")
+	require.Contains(t, msgs, "group 2 has keys up to version 3, 2 below group 1\n")
+	require.NotContains(t, msgs, "group 1 has")
+
+	// The keys of a backup can't be above its read ts.
+	msgs = restore(4, 0)
+	require.Contains(t, msgs, "group 1 has keys at version 5, above the read ts 4 of the "+
+		"backup\n")
+	require.NotContains(t, msgs, "group 2 has")
+}
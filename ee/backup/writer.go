@@ -13,29 +13,34 @@
 package backup
 
 import (
-	"encoding/binary"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"hash"
 	"io"
 	"net/url"
-	"strings"
+	"path"
+	"sort"
 
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/x"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/golang/glog"
 )
 
-// handler interface is implemented by URI scheme handlers.
-type handler interface {
-	// Handlers know how to Write and Close to their target.
-	io.WriteCloser
-	// Session receives the host and path of the target. It should get all its configuration
-	// from the environment.
-	Open(*url.URL, *Request) error
-}
-
 // writer handles the writes from stream.Orchestrate. It implements the kvStream interface.
 type writer struct {
-	h handler
+	h    handler
+	sum  hash.Hash      // SHA-256 of the data written so far
+	enc  *encryptWriter // encrypts the KVs, nil if the backup isn't encrypted
+	comp io.WriteCloser // compresses the KVs, nil if the backup isn't compressed
+	w    io.Writer      // writes the KVs to both h and sum, through comp and enc if set
+
+	// preds are the predicates of the keys written so far.
+	preds map[string]struct{}
+	// started is set once the header is written, see writeHeader.
+	started bool
 }
 
 // newWriter parses the requested target URI, finds a handler and then tries to create a session.
@@ -45,21 +50,23 @@ type writer struct {
 //   /[path]?[args] (only for local or NFS)
 //
 // Target URI parts:
-//   scheme - service handler, one of: "s3", "gs", "az", "http", "file"
+//   scheme - service handler, one of: "s3", "gs", "azblob", "hdfs", "http", "file"
 //     host - remote address. ex: "dgraph.s3.amazonaws.com"
 //     path - directory, bucket or container at target. ex: "/dgraph/backups/"
 //     args - specific arguments that are ok to appear in logs.
 //
 // Global args (might not be support by all handlers):
 //     secure - true|false turn on/off TLS.
-//   compress - true|false turn on/off data compression.
+//
+// The data is compressed with the codec of the request, then encrypted if r.Key is set.
 //
 // Examples:
 //   s3://dgraph.s3.amazonaws.com/dgraph/backups?secure=true
 //   gs://dgraph/backups/
-//   as://dgraph-container/backups/
+//   azblob://dgraph/dgraph-container/backups/
+//   hdfs://namenode:9870/dgraph/backups?user=dgraph
 //   http://backups.dgraph.io/upload
-//   file:///tmp/dgraph/backups or /tmp/dgraph/backups?compress=gzip
+//   file:///tmp/dgraph/backups or /tmp/dgraph/backups
 func (r *Request) newWriter() (*writer, error) {
 	uri, err := url.Parse(r.Backup.Target)
 	if err != nil {
@@ -67,50 +74,91 @@ func (r *Request) newWriter() (*writer, error) {
 	}
 
 	// find handler for this URI scheme
-	var h handler
-	switch uri.Scheme {
-	case "file":
-		h = &fileHandler{}
-	case "s3":
-		h = &s3Handler{}
-	case "http", "https":
-		if strings.HasPrefix(uri.Host, "s3") &&
-			strings.HasSuffix(uri.Host, ".amazonaws.com") {
-			h = &s3Handler{}
-		}
-	}
+	h := getHandler(uri, nil)
 	if h == nil {
 		return nil, x.Errorf("Unable to handle url: %v", uri)
 	}
 
-	if err := h.Open(uri, r); err != nil {
+	// The location is: /[path]/dgraph.20181106.011302/r110001-g1.backup
+	name := path.Join(backupDir(r.Backup.UnixTs), backupName(r.Backup.ReadTs, r.Backup.GroupId))
+	if err := h.Create(uri, name); err != nil {
 		return nil, err
 	}
+	glog.Infof("Backup: writing %q, estimated size %s", name, humanize.Bytes(r.Sizex))
 
-	return &writer{h: h}, nil
+	sum := sha256.New()
+	w := &writer{
+		h:     h,
+		sum:   sum,
+		w:     io.MultiWriter(r.Limit.Writer(h), sum, countWriter{x.BackupBytes}),
+		preds: make(map[string]struct{}),
+	}
+	if r.Key != nil {
+		if w.enc, err = newEncryptWriter(w.w, r.Key); err != nil {
+			x.Ignore(h.Close())
+			return nil, err
+		}
+		w.w = w.enc
+	}
+	if w.comp, err = newCompressor(r.Backup.Compression, w.w); err != nil {
+		x.Ignore(h.Close())
+		return nil, err
+	}
+	if w.comp != nil {
+		w.w = w.comp
+	}
+	return w, nil
 }
 
 func (w *writer) flush() error {
 	glog.V(2).Infof("Backup closing handler.")
+	if w.comp != nil {
+		if err := w.comp.Close(); err != nil {
+			x.Ignore(w.h.Close())
+			return err
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			x.Ignore(w.h.Close())
+			return err
+		}
+	}
 	return w.h.Close()
 }
 
-// write uses the data length as delimiter.
-// XXX: we could use CRC for restore.
+// checksum returns the hex SHA-256 of the data written.
+func (w *writer) checksum() string {
+	return hex.EncodeToString(w.sum.Sum(nil))
+}
+
+// predicates returns the predicates of the keys written, sorted.
+func (w *writer) predicates() []string {
+	preds := make([]string, 0, len(w.preds))
+	for attr := range w.preds {
+		preds = append(preds, attr)
+	}
+	sort.Strings(preds)
+	return preds
+}
+
+// write writes kv in its own frame, after the header of the file if it's the first one.
 func (w *writer) write(kv *pb.KV) error {
-	if err := binary.Write(w.h, binary.LittleEndian, uint64(kv.Size())); err != nil {
-		return err
+	if !w.started {
+		if err := writeHeader(w.w); err != nil {
+			return err
+		}
+		w.started = true
 	}
 	b, err := kv.Marshal()
 	if err != nil {
 		return err
 	}
-	_, err = w.h.Write(b)
-	return err
+	return writeFrame(w.w, b)
 }
 
 // Send implements the stream.kvStream interface.
-// It writes the received KV to the target as a delimited binary chain.
+// It writes the received KVs to the target in the version 2 format, see formatVersion.
 // Returns error if the writing fails, nil on success.
 func (w *writer) Send(kvs *pb.KVS) error {
 	var err error
@@ -119,6 +167,21 @@ func (w *writer) Send(kvs *pb.KVS) error {
 		if err != nil {
 			return err
 		}
+		// A drop of all the data has no predicate.
+		if pk := x.Parse(kv.Key); pk != nil && pk.Attr != "" {
+			w.preds[pk.Attr] = struct{}{}
+		}
 	}
+	x.BackupKeys.Add(int64(len(kvs.Kv)))
 	return nil
 }
+
+// countWriter counts the bytes written through it in c, e.g. in the backup metrics.
+type countWriter struct {
+	c *expvar.Int
+}
+
+func (cw countWriter) Write(b []byte) (int, error) {
+	cw.c.Add(int64(len(b)))
+	return len(b), nil
+}
@@ -0,0 +1,177 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/gogo/protobuf/jsonpb"
+)
+
+// zeroStateName is the name of the file the Zero state of a restore is written to, next to
+// its pN directories. Zero reads it with --restore_state.
+const zeroStateName = "zero_state.json"
+
+// zeroState collects the state Zero needs to serve the data of a restore: the group of each
+// predicate, and the highest UID and commit ts used by the data, so they aren't leased again.
+// It's safe for concurrent use.
+type zeroState struct {
+	sync.Mutex
+	tablets  map[string]*pb.Tablet
+	readTs   map[string]uint64 // ts of the backup each tablet was found in
+	maxUid   uint64
+	maxTxnTs uint64
+}
+
+func newZeroState() *zeroState {
+	return &zeroState{tablets: make(map[string]*pb.Tablet), readTs: make(map[string]uint64)}
+}
+
+// fileState collects the state of a single backup file, before it's merged into a zeroState.
+// It's saved in the checkpoints of the file, so it's exported to JSON.
+type fileState struct {
+	Groups   map[string]uint32 `json:"groups,omitempty"`
+	MaxUid   uint64            `json:"max_uid"`
+	MaxTxnTs uint64            `json:"max_txn_ts"`
+	Stats    statsSet          `json:"stats,omitempty"` // set to count them, see --stats_csv
+}
+
+// copy returns a copy of fs that doesn't share its groups or stats.
+func (fs *fileState) copy() fileState {
+	c := *fs
+	c.Groups = make(map[string]uint32, len(fs.Groups))
+	for attr, gid := range fs.Groups {
+		c.Groups[attr] = gid
+	}
+	c.Stats = fs.Stats.copy()
+	return c
+}
+
+// add records the key pk of kv, restored into group gid.
+func (fs *fileState) add(pk *x.ParsedKey, kv *pb.KV, gid uint32) error {
+	if fs.Groups == nil {
+		fs.Groups = make(map[string]uint32)
+	}
+	fs.Groups[pk.Attr] = gid
+	fs.MaxTxnTs = x.Max(fs.MaxTxnTs, kv.Version)
+	st := fs.Stats.get(pk.Attr)
+	if st != nil {
+		st.Bytes += int64(len(kv.Key) + len(kv.Val))
+	}
+	if !pk.IsData() && !pk.IsReverse() {
+		return nil
+	}
+	// The subject of a data key and the object of a reverse key, and the UIDs they point to.
+	fs.MaxUid = x.Max(fs.MaxUid, pk.Uid)
+	if len(kv.UserMeta) == 0 || kv.UserMeta[0]&posting.BitCompletePosting == 0 {
+		return nil
+	}
+	var pl pb.PostingList
+	if err := pl.Unmarshal(kv.Val); err != nil {
+		return x.Wrapf(err, "while decoding posting list of key %q", kv.Key)
+	}
+	// The pack has the UIDs of all the postings, the value postings included.
+	if n := codec.ExactLen(pl.Pack); st != nil && pk.IsData() && n > 0 {
+		st.Triples += int64(n)
+		st.Subjects++
+	}
+	var pitr posting.PIterator
+	for pitr.Init(&pl, 0); pitr.Valid(); pitr.Next() {
+		// The UIDs of value postings are fingerprints of their language.
+		if p := pitr.Posting(); p.PostingType == pb.Posting_REF {
+			fs.MaxUid = x.Max(fs.MaxUid, p.Uid)
+		}
+	}
+	return nil
+}
+
+// merge adds the state of a file of the backup taken at readTs. A predicate found in the
+// files of more than one group belongs to the group of the latest backup.
+func (zs *zeroState) merge(fs *fileState, readTs uint64) {
+	zs.Lock()
+	defer zs.Unlock()
+	for attr, gid := range fs.Groups {
+		if readTs < zs.readTs[attr] {
+			continue
+		}
+		zs.tablets[attr] = &pb.Tablet{GroupId: gid, Predicate: attr}
+		zs.readTs[attr] = readTs
+	}
+	zs.maxUid = x.Max(zs.maxUid, fs.MaxUid)
+	zs.maxTxnTs = x.Max(zs.maxTxnTs, fs.MaxTxnTs)
+}
+
+// groups returns the groups of the tablets, sorted.
+func (zs *zeroState) groups() []uint32 {
+	zs.Lock()
+	defer zs.Unlock()
+	seen := make(map[uint32]bool)
+	var gids []uint32
+	for _, tablet := range zs.tablets {
+		if !seen[tablet.GroupId] {
+			seen[tablet.GroupId] = true
+			gids = append(gids, tablet.GroupId)
+		}
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	return gids
+}
+
+// maxReadTs returns the read ts of the latest backup the tablets were found in.
+func (zs *zeroState) maxReadTs() uint64 {
+	zs.Lock()
+	defer zs.Unlock()
+	var max uint64
+	for _, ts := range zs.readTs {
+		max = x.Max(max, ts)
+	}
+	return max
+}
+
+// write writes the state to zeroStateName in pdir, as a pb.MembershipState in the JSON format
+// of the /state endpoint of Zero.
+func (zs *zeroState) write(pdir string) error {
+	zs.Lock()
+	defer zs.Unlock()
+	state := &pb.MembershipState{
+		Groups:     make(map[uint32]*pb.Group),
+		MaxLeaseId: zs.maxUid,
+		MaxTxnTs:   zs.maxTxnTs,
+	}
+	for attr, tablet := range zs.tablets {
+		group, ok := state.Groups[tablet.GroupId]
+		if !ok {
+			group = &pb.Group{Tablets: make(map[string]*pb.Tablet)}
+			state.Groups[tablet.GroupId] = group
+		}
+		group.Tablets[attr] = tablet
+	}
+	f, err := os.Create(filepath.Join(pdir, zeroStateName))
+	if err != nil {
+		return err
+	}
+	m := jsonpb.Marshaler{Indent: "  "}
+	if err := m.Marshal(f, state); err != nil {
+		x.Ignore(f.Close())
+		return err
+	}
+	return f.Close()
+}
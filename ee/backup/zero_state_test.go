@@ -0,0 +1,82 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package backup
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZeroState(t *testing.T) {
+	list := func(uids ...uint64) *pb.PostingList {
+		enc := codec.Encoder{BlockSize: 10}
+		for _, uid := range uids {
+			enc.Add(uid)
+		}
+		return &pb.PostingList{Pack: enc.Done()}
+	}
+	kv := func(key []byte, pl *pb.PostingList, version uint64) *pb.KV {
+		val, err := pl.Marshal()
+		require.NoError(t, err)
+		return &pb.KV{Key: key, Val: val, UserMeta: []byte{posting.BitCompletePosting},
+			Version: version}
+	}
+	// The lang value posting has a fingerprint as UID.
+	name := list(math.MaxUint64 - 1)
+	name.Postings = []*pb.Posting{{Uid: math.MaxUint64 - 1, Value: []byte("a"),
+		LangTag: []byte("en"), PostingType: pb.Posting_VALUE_LANG}}
+
+	var fs1, fs2 fileState
+	for _, kv := range []*pb.KV{
+		kv(x.DataKey("friend", 3), list(7, 20), 4),
+		kv(x.ReverseKey("friend", 30), list(3), 4),
+		kv(x.DataKey("name", 3), name, 6),
+		kv(x.IndexKey("name", "a"), list(3, 50), 6),
+	} {
+		require.NoError(t, fs1.add(x.Parse(kv.Key), kv, 1))
+	}
+	k := kv(x.DataKey("friend", 3), list(7), 8)
+	require.NoError(t, fs2.add(x.Parse(k.Key), k, 2))
+
+	zs := newZeroState()
+	// The latest backup wins, whatever the order the files are loaded in.
+	zs.merge(&fs2, 20)
+	zs.merge(&fs1, 10)
+
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, zs.write(dir))
+
+	f, err := os.Open(filepath.Join(dir, zeroStateName))
+	require.NoError(t, err)
+	defer f.Close()
+	var state pb.MembershipState
+	require.NoError(t, jsonpb.Unmarshal(f, &state))
+	require.Equal(t, uint64(30), state.MaxLeaseId)
+	require.Equal(t, uint64(8), state.MaxTxnTs)
+	require.Equal(t, uint32(2), state.Groups[2].Tablets["friend"].GroupId)
+	require.Equal(t, uint32(1), state.Groups[1].Tablets["name"].GroupId)
+	require.Len(t, state.Groups[1].Tablets, 1)
+}
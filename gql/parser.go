@@ -18,6 +18,7 @@ package gql
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strconv"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/dgraph-io/dgraph/lex"
 	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/types"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/golang/glog"
 )
@@ -63,6 +65,7 @@ type GraphQuery struct {
 	Facets       *pb.FacetParams
 	FacetsFilter *FilterTree
 	GroupbyAttrs []GroupByAttr
+	Having       *FilterTree // Filter on the aggregates of the groups of @groupby.
 	FacetVar     map[string]string
 	FacetOrder   string
 	FacetDesc    bool
@@ -85,6 +88,11 @@ type GraphQuery struct {
 type RecurseArgs struct {
 	Depth     uint64
 	AllowLoop bool
+	// Levels are the predicates followed at each depth, the last ones for all deeper levels.
+	// Predicates in none of them are followed at every depth.
+	Levels [][]string
+	// Truncated marks the nodes whose edges were dropped to cut a cycle.
+	Truncated bool
 }
 
 type GroupByAttr struct {
@@ -200,6 +208,12 @@ func (f *Function) IsPasswordVerifier() bool {
 	return f.Name == "checkpwd"
 }
 
+// IsGeoDistance returns whether the function is distance(loc, [lng, lat]), the distance from
+// the point to the geo value of loc.
+func (f *Function) IsGeoDistance() bool {
+	return f.Name == "distance"
+}
+
 // DebugPrint is useful for debugging.
 func (gq *GraphQuery) DebugPrint(prefix string) {
 	glog.Infof("%s[%x %q %q]\n", prefix, gq.UID, gq.Attr, gq.Alias)
@@ -208,6 +222,17 @@ func (gq *GraphQuery) DebugPrint(prefix string) {
 	}
 }
 
+// Depth returns the number of nested levels of the block, one for a block without children.
+func (gq *GraphQuery) Depth() int {
+	depth := 0
+	for _, child := range gq.Children {
+		if d := child.Depth(); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
 func (gq *GraphQuery) isFragment() bool {
 	return gq.fragment != ""
 }
@@ -297,29 +322,23 @@ func checkValueType(vm varMap) error {
 		}
 
 		// Type check the values.
-		if v.Value != "" {
-			switch typ {
-			case "int":
-				{
-					if _, err := strconv.ParseInt(v.Value, 0, 64); err != nil {
-						return x.Wrapf(err, "Expected an int but got %v", v.Value)
-					}
-				}
-			case "float":
-				{
-					if _, err := strconv.ParseFloat(v.Value, 64); err != nil {
-						return x.Wrapf(err, "Expected a float but got %v", v.Value)
-					}
-				}
-			case "bool":
-				{
-					if _, err := strconv.ParseBool(v.Value); err != nil {
-						return x.Wrapf(err, "Expected a bool but got %v", v.Value)
-					}
-				}
-			case "string": // Value is a valid string. No checks required.
-			default:
-				return x.Errorf("Type %v not supported", typ)
+		if v.Value == "" {
+			continue
+		}
+		elemTyp, isList := listElemType(typ)
+		if !isList {
+			if err := checkScalarValue(typ, v.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		vals, err := listValues(v.Value)
+		if err != nil {
+			return x.Wrapf(err, "Expected a list for variable %v but got %v", k, v.Value)
+		}
+		for _, val := range vals {
+			if err := checkScalarValue(elemTyp, val); err != nil {
+				return err
 			}
 		}
 	}
@@ -327,33 +346,210 @@ func checkValueType(vm varMap) error {
 	return nil
 }
 
+func checkScalarValue(typ, val string) error {
+	switch typ {
+	case "int":
+		if _, err := strconv.ParseInt(val, 0, 64); err != nil {
+			return x.Wrapf(err, "Expected an int but got %v", val)
+		}
+	case "float":
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			return x.Wrapf(err, "Expected a float but got %v", val)
+		}
+	case "bool":
+		if _, err := strconv.ParseBool(val); err != nil {
+			return x.Wrapf(err, "Expected a bool but got %v", val)
+		}
+	case "datetime":
+		if _, err := types.ParseTime(val); err != nil {
+			return x.Wrapf(err, "Expected a datetime but got %v", val)
+		}
+	case "string": // Value is a valid string. No checks required.
+	default:
+		return x.Errorf("Type %v not supported", typ)
+	}
+	return nil
+}
+
+// listElemType returns the type of the elements of a list type, like string for [string].
+func listElemType(typ string) (string, bool) {
+	typ = strings.TrimSuffix(typ, "!")
+	if len(typ) < 2 || typ[0] != '[' || typ[len(typ)-1] != ']' {
+		return "", false
+	}
+	return typ[1 : len(typ)-1], true
+}
+
+// listValues returns the values of a list variable, given as a JSON array of strings, numbers
+// or booleans.
+func listValues(val string) ([]string, error) {
+	var elems []interface{}
+	dec := json.NewDecoder(strings.NewReader(val))
+	dec.UseNumber()
+	if err := dec.Decode(&elems); err != nil {
+		return nil, err
+	}
+	vals := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		v, err := scalarVarValue(elem)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+func scalarVarValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", x.Errorf("Expected a string, number or boolean but got %v", v)
+}
+
+// ParseVariables parses the values of the GraphQL variables of a query, given as a JSON
+// object of strings, numbers, booleans, or lists of those, into the values of a Request. The
+// names of the variables can leave out the $.
+func ParseVariables(js []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, x.Wrapf(err, "Expected the variables as a JSON object")
+	}
+	vars := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if !strings.HasPrefix(k, "$") {
+			k = "$" + k
+		}
+		switch v := v.(type) {
+		case nil:
+			// Left out, as if not given.
+			continue
+		case []interface{}:
+			for _, elem := range v {
+				if _, err := scalarVarValue(elem); err != nil {
+					return nil, x.Wrapf(err, "Invalid value in list variable %s", k)
+				}
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			vars[k] = string(b)
+		default:
+			val, err := scalarVarValue(v)
+			if err != nil {
+				return nil, x.Wrapf(err, "Invalid value of variable %s", k)
+			}
+			vars[k] = val
+		}
+	}
+	return vars, nil
+}
+
 func substituteVar(f string, res *string, vmap varMap) error {
 	if len(f) > 0 && f[0] == '$' {
 		va, ok := vmap[f]
 		if !ok || va.Type == "" {
 			return x.Errorf("Variable not defined %v", f)
 		}
+		if _, isList := listElemType(va.Type); isList {
+			return x.Errorf("List variable %v can only be used as the argument of a function", f)
+		}
 		*res = va.Value
 	}
 	return nil
 }
 
-func substituteVariables(gq *GraphQuery, vmap varMap) error {
-	for k, v := range gq.Args {
-		// v won't be empty as its handled in parseGqlVariables.
-		val := gq.Args[k]
-		if err := substituteVar(v, &val, vmap); err != nil {
-			return err
+// varValues returns the values of the GraphQL variable f, one per element for a list variable.
+func varValues(f string, vmap varMap) ([]string, error) {
+	va, ok := vmap[f]
+	if !ok {
+		return nil, x.Errorf("Couldn't find value for GraphQL variable: [%s]", f)
+	}
+	if _, isList := listElemType(va.Type); !isList {
+		return []string{va.Value}, nil
+	}
+	if va.Value == "" {
+		return nil, nil
+	}
+	return listValues(va.Value)
+}
+
+// parseIDs parses the uids given by the GraphQL variable f.
+func parseIDs(f string, vmap varMap) ([]uint64, error) {
+	vals, err := varValues(f, vmap)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, x.Errorf("Id can't be empty")
+	}
+	var uids []uint64
+	for _, val := range vals {
+		if val == "" {
+			return nil, x.Errorf("Id can't be empty")
+		}
+		ids, err := parseID(val)
+		if err != nil {
+			return nil, err
+		}
+		uids = append(uids, ids...)
+	}
+	return uids, nil
+}
+
+// substituteArgs returns args with the GraphQL variables replaced by their values, a list
+// variable giving one argument per element.
+func substituteArgs(fn string, args []Arg, vmap varMap) ([]Arg, error) {
+	out := make([]Arg, 0, len(args))
+	for _, arg := range args {
+		if !arg.IsGraphQLVar {
+			out = append(out, arg)
+			continue
+		}
+		if va, ok := vmap[arg.Value]; !ok || va.Type == "" {
+			return nil, x.Errorf("Variable not defined %v", arg.Value)
+		}
+		vals, err := varValues(arg.Value, vmap)
+		if err != nil {
+			return nil, err
+		}
+		if fn == "regexp" {
+			if len(vals) != 1 {
+				return nil, x.Errorf("Expected one regular expression in %s", arg.Value)
+			}
+			// Value should have been populated from the map that the user gave us in the
+			// GraphQL variable map. Let's parse the expression and flags from the variable
+			// string.
+			ra, err := parseRegexArgs(vals[0])
+			if err != nil {
+				return nil, err
+			}
+			// Regex functions should have two args, the expression and the flags.
+			out = append(out, Arg{Value: ra.expr, IsGraphQLVar: true}, Arg{Value: ra.flags})
+			continue
+		}
+		for _, val := range vals {
+			out = append(out, Arg{Value: val, IsGraphQLVar: true})
 		}
-		gq.Args[k] = val
 	}
+	return out, nil
+}
 
+func substituteVariables(gq *GraphQuery, vmap varMap) error {
 	idVal, ok := gq.Args["id"]
 	if ok && len(gq.UID) == 0 {
-		if idVal == "" {
-			return x.Errorf("Id can't be empty")
+		if va, ok := vmap[idVal]; !ok || va.Type == "" {
+			return x.Errorf("Variable not defined %v", idVal)
 		}
-		uids, err := parseID(idVal)
+		uids, err := parseIDs(idVal, vmap)
 		if err != nil {
 			return err
 		}
@@ -362,32 +558,24 @@ func substituteVariables(gq *GraphQuery, vmap varMap) error {
 		delete(gq.Args, "id")
 	}
 
+	for k, v := range gq.Args {
+		// v won't be empty as its handled in parseGqlVariables.
+		val := gq.Args[k]
+		if err := substituteVar(v, &val, vmap); err != nil {
+			return err
+		}
+		gq.Args[k] = val
+	}
+
 	if gq.Func != nil {
 		if err := substituteVar(gq.Func.Attr, &gq.Func.Attr, vmap); err != nil {
 			return err
 		}
-
-		for idx, v := range gq.Func.Args {
-			if !v.IsGraphQLVar {
-				continue
-			}
-			if err := substituteVar(v.Value, &gq.Func.Args[idx].Value, vmap); err != nil {
-				return err
-			}
-			if gq.Func.Name == "regexp" {
-				// Value should have been populated from the map that the user gave us in the
-				// GraphQL variable map. Let's parse the expression and flags from the variable
-				// string.
-				ra, err := parseRegexArgs(gq.Func.Args[idx].Value)
-				if err != nil {
-					return err
-				}
-				// We modify the value of this arg and add a new arg for the flags. Regex functions
-				// should have two args.
-				gq.Func.Args[idx].Value = ra.expr
-				gq.Func.Args = append(gq.Func.Args, Arg{Value: ra.flags})
-			}
+		args, err := substituteArgs(gq.Func.Name, gq.Func.Args, vmap)
+		if err != nil {
+			return err
 		}
+		gq.Func.Args = args
 	}
 
 	for _, child := range gq.Children {
@@ -409,27 +597,21 @@ func substituteVariablesFilter(f *FilterTree, vmap varMap) error {
 			return err
 		}
 
-		for idx, v := range f.Func.Args {
-			if f.Func.Name == uid {
-				// This is to support GraphQL variables in uid functions.
-				idVal, ok := vmap[v.Value]
-				if !ok {
-					return x.Errorf("Couldn't find value for GraphQL variable: [%s]", v.Value)
-				}
-				if idVal.Value == "" {
-					return x.Errorf("Id can't be empty")
-				}
-				uids, err := parseID(idVal.Value)
+		if f.Func.Name == uid {
+			// This is to support GraphQL variables in uid functions.
+			for _, v := range f.Func.Args {
+				uids, err := parseIDs(v.Value, vmap)
 				if err != nil {
 					return err
 				}
 				f.Func.UID = append(f.Func.UID, uids...)
-				continue
 			}
-
-			if err := substituteVar(v.Value, &f.Func.Args[idx].Value, vmap); err != nil {
+		} else {
+			args, err := substituteArgs(f.Func.Name, f.Func.Args, vmap)
+			if err != nil {
 				return err
 			}
+			f.Func.Args = args
 		}
 	}
 
@@ -459,6 +641,12 @@ type Result struct {
 // Parse initializes and runs the lexer. It also constructs the GraphQuery subgraph
 // from the lexed items.
 func Parse(r Request) (res Result, rerr error) {
+	return parse(r, nil)
+}
+
+// parse is Parse, with needs the variables used outside of the query, like in the conditions
+// of an upsert.
+func parse(r Request, needs []string) (res Result, rerr error) {
 	query := r.Str
 	vmap := convertToVarMap(r.Variables)
 
@@ -538,6 +726,9 @@ func Parse(r Request) (res Result, rerr error) {
 		}
 
 		allVars := res.QueryVars
+		if len(needs) > 0 {
+			allVars = append(allVars[:len(allVars):len(allVars)], &Vars{Needs: needs})
+		}
 		if err := checkDependency(allVars); err != nil {
 			return res, err
 		}
@@ -702,6 +893,28 @@ L2:
 	return gq, nil
 }
 
+// parseRecurseLevels parses the predicates followed at each depth of @recurse, given as
+// levels: "friend, follows; works_at", with the levels separated by semicolons.
+func parseRecurseLevels(val string) ([][]string, error) {
+	val, err := unquoteIfQuoted(val)
+	if err != nil {
+		return nil, err
+	}
+	var levels [][]string
+	for _, level := range strings.Split(val, ";") {
+		var preds []string
+		for _, pred := range strings.Split(level, ",") {
+			pred = strings.TrimSpace(pred)
+			if pred == "" {
+				return nil, x.Errorf("Expected predicate names in levels of @recurse, got: %q", val)
+			}
+			preds = append(preds, pred)
+		}
+		levels = append(levels, preds)
+	}
+	return levels, nil
+}
+
 func parseRecurseArgs(it *lex.ItemIterator, gq *GraphQuery) error {
 	if ok := trySkipItemTyp(it, itemLeftRound); !ok {
 		// We don't have a (, we can return.
@@ -740,6 +953,18 @@ func parseRecurseArgs(it *lex.ItemIterator, gq *GraphQuery) error {
 				return err
 			}
 			gq.RecurseArgs.AllowLoop = allowLoop
+		case "levels":
+			levels, err := parseRecurseLevels(val)
+			if err != nil {
+				return err
+			}
+			gq.RecurseArgs.Levels = levels
+		case "truncated":
+			truncated, err := strconv.ParseBool(val)
+			if err != nil {
+				return err
+			}
+			gq.RecurseArgs.Truncated = truncated
 		default:
 			return fmt.Errorf("Unexpected key: [%s] inside @recurse block", key)
 		}
@@ -798,7 +1023,13 @@ L:
 				gq.Cascade = true
 			case "groupby":
 				gq.IsGroupby = true
-				parseGroupby(it, gq)
+				if err := parseGroupby(it, gq); err != nil {
+					return nil, err
+				}
+			case "having":
+				if err := parseHaving(it, gq); err != nil {
+					return nil, err
+				}
 			case "ignorereflex":
 				gq.IgnoreReflex = true
 			case "recurse":
@@ -1006,6 +1237,12 @@ func parseGqlVariables(it *lex.ItemIterator, vmap varMap) error {
 		// Get variable type.
 		it.Next()
 		item = it.Item()
+		isList := item.Typ == itemLeftSquare
+		if isList {
+			// A list of values, like [string].
+			it.Next()
+			item = it.Item()
+		}
 		if item.Typ != itemName {
 			return x.Errorf("Expecting a variable type. Got: %v", item)
 		}
@@ -1015,6 +1252,12 @@ func parseGqlVariables(it *lex.ItemIterator, vmap varMap) error {
 		if varType == "" {
 			return x.Errorf("Type of a variable can't be empty")
 		}
+		if isList {
+			if _, ok := tryParseItemType(it, itemRightSquare); !ok {
+				return x.Errorf("Expecting ] after the type of list variable %s", varName)
+			}
+			varType = "[" + varType + "]"
+		}
 		it.Next()
 		item = it.Item()
 		if item.Typ == itemMathOp && item.Val == "!" {
@@ -1474,7 +1717,7 @@ L:
 				continue
 				// Lets reassemble the geo tokens.
 			} else if itemInFunc.Typ == itemLeftSquare {
-				isGeo := isGeoFunc(function.Name)
+				isGeo := isGeoFunc(function.Name) || function.IsGeoDistance()
 				if !isGeo && !isInequalityFn(function.Name) {
 					return nil, x.Errorf("Unexpected character [ while parsing request.")
 				}
@@ -1862,6 +2105,49 @@ func parseGroupby(it *lex.ItemIterator, gq *GraphQuery) error {
 }
 
 // parseFilter parses the filter directive to produce a QueryFilter / parse tree.
+// parseHaving parses @having, filtering the groups of the preceding @groupby on their
+// aggregates, like @having(gt(count, 10) and lt(avg_age, 30)). The aggregates are named by
+// their alias, or count.
+func parseHaving(it *lex.ItemIterator, gq *GraphQuery) error {
+	if !gq.IsGroupby {
+		return x.Errorf("@having is only allowed after @groupby")
+	}
+	if gq.Having != nil {
+		return x.Errorf("Use AND, OR and round brackets instead of multiple having directives.")
+	}
+	having, err := parseFilter(it)
+	if err != nil {
+		return err
+	}
+	if err := checkHaving(having); err != nil {
+		return err
+	}
+	gq.Having = having
+	return nil
+}
+
+func checkHaving(ft *FilterTree) error {
+	for _, ch := range ft.Child {
+		if err := checkHaving(ch); err != nil {
+			return err
+		}
+	}
+	f := ft.Func
+	if f == nil {
+		return nil
+	}
+	if !isInequalityFn(f.Name) {
+		return x.Errorf("Only eq, le, ge, lt and gt are allowed in @having. Got: %s", f.Name)
+	}
+	if f.IsCount || f.IsValueVar || len(f.NeedsVar) > 0 || f.Lang != "" {
+		return x.Errorf("Expected the name of an aggregate in %s of @having", f.Name)
+	}
+	if len(f.Args) != 1 || f.Args[0].IsGraphQLVar {
+		return x.Errorf("Expected one value to compare %s with in @having", f.Attr)
+	}
+	return nil
+}
+
 func parseFilter(it *lex.ItemIterator) (*FilterTree, error) {
 	it.Next()
 	item := it.Item()
@@ -2089,7 +2375,13 @@ func parseDirective(it *lex.ItemIterator, curp *GraphQuery) error {
 				return x.Errorf("Only one group by directive allowed.")
 			}
 			curp.IsGroupby = true
-			parseGroupby(it, curp)
+			if err := parseGroupby(it, curp); err != nil {
+				return err
+			}
+		case "having":
+			if err := parseHaving(it, curp); err != nil {
+				return err
+			}
 		default:
 			return x.Errorf("Unknown directive [%s]", item.Val)
 		}
@@ -2137,7 +2429,7 @@ func validKeyAtRoot(k string) bool {
 	switch k {
 	case "func", "orderasc", "orderdesc", "first", "offset", "after":
 		return true
-	case "from", "to", "numpaths":
+	case "from", "to", "numpaths", "weightFacet":
 		// Specific to shortest path
 		return true
 	case "depth":
@@ -2332,6 +2624,12 @@ func getRoot(it *lex.ItemIterator) (gq *GraphQuery, rerr error) {
 			}
 
 		ASSIGN:
+			if key == "weightFacet" || key == "after" {
+				// A cursor given to after is quoted.
+				if val, err = unquoteIfQuoted(val); err != nil {
+					return nil, err
+				}
+			}
 			if _, ok := gq.Args[key]; ok {
 				return gq, x.Errorf("Repeated key %q at root", key)
 			}
@@ -2449,7 +2747,8 @@ func godeep(it *lex.ItemIterator, gq *GraphQuery) error {
 				}
 			}
 
-			if valLower == "checkpwd" {
+			isDistance := valLower == "distance" && peekIt[0].Typ == itemLeftRound
+			if valLower == "checkpwd" || isDistance {
 				child := &GraphQuery{
 					Args:  make(map[string]string),
 					Var:   varName,
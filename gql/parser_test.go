@@ -1076,6 +1076,52 @@ func TestParseShortestPath(t *testing.T) {
 	require.Equal(t, "3", res.Query[0].Args["numpaths"])
 }
 
+func TestParseShortestPathWeightFacet(t *testing.T) {
+	query := `
+	{
+		shortest(from:0x0a, to:0x0b, weightFacet: "distance") {
+			roads
+		}
+	}
+`
+	res, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	require.Equal(t, "distance", res.Query[0].Args["weightFacet"])
+}
+
+func TestParseRecurseLevels(t *testing.T) {
+	query := `
+	{
+		me(func: uid(0x0a)) @recurse(depth: 4, levels: "derived_from, copied_from; owned_by", truncated: true) {
+			derived_from
+			copied_from
+			owned_by
+			name
+		}
+	}
+`
+	res, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	args := res.Query[0].RecurseArgs
+	require.Equal(t, uint64(4), args.Depth)
+	require.Equal(t, [][]string{{"derived_from", "copied_from"}, {"owned_by"}}, args.Levels)
+	require.True(t, args.Truncated)
+}
+
+func TestParseRecurseLevelsEmpty(t *testing.T) {
+	query := `
+	{
+		me(func: uid(0x0a)) @recurse(levels: "friend;;follows") {
+			friend
+			follows
+		}
+	}
+`
+	_, err := Parse(Request{Str: query})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Expected predicate names in levels of @recurse")
+}
+
 func TestParseMultipleQueries(t *testing.T) {
 	query := `
 	{
@@ -1859,6 +1905,73 @@ func TestParseVariablesError1(t *testing.T) {
 	require.Contains(t, err.Error(), "should be initialised")
 }
 
+func TestParseVariablesJSON(t *testing.T) {
+	vars, err := ParseVariables([]byte(`{"$name": "Alice", "age": 25, "score": 1.5,
+		"alive": true, "names": ["Alice", "Bob"], "missing": null}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"$name":  "Alice",
+		"$age":   "25",
+		"$score": "1.5",
+		"$alive": "true",
+		"$names": `["Alice","Bob"]`,
+	}, vars)
+
+	_, err = ParseVariables([]byte(`{"$a": {"b": 1}}`))
+	require.Error(t, err)
+	_, err = ParseVariables([]byte(`{"$a": [["b"]]}`))
+	require.Error(t, err)
+}
+
+func TestParseTypedVariables(t *testing.T) {
+	query := `
+	query test($names: [string], $ids: [int]!, $since: datetime, $n: int) {
+		me(func: eq(name, $names), first: $n) @filter(uid($ids) and ge(dob, $since)) {
+			name
+		}
+	}
+	`
+	vars, err := ParseVariables([]byte(`{"names": ["Alice", "Bob"], "ids": [1, 2],
+		"since": "2018-01-01", "n": 10}`))
+	require.NoError(t, err)
+	res, err := Parse(Request{Str: query, Variables: vars})
+	require.NoError(t, err)
+	gq := res.Query[0]
+	require.Equal(t, []Arg{{Value: "Alice", IsGraphQLVar: true}, {Value: "Bob", IsGraphQLVar: true}},
+		gq.Func.Args)
+	require.Equal(t, "10", gq.Args["first"])
+	require.Equal(t, []uint64{1, 2}, gq.Filter.Child[0].Func.UID)
+	require.Equal(t, "2018-01-01", gq.Filter.Child[1].Func.Args[0].Value)
+}
+
+func TestParseTypedVariablesError(t *testing.T) {
+	query := `
+	query test($since: datetime) {
+		me(func: ge(dob, $since)) {
+			name
+		}
+	}
+	`
+	_, err := Parse(Request{Str: query, Variables: map[string]string{"$since": "yesterday"}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Expected a datetime")
+
+	query = `
+	query test($ids: [int]) {
+		me(func: uid(1), first: $ids) {
+			name
+		}
+	}
+	`
+	_, err = Parse(Request{Str: query, Variables: map[string]string{"$ids": `["a"]`}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Expected an int")
+
+	_, err = Parse(Request{Str: query, Variables: map[string]string{"$ids": `[1]`}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can only be used as the argument of a function")
+}
+
 func TestParseFilter_root(t *testing.T) {
 	query := `
 	query {
@@ -2438,6 +2551,26 @@ func TestParseCheckPwd(t *testing.T) {
 	require.Equal(t, "password", gq.Query[0].Children[0].Attr)
 }
 
+func TestParseGeoDistance(t *testing.T) {
+	query := `{
+		me(func: near(loc, [1.1, 2.0], 10)) {
+			d as distance(loc, [1.1, 2.0])
+			distance
+			dist: val(d)
+		}
+	}`
+	gq, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	child := gq.Query[0].Children[0]
+	require.Equal(t, "distance", child.Func.Name)
+	require.Equal(t, "loc", child.Attr)
+	require.Equal(t, "d", child.Var)
+	require.Equal(t, "[1.1,2.0]", child.Func.Args[0].Value)
+	// A predicate named distance is still fetched as usual.
+	require.Equal(t, "distance", gq.Query[0].Children[1].Attr)
+	require.Nil(t, gq.Query[0].Children[1].Func)
+}
+
 func TestParseComments(t *testing.T) {
 	query := `
 	# Something
@@ -2926,6 +3059,59 @@ func TestParseGroupbyError(t *testing.T) {
 	require.Contains(t, err.Error(), "Only aggregator/count functions allowed inside @groupby")
 }
 
+func TestParseGroupbyHaving(t *testing.T) {
+	query := `
+	query {
+		me(func: uid(0x1)) {
+			friends @groupby(name, school) @having(gt(count, 2) and not eq(avgAge, 20)) {
+				count(uid)
+				avgAge: avg(age)
+			}
+		}
+	}
+`
+	res, err := Parse(Request{Str: query})
+	require.NoError(t, err)
+	having := res.Query[0].Children[0].Having
+	require.NotNil(t, having)
+	require.Equal(t, "and", having.Op)
+	require.Equal(t, "gt", having.Child[0].Func.Name)
+	require.Equal(t, "count", having.Child[0].Func.Attr)
+	require.Equal(t, "2", having.Child[0].Func.Args[0].Value)
+	require.Equal(t, "not", having.Child[1].Op)
+	require.Equal(t, "avgAge", having.Child[1].Child[0].Func.Attr)
+}
+
+func TestParseHavingWithoutGroupby(t *testing.T) {
+	query := `
+	query {
+		me(func: uid(0x1)) {
+			friends @having(gt(count, 2)) {
+				count(uid)
+			}
+		}
+	}
+`
+	_, err := Parse(Request{Str: query})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "@having is only allowed after @groupby")
+}
+
+func TestParseHavingError(t *testing.T) {
+	query := `
+	query {
+		me(func: uid(0x1)) {
+			friends @groupby(name) @having(anyofterms(count, "2")) {
+				count(uid)
+			}
+		}
+	}
+`
+	_, err := Parse(Request{Str: query})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Only eq, le, ge, lt and gt are allowed in @having")
+}
+
 func TestParseFacetsError1(t *testing.T) {
 	query := `
 	query {
@@ -4388,3 +4574,37 @@ func TestParseGraphQLVarPaginationRootMultiple(t *testing.T) {
 	require.Equal(t, args["after"], "0x123")
 	require.Equal(t, gq.Query[0].Order[0].Attr, "name")
 }
+
+func TestParseRootCursor(t *testing.T) {
+	q := `{
+		q(func: has(name), orderasc: name, first: 10, after: "eyJ1IjoxfQ") {
+			name
+			_cursor_
+		}
+	}`
+	gq, err := Parse(Request{Str: q})
+	require.NoError(t, err)
+	require.Equal(t, "eyJ1IjoxfQ", gq.Query[0].Args["after"])
+	require.Equal(t, "_cursor_", gq.Query[0].Children[1].Attr)
+}
+
+func TestQueryDepth(t *testing.T) {
+	q := `{
+		me(func: uid(1)) {
+			name
+			friend {
+				name
+				school {
+					name
+				}
+			}
+		}
+		you(func: uid(2)) {
+			name
+		}
+	}`
+	gq, err := Parse(Request{Str: q})
+	require.NoError(t, err)
+	require.Equal(t, 4, gq.Query[0].Depth())
+	require.Equal(t, 2, gq.Query[1].Depth())
+}
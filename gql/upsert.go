@@ -0,0 +1,383 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gql
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// Upsert is a query and the mutations applied depending on its results, in the same
+// transaction:
+//
+//	upsert {
+//	  query {
+//	    v as var(func: eq(email, "alice@example.com"))
+//	  }
+//	  mutation @if(eq(len(v), 0)) {
+//	    set {
+//	      _:alice <email> "alice@example.com" .
+//	    }
+//	  }
+//	}
+type Upsert struct {
+	Query     string
+	Mutations []*CondMutation
+}
+
+// CondMutation is a mutation of an upsert, applied if Cond holds, or always if it is nil.
+type CondMutation struct {
+	Cond     *Cond
+	Mutation *api.Mutation
+}
+
+// Cond is the condition of a mutation of an upsert, on the number of uids or values of the
+// query variables, like eq(len(v), 0). Conditions are combined with and, or and not.
+type Cond struct {
+	Op    string // and, or or not to combine Child, empty for a comparison
+	Child []*Cond
+	Func  string // eq, lt, le, gt or ge
+	Var   string
+	Value int
+}
+
+// IsUpsert returns whether the mutation text is an upsert block.
+func IsUpsert(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "upsert") &&
+		strings.HasPrefix(strings.TrimSpace(s[len("upsert"):]), "{")
+}
+
+// UidVar returns the name of the variable if s refers to the uids of a query variable, like
+// uid(v), in the subject or object of a mutation of an upsert.
+func UidVar(s string) (string, bool) {
+	if !strings.HasPrefix(s, "uid(") || !strings.HasSuffix(s, ")") {
+		return "", false
+	}
+	v := strings.TrimSpace(s[len("uid(") : len(s)-1])
+	return v, v != ""
+}
+
+// ParseUpsert parses an upsert block, with one query block and one or more mutation
+// blocks.
+func ParseUpsert(s string) (*Upsert, error) {
+	s = strings.TrimSpace(s)
+	if !IsUpsert(s) {
+		return nil, x.Errorf("Expected upsert block")
+	}
+	s = strings.TrimSpace(s[len("upsert"):])
+	body, rest, err := block(s, '{', '}')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, x.Errorf("Unexpected text after upsert block: %q", rest)
+	}
+
+	up := &Upsert{}
+	for body = strings.TrimSpace(body); body != ""; body = strings.TrimSpace(body) {
+		var name string
+		name, body = word(body)
+		body = strings.TrimSpace(body)
+		switch name {
+		case "query":
+			if up.Query != "" {
+				return nil, x.Errorf("Upsert can only have one query block")
+			}
+			if up.Query, body, err = block(body, '{', '}'); err != nil {
+				return nil, err
+			}
+			up.Query = "{" + up.Query + "}"
+		case "mutation":
+			cm := &CondMutation{}
+			if strings.HasPrefix(body, "@if") {
+				var cond string
+				body = strings.TrimSpace(body[len("@if"):])
+				if cond, body, err = block(body, '(', ')'); err != nil {
+					return nil, err
+				}
+				if cm.Cond, err = parseCond(cond); err != nil {
+					return nil, err
+				}
+				body = strings.TrimSpace(body)
+			}
+			var mu string
+			if mu, body, err = block(body, '{', '}'); err != nil {
+				return nil, err
+			}
+			if cm.Mutation, err = ParseMutation("{" + mu + "}"); err != nil {
+				return nil, err
+			}
+			up.Mutations = append(up.Mutations, cm)
+		default:
+			return nil, x.Errorf("Expected query or mutation block in upsert, got: %q", name)
+		}
+	}
+	if up.Query == "" {
+		return nil, x.Errorf("Upsert needs a query block")
+	}
+	if len(up.Mutations) == 0 {
+		return nil, x.Errorf("Upsert needs a mutation block")
+	}
+	return up, nil
+}
+
+// ParseQuery parses the query of the upsert, checking that it defines the variables used in
+// the conditions of the mutations, and the variables in needs, used in their N-Quads.
+func (up *Upsert) ParseQuery(needs ...string) (Result, error) {
+	for _, cm := range up.Mutations {
+		if cm.Cond != nil {
+			needs = cm.Cond.vars(needs)
+		}
+	}
+	res, err := parse(Request{Str: up.Query}, needs)
+	if err != nil {
+		return res, err
+	}
+	if len(res.Query) == 0 && len(needs) > 0 {
+		return res, x.Errorf("Variables used in the mutations are not defined: %v", needs)
+	}
+	return res, nil
+}
+
+// ParseCond parses a condition of a mutation, like @if(eq(len(v), 0)).
+func ParseCond(s string) (*Cond, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "@if") {
+		return nil, x.Errorf("Expected @if condition, got: %q", s)
+	}
+	cond, rest, err := block(strings.TrimSpace(s[len("@if"):]), '(', ')')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, x.Errorf("Unexpected text after condition: %q", rest)
+	}
+	return parseCond(cond)
+}
+
+// Eval returns whether the condition holds, with lens giving the number of uids or values of
+// the variables.
+func (c *Cond) Eval(lens func(v string) int) bool {
+	switch c.Op {
+	case "and":
+		for _, ch := range c.Child {
+			if !ch.Eval(lens) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, ch := range c.Child {
+			if ch.Eval(lens) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return !c.Child[0].Eval(lens)
+	}
+
+	n := lens(c.Var)
+	switch c.Func {
+	case "eq":
+		return n == c.Value
+	case "lt":
+		return n < c.Value
+	case "le":
+		return n <= c.Value
+	case "gt":
+		return n > c.Value
+	case "ge":
+		return n >= c.Value
+	}
+	return false
+}
+
+func (c *Cond) vars(vs []string) []string {
+	if c.Op == "" {
+		return append(vs, c.Var)
+	}
+	for _, ch := range c.Child {
+		vs = ch.vars(vs)
+	}
+	return vs
+}
+
+// block returns the text between the open character starting s and its matching close
+// character, skipping over quoted strings, and the text after it.
+func block(s string, open, close byte) (string, string, error) {
+	if len(s) == 0 || s[0] != open {
+		return "", "", x.Errorf("Expected %c, got: %q", open, s)
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return s[1:i], s[i+1:], nil
+			}
+		}
+	}
+	return "", "", x.Errorf("Unclosed %c in: %q", open, s)
+}
+
+// word returns the identifier starting s and the text after it.
+func word(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.'
+	})
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// condParser parses a condition, with and binding tighter than or:
+//
+//	cond := term {"or" term}
+//	term := factor {"and" factor}
+//	factor := "not" factor | "(" cond ")" | func "(" "len" "(" var ")" "," int ")"
+type condParser struct {
+	s string
+}
+
+func parseCond(s string) (*Cond, error) {
+	p := &condParser{s: s}
+	c, err := p.cond()
+	if err != nil {
+		return nil, err
+	}
+	if p.s = strings.TrimSpace(p.s); p.s != "" {
+		return nil, x.Errorf("Unexpected text in condition: %q", p.s)
+	}
+	return c, nil
+}
+
+// peek returns the next identifier, without consuming it.
+func (p *condParser) peek() string {
+	w, _ := word(strings.TrimSpace(p.s))
+	return strings.ToLower(w)
+}
+
+func (p *condParser) next() string {
+	var w string
+	w, p.s = word(strings.TrimSpace(p.s))
+	return strings.ToLower(w)
+}
+
+func (p *condParser) expect(c byte) error {
+	p.s = strings.TrimSpace(p.s)
+	if len(p.s) == 0 || p.s[0] != c {
+		return x.Errorf("Expected %c in condition, got: %q", c, p.s)
+	}
+	p.s = p.s[1:]
+	return nil
+}
+
+func (p *condParser) cond() (*Cond, error) {
+	return p.combine("or", p.term)
+}
+
+func (p *condParser) term() (*Cond, error) {
+	return p.combine("and", p.factor)
+}
+
+// combine parses operands joined by op.
+func (p *condParser) combine(op string, operand func() (*Cond, error)) (*Cond, error) {
+	c, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != op {
+		return c, nil
+	}
+	c = &Cond{Op: op, Child: []*Cond{c}}
+	for p.peek() == op {
+		p.next()
+		ch, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		c.Child = append(c.Child, ch)
+	}
+	return c, nil
+}
+
+func (p *condParser) factor() (*Cond, error) {
+	if err := p.expect('('); err == nil {
+		c, err := p.cond()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(')')
+	}
+
+	fn := p.next()
+	switch fn {
+	case "not":
+		c, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return &Cond{Op: "not", Child: []*Cond{c}}, nil
+	case "eq", "lt", "le", "gt", "ge":
+	default:
+		return nil, x.Errorf("Unsupported function in condition: %q", fn)
+	}
+
+	c := &Cond{Func: fn}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	if p.next() != "len" {
+		return nil, x.Errorf("Expected len(variable) as first argument of %s", fn)
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	if c.Var, p.s = word(strings.TrimSpace(p.s)); c.Var == "" {
+		return nil, x.Errorf("Expected variable in len of %s, got: %q", fn, p.s)
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	if err := p.expect(','); err != nil {
+		return nil, err
+	}
+	var val string
+	val, p.s = word(strings.TrimSpace(p.s))
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, x.Errorf("Expected integer as second argument of %s, got: %q", fn, val)
+	}
+	c.Value = n
+	return c, p.expect(')')
+}
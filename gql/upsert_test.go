@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpsert(t *testing.T) {
+	up, err := ParseUpsert(`
+	upsert {
+		query {
+			v as var(func: eq(email, "a}b@example.com"))
+		}
+		mutation @if(eq(len(v), 0)) {
+			set {
+				_:a <email> "a}b@example.com" .
+			}
+		}
+		mutation @if(gt(len(v), 0)) {
+			delete {
+				<0x1> <friend> <0x2> .
+			}
+		}
+	}`)
+	require.NoError(t, err)
+	require.Len(t, up.Mutations, 2)
+	require.Equal(t, &Cond{Func: "eq", Var: "v", Value: 0}, up.Mutations[0].Cond)
+	require.Contains(t, string(up.Mutations[0].Mutation.SetNquads),
+		`_:a <email> "a}b@example.com" .`)
+	require.Equal(t, &Cond{Func: "gt", Var: "v", Value: 0}, up.Mutations[1].Cond)
+	require.Contains(t, string(up.Mutations[1].Mutation.DelNquads), `<0x1> <friend> <0x2> .`)
+
+	res, err := up.ParseQuery()
+	require.NoError(t, err)
+	require.Len(t, res.Query, 1)
+	require.Equal(t, "v", res.Query[0].Var)
+}
+
+func TestParseUpsertUndefinedVar(t *testing.T) {
+	up, err := ParseUpsert(`
+	upsert {
+		query {
+			v as var(func: eq(email, "a@example.com"))
+		}
+		mutation @if(eq(len(w), 0)) {
+			set {
+				_:a <email> "a@example.com" .
+			}
+		}
+	}`)
+	require.NoError(t, err)
+	_, err = up.ParseQuery()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Used:[w]")
+}
+
+func TestParseUpsertError(t *testing.T) {
+	for _, s := range []string{
+		`upsert { mutation { set { _:a <name> "a" . } } }`,
+		`upsert { query { v as var(func: has(name)) } }`,
+		`upsert { query { v as var(func: has(name)) } mutation @if(eq(len(v), 0) { } }`,
+		`upsert { query { v as var(func: has(name)) } foo { } }`,
+		`upsert { query { v as var(func: has(name)) } mutation { set { } } } extra`,
+	} {
+		_, err := ParseUpsert(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseCond(t *testing.T) {
+	lens := map[string]int{"a": 0, "b": 2}
+	lenOf := func(v string) int { return lens[v] }
+	for s, want := range map[string]bool{
+		`@if(eq(len(a), 0))`:                                      true,
+		`@if(lt(len(b), 2))`:                                      false,
+		`@if(le(len(b), 2))`:                                      true,
+		`@if(ge(len(b), 3))`:                                      false,
+		`@if(not eq(len(a), 0))`:                                  false,
+		`@if(eq(len(a), 0) and gt(len(b), 1))`:                    true,
+		`@if(eq(len(a), 1) or gt(len(b), 1))`:                     true,
+		`@if(eq(len(a), 1) or eq(len(b), 2) and gt(len(a), 0))`:   false,
+		`@if((eq(len(a), 1) or eq(len(b), 2)) AND eq(len(a), 0))`: true,
+	} {
+		c, err := ParseCond(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, c.Eval(lenOf), s)
+	}
+
+	for _, s := range []string{
+		`eq(len(a), 0)`,
+		`@if(eq(a, 0))`,
+		`@if(has(len(a), 0))`,
+		`@if(eq(len(a), x))`,
+		`@if(eq(len(a), 0) and)`,
+		`@if(eq(len(a), 0)) extra`,
+	} {
+		_, err := ParseCond(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestUidVar(t *testing.T) {
+	v, ok := UidVar("uid( v )")
+	require.True(t, ok)
+	require.Equal(t, "v", v)
+
+	for _, s := range []string{"uid()", "_:v", "0x1", "uid(v"} {
+		_, ok = UidVar(s)
+		require.False(t, ok, s)
+	}
+}
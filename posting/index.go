@@ -679,6 +679,35 @@ func RebuildReverseEdges(ctx context.Context, attr string, startTs uint64) error
 	return builder.Run(ctx)
 }
 
+// RebuildAllIndexes deletes the indexes, reverse edges and count indexes of attr, and rebuilds
+// the ones its schema has at startTs. It's used to make them match the data of a restore.
+func RebuildAllIndexes(ctx context.Context, attr string, startTs uint64) error {
+	if err := DeleteIndex(attr); err != nil {
+		return err
+	}
+	if err := DeleteReverseEdges(attr); err != nil {
+		return err
+	}
+	if err := DeleteCountIndex(attr); err != nil {
+		return err
+	}
+	if schema.State().IsIndexed(attr) {
+		if err := RebuildIndex(ctx, attr, startTs); err != nil {
+			return err
+		}
+	}
+	// The reverse edges are counted by the count index, so they go first.
+	if schema.State().IsReversed(attr) {
+		if err := RebuildReverseEdges(ctx, attr, startTs); err != nil {
+			return err
+		}
+	}
+	if schema.State().HasCount(attr) {
+		return RebuildCountIndex(ctx, attr, startTs)
+	}
+	return nil
+}
+
 func DeleteIndex(attr string) error {
 	lcache.clear(func(key []byte) bool {
 		return compareAttrAndType(key, attr, x.ByteIndex)
@@ -758,6 +787,9 @@ func DeleteAll() error {
 			// Don't delete schema for _predicate_
 			_, isInitialPred := x.InitialPreds[pk.Attr]
 			return !isInitialPred
+		} else if pk.IsBackups() {
+			// The group still takes backups, which need the drop of all the data.
+			return false
 		}
 		return true
 	})
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/stretchr/testify/require"
 
 	"github.com/dgraph-io/dgraph/protos/pb"
@@ -163,6 +164,26 @@ func TestAddMutation_Value(t *testing.T) {
 	checkValue(t, ol, "119", txn.StartTs)
 }
 
+func TestReadConflictKeys(t *testing.T) {
+	require.NoError(t, schema.ParseBytes([]byte("readconflict: string ."), 1))
+	key := x.DataKey("readconflict", 10)
+	ol, err := getNew(key, ps)
+	require.NoError(t, err)
+	edge := &pb.DirectedEdge{
+		Attr:  "readconflict",
+		Value: []byte("oh hey there"),
+	}
+	txn := &Txn{StartTs: 1}
+	addMutationHelper(t, ol, edge, Set, txn)
+
+	// A read of the value conflicts with its write.
+	ctx := &api.TxnContext{}
+	txn.Fill(ctx)
+	require.Len(t, ctx.Keys, 1)
+	require.Equal(t, ctx.Keys, ReadConflictKeys([][]byte{key, key}))
+	require.NotEqual(t, ctx.Keys, ReadConflictKeys([][]byte{x.DataKey("readconflict", 11)}))
+}
+
 func TestAddMutation_jchiu1(t *testing.T) {
 	key := x.DataKey("value", 12)
 	ol, err := Get(key)
@@ -18,6 +18,7 @@ package posting
 
 import (
 	"bytes"
+	"fmt"
 	"math"
 	"strconv"
 	"sync/atomic"
@@ -67,7 +68,7 @@ func (t *Txn) Fill(ctx *api.TxnContext) {
 		// We don't need to send the whole conflict key to Zero. Solving #2338
 		// should be done by sending a list of mutating predicates to Zero,
 		// along with the keys to be used for conflict detection.
-		fps := strconv.FormatUint(farm.Fingerprint64([]byte(key)), 36)
+		fps := conflictFingerprint(key)
 		if !x.HasString(ctx.Keys, fps) {
 			ctx.Keys = append(ctx.Keys, fps)
 		}
@@ -80,6 +81,26 @@ func (t *Txn) Fill(ctx *api.TxnContext) {
 	}
 }
 
+func conflictFingerprint(key string) string {
+	return strconv.FormatUint(farm.Fingerprint64([]byte(key)), 36)
+}
+
+// ReadConflictKeys returns the conflict keys, as sent to Zero by Fill, of the posting lists at
+// keys read by a transaction. A transaction sending them aborts if another one writing to these
+// posting lists commits after it started. The uid of the value isn't known for a read, so this
+// only catches writes of scalar values, of predicates with the @upsert directive, and of other
+// transactions sending the same read keys.
+func ReadConflictKeys(keys [][]byte) []string {
+	var fps []string
+	for _, key := range keys {
+		fp := conflictFingerprint(fmt.Sprintf("%s|%d", key, 0))
+		if !x.HasString(fps, fp) {
+			fps = append(fps, fp)
+		}
+	}
+	return fps
+}
+
 // Don't call this for schema mutations. Directly commit them.
 // This function only stores deltas to the commit timestamps. It does not try to generate a state.
 // TODO: Simplify this function. All it should be doing is to store the deltas, and not try to
@@ -244,6 +244,24 @@ func processToFastJsonCtxVars(t *testing.T, query string, ctx context.Context,
 	return string(resp), err
 }
 
+// processToStream returns the JSON of each batch of a streamed query.
+func processToStream(t *testing.T, query string, batch int) ([]string, error) {
+	res, err := gql.Parse(gql.Request{Str: query})
+	if err != nil {
+		return nil, err
+	}
+
+	startTs := timestamp()
+	maxPendingCh <- startTs
+	queryRequest := QueryRequest{Latency: &Latency{}, GqlQuery: &res, ReadTs: startTs}
+	var out []string
+	err = queryRequest.ProcessStream(defaultContext(), batch, func(js []byte) error {
+		out = append(out, string(js))
+		return nil
+	})
+	return out, err
+}
+
 func processToFastJsonNoErr(t *testing.T, query string) string {
 	res, err := processToFastJson(t, query)
 	require.NoError(t, err)
@@ -0,0 +1,248 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/types"
+	"github.com/dgraph-io/dgraph/worker"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// cursorAttr is the pseudo predicate that returns the cursor of each node at the root of a
+// query, to be passed as the after argument of the next page.
+const cursorAttr = "_cursor_"
+
+// cursor is the position of a node in the results at the root of a query. It is handed to
+// clients base64 encoded, as an opaque string.
+type cursor struct {
+	Order string    `json:"o,omitempty"` // The order of the query, as given by orderKey.
+	Vals  []*string `json:"v,omitempty"` // The value of each order, nil if missing.
+	Uid   uint64    `json:"u"`
+}
+
+// orderKey identifies an order, so that a cursor isn't used with a different one.
+func orderKey(order []*pb.Order) string {
+	keys := make([]string, 0, len(order))
+	for _, o := range order {
+		key := o.Attr
+		if len(o.Langs) > 0 {
+			key += "@" + strings.Join(o.Langs, ":")
+		}
+		if o.Desc {
+			key += " desc"
+		}
+		keys = append(keys, key)
+	}
+	return strings.Join(keys, ",")
+}
+
+func encodeCursor(order []*pb.Order, uid uint64, vals []types.Val) (string, error) {
+	c := cursor{Order: orderKey(order), Uid: uid}
+	for _, v := range vals {
+		if v.Value == nil {
+			c.Vals = append(c.Vals, nil)
+			continue
+		}
+		sv := types.ValueForType(types.StringID)
+		if err := types.Marshal(v, &sv); err != nil {
+			return "", err
+		}
+		s := sv.Value.(string)
+		c.Vals = append(c.Vals, &s)
+	}
+	js, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(js), nil
+}
+
+// decodeCursor returns the cursor given to the after argument of a query with the order.
+func decodeCursor(s string, order []*pb.Order) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, x.Errorf("Invalid value for after: %q", s)
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(js, c); err != nil {
+		return nil, x.Errorf("Invalid value for after: %q", s)
+	}
+	if c.Order != orderKey(order) || (len(order) > 0 && len(c.Vals) != len(order)) {
+		return nil, x.Errorf("The cursor given to after belongs to a query with another order")
+	}
+	return c, nil
+}
+
+// usesCursor returns whether the cursors of the nodes of sg are needed, either to continue
+// after one or to return them.
+func (sg *SubGraph) usesCursor() bool {
+	if sg.Params.cursor != nil {
+		return true
+	}
+	for _, child := range sg.Children {
+		if child.Attr == cursorAttr {
+			return true
+		}
+	}
+	return false
+}
+
+// compareSortValues compares the values a and b of two nodes for the order given by desc, with
+// missing values sorted last, like the sort of the worker. Values of b that are strings are
+// converted to the type of those of a.
+func compareSortValues(a, b []types.Val, desc []bool) (int, error) {
+	for i := range a {
+		an, bn := a[i].Value == nil, b[i].Value == nil
+		var c int
+		switch {
+		case an && bn:
+			continue
+		case an:
+			c = 1
+		case bn:
+			c = -1
+		default:
+			bv := b[i]
+			if bv.Tid != a[i].Tid {
+				// Convert reads the value from its bytes.
+				str := types.Val{Tid: types.StringID, Value: []byte(bv.Value.(string))}
+				var err error
+				if bv, err = types.Convert(str, a[i].Tid); err != nil {
+					return 0, err
+				}
+			}
+			if eq, err := types.Equal(a[i], bv); err != nil {
+				return 0, err
+			} else if eq {
+				continue
+			}
+			less, err := types.Less(a[i], bv)
+			if err != nil {
+				return 0, err
+			}
+			c = 1
+			if less {
+				c = -1
+			}
+		}
+		if desc[i] {
+			c = -c
+		}
+		return c, nil
+	}
+	return 0, nil
+}
+
+// applyCursor orders the uids at the root by the values of the orders and then by uid, so that
+// the position of a node only depends on its values. Only the nodes after the cursor of the
+// query, if any, are kept before applying the pagination.
+func (sg *SubGraph) applyCursor(ctx context.Context) error {
+	for _, it := range sg.Params.NeedsVar {
+		if it.Typ == gql.VALUE_VAR {
+			return x.Errorf("Cursors can't be used with an order by a value variable")
+		}
+	}
+
+	dest := sg.DestUIDs
+	vals, err := worker.FetchSortValues(ctx, sg.Params.Order, dest, sg.ReadTs)
+	if err != nil {
+		return err
+	}
+	desc := make([]bool, 0, len(sg.Params.Order))
+	for _, o := range sg.Params.Order {
+		desc = append(desc, o.Desc)
+	}
+
+	var after []types.Val
+	if c := sg.Params.cursor; c != nil {
+		for _, v := range c.Vals {
+			if v == nil {
+				after = append(after, types.Val{Tid: types.StringID})
+				continue
+			}
+			after = append(after, types.Val{Tid: types.StringID, Value: *v})
+		}
+	}
+
+	idx := make([]int, 0, len(dest.Uids))
+	for i, uid := range dest.Uids {
+		if vals[i][0].Value == nil {
+			// Like the sort of the worker, nodes without a value for the first order are
+			// skipped.
+			continue
+		}
+		if after != nil {
+			c, err := compareSortValues(vals[i], after, desc)
+			if err != nil {
+				return err
+			}
+			if c < 0 || (c == 0 && uid <= sg.Params.cursor.Uid) {
+				continue
+			}
+		}
+		idx = append(idx, i)
+	}
+
+	var serr error
+	sort.SliceStable(idx, func(i, j int) bool {
+		c, err := compareSortValues(vals[idx[i]], vals[idx[j]], desc)
+		if err != nil {
+			serr = err
+		}
+		if c == 0 {
+			return dest.Uids[idx[i]] < dest.Uids[idx[j]]
+		}
+		return c < 0
+	})
+	if serr != nil {
+		return serr
+	}
+
+	count := sg.Params.Count
+	if count == 0 {
+		// Only retrieve up to 1000 results by default.
+		count = 1000
+	}
+	start, end := x.PageRange(count, sg.Params.Offset, len(idx))
+	uids := make([]uint64, 0, end-start)
+	sg.cursorVals = make(map[uint64][]types.Val, end-start)
+	for _, i := range idx[start:end] {
+		uids = append(uids, dest.Uids[i])
+		sg.cursorVals[dest.Uids[i]] = vals[i]
+	}
+	sg.uidMatrix = []*pb.List{{Uids: uids}}
+	sg.updateDestUids()
+	return nil
+}
+
+// addCursor adds the cursor of the node uid at the root of sg to dst.
+func (sg *SubGraph) addCursor(uid uint64, fieldName string, dst outputNode) error {
+	c, err := encodeCursor(sg.Params.Order, uid, sg.cursorVals[uid])
+	if err != nil {
+		return err
+	}
+	dst.AddValue(fieldName, types.Val{Tid: types.StringID, Value: c})
+	return nil
+}
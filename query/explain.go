@@ -0,0 +1,300 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/algo"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/tok"
+	"github.com/dgraph-io/dgraph/worker"
+	"google.golang.org/grpc/metadata"
+)
+
+// ExplainStep is a step of the plan of a query: the processing of a block, a predicate or a
+// filter, with how it was done and the rows it went through. The rows of a step are the uids
+// it reached, and the values it read.
+type ExplainStep struct {
+	// Path is the place of the step in the query, like "me.friend" or "me@filter.eq(name)".
+	Path      string `json:"path"`
+	Predicate string `json:"predicate,omitempty"`
+	Function  string `json:"function,omitempty"`
+	// Index is the index used by the function, empty if it reads the predicate.
+	Index   string `json:"index,omitempty"`
+	Reverse bool   `json:"reverse,omitempty"`
+	// Group is the group serving the tablet of the predicate.
+	Group uint32 `json:"group,omitempty"`
+	// Intersections are how lists of uids were intersected or merged, like
+	// "filters: intersect 2 lists, linear".
+	Intersections []string `json:"intersections,omitempty"`
+	// EstimatedRows are the rows expected from the rows given to the step and the schema of
+	// its predicate, nil if they can't be known before running it.
+	EstimatedRows *int `json:"estimated_rows,omitempty"`
+	InputRows     int  `json:"input_rows"`
+	// FetchedRows are the rows read from the index or the predicate, before the filters and
+	// the pagination, and Rows those left after them.
+	FetchedRows int     `json:"fetched_rows"`
+	Rows        int     `json:"rows"`
+	LatencyMs   float64 `json:"latency_ms"`
+}
+
+// isExplain returns whether the plan of a query is asked for.
+func isExplain(ctx context.Context) bool {
+	var explain bool
+	// gRPC client passes information about explain as metadata.
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		explain = len(md["explain"]) > 0 && md["explain"][0] == "true"
+	}
+	// HTTP passes information about explain as query parameter which is attached to context.
+	return explain || ctx.Value("explain") == "true"
+}
+
+// explainStats records what happened while processing a SubGraph.
+type explainStats struct {
+	sync.Mutex
+	start         time.Time
+	latency       time.Duration
+	input         int
+	fetched       int
+	rows          int
+	intersections []string
+}
+
+func newExplainStats(sg *SubGraph) *explainStats {
+	st := &explainStats{start: time.Now()}
+	if sg.SrcUIDs != nil {
+		st.input = len(sg.SrcUIDs.Uids)
+	}
+	return st
+}
+
+// rows returns the uids reached by sg, and the values it read.
+func (sg *SubGraph) rows() int {
+	n := 0
+	if sg.DestUIDs != nil {
+		n = len(sg.DestUIDs.Uids)
+	}
+	for _, vl := range sg.valueMatrix {
+		n += len(vl.Values)
+	}
+	return n
+}
+
+// The methods of explainStats are no-ops on nil, when the plan isn't asked for.
+
+func (st *explainStats) fetch(sg *SubGraph) {
+	if st != nil {
+		st.fetched = sg.rows()
+	}
+}
+
+func (st *explainStats) done(sg *SubGraph) {
+	if st == nil || st.latency != 0 {
+		return
+	}
+	st.latency = time.Since(st.start)
+	st.rows = sg.rows()
+	if sg.Params.DoCount {
+		st.rows = len(sg.counts)
+	}
+}
+
+// intersect records the intersection of lists, from the smallest to the largest.
+func (st *explainStats) intersect(what string, lists []*pb.List) {
+	if st == nil || len(lists) < 2 {
+		return
+	}
+	lens := make([]int, 0, len(lists))
+	for _, l := range lists {
+		lens = append(lens, len(l.Uids))
+	}
+	sort.Ints(lens)
+	st.note(fmt.Sprintf("%s: intersect %d lists, %s", what, len(lists),
+		algo.IntersectStrategy(lens[0], lens[1])))
+}
+
+func (st *explainStats) note(s string) {
+	if st == nil {
+		return
+	}
+	st.Lock()
+	defer st.Unlock()
+	st.intersections = append(st.intersections, s)
+}
+
+// Explain returns the plan of the processed blocks of a query, with what happened at each step.
+func Explain(ctx context.Context, sgs []*SubGraph) ([]*ExplainStep, error) {
+	attrs := make(map[string]struct{})
+	for _, sg := range sgs {
+		sg.recurse(func(sg *SubGraph) {
+			if sg.Attr != "" {
+				attrs[strings.TrimPrefix(sg.Attr, "~")] = struct{}{}
+			}
+		})
+	}
+	schemas := make(map[string]*api.SchemaNode)
+	if len(attrs) > 0 {
+		req := &pb.SchemaRequest{Fields: []string{"type", "list", "tokenizer"}}
+		for attr := range attrs {
+			req.Predicates = append(req.Predicates, attr)
+		}
+		nodes, err := worker.GetSchemaOverNetwork(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			schemas[node.Predicate] = node
+		}
+	}
+
+	var steps []*ExplainStep
+	var walk func(sg *SubGraph, path string, parent *ExplainStep)
+	walk = func(sg *SubGraph, path string, parent *ExplainStep) {
+		if sg.IsInternal() || sg.Attr == "uid" || sg.Attr == cursorAttr {
+			return
+		}
+		step := newExplainStep(sg, path, parent, schemas[strings.TrimPrefix(sg.Attr, "~")])
+		steps = append(steps, step)
+		for _, filter := range sg.Filters {
+			walkFilter(filter, path+"@filter", step, &steps, schemas)
+		}
+		for _, child := range sg.Children {
+			walk(child, path+"."+child.fieldName(), step)
+		}
+	}
+	for _, sg := range sgs {
+		walk(sg, sg.Params.Alias, nil)
+	}
+	return steps, nil
+}
+
+func walkFilter(sg *SubGraph, path string, parent *ExplainStep, steps *[]*ExplainStep,
+	schemas map[string]*api.SchemaNode) {
+	if sg.FilterOp != "" {
+		path += "." + sg.FilterOp
+	} else if sg.SrcFunc != nil {
+		path += fmt.Sprintf(".%s(%s)", sg.SrcFunc.Name, sg.Attr)
+	}
+	step := newExplainStep(sg, path, parent, schemas[strings.TrimPrefix(sg.Attr, "~")])
+	*steps = append(*steps, step)
+	for _, filter := range sg.Filters {
+		walkFilter(filter, path, step, steps, schemas)
+	}
+}
+
+func newExplainStep(sg *SubGraph, path string, parent *ExplainStep,
+	schema *api.SchemaNode) *ExplainStep {
+	step := &ExplainStep{
+		Path:      path,
+		Predicate: strings.TrimPrefix(sg.Attr, "~"),
+		Reverse:   strings.HasPrefix(sg.Attr, "~"),
+	}
+	if sg.SrcFunc != nil {
+		step.Function = sg.SrcFunc.Name
+		if sg.Attr != "" && schema != nil {
+			step.Index = indexUsed(sg.SrcFunc, schema.Tokenizer)
+		}
+	}
+	if sg.Attr != "" {
+		step.Group = worker.BelongsTo(step.Predicate)
+	}
+	if st := sg.explain; st != nil {
+		step.InputRows = st.input
+		step.FetchedRows = st.fetched
+		step.Rows = st.rows
+		step.LatencyMs = float64(st.latency) / float64(time.Millisecond)
+		step.Intersections = st.intersections
+	}
+	step.EstimatedRows = estimateRows(sg, step.InputRows, parent != nil, schema)
+	return step
+}
+
+// estimateRows estimates the rows of a step from the rows given to it, before running it: a
+// value of a predicate that isn't a list, a count, or the first uids of each node. It returns
+// nil if they can't be known in advance.
+func estimateRows(sg *SubGraph, input int, child bool, schema *api.SchemaNode) *int {
+	var n int
+	switch {
+	case !child && sg.SrcFunc != nil && sg.SrcFunc.Name == "uid" && sg.SrcUIDs != nil:
+		n = len(sg.SrcUIDs.Uids)
+	case !child:
+		return nil
+	case sg.Params.DoCount:
+		n = input
+	case schema != nil && schema.Type != "uid" && !schema.List:
+		n = input
+	case sg.Params.Count > 0:
+		n = input * sg.Params.Count
+	default:
+		return nil
+	}
+	return &n
+}
+
+// indexUsed returns the index a function looks up, out of the tokenizers of its predicate, in
+// the same way as the workers pick it. It's empty if the function reads the predicate.
+func indexUsed(fn *Function, tokenizers []string) string {
+	if fn.IsCount || fn.IsValueVar || len(tokenizers) == 0 {
+		return ""
+	}
+	has := func(name string) string {
+		for _, t := range tokenizers {
+			if t == name {
+				return t
+			}
+		}
+		return ""
+	}
+	switch fn.Name {
+	case "anyofterms", "allofterms":
+		return has("term")
+	case "anyoftext", "alloftext":
+		return has("fulltext")
+	case "regexp", "match":
+		return has("trigram")
+	case "near", "within", "contains", "intersects":
+		return has("geo")
+	case "eq", "le", "lt", "ge", "gt":
+	default:
+		return ""
+	}
+
+	var sortable string
+	for _, name := range tokenizers {
+		t, ok := tok.GetTokenizer(name)
+		if !ok {
+			continue
+		}
+		if fn.Name == "eq" && !t.IsLossy() {
+			return name
+		}
+		if sortable == "" && t.IsSortable() {
+			sortable = name
+		}
+	}
+	if sortable == "" && fn.Name == "eq" {
+		return tokenizers[0]
+	}
+	return sortable
+}
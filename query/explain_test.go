@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexUsed(t *testing.T) {
+	tests := []struct {
+		fn         string
+		tokenizers []string
+		index      string
+	}{
+		{fn: "eq", tokenizers: []string{"term", "exact", "trigram"}, index: "exact"},
+		{fn: "eq", tokenizers: []string{"term"}, index: "term"},
+		{fn: "ge", tokenizers: []string{"term", "exact"}, index: "exact"},
+		{fn: "ge", tokenizers: []string{"hash"}, index: ""},
+		{fn: "anyofterms", tokenizers: []string{"exact", "term"}, index: "term"},
+		{fn: "alloftext", tokenizers: []string{"fulltext"}, index: "fulltext"},
+		{fn: "regexp", tokenizers: []string{"term", "trigram"}, index: "trigram"},
+		{fn: "has", tokenizers: []string{"exact"}, index: ""},
+		{fn: "eq", tokenizers: nil, index: ""},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.index, indexUsed(&Function{Name: tc.fn}, tc.tokenizers),
+			"%s %v", tc.fn, tc.tokenizers)
+	}
+}
+
+func explainQuery(t *testing.T, query string) []*ExplainStep {
+	res, err := gql.Parse(gql.Request{Str: query})
+	require.NoError(t, err)
+
+	startTs := timestamp()
+	maxPendingCh <- startTs
+	ctx := context.WithValue(defaultContext(), "explain", "true")
+	queryRequest := QueryRequest{Latency: &Latency{}, GqlQuery: &res, ReadTs: startTs}
+	er, err := queryRequest.Process(ctx)
+	require.NoError(t, err)
+	return er.Explain
+}
+
+func TestExplain(t *testing.T) {
+	query := `{
+		me(func: anyofterms(name, "Michonne Andrea")) @filter(ge(age, 10)) {
+			name
+			friend(first: 2) {
+				name
+			}
+		}
+	}`
+
+	steps := explainQuery(t, query)
+	byPath := make(map[string]*ExplainStep)
+	var paths []string
+	for _, step := range steps {
+		byPath[step.Path] = step
+		paths = append(paths, step.Path)
+	}
+	require.Equal(t,
+		[]string{"me", "me@filter.ge(age)", "me.name", "me.friend", "me.friend.name"}, paths)
+
+	me := byPath["me"]
+	require.Equal(t, "name", me.Predicate)
+	require.Equal(t, "anyofterms", me.Function)
+	require.Equal(t, "term", me.Index)
+	require.Nil(t, me.EstimatedRows)
+	require.True(t, me.Rows <= me.FetchedRows)
+	require.Equal(t, "int", byPath["me@filter.ge(age)"].Index)
+
+	name := byPath["me.name"]
+	require.NotNil(t, name.EstimatedRows)
+	require.Equal(t, name.InputRows, *name.EstimatedRows)
+
+	friend := byPath["me.friend"]
+	require.NotNil(t, friend.EstimatedRows)
+	require.Equal(t, 2*friend.InputRows, *friend.EstimatedRows)
+	require.True(t, friend.Rows <= *friend.EstimatedRows)
+}
+
+func TestExplainOff(t *testing.T) {
+	res, err := gql.Parse(gql.Request{Str: `{ me(func: uid(0x01)) { name } }`})
+	require.NoError(t, err)
+
+	startTs := timestamp()
+	maxPendingCh <- startTs
+	queryRequest := QueryRequest{Latency: &Latency{}, GqlQuery: &res, ReadTs: startTs}
+	er, err := queryRequest.Process(defaultContext())
+	require.NoError(t, err)
+	require.Nil(t, er.Explain)
+}
@@ -22,6 +22,7 @@ import (
 	"strconv"
 
 	"github.com/dgraph-io/dgraph/algo"
+	"github.com/dgraph-io/dgraph/gql"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/types"
 	"github.com/dgraph-io/dgraph/x"
@@ -39,15 +40,25 @@ type groupResult struct {
 	uids       []uint64
 }
 
+// aggregateName returns the name of the aggregate of child in the groups.
+func aggregateName(child *SubGraph) string {
+	switch {
+	case child.Params.Alias != "":
+		return child.Params.Alias
+	case child.Params.DoCount:
+		return "count"
+	case child.SrcFunc != nil:
+		return fmt.Sprintf("%s(%s)", child.SrcFunc.Name, child.Attr)
+	}
+	return child.Attr
+}
+
 func (grp *groupResult) aggregateChild(child *SubGraph) error {
-	fieldName := child.Params.Alias
+	fieldName := aggregateName(child)
 	if child.Params.DoCount {
 		if child.Attr != "uid" {
 			return x.Errorf("Only uid predicate is allowed in count within groupby")
 		}
-		if fieldName == "" {
-			fieldName = "count"
-		}
 		grp.aggregates = append(grp.aggregates, groupPair{
 			attr: fieldName,
 			key: types.Val{
@@ -58,9 +69,6 @@ func (grp *groupResult) aggregateChild(child *SubGraph) error {
 		return nil
 	}
 	if child.SrcFunc != nil && isAggregatorFn(child.SrcFunc.Name) {
-		if fieldName == "" {
-			fieldName = fmt.Sprintf("%s(%s)", child.SrcFunc.Name, child.Attr)
-		}
 		finalVal, err := aggregateGroup(grp, child)
 		if err != nil {
 			return err
@@ -73,6 +81,56 @@ func (grp *groupResult) aggregateChild(child *SubGraph) error {
 	return nil
 }
 
+// value returns the aggregate, or else the grouping key, with the given name.
+func (grp *groupResult) value(name string) (types.Val, bool) {
+	for _, p := range grp.aggregates {
+		if p.attr == name {
+			return p.key, true
+		}
+	}
+	for _, p := range grp.keys {
+		if p.attr == name {
+			return p.key, true
+		}
+	}
+	return types.Val{}, false
+}
+
+// matches returns whether the group satisfies the @having filter. A comparison with an
+// aggregate the group doesn't have is false.
+func (grp *groupResult) matches(ft *gql.FilterTree) bool {
+	switch ft.Op {
+	case "and":
+		for _, ch := range ft.Child {
+			if !grp.matches(ch) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, ch := range ft.Child {
+			if grp.matches(ch) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return !grp.matches(ft.Child[0])
+	}
+
+	f := ft.Func
+	val, ok := grp.value(f.Attr)
+	if !ok {
+		return false
+	}
+	arg, err := types.Convert(types.Val{Tid: types.StringID, Value: []byte(f.Args[0].Value)},
+		val.Tid)
+	if err != nil {
+		return false
+	}
+	return types.CompareVals(f.Name, val, arg)
+}
+
 type groupResults struct {
 	group []*groupResult
 }
@@ -245,8 +303,20 @@ func (sg *SubGraph) formResult(ul *pb.List) (*groupResults, error) {
 
 	// Create all the groups here.
 	res.formGroups(dedupMap, &pb.List{}, []groupPair{})
+	if err := sg.aggregateGroups(res); err != nil {
+		return res, err
+	}
+	// Sort to order the groups for determinism.
+	sort.Slice(res.group, func(i, j int) bool {
+		return groupLess(res.group[i], res.group[j])
+	})
+
+	return res, nil
+}
 
-	// Go over the groups and aggregate the values.
+// aggregateGroups computes the aggregates of the groups and drops the groups not matching
+// the @having filter.
+func (sg *SubGraph) aggregateGroups(res *groupResults) error {
 	for _, child := range sg.Children {
 		if child.Params.ignoreResult {
 			continue
@@ -255,16 +325,21 @@ func (sg *SubGraph) formResult(ul *pb.List) (*groupResults, error) {
 		for _, grp := range res.group {
 			err := grp.aggregateChild(child)
 			if err != nil && err != ErrEmptyVal {
-				return res, err
+				return err
 			}
 		}
 	}
-	// Sort to order the groups for determinism.
-	sort.Slice(res.group, func(i, j int) bool {
-		return groupLess(res.group[i], res.group[j])
-	})
-
-	return res, nil
+	if sg.Params.groupbyHaving == nil {
+		return nil
+	}
+	groups := res.group[:0]
+	for _, grp := range res.group {
+		if grp.matches(sg.Params.groupbyHaving) {
+			groups = append(groups, grp)
+		}
+	}
+	res.group = groups
+	return nil
 }
 
 // This function is to use the fillVars. It is similar to formResult, the only difference being
@@ -323,23 +398,16 @@ func (sg *SubGraph) fillGroupedVars(doneVars map[string]varValue, path []*SubGra
 	// Create all the groups here.
 	res := new(groupResults)
 	res.formGroups(dedupMap, &pb.List{}, []groupPair{})
+	if err := sg.aggregateGroups(res); err != nil {
+		return err
+	}
 
-	// Go over the groups and aggregate the values.
 	for _, child := range sg.Children {
-		if child.Params.ignoreResult {
-			continue
-		}
-		// This is a aggregation node.
-		for _, grp := range res.group {
-			err := grp.aggregateChild(child)
-			if err != nil && err != ErrEmptyVal {
-				return err
-			}
-		}
-		if child.Params.Var == "" {
+		if child.Params.ignoreResult || child.Params.Var == "" {
 			continue
 		}
 		chVar := child.Params.Var
+		fieldName := aggregateName(child)
 
 		tempMap := make(map[uint64]types.Val)
 		for _, grp := range res.group {
@@ -354,9 +422,9 @@ func (sg *SubGraph) fillGroupedVars(doneVars map[string]varValue, path []*SubGra
 			if !ok {
 				return x.Errorf("Vars can be assigned only when grouped by UID attribute")
 			}
-			// grp.aggregates could be empty if schema conversion failed during aggregation
-			if len(grp.aggregates) > 0 {
-				tempMap[uid] = grp.aggregates[len(grp.aggregates)-1].key
+			// The aggregate could be missing if schema conversion failed during aggregation.
+			if val, ok := grp.value(fieldName); ok {
+				tempMap[uid] = val
 			}
 		}
 		doneVars[chVar] = varValue{
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// Limits bound the work of a query, so that a single query can't exhaust an Alpha. Zero values
+// are no limit.
+type Limits struct {
+	// Timeout is the time a query can run for.
+	Timeout time.Duration
+	// MaxDepth is the number of nested levels of a block, one for a block without children.
+	MaxDepth int
+	// MaxEdges is the number of edges, uids and values, a query can return.
+	MaxEdges uint64
+	// Partial returns the results found within MaxDepth and MaxEdges, instead of an error, when
+	// a query goes beyond them.
+	Partial bool
+}
+
+// Tighten returns l with each limit set in o that is lower than the one in l, so that the
+// limits asked for by a request can't go beyond those of the server.
+func (l Limits) Tighten(o Limits) Limits {
+	if o.Timeout > 0 && (l.Timeout == 0 || o.Timeout < l.Timeout) {
+		l.Timeout = o.Timeout
+	}
+	if o.MaxDepth > 0 && (l.MaxDepth == 0 || o.MaxDepth < l.MaxDepth) {
+		l.MaxDepth = o.MaxDepth
+	}
+	if o.MaxEdges > 0 && (l.MaxEdges == 0 || o.MaxEdges < l.MaxEdges) {
+		l.MaxEdges = o.MaxEdges
+	}
+	l.Partial = l.Partial || o.Partial
+	return l
+}
+
+// limiter keeps the count of the edges returned by a query, against its limits.
+type limiter struct {
+	Limits
+	edges     uint64
+	truncated int32
+}
+
+type limiterKey struct{}
+
+// WithLimits returns a context in which the queries processed are bound by l.
+func WithLimits(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, limiterKey{}, &limiter{Limits: l})
+}
+
+// Truncated returns whether the results of the queries processed in ctx were cut short by
+// their limits.
+func Truncated(ctx context.Context) bool {
+	l := limiterFrom(ctx)
+	return l != nil && atomic.LoadInt32(&l.truncated) == 1
+}
+
+// withoutLimits returns a context in which the edges found aren't counted.
+func withoutLimits(ctx context.Context) context.Context {
+	if limiterFrom(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, limiterKey{}, (*limiter)(nil))
+}
+
+func limiterFrom(ctx context.Context) *limiter {
+	l, _ := ctx.Value(limiterKey{}).(*limiter)
+	return l
+}
+
+// take takes up to n edges out of those left to the query, and returns how many it got.
+func (l *limiter) take(n uint64) uint64 {
+	for {
+		used := atomic.LoadUint64(&l.edges)
+		if used >= l.MaxEdges {
+			return 0
+		}
+		got := n
+		if left := l.MaxEdges - used; got > left {
+			got = left
+		}
+		if atomic.CompareAndSwapUint64(&l.edges, used, used+got) {
+			return got
+		}
+	}
+}
+
+// limitDepth checks that the block gq isn't deeper than the limit. With partial results, the
+// levels past the limit are removed instead.
+func (l *limiter) limitDepth(gq *gql.GraphQuery) error {
+	if l == nil || l.MaxDepth == 0 {
+		return nil
+	}
+	if gq.Recurse {
+		depth := gq.RecurseArgs.Depth
+		if depth != 0 && depth <= uint64(l.MaxDepth) {
+			return nil
+		}
+		if !l.Partial {
+			return x.Errorf("Query exceeds the limit of %d levels: @recurse needs a depth of at "+
+				"most %d", l.MaxDepth, l.MaxDepth)
+		}
+		gq.RecurseArgs.Depth = uint64(l.MaxDepth)
+		atomic.StoreInt32(&l.truncated, 1)
+		return nil
+	}
+
+	var prune func(gq *gql.GraphQuery, level int) bool
+	prune = func(gq *gql.GraphQuery, level int) bool {
+		if len(gq.Children) == 0 {
+			return false
+		}
+		if level == l.MaxDepth {
+			gq.Children = nil
+			return true
+		}
+		pruned := false
+		for _, child := range gq.Children {
+			pruned = prune(child, level+1) || pruned
+		}
+		return pruned
+	}
+	if !l.Partial {
+		if depth := gq.Depth(); depth > l.MaxDepth {
+			return x.Errorf("Query exceeds the limit of %d levels with a block of %d levels",
+				l.MaxDepth, depth)
+		}
+		return nil
+	}
+	if prune(gq, 1) {
+		atomic.StoreInt32(&l.truncated, 1)
+	}
+	return nil
+}
+
+// limitEdges counts the uids and values found by sg against the edges left to the query. With
+// partial results, those beyond the limit are dropped, otherwise an error is returned.
+func (sg *SubGraph) limitEdges(ctx context.Context) error {
+	l := limiterFrom(ctx)
+	if l == nil || l.MaxEdges == 0 {
+		return nil
+	}
+	if len(sg.Filters) > 0 {
+		// Leave out the uids removed by the filters.
+		sg.updateUidMatrix()
+	}
+	var n uint64
+	for _, ul := range sg.uidMatrix {
+		n += uint64(len(ul.Uids))
+	}
+	for _, vl := range sg.valueMatrix {
+		n += uint64(len(vl.Values))
+	}
+	got := l.take(n)
+	if got == n {
+		return nil
+	}
+	if !l.Partial {
+		return x.Errorf("Query exceeds the limit of %d edges", l.MaxEdges)
+	}
+	atomic.StoreInt32(&l.truncated, 1)
+
+	left := got
+	keep := func(n int) int {
+		if uint64(n) > left {
+			n = int(left)
+		}
+		left -= uint64(n)
+		return n
+	}
+	// The facets of a list of uids are those of each uid, and those of a list of values are
+	// of its first value.
+	trimFacets := func(i, n int) {
+		if i < len(sg.facetsMatrix) && len(sg.facetsMatrix[i].FacetsList) > n {
+			sg.facetsMatrix[i].FacetsList = sg.facetsMatrix[i].FacetsList[:n]
+		}
+	}
+	for i, ul := range sg.uidMatrix {
+		if k := keep(len(ul.Uids)); k < len(ul.Uids) {
+			ul.Uids = ul.Uids[:k]
+			trimFacets(i, k)
+		}
+	}
+	for i, vl := range sg.valueMatrix {
+		if k := keep(len(vl.Values)); k < len(vl.Values) {
+			vl.Values = vl.Values[:k]
+			if k == 0 {
+				trimFacets(i, 0)
+			}
+		}
+	}
+
+	// Only the uids left in the lists are followed by the children.
+	kept := make(map[uint64]struct{})
+	for _, ul := range sg.uidMatrix {
+		for _, uid := range ul.Uids {
+			kept[uid] = struct{}{}
+		}
+	}
+	if sg.DestUIDs != nil {
+		dest := &pb.List{Uids: make([]uint64, 0, len(kept))}
+		for _, uid := range sg.DestUIDs.Uids {
+			if _, ok := kept[uid]; ok {
+				dest.Uids = append(dest.Uids, uid)
+			}
+		}
+		sg.DestUIDs = dest
+	}
+	return nil
+}
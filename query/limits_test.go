@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsTighten(t *testing.T) {
+	server := Limits{Timeout: time.Minute, MaxEdges: 1000}
+	require.Equal(t, server, server.Tighten(Limits{}))
+	require.Equal(t, Limits{Timeout: time.Second, MaxDepth: 3, MaxEdges: 1000, Partial: true},
+		server.Tighten(Limits{Timeout: time.Second, MaxDepth: 3, MaxEdges: 5000, Partial: true}))
+}
+
+func TestQueryMaxEdges(t *testing.T) {
+	query := `{
+		me(func: uid(10000, 10001, 10002)) {
+			name
+		}
+	}`
+
+	ctx := WithLimits(defaultContext(), Limits{MaxEdges: 6})
+	js, err := processToFastJsonCtxVars(t, query, ctx, nil)
+	require.NoError(t, err)
+	require.JSONEq(t,
+		`{"data": {"me":[{"name":"Alice"},{"name":"Elizabeth"},{"name":"Alice"}]}}`, js)
+	require.False(t, Truncated(ctx))
+
+	ctx = WithLimits(defaultContext(), Limits{MaxEdges: 4})
+	_, err = processToFastJsonCtxVars(t, query, ctx, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Query exceeds the limit of 4 edges")
+
+	// The three uids at the root take three of the edges, so only one name is left.
+	ctx = WithLimits(defaultContext(), Limits{MaxEdges: 4, Partial: true})
+	js, err = processToFastJsonCtxVars(t, query, ctx, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data": {"me":[{"name":"Alice"}]}}`, js)
+	require.True(t, Truncated(ctx))
+}
+
+func TestQueryMaxEdgesFilter(t *testing.T) {
+	// The uids matched by the filter don't count, only those returned.
+	query := `{
+		me(func: uid(10000, 10001, 10002)) @filter(eq(age, 75)) {
+			name
+		}
+	}`
+
+	ctx := WithLimits(defaultContext(), Limits{MaxEdges: 4})
+	js, err := processToFastJsonCtxVars(t, query, ctx, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data": {"me":[{"name":"Elizabeth"},{"name":"Alice"}]}}`, js)
+}
+
+func TestQueryMaxDepth(t *testing.T) {
+	query := `{
+		me(func: uid(0x01)) {
+			name
+			friend {
+				name
+			}
+		}
+	}`
+
+	ctx := WithLimits(defaultContext(), Limits{MaxDepth: 3})
+	js, err := processToFastJsonCtxVars(t, query, ctx, nil)
+	require.NoError(t, err)
+	require.Contains(t, js, "Glenn Rhee")
+	require.False(t, Truncated(ctx))
+
+	ctx = WithLimits(defaultContext(), Limits{MaxDepth: 2})
+	_, err = processToFastJsonCtxVars(t, query, ctx, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Query exceeds the limit of 2 levels")
+
+	ctx = WithLimits(defaultContext(), Limits{MaxDepth: 2, Partial: true})
+	js, err = processToFastJsonCtxVars(t, query, ctx, nil)
+	require.NoError(t, err)
+	require.Contains(t, js, "Michonne")
+	require.NotContains(t, js, "Glenn Rhee")
+	require.True(t, Truncated(ctx))
+}
+
+func TestQueryMaxDepthRecurse(t *testing.T) {
+	query := `{
+		me(func: uid(0x01)) @recurse {
+			name
+			friend
+		}
+	}`
+
+	ctx := WithLimits(defaultContext(), Limits{MaxDepth: 2})
+	_, err := processToFastJsonCtxVars(t, query, ctx, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "@recurse needs a depth of at most 2")
+
+	ctx = WithLimits(defaultContext(), Limits{MaxDepth: 2, Partial: true})
+	_, err = processToFastJsonCtxVars(t, query, ctx, nil)
+	require.NoError(t, err)
+	require.True(t, Truncated(ctx))
+}
@@ -484,6 +484,10 @@ func processNodeUids(n *fastJsonNode, sg *SubGraph) error {
 type Extensions struct {
 	Latency *api.Latency    `json:"server_latency,omitempty"`
 	Txn     *api.TxnContext `json:"txn,omitempty"`
+	// Truncated is set if the results were cut short by the limits of the query.
+	Truncated bool `json:"truncated,omitempty"`
+	// Explain is the plan of the query, if it was asked for.
+	Explain []*ExplainStep `json:"explain,omitempty"`
 }
 
 func (sg *SubGraph) toFastJSON(l *Latency) ([]byte, error) {
@@ -112,6 +112,7 @@ type params struct {
 	Count      int
 	Offset     int
 	AfterUID   uint64
+	cursor     *cursor // Position at the root to continue after, for an ordered query.
 	DoCount    bool
 	GetUid     bool
 	Order      []*pb.Order
@@ -140,9 +141,11 @@ type params struct {
 	Expand         string // Value is either _all_/variable-name or empty.
 	isGroupBy      bool
 	groupbyAttrs   []gql.GroupByAttr
+	groupbyHaving  *gql.FilterTree
 	uidCount       bool
 	uidCountAlias  string
 	numPaths       int
+	weightFacet    string   // Facet giving the weights of the edges in shortest path.
 	parentIds      []uint64 // This is a stack that is maintained and passed down to children.
 	IsEmpty        bool     // Won't have any SrcUids or DestUids. Only used to get aggregated vars
 	expandAll      bool     // expand all languages
@@ -186,6 +189,13 @@ type SubGraph struct {
 	// destUIDs is a list of destination UIDs, after applying filters, pagination.
 	DestUIDs *pb.List
 	List     bool // whether predicate is of list type
+
+	// truncated has the source uids whose edges were dropped to cut a cycle in recurse.
+	truncated map[uint64]bool
+	// cursorVals has the sort values of the uids at the root, when their cursors are needed.
+	cursorVals map[uint64][]types.Val
+	// explain records how the SubGraph was processed, when the plan of the query is asked for.
+	explain *explainStats
 }
 
 func (sg *SubGraph) recurse(set func(sg *SubGraph)) {
@@ -344,6 +354,23 @@ func addCheckPwd(pc *SubGraph, vals []*pb.TaskValue, dst outputNode) {
 	dst.AddValue(fieldName, c)
 }
 
+func addGeoDistance(pc *SubGraph, vals []*pb.TaskValue, dst outputNode) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	sv, err := convertWithBestEffort(vals[0], pc.Attr)
+	if err != nil {
+		return err
+	}
+
+	fieldName := pc.Params.Alias
+	if fieldName == "" {
+		fieldName = fmt.Sprintf("distance(%s)", pc.Attr)
+	}
+	dst.AddValue(fieldName, sv)
+	return nil
+}
+
 func alreadySeen(parentIds []uint64, uid uint64) bool {
 	for _, id := range parentIds {
 		if id == uid {
@@ -419,6 +446,11 @@ func (sg *SubGraph) preTraverse(uid uint64, dst outputNode) error {
 		} else if pc.SrcFunc != nil && pc.SrcFunc.Name == "checkpwd" {
 			addCheckPwd(pc, pc.valueMatrix[idx].Values, dst)
 
+		} else if pc.SrcFunc != nil && pc.SrcFunc.Name == "distance" {
+			if err := addGeoDistance(pc, pc.valueMatrix[idx].Values, dst); err != nil {
+				return err
+			}
+
 		} else if idx < len(pc.uidMatrix) && len(pc.uidMatrix[idx].Uids) > 0 {
 			var fcsList []*pb.Facets
 			if pc.Params.Facet != nil {
@@ -490,6 +522,14 @@ func (sg *SubGraph) preTraverse(uid uint64, dst outputNode) error {
 				dst.SetUID(uid, pc.fieldName())
 				continue
 			}
+			if pc.Attr == cursorAttr {
+				if sg.cursorVals != nil {
+					if err := sg.addCursor(uid, pc.fieldName(), dst); err != nil {
+						return err
+					}
+				}
+				continue
+			}
 
 			if len(pc.facetsMatrix) > idx && len(pc.facetsMatrix[idx].FacetsList) > 0 {
 				// in case of Value we have only one Facets
@@ -543,6 +583,14 @@ func (sg *SubGraph) preTraverse(uid uint64, dst outputNode) error {
 		}
 	}
 
+	for _, pc := range sg.Children {
+		if pc.truncated[uid] {
+			// Some edges of this node were dropped to cut a cycle in recurse.
+			dst.AddValue("_truncated_", types.Val{Tid: types.BoolID, Value: true})
+			break
+		}
+	}
+
 	if sg.Params.IgnoreReflex && len(sg.Params.parentIds) > 0 {
 		// Lets pop the stack.
 		sg.Params.parentIds = (sg.Params.parentIds)[:len(sg.Params.parentIds)-1]
@@ -696,6 +744,7 @@ func treeCopy(gq *gql.GraphQuery, sg *SubGraph) error {
 			Order:          gchild.Order,
 			Var:            gchild.Var,
 			groupbyAttrs:   gchild.GroupbyAttrs,
+			groupbyHaving:  gchild.Having,
 			isGroupBy:      gchild.IsGroupby,
 			isInternal:     gchild.IsInternal,
 			uidCount:       gchild.UidCount,
@@ -735,7 +784,8 @@ func treeCopy(gq *gql.GraphQuery, sg *SubGraph) error {
 		}
 
 		if gchild.Func != nil &&
-			(gchild.Func.IsAggregator() || gchild.Func.IsPasswordVerifier()) {
+			(gchild.Func.IsAggregator() || gchild.Func.IsPasswordVerifier() ||
+				gchild.Func.IsGeoDistance()) {
 			if len(gchild.Children) != 0 {
 				return x.Errorf("Node with %q cant have child attr", gchild.Func.Name)
 			}
@@ -784,11 +834,20 @@ func (args *params) fill(gq *gql.GraphQuery) error {
 		args.Offset = int(offset)
 	}
 	if v, ok := gq.Args["after"]; ok {
-		after, err := strconv.ParseUint(v, 0, 64)
-		if err != nil {
-			return err
+		if after, err := strconv.ParseUint(v, 0, 64); err == nil {
+			args.AfterUID = uint64(after)
+		} else {
+			// Otherwise it is a cursor returned by _cursor_ at the root of the query.
+			c, err := decodeCursor(v, gq.Order)
+			if err != nil {
+				return err
+			}
+			if len(gq.Order) == 0 {
+				args.AfterUID = c.Uid
+			} else {
+				args.cursor = c
+			}
 		}
-		args.AfterUID = uint64(after)
 	}
 
 	if v, ok := gq.Args["depth"]; ok && (args.Alias == "shortest") {
@@ -805,6 +864,9 @@ func (args *params) fill(gq *gql.GraphQuery) error {
 		}
 		args.numPaths = int(numPaths)
 	}
+	if v, ok := gq.Args["weightFacet"]; ok && args.Alias == "shortest" {
+		args.weightFacet = v
+	}
 	if v, ok := gq.Args["from"]; ok && args.Alias == "shortest" {
 		from, err := strconv.ParseUint(v, 0, 64)
 		if err != nil {
@@ -884,6 +946,7 @@ func newGraph(ctx context.Context, gq *gql.GraphQuery) (*SubGraph, error) {
 		RecurseArgs:   gq.RecurseArgs,
 		Var:           gq.Var,
 		groupbyAttrs:  gq.GroupbyAttrs,
+		groupbyHaving: gq.Having,
 		isGroupBy:     gq.IsGroupby,
 		uidCount:      gq.UidCount,
 		uidCountAlias: gq.UidCountAlias,
@@ -1357,7 +1420,7 @@ func (sg *SubGraph) populateVarMap(doneVars map[string]varValue, sgPath []*SubGr
 		for _, child := range sg.Children {
 			// For uid we dont actually populate the uidMatrix or values. So a node asking for
 			// uid would always be excluded. Therefore we skip it.
-			if child.Attr == "uid" {
+			if child.Attr == "uid" || child.Attr == cursorAttr {
 				continue
 			}
 
@@ -1849,7 +1912,7 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 	stop := x.SpanTimer(span, "query.ProcessGraph"+suffix)
 	defer stop()
 
-	if sg.Attr == "uid" {
+	if sg.Attr == "uid" || sg.Attr == cursorAttr {
 		// We dont need to call ProcessGraph for uid, as we already have uids
 		// populated from parent and there is nothing to process but uidMatrix
 		// and values need to have the right sizes so that preTraverse works.
@@ -1857,6 +1920,16 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 		rch <- nil
 		return
 	}
+	if isExplain(ctx) {
+		// The stats are complete before the result is sent, for the plan to be read after.
+		sg.explain = newExplainStats(sg)
+		out := rch
+		rch = make(chan error, 1)
+		defer func() {
+			sg.explain.done(sg)
+			out <- <-rch
+		}()
+	}
 	var err error
 	if parent == nil && sg.SrcFunc != nil && sg.SrcFunc.Name == "uid" {
 		// I'm root and I'm using some variable that has been populated.
@@ -1881,6 +1954,7 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 				rch <- err
 				return
 			}
+			sg.explain.intersect("uids", []*pb.List{sg.DestUIDs, sg.SrcUIDs})
 			algo.IntersectWith(sg.DestUIDs, sg.SrcUIDs, sg.DestUIDs)
 			rch <- nil
 			return
@@ -1930,6 +2004,7 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 			}
 
 			if result.IntersectDest {
+				sg.explain.intersect("tokens", result.UidMatrix)
 				sg.DestUIDs = algo.IntersectSorted(result.UidMatrix)
 			} else {
 				sg.DestUIDs = algo.MergeSorted(result.UidMatrix)
@@ -1942,11 +2017,17 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 		}
 	}
 
+	sg.explain.fetch(sg)
+
 	if sg.DestUIDs == nil || len(sg.DestUIDs.Uids) == 0 {
 		// Looks like we're done here. Be careful with nil srcUIDs!
 		if span != nil {
 			span.Annotatef(nil, "Zero uids for %q", sg.Attr)
 		}
+		if err = sg.limitEdges(ctx); err != nil {
+			rch <- err
+			return
+		}
 		out := sg.Children[:0]
 		for _, child := range sg.Children {
 			if child.IsInternal() && child.Attr == "expand" {
@@ -1977,7 +2058,8 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 			filter.SrcUIDs = sg.DestUIDs
 			// Passing the pointer is okay since the filter only reads.
 			filter.Params.ParentVars = sg.Params.ParentVars // Pass to the child.
-			go ProcessGraph(ctx, filter, sg, filterChan)
+			// The uids matched by filters aren't returned, so they don't count as edges.
+			go ProcessGraph(withoutLimits(ctx), filter, sg, filterChan)
 		}
 
 		var filterErr error
@@ -2000,11 +2082,14 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 			lists = append(lists, filter.DestUIDs)
 		}
 		if sg.FilterOp == "or" {
+			sg.explain.note(fmt.Sprintf("filters: merge %d lists", len(lists)))
 			sg.DestUIDs = algo.MergeSorted(lists)
 		} else if sg.FilterOp == "not" {
 			x.AssertTrue(len(sg.Filters) == 1)
+			sg.explain.note("filters: difference")
 			sg.DestUIDs = algo.Difference(sg.DestUIDs, sg.Filters[0].DestUIDs)
 		} else if sg.FilterOp == "and" {
+			sg.explain.intersect("filters", lists)
 			sg.DestUIDs = algo.IntersectSorted(lists)
 		} else {
 			// We need to also intersect the original dest uids in this case to get the final
@@ -2014,16 +2099,31 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 			// TODO - See if the server performing the filter can intersect with the srcUIDs before
 			// returning them in this case.
 			lists = append(lists, sg.DestUIDs)
+			sg.explain.intersect("filters", lists)
 			sg.DestUIDs = algo.IntersectSorted(lists)
 		}
 	}
 
+	if parent != nil && sg.Params.cursor != nil {
+		rch <- x.Errorf("Cursors of ordered results can only be given to after at the root")
+		return
+	}
+
 	if len(sg.Params.Order) == 0 && len(sg.Params.FacetOrder) == 0 {
 		// There is no ordering. Just apply pagination and return.
 		if err = sg.applyPagination(ctx); err != nil {
 			rch <- err
 			return
 		}
+		if parent == nil && sg.usesCursor() {
+			sg.cursorVals = make(map[uint64][]types.Val)
+		}
+	} else if parent == nil && sg.usesCursor() && !sg.Params.DoCount {
+		// Cursors need an order that only depends on the values of each node.
+		if err = sg.applyCursor(ctx); err != nil {
+			rch <- err
+			return
+		}
 	} else {
 		// If we are asked for count, we don't need to change the order of results.
 		if !sg.Params.DoCount {
@@ -2035,6 +2135,13 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 		}
 	}
 
+	if !sg.Params.DoCount {
+		if err = sg.limitEdges(ctx); err != nil {
+			rch <- err
+			return
+		}
+	}
+
 	// We store any variable defined by this node in the map and pass it on
 	// to the children which might depend on it.
 	if err = sg.updateVars(sg.Params.ParentVars, []*SubGraph{}); err != nil {
@@ -2082,6 +2189,9 @@ func ProcessGraph(ctx context.Context, sg, parent *SubGraph, rch chan error) {
 		}
 	}
 
+	// The latency of the step leaves out its children.
+	sg.explain.done(sg)
+
 	childChan := make(chan error, len(sg.Children))
 	for i := 0; i < len(sg.Children); i++ {
 		child := sg.Children[i]
@@ -2318,7 +2428,8 @@ func (sg *SubGraph) sortAndPaginateUsingVar(ctx context.Context) error {
 // isValidArg checks if arg passed is valid keyword.
 func isValidArg(a string) bool {
 	switch a {
-	case "numpaths", "from", "to", "orderasc", "orderdesc", "first", "offset", "after", "depth":
+	case "numpaths", "from", "to", "orderasc", "orderdesc", "first", "offset", "after", "depth",
+		"weightFacet":
 		return true
 	}
 	return false
@@ -2442,6 +2553,9 @@ func (req *QueryRequest) ProcessQuery(ctx context.Context) (err error) {
 			gq.Alias != "shortest" && !gq.IsEmpty) {
 			return x.Errorf("Invalid query, query pb.id is zero and generator is nil")
 		}
+		if err := limiterFrom(ctx).limitDepth(gq); err != nil {
+			return err
+		}
 		sg, err := ToSubGraph(ctx, gq)
 		if err != nil {
 			return err
@@ -2565,6 +2679,60 @@ func (req *QueryRequest) ProcessQuery(ctx context.Context) (err error) {
 	return nil
 }
 
+// VarLen returns the number of uids or values of the variable v of the processed query.
+func (req *QueryRequest) VarLen(v string) int {
+	val, ok := req.vars[v]
+	if !ok {
+		return 0
+	}
+	if val.Uids != nil {
+		return len(val.Uids.Uids)
+	}
+	return len(val.Vals)
+}
+
+// VarUids returns the uids of the variable v of the processed query.
+func (req *QueryRequest) VarUids(v string) []uint64 {
+	val, ok := req.vars[v]
+	if !ok || val.Uids == nil {
+		return nil
+	}
+	return val.Uids.Uids
+}
+
+// ReadKeys returns the keys of the posting lists read by the processed query: the index keys
+// looked up by its eq functions, and the data keys of the predicates it read for each uid.
+func (req *QueryRequest) ReadKeys() [][]byte {
+	var keys [][]byte
+	for _, root := range req.Subgraphs {
+		root.recurse(func(sg *SubGraph) {
+			attr := sg.Attr
+			if attr == "" || attr == "uid" || attr == "_predicate_" || sg.IsInternal() ||
+				strings.HasPrefix(attr, "expand(") {
+				return
+			}
+			if fn := sg.SrcFunc; fn != nil && fn.Name == "eq" && !fn.IsCount && !fn.IsValueVar {
+				var args []string
+				for _, arg := range fn.Args {
+					args = append(args, arg.Value)
+				}
+				keys = append(keys, worker.EqIndexKeys(attr, args)...)
+			}
+			if sg.SrcUIDs == nil {
+				return
+			}
+			for _, uid := range sg.SrcUIDs.Uids {
+				if strings.HasPrefix(attr, "~") {
+					keys = append(keys, x.ReverseKey(attr[1:], uid))
+				} else {
+					keys = append(keys, x.DataKey(attr, uid))
+				}
+			}
+		})
+	}
+	return keys
+}
+
 var MutationNotAllowedErr = x.Errorf("Mutations are forbidden on this server.")
 
 type InvalidRequestError struct {
@@ -2587,6 +2755,8 @@ func (e *InternalError) Error() string {
 type ExecuteResult struct {
 	Subgraphs  []*SubGraph
 	SchemaNode []*api.SchemaNode
+	// Explain is the plan of the query, if it was asked for.
+	Explain []*ExplainStep
 }
 
 func (qr *QueryRequest) Process(ctx context.Context) (er ExecuteResult, err error) {
@@ -2595,6 +2765,11 @@ func (qr *QueryRequest) Process(ctx context.Context) (er ExecuteResult, err erro
 		return er, err
 	}
 	er.Subgraphs = qr.Subgraphs
+	if isExplain(ctx) {
+		if er.Explain, err = Explain(ctx, qr.Subgraphs); err != nil {
+			return er, x.Wrapf(err, "while explaining the query")
+		}
+	}
 
 	if qr.GqlQuery.Schema != nil {
 		if er.SchemaNode, err = worker.GetSchemaOverNetwork(ctx, qr.GqlQuery.Schema); err != nil {
@@ -1099,6 +1099,36 @@ func TestGroupByAlias(t *testing.T) {
 	require.JSONEq(t, `{"data":{"me":[{"friend":[{"@groupby":[{"school":"0x1388","MaxName":"Glenn Rhee","MinName":"Daryl Dixon","UidCount":2},{"school":"0x1389","MaxName":"Rick Grimes","MinName":"Andrea","UidCount":3}]}]}]}}`, js)
 }
 
+func TestGroupByHaving(t *testing.T) {
+	query := `
+		{
+			me(func: uid(1)) {
+				friend @groupby(school) @having(gt(UidCount, 2)) {
+					MaxName: max(name)
+					UidCount: count(uid)
+				}
+			}
+		}
+	`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t, `{"data":{"me":[{"friend":[{"@groupby":[{"school":"0x1389","MaxName":"Rick Grimes","UidCount":3}]}]}]}}`, js)
+}
+
+func TestGroupByHavingAnd(t *testing.T) {
+	query := `
+		{
+			me(func: uid(1)) {
+				friend @groupby(school) @having(gt(count, 1) and lt(MaxName, "Hz")) {
+					MaxName: max(name)
+					count(uid)
+				}
+			}
+		}
+	`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t, `{"data":{"me":[{"friend":[{"@groupby":[{"school":"0x1388","MaxName":"Glenn Rhee","count":2}]}]}]}}`, js)
+}
+
 func TestGroupByAgg(t *testing.T) {
 	query := `
 		{
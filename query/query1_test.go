@@ -19,6 +19,7 @@ package query
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -1744,6 +1745,154 @@ func TestMultiSort3(t *testing.T) {
 	require.JSONEq(t, `{"data": {"me":[{"name":"Elizabeth","age":25},{"name":"Colin","age":25},{"name":"Bob","age":25},{"name":"Alice","age":25},{"name":"Elizabeth","age":75},{"name":"Bob","age":75},{"name":"Alice","age":75},{"name":"Alice","age":75}]}}`, js)
 }
 
+func TestStreamQuery(t *testing.T) {
+
+	query := `{
+		me(func: uid(10005, 10006, 10001, 10002, 10003, 10004, 10007, 10000), orderasc: age, orderdesc: name) {
+			name
+			age
+		}
+	}`
+
+	out, err := processToStream(t, query, 3)
+	require.NoError(t, err)
+	require.Len(t, out, 3)
+	require.JSONEq(t, `{"me":[{"name":"Elizabeth","age":25},{"name":"Colin","age":25},{"name":"Bob","age":25}]}`, out[0])
+	require.JSONEq(t, `{"me":[{"name":"Alice","age":25},{"name":"Elizabeth","age":75},{"name":"Bob","age":75}]}`, out[1])
+	require.JSONEq(t, `{"me":[{"name":"Alice","age":75},{"name":"Alice","age":75}]}`, out[2])
+}
+
+func TestStreamQueryFilterFirst(t *testing.T) {
+
+	query := `{
+		me(func: uid(10005, 10006, 10001, 10002, 10003, 10004, 10007, 10000), orderasc: age, orderdesc: name, first: 3) @filter(eq(age, 75)) {
+			name
+			age
+		}
+	}`
+
+	out, err := processToStream(t, query, 2)
+	require.NoError(t, err)
+	require.Len(t, out, 2)
+	require.JSONEq(t, `{"me":[{"name":"Elizabeth","age":75},{"name":"Bob","age":75}]}`, out[0])
+	require.JSONEq(t, `{"me":[{"name":"Alice","age":75}]}`, out[1])
+}
+
+func TestStreamQueryEmpty(t *testing.T) {
+
+	query := `{
+		me(func: eq(name, "no such name")) {
+			name
+		}
+	}`
+
+	out, err := processToStream(t, query, 2)
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	require.JSONEq(t, `{"me":[]}`, out[0])
+}
+
+func TestStreamQueryVars(t *testing.T) {
+
+	query := `{
+		var(func: uid(10005, 10006)) {
+			a as age
+		}
+		me(func: uid(a)) {
+			name
+		}
+	}`
+
+	_, err := processToStream(t, query, 2)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Only a query with a single block can be streamed")
+}
+
+// pageWithCursor returns the names of a page of query and the cursor of its last node.
+func pageWithCursor(t *testing.T, query string) ([]string, string) {
+	js := processToFastJsonNoErr(t, query)
+	var res struct {
+		Data struct {
+			Me []struct {
+				Name   string `json:"name"`
+				Cursor string `json:"_cursor_"`
+			} `json:"me"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(js), &res))
+	var names []string
+	var last string
+	for _, n := range res.Data.Me {
+		require.NotEmpty(t, n.Cursor)
+		names = append(names, n.Name)
+		last = n.Cursor
+	}
+	return names, last
+}
+
+func TestCursorOrdered(t *testing.T) {
+	query := `{
+		me(func: uid(10005, 10006, 10001, 10002, 10003, 10004, 10007, 10000), orderasc: age, first: 3%s) {
+			name
+			_cursor_
+		}
+	}`
+
+	names, after := pageWithCursor(t, fmt.Sprintf(query, ""))
+	require.Equal(t, []string{"Alice", "Bob", "Colin"}, names)
+	names, after = pageWithCursor(t, fmt.Sprintf(query, fmt.Sprintf(", after: %q", after)))
+	require.Equal(t, []string{"Elizabeth", "Elizabeth", "Alice"}, names)
+	names, after = pageWithCursor(t, fmt.Sprintf(query, fmt.Sprintf(", after: %q", after)))
+	require.Equal(t, []string{"Bob", "Alice"}, names)
+	names, _ = pageWithCursor(t, fmt.Sprintf(query, fmt.Sprintf(", after: %q", after)))
+	require.Empty(t, names)
+}
+
+func TestCursorOrderedDesc(t *testing.T) {
+	query := `{
+		me(func: uid(10005, 10006, 10001, 10002, 10003, 10004, 10007, 10000), orderdesc: name, orderasc: age, first: 4%s) {
+			name
+			_cursor_
+		}
+	}`
+
+	names, after := pageWithCursor(t, fmt.Sprintf(query, ""))
+	require.Equal(t, []string{"Elizabeth", "Elizabeth", "Colin", "Bob"}, names)
+	names, _ = pageWithCursor(t, fmt.Sprintf(query, fmt.Sprintf(", after: %q", after)))
+	require.Equal(t, []string{"Bob", "Alice", "Alice", "Alice"}, names)
+}
+
+func TestCursorUnordered(t *testing.T) {
+	query := `{
+		me(func: eq(age, 75), first: 2%s) {
+			name
+			_cursor_
+		}
+	}`
+
+	names, after := pageWithCursor(t, fmt.Sprintf(query, ""))
+	require.Equal(t, []string{"Elizabeth", "Alice"}, names)
+	names, _ = pageWithCursor(t, fmt.Sprintf(query, fmt.Sprintf(", after: %q", after)))
+	require.Equal(t, []string{"Bob", "Alice"}, names)
+}
+
+func TestCursorOtherOrder(t *testing.T) {
+	_, after := pageWithCursor(t, `{
+		me(func: uid(10005, 10006, 10001, 10002), orderasc: age, first: 1) {
+			name
+			_cursor_
+		}
+	}`)
+
+	_, err := processToFastJson(t, fmt.Sprintf(`{
+		me(func: uid(10005, 10006, 10001, 10002), orderasc: name, after: %q) {
+			name
+		}
+	}`, after))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "belongs to a query with another order")
+}
+
 func TestMultiSort4(t *testing.T) {
 
 	query := `{
@@ -1798,6 +1947,20 @@ func TestMultiSort7Paginate(t *testing.T) {
 	require.JSONEq(t, `{"data": {"me":[{"name":"Alice","age":25},{"name":"Alice","age":75},{"name":"Alice","age":75},{"name":"Bob","age":25},{"name":"Bob","age":75},{"name":"Colin","age":25},{"name":"Elizabeth","age":25}]}}`, js)
 }
 
+func TestMultiSort8PaginateOffset(t *testing.T) {
+
+	// The page starts in the middle of the Alices, and is ordered by age within each name.
+	query := `{
+		me(func: uid(10005, 10006, 10001, 10002, 10003, 10004, 10007, 10000), orderasc: name, orderdesc: age, first: 4, offset: 2) {
+			name
+			age
+		}
+	}`
+
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t, `{"data": {"me":[{"name":"Alice","age":25},{"name":"Bob","age":75},{"name":"Bob","age":25},{"name":"Colin","age":25}]}}`, js)
+}
+
 func TestFilterRootOverride(t *testing.T) {
 
 	query := `{
@@ -1483,6 +1483,46 @@ func TestNearGenerator(t *testing.T) {
 	require.JSONEq(t, `{"data": {"me":[{"name":"Michonne","gender":"female"},{"name":"Rick Grimes","gender": "male"},{"name":"Glenn Rhee"}]}}`, js)
 }
 
+func TestNearGeneratorOrderByDistance(t *testing.T) {
+	query := `{
+		var(func:near(loc, [1.1,2.0], 5.001)) @filter(not uid(25)) {
+			d as distance(loc, [1.1,2.0])
+		}
+		me(func: uid(d), orderdesc: val(d), first: 1) {
+			name
+			dist: math(ceil(d))
+		}
+	}`
+
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t, `{"data": {"me":[{"name":"Glenn Rhee","dist":2.0}]}}`, js)
+}
+
+func TestGeoDistance(t *testing.T) {
+	query := `{
+		me(func: uid(1, 23)) {
+			name
+			distance(loc, [1.1,2.0])
+		}
+	}`
+
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t, `{"data": {"me":[{"name":"Michonne","distance(loc)":0.0},`+
+		`{"name":"Rick Grimes","distance(loc)":0.0}]}}`, js)
+}
+
+func TestGeoDistanceNotGeo(t *testing.T) {
+	query := `{
+		me(func: uid(1)) {
+			distance(name, [1.1,2.0])
+		}
+	}`
+
+	_, err := processToFastJson(t, query)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "distance fn can only be used on attr: [name] with schema type geo")
+}
+
 func TestNearGeneratorFilter(t *testing.T) {
 
 	query := `{
@@ -25,6 +25,8 @@ import (
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/schema"
 	"github.com/dgraph-io/dgraph/types"
+	"github.com/dgraph-io/dgraph/worker"
+	"github.com/dgraph-io/dgraph/x"
 	"github.com/stretchr/testify/require"
 )
 
@@ -162,6 +164,51 @@ func TestRecurseQueryLimitDepth2(t *testing.T) {
 		`{"data": {"me":[{"uid":"0x1","friend":[{"uid":"0x17","name":"Rick Grimes"},{"uid":"0x18","name":"Glenn Rhee"},{"uid":"0x19","name":"Daryl Dixon"},{"uid":"0x1f","name":"Andrea"},{"uid":"0x65"}],"name":"Michonne"}]}}`, js)
 }
 
+func TestRecurseQueryLevels(t *testing.T) {
+
+	query := `
+		{
+			me(func: uid(0x01)) @recurse(depth: 4, levels: "friend; path") {
+				friend
+				path
+				name
+			}
+		}`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t,
+		`{"data": {"me":[{"name":"Michonne", "friend":[{"name":"Rick Grimes"},{"name":"Glenn Rhee"},{"name":"Daryl Dixon"},{"name":"Andrea", "path":[{"name":"Alice", "path":[{"name":"Bob"},{"name":"Matt"}]}]}]}]}}`, js)
+}
+
+func TestRecurseQueryLevelsError(t *testing.T) {
+
+	query := `
+		{
+			me(func: uid(0x01)) @recurse(levels: "friend; follows") {
+				friend
+				name
+			}
+		}`
+
+	ctx := defaultContext()
+	_, err := processToFastJsonCtxVars(t, query, ctx, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Predicate follows in levels of @recurse is not in the block")
+}
+
+func TestRecurseQueryTruncated(t *testing.T) {
+
+	query := `
+		{
+			me(func: uid(0x01)) @recurse(truncated: true) {
+				friend
+				name
+			}
+		}`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t,
+		`{"data": {"me":[{"name":"Michonne", "friend":[{"name":"Rick Grimes", "friend":[{"name":"Michonne", "_truncated_":true}]},{"name":"Glenn Rhee"},{"name":"Daryl Dixon"},{"name":"Andrea", "friend":[{"name":"Glenn Rhee"}]}]}]}}`, js)
+}
+
 func TestRecurseVariable(t *testing.T) {
 
 	query := `
@@ -465,6 +512,52 @@ func TestShortestPathWeights(t *testing.T) {
 		js)
 }
 
+func TestShortestPathWeightFacet(t *testing.T) {
+
+	query := `
+		{
+			A as shortest(from:1, to:1002, weightFacet: "weight") {
+				path
+			}
+
+			me(func: uid( A)) {
+				name
+			}
+		}`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t,
+		`{"data":{"me":[{"name":"Michonne"},{"name":"Andrea"},{"name":"Alice"},{"name":"Bob"},{"name":"Matt"}],"_path_":[{"uid":"0x1","path":[{"uid":"0x1f","path":[{"uid":"0x3e8","path":[{"uid":"0x3e9","path":[{"uid":"0x3ea","path|weight":0.100000}],"path|weight":0.100000}],"path|weight":0.100000}],"path|weight":0.100000}]}]}}`,
+		js)
+}
+
+func TestShortestPathWeightFacetMultiFacet(t *testing.T) {
+
+	query := `
+		{
+			shortest(from:1, to:1002, weightFacet: weight) {
+				path @facets(weight, weight1)
+			}
+		}`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t,
+		`{"data":{"_path_":[{"uid":"0x1","path":[{"uid":"0x1f","path":[{"uid":"0x3e8","path":[{"uid":"0x3e9","path":[{"uid":"0x3ea","path|weight":0.100000}],"path|weight":0.100000}],"path|weight":0.100000}],"path|weight":0.100000,"path|weight1":0.200000}]}]}}`,
+		js)
+}
+
+func TestKShortestPathWeightFacet(t *testing.T) {
+
+	query := `
+		{
+			shortest(from: 1, to:1003, numpaths: 2, weightFacet: "weight") {
+				path
+			}
+		}`
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t,
+		`{"data":{"_path_":[{"uid":"0x1","path":[{"uid":"0x1f","path":[{"uid":"0x3e8","path":[{"uid":"0x3e9","path":[{"uid":"0x3ea","path":[{"uid":"0x3eb","path|weight":0.600000}],"path|weight":0.100000}],"path|weight":0.100000}],"path|weight":0.100000}],"path|weight":0.100000}]},{"uid":"0x1","path":[{"uid":"0x1f","path":[{"uid":"0x3e8","path":[{"uid":"0x3ea","path":[{"uid":"0x3eb","path|weight":0.600000}],"path|weight":0.700000}],"path|weight":0.100000}],"path|weight":0.100000}]}]}}`,
+		js)
+}
+
 func TestShortestPath2(t *testing.T) {
 
 	query := `
@@ -1778,3 +1871,36 @@ func TestFilterRegex16(t *testing.T) {
 		`{"data": {"me":[{"name@ru":"Артём Ткаченко"}]}}`,
 		js)
 }
+
+func TestReadKeys(t *testing.T) {
+	query := `
+		{
+			me(func: eq(full_name, "Michonne's large name for hashing")) {
+				name
+				friend @filter(eq(alias, "Zambo Alice"))
+			}
+		}
+	`
+	res, err := gql.Parse(gql.Request{Str: query})
+	require.NoError(t, err)
+	startTs := timestamp()
+	maxPendingCh <- startTs
+	queryRequest := QueryRequest{Latency: &Latency{}, GqlQuery: &res, ReadTs: startTs}
+	require.NoError(t, queryRequest.ProcessQuery(defaultContext()))
+
+	keys := make(map[string]bool)
+	for _, key := range queryRequest.ReadKeys() {
+		keys[string(key)] = true
+	}
+	indexKeys := worker.EqIndexKeys("full_name", []string{"Michonne's large name for hashing"})
+	require.Len(t, indexKeys, 1)
+	require.True(t, keys[string(indexKeys[0])])
+	require.True(t, keys[string(x.DataKey("name", 1))])
+	require.True(t, keys[string(x.DataKey("friend", 1))])
+	// The filter reads the alias index, and the alias of each friend.
+	aliasKeys := worker.EqIndexKeys("alias", []string{"Zambo Alice"})
+	require.Len(t, aliasKeys, 1)
+	require.True(t, keys[string(aliasKeys[0])])
+	require.True(t, keys[string(x.DataKey("alias", 23))])
+	require.False(t, keys[string(x.DataKey("name", 23))])
+}
@@ -544,6 +544,45 @@ func TestFacetsFilterge(t *testing.T) {
 		js)
 }
 
+func TestFacetsFilterBetween(t *testing.T) {
+	populateGraphWithFacets(t)
+	defer teardownGraphWithFacets(t)
+	// find friends of 1 since 2005 or 2006, with a name.
+	query := `
+		{
+			me(func: uid(0x1)) {
+				name
+				friend @facets(between(since, "2005-01-01", "2006-12-31")) @filter(has(name)) {
+					name
+					uid
+				}
+			}
+		}
+	`
+
+	js := processToFastJsonNoErr(t, query)
+	require.JSONEq(t,
+		`{"data": {"me":[{"friend":[{"uid":"0x17","name":"Rick Grimes"},{"uid":"0x1f","name":"Andrea"}],"name":"Michonne"}]}}`,
+		js)
+}
+
+func TestFacetsFilterBetweenOneArg(t *testing.T) {
+	populateGraphWithFacets(t)
+	defer teardownGraphWithFacets(t)
+	query := `
+		{
+			me(func: uid(0x1)) {
+				friend @facets(between(since, "2005-01-01")) {
+					uid
+				}
+			}
+		}
+	`
+
+	_, err := processToFastJson(t, query)
+	require.Error(t, err)
+}
+
 func TestFacetsFilterAndOrle(t *testing.T) {
 	populateGraphWithFacets(t)
 	defer teardownGraphWithFacets(t)
@@ -30,6 +30,8 @@ func (start *SubGraph) expandRecurse(ctx context.Context, maxDepth uint64) error
 	// Note: Key format is - "attr|fromUID|toUID"
 	reachMap := make(map[string]struct{})
 	allowLoop := start.Params.RecurseArgs.AllowLoop
+	markTruncated := start.Params.RecurseArgs.Truncated
+	levels := recurseLevels(start.Params.RecurseArgs.Levels)
 	var numEdges uint64
 	var exec []*SubGraph
 	var err error
@@ -52,7 +54,7 @@ func (start *SubGraph) expandRecurse(ctx context.Context, maxDepth uint64) error
 	}
 
 	// Add children back and expand if necessary
-	if exec, err = expandChildren(ctx, start, startChildren); err != nil {
+	if exec, err = expandChildren(ctx, start, startChildren, levels, 1); err != nil {
 		return err
 	}
 
@@ -105,6 +107,12 @@ func (start *SubGraph) expandRecurse(ctx context.Context, maxDepth uint64) error
 						key := fmt.Sprintf("%s|%d|%d", sg.Attr, fromUID, uid)
 						_, seen := reachMap[key] // Combine fromUID here.
 						if seen {
+							if markTruncated {
+								if sg.truncated == nil {
+									sg.truncated = make(map[uint64]bool)
+								}
+								sg.truncated[fromUID] = true
+							}
 							return false
 						} else {
 							// Mark this edge as taken. We'd disallow this edge later.
@@ -130,7 +138,7 @@ func (start *SubGraph) expandRecurse(ctx context.Context, maxDepth uint64) error
 			if len(sg.DestUIDs.Uids) == 0 {
 				continue
 			}
-			if exp, err = expandChildren(ctx, sg, startChildren); err != nil {
+			if exp, err = expandChildren(ctx, sg, startChildren, levels, depth+1); err != nil {
 				return err
 			}
 			out = append(out, exp...)
@@ -148,8 +156,42 @@ func (start *SubGraph) expandRecurse(ctx context.Context, maxDepth uint64) error
 	}
 }
 
-// expandChildren adds child nodes to a SubGraph with no children, expanding them if necessary.
-func expandChildren(ctx context.Context, sg *SubGraph, children []*SubGraph) ([]*SubGraph, error) {
+// recurseLevels are the predicates followed at each depth of a recurse query.
+type recurseLevels [][]string
+
+// follows returns whether the predicate of child is followed at depth, starting from 1.
+func (levels recurseLevels) follows(child *SubGraph, depth uint64) bool {
+	if len(levels) == 0 {
+		return true
+	}
+	named := func(preds []string) bool {
+		for _, pred := range preds {
+			if pred == child.Attr || (child.Params.Alias != "" && pred == child.Params.Alias) {
+				return true
+			}
+		}
+		return false
+	}
+	idx := uint64(len(levels)) - 1
+	if depth-1 < idx {
+		idx = depth - 1
+	}
+	if named(levels[idx]) {
+		return true
+	}
+	for _, preds := range levels {
+		if named(preds) {
+			return false
+		}
+	}
+	// Predicates not in any level are followed at every depth.
+	return true
+}
+
+// expandChildren adds child nodes to a SubGraph with no children, expanding them if necessary,
+// keeping those followed at depth.
+func expandChildren(ctx context.Context, sg *SubGraph, children []*SubGraph,
+	levels recurseLevels, depth uint64) ([]*SubGraph, error) {
 	if len(sg.Children) > 0 {
 		return nil, errors.New("Subgraph should not have any children")
 	}
@@ -163,6 +205,9 @@ func expandChildren(ctx context.Context, sg *SubGraph, children []*SubGraph) ([]
 	sg.Children = sg.Children[:0]
 	// Link new child nodes back to parent destination UIDs
 	for _, child := range expandedChildren {
+		if !levels.follows(child, depth) {
+			continue
+		}
 		newChild := new(SubGraph)
 		newChild.copyFiltersRecurse(child)
 		newChild.SrcUIDs = sg.DestUIDs
@@ -178,6 +223,9 @@ func Recurse(ctx context.Context, sg *SubGraph) error {
 		return x.Errorf("Invalid recurse path query")
 	}
 
+	if err := sg.checkRecurseLevels(); err != nil {
+		return err
+	}
 	depth := sg.Params.RecurseArgs.Depth
 	if depth == 0 {
 		if sg.Params.RecurseArgs.AllowLoop {
@@ -189,3 +237,28 @@ func Recurse(ctx context.Context, sg *SubGraph) error {
 	}
 	return sg.expandRecurse(ctx, depth)
 }
+
+// checkRecurseLevels checks that the predicates in the levels of @recurse are in the block.
+func (sg *SubGraph) checkRecurseLevels() error {
+	for _, child := range sg.Children {
+		if child.Params.Expand != "" {
+			// The predicates are only known once expanded.
+			return nil
+		}
+	}
+	for _, preds := range sg.Params.RecurseArgs.Levels {
+		for _, pred := range preds {
+			found := false
+			for _, child := range sg.Children {
+				if pred == child.Attr || pred == child.Params.Alias {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return x.Errorf("Predicate %s in levels of @recurse is not in the block", pred)
+			}
+		}
+	}
+	return nil
+}
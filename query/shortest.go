@@ -20,6 +20,7 @@ import (
 	"container/heap"
 	"context"
 	"math"
+	"sort"
 	"sync"
 
 	"github.com/dgraph-io/dgraph/algo"
@@ -96,7 +97,9 @@ type nodeInfo struct {
 	node *Item
 }
 
-func (sg *SubGraph) getCost(matrix, list int) (cost float64,
+// getCost returns the cost of an edge, taken from the facet with the key weightFacet, or else
+// from the only facet of the edge, or 1 if the edge has no facets.
+func (sg *SubGraph) getCost(matrix, list int, weightFacet string) (cost float64,
 	fcs *pb.Facets, rerr error) {
 
 	cost = 1.0
@@ -113,11 +116,24 @@ func (sg *SubGraph) getCost(matrix, list int) (cost float64,
 		rerr = ErrFacet
 		return cost, fcs, rerr
 	}
-	if len(fcs.Facets) > 1 {
+	weight := fcs.Facets[0]
+	if weightFacet != "" {
+		weight = nil
+		for _, f := range fcs.Facets {
+			if f.Key == weightFacet {
+				weight = f
+				break
+			}
+		}
+		if weight == nil {
+			rerr = ErrFacet
+			return cost, fcs, rerr
+		}
+	} else if len(fcs.Facets) > 1 {
 		rerr = x.Errorf("Expected 1 but got %d facets", len(fcs.Facets))
 		return cost, fcs, rerr
 	}
-	tv, err := facets.ValFor(fcs.Facets[0])
+	tv, err := facets.ValFor(weight)
 	if err != nil {
 		return 0.0, nil, err
 	}
@@ -128,9 +144,41 @@ func (sg *SubGraph) getCost(matrix, list int) (cost float64,
 	} else {
 		rerr = ErrFacet
 	}
+	if rerr == nil && weightFacet != "" && cost < 0 {
+		rerr = x.Errorf("Expected a non-negative weight in facet %s but got %v", weightFacet, cost)
+	}
 	return cost, fcs, rerr
 }
 
+// fetchWeightFacet makes the predicates of the shortest path block fetch the facet giving the
+// weights of their edges.
+func (sg *SubGraph) fetchWeightFacet() {
+	key := sg.Params.weightFacet
+	if key == "" {
+		return
+	}
+	for _, child := range sg.Children {
+		fp := child.Params.Facet
+		if fp == nil {
+			child.Params.Facet = &pb.FacetParams{Param: []*pb.FacetParam{{Key: key}}}
+			continue
+		}
+		if fp.AllKeys {
+			continue
+		}
+		idx := sort.Search(len(fp.Param), func(i int) bool {
+			return fp.Param[i].Key >= key
+		})
+		if idx < len(fp.Param) && fp.Param[idx].Key == key {
+			continue
+		}
+		// The keys are kept sorted.
+		fp.Param = append(fp.Param, nil)
+		copy(fp.Param[idx+1:], fp.Param[idx:])
+		fp.Param[idx] = &pb.FacetParam{Key: key}
+	}
+}
+
 func (start *SubGraph) expandOut(ctx context.Context,
 	adjacencyMap map[uint64]map[uint64]mapItem, next chan bool, rch chan error) {
 
@@ -154,7 +202,8 @@ func (start *SubGraph) expandOut(ctx context.Context,
 		}
 		rrch := make(chan error, len(exec))
 		for _, sg := range exec {
-			go ProcessGraph(ctx, sg, dummy, rrch)
+			// The edges explored aren't all returned. They're limited by QueryEdgeLimit.
+			go ProcessGraph(withoutLimits(ctx), sg, dummy, rrch)
 		}
 
 		for range exec {
@@ -183,7 +232,7 @@ func (start *SubGraph) expandOut(ctx context.Context,
 							adjacencyMap[fromUID] = make(map[uint64]mapItem)
 						}
 						// The default cost we'd use is 1.
-						cost, facet, err := sg.getCost(mIdx, lIdx)
+						cost, facet, err := sg.getCost(mIdx, lIdx, start.Params.weightFacet)
 						if err == ErrFacet {
 							// Ignore the edge and continue.
 							continue
@@ -418,6 +467,7 @@ func ShortestPath(ctx context.Context, sg *SubGraph) ([]*SubGraph, error) {
 	if sg.Params.Alias != "shortest" {
 		return nil, x.Errorf("Invalid shortest path query")
 	}
+	sg.fetchWeightFacet()
 	numPaths := sg.Params.numPaths
 	if numPaths == 0 {
 		// Return 1 path by default.
@@ -464,12 +514,10 @@ func ShortestPath(ctx context.Context, sg *SubGraph) ([]*SubGraph, error) {
 		if item.uid == sg.Params.To {
 			break
 		}
-		if item.hop > numHops && numHops < maxHops {
+		if item.hop > numHops && numHops < maxHops && !stopExpansion {
 			// Explore the next level by calling processGraph and add them
 			// to the queue.
-			if !stopExpansion {
-				next <- true
-			}
+			next <- true
 			select {
 			case err = <-expandErr:
 				if err != nil {
@@ -490,49 +538,49 @@ func ShortestPath(ctx context.Context, sg *SubGraph) ([]*SubGraph, error) {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
-			if !stopExpansion {
-				neighbours := adjacencyMap[item.uid]
-				for toUid, info := range neighbours {
-					cost := info.cost
-					d, ok := dist[toUid]
-					if ok && d.cost <= item.cost+cost {
-						continue
+			// The edges of the last level are known even once the expansion has stopped, and
+			// with weighted edges a path with more hops can still be shorter.
+			neighbours := adjacencyMap[item.uid]
+			for toUid, info := range neighbours {
+				cost := info.cost
+				d, ok := dist[toUid]
+				if ok && d.cost <= item.cost+cost {
+					continue
+				}
+				if !ok {
+					// This is the first time we're seeing this node. So
+					// create a new node and add it to the heap and map.
+					node := &Item{
+						uid:  toUid,
+						cost: item.cost + cost,
+						hop:  item.hop + 1,
 					}
-					if !ok {
-						// This is the first time we're seeing this node. So
-						// create a new node and add it to the heap and map.
-						node := &Item{
-							uid:  toUid,
-							cost: item.cost + cost,
-							hop:  item.hop + 1,
-						}
-						heap.Push(&pq, node)
-						dist[toUid] = nodeInfo{
-							parent: item.uid,
-							node:   node,
-							mapItem: mapItem{
-								cost:  item.cost + cost,
-								attr:  info.attr,
-								facet: info.facet,
-							},
-						}
-					} else {
-						// We've already seen this node. So, just update the cost
-						// and fix the priority in the heap and map.
-						node := dist[toUid].node
-						node.cost = item.cost + cost
-						node.hop = item.hop + 1
-						heap.Fix(&pq, node.index)
-						// Update the map with new values.
-						dist[toUid] = nodeInfo{
-							parent: item.uid,
-							node:   node,
-							mapItem: mapItem{
-								cost:  item.cost + cost,
-								attr:  info.attr,
-								facet: info.facet,
-							},
-						}
+					heap.Push(&pq, node)
+					dist[toUid] = nodeInfo{
+						parent: item.uid,
+						node:   node,
+						mapItem: mapItem{
+							cost:  item.cost + cost,
+							attr:  info.attr,
+							facet: info.facet,
+						},
+					}
+				} else {
+					// We've already seen this node. So, just update the cost
+					// and fix the priority in the heap and map.
+					node := dist[toUid].node
+					node.cost = item.cost + cost
+					node.hop = item.hop + 1
+					heap.Fix(&pq, node.index)
+					// Update the map with new values.
+					dist[toUid] = nodeInfo{
+						parent: item.uid,
+						node:   node,
+						mapItem: mapItem{
+							cost:  item.cost + cost,
+							attr:  info.attr,
+							facet: info.facet,
+						},
 					}
 				}
 			}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"context"
+
+	"github.com/dgraph-io/dgraph/algo"
+	"github.com/dgraph-io/dgraph/gql"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// StreamBatchSize is the default number of root uids whose results are encoded together by
+// ProcessStream.
+const StreamBatchSize = 1000
+
+// ProcessStream processes a query with a single block, batch uids of its root at a time, so
+// that only the results of one batch are held in memory. The root is first resolved on its
+// own, applying its function, filter, order and pagination. The children are then fetched
+// for each batch of the resulting uids, in order, and the JSON of the batch, shaped like the
+// response of the whole block, is handed to send.
+func (req *QueryRequest) ProcessStream(ctx context.Context, batch int,
+	send func(json []byte) error) error {
+	if batch <= 0 {
+		batch = StreamBatchSize
+	}
+	gq, err := streamBlock(req.GqlQuery)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the uids of the root, without its children.
+	root := *gq
+	root.Children = nil
+	root.Cascade = false
+	rootReq := req.block(&root)
+	if err := rootReq.ProcessQuery(ctx); err != nil {
+		return err
+	}
+	sg := rootReq.Subgraphs[0]
+	var uids []uint64
+	if len(sg.uidMatrix) > 0 {
+		for _, uid := range sg.uidMatrix[0].Uids {
+			if algo.IndexOf(sg.DestUIDs, uid) >= 0 {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	if len(uids) == 0 {
+		json, err := ToJson(req.Latency, rootReq.Subgraphs)
+		if err != nil {
+			return err
+		}
+		return send(json)
+	}
+
+	for start := 0; start < len(uids); start += batch {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + batch
+		if end > len(uids) {
+			end = len(uids)
+		}
+		batchReq := req.block(batchBlock(gq, uids[start:end]))
+		if err := batchReq.ProcessQuery(ctx); err != nil {
+			return err
+		}
+		json, err := ToJson(req.Latency, batchReq.Subgraphs)
+		if err != nil {
+			return err
+		}
+		if err := send(json); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamBlock returns the block of a query that can be streamed: a single block, without
+// variables, whose result for a root uid doesn't depend on the other root uids.
+func streamBlock(res *gql.Result) (*gql.GraphQuery, error) {
+	if len(res.Query) != 1 || res.Schema != nil {
+		return nil, x.Errorf("Only a query with a single block can be streamed")
+	}
+	for _, vars := range res.QueryVars {
+		if len(vars.Defines) > 0 || len(vars.Needs) > 0 {
+			return nil, x.Errorf("Query variables are not supported while streaming")
+		}
+	}
+	gq := res.Query[0]
+	switch {
+	case gq.Alias == "shortest":
+		return nil, x.Errorf("Shortest path queries can't be streamed")
+	case gq.Recurse:
+		return nil, x.Errorf("@recurse queries can't be streamed")
+	case gq.IsGroupby:
+		return nil, x.Errorf("@groupby at root can't be streamed")
+	case gq.UidCount:
+		return nil, x.Errorf("count(uid) at root can't be streamed")
+	case gq.IsEmpty:
+		return nil, x.Errorf("Aggregation blocks can't be streamed")
+	}
+	return gq, nil
+}
+
+// batchBlock returns a copy of the block gq that fetches the uids of a batch of its root, in
+// the order they were resolved in.
+func batchBlock(gq *gql.GraphQuery, uids []uint64) *gql.GraphQuery {
+	b := *gq
+	b.Func = &gql.Function{Name: "uid"}
+	b.UID = append(uids[:0:0], uids...)
+	b.Filter = nil
+	b.Args = make(map[string]string)
+	for k, v := range gq.Args {
+		switch k {
+		case "first", "offset", "after":
+		default:
+			b.Args[k] = v
+		}
+	}
+	return &b
+}
+
+// block returns a request for the single block gq, at the same timestamp as req.
+func (req *QueryRequest) block(gq *gql.GraphQuery) *QueryRequest {
+	return &QueryRequest{
+		ReadTs:  req.ReadTs,
+		Latency: req.Latency,
+		GqlQuery: &gql.Result{
+			Query:     []*gql.GraphQuery{gq},
+			QueryVars: []*gql.Vars{{}},
+		},
+	}
+}
@@ -82,6 +82,14 @@ L:
 				return rnq, x.Errorf("Expected variable name, found: %s", item.Val)
 			}
 
+			// The uids of a query variable, substituted in an upsert.
+			uidVar := "uid(" + strings.TrimSpace(item.Val) + ")"
+			if rnq.Subject == "" {
+				rnq.Subject = uidVar
+			} else {
+				rnq.ObjectId = uidVar
+			}
+
 			it.Next() // parse ')'
 
 		case itemPredicate:
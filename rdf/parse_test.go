@@ -50,6 +50,24 @@ var testNQuads = []struct {
 			ObjectValue: nil,
 		},
 	},
+	{
+		input: `uid(v) <predicate> uid( w ) .`,
+		nq: api.NQuad{
+			Subject:     "uid(v)",
+			Predicate:   "predicate",
+			ObjectId:    "uid(w)",
+			ObjectValue: nil,
+		},
+	},
+	{
+		input: `uid(v) <name> "Alice" .`,
+		nq: api.NQuad{
+			Subject:     "uid(v)",
+			Predicate:   "name",
+			ObjectId:    "",
+			ObjectValue: &api.Value{Val: &api.Value_DefaultVal{DefaultVal: "Alice"}},
+		},
+	},
 	{
 		input: `_:alice <predicate> <object_id> .`,
 		nq: api.NQuad{
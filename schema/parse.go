@@ -17,6 +17,7 @@
 package schema
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/dgraph-io/dgraph/lex"
@@ -62,7 +63,7 @@ func parseDirective(it *lex.ItemIterator, schema *pb.SchemaUpdate, t types.TypeI
 		}
 		schema.Directive = pb.SchemaUpdate_REVERSE
 	case "index":
-		if tokenizer, err := parseIndexDirective(it, schema.Predicate, t); err != nil {
+		if tokenizer, err := parseIndexDirective(it, schema, t); err != nil {
 			return err
 		} else {
 			schema.Directive = pb.SchemaUpdate_INDEX
@@ -174,9 +175,11 @@ func parseScalarPair(it *lex.ItemIterator, predicate string) (*pb.SchemaUpdate,
 	return schema, nil
 }
 
-// parseIndexDirective works on "@index" or "@index(customtokenizer)".
-func parseIndexDirective(it *lex.ItemIterator, predicate string,
+// parseIndexDirective works on "@index" or "@index(customtokenizer)". The options of a
+// tokenizer, like "@index(fulltext(lang: "de"))", are set in schema.
+func parseIndexDirective(it *lex.ItemIterator, schema *pb.SchemaUpdate,
 	typ types.TypeID) ([]string, error) {
+	predicate := schema.Predicate
 	var tokenizers []string
 	var seen = make(map[string]bool)
 	var seenSortableTok bool
@@ -240,6 +243,16 @@ func parseIndexDirective(it *lex.ItemIterator, predicate string,
 			}
 			seenSortableTok = true
 		}
+		if next, ok := it.PeekOne(); ok && next.Typ == itemLeftRound {
+			if tokenizer.Name() != "fulltext" {
+				return nil, x.Errorf("Tokenizer: %s doesn't take options for pred: %s",
+					tokenizer.Name(), predicate)
+			}
+			it.Next()
+			if err := parseFulltextOptions(it, schema); err != nil {
+				return nil, err
+			}
+		}
 		tokenizers = append(tokenizers, tokenizer.Name())
 		seen[tokenizer.Name()] = true
 		expectArg = false
@@ -247,6 +260,68 @@ func parseIndexDirective(it *lex.ItemIterator, predicate string,
 	return tokenizers, nil
 }
 
+// parseFulltextOptions parses the options of a fulltext index, after the left round bracket,
+// like (lang: "de", stopwords: false, stemming: false). By default values without a language
+// tag are analyzed as English, stop words are removed and terms are stemmed.
+func parseFulltextOptions(it *lex.ItemIterator, schema *pb.SchemaUpdate) error {
+	expectArg := true
+	for it.Next() {
+		next := it.Item()
+		switch {
+		case next.Typ == itemRightRound:
+			if expectArg {
+				return x.Errorf("Expected a fulltext option for pred: %s", schema.Predicate)
+			}
+			return nil
+		case next.Typ == itemComma && !expectArg:
+			expectArg = true
+			continue
+		case next.Typ != itemText || !expectArg:
+			return x.Errorf("Expected a fulltext option but got: %v", next.Val)
+		}
+		name := strings.ToLower(next.Val)
+		if !it.Next() || it.Item().Typ != itemColon {
+			return x.Errorf("Expected a colon after fulltext option %s", name)
+		}
+		if !it.Next() {
+			return x.Errorf("Invalid ending.")
+		}
+		val := it.Item()
+		if val.Typ == itemQuotedText {
+			v, err := strconv.Unquote(val.Val)
+			if err != nil {
+				return x.Wrapf(err, "Invalid value for fulltext option %s", name)
+			}
+			val.Val = v
+		} else if val.Typ != itemText {
+			return x.Errorf("Expected a value for fulltext option %s but got: %v",
+				name, val.Val)
+		}
+		switch name {
+		case "lang":
+			if val.Val == "" {
+				return x.Errorf("Empty lang for fulltext index of pred: %s", schema.Predicate)
+			}
+			schema.FulltextLang = val.Val
+		case "stopwords", "stemming":
+			on, err := strconv.ParseBool(val.Val)
+			if err != nil {
+				return x.Errorf("Expected true or false for fulltext option %s but got: %v",
+					name, val.Val)
+			}
+			if name == "stopwords" {
+				schema.FulltextKeepStopwords = !on
+			} else {
+				schema.FulltextNoStem = !on
+			}
+		default:
+			return x.Errorf("Invalid fulltext option %s for pred: %s", name, schema.Predicate)
+		}
+		expectArg = false
+	}
+	return x.Errorf("Invalid ending.")
+}
+
 // resolveTokenizers resolves default tokenizers and verifies tokenizers definitions.
 func resolveTokenizers(updates []*pb.SchemaUpdate) error {
 	for _, schema := range updates {
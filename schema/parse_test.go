@@ -376,3 +376,47 @@ func TestParseUpsert(t *testing.T) {
 	`)
 	require.NoError(t, err)
 }
+
+func TestParseFulltextOptions(t *testing.T) {
+	reset()
+	schemas, err := Parse(`
+		title: string @index(fulltext(lang: "de", stopwords: false, stemming: false), term) .
+		body: string @index(fulltext(lang: fr)) .
+	`)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(schemas))
+	require.EqualValues(t, &pb.SchemaUpdate{
+		Predicate:             "title",
+		ValueType:             9,
+		Directive:             pb.SchemaUpdate_INDEX,
+		Tokenizer:             []string{"fulltext", "term"},
+		FulltextLang:          "de",
+		FulltextKeepStopwords: true,
+		FulltextNoStem:        true,
+	}, schemas[0])
+	require.Equal(t, "fr", schemas[1].FulltextLang)
+	require.False(t, schemas[1].FulltextKeepStopwords)
+	require.False(t, schemas[1].FulltextNoStem)
+
+	require.Equal(t, `title:string @index(fulltext(lang: "de", stopwords: false, `+
+		`stemming: false),term)`, Format("title", *schemas[0]))
+	require.Equal(t, `body:string @index(fulltext(lang: "fr"))`, Format("body", *schemas[1]))
+}
+
+func TestParseFulltextOptionsError(t *testing.T) {
+	reset()
+	_, err := Parse(`title: string @index(term(lang: "de")) .`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't take options")
+
+	_, err = Parse(`title: string @index(fulltext(analyzer: "de")) .`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Invalid fulltext option analyzer")
+
+	_, err = Parse(`title: string @index(fulltext(stemming: maybe)) .`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Expected true or false")
+
+	_, err = Parse(`title: string @index(fulltext()) .`)
+	require.Error(t, err)
+}
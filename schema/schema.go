@@ -19,6 +19,7 @@ package schema
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/dgraph-io/badger"
@@ -165,11 +166,21 @@ func (s *state) Tokenizer(pred string) []tok.Tokenizer {
 	for _, it := range schema.Tokenizer {
 		t, found := tok.GetTokenizer(it)
 		x.AssertTruef(found, "Invalid tokenizer %s", it)
-		tokenizers = append(tokenizers, t)
+		tokenizers = append(tokenizers, IndexTokenizer(schema, t))
 	}
 	return tokenizers
 }
 
+// IndexTokenizer returns the tokenizer t with the options set for it in the schema of the
+// predicate, like the language of a fulltext index.
+func IndexTokenizer(schema *pb.SchemaUpdate, t tok.Tokenizer) tok.Tokenizer {
+	if _, ok := t.(tok.FullTextTokenizer); ok {
+		return tok.GetFullTextTokenizer(schema.FulltextLang, schema.FulltextKeepStopwords,
+			schema.FulltextNoStem)
+	}
+	return t
+}
+
 // TokenizerNames returns the tokenizer names for given predicate
 func (s *state) TokenizerNames(pred string) []string {
 	var names []string
@@ -296,6 +307,72 @@ func LoadFromDb() error {
 	return nil
 }
 
+// Format returns the schema of attr in the format of a schema file, e.g.
+// "name:string @index(exact)", without the ending dot.
+func Format(attr string, update pb.SchemaUpdate) string {
+	// bytes.Buffer never returns error for any of the writes. So, we don't need to check them.
+	var buf bytes.Buffer
+	if strings.ContainsRune(attr, ':') {
+		buf.WriteRune('<')
+		buf.WriteString(attr)
+		buf.WriteRune('>')
+	} else {
+		buf.WriteString(attr)
+	}
+	buf.WriteByte(':')
+	if update.List {
+		buf.WriteRune('[')
+	}
+	buf.WriteString(types.TypeID(update.ValueType).Name())
+	if update.List {
+		buf.WriteRune(']')
+	}
+	if update.Directive == pb.SchemaUpdate_REVERSE {
+		buf.WriteString(" @reverse")
+	} else if update.Directive == pb.SchemaUpdate_INDEX && len(update.Tokenizer) > 0 {
+		buf.WriteString(" @index(")
+		for i, name := range update.Tokenizer {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(name)
+			if name == "fulltext" {
+				buf.WriteString(fulltextOptions(update))
+			}
+		}
+		buf.WriteByte(')')
+	}
+	if update.Count {
+		buf.WriteString(" @count")
+	}
+	if update.Lang {
+		buf.WriteString(" @lang")
+	}
+	if update.Upsert {
+		buf.WriteString(" @upsert")
+	}
+	return buf.String()
+}
+
+// fulltextOptions formats the options of the fulltext index, like (lang: "de", stemming: false),
+// or returns an empty string if none are set.
+func fulltextOptions(update pb.SchemaUpdate) string {
+	var opts []string
+	if update.FulltextLang != "" {
+		opts = append(opts, fmt.Sprintf("lang: %q", update.FulltextLang))
+	}
+	if update.FulltextKeepStopwords {
+		opts = append(opts, "stopwords: false")
+	}
+	if update.FulltextNoStem {
+		opts = append(opts, "stemming: false")
+	}
+	if len(opts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(opts, ", ") + ")"
+}
+
 func reset() {
 	pstate = new(state)
 	pstate.init()
@@ -35,6 +35,7 @@ const (
 	itemUnderscore
 	itemLeftSquare
 	itemRightSquare
+	itemQuotedText // quoted string, like "de"
 )
 
 func lexText(l *lex.Lexer) lex.StateFn {
@@ -54,6 +55,11 @@ Loop:
 			l.Emit(itemDot)
 		case r == ',':
 			l.Emit(itemComma)
+		case r == '"':
+			if err := l.LexQuotedString(); err != nil {
+				return l.Errorf("Invalid schema: %v", err)
+			}
+			l.Emit(itemQuotedText)
 		case r == '<':
 			if err := lex.LexIRIRef(l, itemText); err != nil {
 				return l.Errorf("Invalid schema: %v", err)
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Limiter limits the rate of the bytes sent by the streams sharing it. It's safe for concurrent
+// use. A nil Limiter doesn't limit anything.
+type Limiter struct {
+	sync.Mutex
+	rate float64   // bytes per second
+	next time.Time // when the bytes let through so far are within the rate
+}
+
+// NewLimiter returns a limiter of mbps MB per second, or nil if mbps isn't positive.
+func NewLimiter(mbps float64) *Limiter {
+	if mbps <= 0 {
+		return nil
+	}
+	return &Limiter{rate: mbps * 1e6}
+}
+
+// Wait blocks until n more bytes can be sent, or ctx is done. The time spent idle isn't saved up
+// for later, so there are no bursts above the rate.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	d := l.next.Sub(now)
+	l.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -43,6 +43,16 @@ type Lists struct {
 	DB            *badger.DB
 	ChooseKeyFunc func(item *badger.Item) bool
 	ItemToKVFunc  func(key []byte, itr *badger.Iterator) (*pb.KV, error)
+
+	// Ordered makes the stream send the keys in order, in batches of about 4MB, so that the
+	// receiver knows all the keys up to the last one it got. The keys are then read by a single
+	// goroutine, instead of 16.
+	Ordered bool
+	// Since, if set, makes the stream start after this key. It's used with Ordered, to resume a
+	// stream after the last key received.
+	Since []byte
+	// Limiter, if set, limits the rate of the batches sent.
+	Limiter *Limiter
 }
 
 // keyRange is [start, end), including start, excluding end. Do ensure that the start,
@@ -63,8 +73,12 @@ func (sl *Lists) Orchestrate(ctx context.Context, logPrefix string, ts uint64) e
 	go sl.produceRanges(ctx, ts, keyCh)
 
 	// Read the posting lists corresponding to keys and send to kvChan.
+	numGo := 16
+	if sl.Ordered {
+		numGo = 1
+	}
 	var wg sync.WaitGroup
-	for i := 0; i < 16; i++ {
+	for i := 0; i < numGo; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -105,7 +119,14 @@ func (sl *Lists) produceRanges(ctx context.Context, ts uint64, keyCh chan keyRan
 	}
 	splits := sl.DB.KeySplits(prefix)
 	start := prefix
+	if len(sl.Since) > 0 && bytes.Compare(sl.Since, start) >= 0 {
+		// The key right after Since.
+		start = append(y.SafeCopy(nil, sl.Since), 0)
+	}
 	for _, key := range splits {
+		if bytes.Compare([]byte(key), start) <= 0 {
+			continue
+		}
 		keyCh <- keyRange{start: start, end: y.SafeCopy(nil, []byte(key))}
 		start = y.SafeCopy(nil, []byte(key))
 	}
@@ -170,6 +191,7 @@ func (sl *Lists) produceKVs(ctx context.Context, ts uint64,
 		}
 		if len(kvs.Kv) > 0 {
 			kvChan <- kvs
+			size = 0
 		}
 		return nil
 	}
@@ -198,8 +220,10 @@ func (sl *Lists) streamKVs(ctx context.Context, logPrefix string, kvChan chan *p
 	now := time.Now()
 
 	slurp := func(batch *pb.KVS) error {
+		// The batches of an ordered stream are sent as they are, for the receiver to keep track
+		// of them.
 	loop:
-		for {
+		for !sl.Ordered {
 			select {
 			case kvs, ok := <-kvChan:
 				if !ok {
@@ -214,6 +238,9 @@ func (sl *Lists) streamKVs(ctx context.Context, logPrefix string, kvChan chan *p
 		sz := uint64(batch.Size())
 		bytesSent += sz
 		count += len(batch.Kv)
+		if err := sl.Limiter.Wait(ctx, int(sz)); err != nil {
+			return err
+		}
 		t := time.Now()
 		if err := sl.Stream.Send(batch); err != nil {
 			return err
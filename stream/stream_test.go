@@ -17,12 +17,14 @@
 package stream
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
 	"math"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger"
 	"github.com/dgraph-io/dgraph/protos/pb"
@@ -158,3 +160,65 @@ func TestOrchestrate(t *testing.T) {
 		require.Equal(t, 50, count, "Count mismatch for pred: %s", pred)
 	}
 }
+
+func TestOrchestrateOrdered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := openManaged(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, pred := range []string{"p0", "p1", "p2"} {
+		txn := db.NewTransactionAt(math.MaxUint64, true)
+		for i := 1; i <= 100; i++ {
+			require.NoError(t, txn.Set(x.DataKey(pred, uint64(i)), value(i)))
+		}
+		require.NoError(t, txn.CommitAt(5, nil))
+	}
+
+	c := &collector{}
+	sl := Lists{Stream: c, DB: db, Ordered: true}
+	sl.ItemToKVFunc = func(key []byte, itr *badger.Iterator) (*pb.KV, error) {
+		val, err := itr.Item().ValueCopy(nil)
+		require.NoError(t, err)
+		return &pb.KV{Key: key, Val: val, Version: itr.Item().Version()}, nil
+	}
+	require.NoError(t, sl.Orchestrate(context.Background(), "Testing", math.MaxUint64))
+	require.Equal(t, 300, len(c.kv))
+	for i := 1; i < len(c.kv); i++ {
+		require.True(t, bytes.Compare(c.kv[i-1].Key, c.kv[i].Key) < 0)
+	}
+
+	// Resume after the 150th key.
+	all := c.kv
+	c.kv = nil
+	sl.Since = all[149].Key
+	require.NoError(t, sl.Orchestrate(context.Background(), "Testing", math.MaxUint64))
+	require.Equal(t, all[150:], c.kv)
+
+	// Only the keys of the predicate after Since are sent.
+	c.kv = nil
+	sl.Predicate = "p1"
+	sl.Since = x.DataKey("p1", 90)
+	require.NoError(t, sl.Orchestrate(context.Background(), "Testing", math.MaxUint64))
+	require.Equal(t, 10, len(c.kv))
+	require.Equal(t, x.DataKey("p1", 91), c.kv[0].Key)
+}
+
+func TestLimiter(t *testing.T) {
+	require.Nil(t, NewLimiter(0))
+	require.NoError(t, NewLimiter(0).Wait(context.Background(), 1<<30))
+
+	// 100 KB at 0.5 MB/s take 0.2s.
+	l := NewLimiter(0.5)
+	start := time.Now()
+	require.NoError(t, l.Wait(context.Background(), 50000))
+	require.NoError(t, l.Wait(context.Background(), 50000))
+	require.True(t, time.Since(start) >= 190*time.Millisecond, "took %s", time.Since(start))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, context.Canceled, l.Wait(ctx, 1<<20))
+}
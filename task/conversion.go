@@ -18,6 +18,7 @@ package task
 
 import (
 	"encoding/binary"
+	"math"
 
 	"github.com/dgraph-io/dgraph/protos/pb"
 )
@@ -38,6 +39,12 @@ func ToInt(val *pb.TaskValue) int64 {
 	return int64(result)
 }
 
+func FromFloat(val float64) *pb.TaskValue {
+	bs := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bs, math.Float64bits(val))
+	return &pb.TaskValue{Val: []byte(bs), ValType: pb.Posting_FLOAT}
+}
+
 func FromBool(val bool) *pb.TaskValue {
 	if val == true {
 		return FromInt(1)
@@ -19,6 +19,7 @@ package tok
 import (
 	"encoding/binary"
 	"plugin"
+	"strings"
 	"time"
 
 	farm "github.com/dgryski/go-farm"
@@ -69,6 +70,7 @@ func init() {
 	registerTokenizer(ExactTokenizer{})
 	registerTokenizer(BoolTokenizer{})
 	registerTokenizer(TrigramTokenizer{})
+	registerTokenizer(TrigramFoldTokenizer{})
 	registerTokenizer(HashTokenizer{})
 	registerTokenizer(TermTokenizer{})
 	registerTokenizer(FullTextTokenizer{})
@@ -246,7 +248,13 @@ func (t ExactTokenizer) Identifier() byte { return 0x2 }
 func (t ExactTokenizer) IsSortable() bool { return true }
 func (t ExactTokenizer) IsLossy() bool    { return false }
 
-type FullTextTokenizer struct{ lang string }
+// FullTextTokenizer analyzes values in lang, or English if empty. Stop words are removed and
+// terms are stemmed, unless keepStopwords or noStem are set by the schema of the predicate.
+type FullTextTokenizer struct {
+	lang          string
+	keepStopwords bool
+	noStem        bool
+}
 
 func (t FullTextTokenizer) Name() string { return "fulltext" }
 func (t FullTextTokenizer) Type() string { return "string" }
@@ -259,9 +267,13 @@ func (t FullTextTokenizer) Tokens(v interface{}) ([]string, error) {
 	// pass 1 - lowercase and normalize input
 	tokens := fulltextAnalyzer.Analyze([]byte(str))
 	// pass 2 - filter stop words
-	tokens = filterStopwords(lang, tokens)
+	if !t.keepStopwords {
+		tokens = filterStopwords(lang, tokens)
+	}
 	// pass 3 - filter stems
-	tokens = filterStemmers(lang, tokens)
+	if !t.noStem {
+		tokens = filterStemmers(lang, tokens)
+	}
 	// finally, return the terms.
 	return uniqueTerms(tokens), nil
 }
@@ -296,6 +308,12 @@ func EncodeRegexTokens(tokens []string) {
 	}
 }
 
+func EncodeFoldedRegexTokens(tokens []string) {
+	for i := 0; i < len(tokens); i++ {
+		tokens[i] = encodeToken(tokens[i], TrigramFoldTokenizer{}.Identifier())
+	}
+}
+
 type BoolTokenizer struct{}
 
 func (t BoolTokenizer) Name() string { return "bool" }
@@ -335,6 +353,23 @@ func (t TrigramTokenizer) Identifier() byte { return 0xA }
 func (t TrigramTokenizer) IsSortable() bool { return false }
 func (t TrigramTokenizer) IsLossy() bool    { return true }
 
+// TrigramFoldTokenizer generates the trigrams of the value folded to lower case, so that
+// case-insensitive regular expressions can use the index.
+type TrigramFoldTokenizer struct{}
+
+func (t TrigramFoldTokenizer) Name() string { return "trigram_ci" }
+func (t TrigramFoldTokenizer) Type() string { return "string" }
+func (t TrigramFoldTokenizer) Tokens(v interface{}) ([]string, error) {
+	value, ok := v.(string)
+	if !ok {
+		return nil, x.Errorf("Trigram indices only supported for string types")
+	}
+	return TrigramTokenizer{}.Tokens(strings.ToLower(value))
+}
+func (t TrigramFoldTokenizer) Identifier() byte { return 0xC }
+func (t TrigramFoldTokenizer) IsSortable() bool { return false }
+func (t TrigramFoldTokenizer) IsLossy() bool    { return true }
+
 type HashTokenizer struct{}
 
 func (t HashTokenizer) Name() string { return "hash" }
@@ -179,6 +179,26 @@ func TestTrigramTokenizer(t *testing.T) {
 	require.Equal(t, expected, tokens)
 }
 
+func TestTrigramFoldTokenizer(t *testing.T) {
+	tokenizer, has := GetTokenizer("trigram_ci")
+	require.True(t, has)
+	require.NotNil(t, tokenizer)
+	tokens, err := BuildTokens("DGraph dgraph", tokenizer)
+	require.NoError(t, err)
+	id := tokenizer.Identifier()
+	expected := []string{
+		encodeToken("dgr", id),
+		encodeToken("gra", id),
+		encodeToken("rap", id),
+		encodeToken("aph", id),
+		encodeToken("ph ", id),
+		encodeToken("h d", id),
+		encodeToken(" dg", id),
+	}
+	sort.Strings(expected)
+	require.Equal(t, expected, tokens)
+}
+
 func TestGetFullTextTokens(t *testing.T) {
 	val := "Our chief weapon is surprise...surprise and fear...fear and surprise...." +
 		"Our two weapons are fear and surprise...and ruthless efficiency.... " +
@@ -200,6 +220,22 @@ func TestGetFullTextTokens1(t *testing.T) {
 	require.Equal(t, 3, len(tokens))
 }
 
+func TestGetFullTextTokensOptions(t *testing.T) {
+	val := "The weapons of the chief"
+	tokens, err := GetFullTextTokenizer("", true, true).Tokens(val)
+	require.NoError(t, err)
+	require.Equal(t, []string{"chief", "of", "the", "weapons"}, tokens)
+
+	tokens, err = GetFullTextTokenizer("", false, true).Tokens(val)
+	require.NoError(t, err)
+	require.Equal(t, []string{"chief", "weapons"}, tokens)
+
+	// The language of the value overrides the one of the predicate, keeping the options.
+	tokens, err = GetLangTokenizer(GetFullTextTokenizer("de", true, false), "en").Tokens(val)
+	require.NoError(t, err)
+	require.Equal(t, []string{"chief", "of", "the", "weapon"}, tokens)
+}
+
 func TestGetFullTextTokensInvalidLang(t *testing.T) {
 	tokens, err := GetFullTextTokens([]string{"Quick brown fox"}, "xxx_such_language")
 	require.NoError(t, err)
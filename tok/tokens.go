@@ -24,15 +24,22 @@ func GetLangTokenizer(t Tokenizer, lang string) Tokenizer {
 	if lang == "" {
 		return t
 	}
-	switch t.(type) {
+	switch ft := t.(type) {
 	case FullTextTokenizer:
 		// we must return a new instance because another goroutine might be calling this
-		// with a different lang.
-		return FullTextTokenizer{lang: lang}
+		// with a different lang. The copy keeps the stop word and stemming options.
+		ft.lang = lang
+		return ft
 	}
 	return t
 }
 
+// GetFullTextTokenizer returns the fulltext tokenizer for a predicate, analyzing values
+// without a language tag in lang, and keeping stop words or skipping stemming if asked.
+func GetFullTextTokenizer(lang string, keepStopwords, noStem bool) Tokenizer {
+	return FullTextTokenizer{lang: lang, keepStopwords: keepStopwords, noStem: noStem}
+}
+
 func GetTermTokens(funcArgs []string) ([]string, error) {
 	if l := len(funcArgs); l != 1 {
 		return nil, x.Errorf("Function requires 1 arguments, but got %d", l)
@@ -41,8 +48,14 @@ func GetTermTokens(funcArgs []string) ([]string, error) {
 }
 
 func GetFullTextTokens(funcArgs []string, lang string) ([]string, error) {
+	return GetFullTextTokensWith(funcArgs, FullTextTokenizer{}, lang)
+}
+
+// GetFullTextTokensWith tokenizes the argument of a fulltext function with the fulltext
+// tokenizer t of the predicate, in lang if not empty.
+func GetFullTextTokensWith(funcArgs []string, t Tokenizer, lang string) ([]string, error) {
 	if l := len(funcArgs); l != 1 {
 		return nil, x.Errorf("Function requires 1 arguments, but got %d", l)
 	}
-	return BuildTokens(funcArgs[0], FullTextTokenizer{lang: lang})
+	return BuildTokens(funcArgs[0], GetLangTokenizer(t, lang))
 }
@@ -21,6 +21,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/golang/geo/s1"
 	"github.com/golang/geo/s2"
 	"github.com/twpayne/go-geom"
 
@@ -44,8 +45,9 @@ const (
 
 // GeoQueryData is pb.data used by the geo query filter to additionally filter the geometries.
 type GeoQueryData struct {
-	pt    *s2.Point  // If not nil, the input data was a point
-	loops []*s2.Loop // If not empty, the input data was a polygon/multipolygon or it was a near query.
+	pt    *s2.Point    // If not nil, the input data was a point
+	loops []*s2.Loop   // If not empty, the input data was a polygon/multipolygon or it was a near query.
+	holes [][]*s2.Loop // Holes of each loop, for an intersects query.
 	qtype QueryType
 }
 
@@ -117,12 +119,33 @@ func GetGeoTokens(srcFunc *pb.SrcFunction) ([]string, *GeoQueryData, error) {
 	}
 }
 
+// GetGeoDistanceQuery returns the GeoQueryData of the distance function, with the point to
+// measure the distance from.
+func GetGeoDistanceQuery(srcFunc *pb.SrcFunction) (*GeoQueryData, error) {
+	// The last argument is the attribute, added by the parser.
+	if len(srcFunc.Args) != 2 {
+		return nil, x.Errorf("distance function requires 2 arguments, but got %d",
+			len(srcFunc.Args)-1)
+	}
+	g, err := convertToGeom(srcFunc.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	p, ok := g.(*geom.Point)
+	if !ok {
+		return nil, x.Errorf("distance function requires a point, but got %T", g)
+	}
+	pt := pointFromPoint(p)
+	return &GeoQueryData{pt: &pt}, nil
+}
+
 // queryTokensGeo returns the tokens to be used to look up the geo index for a given filter.
 // qt is the type of Geo query - near/intersects/contains/within
 // g is the geom.T representation of the input. It could be a point/polygon/multipolygon.
 // maxDistance is distance in metres, only used for near query.
 func queryTokensGeo(qt QueryType, g geom.T, maxDistance float64) ([]string, *GeoQueryData, error) {
 	var loops []*s2.Loop
+	var holes [][]*s2.Loop
 	var pt *s2.Point
 	var err error
 	switch v := g.(type) {
@@ -146,7 +169,12 @@ func queryTokensGeo(qt QueryType, g geom.T, maxDistance float64) ([]string, *Geo
 		if err != nil {
 			return nil, nil, err
 		}
+		h, err := holesFromPolygon(v)
+		if err != nil {
+			return nil, nil, err
+		}
 		loops = append(loops, l)
+		holes = append(holes, h)
 
 	case *geom.MultiPolygon:
 		// We get a loop for each polygon.
@@ -155,7 +183,12 @@ func queryTokensGeo(qt QueryType, g geom.T, maxDistance float64) ([]string, *Geo
 			if err != nil {
 				return nil, nil, err
 			}
+			h, err := holesFromPolygon(v.Polygon(i))
+			if err != nil {
+				return nil, nil, err
+			}
 			loops = append(loops, l)
+			holes = append(holes, h)
 		}
 
 	default:
@@ -205,12 +238,13 @@ func queryTokensGeo(qt QueryType, g geom.T, maxDistance float64) ([]string, *Geo
 	case QueryTypeIntersects:
 		// An intersects query is as the name suggests all the entities which intersect with the
 		// given region. So we look at all the objects whose parents match our cover as well as
-		// all the objects whose cover matches our parents.
+		// all the objects whose cover matches our parents. The cover ignores the holes of the
+		// region, the entities lying within one are filtered out later.
 		if len(loops) == 0 {
 			return nil, nil, x.Errorf("Require a polygon for intersects query")
 		}
 		toks := parentCoverTokens(parents, cover)
-		return toks, &GeoQueryData{loops: loops, qtype: qt}, nil
+		return toks, &GeoQueryData{loops: loops, holes: holes, qtype: qt}, nil
 
 	default:
 		return nil, nil, x.Errorf("Unknown query type")
@@ -357,6 +391,28 @@ func (q GeoQueryData) contains(g geom.T) bool {
 	}
 }
 
+// holesOf returns the holes of the ith loop of the query.
+func (q GeoQueryData) holesOf(i int) []*s2.Loop {
+	if i < len(q.holes) {
+		return q.holes[i]
+	}
+	return nil
+}
+
+// intersectsLoop returns true if the loop l intersects the ith loop of the query, without lying
+// within one of its holes.
+func (q GeoQueryData) intersectsLoop(l *s2.Loop, i int) bool {
+	if !Intersects(l, q.loops[i]) {
+		return false
+	}
+	for _, hole := range q.holesOf(i) {
+		if Contains(hole, l) {
+			return false
+		}
+	}
+	return true
+}
+
 // returns true if the geometry represented by uid/attr intersects the given loop or point
 func (q GeoQueryData) intersects(g geom.T) bool {
 	x.AssertTruef(len(q.loops) > 0, "Loop should be defined for intersects.")
@@ -364,10 +420,17 @@ func (q GeoQueryData) intersects(g geom.T) bool {
 	case *geom.Point:
 		p := pointFromPoint(v)
 		// else loop is not nil
-		for _, l := range q.loops {
-			if l.ContainsPoint(p) {
-				return true
+	Loops:
+		for i, l := range q.loops {
+			if !l.ContainsPoint(p) {
+				continue
 			}
+			for _, hole := range q.holesOf(i) {
+				if hole.ContainsPoint(p) {
+					continue Loops
+				}
+			}
+			return true
 		}
 		return false
 
@@ -376,8 +439,8 @@ func (q GeoQueryData) intersects(g geom.T) bool {
 		if err != nil {
 			return false
 		}
-		for _, loop := range q.loops {
-			if Intersects(l, loop) {
+		for i := range q.loops {
+			if q.intersectsLoop(l, i) {
 				return true
 			}
 		}
@@ -389,8 +452,8 @@ func (q GeoQueryData) intersects(g geom.T) bool {
 			if err != nil {
 				return false
 			}
-			for _, loop := range q.loops {
-				if Intersects(l, loop) {
+			for j := range q.loops {
+				if q.intersectsLoop(l, j) {
 					return true
 				}
 			}
@@ -422,3 +485,50 @@ func MatchGeo(value *pb.TaskValue, q *GeoQueryData) bool {
 	g := gc.Value.(geom.T)
 	return q.MatchesFilter(g)
 }
+
+// GeoDistance returns the distance in meters from the point of the distance query to the
+// geometry of the value, or to the closest edge of a polygon not containing the point. It
+// returns false if the value isn't a geometry.
+func GeoDistance(value *pb.TaskValue, q *GeoQueryData) (float64, bool) {
+	x.AssertTruef(q.pt != nil, "Point should be defined for distance.")
+	if len(value.Val) == 0 || TypeID(value.ValType) != GeoID {
+		return 0, false
+	}
+	src := ValueForType(BinaryID)
+	src.Value = value.Val
+	gc, err := Convert(src, GeoID)
+	if err != nil {
+		return 0, false
+	}
+
+	var polygons []*geom.Polygon
+	switch v := gc.Value.(geom.T).(type) {
+	case *geom.Point:
+		return float64(EarthDistance(q.pt.Distance(pointFromPoint(v)))), true
+	case *geom.Polygon:
+		polygons = append(polygons, v)
+	case *geom.MultiPolygon:
+		for i := 0; i < v.NumPolygons(); i++ {
+			polygons = append(polygons, v.Polygon(i))
+		}
+	default:
+		return 0, false
+	}
+
+	closest := s1.InfAngle()
+	for _, p := range polygons {
+		l, err := loopFromPolygon(p)
+		if err != nil {
+			return 0, false
+		}
+		if l.ContainsPoint(*q.pt) {
+			return 0, true
+		}
+		for i := 0; i < l.NumEdges(); i++ {
+			if d := s2.DistanceFromSegment(*q.pt, l.Vertex(i), l.Vertex(i+1)); d < closest {
+				closest = d
+			}
+		}
+	}
+	return float64(EarthDistance(closest)), true
+}
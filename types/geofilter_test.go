@@ -21,6 +21,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/stretchr/testify/require"
 	"github.com/twpayne/go-geom"
@@ -396,3 +397,75 @@ func TestMatchesFilterNearPoint(t *testing.T) {
 	})
 	require.True(t, qd.MatchesFilter(poly))
 }
+
+func TestMatchesFilterIntersectsPolygonWithHole(t *testing.T) {
+	p := geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{
+		{{-122, 37}, {-123, 37}, {-123, 38}, {-122, 38}, {-122, 37}},
+		{{-122.2, 37.2}, {-122.2, 37.8}, {-122.8, 37.8}, {-122.8, 37.2}, {-122.2, 37.2}},
+	})
+	data := formDataPolygon(t, p)
+	_, qd, err := queryTokens(QueryTypeIntersects, data, 0.0)
+	require.NoError(t, err)
+
+	// Point in the ring around the hole
+	p2 := geom.NewPoint(geom.XY).MustSetCoords(geom.Coord{-122.1, 37.5})
+	require.True(t, qd.MatchesFilter(p2))
+
+	// Point in the hole
+	p3 := geom.NewPoint(geom.XY).MustSetCoords(geom.Coord{-122.5, 37.5})
+	require.False(t, qd.MatchesFilter(p3))
+
+	// Poly in the hole
+	poly := geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{
+		{{-122.4, 37.4}, {-122.6, 37.4}, {-122.6, 37.6}, {-122.4, 37.6}, {-122.4, 37.4}},
+	})
+	require.False(t, qd.MatchesFilter(poly))
+
+	// Poly crossing the edge of the hole
+	poly = geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{
+		{{-122.1, 37.4}, {-122.6, 37.4}, {-122.6, 37.6}, {-122.1, 37.6}, {-122.1, 37.4}},
+	})
+	require.True(t, qd.MatchesFilter(poly))
+}
+
+func geoTaskValue(t *testing.T, g geom.T) *pb.TaskValue {
+	d, err := wkb.Marshal(g, binary.LittleEndian)
+	require.NoError(t, err)
+	return &pb.TaskValue{Val: d, ValType: pb.Posting_GEO}
+}
+
+func TestGeoDistance(t *testing.T) {
+	qd, err := GetGeoDistanceQuery(&pb.SrcFunction{
+		Name: "distance",
+		Args: []string{"[-122, 37.5]", "loc"},
+	})
+	require.NoError(t, err)
+
+	// One degree of latitude away
+	p := geom.NewPoint(geom.XY).MustSetCoords(geom.Coord{-122, 38.5})
+	d, ok := GeoDistance(geoTaskValue(t, p), qd)
+	require.True(t, ok)
+	require.InDelta(t, 111194.9, d, 1)
+
+	// Poly containing the point
+	poly := geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{
+		{{-121, 37}, {-123, 37}, {-123, 38}, {-121, 38}, {-121, 37}},
+	})
+	d, ok = GeoDistance(geoTaskValue(t, poly), qd)
+	require.True(t, ok)
+	require.Equal(t, 0.0, d)
+
+	// Poly with its closest edge one degree of longitude away
+	poly = geom.NewPolygon(geom.XY).MustSetCoords([][]geom.Coord{
+		{{-120, 37}, {-121, 37}, {-121, 38}, {-120, 38}, {-120, 37}},
+	})
+	d, ok = GeoDistance(geoTaskValue(t, poly), qd)
+	require.True(t, ok)
+	require.InDelta(t, 88215.2, d, 1)
+
+	_, err = GetGeoDistanceQuery(&pb.SrcFunction{
+		Name: "distance",
+		Args: []string{"[[[-120, 37], [-121, 37], [-121, 38], [-120, 37]]]", "loc"},
+	})
+	require.Error(t, err)
+}
@@ -129,13 +129,30 @@ func pointFromPoint(p *geom.Point) s2.Point {
 func loopFromPolygon(p *geom.Polygon) (*s2.Loop, error) {
 	// go implementation of s2 does not support more than one loop (and will panic if the size of
 	// the loops array > 1). So we will skip the holes in the polygon and just use the outer loop.
-	r := p.LinearRing(0)
+	return loopFromLinearRing(p.LinearRing(0))
+}
+
+// holesFromPolygon converts the holes of a geom.Polygon, its rings after the first one, to
+// s2.Loops.
+func holesFromPolygon(p *geom.Polygon) ([]*s2.Loop, error) {
+	var holes []*s2.Loop
+	for i := 1; i < p.NumLinearRings(); i++ {
+		l, err := loopFromLinearRing(p.LinearRing(i))
+		if err != nil {
+			return nil, err
+		}
+		holes = append(holes, l)
+	}
+	return holes, nil
+}
+
+func loopFromLinearRing(r *geom.LinearRing) (*s2.Loop, error) {
 	n := r.NumCoords()
 	if n < 4 {
 		return nil, x.Errorf("Can't convert ring with less than 4 pts")
 	}
 	if !r.Coord(0).Equal(geom.XY, r.Coord(n-1)) {
-		return nil, x.Errorf("Last coordinate not same as first for polygon: %+v\n", p)
+		return nil, x.Errorf("Last coordinate not same as first for polygon: %+v\n", r.Coords())
 	}
 	// S2 specifies that the orientation of the polygons should be CCW. However there is no
 	// restriction on the orientation in WKB (or geojson). To get the correct orientation we assume
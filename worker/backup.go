@@ -19,6 +19,8 @@
 package worker
 
 import (
+	"io"
+
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/golang/glog"
@@ -26,12 +28,28 @@ import (
 )
 
 // Backup implements the Worker interface.
-func (w *grpcWorker) Backup(ctx context.Context, req *pb.BackupRequest) (*pb.Status, error) {
+func (w *grpcWorker) Backup(ctx context.Context,
+	req *pb.BackupRequest) (*pb.Status, error) {
 	glog.Warningf("Backup failed: %v", x.ErrNotSupported)
 	return &pb.Status{}, x.ErrNotSupported
 }
 
 // BackupOverNetwork handles a request coming from an HTTP client.
-func BackupOverNetwork(pctx context.Context, target string) error {
+func BackupOverNetwork(pctx context.Context, target string, incremental bool,
+	compression string, prefixes []string, skipIndexes bool) error {
 	return x.ErrNotSupported
 }
+
+// createExportObject fails, exports can't be written to remote destinations without enterprise
+// features.
+func createExportObject(dest, name string) (io.WriteCloser, error) {
+	return nil, x.ErrNotSupported
+}
+
+// scheduleBackups is a no-op, the backups can't be scheduled without enterprise features.
+func (g *groupi) scheduleBackups() {
+	defer g.closer.Done() // CLOSER:1
+	if Config.BackupSchedule != "" {
+		glog.Warningf("Backup schedule ignored: %v", x.ErrNotSupported)
+	}
+}
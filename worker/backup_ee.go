@@ -13,6 +13,10 @@
 package worker
 
 import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/dgraph/ee/backup"
@@ -24,23 +28,41 @@ import (
 	"golang.org/x/net/context"
 )
 
-func backupProcess(ctx context.Context, req *pb.BackupRequest) error {
+var (
+	backupLimitOnce sync.Once
+	backupLimit     *backup.RateLimiter
+)
+
+// backupLimiter returns the limiter of the backup uploads and restore downloads of this Alpha,
+// shared by all of them.
+func backupLimiter() *backup.RateLimiter {
+	backupLimitOnce.Do(func() {
+		backupLimit = backup.NewRateLimiter(Config.BackupRateLimit)
+	})
+	return backupLimit
+}
+
+func backupProcess(ctx context.Context, req *pb.BackupRequest) (*pb.Status, error) {
 	glog.Infof("Backup request: group %d at %d", req.GroupId, req.ReadTs)
 	if err := ctx.Err(); err != nil {
 		glog.Errorf("Context error during backup: %v\n", err)
-		return err
+		return nil, err
 	}
 	// sanity, make sure this is our group.
 	if groups().groupId() != req.GroupId {
-		return x.Errorf("Backup request group mismatch. Mine: %d. Requested: %d\n",
+		return nil, x.Errorf("Backup request group mismatch. Mine: %d. Requested: %d\n",
 			groups().groupId(), req.GroupId)
 	}
+	if req.ReadTs == 0 {
+		// Only start recording the drops, see BackupOverNetwork.
+		return &pb.Status{}, groups().Node.recordDrops(ctx)
+	}
 	// wait for this node to catch-up.
 	if err := posting.Oracle().WaitForTs(ctx, req.ReadTs); err != nil {
-		return err
+		return nil, err
 	}
 	// create backup request and process it.
-	br := &backup.Request{DB: pstore, Backup: req}
+	br := &backup.Request{DB: pstore, Backup: req, Key: Config.BackupKey, Limit: backupLimiter()}
 	// calculate estimated upload size
 	for _, t := range groups().tablets {
 		if t.GroupId == req.GroupId {
@@ -50,19 +72,24 @@ func backupProcess(ctx context.Context, req *pb.BackupRequest) error {
 	return br.Process(ctx)
 }
 
+// recordDrops sets x.BackupsKey through Raft, for every Alpha of the group to record its drops
+// from then on, see recordDrop.
+func (n *node) recordDrops(ctx context.Context) error {
+	if ok, err := takesBackups(); err != nil || ok {
+		return err
+	}
+	kv := &pb.KV{Key: x.BackupsKey(), UserMeta: []byte{0}, Version: 1}
+	return n.proposeAndWait(ctx, &pb.Proposal{Kv: []*pb.KV{kv}})
+}
+
 // Backup handles a request coming from another node.
-func (w *grpcWorker) Backup(ctx context.Context, req *pb.BackupRequest) (*pb.Status, error) {
-	var resp pb.Status
+func (w *grpcWorker) Backup(ctx context.Context,
+	req *pb.BackupRequest) (*pb.Status, error) {
 	glog.V(2).Infof("Received backup request via Grpc: %+v", req)
-	if err := backupProcess(ctx, req); err != nil {
-		resp.Code = -1
-		resp.Msg = err.Error()
-		return &resp, err
-	}
-	return &resp, nil
+	return backupProcess(ctx, req)
 }
 
-func backupGroup(ctx context.Context, in pb.BackupRequest) error {
+func backupGroup(ctx context.Context, in pb.BackupRequest) (*pb.Status, error) {
 	glog.V(2).Infof("Sending backup request: %+v\n", in)
 	// this node is part of the group, process backup.
 	if groups().groupId() == in.GroupId {
@@ -72,24 +99,76 @@ func backupGroup(ctx context.Context, in pb.BackupRequest) error {
 	// send request to any node in the group.
 	pl := groups().AnyServer(in.GroupId)
 	if pl == nil {
-		return x.Errorf("Couldn't find a server in group %d", in.GroupId)
+		return nil, x.Errorf("Couldn't find a server in group %d", in.GroupId)
 	}
-	status, err := pb.NewWorkerClient(pl.Get()).Backup(ctx, &in)
+	resp, err := pb.NewWorkerClient(pl.Get()).Backup(ctx, &in)
 	if err != nil {
 		glog.Errorf("Backup error group %d: %s", in.GroupId, err)
-		return err
-	}
-	if status.Code != 0 {
-		err := x.Errorf("Backup error group %d: %s", in.GroupId, status.Msg)
-		glog.Errorln(err)
-		return err
+		return nil, err
 	}
 	glog.V(2).Infof("Backup request to gid=%d. OK\n", in.GroupId)
-	return nil
+	return resp, nil
+}
+
+// scheduleBackups takes the backups of Config.BackupSchedule to Config.BackupDestination until
+// the group is closed. Only the leader of group 1 takes them, so the cluster takes each one
+// once. A chain of Config.BackupFullEvery backups starts with a full backup, the rest are
+// incremental. The chain is read from the destination, so restarts don't reset it.
+func (g *groupi) scheduleBackups() {
+	defer g.closer.Done() // CLOSER:1
+	if Config.BackupSchedule == "" {
+		return
+	}
+	sched, err := backup.ParseSchedule(Config.BackupSchedule)
+	x.Checkf(err, "Invalid backup schedule")
+	x.Checkf(backup.CheckCompression(Config.BackupCompression), "Invalid backup compression")
+	glog.Infof("Backups scheduled to %q: %s", Config.BackupDestination, Config.BackupSchedule)
+
+	for {
+		next := sched.Next(time.Now())
+		select {
+		case <-g.closer.HasBeenClosed():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		if g.groupId() != 1 || !g.Node.AmLeader() {
+			continue
+		}
+
+		// An error means there's no chain yet, and the first backup must be a full one.
+		incremental := false
+		chain, err := backup.Chain(Config.BackupDestination, 0, &backup.Credentials{})
+		if err == nil {
+			incremental = Config.BackupFullEvery == 0 || len(chain) < Config.BackupFullEvery
+		}
+		glog.Infof("Taking scheduled backup to %q, incremental: %v",
+			Config.BackupDestination, incremental)
+		err = BackupOverNetwork(context.Background(), Config.BackupDestination, incremental,
+			Config.BackupCompression, nil, Config.BackupSkipIndexes)
+		if err != nil {
+			glog.Errorf("Scheduled backup failed: %v", err)
+		}
+	}
 }
 
 // BackupOverNetwork handles a request coming from an HTTP client.
-func BackupOverNetwork(pctx context.Context, target string) error {
+// If incremental is true, only the data committed since the latest backup at target is
+// backed up. Otherwise, or if there are no backups at target yet, a full backup is taken.
+// The backup files are compressed with the compression codec, if set. If prefixes are set,
+// only the predicates starting with any of them are backed up, e.g. the ones of a tenant.
+// If skipIndexes is true, the index, reverse and count keys aren't backed up, restore
+// rebuilds them from the data.
+func BackupOverNetwork(pctx context.Context, target string, incremental bool,
+	compression string, prefixes []string, skipIndexes bool) (err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			x.BackupFailures.Add(1)
+			return
+		}
+		x.BackupDuration.Set(int64(time.Since(start).Seconds()))
+		x.BackupLastSuccess.Set(time.Now().Unix())
+	}(time.Now())
+
 	ctx, cancel := context.WithCancel(pctx)
 	defer cancel()
 
@@ -99,6 +178,47 @@ func BackupOverNetwork(pctx context.Context, target string) error {
 		return err
 	}
 
+	if err := backup.CheckCompression(compression); err != nil {
+		return err
+	}
+	if compression == "none" {
+		compression = ""
+	}
+	// The prefixes are compared with the ones of the latest backup.
+	prefixes = append([]string(nil), prefixes...)
+	sort.Strings(prefixes)
+
+	var since uint64
+	if incremental {
+		m, err := backup.LatestManifest(target)
+		if err != nil {
+			glog.Errorf("Unable to read the latest backup manifest: %s", err)
+			return err
+		}
+		switch {
+		case m == nil:
+			glog.Infof("No previous backups found at %q, taking a full backup.", target)
+		case strings.Join(m.PredicatePrefixes, ",") != strings.Join(prefixes, ","):
+			// The backups of different predicates can't be chained.
+			return x.Errorf("The latest backup at %q has predicate prefixes %v, an "+
+				"incremental backup with %v can't be taken since it", target,
+				m.PredicatePrefixes, prefixes)
+		default:
+			since = m.ReadTs
+		}
+	}
+
+	// The groups only record their drops once they take backups. The incremental backups taken
+	// since this one need the drops after its read ts, so the groups record them before it's
+	// taken.
+	gids := groups().KnownGroups()
+	for _, gid := range gids {
+		if _, err := backupGroup(ctx, pb.BackupRequest{GroupId: gid}); err != nil {
+			glog.Errorf("Unable to record the drops of group %d: %s", gid, err)
+			return err
+		}
+	}
+
 	// Get ReadTs from zero and wait for stream to catch up.
 	ts, err := Timestamps(ctx, &pb.Num{ReadOnly: true})
 	if err != nil {
@@ -106,32 +226,81 @@ func BackupOverNetwork(pctx context.Context, target string) error {
 		return err
 	}
 
-	gids := groups().KnownGroups()
 	req := pb.BackupRequest{
-		ReadTs: ts.ReadOnly,
-		Target: target,
-		UnixTs: time.Now().UTC().Format("20060102.1504"),
+		ReadTs:            ts.ReadOnly,
+		SinceTs:           since,
+		Target:            target,
+		UnixTs:            time.Now().UTC().Format("20060102.150405"),
+		Compression:       compression,
+		PredicatePrefixes: prefixes,
+		SkipIndexes:       skipIndexes,
 	}
 	glog.Infof("Created backup request: %+v. Groups=%v\n", req, gids)
 
 	// This will dispatch the request to all groups and wait for their response.
 	// If we receive any failures, we cancel the process.
-	errCh := make(chan error, 1)
+	type result struct {
+		gid  uint32
+		resp *pb.Status
+		err  error
+	}
+	resCh := make(chan result, len(gids))
 	for _, gid := range gids {
 		req.GroupId = gid
-		go func() {
-			errCh <- backupGroup(ctx, req)
-		}()
+		go func(req pb.BackupRequest) {
+			resp, err := backupGroup(ctx, req)
+			resCh <- result{gid: req.GroupId, resp: resp, err: err}
+		}(req)
 	}
 
+	checksums := make(map[uint32]string)
+	preds := make(map[uint32][]string)
+	encryption := make(map[string]bool)
 	for i := 0; i < len(gids); i++ {
-		err := <-errCh
-		if err != nil {
-			glog.Errorf("Error received during backup: %v", err)
-			return err
+		res := <-resCh
+		if res.err != nil {
+			glog.Errorf("Error received during backup: %v", res.err)
+			return res.err
 		}
+		checksums[res.gid] = res.resp.Checksum
+		preds[res.gid] = res.resp.Predicates
+		encryption[res.resp.Encryption] = true
+	}
+	// Either all the groups are encrypted or none are, otherwise the Alphas are misconfigured.
+	if len(encryption) > 1 {
+		err := x.Errorf("Backup groups are not all encrypted the same way, " +
+			"check the --encryption_key_file of every Alpha")
+		glog.Errorf("Unable to complete backup: %s", err)
+		return err
 	}
 	req.GroupId = 0
+
+	// The manifest marks the backup as complete and chains it to the previous one.
+	m := &backup.Manifest{
+		Version:           x.Version(),
+		Since:             req.SinceTs,
+		ReadTs:            req.ReadTs,
+		Groups:            gids,
+		Checksums:         checksums,
+		Predicates:        preds,
+		Compression:       req.Compression,
+		PredicatePrefixes: req.PredicatePrefixes,
+		SkipIndexes:       req.SkipIndexes,
+		Ordered:           req.SinceTs == 0,
+	}
+	for enc := range encryption {
+		m.Encryption = enc
+	}
+	if err := backup.WriteManifest(target, req.UnixTs, m); err != nil {
+		glog.Errorf("Unable to write the backup manifest: %s", err)
+		return err
+	}
 	glog.Infof("Backup for req: %+v. OK.\n", req)
 	return nil
 }
+
+// createExportObject creates the object name of an export at the destination URI dest, with
+// the backup handlers.
+func createExportObject(dest, name string) (io.WriteCloser, error) {
+	return backup.Create(dest, name)
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+)
+
+// Number of committed mutations buffered for a subscriber. A subscriber that falls further
+// behind is dropped, so it can't hold back the commits.
+const subscriberBuffer = 1000
+
+// How long the edges of a pending transaction are kept after its last mutation. It's longer
+// than the time after which the leader aborts a pending transaction, see abortOldTransactions,
+// so only the transactions whose abort this Alpha missed are expired.
+const pendingChangesTTL = 10 * time.Minute
+
+var errSlowSubscriber = x.Errorf("Subscriber dropped for not keeping up with the changes")
+
+// AuthorizeSubscription, if set, checks that the caller of Subscribe can read the changes it
+// asks for. It's set by the Alpha to enforce the ACLs, which the worker doesn't know about.
+var AuthorizeSubscription func(ctx context.Context, req *pb.SubscribeRequest) error
+
+// changeFeed keeps the edges of the mutations applied by the pending transactions of this
+// group, and sends them to the subscribers once the transactions are committed. The drops of
+// predicates and of all the data are sent as they are applied. Every Alpha of the group sends
+// its subscribers all the changes of the group.
+type changeFeed struct {
+	sync.Mutex
+	pending    map[uint64]*pendingChanges // by start ts
+	subs       map[*subscriber]struct{}
+	lastExpire time.Time
+}
+
+// pendingChanges are the edges of a pending transaction, with the time of its last mutation.
+type pendingChanges struct {
+	edges      []*pb.DirectedEdge
+	lastUpdate time.Time
+}
+
+type subscriber struct {
+	req *pb.SubscribeRequest
+	ch  chan *pb.Changes // closed if the subscriber is dropped
+}
+
+var changes = newChangeFeed()
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		pending: make(map[uint64]*pendingChanges),
+		subs:    make(map[*subscriber]struct{}),
+	}
+}
+
+// add records the edges of a mutation of the transaction started at startTs. The edges of
+// the transactions without a mutation for pendingChangesTTL are dropped now and then.
+func (cf *changeFeed) add(startTs uint64, edges []*pb.DirectedEdge) {
+	cf.Lock()
+	defer cf.Unlock()
+	now := time.Now()
+	p, ok := cf.pending[startTs]
+	if !ok {
+		p = &pendingChanges{}
+		cf.pending[startTs] = p
+	}
+	p.edges = append(p.edges, edges...)
+	p.lastUpdate = now
+	if now.Sub(cf.lastExpire) >= time.Minute {
+		cf.expire(now.Add(-pendingChangesTTL))
+		cf.lastExpire = now
+	}
+}
+
+// expire drops the edges of the transactions without a mutation since cutoff. The mutex must
+// be held.
+func (cf *changeFeed) expire(cutoff time.Time) {
+	var expired []uint64
+	for startTs, p := range cf.pending {
+		if p.lastUpdate.Before(cutoff) {
+			expired = append(expired, startTs)
+		}
+	}
+	for _, startTs := range expired {
+		delete(cf.pending, startTs)
+	}
+	if len(expired) > 0 {
+		glog.Warningf("Dropped the changes of %d transactions pending for more than %s.",
+			len(expired), pendingChangesTTL)
+	}
+}
+
+// done sends the edges of the transaction started at startTs to the subscribers if it was
+// committed at commitTs, or drops them if it was aborted, with a zero commitTs.
+func (cf *changeFeed) done(startTs, commitTs uint64) {
+	cf.Lock()
+	defer cf.Unlock()
+	p, ok := cf.pending[startTs]
+	if !ok {
+		return
+	}
+	delete(cf.pending, startTs)
+	if commitTs == 0 {
+		return
+	}
+
+	for sub := range cf.subs {
+		var subEdges []*pb.DirectedEdge
+		for _, edge := range p.edges {
+			if sub.wants(edge.Attr) {
+				subEdges = append(subEdges, edge)
+			}
+		}
+		if len(subEdges) > 0 {
+			cf.send(sub, &pb.Changes{CommitTs: commitTs, Edges: subEdges})
+		}
+	}
+}
+
+// dropped sends the drop at ts of attr, or of all the data if attr is empty, to the
+// subscribers.
+func (cf *changeFeed) dropped(attr string, ts uint64) {
+	cf.Lock()
+	defer cf.Unlock()
+	for sub := range cf.subs {
+		switch {
+		case attr == "":
+			cf.send(sub, &pb.Changes{CommitTs: ts, DropAll: true})
+		case sub.wants(attr):
+			cf.send(sub, &pb.Changes{CommitTs: ts, DropAttr: attr})
+		}
+	}
+}
+
+// send sends c to sub, or drops sub if it's too far behind. The mutex must be held.
+func (cf *changeFeed) send(sub *subscriber, c *pb.Changes) {
+	select {
+	case sub.ch <- c:
+	default:
+		glog.Warningf("Dropping a subscriber of the changes, %d commits behind.",
+			subscriberBuffer)
+		delete(cf.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// reset drops the edges of all the pending transactions.
+func (cf *changeFeed) reset() {
+	cf.Lock()
+	defer cf.Unlock()
+	cf.pending = make(map[uint64]*pendingChanges)
+}
+
+func (cf *changeFeed) subscribe(req *pb.SubscribeRequest) *subscriber {
+	sub := &subscriber{req: req, ch: make(chan *pb.Changes, subscriberBuffer)}
+	cf.Lock()
+	defer cf.Unlock()
+	cf.subs[sub] = struct{}{}
+	return sub
+}
+
+func (cf *changeFeed) unsubscribe(sub *subscriber) {
+	cf.Lock()
+	defer cf.Unlock()
+	if _, ok := cf.subs[sub]; ok {
+		delete(cf.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// wants returns whether the subscriber gets the changes of attr.
+func (sub *subscriber) wants(attr string) bool {
+	if len(sub.req.Predicates) == 0 && len(sub.req.PredicatePrefixes) == 0 {
+		return true
+	}
+	for _, pred := range sub.req.Predicates {
+		if attr == pred {
+			return true
+		}
+	}
+	for _, prefix := range sub.req.PredicatePrefixes {
+		if strings.HasPrefix(attr, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe streams the mutations committed and the drops made in the group of this Alpha
+// from now on, as they are applied. Config.ChangeDataCapture must be set, and the caller must
+// pass AuthorizeSubscription.
+func (w *grpcWorker) Subscribe(req *pb.SubscribeRequest, stream pb.Worker_SubscribeServer) error {
+	if !Config.ChangeDataCapture {
+		return x.Errorf("Change data capture isn't enabled on this Alpha, see --cdc")
+	}
+	ctx := stream.Context()
+	if AuthorizeSubscription != nil {
+		if err := AuthorizeSubscription(ctx, req); err != nil {
+			return err
+		}
+	}
+	sub := changes.subscribe(req)
+	defer changes.unsubscribe(sub)
+
+	for {
+		select {
+		case c, ok := <-sub.ch:
+			if !ok {
+				return errSlowSubscriber
+			}
+			if err := stream.Send(c); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeed(t *testing.T) {
+	cf := newChangeFeed()
+	all := cf.subscribe(&pb.SubscribeRequest{})
+	names := cf.subscribe(&pb.SubscribeRequest{PredicatePrefixes: []string{"na"}})
+
+	name := &pb.DirectedEdge{Entity: 1, Attr: "name", Value: []byte("alice")}
+	friend := &pb.DirectedEdge{Entity: 1, Attr: "friend", ValueId: 2}
+	cf.add(10, []*pb.DirectedEdge{name})
+	cf.add(10, []*pb.DirectedEdge{friend})
+	cf.add(11, []*pb.DirectedEdge{{Entity: 3, Attr: "name"}})
+
+	// The aborted transaction is dropped, the committed one is sent.
+	cf.done(11, 0)
+	cf.done(10, 12)
+	require.Len(t, cf.pending, 0)
+	require.Equal(t, &pb.Changes{CommitTs: 12, Edges: []*pb.DirectedEdge{name, friend}},
+		<-all.ch)
+	require.Equal(t, &pb.Changes{CommitTs: 12, Edges: []*pb.DirectedEdge{name}}, <-names.ch)
+
+	// Only the changes the subscribers want are sent.
+	cf.add(13, []*pb.DirectedEdge{friend})
+	cf.done(13, 14)
+	require.Len(t, all.ch, 1)
+	require.Len(t, names.ch, 0)
+
+	// A subscriber that doesn't keep up is dropped.
+	for i := 0; i < subscriberBuffer; i++ {
+		cf.add(20, []*pb.DirectedEdge{friend})
+		cf.done(20, 21)
+	}
+	for range all.ch {
+	}
+	require.Len(t, cf.subs, 1)
+	cf.unsubscribe(all)
+	cf.unsubscribe(names)
+	require.Len(t, cf.subs, 0)
+	_, ok := <-names.ch
+	require.False(t, ok)
+}
+
+func TestChangeFeedDrops(t *testing.T) {
+	cf := newChangeFeed()
+	all := cf.subscribe(&pb.SubscribeRequest{})
+	names := cf.subscribe(&pb.SubscribeRequest{Predicates: []string{"name"}})
+
+	cf.dropped("friend", 10)
+	cf.dropped("name", 11)
+	cf.dropped("", 12)
+	require.Equal(t, &pb.Changes{CommitTs: 10, DropAttr: "friend"}, <-all.ch)
+	require.Equal(t, &pb.Changes{CommitTs: 11, DropAttr: "name"}, <-all.ch)
+	require.Equal(t, &pb.Changes{CommitTs: 12, DropAll: true}, <-all.ch)
+	require.Equal(t, &pb.Changes{CommitTs: 11, DropAttr: "name"}, <-names.ch)
+	require.Equal(t, &pb.Changes{CommitTs: 12, DropAll: true}, <-names.ch)
+}
+
+func TestChangeFeedExpire(t *testing.T) {
+	cf := newChangeFeed()
+	friend := &pb.DirectedEdge{Entity: 1, Attr: "friend", ValueId: 2}
+	cf.add(10, []*pb.DirectedEdge{friend})
+	cf.add(11, []*pb.DirectedEdge{friend})
+	cf.pending[10].lastUpdate = time.Now().Add(-2 * pendingChangesTTL)
+
+	// The expired transactions are dropped on the next mutation.
+	cf.lastExpire = time.Time{}
+	cf.add(12, []*pb.DirectedEdge{friend})
+	require.Len(t, cf.pending, 2)
+	require.Contains(t, cf.pending, uint64(11))
+	require.Contains(t, cf.pending, uint64(12))
+}
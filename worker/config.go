@@ -15,14 +15,22 @@
  */
 package worker
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 type IPRange struct {
 	Lower, Upper net.IP
 }
 
 type Options struct {
-	ExportPath          string
+	ExportPath string
+	// ExportWorkers is the number of goroutines compressing the data of an export, each one
+	// into its own parts, and ExportPartSize the size in bytes at which a part is closed and
+	// the next one started, zero to not split them.
+	ExportWorkers       int
+	ExportPartSize      int64
 	NumPendingProposals int
 	// TODO: Get rid of this here.
 	Tracing             float64
@@ -32,6 +40,37 @@ type Options struct {
 	ExpandEdge          bool
 	WhiteListedIPRanges []IPRange
 	MaxRetries          int
+	// BackupKey is the AES key used to encrypt backups, nil to not encrypt them.
+	BackupKey []byte
+	// BackupRateLimit is the maximum rate of backup uploads and restore downloads in MB/s,
+	// zero for no limit.
+	BackupRateLimit float64
+	// BackupSchedule is the schedule of the backups taken automatically to
+	// BackupDestination, empty to not take any. See backup.ParseSchedule.
+	BackupSchedule    string
+	BackupDestination string
+	// BackupFullEvery is the number of scheduled backups in a chain, a full backup followed
+	// by incremental ones. Zero takes only incremental backups after the first full one.
+	BackupFullEvery int
+	// BackupCompression is the compression codec of the scheduled backups.
+	BackupCompression string
+	// BackupSkipIndexes leaves the index, reverse and count keys out of the scheduled backups.
+	BackupSkipIndexes bool
+	// ChangeDataCapture keeps the edges of the pending transactions, to send them to the
+	// subscribers of Worker.Subscribe once they're committed.
+	ChangeDataCapture bool
+	// Learner joins a group as a learner, which replicates it but never votes or becomes its
+	// leader. LearnerGroup is the group to join, zero to let Zero pick the one with the fewest
+	// learners.
+	Learner      bool
+	LearnerGroup uint32
+	// SnapshotRateLimit is the maximum rate in MB/s of the snapshots sent to the other members
+	// of the group, zero for no limit.
+	SnapshotRateLimit float64
+	// ReplicateFrom is the location of the backups of another cluster, which this cluster
+	// replicates every ReplicateInterval, empty to not replicate. See replicate.
+	ReplicateFrom     string
+	ReplicateInterval time.Duration
 }
 
 var Config Options
@@ -72,9 +72,10 @@ func newNode(store *raftwal.DiskStorage, gid uint32, id uint64, myAddr string) *
 	glog.Infof("Node ID: %v with GroupID: %v\n", id, gid)
 
 	rc := &pb.RaftContext{
-		Addr:  myAddr,
-		Group: gid,
-		Id:    id,
+		Addr:      myAddr,
+		Group:     gid,
+		Id:        id,
+		IsLearner: Config.Learner,
 	}
 	m := conn.NewNode(rc, store)
 
@@ -155,6 +156,39 @@ func detectPendingTxns(attr string) error {
 	return errHasPendingTxns
 }
 
+// recordDrop writes x.DropKey(attr) at ts, for the incremental backups taken after the drop of
+// attr, or of all the data if attr is empty, to carry it. Drops are only recorded once the group
+// takes backups, see recordDrops. Proposals of older Alphas may have no ts, their drops aren't
+// recorded.
+func recordDrop(attr string, ts uint64) error {
+	if ts == 0 {
+		return nil
+	}
+	if ok, err := takesBackups(); err != nil || !ok {
+		return err
+	}
+	w := x.NewTxnWriter(pstore)
+	w.BlindWrite = true
+	if err := w.SetAt(x.DropKey(attr), nil, 0, ts); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// takesBackups returns whether x.BackupsKey is set, i.e. the group records its drops.
+func takesBackups() (bool, error) {
+	txn := pstore.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	switch _, err := txn.Get(x.BackupsKey()); err {
+	case nil:
+		return true, nil
+	case badger.ErrKeyNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 // We don't support schema mutations across nodes in a transaction.
 // Wait for all transactions to either abort or complete and all write transactions
 // involving the predicate are aborted until schema mutations are done.
@@ -164,8 +198,15 @@ func (n *node) applyMutations(ctx context.Context, proposal *pb.Proposal) error
 	if proposal.Mutations.DropAll {
 		// Ensures nothing get written to disk due to commit proposals.
 		posting.Oracle().ResetTxns()
+		changes.reset()
 		schema.State().DeleteAll()
-		return posting.DeleteAll()
+		if err := posting.DeleteAll(); err != nil {
+			return err
+		}
+		if Config.ChangeDataCapture {
+			changes.dropped("", proposal.Mutations.StartTs)
+		}
+		return recordDrop("", proposal.Mutations.StartTs)
 	}
 
 	if proposal.Mutations.StartTs == 0 {
@@ -218,7 +259,13 @@ func (n *node) applyMutations(ctx context.Context, proposal *pb.Proposal) error
 				return err
 			}
 			span.Annotatef(nil, "Deleting predicate: %s", edge.Attr)
-			return posting.DeletePredicate(ctx, edge.Attr)
+			if err := posting.DeletePredicate(ctx, edge.Attr); err != nil {
+				return err
+			}
+			if Config.ChangeDataCapture {
+				changes.dropped(edge.Attr, startTs)
+			}
+			return recordDrop(edge.Attr, startTs)
 		}
 		// Dont derive schema when doing deletion.
 		if edge.Op == pb.DirectedEdge_DEL {
@@ -267,6 +314,9 @@ func (n *node) applyMutations(ctx context.Context, proposal *pb.Proposal) error
 	if retries > 0 {
 		span.Annotatef(nil, "retries=true num=%d", retries)
 	}
+	if Config.ChangeDataCapture {
+		changes.add(m.StartTs, m.Edges)
+	}
 	return nil
 }
 
@@ -306,6 +356,10 @@ func (n *node) applyCommitted(proposal *pb.Proposal) error {
 		n.elog.Printf("Applying Oracle Delta for key: %s", proposal.Key)
 		return n.commitOrAbort(proposal.Key, proposal.Delta)
 
+	case proposal.Restore != nil:
+		n.elog.Printf("Applying restore for key: %s", proposal.Key)
+		return applyRestore(proposal.Restore)
+
 	case proposal.Snapshot != nil:
 		existing, err := n.Store.Snapshot()
 		if err != nil {
@@ -485,6 +539,13 @@ func (n *node) commitOrAbort(pkey string, delta *pb.OracleDelta) error {
 	}
 	// Now advance Oracle(), so we can service waiting reads.
 	posting.Oracle().ProcessDelta(delta)
+
+	// The changes are sent once they can be read.
+	if Config.ChangeDataCapture {
+		for _, txn := range delta.Txns {
+			changes.done(txn.StartTs, txn.CommitTs)
+		}
+	}
 	return nil
 }
 
@@ -811,8 +872,8 @@ func (n *node) rollupLists(readTs uint64) error {
 	sl := stream.Lists{Stream: writer, DB: pstore}
 	sl.ChooseKeyFunc = func(item *badger.Item) bool {
 		pk := x.Parse(item.Key())
-		if pk.IsSchema() {
-			// Skip if schema.
+		if !pk.IsPredicate() || pk.IsSchema() {
+			// Skip if schema, or not a key of a predicate, like a drop or reindex key.
 			return false
 		}
 		// Return true if we don't find the BitCompletePosting bit.
@@ -1103,10 +1164,13 @@ func (n *node) InitAndStartNode() {
 			n.SetConfState(&sp.Metadata.ConfState)
 
 			members := groups().members(n.gid)
-			for _, id := range sp.Metadata.ConfState.Nodes {
-				m, ok := members[id]
-				if ok {
-					n.Connect(id, m.Addr)
+			cs := sp.Metadata.ConfState
+			for _, ids := range [][]uint64{cs.Nodes, cs.Learners} {
+				for _, id := range ids {
+					m, ok := members[id]
+					if ok {
+						n.Connect(id, m.Addr)
+					}
 				}
 			}
 		}
@@ -1125,6 +1189,8 @@ func (n *node) InitAndStartNode() {
 			n.retryUntilSuccess(n.joinPeers, time.Second)
 			n.SetRaft(raft.StartNode(n.Cfg, nil))
 		} else {
+			// A learner can't start a group, it'd have nobody to learn from.
+			x.AssertTruef(!Config.Learner, "No peer to learn from in group %d", n.gid)
 			peers := []raft.Peer{{ID: n.Id}}
 			n.SetRaft(raft.StartNode(n.Cfg, peers))
 			// Trigger election, so this node can become the leader of this single-node cluster.
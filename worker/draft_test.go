@@ -18,6 +18,7 @@ package worker
 
 import (
 	"io/ioutil"
+	"math"
 	"os"
 	"testing"
 
@@ -26,8 +27,10 @@ import (
 	"github.com/dgraph-io/dgraph/posting"
 	"github.com/dgraph-io/dgraph/protos/pb"
 	"github.com/dgraph-io/dgraph/raftwal"
+	"github.com/dgraph-io/dgraph/schema"
 	"github.com/dgraph-io/dgraph/x"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
 )
 
 func openBadger(dir string) (*badger.DB, error) {
@@ -124,3 +127,36 @@ func TestCalculateSnapshot(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, snap)
 }
+
+func TestDropAllRecordsDrop(t *testing.T) {
+	require.NoError(t, schema.ParseBytes([]byte(`name: string .`), 1))
+	dropAll := func(ts uint64) {
+		var n node
+		proposal := &pb.Proposal{Mutations: &pb.Mutations{DropAll: true, StartTs: ts}}
+		require.NoError(t, n.applyMutations(context.Background(), proposal))
+	}
+	keys := func() [][]byte {
+		txn := pstore.NewTransactionAt(math.MaxUint64, false)
+		defer txn.Discard()
+		itr := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer itr.Close()
+		var keys [][]byte
+		for itr.Rewind(); itr.Valid(); itr.Next() {
+			if pk := x.Parse(itr.Item().Key()); !pk.IsSchema() {
+				keys = append(keys, itr.Item().KeyCopy(nil))
+			}
+		}
+		return keys
+	}
+
+	// Without backups, the drop leaves no key behind.
+	dropAll(10)
+	require.Empty(t, keys())
+
+	// Once the group takes backups, the drop is recorded for the incremental ones.
+	w := x.NewTxnWriter(pstore)
+	require.NoError(t, w.SetAt(x.BackupsKey(), nil, 0, 1))
+	require.NoError(t, w.Flush())
+	dropAll(20)
+	require.Equal(t, [][]byte{x.DropKey(""), x.BackupsKey()}, keys())
+}
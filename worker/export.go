@@ -20,12 +20,19 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -34,6 +41,7 @@ import (
 	"github.com/dgraph-io/badger"
 	"github.com/dgraph-io/dgraph/posting"
 	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
 	"github.com/dgraph-io/dgraph/stream"
 	"github.com/dgraph-io/dgraph/types"
 	"github.com/dgraph-io/dgraph/types/facets"
@@ -139,63 +147,110 @@ func toRDF(pl *posting.List, prefix string, readTs uint64) (*pb.KV, error) {
 	return kv, err
 }
 
-func toSchema(attr string, update pb.SchemaUpdate) (*pb.KV, error) {
-	// bytes.Buffer never returns error for any of the writes. So, we don't need to check them.
+// toCSV writes the postings of pl as rows of a CSV file with the uid of the node, the value or
+// uid it points to, and the language of the value if any. Facets are left out.
+func toCSV(pl *posting.List, uid uint64, readTs uint64) (*pb.KV, error) {
 	var buf bytes.Buffer
-	if strings.ContainsRune(attr, ':') {
-		buf.WriteRune('<')
-		buf.WriteString(attr)
-		buf.WriteRune('>')
-	} else {
-		buf.WriteString(attr)
-	}
-	buf.WriteByte(':')
-	if update.List {
-		buf.WriteRune('[')
-	}
-	buf.WriteString(types.TypeID(update.ValueType).Name())
-	if update.List {
-		buf.WriteRune(']')
-	}
-	if update.Directive == pb.SchemaUpdate_REVERSE {
-		buf.WriteString(" @reverse")
-	} else if update.Directive == pb.SchemaUpdate_INDEX && len(update.Tokenizer) > 0 {
-		buf.WriteString(" @index(")
-		buf.WriteString(strings.Join(update.Tokenizer, ","))
-		buf.WriteByte(')')
-	}
-	if update.Count {
-		buf.WriteString(" @count")
-	}
-	if update.Lang {
-		buf.WriteString(" @lang")
-	}
-	if update.Upsert {
-		buf.WriteString(" @upsert")
+	w := csv.NewWriter(&buf)
+
+	err := pl.Iterate(readTs, 0, func(p *pb.Posting) error {
+		subject := fmt.Sprintf("%#x", uid)
+		if p.PostingType == pb.Posting_REF {
+			return w.Write([]string{subject, fmt.Sprintf("%#x", p.Uid), ""})
+		}
+		src := types.ValueForType(types.TypeID(p.ValType))
+		src.Value = p.Value
+		str, err := types.Convert(src, types.StringID)
+		if err != nil {
+			glog.Errorf("While converting %v to string. Err=%v. Ignoring.\n", src, err)
+			return nil
+		}
+		val := strings.TrimRight(str.Value.(string), "\x00")
+		return w.Write([]string{subject, val, string(p.LangTag)})
+	})
+	w.Flush()
+	if err == nil {
+		err = w.Error()
 	}
-	buf.WriteString(" . \n")
 	kv := &pb.KV{
 		Val:     buf.Bytes(),
+		Version: 1, // Data value.
+	}
+	return kv, err
+}
+
+func toSchema(attr string, update pb.SchemaUpdate) (*pb.KV, error) {
+	kv := &pb.KV{
+		Val:     []byte(schema.Format(attr, update) + " . \n"),
 		Version: 2, // Schema value
 	}
 	return kv, nil
 }
 
 type fileWriter struct {
-	fd *os.File
+	fd io.WriteCloser
 	bw *bufio.Writer
+	cw *countingWriter
 	gw *gzip.Writer
 }
 
-func (writer *fileWriter) open(fpath string) error {
-	var err error
-	writer.fd, err = os.Create(fpath)
-	if err != nil {
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// syncFile is a local file of an export, synced when it's closed.
+type syncFile struct {
+	*os.File
+}
+
+func (f syncFile) Close() error {
+	if err := f.Sync(); err != nil {
+		x.Ignore(f.File.Close())
 		return err
 	}
+	return f.File.Close()
+}
+
+// exportFiles creates the files of an export, in the local export directory or at a remote
+// destination.
+type exportFiles struct {
+	dir  string // the directory of the export, relative to the export path or destination
+	dest string // URI of the destination, empty to write to Config.ExportPath
+}
+
+func (ef *exportFiles) create(name string) (io.WriteCloser, error) {
+	if ef.dest != "" {
+		return createExportObject(ef.dest, path.Join(ef.dir, name))
+	}
+	fd, err := os.Create(filepath.Join(Config.ExportPath, ef.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return syncFile{fd}, nil
+}
 
+// location returns where the files are written, for the logs.
+func (ef *exportFiles) location() string {
+	if ef.dest != "" {
+		return strings.TrimSuffix(ef.dest, "/") + "/" + ef.dir
+	}
+	return filepath.Join(Config.ExportPath, ef.dir)
+}
+
+func (writer *fileWriter) open(fd io.WriteCloser) error {
+	var err error
+	writer.fd = fd
 	writer.bw = bufio.NewWriterSize(writer.fd, 1e6)
-	writer.gw, err = gzip.NewWriterLevel(writer.bw, gzip.BestCompression)
+	writer.cw = &countingWriter{w: writer.bw}
+	writer.gw, err = gzip.NewWriterLevel(writer.cw, gzip.DefaultCompression)
 	return err
 }
 
@@ -207,31 +262,244 @@ func (writer *fileWriter) Close() error {
 		return err
 	}
 	if err := writer.bw.Flush(); err != nil {
+		x.Ignore(writer.fd.Close())
 		return err
 	}
-	if err := writer.fd.Sync(); err != nil {
+	return writer.fd.Close()
+}
+
+// exportPart is a file with part of the data of a group, compressed on its own.
+type exportPart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	NQuads int64  `json:"nquads,omitempty"`
+}
+
+// exportManifest lists the files of the export of a group. It's written last, so an export
+// without a manifest is incomplete.
+type exportManifest struct {
+	Group       uint32       `json:"group"`
+	ReadTs      uint64       `json:"read_ts"`
+	Format      string       `json:"format"`
+	Compression string       `json:"compression"`
+	Schema      string       `json:"schema"`
+	Parts       []exportPart `json:"parts"`
+
+	// The predicates selected by the request, if it didn't export all of them.
+	Predicates        []string `json:"predicates,omitempty"`
+	PredicatePrefixes []string `json:"predicate_prefixes,omitempty"`
+}
+
+// partWriter compresses the data of an export into parts, with as many goroutines as workers.
+// Each goroutine writes its own part, and starts a new one once it's partSize long. newPart
+// returns the name and the file of part n.
+type partWriter struct {
+	newPart  func(n int) (string, io.WriteCloser, error)
+	partSize int64
+	batches  chan []*pb.KV
+	wg       sync.WaitGroup
+	numParts int32
+
+	sync.Mutex
+	parts []exportPart
+	err   error
+}
+
+func newPartWriter(workers int, partSize int64,
+	newPart func(n int) (string, io.WriteCloser, error)) *partWriter {
+	if workers < 1 {
+		workers = 1
+	}
+	pw := &partWriter{
+		newPart:  newPart,
+		partSize: partSize,
+		batches:  make(chan []*pb.KV, workers),
+	}
+	pw.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pw.run()
+	}
+	return pw
+}
+
+func (pw *partWriter) setErr(err error) {
+	pw.Lock()
+	defer pw.Unlock()
+	if pw.err == nil {
+		pw.err = err
+	}
+}
+
+func (pw *partWriter) getErr() error {
+	pw.Lock()
+	defer pw.Unlock()
+	return pw.err
+}
+
+func (pw *partWriter) run() {
+	defer pw.wg.Done()
+	var writer *fileWriter
+	var part exportPart
+	closePart := func() error {
+		if writer == nil {
+			return nil
+		}
+		err := writer.Close()
+		part.Size = writer.cw.n
+		writer = nil
+		if err != nil {
+			return err
+		}
+		pw.Lock()
+		pw.parts = append(pw.parts, part)
+		pw.Unlock()
+		return nil
+	}
+
+	for batch := range pw.batches {
+		if pw.getErr() != nil {
+			continue // Drain the batches.
+		}
+		if writer == nil {
+			name, fd, err := pw.newPart(int(atomic.AddInt32(&pw.numParts, 1)))
+			if err != nil {
+				pw.setErr(err)
+				continue
+			}
+			writer = &fileWriter{}
+			if err := writer.open(fd); err != nil {
+				x.Ignore(fd.Close())
+				pw.setErr(err)
+				writer = nil
+				continue
+			}
+			part = exportPart{Name: name}
+		}
+		for _, kv := range batch {
+			if _, err := writer.gw.Write(kv.Val); err != nil {
+				pw.setErr(err)
+				break
+			}
+			part.NQuads += int64(bytes.Count(kv.Val, []byte{'\n'}))
+		}
+		if pw.partSize > 0 && writer.cw.n >= pw.partSize {
+			if err := closePart(); err != nil {
+				pw.setErr(err)
+			}
+		}
+	}
+	if err := closePart(); err != nil {
+		pw.setErr(err)
+	}
+}
+
+func (pw *partWriter) write(batch []*pb.KV) error {
+	if err := pw.getErr(); err != nil {
 		return err
 	}
-	return writer.fd.Close()
+	pw.batches <- batch
+	return nil
+}
+
+// Close waits for the parts to be written, and returns them sorted by name.
+func (pw *partWriter) Close() ([]exportPart, error) {
+	close(pw.batches)
+	pw.wg.Wait()
+	sort.Slice(pw.parts, func(i, j int) bool { return pw.parts[i].Name < pw.parts[j].Name })
+	return pw.parts, pw.err
+}
+
+// csvWriter writes the data of each predicate to its own CSV file, with the predicate in the
+// key of the KVs. The header of a file names its columns uid, <predicate> and lang. newFile
+// returns the name and the file of a predicate.
+type csvWriter struct {
+	newFile func(attr string) (string, io.WriteCloser, error)
+	files   map[string]*fileWriter
+	names   map[string]string
+}
+
+func newCSVWriter(newFile func(attr string) (string, io.WriteCloser, error)) *csvWriter {
+	return &csvWriter{
+		newFile: newFile,
+		files:   make(map[string]*fileWriter),
+		names:   make(map[string]string),
+	}
+}
+
+func (cw *csvWriter) write(batch []*pb.KV) error {
+	for _, kv := range batch {
+		attr := string(kv.Key)
+		writer, ok := cw.files[attr]
+		if !ok {
+			name, fd, err := cw.newFile(attr)
+			if err != nil {
+				return err
+			}
+			writer = &fileWriter{}
+			if err := writer.open(fd); err != nil {
+				x.Ignore(fd.Close())
+				return err
+			}
+			cw.files[attr] = writer
+			cw.names[attr] = name
+
+			w := csv.NewWriter(writer.gw)
+			if err := w.Write([]string{"uid", attr, "lang"}); err != nil {
+				return err
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return err
+			}
+		}
+		if _, err := writer.gw.Write(kv.Val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the files, and returns them sorted by name.
+func (cw *csvWriter) Close() ([]exportPart, error) {
+	var parts []exportPart
+	var rerr error
+	for attr, writer := range cw.files {
+		if err := writer.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+		parts = append(parts, exportPart{Name: cw.names[attr], Size: writer.cw.n})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Name < parts[j].Name })
+	return parts, rerr
+}
+
+// dataWriter writes the data of an export, into parts or into a file per predicate.
+type dataWriter interface {
+	write(batch []*pb.KV) error
+	Close() ([]exportPart, error)
 }
 
 type writerMux struct {
-	data   *fileWriter
+	data   dataWriter
 	schema *fileWriter
 }
 
 func (mux *writerMux) Send(kvs *pb.KVS) error {
+	var data []*pb.KV
 	for _, kv := range kvs.Kv {
-		var writer *fileWriter
 		switch kv.Version {
 		case 1: // data
-			writer = mux.data
+			data = append(data, kv)
 		case 2: // schema
-			writer = mux.schema
+			if _, err := mux.schema.gw.Write(kv.Val); err != nil {
+				return err
+			}
 		default:
 			glog.Fatalf("Invalid data type found: %x", kv.Key)
 		}
-		if _, err := writer.gw.Write(kv.Val); err != nil {
+	}
+	if len(data) > 0 {
+		if err := mux.data.write(data); err != nil {
 			return err
 		}
 	}
@@ -239,12 +507,98 @@ func (mux *writerMux) Send(kvs *pb.KVS) error {
 	return nil
 }
 
-// export creates a export of data by exporting it as an RDF gzip.
+// The formats of an export. The data is exported as N-Quads by default, or as CSV files with
+// a file per predicate.
+const (
+	rdfFormat = "rdf"
+	csvFormat = "csv"
+)
+
+// exportFormat returns the format of an export, or an error if it isn't supported.
+func exportFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", rdfFormat:
+		return rdfFormat, nil
+	case csvFormat:
+		return csvFormat, nil
+	case "parquet":
+		return "", x.Errorf("Export to Parquet isn't supported yet, use csv instead")
+	default:
+		return "", x.Errorf("Invalid export format: %q. Must be rdf or csv", format)
+	}
+}
+
+// exportsPredicate returns whether attr is exported by the request in, which exports all the
+// predicates unless it selects some by name or prefix.
+func exportsPredicate(in *pb.ExportRequest, attr string) bool {
+	if len(in.Predicates) == 0 && len(in.PredicatePrefixes) == 0 {
+		return true
+	}
+	for _, pred := range in.Predicates {
+		if attr == pred {
+			return true
+		}
+	}
+	for _, prefix := range in.PredicatePrefixes {
+		if strings.HasPrefix(attr, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportByName exports the predicates named by in, which selects no prefixes. The keys of each
+// predicate are streamed on their own, instead of going through all the keys of the group.
+// Their schema keys aren't under the prefix of the predicate, they're read directly.
+func exportByName(ctx context.Context, sl *stream.Lists, mux *writerMux,
+	in *pb.ExportRequest) error {
+	txn := pstore.NewTransactionAt(in.ReadTs, false)
+	defer txn.Discard()
+
+	seen := make(map[string]bool)
+	for _, attr := range in.Predicates {
+		if seen[attr] || attr == "_predicate_" || !groups().ServesTablet(attr) {
+			continue
+		}
+		seen[attr] = true
+
+		item, err := txn.Get(x.SchemaKey(attr))
+		switch {
+		case err == badger.ErrKeyNotFound:
+		case err != nil:
+			return err
+		default:
+			var update pb.SchemaUpdate
+			if err := item.Value(update.Unmarshal); err != nil {
+				return x.Wrapf(err, "while reading the schema of %q", attr)
+			}
+			kv, err := toSchema(attr, update)
+			if err != nil {
+				return err
+			}
+			if err := mux.Send(&pb.KVS{Kv: []*pb.KV{kv}}); err != nil {
+				return err
+			}
+		}
+
+		sl.Predicate = attr
+		if err := sl.Orchestrate(ctx, "Export "+attr, in.ReadTs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// export creates a export of data by exporting it as an RDF or CSV gzip.
 func export(ctx context.Context, in *pb.ExportRequest) error {
 	if in.GroupId != groups().groupId() {
 		return x.Errorf("Export request group mismatch. Mine: %d. Requested: %d\n",
 			groups().groupId(), in.GroupId)
 	}
+	format, err := exportFormat(in.Format)
+	if err != nil {
+		return err
+	}
 	glog.Infof("Export requested at %d.", in.ReadTs)
 
 	// Let's wait for this server to catch up to all the updates until this ts.
@@ -253,36 +607,49 @@ func export(ctx context.Context, in *pb.ExportRequest) error {
 	}
 	glog.Infof("Running export for group %d at timestamp %d.", in.GroupId, in.ReadTs)
 
+	// The files are written to the export directory, or uploaded to the destination.
 	uts := time.Unix(in.UnixTs, 0)
-	bdir := path.Join(Config.ExportPath, fmt.Sprintf(
-		"dgraph.r%d.u%s", in.ReadTs, uts.UTC().Format("0102.1504")))
-
-	if err := os.MkdirAll(bdir, 0700); err != nil {
-		return err
-	}
-	path := func(suffix string) (string, error) {
-		return filepath.Abs(path.Join(bdir, fmt.Sprintf("g%02d.%s", in.GroupId, suffix)))
+	files := &exportFiles{
+		dir:  fmt.Sprintf("dgraph.r%d.u%s", in.ReadTs, uts.UTC().Format("0102.1504")),
+		dest: in.Destination,
 	}
-
-	// Open data file now.
-	dataPath, err := path("rdf.gz")
-	if err != nil {
-		return err
+	if files.dest == "" {
+		if err := os.MkdirAll(filepath.Join(Config.ExportPath, files.dir), 0700); err != nil {
+			return err
+		}
 	}
-	glog.Infof("Exporting data for group: %d at %s\n", in.GroupId, dataPath)
-	dataWriter := &fileWriter{}
-	if err := dataWriter.open(dataPath); err != nil {
-		return err
+	create := func(suffix string) (string, io.WriteCloser, error) {
+		name := fmt.Sprintf("g%02d.%s", in.GroupId, suffix)
+		fd, err := files.create(name)
+		return name, fd, err
+	}
+
+	// The N-Quads are written to parts by Config.ExportWorkers goroutines. The CSV files are
+	// written one per predicate.
+	glog.Infof("Exporting data for group: %d to %s in %s format\n", in.GroupId,
+		files.location(), format)
+	var dataWriter dataWriter
+	switch format {
+	case csvFormat:
+		dataWriter = newCSVWriter(func(attr string) (string, io.WriteCloser, error) {
+			return create(url.PathEscape(attr) + ".csv.gz")
+		})
+	default:
+		dataWriter = newPartWriter(Config.ExportWorkers, Config.ExportPartSize,
+			func(n int) (string, io.WriteCloser, error) {
+				return create(fmt.Sprintf("%04d.rdf.gz", n))
+			})
 	}
 
 	// Open schema file now.
-	schemaPath, err := path("schema.gz")
+	schemaName, fd, err := create("schema.gz")
 	if err != nil {
 		return err
 	}
-	glog.Infof("Exporting schema for group: %d at %s\n", in.GroupId, schemaPath)
+	glog.Infof("Exporting schema for group: %d at %s\n", in.GroupId, schemaName)
 	schemaWriter := &fileWriter{}
-	if err := schemaWriter.open(schemaPath); err != nil {
+	if err := schemaWriter.open(fd); err != nil {
+		x.Ignore(fd.Close())
 		return err
 	}
 
@@ -290,10 +657,10 @@ func export(ctx context.Context, in *pb.ExportRequest) error {
 	sl := stream.Lists{Stream: &mux, DB: pstore}
 	sl.ChooseKeyFunc = func(item *badger.Item) bool {
 		pk := x.Parse(item.Key())
-		if pk.Attr == "_predicate_" {
+		if pk == nil || !pk.IsPredicate() || pk.Attr == "_predicate_" {
 			return false
 		}
-		if !groups().ServesTablet(pk.Attr) {
+		if !groups().ServesTablet(pk.Attr) || !exportsPredicate(in, pk.Attr) {
 			return false
 		}
 		// We need to ensure that schema keys are separately identifiable, so they can be
@@ -319,11 +686,18 @@ func export(ctx context.Context, in *pb.ExportRequest) error {
 			return toSchema(pk.Attr, update)
 
 		case pk.IsData():
-			prefix := fmt.Sprintf("<_:uid%x> <%s> ", pk.Uid, pk.Attr)
 			pl, err := posting.ReadPostingList(key, itr)
 			if err != nil {
 				return nil, err
 			}
+			if format == csvFormat {
+				kv, err := toCSV(pl, pk.Uid, in.ReadTs)
+				if kv != nil {
+					kv.Key = []byte(pk.Attr)
+				}
+				return kv, err
+			}
+			prefix := fmt.Sprintf("<_:uid%x> <%s> ", pk.Uid, pk.Attr)
 			return toRDF(pl, prefix, in.ReadTs)
 
 		default:
@@ -333,16 +707,50 @@ func export(ctx context.Context, in *pb.ExportRequest) error {
 	}
 
 	// All prepwork done. Time to roll.
-	if err := sl.Orchestrate(ctx, "Export", in.ReadTs); err != nil {
-		return err
+	if len(in.Predicates) > 0 && len(in.PredicatePrefixes) == 0 {
+		err = exportByName(ctx, &sl, &mux, in)
+	} else {
+		err = sl.Orchestrate(ctx, "Export", in.ReadTs)
 	}
-	if err := mux.data.Close(); err != nil {
+	parts, perr := mux.data.Close()
+	if err != nil {
 		return err
 	}
+	if perr != nil {
+		return perr
+	}
 	if err := mux.schema.Close(); err != nil {
 		return err
 	}
-	glog.Infof("Export DONE for group %d at timestamp %d.", in.GroupId, in.ReadTs)
+
+	m := &exportManifest{
+		Group:       in.GroupId,
+		ReadTs:      in.ReadTs,
+		Format:      format,
+		Compression: "gzip",
+		Schema:      schemaName,
+		Parts:       parts,
+
+		Predicates:        in.Predicates,
+		PredicatePrefixes: in.PredicatePrefixes,
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, fd, err = create("manifest.json")
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(b); err != nil {
+		x.Ignore(fd.Close())
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		return err
+	}
+	glog.Infof("Export DONE for group %d at timestamp %d, in %d parts.", in.GroupId, in.ReadTs,
+		len(parts))
 	return nil
 }
 
@@ -384,7 +792,15 @@ func handleExportOverNetwork(ctx context.Context, in *pb.ExportRequest) error {
 	return err
 }
 
-func ExportOverNetwork(ctx context.Context) error {
+// ExportOverNetwork exports the data of all the groups in format, rdf or csv. An empty format
+// is rdf. The files are written to the export directory of the Alphas, or to the destination
+// URI if it's set, in the formats of the backup targets. If predicates or prefixes are set,
+// only the predicates named or starting with the prefixes are exported.
+func ExportOverNetwork(ctx context.Context, format, destination string,
+	predicates, prefixes []string) error {
+	if _, err := exportFormat(format); err != nil {
+		return err
+	}
 	// If we haven't even had a single membership update, don't run export.
 	if err := x.HealthCheck(); err != nil {
 		glog.Errorf("Rejecting export request due to health check error: %v\n", err)
@@ -407,9 +823,14 @@ func ExportOverNetwork(ctx context.Context) error {
 	for _, gid := range gids {
 		go func(group uint32) {
 			req := &pb.ExportRequest{
-				GroupId: group,
-				ReadTs:  readTs,
-				UnixTs:  time.Now().Unix(),
+				GroupId:     group,
+				ReadTs:      readTs,
+				UnixTs:      time.Now().Unix(),
+				Format:      format,
+				Destination: destination,
+
+				Predicates:        predicates,
+				PredicatePrefixes: prefixes,
 			}
 			ch <- handleExportOverNetwork(ctx, req)
 		}(gid)
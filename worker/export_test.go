@@ -20,6 +20,10 @@ import (
 	"bufio"
 	"compress/gzip"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -121,12 +125,15 @@ func TestExport(t *testing.T) {
 	searchDir := bdir
 	fileList := []string{}
 	schemaFileList := []string{}
+	var manifestPath string
 	err = filepath.Walk(searchDir, func(path string, f os.FileInfo, err error) error {
 		if f.IsDir() {
 			return nil
 		}
 		if path != bdir {
-			if strings.Contains(path, "schema") {
+			if strings.HasSuffix(path, "manifest.json") {
+				manifestPath = path
+			} else if strings.Contains(path, "schema") {
 				schemaFileList = append(schemaFileList, path)
 			} else {
 				fileList = append(fileList, path)
@@ -137,6 +144,15 @@ func TestExport(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 1, len(fileList), "filelist=%v", fileList)
 
+	b, err := ioutil.ReadFile(manifestPath)
+	require.NoError(t, err)
+	var m exportManifest
+	require.NoError(t, json.Unmarshal(b, &m))
+	require.Equal(t, "g01.schema.gz", m.Schema)
+	require.Len(t, m.Parts, 1)
+	require.Equal(t, filepath.Base(fileList[0]), m.Parts[0].Name)
+	require.Equal(t, int64(8), m.Parts[0].NQuads)
+
 	file := fileList[0]
 	f, err := os.Open(file)
 	require.NoError(t, err)
@@ -233,6 +249,126 @@ func TestExport(t *testing.T) {
 	require.Equal(t, 1, count)
 }
 
+func TestExportCSV(t *testing.T) {
+	initTestExport(t, "name:string @index .")
+	bdir, err := ioutil.TempDir("", "export")
+	require.NoError(t, err)
+	defer os.RemoveAll(bdir)
+
+	Config.ExportPath = bdir
+	readTs := timestamp()
+	posting.Oracle().ProcessDelta(&pb.OracleDelta{MaxAssigned: readTs})
+	err = export(context.Background(),
+		&pb.ExportRequest{ReadTs: readTs, GroupId: 1, Format: "csv"})
+	require.NoError(t, err)
+
+	readCSV := func(name string) [][]string {
+		files, err := filepath.Glob(filepath.Join(bdir, "*", name))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		f, err := os.Open(files[0])
+		require.NoError(t, err)
+		defer f.Close()
+		r, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		rows, err := csv.NewReader(r).ReadAll()
+		require.NoError(t, err)
+		return rows
+	}
+
+	friends := readCSV("g01.friend.csv.gz")
+	require.Equal(t, []string{"uid", "friend", "lang"}, friends[0])
+	require.Equal(t, [][]string{
+		{"0x1", "0x5", ""},
+		{"0x2", "0x5", ""},
+		{"0x3", "0x5", ""},
+		{"0x4", "0x5", ""},
+	}, friends[1:])
+
+	names := readCSV("g01.name.csv.gz")
+	require.Equal(t, [][]string{
+		{"uid", "name", "lang"},
+		{"0x1", "pho\ton", ""},
+		{"0x2", "pho\ton", "en"},
+		{"0x3", "First Line\nSecondLine", ""},
+		{"0x5", "", ""},
+	}, names)
+
+	manifests, err := filepath.Glob(filepath.Join(bdir, "*", "g01.manifest.json"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	b, err := ioutil.ReadFile(manifests[0])
+	require.NoError(t, err)
+	var m exportManifest
+	require.NoError(t, json.Unmarshal(b, &m))
+	require.Equal(t, "csv", m.Format)
+	require.Len(t, m.Parts, 2)
+	require.Equal(t, "g01.friend.csv.gz", m.Parts[0].Name)
+	require.Equal(t, "g01.name.csv.gz", m.Parts[1].Name)
+
+	_, err = exportFormat("parquet")
+	require.Error(t, err)
+}
+
+func TestExportPredicates(t *testing.T) {
+	initTestExport(t, "name:string @index .")
+
+	exportWith := func(in *pb.ExportRequest) (exportManifest, string) {
+		bdir, err := ioutil.TempDir("", "export")
+		require.NoError(t, err)
+		defer os.RemoveAll(bdir)
+
+		Config.ExportPath = bdir
+		in.ReadTs = timestamp()
+		in.GroupId = 1
+		posting.Oracle().ProcessDelta(&pb.OracleDelta{MaxAssigned: in.ReadTs})
+		require.NoError(t, export(context.Background(), in))
+
+		manifests, err := filepath.Glob(filepath.Join(bdir, "*", "g01.manifest.json"))
+		require.NoError(t, err)
+		require.Len(t, manifests, 1)
+		b, err := ioutil.ReadFile(manifests[0])
+		require.NoError(t, err)
+		var m exportManifest
+		require.NoError(t, json.Unmarshal(b, &m))
+
+		f, err := os.Open(filepath.Join(filepath.Dir(manifests[0]), m.Schema))
+		require.NoError(t, err)
+		defer f.Close()
+		r, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		sch, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		return m, string(sch)
+	}
+	nquads := func(m exportManifest) int64 {
+		var n int64
+		for _, part := range m.Parts {
+			n += part.NQuads
+		}
+		return n
+	}
+
+	// By name, the friend edges and their schema.
+	m, sch := exportWith(&pb.ExportRequest{Predicates: []string{"friend", "friend"}})
+	require.Equal(t, []string{"friend", "friend"}, m.Predicates)
+	require.Equal(t, int64(4), nquads(m))
+	require.Equal(t, "friend:uid . \n", sch)
+
+	// By prefix, the names.
+	m, sch = exportWith(&pb.ExportRequest{PredicatePrefixes: []string{"na"}})
+	require.Equal(t, []string{"na"}, m.PredicatePrefixes)
+	require.Equal(t, int64(4), nquads(m))
+	require.Empty(t, sch)
+
+	// Both, and a predicate that doesn't exist.
+	m, _ = exportWith(&pb.ExportRequest{
+		Predicates:        []string{"friend", "none"},
+		PredicatePrefixes: []string{"na"},
+	})
+	require.Equal(t, int64(8), nquads(m))
+}
+
 type skv struct {
 	attr   string
 	schema pb.SchemaUpdate
@@ -372,3 +508,35 @@ func TestToSchema(t *testing.T) {
 // 		buf.Reset()
 // 	}
 // }
+
+func TestExportParts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Parts of at least 1 byte hold one batch each.
+	pw := newPartWriter(3, 1, func(n int) (string, io.WriteCloser, error) {
+		name := fmt.Sprintf("g01.%04d.rdf.gz", n)
+		fd, err := os.Create(filepath.Join(dir, name))
+		return name, fd, err
+	})
+	for i := 0; i < 10; i++ {
+		pw.batches <- []*pb.KV{
+			{Val: []byte(fmt.Sprintf("<_:uid%x> <name> \"a\" .\n", i)), Version: 1},
+			{Val: []byte(fmt.Sprintf("<_:uid%x> <age> \"1\" .\n", i)), Version: 1},
+		}
+	}
+	parts, err := pw.Close()
+	require.NoError(t, err)
+	require.Len(t, parts, 10)
+
+	var nquads int64
+	for i, part := range parts {
+		require.Equal(t, fmt.Sprintf("g01.%04d.rdf.gz", i+1), part.Name)
+		fi, err := os.Stat(filepath.Join(dir, part.Name))
+		require.NoError(t, err)
+		require.Equal(t, fi.Size(), part.Size)
+		nquads += part.NQuads
+	}
+	require.Equal(t, int64(20), nquads)
+}
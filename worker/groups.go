@@ -62,7 +62,8 @@ func groups() *groupi {
 // and either start or restart RAFT nodes.
 // This function triggers RAFT nodes to be created, and is the entrace to the RAFT
 // world from main.go.
-func StartRaftNodes(walStore *badger.DB, bindall bool) {
+func StartRaftNodes(ws *badger.DB, bindall bool) {
+	walStore = ws
 	gr = new(groupi)
 	gr.ctx, gr.cancel = context.WithCancel(context.Background())
 
@@ -91,7 +92,10 @@ func StartRaftNodes(walStore *badger.DB, bindall bool) {
 	// Successfully connect with dgraphzero, before doing anything else.
 
 	// Connect with Zero leader and figure out what group we should belong to.
-	m := &pb.Member{Id: Config.RaftId, Addr: Config.MyAddr}
+	m := &pb.Member{Id: Config.RaftId, Addr: Config.MyAddr, Learner: Config.Learner}
+	if Config.Learner {
+		m.GroupId = Config.LearnerGroup
+	}
 	var connState *pb.ConnectionState
 	var err error
 	for { // Keep on retrying. See: https://github.com/dgraph-io/dgraph/issues/2289
@@ -111,6 +115,14 @@ func StartRaftNodes(walStore *badger.DB, bindall bool) {
 	}
 	glog.Infof("Connected to group zero. Assigned group: %+v\n", connState.GetMember().GetGroupId())
 	Config.RaftId = connState.GetMember().GetId()
+	for _, group := range connState.GetState().GetGroups() {
+		// Whether this Alpha is a learner can't change once it joined its group.
+		if self, ok := group.Members[Config.RaftId]; ok && self.Learner != Config.Learner {
+			glog.Warningf("This Alpha joined its group with learner=%v, ignoring --learner=%v",
+				self.Learner, Config.Learner)
+			Config.Learner = self.Learner
+		}
+	}
 	// This timestamp would be used for reading during snapshot after bulk load.
 	// The stream is async, we need this information before we start or else replica might
 	// not get any data.
@@ -125,15 +137,18 @@ func StartRaftNodes(walStore *badger.DB, bindall bool) {
 	gr.Node = newNode(store, gid, Config.RaftId, Config.MyAddr)
 
 	x.Checkf(schema.LoadFromDb(), "Error while initializing schema")
+	x.Checkf(rebuildMarkedIndexes(), "Error while rebuilding the indexes of a restore")
 	raftServer.Node = gr.Node.Node
 	gr.Node.InitAndStartNode()
 	x.UpdateHealthStatus(true)
 
-	gr.closer = y.NewCloser(4) // Match CLOSER:1 in this file.
+	gr.closer = y.NewCloser(6) // Match CLOSER:1 in this file.
 	go gr.sendMembershipUpdates()
 	go gr.receiveMembershipUpdates()
 	go gr.cleanupTablets()
 	go gr.processOracleDeltaStream()
+	go gr.scheduleBackups()
+	go gr.replicate()
 
 	gr.proposeInitialSchema()
 }
@@ -222,7 +237,9 @@ func (g *groupi) calculateTabletSizes() map[string]*pb.Tablet {
 		item := itr.Item()
 
 		pk := x.Parse(item.Key())
-		if pk == nil {
+		if pk == nil || !pk.IsPredicate() {
+			// Only the keys of predicates belong to tablets. A drop is even kept after its
+			// predicate is gone, see x.DropKey.
 			itr.Next()
 			continue
 		}
@@ -343,6 +360,11 @@ func (g *groupi) BelongsTo(key string) uint32 {
 	return 0
 }
 
+// BelongsTo returns the group serving the tablet of a predicate, zero if it isn't known yet.
+func BelongsTo(attr string) uint32 {
+	return groups().BelongsTo(attr)
+}
+
 func (g *groupi) ServesTabletRW(key string) bool {
 	tablet := g.Tablet(key)
 	if tablet != nil && !tablet.ReadOnly && tablet.GroupId == groups().groupId() {
@@ -417,13 +439,25 @@ func (g *groupi) AnyTwoServers(gid uint32) []string {
 	if !has {
 		return []string{}
 	}
-	var res []string
+	// Learners are read replicas, typically placed away from the voters of their group, so
+	// learners ask learners first and voters ask voters first.
+	var res, others []string
 	for _, m := range group.Members {
 		// map iteration gives us members in no particular order.
+		if m.Learner != Config.Learner {
+			others = append(others, m.Addr)
+			continue
+		}
 		res = append(res, m.Addr)
+		if len(res) >= 2 {
+			return res
+		}
+	}
+	for _, addr := range others {
 		if len(res) >= 2 {
 			break
 		}
+		res = append(res, addr)
 	}
 	return res
 }
@@ -459,15 +493,19 @@ func (g *groupi) AnyServer(gid uint32) *conn.Pool {
 }
 
 func (g *groupi) MyPeer() (uint64, bool) {
+	var peer uint64
 	members := g.members(g.groupId())
-	if members != nil {
-		for _, m := range members {
-			if m.Id != g.Node.Id {
-				return m.Id, true
-			}
+	for _, m := range members {
+		if m.Id == g.Node.Id {
+			continue
+		}
+		// Prefer a voter, which can take part in the join right away.
+		if !m.Learner {
+			return m.Id, true
 		}
+		peer = m.Id
 	}
-	return 0, false
+	return peer, peer > 0
 }
 
 // Leader will try to return the leader of a given group, based on membership information.
@@ -567,6 +605,7 @@ func (g *groupi) doSendMembership(tablets map[string]*pb.Tablet) error {
 		Addr:       Config.MyAddr,
 		Leader:     leader,
 		LastUpdate: uint64(time.Now().Unix()),
+		Learner:    Config.Learner,
 	}
 	group := &pb.Group{
 		Members: make(map[uint64]*pb.Member),
@@ -806,7 +845,8 @@ func (g *groupi) cleanupTablets() {
 
 				// TODO: Investiage out of bounds.
 				pk := x.Parse(item.Key())
-				if pk == nil {
+				if pk == nil || !pk.IsPredicate() {
+					// The drop, reindex, replicated and snapshot keys aren't served as tablets.
 					itr.Next()
 					continue
 				}
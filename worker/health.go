@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/dgraph-io/dgraph/conn"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"golang.org/x/net/context"
+)
+
+// MemberHealth is the health of an Alpha in a group, as reported by /health?all=true.
+type MemberHealth struct {
+	Id     uint64 `json:"id"`
+	Group  uint32 `json:"group"`
+	Addr   string `json:"addr"`
+	Leader bool   `json:"leader"`
+	// Learner is set for a learner, which never votes or becomes the leader.
+	Learner bool `json:"learner,omitempty"`
+	// Self is set for the Alpha that answers the request.
+	Self          bool   `json:"self,omitempty"`
+	AppliedIndex  uint64 `json:"applied_index"`
+	SnapshotIndex uint64 `json:"snapshot_index"`
+	SnapshotTs    uint64 `json:"snapshot_ts"`
+	DiskUsage     int64  `json:"disk_usage_bytes"`
+	// ReplicatedTs is the read ts of the last backup of another cluster replicated into the
+	// group, if it replicates one.
+	ReplicatedTs uint64 `json:"replicated_ts,omitempty"`
+	// Lag is the number of Raft entries applied by the leader of the group, and not yet by this
+	// member. It's nil if the member couldn't be reached.
+	Lag   *uint64 `json:"lag,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+// localHealth returns the state of the Raft node of this Alpha.
+func localHealth() (*pb.HealthInfo, error) {
+	n := groups().Node
+	if n == nil {
+		return nil, conn.ErrNoNode
+	}
+	snap, err := n.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	info := &pb.HealthInfo{
+		Id:            n.Id,
+		GroupId:       n.gid,
+		Addr:          Config.MyAddr,
+		Leader:        n.AmLeader(),
+		AppliedIndex:  n.Applied.DoneUntil(),
+		SnapshotIndex: snap.Index,
+		SnapshotTs:    snap.ReadTs,
+	}
+	if info.ReplicatedTs, err = replicatedTs(); err != nil {
+		return nil, err
+	}
+	// Badger updates the sizes of the files every minute, which is close enough.
+	for _, db := range []*badger.DB{pstore, walStore} {
+		if db != nil {
+			lsm, vlog := db.Size()
+			info.DiskUsage += lsm + vlog
+		}
+	}
+	return info, nil
+}
+
+// replicatedTs returns the read ts of the last backup of another cluster replicated into the
+// group, or zero if it doesn't replicate one.
+func replicatedTs() (uint64, error) {
+	txn := pstore.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	item, err := txn.Get(x.ReplicatedKey())
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(val) != 8 {
+		return 0, x.Errorf("Invalid replicated ts %x", val)
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
+
+func (w *grpcWorker) Health(ctx context.Context, _ *api.Payload) (*pb.HealthInfo, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return localHealth()
+}
+
+func memberHealth(ctx context.Context, m *pb.Member) (*pb.HealthInfo, error) {
+	if n := groups().Node; n != nil && m.Id == n.Id {
+		return localHealth()
+	}
+	pl, err := conn.Get().Get(m.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return pb.NewWorkerClient(pl.Get()).Health(ctx, &api.Payload{})
+}
+
+// ClusterHealth asks each Alpha of every group for the state of its Raft node, and returns it
+// with how far behind the leader of its group each one is. The Alphas that can't be reached
+// are returned with the error.
+func ClusterHealth(ctx context.Context) []*MemberHealth {
+	var members []*MemberHealth
+	for _, gid := range groups().KnownGroups() {
+		for _, m := range groups().members(gid) {
+			members = append(members, &MemberHealth{
+				Id:      m.Id,
+				Group:   gid,
+				Addr:    m.Addr,
+				Leader:  m.Leader,
+				Learner: m.Learner,
+				Self:    m.Id == Config.RaftId,
+			})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, mh := range members {
+		wg.Add(1)
+		go func(mh *MemberHealth) {
+			defer wg.Done()
+			info, err := memberHealth(ctx, &pb.Member{Id: mh.Id, Addr: mh.Addr})
+			if err != nil {
+				mh.Error = err.Error()
+				return
+			}
+			// The member knows better than the membership state whether it's the leader.
+			mh.Leader = info.Leader
+			mh.AppliedIndex = info.AppliedIndex
+			mh.SnapshotIndex = info.SnapshotIndex
+			mh.SnapshotTs = info.SnapshotTs
+			mh.DiskUsage = info.DiskUsage
+			mh.ReplicatedTs = info.ReplicatedTs
+		}(mh)
+	}
+	wg.Wait()
+
+	setLags(members)
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Group != members[j].Group {
+			return members[i].Group < members[j].Group
+		}
+		return members[i].Id < members[j].Id
+	})
+	return members
+}
+
+// setLags sets the lag of the members that were reached, from the applied index of the leader of
+// their group. If the leader couldn't be reached, the most advanced member stands in for it.
+func setLags(members []*MemberHealth) {
+	type ref struct {
+		applied uint64
+		leader  bool
+	}
+	refs := make(map[uint32]ref)
+	for _, mh := range members {
+		if mh.Error != "" {
+			continue
+		}
+		r := refs[mh.Group]
+		switch {
+		case r.leader:
+		case mh.Leader:
+			r = ref{applied: mh.AppliedIndex, leader: true}
+		case mh.AppliedIndex > r.applied:
+			r.applied = mh.AppliedIndex
+		}
+		refs[mh.Group] = r
+	}
+	for _, mh := range members {
+		if mh.Error != "" {
+			continue
+		}
+		var lag uint64
+		if r := refs[mh.Group]; r.applied > mh.AppliedIndex {
+			lag = r.applied - mh.AppliedIndex
+		}
+		mh.Lag = &lag
+	}
+}
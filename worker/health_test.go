@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLags(t *testing.T) {
+	members := []*MemberHealth{
+		{Id: 1, Group: 1, AppliedIndex: 90},
+		{Id: 2, Group: 1, AppliedIndex: 100, Leader: true},
+		{Id: 3, Group: 1, AppliedIndex: 120},
+		{Id: 4, Group: 1, Error: "unreachable"},
+		// Without a leader reached, the most advanced member stands in for it.
+		{Id: 5, Group: 2, AppliedIndex: 40, Leader: true, Error: "unreachable"},
+		{Id: 6, Group: 2, AppliedIndex: 30},
+		{Id: 7, Group: 2, AppliedIndex: 35},
+	}
+	setLags(members)
+
+	lags := make(map[uint64]uint64)
+	for _, mh := range members {
+		if mh.Error != "" {
+			require.Nil(t, mh.Lag)
+			continue
+		}
+		require.NotNil(t, mh.Lag)
+		lags[mh.Id] = *mh.Lag
+	}
+	require.Equal(t, map[uint64]uint64{1: 10, 2: 0, 3: 0, 6: 5, 7: 0}, lags)
+}
@@ -214,6 +214,12 @@ func needReindexing(old pb.SchemaUpdate, current pb.SchemaUpdate) bool {
 			return true
 		}
 	}
+	// if the fulltext index analyzes values differently
+	if current.FulltextLang != old.FulltextLang ||
+		current.FulltextKeepStopwords != old.FulltextKeepStopwords ||
+		current.FulltextNoStem != old.FulltextNoStem {
+		return true
+	}
 
 	return false
 }
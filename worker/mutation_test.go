@@ -222,4 +222,12 @@ func TestNeedReindexing(t *testing.T) {
 	s1 = pb.SchemaUpdate{ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"exact"}}
 	s2 = pb.SchemaUpdate{ValueType: pb.Posting_FLOAT, Directive: pb.SchemaUpdate_NONE}
 	require.True(t, needReindexing(s1, s2))
+
+	s1 = pb.SchemaUpdate{ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"fulltext"}}
+	s2 = pb.SchemaUpdate{ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"fulltext"}, FulltextLang: "de"}
+	require.True(t, needReindexing(s1, s2))
+
+	s1 = pb.SchemaUpdate{ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"fulltext"}, FulltextLang: "de"}
+	s2 = pb.SchemaUpdate{ValueType: pb.Posting_STRING, Directive: pb.SchemaUpdate_INDEX, Tokenizer: []string{"fulltext"}, FulltextLang: "de", FulltextNoStem: true}
+	require.True(t, needReindexing(s1, s2))
 }
@@ -17,6 +17,10 @@
 package worker
 
 import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"sync"
 	"sync/atomic"
 
 	"github.com/coreos/etcd/raft"
@@ -35,11 +39,58 @@ const (
 	MB = 1 << 20
 )
 
+var (
+	snapshotLimitOnce sync.Once
+	snapshotLimit     *ws.Limiter
+)
+
+// snapshotLimiter returns the limiter of the snapshots streamed by this Alpha, shared by all of
+// them.
+func snapshotLimiter() *ws.Limiter {
+	snapshotLimitOnce.Do(func() {
+		snapshotLimit = ws.NewLimiter(Config.SnapshotRateLimit)
+	})
+	return snapshotLimit
+}
+
+// snapshotProgress returns the snapshot this Alpha was receiving when its stream broke, with the
+// last key written, or nil if there's none.
+func snapshotProgress(ps *badger.DB) (*pb.Snapshot, error) {
+	txn := ps.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	item, err := txn.Get(x.SnapshotKey())
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	progress := &pb.Snapshot{}
+	if err := progress.Unmarshal(val); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
 // populateSnapshot gets data for a shard from the leader and writes it to BadgerDB on the follower.
 func (n *node) populateSnapshot(snap pb.Snapshot, ps *badger.DB, pl *conn.Pool) (int, error) {
 	conn := pl.Get()
 	c := pb.NewWorkerClient(conn)
 
+	// If we were already receiving this snapshot, we only ask for the keys after the last one we
+	// wrote. The leader sends the keys in order, so we have all the keys before it.
+	progress, err := snapshotProgress(ps)
+	if err != nil {
+		return 0, err
+	}
+	if progress != nil && progress.Index == snap.Index && progress.ReadTs == snap.ReadTs {
+		snap.SinceKey = progress.SinceKey
+	}
+
 	// Set my RaftContext on the snapshot, so it's easier to locate me.
 	ctx := n.ctx
 	snap.Context = n.RaftContext
@@ -50,8 +101,11 @@ func (n *node) populateSnapshot(snap pb.Snapshot, ps *badger.DB, pl *conn.Pool)
 	if err := stream.Send(&snap); err != nil {
 		return 0, err
 	}
-	// Before we write anything, we should drop all the data stored in ps.
-	if err := ps.DropAll(); err != nil {
+	if len(snap.SinceKey) > 0 {
+		glog.Infof("Resuming snapshot at index %d after key %s", snap.Index,
+			hex.EncodeToString(snap.SinceKey))
+	} else if err := ps.DropAll(); err != nil {
+		// Before we write anything, we should drop all the data stored in ps.
 		return 0, err
 	}
 
@@ -59,6 +113,7 @@ func (n *node) populateSnapshot(snap pb.Snapshot, ps *badger.DB, pl *conn.Pool)
 	count := 0
 	writer := x.NewTxnWriter(ps)
 	writer.BlindWrite = true // Do overwrite keys.
+	progress = &pb.Snapshot{Index: snap.Index, ReadTs: snap.ReadTs}
 	for {
 		kvs, err := stream.Recv()
 		if err != nil {
@@ -79,6 +134,22 @@ func (n *node) populateSnapshot(snap pb.Snapshot, ps *badger.DB, pl *conn.Pool)
 			return 0, err
 		}
 		count += len(kvs.Kv)
+		if len(kvs.Kv) == 0 {
+			continue
+		}
+		// Record the last key of the batch, to resume from it if the stream breaks. Badger
+		// commits in order, so the keys of the batch are written before it.
+		progress.SinceKey = kvs.Kv[len(kvs.Kv)-1].Key
+		val, err := progress.Marshal()
+		if err != nil {
+			return 0, err
+		}
+		if err := writer.SetAt(x.SnapshotKey(), val, 0, 1); err != nil {
+			return 0, err
+		}
+	}
+	if err := writer.Delete(x.SnapshotKey(), 1); err != nil {
+		return 0, err
 	}
 	if err := writer.Flush(); err != nil {
 		return 0, err
@@ -104,18 +175,29 @@ func doStreamSnapshot(snap *pb.Snapshot, stream pb.Worker_StreamSnapshotServer)
 	// Note: This would also pick up schema updates done "after" the snapshot index. Guess that
 	// might be OK. Otherwise, we'd want to version the schemas as well. Currently, they're stored
 	// at timestamp=1.
+	//
+	// The keys are sent in order, after snap.SinceKey if the follower is resuming the stream, and
+	// at the rate set by --snapshot_rate_limit.
 
 	var numKeys uint64
-	sl := ws.Lists{Stream: stream, DB: pstore}
-	sl.ChooseKeyFunc = func(_ *badger.Item) bool {
-		// Pick all keys.
-		return true
+	sl := ws.Lists{
+		Stream:  stream,
+		DB:      pstore,
+		Ordered: true,
+		Since:   snap.SinceKey,
+		Limiter: snapshotLimiter(),
+	}
+	snapshotKey := x.SnapshotKey()
+	sl.ChooseKeyFunc = func(item *badger.Item) bool {
+		// Pick all keys, but the progress of a snapshot this Alpha was receiving.
+		return !bytes.Equal(item.Key(), snapshotKey)
 	}
 	sl.ItemToKVFunc = func(key []byte, itr *badger.Iterator) (*pb.KV, error) {
 		atomic.AddUint64(&numKeys, 1)
 		item := itr.Item()
 		pk := x.Parse(key)
-		if pk.IsSchema() {
+		if !pk.IsPredicate() || pk.IsSchema() {
+			// The schema, drop, reindex and replicated keys aren't posting lists.
 			val, err := item.ValueCopy(nil)
 			if err != nil {
 				return nil, err
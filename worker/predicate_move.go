@@ -81,6 +81,10 @@ func populateKeyValues(ctx context.Context, kvs []*pb.KV) error {
 		return x.Errorf("Error while writing to badger")
 	}
 	wg.Wait()
+	if predicate == "" {
+		// A key that isn't of a predicate, like x.BackupsKey, has no schema to load.
+		return nil
+	}
 	return schema.Load(predicate)
 }
 
@@ -0,0 +1,226 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/conn"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+
+	"golang.org/x/net/context"
+)
+
+// predicateHits counts the tasks served by this Alpha for each predicate since it started.
+var predicateHits struct {
+	sync.Mutex
+	m map[string]uint64
+}
+
+func countHit(attr string) {
+	predicateHits.Lock()
+	defer predicateHits.Unlock()
+	if predicateHits.m == nil {
+		predicateHits.m = make(map[string]uint64)
+	}
+	predicateHits.m[attr]++
+}
+
+func hits() map[string]uint64 {
+	predicateHits.Lock()
+	defer predicateHits.Unlock()
+	res := make(map[string]uint64, len(predicateHits.m))
+	for attr, n := range predicateHits.m {
+		res[attr] = n
+	}
+	return res
+}
+
+// localPredicateStats returns the statistics of the tablets served by this Alpha. Unless hitsOnly
+// is set, it iterates over all their keys to count them.
+func localPredicateStats(hitsOnly bool) []*pb.PredicateStats {
+	gid := groups().groupId()
+	byPred := make(map[string]*pb.PredicateStats)
+	for attr, n := range hits() {
+		if groups().ServesTablet(attr) {
+			byPred[attr] = &pb.PredicateStats{Predicate: attr, GroupId: gid, Hits: n}
+		}
+	}
+	if !hitsOnly {
+		countKeys(byPred, gid)
+	}
+
+	stats := make([]*pb.PredicateStats, 0, len(byPred))
+	for _, ps := range byPred {
+		if !hitsOnly {
+			if node := populateSchema(ps.Predicate,
+				[]string{"type", "tokenizer", "reverse", "count"}); node != nil {
+				ps.Type = node.Type
+				ps.Tokenizers = node.Tokenizer
+				ps.Reverse = node.Reverse
+				ps.Count = node.Count
+			}
+		}
+		stats = append(stats, ps)
+	}
+	return stats
+}
+
+// countKeys adds the keys of the tablets served by this Alpha, and their estimated size, to
+// byPred, in the same way as calculateTabletSizes.
+func countKeys(byPred map[string]*pb.PredicateStats, gid uint32) {
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	txn := pstore.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	itr := txn.NewIterator(opt)
+	defer itr.Close()
+
+	for itr.Rewind(); itr.Valid(); {
+		item := itr.Item()
+		pk := x.Parse(item.Key())
+		if pk == nil || !pk.IsPredicate() {
+			itr.Next()
+			continue
+		}
+		ps, has := byPred[pk.Attr]
+		if !has {
+			if !groups().ServesTablet(pk.Attr) {
+				if pk.IsSchema() {
+					itr.Next()
+				} else {
+					itr.Seek(pk.SkipPredicate())
+				}
+				continue
+			}
+			ps = &pb.PredicateStats{Predicate: pk.Attr, GroupId: gid}
+			byPred[pk.Attr] = ps
+		}
+		switch {
+		case pk.IsData():
+			ps.DataKeys++
+		case pk.IsIndex():
+			ps.IndexKeys++
+		case pk.IsReverse():
+			ps.ReverseKeys++
+		case pk.IsCount():
+			ps.CountKeys++
+		}
+		ps.Space += item.EstimatedSize()
+		itr.Next()
+	}
+}
+
+func (w *grpcWorker) PredicateStats(ctx context.Context,
+	req *pb.PredicateStatsRequest) (*pb.PredicateStatsList, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return &pb.PredicateStatsList{Stats: localPredicateStats(req.HitsOnly)}, nil
+}
+
+// groupPredicateStats returns the statistics of the tablets of group gid. One Alpha of the group
+// counts the keys, and the hits of all of them are added up. The Alphas that can't be reached
+// for their hits are skipped.
+func groupPredicateStats(ctx context.Context, gid uint32) ([]*pb.PredicateStats, error) {
+	var stats []*pb.PredicateStats
+	var counted string
+	if groups().ServesGroup(gid) {
+		stats, counted = localPredicateStats(false), Config.MyAddr
+	} else {
+		pl := groups().AnyServer(gid)
+		if pl == nil {
+			return nil, conn.ErrNoConnection
+		}
+		res, err := pb.NewWorkerClient(pl.Get()).PredicateStats(ctx, &pb.PredicateStatsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		stats, counted = res.Stats, pl.Addr
+	}
+
+	byPred := make(map[string]*pb.PredicateStats, len(stats))
+	for _, ps := range stats {
+		byPred[ps.Predicate] = ps
+	}
+	for _, m := range groups().members(gid) {
+		if m.Addr == counted {
+			continue
+		}
+		var others []*pb.PredicateStats
+		if m.Addr == Config.MyAddr {
+			others = localPredicateStats(true)
+		} else {
+			pl, err := conn.Get().Get(m.Addr)
+			if err != nil {
+				glog.Warningf("Skipping the hits of %s: %v", m.Addr, err)
+				continue
+			}
+			res, err := pb.NewWorkerClient(pl.Get()).PredicateStats(ctx,
+				&pb.PredicateStatsRequest{HitsOnly: true})
+			if err != nil {
+				glog.Warningf("Skipping the hits of %s: %v", m.Addr, err)
+				continue
+			}
+			others = res.Stats
+		}
+		for _, o := range others {
+			if ps, ok := byPred[o.Predicate]; ok {
+				ps.Hits += o.Hits
+			}
+		}
+	}
+	return stats, nil
+}
+
+// PredicateStatsOverNetwork returns the statistics of the tablets of every group, sorted by
+// predicate.
+func PredicateStatsOverNetwork(ctx context.Context) ([]*pb.PredicateStats, error) {
+	gids := groups().KnownGroups()
+	type result struct {
+		stats []*pb.PredicateStats
+		err   error
+	}
+	results := make(chan result, len(gids))
+	for _, gid := range gids {
+		go func(gid uint32) {
+			stats, err := groupPredicateStats(ctx, gid)
+			if err != nil {
+				err = x.Wrapf(err, "while getting the statistics of group %d", gid)
+			}
+			results <- result{stats, err}
+		}(gid)
+	}
+
+	var stats []*pb.PredicateStats
+	for range gids {
+		res := <-results
+		if res.err != nil {
+			return nil, res.err
+		}
+		stats = append(stats, res.stats...)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Predicate < stats[j].Predicate
+	})
+	return stats, nil
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredicateHits(t *testing.T) {
+	before := hits()
+	countHit("name2")
+	countHit("name2")
+	countHit("friend_not_served")
+
+	byPred := make(map[string]*pb.PredicateStats)
+	for _, ps := range localPredicateStats(true) {
+		byPred[ps.Predicate] = ps
+	}
+	require.Equal(t, before["name2"]+2, byPred["name2"].Hits)
+	require.Equal(t, uint32(1), byPred["name2"].GroupId)
+	// The hits of the tablets served by another group aren't sent.
+	require.NotContains(t, byPred, "friend_not_served")
+}
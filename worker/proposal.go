@@ -145,6 +145,11 @@ func (n *node) proposeAndWait(ctx context.Context, proposal *pb.Proposal) error
 		}
 	}
 
+	// Restores load the backups of the group, which can take a long time.
+	if proposal.Restore != nil {
+		noTimeout = true
+	}
+
 	// Let's keep the same key, so multiple retries of the same proposal would
 	// have this shared key. Thus, each server in the group can identify
 	// whether it has already done this work, and if so, skip it.
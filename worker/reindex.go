@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"context"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+)
+
+// rebuildMarkedIndexes rebuilds the indexes, reverse edges and count indexes of the predicates
+// marked with x.ReindexKey by a restore that skipped them, and deletes the marks. The indexes
+// of each predicate are rebuilt at the version of its mark. It's called as the Alpha starts,
+// before it serves any request, so the marks never reach a backup or a snapshot, and after
+// an online restore.
+func rebuildMarkedIndexes() error {
+	type mark struct {
+		key  []byte
+		attr string
+		ts   uint64
+	}
+	var marks []mark
+	err := pstore.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = x.ReindexPrefix()
+		opt.PrefetchValues = false
+		itr := txn.NewIterator(opt)
+		defer itr.Close()
+		for itr.Rewind(); itr.Valid(); itr.Next() {
+			item := itr.Item()
+			pk := x.Parse(item.Key())
+			if pk == nil {
+				continue
+			}
+			marks = append(marks, mark{key: item.KeyCopy(nil), attr: pk.Attr, ts: item.Version()})
+		}
+		return nil
+	})
+	if err != nil || len(marks) == 0 {
+		return err
+	}
+
+	glog.Infof("Rebuilding the indexes of %d restored predicates", len(marks))
+	writer := x.NewTxnWriter(pstore)
+	for _, m := range marks {
+		if m.attr != x.PredicateListAttr {
+			glog.Infof("Rebuilding the indexes of predicate %q at ts %d", m.attr, m.ts)
+			if err := posting.RebuildAllIndexes(context.Background(), m.attr, m.ts); err != nil {
+				return err
+			}
+		}
+		if err := writer.Delete(m.key, m.ts); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	// Drop the lists read while the indexes were rebuilt.
+	posting.EvictLRU()
+	glog.Infof("Rebuilt the indexes of %d restored predicates", len(marks))
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/dgraph-io/dgraph/codec"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuildMarkedIndexes(t *testing.T) {
+	require.NoError(t, schema.ParseBytes([]byte(`restored: string @index(exact) .`), 1))
+
+	// The data of a restore that skipped the index keys, and its mark.
+	enc := codec.Encoder{BlockSize: 10}
+	enc.Add(math.MaxUint64)
+	pl := &pb.PostingList{Pack: enc.Done(), Postings: []*pb.Posting{{Uid: math.MaxUint64,
+		ValType: pb.Posting_STRING, Value: []byte("alice"), PostingType: pb.Posting_VALUE}}}
+	val, err := pl.Marshal()
+	require.NoError(t, err)
+	w := x.NewTxnWriter(pstore)
+	require.NoError(t, w.SetAt(x.DataKey("restored", 1), val, posting.BitCompletePosting, 5))
+	require.NoError(t, w.SetAt(x.ReindexKey("restored"), nil, 0, 6))
+	require.NoError(t, w.Flush())
+
+	require.NoError(t, rebuildMarkedIndexes())
+	txn := pstore.NewTransactionAt(math.MaxUint64, false)
+	defer txn.Discard()
+	item, err := txn.Get(x.IndexKey("restored", "\x02alice"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), item.Version())
+	_, err = txn.Get(x.ReindexKey("restored"))
+	require.Equal(t, badger.ErrKeyNotFound, err)
+
+	// Once the marks are deleted, nothing is rebuilt.
+	require.NoError(t, rebuildMarkedIndexes())
+}
@@ -0,0 +1,33 @@
+// +build oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"github.com/dgraph-io/dgraph/x"
+	"github.com/golang/glog"
+)
+
+// replicate is a no-op, the backups of another cluster can't be replicated without enterprise
+// features.
+func (g *groupi) replicate() {
+	defer g.closer.Done() // CLOSER:1
+	if Config.ReplicateFrom != "" {
+		glog.Warningf("Replication ignored: %v", x.ErrNotSupported)
+	}
+}
@@ -0,0 +1,246 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package worker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/dgraph/ee/backup"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ReplicationStatus is how far this cluster replicated the backups at Source.
+type ReplicationStatus struct {
+	Source string `json:"source"`
+	// LastBackupTs is the read ts of the last backup at Source, taken at LastBackupTime.
+	LastBackupTs   uint64              `json:"last_backup_ts"`
+	LastBackupTime time.Time           `json:"last_backup_time"`
+	Groups         []*GroupReplication `json:"groups"`
+}
+
+// GroupReplication is how far a group replicated the backups.
+type GroupReplication struct {
+	Group uint32 `json:"group"`
+	// ReplicatedTs is the read ts of the last backup replicated into the group, zero if none.
+	ReplicatedTs uint64 `json:"replicated_ts"`
+	// LagSeconds is the time since that backup was taken. It's nil if that backup isn't in the
+	// current chain of backups anymore, or the group couldn't be reached.
+	LagSeconds *int64 `json:"lag_seconds,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// replicate keeps this cluster a warm standby of the cluster whose backups are written to
+// Config.ReplicateFrom, usually by its scheduled backups. Every Config.ReplicateInterval, the
+// leader of group 1 loads into each group the backups taken after the last one it replicated.
+// The data of a group that doesn't have the full backup the chain starts with is replaced.
+func (g *groupi) replicate() {
+	defer g.closer.Done() // CLOSER:1
+	if Config.ReplicateFrom == "" {
+		return
+	}
+	glog.Infof("Replicating the backups at %q every %s", Config.ReplicateFrom,
+		Config.ReplicateInterval)
+
+	ticker := time.NewTicker(Config.ReplicateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.closer.HasBeenClosed():
+			return
+		case <-ticker.C:
+		}
+		if g.groupId() != 1 || !g.Node.AmLeader() {
+			continue
+		}
+		if err := replicateBackups(context.Background()); err != nil {
+			x.ReplicationFailures.Add(1)
+			glog.Errorf("Replication of the backups at %q failed: %v", Config.ReplicateFrom, err)
+		}
+	}
+}
+
+// replicateBackups loads into each group the backups at Config.ReplicateFrom it doesn't have.
+func replicateBackups(ctx context.Context) error {
+	status, chain, err := replicationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	replicated := make(map[uint32]uint64)
+	for _, gr := range status.Groups {
+		if gr.Error != "" {
+			return x.Errorf("Unable to get the replicated ts of group %d: %s", gr.Group, gr.Error)
+		}
+		replicated[gr.Group] = gr.ReplicatedTs
+	}
+	plan := replicationPlan(chain, replicated)
+	if len(plan) == 0 {
+		setReplicationLag(status)
+		return nil
+	}
+
+	if _, err := checkTablets(ctx, chain); err != nil {
+		return err
+	}
+	last := chain[len(chain)-1]
+	commitTs, err := restoreCommitTs(ctx, last.ReadTs)
+	if err != nil {
+		return err
+	}
+	gids := make([]uint32, 0, len(plan))
+	for gid := range plan {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	for _, gid := range gids {
+		if plan[gid] == 0 {
+			glog.Infof("Replication: replacing the data of group %d with the backups up to ts %d",
+				gid, last.ReadTs)
+		} else {
+			glog.Infof("Replication: loading the backups from ts %d to %d into group %d",
+				plan[gid], last.ReadTs, gid)
+		}
+		err := restoreGroup(ctx, pb.RestoreRequest{
+			GroupId:   gid,
+			Location:  Config.ReplicateFrom,
+			RestoreTs: last.ReadTs,
+			CommitTs:  commitTs,
+			SinceTs:   plan[gid],
+			Replicate: true,
+		})
+		if err != nil {
+			return x.Wrapf(err, "while replicating into group %d", gid)
+		}
+	}
+
+	if t, err := last.Time(); err == nil {
+		x.ReplicationLag.Set(int64(time.Since(t).Seconds()))
+	}
+	return nil
+}
+
+// replicationPlan returns the ts after which the backups of chain must be loaded into each
+// group for it to catch up with the last one, given the ts of the last backup each group
+// replicated. It's zero for the groups without the full backup the chain starts with, whose
+// data must be replaced. The groups that caught up are left out.
+func replicationPlan(chain []*backup.Manifest, replicated map[uint32]uint64) map[uint32]uint64 {
+	first, last := chain[0].ReadTs, chain[len(chain)-1].ReadTs
+	plan := make(map[uint32]uint64)
+	for _, m := range chain {
+		for _, gid := range m.Groups {
+			switch ts := replicated[gid]; {
+			case ts >= last:
+			case ts < first:
+				plan[gid] = 0
+			default:
+				plan[gid] = ts
+			}
+		}
+	}
+	return plan
+}
+
+// ReplicationStatusOverNetwork returns how far each group replicated the backups at
+// Config.ReplicateFrom.
+func ReplicationStatusOverNetwork(ctx context.Context) (*ReplicationStatus, error) {
+	status, _, err := replicationStatus(ctx)
+	if err == nil {
+		setReplicationLag(status)
+	}
+	return status, err
+}
+
+// replicationStatus reads the chain of backups at Config.ReplicateFrom, and asks every group of
+// the backups for the ts of the last one it replicated.
+func replicationStatus(ctx context.Context) (*ReplicationStatus, []*backup.Manifest, error) {
+	if Config.ReplicateFrom == "" {
+		return nil, nil, x.Errorf("This cluster doesn't replicate another one," +
+			" see --replicate_from")
+	}
+	chain, err := backup.Chain(Config.ReplicateFrom, 0, &backup.Credentials{})
+	if err != nil {
+		return nil, nil, err
+	}
+	last := chain[len(chain)-1]
+	status := &ReplicationStatus{Source: Config.ReplicateFrom, LastBackupTs: last.ReadTs}
+	if status.LastBackupTime, err = last.Time(); err != nil {
+		return nil, nil, err
+	}
+
+	taken := make(map[uint64]time.Time)
+	for _, m := range chain {
+		if t, err := m.Time(); err == nil {
+			taken[m.ReadTs] = t
+		}
+	}
+	seen := make(map[uint32]bool)
+	for _, m := range chain {
+		for _, gid := range m.Groups {
+			if seen[gid] {
+				continue
+			}
+			seen[gid] = true
+			gr := &GroupReplication{Group: gid}
+			if gr.ReplicatedTs, err = groupReplicatedTs(ctx, gid); err != nil {
+				gr.Error = err.Error()
+			} else if t, ok := taken[gr.ReplicatedTs]; ok {
+				lag := int64(time.Since(t).Seconds())
+				gr.LagSeconds = &lag
+			}
+			status.Groups = append(status.Groups, gr)
+		}
+	}
+	sort.Slice(status.Groups, func(i, j int) bool {
+		return status.Groups[i].Group < status.Groups[j].Group
+	})
+	return status, chain, nil
+}
+
+// groupReplicatedTs asks the members of group gid, the leader first, for the ts of the last
+// backup replicated into the group.
+func groupReplicatedTs(ctx context.Context, gid uint32) (uint64, error) {
+	var members []*pb.Member
+	for _, m := range groups().members(gid) {
+		if m.Leader {
+			members = append([]*pb.Member{m}, members...)
+		} else {
+			members = append(members, m)
+		}
+	}
+	err := x.Errorf("No member of group %d is known", gid)
+	for _, m := range members {
+		mctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		var info *pb.HealthInfo
+		info, err = memberHealth(mctx, m)
+		cancel()
+		if err == nil {
+			return info.ReplicatedTs, nil
+		}
+	}
+	return 0, err
+}
+
+// setReplicationLag sets the replication lag metric to the lag of the group furthest behind.
+func setReplicationLag(status *ReplicationStatus) {
+	var lag int64
+	for _, gr := range status.Groups {
+		if gr.LagSeconds != nil && *gr.LagSeconds > lag {
+			lag = *gr.LagSeconds
+		}
+	}
+	x.ReplicationLag.Set(lag)
+}
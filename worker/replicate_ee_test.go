@@ -0,0 +1,38 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgraph/ee/backup"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicationPlan(t *testing.T) {
+	chain := []*backup.Manifest{
+		{ReadTs: 10, Groups: []uint32{1, 2}},
+		{Since: 10, ReadTs: 20, Groups: []uint32{1, 2}},
+		{Since: 20, ReadTs: 30, Groups: []uint32{1, 2, 3}},
+	}
+	plan := replicationPlan(chain, map[uint32]uint64{
+		// Group 1 caught up, group 2 has the first two backups, group 3 has none.
+		1: 30,
+		2: 20,
+	})
+	require.Equal(t, map[uint32]uint64{2: 20, 3: 0}, plan)
+
+	// A group replicated from an older chain has its data replaced.
+	plan = replicationPlan(chain[1:], map[uint32]uint64{1: 30, 2: 5, 3: 30})
+	require.Equal(t, map[uint32]uint64{2: 0}, plan)
+}
@@ -0,0 +1,221 @@
+// +build !oss
+
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Dgraph Community License (the "License"); you
+ * may not use this file except in compliance with the License. You
+ * may obtain a copy of the License at
+ *
+ *     https://github.com/dgraph-io/dgraph/blob/master/licenses/DCL.txt
+ */
+
+package worker
+
+import (
+	"github.com/dgraph-io/dgraph/ee/backup"
+	"github.com/dgraph-io/dgraph/posting"
+	"github.com/dgraph-io/dgraph/protos/pb"
+	"github.com/dgraph-io/dgraph/schema"
+	"github.com/dgraph-io/dgraph/x"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// applyRestore replaces the data of this node with the backups of its group, or with
+// req.SinceTs set, loads the backups taken after it on top of the data. It's called by every
+// replica of the group when the restore proposal is applied.
+func applyRestore(req *pb.RestoreRequest) error {
+	if groups().groupId() != req.GroupId {
+		return x.Errorf("Restore request group mismatch. Mine: %d. Requested: %d\n",
+			groups().groupId(), req.GroupId)
+	}
+	// Ensures nothing get written to disk due to commit proposals.
+	posting.Oracle().ResetTxns()
+	changes.reset()
+	if req.SinceTs > 0 {
+		glog.Infof("Restore: loading the backups of group %d at %q taken after ts %d",
+			req.GroupId, req.Location, req.SinceTs)
+	} else {
+		glog.Infof("Restore: replacing the data of group %d with the backups at %q",
+			req.GroupId, req.Location)
+		schema.State().DeleteAll()
+		if err := posting.DeleteAll(); err != nil {
+			return err
+		}
+	}
+	if err := backup.RestoreGroup(pstore, req, Config.BackupKey, backupLimiter()); err != nil {
+		glog.Errorf("Restore of group %d failed: %s", req.GroupId, err)
+		return err
+	}
+	// Drop the lists read while the data was being replaced, and load the restored schema.
+	posting.EvictLRU()
+	if err := schema.LoadFromDb(); err != nil {
+		return err
+	}
+	if err := rebuildMarkedIndexes(); err != nil {
+		return err
+	}
+	glog.Infof("Restore of group %d at commit ts %d. OK.", req.GroupId, req.CommitTs)
+	return nil
+}
+
+func proposeRestore(ctx context.Context, req *pb.RestoreRequest) error {
+	if groups().groupId() != req.GroupId {
+		return x.Errorf("Restore request group mismatch. Mine: %d. Requested: %d\n",
+			groups().groupId(), req.GroupId)
+	}
+	return groups().Node.proposeAndWait(ctx, &pb.Proposal{Restore: req})
+}
+
+// Restore handles a request coming from another node.
+func (w *grpcWorker) Restore(ctx context.Context, req *pb.RestoreRequest) (*pb.Status, error) {
+	glog.V(2).Infof("Received restore request via Grpc: %+v", req)
+	if err := proposeRestore(ctx, req); err != nil {
+		return nil, err
+	}
+	return &pb.Status{Msg: "SUCCESS"}, nil
+}
+
+func restoreGroup(ctx context.Context, req pb.RestoreRequest) error {
+	glog.V(2).Infof("Sending restore request: %+v\n", req)
+	// this node is part of the group, propose the restore.
+	if groups().groupId() == req.GroupId {
+		return proposeRestore(ctx, &req)
+	}
+
+	// send request to any node in the group.
+	pl := groups().AnyServer(req.GroupId)
+	if pl == nil {
+		return x.Errorf("Couldn't find a server in group %d", req.GroupId)
+	}
+	if _, err := pb.NewWorkerClient(pl.Get()).Restore(ctx, &req); err != nil {
+		glog.Errorf("Restore error group %d: %s", req.GroupId, err)
+		return err
+	}
+	glog.V(2).Infof("Restore request to gid=%d. OK\n", req.GroupId)
+	return nil
+}
+
+// RestoreOverNetwork handles a request coming from an HTTP client. It replaces the data of
+// the groups in the backups at location with the backed up data, as of the latest backup
+// taken at or before restoreTs if it's set. Every group of the backups must be in the
+// cluster, and the predicates must either be served by the group that backed them up or not
+// be served yet. Groups that aren't in the backups are left as they are.
+func RestoreOverNetwork(pctx context.Context, location string, restoreTs uint64) error {
+	ctx, cancel := context.WithCancel(pctx)
+	defer cancel()
+
+	// Check that this node can accept requests.
+	if err := x.HealthCheck(); err != nil {
+		glog.Errorf("Restore canceled, not ready to accept requests: %s", err)
+		return err
+	}
+
+	chain, err := backup.Chain(location, restoreTs, &backup.Credentials{})
+	if err != nil {
+		glog.Errorf("Unable to read the backups at %q: %s", location, err)
+		return err
+	}
+	gids, err := checkTablets(ctx, chain)
+	if err != nil {
+		return err
+	}
+	commitTs, err := restoreCommitTs(ctx, chain[len(chain)-1].ReadTs)
+	if err != nil {
+		glog.Errorf("Unable to retrieve a timestamp for restore: %s", err)
+		return err
+	}
+
+	req := pb.RestoreRequest{
+		Location:  location,
+		RestoreTs: restoreTs,
+		CommitTs:  commitTs,
+	}
+	glog.Infof("Created restore request: %+v. Groups=%v\n", req, gids)
+
+	errCh := make(chan error, len(gids))
+	for _, gid := range gids {
+		req.GroupId = gid
+		go func(req pb.RestoreRequest) {
+			errCh <- restoreGroup(ctx, req)
+		}(req)
+	}
+	for range gids {
+		if err := <-errCh; err != nil {
+			glog.Errorf("Error received during restore: %v", err)
+			return err
+		}
+	}
+	glog.Infof("Restore for req: %+v. OK.\n", req)
+	return nil
+}
+
+// checkTablets checks that the groups of the backups of chain are in the cluster, and that
+// each predicate backed up is served by the group that backed it up, assigning the ones that
+// aren't served yet. It returns the groups of the backups.
+func checkTablets(ctx context.Context, chain []*backup.Manifest) ([]uint32, error) {
+	var gids []uint32
+	seen := make(map[uint32]bool)
+	tablets := make(map[string]uint32)
+	for _, m := range chain {
+		for _, gid := range m.Groups {
+			if !seen[gid] {
+				seen[gid] = true
+				gids = append(gids, gid)
+			}
+		}
+		if len(m.Groups) > 0 && len(m.Predicates) == 0 {
+			return nil, x.Errorf("Backup taken at ts %d doesn't record its predicates, "+
+				"it must be restored offline with dgraph restore", m.ReadTs)
+		}
+		for gid, preds := range m.Predicates {
+			for _, pred := range preds {
+				tablets[pred] = gid
+			}
+		}
+	}
+
+	known := make(map[uint32]bool)
+	for _, gid := range groups().KnownGroups() {
+		known[gid] = true
+	}
+	for _, gid := range gids {
+		if !known[gid] {
+			return nil, x.Errorf("Group %d of the backups is not in the cluster", gid)
+		}
+	}
+
+	// The data of each predicate is restored into the group that backed it up, so that group
+	// must serve it. Zero assigns the predicates that aren't served yet.
+	zc := pb.NewZeroClient(groups().connToZeroLeader().Get())
+	for pred, gid := range tablets {
+		tablet, err := zc.ShouldServe(ctx, &pb.Tablet{GroupId: gid, Predicate: pred})
+		if err != nil {
+			return nil, err
+		}
+		if tablet.GroupId != gid {
+			return nil, x.Errorf("Predicate %q is served by group %d, but it was backed up by "+
+				"group %d", pred, tablet.GroupId, gid)
+		}
+	}
+	return gids, nil
+}
+
+// restoreCommitTs moves the Zero timestamps past readTs, the ts of the last backup restored,
+// and returns the ts to write the restored data at.
+func restoreCommitTs(ctx context.Context, readTs uint64) (uint64, error) {
+	ts, err := Timestamps(ctx, &pb.Num{Val: 1})
+	if err != nil {
+		return 0, err
+	}
+	commitTs := ts.StartId
+	if commitTs <= readTs {
+		if ts, err = Timestamps(ctx, &pb.Num{Val: readTs - commitTs + 1}); err != nil {
+			return 0, err
+		}
+		commitTs = ts.EndId
+	}
+	return commitTs, nil
+}
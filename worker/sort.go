@@ -290,21 +290,64 @@ func multiSort(ctx context.Context, r *sortresult, ts *pb.SortMessage) error {
 		}
 	}
 
-	// Execute rest of the sorts concurrently.
-	och := make(chan orderResult, len(ts.Order)-1)
-	for i := 1; i < len(ts.Order); i++ {
+	// Fetch the values of the rest of the attributes.
+	rest, err := FetchSortValues(ctx, ts.Order[1:], dest, ts.ReadTs)
+	if err != nil {
+		return err
+	}
+	for i := range dest.Uids {
+		copy(sortVals[i][1:], rest[i])
+	}
+
+	desc := make([]bool, 0, len(ts.Order))
+	for _, o := range ts.Order {
+		desc = append(desc, o.Desc)
+	}
+
+	// Values have been accumulated, now we do the multisort for each list.
+	for i, ul := range r.reply.UidMatrix {
+		vals := make([][]types.Val, len(ul.Uids))
+		for j, uid := range ul.Uids {
+			idx := algo.IndexOf(dest, uid)
+			x.AssertTrue(idx >= 0)
+			vals[j] = sortVals[idx]
+		}
+		if err := types.Sort(vals, ul, desc); err != nil {
+			return err
+		}
+		// Paginate
+		start, end := x.PageRange(int(ts.Count), int(ts.Offset), len(ul.Uids))
+		ul.Uids = ul.Uids[start:end]
+		r.reply.UidMatrix[i] = ul
+	}
+
+	return nil
+}
+
+// FetchSortValues returns the values of the attributes of order for each uid of uids, fetched
+// concurrently. A uid without a value for an attribute gets a nil value, which is sorted
+// after all the other values.
+func FetchSortValues(ctx context.Context, order []*pb.Order, uids *pb.List,
+	readTs uint64) ([][]types.Val, error) {
+	vals := make([][]types.Val, len(uids.Uids))
+	for i := range vals {
+		vals[i] = make([]types.Val, len(order))
+	}
+
+	och := make(chan orderResult, len(order))
+	for i, o := range order {
 		in := &pb.Query{
-			Attr:    ts.Order[i].Attr,
-			UidList: dest,
-			Langs:   ts.Order[i].Langs,
-			ReadTs:  ts.ReadTs,
+			Attr:    o.Attr,
+			UidList: uids,
+			Langs:   o.Langs,
+			ReadTs:  readTs,
 		}
 		go fetchValues(ctx, in, i, och)
 	}
 
 	var oerr error
 	// TODO - Verify behavior with multiple langs.
-	for i := 1; i < len(ts.Order); i++ {
+	for range order {
 		or := <-och
 		if or.err != nil {
 			if oerr == nil {
@@ -314,8 +357,8 @@ func multiSort(ctx context.Context, r *sortresult, ts *pb.SortMessage) error {
 		}
 
 		result := or.r
-		x.AssertTrue(len(result.ValueMatrix) == len(dest.Uids))
-		for i, _ := range dest.Uids {
+		x.AssertTrue(len(result.ValueMatrix) == len(uids.Uids))
+		for i := range uids.Uids {
 			var sv types.Val
 			if len(result.ValueMatrix[i].Values) == 0 {
 				// Assign nil value which is sorted as greater than all other values.
@@ -327,41 +370,13 @@ func multiSort(ctx context.Context, r *sortresult, ts *pb.SortMessage) error {
 				var err error
 				sv, err = types.Convert(val, val.Tid)
 				if err != nil {
-					return err
+					return nil, err
 				}
 			}
-			sortVals[i][or.idx] = sv
-		}
-	}
-
-	if oerr != nil {
-		return oerr
-	}
-
-	desc := make([]bool, 0, len(ts.Order))
-	for _, o := range ts.Order {
-		desc = append(desc, o.Desc)
-	}
-
-	// Values have been accumulated, now we do the multisort for each list.
-	for i, ul := range r.reply.UidMatrix {
-		vals := make([][]types.Val, len(ul.Uids))
-		for j, uid := range ul.Uids {
-			idx := algo.IndexOf(dest, uid)
-			x.AssertTrue(idx >= 0)
-			vals[j] = sortVals[idx]
-		}
-		if err := types.Sort(vals, ul, desc); err != nil {
-			return err
+			vals[i][or.idx] = sv
 		}
-		// Paginate
-		if len(ul.Uids) > int(ts.Count) {
-			ul.Uids = ul.Uids[:ts.Count]
-		}
-		r.reply.UidMatrix[i] = ul
 	}
-
-	return nil
+	return vals, oerr
 }
 
 // processSort does sorting with pagination. It works by iterating over index
@@ -387,6 +402,17 @@ func processSort(ctx context.Context, ts *pb.SortMessage) (*pb.SortResult, error
 		return nil, x.Errorf("Sorting not supported on attr: %s of type: [scalar]", ts.Order[0].Attr)
 	}
 
+	// With more than one order, the uids tied on the first one are only ordered by multiSort,
+	// so the offset can't be applied before it: the first sort returns the uids up to the end
+	// of the page, and multiSort skips the offset.
+	first := ts
+	if len(ts.Order) > 1 && ts.Offset > 0 {
+		fts := *ts
+		fts.Count += fts.Offset
+		fts.Offset = 0
+		first = &fts
+	}
+
 	cctx, cancel := context.WithCancel(ctx)
 	resCh := make(chan *sortresult, 2)
 	go func() {
@@ -397,12 +423,12 @@ func processSort(ctx context.Context, ts *pb.SortMessage) (*pb.SortResult, error
 			resCh <- &sortresult{err: ctx.Err()}
 			return
 		}
-		r := sortWithoutIndex(cctx, ts)
+		r := sortWithoutIndex(cctx, first)
 		resCh <- r
 	}()
 
 	go func() {
-		sr := sortWithIndex(cctx, ts)
+		sr := sortWithIndex(cctx, first)
 		resCh <- sr
 	}()
 
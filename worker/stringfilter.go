@@ -29,6 +29,7 @@ import (
 type matchFn func(types.Val, stringFilter) bool
 
 type stringFilter struct {
+	attr      string
 	funcName  string
 	funcType  FuncType
 	lang      string
@@ -93,18 +94,17 @@ func ineqMatch(value types.Val, filter stringFilter) bool {
 }
 
 func tokenizeValue(value types.Val, filter stringFilter) []string {
-	var tokName string
+	var tokenizer tok.Tokenizer
 	switch filter.funcType {
 	case StandardFn:
-		tokName = "term"
+		var found bool
+		tokenizer, found = tok.GetTokenizer("term")
+		// tokenizer was used in previous stages of query proccessing, it has to be available
+		x.AssertTrue(found)
 	case FullTextSearchFn:
-		tokName = "fulltext"
+		tokenizer = fullTextTokenizer(filter.attr)
 	}
 
-	tokenizer, found := tok.GetTokenizer(tokName)
-	// tokenizer was used in previous stages of query proccessing, it has to be available
-	x.AssertTrue(found)
-
 	tokens, err := tok.BuildTokens(value.Value, tok.GetLangTokenizer(tokenizer, filter.lang))
 	if err != nil {
 		glog.Errorf("Error while building tokens: %s", err)
@@ -220,6 +220,7 @@ const (
 	HasFn
 	UidInFn
 	CustomIndexFn
+	GeoDistanceFn
 	StandardFn = 100
 )
 
@@ -250,6 +251,8 @@ func parseFuncTypeHelper(name string) (FuncType, string) {
 		return AggregatorFn, f
 	case "checkpwd":
 		return PasswordFn, f
+	case "distance":
+		return GeoDistanceFn, f
 	case "regexp":
 		return RegexFn, f
 	case "alloftext", "anyoftext":
@@ -287,7 +290,7 @@ type funcArgs struct {
 // The function tells us whether we want to fetch value posting lists or uid posting lists.
 func (srcFn *functionContext) needsValuePostings(typ types.TypeID) (bool, error) {
 	switch srcFn.fnType {
-	case AggregatorFn, PasswordFn:
+	case AggregatorFn, PasswordFn, GeoDistanceFn:
 		return true, nil
 	case CompareAttrFn:
 		if len(srcFn.tokens) > 0 {
@@ -319,7 +322,7 @@ func handleValuePostings(ctx context.Context, args funcArgs) error {
 	}
 
 	switch srcFn.fnType {
-	case NotAFunction, AggregatorFn, PasswordFn, CompareAttrFn:
+	case NotAFunction, AggregatorFn, PasswordFn, CompareAttrFn, GeoDistanceFn:
 	default:
 		return x.Errorf("Unhandled function in handleValuePostings: %s", srcFn.fname)
 	}
@@ -332,6 +335,10 @@ func handleValuePostings(ctx context.Context, args funcArgs) error {
 		return x.Errorf("checkpwd fn can only be used on attr: [%s] with schema type password."+
 			" Got type: %s", q.Attr, types.TypeID(srcFn.atype).Name())
 	}
+	if srcFn.fnType == GeoDistanceFn && srcFn.atype != types.GeoID {
+		return x.Errorf("distance fn can only be used on attr: [%s] with schema type geo."+
+			" Got type: %s", q.Attr, types.TypeID(srcFn.atype).Name())
+	}
 	if srcFn.n == 0 {
 		return nil
 	}
@@ -475,6 +482,21 @@ func handleValuePostings(ctx context.Context, args funcArgs) error {
 				}
 				// Add an empty UID list to make later processing consistent
 				out.UidMatrix = append(out.UidMatrix, &emptyUIDList)
+			case srcFn.fnType == GeoDistanceFn:
+				lastPos := len(out.ValueMatrix) - 1
+				// For a list of geometries, the distance to the closest one.
+				closest, found := 0.0, false
+				for _, v := range out.ValueMatrix[lastPos].Values {
+					if d, ok := types.GeoDistance(v, srcFn.geoQuery); ok && (!found || d < closest) {
+						closest, found = d, true
+					}
+				}
+				out.ValueMatrix[lastPos] = &pb.ValueList{}
+				if found {
+					out.ValueMatrix[lastPos].Values = []*pb.TaskValue{ctask.FromFloat(closest)}
+				}
+				// Add an empty UID list to make later processing consistent
+				out.UidMatrix = append(out.UidMatrix, &emptyUIDList)
 			default:
 				out.UidMatrix = append(out.UidMatrix, uidList)
 			}
@@ -717,6 +739,7 @@ func processTask(ctx context.Context, q *pb.Query, gid uint32) (*pb.Result, erro
 	if !groups().ServesTablet(q.Attr) {
 		return &emptyResult, errUnservedTablet
 	}
+	countHit(q.Attr)
 	out, err := helpProcessTask(ctx, q, gid)
 	if err != nil {
 		return &emptyResult, err
@@ -876,19 +899,33 @@ func handleRegexFunction(ctx context.Context, arg funcArgs) error {
 		return x.Errorf("Got non-string type. Regex match is allowed only on string type.")
 	}
 	tokenizers := schema.State().TokenizerNames(attr)
-	var found bool
+	var trigram, trigramFold bool
 	for _, t := range tokenizers {
-		if t == "trigram" { // TODO(tzdybal) - maybe just rename to 'regex' tokenizer?
-			found = true
+		switch t {
+		case tok.TrigramTokenizer{}.Name(): // TODO(tzdybal) - maybe just rename to 'regex' tokenizer?
+			trigram = true
+		case tok.TrigramFoldTokenizer{}.Name():
+			trigramFold = true
 		}
 	}
-	if !found {
+	if !trigram && !trigramFold {
 		return x.Errorf("Attribute %v does not have trigram index for regex matching.", attr)
 	}
 
-	query := cindex.RegexpQuery(arg.srcFn.regex.Syntax)
+	var query *cindex.Query
+	var encode func([]string)
+	// The case-folded index is used for case-insensitive expressions, as the variants of
+	// their trigrams in all cases are often too many for the trigram index. It also works
+	// for case-sensitive ones, less selectively.
+	if trigramFold && (!trigram || foldsCase(arg.srcFn.regex.Syntax)) {
+		query = cindex.RegexpQuery(foldRegexp(arg.srcFn.regex.Syntax))
+		encode = tok.EncodeFoldedRegexTokens
+	} else {
+		query = cindex.RegexpQuery(arg.srcFn.regex.Syntax)
+		encode = tok.EncodeRegexTokens
+	}
 	empty := pb.List{}
-	uids, err := uidsForRegex(attr, arg, query, &empty)
+	uids, err := uidsForRegex(attr, arg, query, &empty, encode)
 	isList := schema.State().IsList(attr)
 	lang := langForFunc(arg.q.Langs)
 	if uids != nil {
@@ -1177,6 +1214,7 @@ func filterStringFunction(arg funcArgs) error {
 
 	filtered := &pb.List{Uids: filteredUids}
 	filter := stringFilter{
+		attr:     arg.q.Attr,
 		funcName: arg.srcFn.fname,
 		funcType: arg.srcFn.fnType,
 		lang:     lang,
@@ -1347,6 +1385,11 @@ func parseSrcFn(q *pb.Query) (*functionContext, error) {
 			return nil, err
 		}
 		fc.n = len(q.UidList.Uids)
+	case GeoDistanceFn:
+		if fc.geoQuery, err = types.GetGeoDistanceQuery(q.SrcFunc); err != nil {
+			return nil, err
+		}
+		fc.n = len(q.UidList.Uids)
 	case StandardFn, FullTextSearchFn:
 		// srcfunc 0th val is func name and and [2:] are args.
 		// we tokenize the arguments of the query.
@@ -1357,7 +1400,8 @@ func parseSrcFn(q *pb.Query) (*functionContext, error) {
 		if !found {
 			return nil, x.Errorf("Attribute %s is not indexed with type %s", attr, required)
 		}
-		if fc.tokens, err = getStringTokens(q.SrcFunc.Args, langForFunc(q.Langs), fnType); err != nil {
+		fc.tokens, err = getStringTokens(attr, q.SrcFunc.Args, langForFunc(q.Langs), fnType)
+		if err != nil {
 			return nil, err
 		}
 		fnName := strings.ToLower(q.SrcFunc.Name)
@@ -1603,6 +1647,20 @@ func preprocessFilter(tree *pb.FilterTree) (*facetsTree, error) {
 	if tree == nil {
 		return nil, nil
 	}
+	if tree.Func != nil && strings.ToLower(tree.Func.Name) == "between" {
+		// between(key, low, high) is ge(key, low) AND le(key, high).
+		if len(tree.Func.Args) != 2 {
+			return nil, x.Errorf("Two arguments expected in between, but got %d.",
+				len(tree.Func.Args))
+		}
+		return preprocessFilter(&pb.FilterTree{
+			Op: "and",
+			Children: []*pb.FilterTree{
+				{Func: &pb.Function{Name: "ge", Key: tree.Func.Key, Args: tree.Func.Args[:1]}},
+				{Func: &pb.Function{Name: "le", Key: tree.Func.Key, Args: tree.Func.Args[1:]}},
+			},
+		})
+	}
 	ftree := &facetsTree{}
 	ftree.op = tree.Op
 	if tree.Func != nil {
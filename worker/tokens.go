@@ -67,18 +67,30 @@ func verifyCustomIndex(attr string, tokenizerName string) bool {
 
 // Return string tokens from function arguments. It maps function type to correct tokenizer.
 // Note: regexp functions require regexp compilation of argument, not tokenization.
-func getStringTokens(funcArgs []string, lang string, funcType FuncType) ([]string, error) {
+func getStringTokens(attr string, funcArgs []string, lang string,
+	funcType FuncType) ([]string, error) {
 	if lang == "." {
 		lang = "en"
 	}
 	switch funcType {
 	case FullTextSearchFn:
-		return tok.GetFullTextTokens(funcArgs, lang)
+		return tok.GetFullTextTokensWith(funcArgs, fullTextTokenizer(attr), lang)
 	default:
 		return tok.GetTermTokens(funcArgs)
 	}
 }
 
+// fullTextTokenizer returns the fulltext tokenizer of attr, with the language, stop word and
+// stemming options of its index.
+func fullTextTokenizer(attr string) tok.Tokenizer {
+	for _, t := range schema.State().Tokenizer(attr) {
+		if _, ok := t.(tok.FullTextTokenizer); ok {
+			return t
+		}
+	}
+	return tok.FullTextTokenizer{}
+}
+
 func pickTokenizer(attr string, f string) (tok.Tokenizer, error) {
 	// Get the tokenizers and choose the corresponding one.
 	if !schema.State().IsIndexed(attr) {
@@ -118,6 +130,30 @@ func pickTokenizer(attr string, f string) (tok.Tokenizer, error) {
 	return tokenizers[0], nil
 }
 
+// EqIndexKeys returns the index keys looked up by eq(attr, args...), or none if attr isn't
+// indexed.
+func EqIndexKeys(attr string, args []string) [][]byte {
+	if !schema.State().IsIndexed(attr) {
+		return nil
+	}
+	var keys [][]byte
+	for _, arg := range args {
+		val, err := convertValue(attr, arg)
+		if err != nil {
+			continue
+		}
+		// eq doesn't read the index at readTs to get its tokens.
+		tokens, _, err := getInequalityTokens(0, attr, "eq", val)
+		if err != nil {
+			continue
+		}
+		for _, token := range tokens {
+			keys = append(keys, x.IndexKey(attr, token))
+		}
+	}
+	return keys
+}
+
 // getInequalityTokens gets tokens ge / le compared to given token using the first sortable
 // index that is found for the predicate.
 func getInequalityTokens(readTs uint64, attr, f string,
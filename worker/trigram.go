@@ -18,20 +18,23 @@ package worker
 
 import (
 	"errors"
+	"regexp/syntax"
+	"unicode"
 
 	cindex "github.com/google/codesearch/index"
 
 	"github.com/dgraph-io/dgraph/algo"
 	"github.com/dgraph-io/dgraph/posting"
 	"github.com/dgraph-io/dgraph/protos/pb"
-	"github.com/dgraph-io/dgraph/tok"
 	"github.com/dgraph-io/dgraph/x"
 )
 
 var regexTooWideErr = errors.New("Regular expression is too wide-ranging and can't be executed efficiently.")
 
-func uidsForRegex(attr string, arg funcArgs,
-	query *cindex.Query, intersect *pb.List) (*pb.List, error) {
+// uidsForRegex returns the uids matching the trigram query, with encode turning the trigrams
+// into the tokens of the index used.
+func uidsForRegex(attr string, arg funcArgs, query *cindex.Query, intersect *pb.List,
+	encode func([]string)) (*pb.List, error) {
 	var results *pb.List
 	opts := posting.ListOptions{
 		ReadTs: arg.q.ReadTs,
@@ -51,7 +54,7 @@ func uidsForRegex(attr string, arg funcArgs,
 
 	switch query.Op {
 	case cindex.QAnd:
-		tok.EncodeRegexTokens(query.Trigram)
+		encode(query.Trigram)
 		for _, t := range query.Trigram {
 			trigramUids, err := uidsForTrigram(t)
 			if err != nil {
@@ -73,7 +76,7 @@ func uidsForRegex(attr string, arg funcArgs,
 			}
 			// current list of result is passed for intersection
 			var err error
-			results, err = uidsForRegex(attr, arg, sub, results)
+			results, err = uidsForRegex(attr, arg, sub, results, encode)
 			if err != nil {
 				return nil, err
 			}
@@ -82,7 +85,7 @@ func uidsForRegex(attr string, arg funcArgs,
 			}
 		}
 	case cindex.QOr:
-		tok.EncodeRegexTokens(query.Trigram)
+		encode(query.Trigram)
 		uidMatrix := make([]*pb.List, len(query.Trigram))
 		var err error
 		for i, t := range query.Trigram {
@@ -96,7 +99,7 @@ func uidsForRegex(attr string, arg funcArgs,
 			if results == nil {
 				results = intersect
 			}
-			subUids, err := uidsForRegex(attr, arg, sub, intersect)
+			subUids, err := uidsForRegex(attr, arg, sub, intersect, encode)
 			if err != nil {
 				return nil, err
 			}
@@ -107,3 +110,52 @@ func uidsForRegex(attr string, arg funcArgs,
 	}
 	return results, nil
 }
+
+// foldsCase returns whether some of re matches case-insensitively.
+func foldsCase(re *syntax.Regexp) bool {
+	if re.Flags&syntax.FoldCase != 0 {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if foldsCase(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxFoldedClass is the size of a character class above which foldRegexp matches any
+// character instead.
+const maxFoldedClass = 100
+
+// foldRegexp returns a regular expression matching the values folded to lower case, at least
+// when re matches the values. Its trigrams are those of the trigram_ci index.
+func foldRegexp(re *syntax.Regexp) *syntax.Regexp {
+	folded := *re
+	folded.Flags &^= syntax.FoldCase
+	switch re.Op {
+	case syntax.OpLiteral:
+		folded.Rune = make([]rune, len(re.Rune))
+		for i, r := range re.Rune {
+			folded.Rune[i] = unicode.ToLower(r)
+		}
+	case syntax.OpCharClass:
+		folded.Rune = nil
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if hi-lo > maxFoldedClass {
+				return &syntax.Regexp{Op: syntax.OpAnyChar}
+			}
+			for r := lo; r <= hi; r++ {
+				l := unicode.ToLower(r)
+				folded.Rune = append(folded.Rune, l, l)
+			}
+		}
+	default:
+		folded.Sub = make([]*syntax.Regexp, len(re.Sub))
+		for i, sub := range re.Sub {
+			folded.Sub[i] = foldRegexp(sub)
+		}
+	}
+	return &folded
+}
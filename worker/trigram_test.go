@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package worker
+
+import (
+	"regexp/syntax"
+	"testing"
+
+	cindex "github.com/google/codesearch/index"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoldRegexp(t *testing.T) {
+	re, err := syntax.Parse("Dgraph", syntax.Perl)
+	require.NoError(t, err)
+	require.False(t, foldsCase(re))
+
+	re, err = syntax.Parse("(?i)DGraph|[A-B]ad", syntax.Perl)
+	require.NoError(t, err)
+	require.True(t, foldsCase(re))
+
+	// The variants of the trigrams in all cases are not needed for the folded expression.
+	q := cindex.RegexpQuery(foldRegexp(re))
+	require.Equal(t, cindex.QOr, q.Op)
+	require.Equal(t, []string{"aad", "bad"}, q.Trigram)
+	require.Len(t, q.Sub, 1)
+	require.Equal(t, cindex.QAnd, q.Sub[0].Op)
+	require.Equal(t, []string{"aph", "dgr", "gra", "rap"}, q.Sub[0].Trigram)
+
+	// The expression itself is left as it is.
+	require.True(t, foldsCase(re))
+}
@@ -36,6 +36,7 @@ import (
 
 var (
 	pstore           *badger.DB
+	walStore         *badger.DB
 	workerServer     *grpc.Server
 	raftServer       conn.RaftServer
 	pendingProposals chan struct{}
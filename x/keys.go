@@ -34,6 +34,11 @@ const (
 	// keys of same attributes are located together
 	defaultPrefix = byte(0x00)
 	byteSchema    = byte(0x01)
+	byteReindex   = byte(0x02)
+	byteReplicate = byte(0x03)
+	byteSnapshot  = byte(0x04)
+	byteDrop      = byte(0x05)
+	byteBackups   = byte(0x06)
 )
 
 func writeAttr(buf []byte, attr string) []byte {
@@ -58,6 +63,55 @@ func SchemaKey(attr string) []byte {
 	return buf
 }
 
+// ReindexKey returns the key marking attr to have its indexes, reverse edges and count indexes
+// rebuilt when the Alpha starts. It's written by restores that skip those keys.
+func ReindexKey(attr string) []byte {
+	buf := make([]byte, 1+2+len(attr))
+	buf[0] = byteReindex
+	rest := buf[1:]
+
+	writeAttr(rest, attr)
+	return buf
+}
+
+// ReplicatedKey returns the key storing the read ts of the last backup of another cluster
+// replicated into the group, as a big-endian uint64. It has no attribute.
+func ReplicatedKey() []byte {
+	buf := make([]byte, 1+2)
+	buf[0] = byteReplicate
+	writeAttr(buf[1:], "")
+	return buf
+}
+
+// SnapshotKey returns the key storing how far a follower got in receiving a snapshot from the
+// leader, as a marshaled pb.Snapshot with the last key received. It has no attribute, and it's
+// deleted once the snapshot is received.
+func SnapshotKey() []byte {
+	buf := make([]byte, 1+2)
+	buf[0] = byteSnapshot
+	writeAttr(buf[1:], "")
+	return buf
+}
+
+// DropKey returns the key recording at its version that attr was dropped, or all the data if
+// attr is empty. Incremental backups carry it, for restores to delete the data dropped after
+// the previous backup. It has no value, and it's only written once BackupsKey is set.
+func DropKey(attr string) []byte {
+	buf := make([]byte, 1+2+len(attr))
+	buf[0] = byteDrop
+	writeAttr(buf[1:], attr)
+	return buf
+}
+
+// BackupsKey returns the key marking that the group takes backups, so that it records its drops
+// for the incremental ones, see DropKey. It has no attribute and no value.
+func BackupsKey() []byte {
+	buf := make([]byte, 1+2)
+	buf[0] = byteBackups
+	writeAttr(buf[1:], "")
+	return buf
+}
+
 func DataKey(attr string, uid uint64) []byte {
 	buf := make([]byte, 2+len(attr)+2+8)
 	buf[0] = defaultPrefix
@@ -144,6 +198,33 @@ func (p ParsedKey) IsSchema() bool {
 	return p.bytePrefix == byteSchema
 }
 
+func (p ParsedKey) IsReindex() bool {
+	return p.bytePrefix == byteReindex
+}
+
+func (p ParsedKey) IsReplicated() bool {
+	return p.bytePrefix == byteReplicate
+}
+
+func (p ParsedKey) IsSnapshot() bool {
+	return p.bytePrefix == byteSnapshot
+}
+
+func (p ParsedKey) IsDrop() bool {
+	return p.bytePrefix == byteDrop
+}
+
+func (p ParsedKey) IsBackups() bool {
+	return p.bytePrefix == byteBackups
+}
+
+// IsPredicate returns whether the key holds the data, indexes or schema of a predicate, unlike
+// the keys a server keeps about a predicate or itself, like the reindex, drop, replicated,
+// snapshot and backups keys.
+func (p ParsedKey) IsPredicate() bool {
+	return p.Attr != "" && (p.bytePrefix == defaultPrefix || p.bytePrefix == byteSchema)
+}
+
 func (p ParsedKey) IsType(typ byte) bool {
 	switch typ {
 	case ByteCount, ByteCountRev:
@@ -179,9 +260,11 @@ func (p ParsedKey) SkipRangeOfSameType() []byte {
 	return buf
 }
 
+// SkipSchema returns the key to seek to past the schema keys, and past the reindex, replicated,
+// snapshot, drop and backups keys sorted after them, which aren't keys of predicates either.
 func (p ParsedKey) SkipSchema() []byte {
 	var buf [1]byte
-	buf[0] = byteSchema + 1
+	buf[0] = byteBackups + 1
 	return buf[:]
 }
 
@@ -240,6 +323,20 @@ func SchemaPrefix() []byte {
 	return buf[:]
 }
 
+// ReindexPrefix returns the prefix for Reindex keys.
+func ReindexPrefix() []byte {
+	var buf [1]byte
+	buf[0] = byteReindex
+	return buf[:]
+}
+
+// DropPrefix returns the prefix for Drop keys.
+func DropPrefix() []byte {
+	var buf [1]byte
+	buf[0] = byteDrop
+	return buf[:]
+}
+
 // PredicatePrefix returns the prefix for all keys belonging
 // to this predicate except schema key.
 func PredicatePrefix(predicate string) []byte {
@@ -263,7 +360,7 @@ func Parse(key []byte) *ParsedKey {
 	k = k[sz:]
 
 	switch p.bytePrefix {
-	case byteSchema:
+	case byteSchema, byteReindex, byteReplicate, byteSnapshot, byteDrop, byteBackups:
 		return p
 	default:
 	}
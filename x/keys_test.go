@@ -17,6 +17,7 @@
 package x
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
 	"testing"
@@ -91,3 +92,74 @@ func TestSchemaKey(t *testing.T) {
 		require.Equal(t, sattr, pk.Attr)
 	}
 }
+
+func TestReindexKey(t *testing.T) {
+	key := ReindexKey("name")
+	pk := Parse(key)
+
+	require.True(t, pk.IsReindex())
+	require.False(t, pk.IsSchema())
+	require.Equal(t, "name", pk.Attr)
+	require.True(t, bytes.HasPrefix(key, ReindexPrefix()))
+}
+
+func TestReplicatedKey(t *testing.T) {
+	pk := Parse(ReplicatedKey())
+
+	require.True(t, pk.IsReplicated())
+	require.False(t, pk.IsSchema())
+	require.False(t, pk.IsReindex())
+	require.Equal(t, "", pk.Attr)
+}
+
+func TestSnapshotKey(t *testing.T) {
+	pk := Parse(SnapshotKey())
+
+	require.True(t, pk.IsSnapshot())
+	require.False(t, pk.IsReplicated())
+	require.False(t, pk.IsSchema())
+	require.Equal(t, "", pk.Attr)
+}
+
+func TestDropKey(t *testing.T) {
+	key := DropKey("name")
+	pk := Parse(key)
+
+	require.True(t, pk.IsDrop())
+	require.False(t, pk.IsSchema())
+	require.Equal(t, "name", pk.Attr)
+	require.True(t, bytes.HasPrefix(key, DropPrefix()))
+
+	// A drop of all the data.
+	pk = Parse(DropKey(""))
+	require.True(t, pk.IsDrop())
+	require.Equal(t, "", pk.Attr)
+}
+
+func TestBackupsKey(t *testing.T) {
+	pk := Parse(BackupsKey())
+
+	require.True(t, pk.IsBackups())
+	require.False(t, pk.IsDrop())
+	require.False(t, pk.IsSchema())
+	require.Equal(t, "", pk.Attr)
+}
+
+func TestIsPredicate(t *testing.T) {
+	for _, key := range [][]byte{DataKey("name", 1), IndexKey("name", "a"),
+		ReverseKey("friend", 1), CountKey("friend", 1, false), SchemaKey("name")} {
+		require.True(t, Parse(key).IsPredicate(), "%q", key)
+	}
+	for _, key := range [][]byte{ReindexKey("name"), DropKey("name"), DropKey(""),
+		ReplicatedKey(), SnapshotKey(), BackupsKey()} {
+		require.False(t, Parse(key).IsPredicate(), "%q", key)
+	}
+}
+
+func TestSkipSchema(t *testing.T) {
+	skip := Parse(SchemaKey("name")).SkipSchema()
+	for _, key := range [][]byte{SchemaKey("name"), ReindexKey("name"), DropKey("name"),
+		DropKey(""), ReplicatedKey(), SnapshotKey()} {
+		require.True(t, bytes.Compare(key, skip) < 0, "%q", key)
+	}
+}
@@ -52,6 +52,20 @@ var (
 	MaxPlSize        *expvar.Int
 	MaxPlLength      *expvar.Int
 
+	// backups, restores and replication
+	BackupBytes         *expvar.Int
+	BackupKeys          *expvar.Int
+	BackupFailures      *expvar.Int
+	BackupDuration      *expvar.Int
+	BackupLastSuccess   *expvar.Int
+	RestoreBytes        *expvar.Int
+	RestoreKeys         *expvar.Int
+	RestoreFailures     *expvar.Int
+	RestoreDuration     *expvar.Int
+	RestoreLastSuccess  *expvar.Int
+	ReplicationLag      *expvar.Int
+	ReplicationFailures *expvar.Int
+
 	PredicateStats *expvar.Map
 	Conf           *expvar.Map
 
@@ -86,6 +100,18 @@ func init() {
 	LcacheCapacity = expvar.NewInt("dgraph_lru_capacity_bytes")
 	MaxPlSize = expvar.NewInt("dgraph_max_list_bytes")
 	MaxPlLength = expvar.NewInt("dgraph_max_list_length")
+	BackupBytes = expvar.NewInt("dgraph_backup_written_bytes_total")
+	BackupKeys = expvar.NewInt("dgraph_backup_keys_total")
+	BackupFailures = expvar.NewInt("dgraph_backup_failures_total")
+	BackupDuration = expvar.NewInt("dgraph_backup_duration_seconds")
+	BackupLastSuccess = expvar.NewInt("dgraph_backup_last_success_timestamp_seconds")
+	RestoreBytes = expvar.NewInt("dgraph_restore_read_bytes_total")
+	RestoreKeys = expvar.NewInt("dgraph_restore_keys_total")
+	RestoreFailures = expvar.NewInt("dgraph_restore_failures_total")
+	RestoreDuration = expvar.NewInt("dgraph_restore_duration_seconds")
+	RestoreLastSuccess = expvar.NewInt("dgraph_restore_last_success_timestamp_seconds")
+	ReplicationLag = expvar.NewInt("dgraph_replication_lag_seconds")
+	ReplicationFailures = expvar.NewInt("dgraph_replication_failures_total")
 
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
@@ -225,6 +251,66 @@ func init() {
 			"dgraph_predicate_stats",
 			[]string{"name"}, nil,
 		),
+		"dgraph_backup_written_bytes_total": prometheus.NewDesc(
+			"dgraph_backup_written_bytes_total",
+			"dgraph_backup_written_bytes_total",
+			nil, nil,
+		),
+		"dgraph_backup_keys_total": prometheus.NewDesc(
+			"dgraph_backup_keys_total",
+			"dgraph_backup_keys_total",
+			nil, nil,
+		),
+		"dgraph_backup_failures_total": prometheus.NewDesc(
+			"dgraph_backup_failures_total",
+			"dgraph_backup_failures_total",
+			nil, nil,
+		),
+		"dgraph_backup_duration_seconds": prometheus.NewDesc(
+			"dgraph_backup_duration_seconds",
+			"dgraph_backup_duration_seconds",
+			nil, nil,
+		),
+		"dgraph_backup_last_success_timestamp_seconds": prometheus.NewDesc(
+			"dgraph_backup_last_success_timestamp_seconds",
+			"dgraph_backup_last_success_timestamp_seconds",
+			nil, nil,
+		),
+		"dgraph_restore_read_bytes_total": prometheus.NewDesc(
+			"dgraph_restore_read_bytes_total",
+			"dgraph_restore_read_bytes_total",
+			nil, nil,
+		),
+		"dgraph_restore_keys_total": prometheus.NewDesc(
+			"dgraph_restore_keys_total",
+			"dgraph_restore_keys_total",
+			nil, nil,
+		),
+		"dgraph_restore_failures_total": prometheus.NewDesc(
+			"dgraph_restore_failures_total",
+			"dgraph_restore_failures_total",
+			nil, nil,
+		),
+		"dgraph_restore_duration_seconds": prometheus.NewDesc(
+			"dgraph_restore_duration_seconds",
+			"dgraph_restore_duration_seconds",
+			nil, nil,
+		),
+		"dgraph_restore_last_success_timestamp_seconds": prometheus.NewDesc(
+			"dgraph_restore_last_success_timestamp_seconds",
+			"dgraph_restore_last_success_timestamp_seconds",
+			nil, nil,
+		),
+		"dgraph_replication_lag_seconds": prometheus.NewDesc(
+			"dgraph_replication_lag_seconds",
+			"dgraph_replication_lag_seconds",
+			nil, nil,
+		),
+		"dgraph_replication_failures_total": prometheus.NewDesc(
+			"dgraph_replication_failures_total",
+			"dgraph_replication_failures_total",
+			nil, nil,
+		),
 		"badger_disk_reads_total": prometheus.NewDesc(
 			"badger_disk_reads_total",
 			"badger_disk_reads_total",
@@ -106,6 +106,28 @@ func parseCertificate(required bool, certFile, keyFile string) (*tls.Certificate
 	return &cert, nil
 }
 
+// CertNames returns the names that a certificate identifies its owner by: the common name of
+// its subject, then its DNS, email and URI subject alternative names.
+func CertNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// VerifiesClientCerts returns whether the client certificates are verified against the CAs
+// with the client auth type authType, so that their names can be trusted.
+func VerifiesClientCerts(authType string) bool {
+	auth, err := setupClientAuth(authType)
+	return err == nil && auth >= tls.VerifyClientCertIfGiven
+}
+
 func setupClientAuth(authType string) (tls.ClientAuthType, error) {
 	auth := map[string]tls.ClientAuthType{
 		"REQUEST":          tls.RequestClientCert,
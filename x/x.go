@@ -139,7 +139,8 @@ func AddCorsHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Headers",
 		"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, X-Auth-Token, "+
 			"Cache-Control, X-Requested-With, X-Dgraph-CommitNow, X-Dgraph-Vars, "+
-			"X-Dgraph-MutationType, X-Dgraph-IgnoreIndexConflict")
+			"X-Dgraph-MutationType, X-Dgraph-IgnoreIndexConflict, X-Dgraph-Timeout, "+
+			"X-Dgraph-Max-Depth, X-Dgraph-Max-Edges, X-Dgraph-Partial")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 	w.Header().Set("Connection", "close")
 }
@@ -212,6 +212,32 @@ func (m *XidMap) EvictAll() {
 	}
 }
 
+// Flush persists the mappings assigned so far, without evicting them from the cache. A load
+// that's interrupted after a Flush can be resumed with the same uids for those xids.
+func (m *XidMap) Flush() {
+	for i := range m.shards {
+		m.shards[i].Lock()
+		m.shards[i].flush()
+		m.shards[i].Unlock()
+	}
+}
+
+func (s *shard) flush() {
+	txn := s.xm.kv.NewTransaction(true)
+	defer txn.Discard()
+	for elem := s.queue.Front(); elem != nil; elem = elem.Next() {
+		m := elem.Value.(*mapping)
+		if m.persisted {
+			continue
+		}
+		var uidBuf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(uidBuf[:], m.uid)
+		x.Check(txn.Set([]byte(m.xid), uidBuf[:n]))
+		m.persisted = true
+	}
+	x.Check(txn.Commit())
+}
+
 func (s *shard) evict(ratio float64) {
 	evict := int(float64(s.queue.Len()) * ratio)
 	s.beingEvicted = make(map[string]uint64)